@@ -5,13 +5,56 @@ import java.io.OutputStream;
 import java.io.InputStream;
 import java.io.File;
 import java.io.IOException;
+import java.security.DigestInputStream;
+import java.security.MessageDigest;
+import java.util.Locale;
 
 public class LoadJNI {
 	static {
+		if (Go.LAZY && !Go.loadRequested()) {
+			throw new IllegalStateException("Go native library is loaded lazily, call go.Go.load() first");
+		}
+		if (!linked()) {
+			try {
+				loadLibrary();
+			} catch (IOException ex) {
+				throw new RuntimeException(ex);
+			}
+		}
+		checkABI();
+		GoRuntime.configure();
+		init();
+	}
+
+	// init runs the GojavaInit functions of the bound packages.
+	private static native void init();
+
+	// abiVersion returns the ABI version the native library was built for.
+	private static native String abiVersion();
+
+	// linked reports whether the natives are already linked, by a native
+	// library built with -jni-archive that the application loaded itself.
+	private static boolean linked() {
 		try {
-			loadLibrary();
-		} catch (IOException ex) {
-			throw new RuntimeException(ex);
+			abiVersion();
+			return true;
+		} catch (UnsatisfiedLinkError e) {
+			return false;
+		}
+	}
+
+	// checkABI fails fast if the native library was not built with these
+	// classes, as when the jar and the platform jar come from different
+	// builds, rather than crashing or failing in the middle of a call.
+	private static void checkABI() {
+		String lib;
+		try {
+			lib = abiVersion();
+		} catch (UnsatisfiedLinkError e) {
+			lib = "none";
+		}
+		if (!Go.ABI_VERSION.equals(lib)) {
+			throw new UnsatisfiedLinkError("Go JNI library has ABI version " + lib + ", but the Java classes of the bindings need " + Go.ABI_VERSION + ": the jar and the native library, or platform jar, come from different gojava builds");
 		}
 	}
 
@@ -21,7 +64,17 @@ public class LoadJNI {
 
 		InputStream input = LoadJNI.class.getResourceAsStream("/go/libgojava");
 		if (input == null) {
-			throw new RuntimeException("Go JNI library not found in classpath");
+			// Built with -platform-jars, the library is in the jar with the
+			// classifier of this platform.
+			input = LoadJNI.class.getResourceAsStream("/go/native/" + platformClassifier() + "/libgojava");
+		}
+		if (input == null) {
+			throw new RuntimeException("Go JNI library not found in classpath, add the jar with the " + platformClassifier() + " classifier");
+		}
+		MessageDigest digest = null;
+		if (!Go.NATIVE_SHA256.isEmpty()) {
+			digest = Go.newDigest();
+			input = new DigestInputStream(input, digest);
 		}
 		OutputStream out = new FileOutputStream(temp);
 		try {
@@ -34,6 +87,48 @@ public class LoadJNI {
 			out.close();
 			input.close();
 		}
+		if (digest != null) {
+			try {
+				Go.checkDigest(digest);
+			} catch (SecurityException ex) {
+				temp.delete();
+				throw ex;
+			}
+		}
 		System.load(temp.getAbsolutePath());
 	}
+
+	// platformClassifier returns the classifier of the platform jar for this
+	// JVM, normalized like os-maven-plugin, e.g. linux-x86_64 or osx-aarch_64.
+	static String platformClassifier() {
+		String os = System.getProperty("os.name").toLowerCase(Locale.ROOT).replaceAll("[^a-z0-9]+", "");
+		String arch = System.getProperty("os.arch").toLowerCase(Locale.ROOT).replaceAll("[^a-z0-9]+", "");
+		if (os.startsWith("mac") || os.startsWith("osx") || os.startsWith("darwin")) {
+			os = "osx";
+		} else if (os.startsWith("windows")) {
+			os = "windows";
+		} else if (os.startsWith("linux")) {
+			os = "linux";
+		} else if (os.startsWith("sunos") || os.startsWith("solaris")) {
+			os = "sunos";
+		}
+		if (arch.matches("x8664|amd64|ia32e|em64t|x64")) {
+			arch = "x86_64";
+		} else if (arch.matches("x8632|x86|i[3-6]86|ia32|x32")) {
+			arch = "x86_32";
+		} else if (arch.matches("aarch64|arm64")) {
+			arch = "aarch_64";
+		} else if (arch.matches("arm|arm32")) {
+			arch = "arm_32";
+		} else if (arch.equals("ppc64le")) {
+			arch = "ppcle_64";
+		} else if (arch.equals("ppc64")) {
+			arch = "ppc_64";
+		} else if (arch.equals("s390x")) {
+			arch = "s390_64";
+		} else if (arch.equals("loongarch64")) {
+			arch = "loongarch_64";
+		}
+		return os + "-" + arch;
+	}
 }
\ No newline at end of file
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// gojavaABI is the version of the conventions shared by the generated Java
+// classes and the native library that are not visible in the declarations of
+// their native methods, such as the encoding of the Seq protocol. Increment it
+// when changing them.
+const gojavaABI = 1
+
+// abiVersion returns the ABI version of bindings with the Java sources
+// javaFiles: gojavaABI and a digest of the native methods they declare, which
+// the native library must implement.
+func abiVersion(javaFiles []string) (string, error) {
+	var natives []string
+	for _, f := range javaFiles {
+		src, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		for _, m := range nativeDecl.FindAllStringSubmatch(string(src), -1) {
+			natives = append(natives, fmt.Sprintf("%s %s %s(%s)", filepath.Base(f), m[3], m[4], m[5]))
+		}
+	}
+	sort.Strings(natives)
+	h := sha256.New()
+	for _, n := range natives {
+		fmt.Fprintln(h, n)
+	}
+	return fmt.Sprintf("%d.%x", gojavaABI, h.Sum(nil)[:8]), nil
+}
+
+// genABIHooks writes the Go and C code to bindDir implementing the native
+// method of go.LoadJNI returning abi, the ABI version the library is built
+// for, which LoadJNI checks against go.Go.ABI_VERSION once it is loaded.
+func genABIHooks(bindDir, abi string) error {
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_abi.go"), []byte(fmt.Sprintf(abiHooksGo, abi)), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_abi.c"), []byte(abiHooksC), 0600)
+}
+
+const abiHooksGo = `package gojava_bind
+
+import "C"
+
+//export gojava_abi_version
+func gojava_abi_version() *C.char {
+	return C.CString(%q)
+}
+`
+
+const abiHooksC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jstring JNICALL Java_go_LoadJNI_abiVersion(JNIEnv *env, jclass clazz) {
+	char *v = gojava_abi_version();
+	jstring s = (*env)->NewStringUTF(env, v);
+	free(v);
+	return s;
+}
+`
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestABIVersion(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	a, b := filepath.Join(tmpDir, "A.java"), filepath.Join(tmpDir, "B.java")
+	write := func(path, src string) {
+		if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "class A {\n\tpublic static native long f(long x);\n}\n")
+	write(b, "class B {\n\tprivate static native void g();\n}\n")
+	v1, err := abiVersion([]string{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v1, "1.") {
+		t.Errorf("version %s does not start with gojavaABI", v1)
+	}
+	if v, _ := abiVersion([]string{b, a}); v != v1 {
+		t.Errorf("version depends on the order of the files: %s != %s", v, v1)
+	}
+	write(a, "class A {\n\tpublic static native long f(long x, long y);\n}\n")
+	if v, _ := abiVersion([]string{a, b}); v == v1 {
+		t.Errorf("version %s did not change with a native method", v)
+	}
+}
+
+func TestGenABIHooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := genABIHooks(tmpDir, "1.0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_abi.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil || !strings.Contains(string(d), `C.CString("1.0123456789abcdef")`) {
+		t.Errorf("bad hooks (%v):\n%s", err, d)
+	}
+	java, err := ioutil.ReadFile("LoadJNI.java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range nativeDecl.FindAllStringSubmatch(string(java), -1) {
+		if fn := "Java_go_LoadJNI_" + m[4] + "("; !strings.Contains(abiHooksC+initHooksC, fn) {
+			t.Errorf("LoadJNI.%s is not implemented", m[4])
+		}
+	}
+}
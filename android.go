@@ -0,0 +1,42 @@
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// androidPageSize is the page size the native library is aligned for when
+// building for Android. Google Play requires 16KB alignment for 64-bit
+// libraries, which also load on devices with 4KB pages.
+const androidPageSize = 16384
+
+// androidLinkFlags are the external linker flags aligning the ELF segments
+// of the native library for androidPageSize.
+var androidLinkFlags = fmt.Sprintf("-Wl,-z,max-page-size=%d,-z,common-page-size=%d", androidPageSize, androidPageSize)
+
+// goTargetOS returns the GOOS the native library is built for, which differs
+// from the host when GOOS is set in the environment, e.g. with -env.
+func goTargetOS() (string, error) {
+	out, err := commandOutput("go", "env", "GOOS")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkPageAlignment returns an error if a loadable segment of the ELF shared
+// library at path is not aligned for androidPageSize.
+func checkPageAlignment(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Align < androidPageSize {
+			return fmt.Errorf("%s: segment at %#x is aligned to %d bytes, Android requires %d; use the linker of NDK r27 or later", path, p.Vaddr, p.Align, androidPageSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeELF writes a 64-bit ELF shared library to path with a single loadable
+// segment aligned to align.
+func writeELF(t *testing.T, path string, align uint64) {
+	var b bytes.Buffer
+	h := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_AARCH64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	}
+	copy(h.Ident[:], elf.ELFMAG)
+	h.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	h.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	h.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	p := elf.Prog64{Type: uint32(elf.PT_LOAD), Flags: uint32(elf.PF_R | elf.PF_X), Filesz: 120, Memsz: 120, Align: align}
+	for _, v := range []interface{}{h, p} {
+		if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckPageAlignment(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, tc := range []struct {
+		align uint64
+		ok    bool
+	}{
+		{4096, false},
+		{16384, true},
+		{65536, true},
+	} {
+		path := filepath.Join(tmpDir, "libgojava")
+		writeELF(t, path, tc.align)
+		if err := checkPageAlignment(path); (err == nil) != tc.ok {
+			t.Errorf("align %d: got error %v, want ok %v", tc.align, err, tc.ok)
+		}
+	}
+}
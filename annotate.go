@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// docFinder finds the doc comments of Go declarations by parsing the source
+// files recorded in the positions of the bound packages.
+type docFinder struct {
+	fset  *token.FileSet
+	srcs  *token.FileSet
+	files map[string]*ast.File
+}
+
+func newDocFinder(fset *token.FileSet) *docFinder {
+	return &docFinder{fset: fset, srcs: token.NewFileSet(), files: make(map[string]*ast.File)}
+}
+
+// file returns the parsed source file declaring obj, and the position of
+// obj, or nil if its source is not available.
+func (d *docFinder) file(obj types.Object) (*ast.File, token.Position) {
+	pos := d.fset.Position(obj.Pos())
+	if !pos.IsValid() {
+		return nil, pos
+	}
+	f, ok := d.files[pos.Filename]
+	if !ok {
+		// Sources may be missing, for instance if they were built with -trimpath.
+		f, _ = parser.ParseFile(d.srcs, pos.Filename, nil, parser.ParseComments)
+		d.files[pos.Filename] = f
+	}
+	return f, pos
+}
+
+// funcDecl returns the declaration of fn, with the file declaring it, or nil
+// if its source is not available.
+func (d *docFinder) funcDecl(fn *types.Func) (*ast.FuncDecl, *ast.File) {
+	f, pos := d.file(fn)
+	if f == nil {
+		return nil, nil
+	}
+	for _, decl := range f.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Name.Name == fn.Name() && d.srcs.Position(fd.Name.Pos()).Line == pos.Line {
+			return fd, f
+		}
+	}
+	return nil, nil
+}
+
+// comments returns the doc comment of the declaration of obj, or nil if it
+// has none or its source is not available.
+func (d *docFinder) comments(obj types.Object) *ast.CommentGroup {
+	f, pos := d.file(obj)
+	if f == nil {
+		return nil
+	}
+	match := func(id *ast.Ident) bool {
+		return id.Name == obj.Name() && d.srcs.Position(id.Pos()).Line == pos.Line
+	}
+	var doc *ast.CommentGroup
+	found := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if match(n.Name) {
+				found, doc = true, n.Doc
+			}
+		case *ast.GenDecl:
+			for _, s := range n.Specs {
+				var ids []*ast.Ident
+				var specDoc *ast.CommentGroup
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					ids, specDoc = []*ast.Ident{s.Name}, s.Doc
+				case *ast.ValueSpec:
+					ids, specDoc = s.Names, s.Doc
+				}
+				if specDoc == nil && !n.Lparen.IsValid() {
+					specDoc = n.Doc
+				}
+				for _, id := range ids {
+					if match(id) {
+						found, doc = true, specDoc
+					}
+				}
+			}
+		case *ast.Field:
+			for _, id := range n.Names {
+				if match(id) {
+					found, doc = true, n.Doc
+				}
+			}
+		}
+		return !found
+	})
+	return doc
+}
+
+// doc returns the text of the doc comment of obj.
+func (d *docFinder) doc(obj types.Object) string {
+	return d.comments(obj).Text()
+}
+
+// directives returns the //gojava: directives in the doc comment of obj,
+// without the //gojava: prefix.
+func (d *docFinder) directives(obj types.Object) []string {
+	cg := d.comments(obj)
+	if cg == nil {
+		return nil
+	}
+	var dirs []string
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//gojava:") {
+			dirs = append(dirs, strings.TrimSpace(c.Text[len("//gojava:"):]))
+		}
+	}
+	return dirs
+}
+
+// stabilityLevel returns the Java annotation for the stability directive of
+// obj, or "" if it has none.
+func (d *docFinder) stabilityLevel(obj types.Object) string {
+	for _, dir := range d.directives(obj) {
+		if ann, ok := stabilityLevels[dir]; ok {
+			return ann
+		}
+	}
+	return ""
+}
+
+// deprecation returns the message of the "Deprecated: " paragraph of the doc
+// comment doc, or "" if there is none.
+func deprecation(doc string) string {
+	for _, para := range strings.Split(doc, "\n\n") {
+		if strings.HasPrefix(para, "Deprecated: ") {
+			return strings.Join(strings.Fields(para[len("Deprecated: "):]), " ")
+		}
+	}
+	return ""
+}
+
+// topLevelType matches the declarations of the top level types of generated
+// Java files, which are not indented.
+var topLevelType = regexp.MustCompile(`(?m)^(?:(?:public|abstract|final) )*(?:class|interface|enum) `)
+
+// suppressDeprecations adds @SuppressWarnings("deprecation") to the top level
+// types of the generated Java files if a member of pkgs is deprecated. The
+// generated code calling the deprecated members, like the builders or the
+// registry, would otherwise fail to compile with -strict-java, while the
+// members keep their @Deprecated annotation for the callers of the bindings.
+func suppressDeprecations(files []string, pkgs []*types.Package, docs *docFinder) error {
+	deprecated := false
+	for _, p := range pkgs {
+		forEachMember(p, false, func(class, member string, method bool, obj types.Object) {
+			deprecated = deprecated || deprecation(docs.doc(obj)) != ""
+		})
+	}
+	if !deprecated {
+		return nil
+	}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var edits []edit
+		for _, loc := range topLevelType.FindAllIndex(src, -1) {
+			edits = append(edits, edit{loc[0], loc[0], "@SuppressWarnings(\"deprecation\")\n"})
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		if err := ioutil.WriteFile(file, applyEdits(src, edits), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotation is text inserted before the declaration of a member of a
+// generated Java class, or the removal of the member.
+type annotation struct {
+	// nested is the name of the type nested in the package class declaring
+	// the member, or "" for the package class.
+	nested string
+	// member is the name of the member, or "" for the nested type itself.
+	member string
+	// lines are the lines inserted before the declaration.
+	lines []string
+	// remove removes the member from the API. Methods are deleted, and types
+	// are made package private as other generated code may refer to them.
+	remove bool
+}
+
+// stabilityLevels maps the //gojava: directives marking unstable APIs to the
+// Java annotation of their members.
+var stabilityLevels = map[string]string{
+	"experimental": "@go.Experimental",
+	"internal":     "@go.Internal",
+}
+
+// javadocText returns s, collapsed to a single line and escaped for use in a
+// Javadoc comment.
+func javadocText(s string) string {
+	return strings.Replace(strings.Join(strings.Fields(s), " "), "*/", "*&#47;", -1)
+}
+
+// findAnnotations returns the annotations of the Java members bound to
+// deprecated Go declarations in p, and to declarations marked with a
+// //gojava:experimental or //gojava:internal directive. Members of the
+// latter are removed unless includeUnstable is set.
+func findAnnotations(p *types.Package, docs *docFinder, includeUnstable bool) []annotation {
+	var anns []annotation
+	forEachMember(p, false, func(class, member string, method bool, obj types.Object) {
+		a := annotation{nested: nestedName(class), member: member}
+		if ann := docs.stabilityLevel(obj); ann != "" {
+			if !includeUnstable {
+				if isInterfaceMethod(obj) {
+					verbosef("keeping %s.%s, it is required to implement the interface\n", a.nested, member)
+				} else {
+					anns = append(anns, annotation{nested: a.nested, member: member, remove: true})
+					return
+				}
+			}
+			a.lines = append(a.lines, ann)
+		}
+		if msg := deprecation(docs.doc(obj)); msg != "" {
+			a.lines = append([]string{"/** @deprecated " + javadocText(msg) + " */", "@Deprecated"}, a.lines...)
+		}
+		if len(a.lines) > 0 {
+			anns = append(anns, a)
+		}
+	})
+	return anns
+}
+
+// isInterfaceMethod reports whether obj is a method of an interface type.
+func isInterfaceMethod(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	return recv != nil && types.IsInterface(recv.Type())
+}
+
+// nestedName returns the name of the nested type in the binary class name, or
+// "" if it is a top level class.
+func nestedName(class string) string {
+	if i := strings.LastIndex(class, "$"); i >= 0 {
+		return class[i+1:]
+	}
+	return ""
+}
+
+// matchBrace returns the index of the brace closing the one at src[i], or -1.
+func matchBrace(src []byte, i int) int {
+	depth := 0
+	for ; i < len(src); i++ {
+		if j := skipLiteral(src, i); j != i {
+			i = j
+			continue
+		}
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// classBody returns the start of the declaration of the class or interface
+// named nested in the package class of p in src, or of the package class if
+// nested is "", and the bounds of its body.
+func classBody(src []byte, p *types.Package, nested string) (decl, start, end int, err error) {
+	class := javaClassName(p)
+	loc := regexp.MustCompile(`(?m)^.*\bclass\s+` + class + `\b`).FindIndex(src)
+	if loc == nil {
+		return 0, 0, 0, fmt.Errorf("class %s not found", class)
+	}
+	decl = loc[0]
+	if nested != "" {
+		found := false
+		for _, t := range findNestedTypes(src[loc[0]:]) {
+			if t.name == nested {
+				m := regexp.MustCompile(`\b(class|interface)\s+` + nested + `\b`).FindIndex(src[loc[0]+t.start:])
+				decl, found = loc[0]+t.start+m[0], true
+				break
+			}
+		}
+		if !found {
+			return 0, 0, 0, fmt.Errorf("type %s not found in class %s", nested, class)
+		}
+		decl = bytes.LastIndexByte(src[:decl], '\n') + 1
+	}
+	start = decl + bytes.IndexByte(src[decl:], '{')
+	if end = matchBrace(src, start); end < 0 {
+		return 0, 0, 0, fmt.Errorf("malformed declaration of %s", class)
+	}
+	return decl, start + 1, end, nil
+}
+
+// memberDecls returns the start of the lines declaring the members named name
+// directly in the class body src[start:end].
+func memberDecls(src []byte, start, end int, name string) []int {
+	top := make([]bool, end-start)
+	depth := 0
+	for i := start; i < end; i++ {
+		if j := skipLiteral(src, i); j != i {
+			i = j
+			continue
+		}
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		default:
+			top[i-start] = depth == 0
+		}
+	}
+	decl := regexp.MustCompile(`(?m)^[ \t]*[^\n;{}=/*]*(^|[^\p{L}\p{N}_$.])(` + regexp.QuoteMeta(name) + `)\s*[(=;]`)
+	var lines []int
+	for _, m := range decl.FindAllSubmatchIndex(src[start:end], -1) {
+		if top[m[4]] {
+			lines = append(lines, start+m[0])
+		}
+	}
+	return lines
+}
+
+// memberEnd returns the end of the member declared at src[at], including the
+// trailing newline.
+func memberEnd(src []byte, at int) int {
+	for i := at; i < len(src); i++ {
+		if j := skipLiteral(src, i); j != i {
+			i = j
+			continue
+		}
+		switch src[i] {
+		case '{':
+			i = matchBrace(src, i)
+			if i < 0 {
+				return len(src)
+			}
+			fallthrough
+		case ';':
+			if j := bytes.IndexByte(src[i:], '\n'); j >= 0 {
+				return i + j + 1
+			}
+			return len(src)
+		}
+	}
+	return len(src)
+}
+
+// annotateJava applies anns to the Java source src generated for p.
+// Annotations of members that are not found are skipped.
+func annotateJava(src []byte, p *types.Package, anns []annotation) []byte {
+	var edits []edit
+	add := func(at int, a annotation) {
+		switch {
+		case a.remove && a.member == "":
+			if i := bytes.Index(src[at:], []byte("public ")); i >= 0 {
+				edits = append(edits, edit{at + i, at + i + len("public "), ""})
+			}
+		case a.remove:
+			edits = append(edits, edit{at, memberEnd(src, at), ""})
+		default:
+			line := src[at:]
+			indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+			var b bytes.Buffer
+			for _, l := range a.lines {
+				b.Write(indent)
+				b.WriteString(l)
+				b.WriteByte('\n')
+			}
+			edits = append(edits, edit{at, at, b.String()})
+		}
+	}
+	for _, a := range anns {
+		decl, start, end, err := classBody(src, p, a.nested)
+		if err != nil {
+			verbosef("skipping annotation of %s.%s: %v\n", a.nested, a.member, err)
+			continue
+		}
+		if a.member == "" {
+			add(decl, a)
+			continue
+		}
+		for _, at := range memberDecls(src, start, end, a.member) {
+			add(at, a)
+		}
+	}
+	return applyEdits(src, edits)
+}
+
+// edit replaces src[at:end] with text.
+type edit struct {
+	at, end int
+	text    string
+}
+
+// applyEdits returns src with edits applied. They are applied from the end,
+// so the offsets of earlier edits stay valid, and edits at the same offset in
+// reverse order. src is not modified.
+func applyEdits(src []byte, edits []edit) []byte {
+	edits = append([]edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].at > edits[j].at })
+	for _, e := range edits {
+		out := make([]byte, 0, len(src)+len(e.text))
+		out = append(append(append(out, src[:e.at]...), e.text...), src[e.end:]...)
+		src = out
+	}
+	return src
+}
+
+// javadocEdit returns the edit adding lines to the Javadoc of the member
+// declared at src[at]: the text replacing src[start:end]. The Javadoc
+// generated from the Go doc comment is extended, if there is one, or the one
+// added with the annotations of the member.
+func javadocEdit(src []byte, at int, lines []string) (start, end int, text string) {
+	for at > 0 && src[at-1] == '\n' {
+		i := bytes.LastIndexByte(src[:at-1], '\n') + 1
+		if !bytes.HasPrefix(bytes.TrimLeft(src[i:at], " \t"), []byte("@")) {
+			break
+		}
+		at = i
+	}
+	line := src[at:]
+	indent := string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString("\n" + indent + " * " + l)
+	}
+	before := bytes.TrimRight(src[:at], " \t\n")
+	if c := bytes.LastIndex(before, []byte("/**")); bytes.HasSuffix(before, []byte("*/")) && c >= 0 && !bytes.Contains(before[c+3:len(before)-2], []byte("*/")) {
+		text := bytes.TrimRight(before[:len(before)-2], " \t\n")
+		return len(text), len(before) - 2, b.String() + "\n" + indent + " "
+	}
+	return at, at, indent + "/**" + b.String() + "\n" + indent + " */\n"
+}
+
+// annotatePackage rewrites the generated Java file for p at path, applying
+// the annotations found by findAnnotations. If exposed is not nil, only the
+// functions in it and their type closure are kept in the API.
+func annotatePackage(path string, p *types.Package, docs *docFinder, includeUnstable bool, exposed []string) error {
+	anns := findAnnotations(p, docs, includeUnstable)
+	if exposed != nil {
+		removed, err := exposeAnnotations(p, exposed)
+		if err != nil {
+			return err
+		}
+		// Drop the other annotations of removed members, whose edits would
+		// overlap the removal.
+		gone := make(map[string]bool)
+		for _, a := range removed {
+			gone[a.nested+"."+a.member] = true
+		}
+		var kept []annotation
+		for _, a := range anns {
+			if !gone[a.nested+"."+a.member] {
+				kept = append(kept, a)
+			}
+		}
+		anns = append(kept, removed...)
+	}
+	if len(anns) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, annotateJava(src, p, anns), 0600)
+}
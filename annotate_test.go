@@ -0,0 +1,277 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const deprecatedSrc = `package testpkg
+
+// S is a struct.
+//
+// Deprecated: use T, which
+// is faster.
+type S struct {
+	// Deprecated: unused.
+	X int
+}
+
+// F does nothing.
+func (s *S) F() {}
+
+// Deprecated: use NewT.
+func NewS() *S { return nil }
+
+const (
+	// Deprecated: too small.
+	Small = 1
+)
+`
+
+const deprecatedJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class S extends Seq.Proxy {
+        public final native long getX();
+        public final native void setX(long v);
+        public native void f();
+    }
+
+    public static final long Small = 1L;
+    public static native S newS();
+}
+`
+
+func TestAddDeprecations(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath, javaPath := filepath.Join(tmpDir, "s.go"), filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(goPath, []byte(deprecatedSrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(javaPath, []byte(deprecatedJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goPath, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := annotatePackage(javaPath, p, newDocFinder(fset), false, nil); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"    /** @deprecated use T, which is faster. */\n    @Deprecated\n    public static final class S",
+		"        /** @deprecated unused. */\n        @Deprecated\n        public final native long getX();",
+		"        @Deprecated\n        public final native void setX(",
+		"    }\n\n    /** @deprecated too small. */\n    @Deprecated\n    public static final long Small",
+		"    /** @deprecated use NewT. */\n    @Deprecated\n    public static native S newS();",
+		"\n        public native void f();",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("missing %q:\n%s", s, d)
+		}
+	}
+}
+
+const unstableSrc = `package testpkg
+
+// S is a struct.
+//
+//gojava:experimental
+type S struct{}
+
+// I is an interface.
+type I interface {
+	//gojava:internal
+	F()
+}
+
+// NewS returns a new S.
+//
+// Deprecated: use a literal.
+//
+//gojava:experimental
+func NewS() *S { return nil }
+`
+
+const unstableJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class S extends Seq.Proxy {
+        public S() { super(); }
+    }
+
+    public interface I extends go.Seq.Object {
+        public void f();
+    }
+
+    public static native S newS();
+    public static void touch() {}
+}
+`
+
+func TestAnnotateUnstable(t *testing.T) {
+	fset := token.NewFileSet()
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath := filepath.Join(tmpDir, "s.go")
+	if err := ioutil.WriteFile(goPath, []byte(unstableSrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(fset, goPath, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := newDocFinder(fset)
+
+	excluded := string(annotateJava([]byte(unstableJava), p, findAnnotations(p, docs, false)))
+	for _, s := range []string{"\n    static final class S extends", "    public interface I", "        public void f();", "    }\n\n    public static void touch()"} {
+		if !strings.Contains(excluded, s) {
+			t.Errorf("missing %q:\n%s", s, excluded)
+		}
+	}
+	if strings.Contains(excluded, "newS") {
+		t.Errorf("experimental function not removed:\n%s", excluded)
+	}
+
+	included := string(annotateJava([]byte(unstableJava), p, findAnnotations(p, docs, true)))
+	for _, s := range []string{
+		"    @go.Experimental\n    public static final class S",
+		"        @go.Internal\n        public void f();",
+		"    @Deprecated\n    @go.Experimental\n    public static native S newS();",
+	} {
+		if !strings.Contains(included, s) {
+			t.Errorf("missing %q:\n%s", s, included)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	src := []byte("abcdef")
+	got := applyEdits(src, []edit{{1, 2, "B"}, {4, 4, "1"}, {4, 4, "2"}, {5, 6, ""}, {0, 0, "<"}})
+	if want := "<aBcd21e"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if string(src) != "abcdef" {
+		t.Errorf("src was modified: %q", src)
+	}
+}
+
+func TestSuppressDeprecations(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "SBuilder.java")
+	builder := "package go.testpkg;\n\npublic final class SBuilder {\n\tpublic static class Nested {\n\t}\n}\n\nfinal class Helper {\n}\n"
+	write := func() {
+		if err := ioutil.WriteFile(path, []byte(builder), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() string {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(d)
+	}
+
+	write()
+	p, docs := typeCheckFile(t, "package testpkg\n\nfunc F() {}\n")
+	if err := suppressDeprecations([]string{path}, []*types.Package{p}, docs); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != builder {
+		t.Errorf("changed without deprecations:\n%s", got)
+	}
+
+	p, docs = typeCheckFile(t, deprecatedSrc)
+	if err := suppressDeprecations([]string{path}, []*types.Package{p}, docs); err != nil {
+		t.Fatal(err)
+	}
+	want := "package go.testpkg;\n\n@SuppressWarnings(\"deprecation\")\npublic final class SBuilder {\n\tpublic static class Nested {\n\t}\n}\n\n@SuppressWarnings(\"deprecation\")\nfinal class Helper {\n}\n"
+	if got := read(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+const strictDeprecatedSrc = `package strict
+
+// Config configures the service.
+//
+// Deprecated: use Options.
+type Config struct {
+	A, B, C, D, E int
+}
+
+// Options configures the service.
+type Options struct {
+	Name string
+	// Deprecated: use Name.
+	Label   string
+	A, B, C int
+}
+
+// Deprecated: use NewOptions.
+func NewConfig() *Config { return &Config{} }
+
+func NewOptions() *Options { return &Options{} }
+
+// Deprecated: use Apply.
+func Configure(c *Config) int { return c.A }
+
+func Apply(o *Options) int { return o.A }
+`
+
+// TestStrictJavaDeprecations binds a package with deprecated declarations,
+// builders and the registry with -strict-java, which fails on the
+// deprecation warnings of the generated code calling them.
+func TestStrictJavaDeprecations(t *testing.T) {
+	if _, err := exec.LookPath("javac"); err != nil {
+		t.Skip("javac not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "src")
+	for name, content := range map[string]string{"go.mod": "module example.com/strict\n\ngo 1.16\n", "strict.go": strictDeprecatedSrc} {
+		if err := writeJavaFile(filepath.Join(src, name), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jar := filepath.Join(tmpDir, "strict.jar")
+	if err := bindToJar(&config{target: jar, strictJava: true, registry: true}, src, "."); err != nil {
+		t.Fatal(err)
+	}
+}
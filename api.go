@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// apiManifestPath is the path of the API manifest in the jar.
+const apiManifestPath = "META-INF/gojava/api.txt"
+
+// apiManifest returns the API of the Java members generated for pkgs, keyed
+// by Java name. Each member maps to the Go signature it binds.
+func apiManifest(pkgs []*types.Package, split bool) map[string]string {
+	api := make(map[string]string)
+	for _, p := range pkgs {
+		qual := types.RelativeTo(p)
+		forEachMember(p, split, func(class, member string, method bool, obj types.Object) {
+			name := strings.Replace(class, "$", ".", -1)
+			if member != "" {
+				name += "." + member
+			}
+			sig := types.TypeString(obj.Type(), qual)
+			if _, ok := obj.(*types.TypeName); ok {
+				sig = types.TypeString(obj.Type().Underlying(), qual)
+			}
+			api[name] = strings.Join(strings.Fields(sig), " ")
+		})
+	}
+	return api
+}
+
+// formatAPI returns api as sorted lines of the Java name and Go signature
+// separated by a tab.
+func formatAPI(api map[string]string) []byte {
+	names := make([]string, 0, len(api))
+	for n := range api {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, n := range names {
+		fmt.Fprintf(&b, "%s\t%s\n", n, api[n])
+	}
+	return b.Bytes()
+}
+
+// parseAPI parses an API manifest written by formatAPI.
+func parseAPI(d []byte) map[string]string {
+	api := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(d))
+	for s.Scan() {
+		if f := strings.SplitN(s.Text(), "\t", 2); len(f) == 2 {
+			api[f[0]] = f[1]
+		}
+	}
+	return api
+}
+
+// readJarAPI returns the API manifest in the jar at path, or nil if the jar
+// does not exist or was built without one.
+func readJarAPI(path string) (map[string]string, error) {
+	r, err := zip.OpenReader(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name != apiManifestPath {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		d, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		return parseAPI(d), nil
+	}
+	return nil, nil
+}
+
+// breakingChanges returns the members of old that were removed or changed in
+// api, sorted by name.
+func breakingChanges(old, api map[string]string) []string {
+	var changes []string
+	for n, sig := range old {
+		switch newSig, ok := api[n]; {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("removed %s (%s)", n, sig))
+		case newSig != sig:
+			changes = append(changes, fmt.Sprintf("changed %s from %s to %s", n, sig, newSig))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// checkAPI compares the API of pkgs with the manifest of the jar being
+// replaced at cfg.target, and fails if members were removed or changed,
+// unless cfg.allowBreaking is set.
+func checkAPI(cfg *config, pkgs []*types.Package) error {
+	old, err := readJarAPI(targetPath(cfg))
+	if err != nil {
+		return fmt.Errorf("reading the API of %s: %v", cfg.target, err)
+	}
+	changes := breakingChanges(old, apiManifest(pkgs, cfg.split))
+	if len(changes) == 0 {
+		return nil
+	}
+	if cfg.allowBreaking {
+		for _, c := range changes {
+			fmt.Fprintln(os.Stderr, "warning: breaking change:", c)
+		}
+		return nil
+	}
+	return fmt.Errorf("breaking changes to the API of %s:\n\t%s\nPass -allow-breaking to replace it, and bump the major version of the jar", cfg.target, strings.Join(changes, "\n\t"))
+}
+
+// writeAPIManifest writes the API manifest of pkgs to jarDir.
+func writeAPIManifest(jarDir string, pkgs []*types.Package, split bool) error {
+	path := filepath.Join(jarDir, filepath.FromSlash(apiManifestPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, formatAPI(apiManifest(pkgs, split)), 0600)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestAPIManifest(t *testing.T) {
+	_, p := typeCheckMembers(t)
+	api := apiManifest([]*types.Package{p}, false)
+	exp := map[string]string{
+		"go.testpkg.Testpkg.Point":       "struct{X int; y int}",
+		"go.testpkg.Testpkg.Point.getX":  "int",
+		"go.testpkg.Testpkg.Point.setX":  "int",
+		"go.testpkg.Testpkg.Point.wait_": "func()",
+		"go.testpkg.Testpkg.getOrigin":   "Point",
+		"go.testpkg.Testpkg.setOrigin":   "Point",
+		"go.testpkg.Testpkg.newPoint":    "func() *Point",
+	}
+	if !reflect.DeepEqual(api, exp) {
+		t.Errorf("expected %v, got %v", exp, api)
+	}
+	if got := parseAPI(formatAPI(api)); !reflect.DeepEqual(got, api) {
+		t.Errorf("manifest did not round trip: %v", got)
+	}
+
+	changed := map[string]string{
+		"go.testpkg.Testpkg.Point":      "struct{X int; y int}",
+		"go.testpkg.Testpkg.Point.getX": "int64",
+		"go.testpkg.Testpkg.Point.new":  "func()",
+	}
+	for n, sig := range api {
+		if _, ok := changed[n]; !ok {
+			changed[n] = sig
+		}
+	}
+	delete(changed, "go.testpkg.Testpkg.newPoint")
+	exp2 := []string{
+		"changed go.testpkg.Testpkg.Point.getX from int to int64",
+		"removed go.testpkg.Testpkg.newPoint (func() *Point)",
+	}
+	if got := breakingChanges(api, changed); !reflect.DeepEqual(got, exp2) {
+		t.Errorf("expected %v, got %v", exp2, got)
+	}
+}
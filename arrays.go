@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// arrayKind is a Go slice type bound to a Java primitive array.
+type arrayKind struct {
+	// elem is the Go element type and java the Java element type.
+	elem, java string
+	// region names the JNI functions for the array type, such as
+	// GetFloatArrayRegion.
+	region string
+}
+
+// arrayKinds maps the Go slice types bound to Java arrays by go.GoArrays.
+var arrayKinds = map[string]arrayKind{
+	"[]float32": {"float32", "float", "Float"},
+	"[]float64": {"float64", "double", "Double"},
+	"[]int64":   {"int64", "long", "Long"},
+}
+
+// jniTypes maps Java primitive types to their JNI types.
+var jniTypes = map[string]string{
+	"boolean": "jboolean",
+	"byte":    "jbyte",
+	"short":   "jshort",
+	"int":     "jint",
+	"long":    "jlong",
+	"float":   "jfloat",
+	"double":  "jdouble",
+}
+
+// arrayFunc is a package function taking or returning a slice in arrayKinds,
+// bound through a native method of go.GoArrays.
+type arrayFunc struct {
+	pkg *types.Package
+	fn  *types.Func
+	// err is set if the last result of fn is an error.
+	err bool
+	// critical is set by a //gojava:critical directive, to pass arrays to fn
+	// without copying them.
+	critical bool
+}
+
+// arrayParamType returns the Java type of a parameter or result of type t of
+// an arrayFunc, and whether t is a slice in arrayKinds.
+func arrayParamType(t types.Type) (string, bool, bool) {
+	if k, ok := arrayKinds[t.String()]; ok {
+		return k.java + "[]", true, true
+	}
+	if _, ok := t.Underlying().(*types.Basic); !ok {
+		return "", false, false
+	}
+	jt, err := javaType(t)
+	if _, ok := jniTypes[jt]; err != nil || !ok {
+		return "", false, false
+	}
+	return jt, false, true
+}
+
+// findArrayFuncs returns the exported functions of p with a parameter or
+// result of a slice type in arrayKinds, and otherwise only numeric and
+// boolean parameters and results and an error result. If exposed is not nil,
+// only the functions in it are returned.
+func findArrayFuncs(p *types.Package, exposed []string, docs *docFinder) []arrayFunc {
+	var funcs []arrayFunc
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() || (exposed != nil && !containsString(exposed, name)) {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		res := sig.Results()
+		f := arrayFunc{pkg: p, fn: fn, err: res.Len() > 0 && isError(res.At(res.Len()-1).Type())}
+		n := res.Len()
+		if f.err {
+			n--
+		}
+		if n > 1 || sig.Variadic() {
+			continue
+		}
+		vars := tupleVars(sig.Params())
+		if n == 1 {
+			vars = append(vars, res.At(0))
+		}
+		supported, arrays := true, false
+		for _, v := range vars {
+			_, array, ok := arrayParamType(v.Type())
+			supported = supported && ok
+			arrays = arrays || array
+		}
+		if !supported || !arrays {
+			continue
+		}
+		for _, dir := range docs.directives(fn) {
+			f.critical = f.critical || dir == "critical"
+		}
+		funcs = append(funcs, f)
+	}
+	return funcs
+}
+
+// genArrays adds static methods to the package classes of pkgs in javaDir for
+// the functions taking or returning float32, float64 and int64 slices, which
+// gomobile does not bind, and writes the Go and C code copying Java arrays to
+// and from them to bindDir. It returns the path of go.GoArrays, or nil if
+// there are no such functions.
+func genArrays(bindDir, javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, docs *docFinder) ([]string, error) {
+	var funcs []arrayFunc
+	for _, p := range pkgs {
+		var e []string
+		if exposed != nil {
+			e = exposed[p]
+			if e == nil {
+				e = []string{}
+			}
+		}
+		pfuncs := findArrayFuncs(p, e, docs)
+		if len(pfuncs) == 0 {
+			continue
+		}
+		path := filepath.Join(javaDir, javaClassName(p)+".java")
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var members bytes.Buffer
+		for i, f := range pfuncs {
+			verbosef("Binding the arrays of %s.%s\n", p.Path(), f.fn.Name())
+			members.WriteString(arrayJavaMethod(f, len(funcs)+i))
+		}
+		if src, err = insertIntoClass(src, javaClassName(p), members.String()); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, src, 0600); err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, pfuncs...)
+	}
+	if len(funcs) == 0 {
+		return nil, nil
+	}
+	goSrc, cSrc, javaSrc := arraySources(funcs)
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_arrays.go"), goSrc, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_arrays.c"), cSrc, 0600); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(javaDir, "GoArrays.java")
+	if err := writeJavaFile(path, javaSrc); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+// arrayParams returns the Java parameter declarations of f and their names.
+func arrayParams(f arrayFunc) ([]string, []string) {
+	var decls, names []string
+	for i, v := range tupleVars(f.fn.Type().(*types.Signature).Params()) {
+		jt, _, _ := arrayParamType(v.Type())
+		name := javaIdent(v.Name())
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		decls = append(decls, jt+" "+name)
+		names = append(names, name)
+	}
+	return decls, names
+}
+
+// arrayResult returns the Java result type of f.
+func arrayResult(f arrayFunc) string {
+	res := f.fn.Type().(*types.Signature).Results()
+	if res.Len() == 0 || (f.err && res.Len() == 1) {
+		return "void"
+	}
+	jt, _, _ := arrayParamType(res.At(0).Type())
+	return jt
+}
+
+// arrayJavaMethod returns the static method calling the function f through
+// the index-th native method of go.GoArrays.
+func arrayJavaMethod(f arrayFunc, index int) string {
+	decls, names := arrayParams(f)
+	throws := ""
+	if f.err {
+		throws = " throws Exception"
+	}
+	ret := arrayResult(f)
+	call := fmt.Sprintf("go.GoArrays.call%d(%s);", index, strings.Join(names, ", "))
+	if ret != "void" {
+		call = "return " + call
+	}
+	return fmt.Sprintf("\n\tpublic static %s %s(%s)%s {\n\t\tgo.Go.load();\n\t\t%s\n\t}\n", ret, javaMethodName(f.fn.Name()), strings.Join(decls, ", "), throws, call)
+}
+
+// arraySources returns the Go glue calling funcs, the C code of the native
+// methods of go.GoArrays calling the glue, and go.GoArrays.
+func arraySources(funcs []arrayFunc) ([]byte, []byte, []byte) {
+	imports := map[string]string{}
+	qualifier := func(p *types.Package) string {
+		if a, ok := imports[p.Path()]; ok {
+			return a
+		}
+		a := fmt.Sprintf("arr%d", len(imports))
+		imports[p.Path()] = a
+		return a
+	}
+	var goCalls, cCalls, natives bytes.Buffer
+	for i, f := range funcs {
+		sig := f.fn.Type().(*types.Signature)
+		decls, _ := arrayParams(f)
+		ret := arrayResult(f)
+		throws := ""
+		if f.err {
+			throws = " throws Exception"
+		}
+		fmt.Fprintf(&natives, "\n\tpublic static native %s call%d(%s)%s;\n", ret, i, strings.Join(decls, ", "), throws)
+
+		cRet := "void"
+		if ret != "void" {
+			cRet = jniType(ret)
+		}
+		var goParams, cParams, cArgs, args []string
+		var conv bytes.Buffer
+		for j, v := range tupleVars(sig.Params()) {
+			jt, array, _ := arrayParamType(v.Type())
+			goParams = append(goParams, fmt.Sprintf("a%d C.%s", j, jniType(jt)))
+			cParams = append(cParams, fmt.Sprintf("%s a%d", jniType(jt), j))
+			cArgs = append(cArgs, fmt.Sprintf("a%d", j))
+			switch {
+			case array && f.critical:
+				fmt.Fprintf(&conv, "\t\tv%d, release%d := gojavaCritical%s(env, a%d)\n\t\tdefer release%d()\n", j, j, arrayKinds[v.Type().String()].region, j, j)
+			case array:
+				fmt.Fprintf(&conv, "\t\tv%d := gojavaGet%s(env, a%d)\n", j, arrayKinds[v.Type().String()].region, j)
+			case jt == "boolean":
+				fmt.Fprintf(&conv, "\t\tv%d := %s(a%d != 0)\n", j, types.TypeString(v.Type(), qualifier), j)
+			default:
+				fmt.Fprintf(&conv, "\t\tv%d := %s(a%d)\n", j, types.TypeString(v.Type(), qualifier), j)
+			}
+			args = append(args, fmt.Sprintf("v%d", j))
+		}
+		// The arguments are converted and the function called in a closure,
+		// so critical arrays are released before any other JNI call.
+		var body bytes.Buffer
+		var results []string
+		if ret != "void" {
+			fmt.Fprintf(&body, "\tvar r %s\n", types.TypeString(sig.Results().At(0).Type(), qualifier))
+			results = append(results, "r")
+		}
+		if f.err {
+			body.WriteString("\tvar err error\n")
+			results = append(results, "err")
+		}
+		call := fmt.Sprintf("%s.%s(%s)", qualifier(f.pkg), f.fn.Name(), strings.Join(args, ", "))
+		if len(results) > 0 {
+			call = strings.Join(results, ", ") + " = " + call
+		}
+		fmt.Fprintf(&body, "\tfunc() {\n%s\t\t%s\n\t}()\n", conv.String(), call)
+		if f.err {
+			body.WriteString("\tif err != nil {\n\t\tgojavaThrow(env, \"java/lang/Exception\", err.Error())\n\t\treturn\n\t}\n")
+		}
+		goRet := ""
+		if ret != "void" {
+			goRet = fmt.Sprintf(" (res C.%s)", cRet)
+			if k, ok := arrayKinds[sig.Results().At(0).Type().String()]; ok {
+				fmt.Fprintf(&body, "\treturn gojavaNew%s(env, r)\n", k.region)
+			} else if ret == "boolean" {
+				body.WriteString("\tif r {\n\t\treturn 1\n\t}\n\treturn 0\n")
+			} else {
+				fmt.Fprintf(&body, "\treturn C.%s(r)\n", cRet)
+			}
+		}
+		name := fmt.Sprintf("gojava_arrays_call%d", i)
+		fmt.Fprintf(&goCalls, "\n//export %s\nfunc %s(%s)%s {\n", name, name, strings.Join(append([]string{"env *C.JNIEnv"}, goParams...), ", "), goRet)
+		goCalls.WriteString("\tdefer gojavaRecover(env)\n")
+		goCalls.Write(body.Bytes())
+		goCalls.WriteString("}\n")
+
+		fmt.Fprintf(&cCalls, "\nJNIEXPORT %s JNICALL Java_go_GoArrays_call%d(%s) {\n", cRet, i, strings.Join(append([]string{"JNIEnv *env", "jclass clazz"}, cParams...), ", "))
+		if cRet == "void" {
+			fmt.Fprintf(&cCalls, "\t%s(%s);\n}\n", name, strings.Join(append([]string{"env"}, cArgs...), ", "))
+		} else {
+			fmt.Fprintf(&cCalls, "\treturn %s(%s);\n}\n", name, strings.Join(append([]string{"env"}, cArgs...), ", "))
+		}
+	}
+
+	var paths []string
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var goSrc bytes.Buffer
+	goSrc.WriteString(arraysGoHeader)
+	for _, p := range paths {
+		fmt.Fprintf(&goSrc, "\t%s %q\n", imports[p], p)
+	}
+	goSrc.WriteString(")\n")
+	goSrc.WriteString(arraysGoHelpers)
+	var cSrc bytes.Buffer
+	cSrc.WriteString(arraysCHeader)
+	for _, name := range []string{"Float", "Double", "Long"} {
+		fmt.Fprintf(&goSrc, arraysGoKind, name, strings.ToLower(name), goElem(name))
+		fmt.Fprintf(&cSrc, arraysCKind, strings.ToLower(name), name)
+	}
+	goSrc.Write(goCalls.Bytes())
+	cSrc.Write(cCalls.Bytes())
+	var javaSrc bytes.Buffer
+	javaSrc.WriteString(goArraysJavaHeader)
+	javaSrc.Write(natives.Bytes())
+	javaSrc.WriteString("}\n")
+	return goSrc.Bytes(), cSrc.Bytes(), javaSrc.Bytes()
+}
+
+// jniType returns the JNI type of the Java primitive type or primitive array
+// type t.
+func jniType(t string) string {
+	if strings.HasSuffix(t, "[]") {
+		return jniTypes[strings.TrimSuffix(t, "[]")] + "Array"
+	}
+	return jniTypes[t]
+}
+
+// goElem returns the Go element type of the arrays named region by JNI.
+func goElem(region string) string {
+	for _, k := range arrayKinds {
+		if k.region == region {
+			return k.elem
+		}
+	}
+	return ""
+}
+
+const arraysGoHeader = `package gojava_bind
+
+// #include <jni.h>
+// #include <stdlib.h>
+//
+// jsize gojava_array_length(JNIEnv *env, jarray a);
+// void *gojava_array_critical(JNIEnv *env, jarray a);
+// void gojava_array_release(JNIEnv *env, jarray a, void *p);
+// void gojava_array_throw(JNIEnv *env, char *class, char *msg);
+// void gojava_get_floats(JNIEnv *env, jfloatArray a, jsize n, void *buf);
+// jfloatArray gojava_new_floats(JNIEnv *env, jsize n, void *buf);
+// void gojava_get_doubles(JNIEnv *env, jdoubleArray a, jsize n, void *buf);
+// jdoubleArray gojava_new_doubles(JNIEnv *env, jsize n, void *buf);
+// void gojava_get_longs(JNIEnv *env, jlongArray a, jsize n, void *buf);
+// jlongArray gojava_new_longs(JNIEnv *env, jsize n, void *buf);
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+`
+
+const arraysGoHelpers = `
+// gojavaThrow throws a new Java exception of class with the message msg.
+func gojavaThrow(env *C.JNIEnv, class, msg string) {
+	c, m := C.CString(class), C.CString(msg)
+	defer C.free(unsafe.Pointer(c))
+	defer C.free(unsafe.Pointer(m))
+	C.gojava_array_throw(env, c, m)
+}
+
+// gojavaRecover throws a RuntimeException for a panic of a bound function.
+func gojavaRecover(env *C.JNIEnv) {
+	if p := recover(); p != nil {
+		gojavaThrow(env, "java/lang/RuntimeException", fmt.Sprintf("panic: %v", p))
+	}
+}
+`
+
+// arraysGoKind is the Go code copying and sharing the Java arrays with the
+// JNI name %[1]s and Go element type %[3]s.
+const arraysGoKind = `
+// gojavaGet%[1]s returns a copy of the Java array a.
+func gojavaGet%[1]s(env *C.JNIEnv, a C.j%[2]sArray) []%[3]s {
+	if a == nil {
+		return nil
+	}
+	v := make([]%[3]s, int(C.gojava_array_length(env, C.jarray(a))))
+	if len(v) > 0 {
+		C.gojava_get_%[2]ss(env, a, C.jsize(len(v)), unsafe.Pointer(&v[0]))
+	}
+	return v
+}
+
+// gojavaNew%[1]s returns a new Java array with the elements of v.
+func gojavaNew%[1]s(env *C.JNIEnv, v []%[3]s) C.j%[2]sArray {
+	if v == nil {
+		return nil
+	}
+	var p unsafe.Pointer
+	if len(v) > 0 {
+		p = unsafe.Pointer(&v[0])
+	}
+	return C.gojava_new_%[2]ss(env, C.jsize(len(v)), p)
+}
+
+// gojavaCritical%[1]s returns the elements of the Java array a, which may not
+// be copied, and a function releasing them, writing back any changes.
+func gojavaCritical%[1]s(env *C.JNIEnv, a C.j%[2]sArray) ([]%[3]s, func()) {
+	if a == nil {
+		return nil, func() {}
+	}
+	n := int(C.gojava_array_length(env, C.jarray(a)))
+	p := C.gojava_array_critical(env, C.jarray(a))
+	if p == nil {
+		return nil, func() {}
+	}
+	return unsafe.Slice((*%[3]s)(p), n), func() { C.gojava_array_release(env, C.jarray(a), p) }
+}
+`
+
+const arraysCHeader = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+jsize gojava_array_length(JNIEnv *env, jarray a) {
+	return (*env)->GetArrayLength(env, a);
+}
+
+void *gojava_array_critical(JNIEnv *env, jarray a) {
+	return (*env)->GetPrimitiveArrayCritical(env, a, NULL);
+}
+
+void gojava_array_release(JNIEnv *env, jarray a, void *p) {
+	(*env)->ReleasePrimitiveArrayCritical(env, a, p, 0);
+}
+
+void gojava_array_throw(JNIEnv *env, char *class, char *msg) {
+	jclass c = (*env)->FindClass(env, class);
+	if (c != NULL) {
+		(*env)->ThrowNew(env, c, msg);
+	}
+}
+`
+
+// arraysCKind is the C code copying the Java arrays with the JNI name %[2]s.
+const arraysCKind = `
+void gojava_get_%[1]ss(JNIEnv *env, j%[1]sArray a, jsize n, void *buf) {
+	(*env)->Get%[2]sArrayRegion(env, a, 0, n, buf);
+}
+
+j%[1]sArray gojava_new_%[1]ss(JNIEnv *env, jsize n, void *buf) {
+	j%[1]sArray a = (*env)->New%[2]sArray(env, n);
+	if (a != NULL && n > 0) {
+		(*env)->Set%[2]sArrayRegion(env, a, 0, n, buf);
+	}
+	return a;
+}
+`
+
+const goArraysJavaHeader = `package go;
+
+// GoArrays holds the native methods of the bound Go functions taking or
+// returning float32, float64 and int64 slices. Arrays are copied once to and
+// from Go, or shared with functions marked //gojava:critical.
+public final class GoArrays {
+	private GoArrays() {}
+`
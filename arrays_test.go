@@ -0,0 +1,100 @@
+package main
+
+import (
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const arraysSrc = `package testpkg
+
+type Scale float64
+
+func Dot(a, b []float64) float64 { return 0 }
+
+// Fill sets all elements of v to x.
+//
+//gojava:critical
+func Fill(v []float32, x float32) {}
+
+func Range(n int64, strict bool) ([]int64, error) { return nil, nil }
+
+func Scaled(v []float64, s Scale) []float64 { return v }
+
+func Names(v []float64) []string { return nil }
+
+func Ints(v []int) {}
+`
+
+func TestGenArrays(t *testing.T) {
+	p, docs := typeCheckFile(t, arraysSrc)
+	var names []string
+	for _, f := range findArrayFuncs(p, nil, docs) {
+		names = append(names, f.fn.Name())
+		if f.critical != (f.fn.Name() == "Fill") {
+			t.Errorf("%s: critical is %v", f.fn.Name(), f.critical)
+		}
+	}
+	if got, want := strings.Join(names, ","), "Dot,Fill,Range,Scaled"; got != want {
+		t.Errorf("got array funcs %s, want %s", got, want)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte("package go.testpkg;\n\npublic abstract class Testpkg {\n    public static void touch() {}\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := genArrays(tmpDir, tmpDir, []*types.Package{p}, map[*types.Package][]string{p: {"Dot", "Fill", "Range"}}, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "GoArrays.java" {
+		t.Fatalf("got files %v", files)
+	}
+	for _, c := range []struct {
+		file string
+		want []string
+	}{
+		{"Testpkg.java", []string{
+			"\tpublic static double dot(double[] a, double[] b) {\n\t\tgo.Go.load();\n\t\treturn go.GoArrays.call0(a, b);\n\t}\n",
+			"\tpublic static void fill(float[] v, float x) {\n\t\tgo.Go.load();\n\t\tgo.GoArrays.call1(v, x);\n\t}\n",
+			"\tpublic static long[] range(long n, boolean strict) throws Exception {",
+		}},
+		{"GoArrays.java", []string{
+			"\tpublic static native long[] call2(long n, boolean strict) throws Exception;\n",
+		}},
+		{"gojava_arrays.go", []string{
+			"func gojava_arrays_call0(env *C.JNIEnv, a0 C.jdoubleArray, a1 C.jdoubleArray) (res C.jdouble) {",
+			"\t\tv0, release0 := gojavaCriticalFloat(env, a0)\n\t\tdefer release0()\n\t\tv1 := float32(a1)\n\t\tarr0.Fill(v0, v1)\n",
+			"\t\tv1 := bool(a1 != 0)\n\t\tr, err = arr0.Range(v0, v1)\n",
+			"\treturn gojavaNewLong(env, r)\n",
+		}},
+		{"gojava_arrays.c", []string{
+			"JNIEXPORT jdouble JNICALL Java_go_GoArrays_call0(JNIEnv *env, jclass clazz, jdoubleArray a0, jdoubleArray a1) {\n\treturn gojava_arrays_call0(env, a0, a1);\n}\n",
+			"(*env)->GetLongArrayRegion(env, a, 0, n, buf);",
+		}},
+	} {
+		d, err := ioutil.ReadFile(filepath.Join(tmpDir, c.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range c.want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", c.file, s, d)
+			}
+		}
+		if c.file == "gojava_arrays.go" {
+			if _, err := format.Source(d); err != nil {
+				t.Errorf("%s: %v", c.file, err)
+			}
+		}
+	}
+}
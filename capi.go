@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cAPIHeader is the name of the C header written by writeCAPI.
+const cAPIHeader = "gojava.h"
+
+// sharedLibExt returns the file extension of shared libraries on goos.
+func sharedLibExt(goos string) string {
+	switch goos {
+	case "darwin", "ios":
+		return ".dylib"
+	case "windows":
+		return ".dll"
+	}
+	return ".so"
+}
+
+// writeCAPI writes the native library built in classDir to dir, along with
+// the C header cAPIHeader declaring the functions exported by the Go code in
+// bindDir, so C and other non-JVM code can link the same library.
+func writeCAPI(dir, classDir, bindDir string) error {
+	goos, err := goTargetOS()
+	if err != nil {
+		return err
+	}
+	header, err := exportHeader(bindDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	verbosef("Writing the C API to %s\n", dir)
+	if err := copyFiles([]filePair{
+		{filepath.Join(dir, "libgojava"+sharedLibExt(goos)), filepath.Join(classDir, "libgojava")},
+		{filepath.Join(dir, "seq.h"), filepath.Join(bindDir, "seq.h")},
+	}); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, cAPIHeader), header, 0600)
+}
+
+// cgoTypes maps the cgo names of C types to their C spelling, for the names
+// that differ.
+var cgoTypes = map[string]string{
+	"schar":     "signed char",
+	"uchar":     "unsigned char",
+	"ushort":    "unsigned short",
+	"uint":      "unsigned int",
+	"ulong":     "unsigned long",
+	"longlong":  "long long",
+	"ulonglong": "unsigned long long",
+}
+
+// cType returns the C spelling of the cgo type expression e, or false if it
+// is not a C type.
+func cType(e ast.Expr) (string, bool) {
+	switch e := e.(type) {
+	case *ast.StarExpr:
+		t, ok := cType(e.X)
+		return t + "*", ok
+	case *ast.SelectorExpr:
+		pkg, ok := e.X.(*ast.Ident)
+		switch {
+		case !ok:
+		case pkg.Name == "unsafe" && e.Sel.Name == "Pointer":
+			return "void*", true
+		case pkg.Name == "C" && strings.HasPrefix(e.Sel.Name, "struct_"):
+			return "struct " + e.Sel.Name[len("struct_"):], true
+		case pkg.Name == "C":
+			if t, ok := cgoTypes[e.Sel.Name]; ok {
+				return t, true
+			}
+			return e.Sel.Name, true
+		}
+	}
+	return "", false
+}
+
+// exportedFunc returns the C declaration of the Go function fn exported as
+// name, declaring the struct returned by functions with several results as
+// cgo does.
+func exportedFunc(name string, fn *ast.FuncDecl) (string, error) {
+	var b bytes.Buffer
+	var params []string
+	if fn.Type.Params != nil {
+		for _, f := range fn.Type.Params.List {
+			t, ok := cType(f.Type)
+			if !ok {
+				return "", fmt.Errorf("%s: parameter type is not a C type", name)
+			}
+			if len(f.Names) == 0 {
+				params = append(params, fmt.Sprintf("%s p%d", t, len(params)))
+			}
+			for _, n := range f.Names {
+				params = append(params, t+" "+n.Name)
+			}
+		}
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	var results []string
+	if fn.Type.Results != nil {
+		for _, f := range fn.Type.Results.List {
+			t, ok := cType(f.Type)
+			if !ok {
+				return "", fmt.Errorf("%s: result type is not a C type", name)
+			}
+			for i := 0; i < len(f.Names) || i == 0 && len(f.Names) == 0; i++ {
+				results = append(results, t)
+			}
+		}
+	}
+	ret := "void"
+	switch len(results) {
+	case 0:
+	case 1:
+		ret = results[0]
+	default:
+		ret = "struct " + name + "_return"
+		fmt.Fprintf(&b, "%s {\n", ret)
+		for i, r := range results {
+			fmt.Fprintf(&b, "\t%s r%d;\n", r, i)
+		}
+		b.WriteString("};\n")
+	}
+	fmt.Fprintf(&b, "extern %s %s(%s);\n", ret, name, strings.Join(params, ", "))
+	return b.String(), nil
+}
+
+// forEachExport calls f with the name and declaration of each function
+// exported with an //export comment by the Go files in bindDir.
+func forEachExport(bindDir string, f func(name string, fn *ast.FuncDecl)) error {
+	files, err := filepath.Glob(filepath.Join(bindDir, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, d := range file.Decls {
+			fn, ok := d.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Doc == nil {
+				continue
+			}
+			for _, c := range fn.Doc.List {
+				if strings.HasPrefix(c.Text, "//export ") {
+					f(strings.TrimSpace(c.Text[len("//export "):]), fn)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// exportHeader returns a C header declaring the functions exported by the Go
+// files in bindDir. The go command only writes a header for the exports of
+// the main package, which the bind package is not.
+func exportHeader(bindDir string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(cAPIHeaderStart)
+	err := forEachExport(bindDir, func(name string, fn *ast.FuncDecl) {
+		decl, err := exportedFunc(name, fn)
+		if err != nil {
+			verbosef("Leaving %s out of %s: %v\n", name, cAPIHeader, err)
+			return
+		}
+		b.WriteString(decl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString(cAPIHeaderEnd)
+	return b.Bytes(), nil
+}
+
+const cAPIHeaderStart = `/* Code generated by gojava. DO NOT EDIT. */
+
+#ifndef GOJAVA_H
+#define GOJAVA_H
+
+#include <stdint.h>
+#include "seq.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+`
+
+const cAPIHeaderEnd = `
+#ifdef __cplusplus
+}
+#endif
+
+#endif
+`
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const exportsGo = `package gojava_bind
+
+import "C"
+
+import "unsafe"
+
+//export proxytestpkg__Add
+func proxytestpkg__Add(param_a C.int64_t, param_b C.int64_t) C.int64_t { return 0 }
+
+//export proxytestpkg__Read
+func proxytestpkg__Read(param_p C.nbyteslice) (C.nstring, C.int32_t) { return }
+
+//export gojava_init
+func gojava_init() *C.char { return nil }
+
+//export gojava_ptr
+func gojava_ptr(p unsafe.Pointer, n C.ulonglong) {}
+
+//export gojava_go
+func gojava_go(n int) {}
+
+func notExported(a C.int) {}
+`
+
+func TestExportHeader(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "go_testpkgmain.go"), []byte(exportsGo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := exportHeader(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"#include \"seq.h\"\n",
+		"extern int64_t proxytestpkg__Add(int64_t param_a, int64_t param_b);\n",
+		"struct proxytestpkg__Read_return {\n\tnstring r0;\n\tint32_t r1;\n};\nextern struct proxytestpkg__Read_return proxytestpkg__Read(nbyteslice param_p);\n",
+		"extern char* gojava_init(void);\n",
+		"extern void gojava_ptr(void* p, unsigned long long n);\n",
+	} {
+		if !strings.Contains(string(h), s) {
+			t.Errorf("header missing %q:\n%s", s, h)
+		}
+	}
+	for _, s := range []string{"gojava_go", "notExported"} {
+		if strings.Contains(string(h), s) {
+			t.Errorf("header should not declare %s:\n%s", s, h)
+		}
+	}
+}
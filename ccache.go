@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// compilerCaches are the compiler caches found by -ccache auto, in order of
+// preference.
+var compilerCaches = []string{"ccache", "sccache"}
+
+// findCompilerCache returns the path of the compiler cache selected by mode:
+// the first of compilerCaches in $PATH for auto, none for off, or the named
+// or given program.
+func findCompilerCache(mode string) (string, error) {
+	switch mode {
+	case "off", "":
+		return "", nil
+	case "auto":
+		for _, c := range compilerCaches {
+			if p, err := exec.LookPath(c); err == nil {
+				return p, nil
+			}
+		}
+		return "", nil
+	}
+	p, err := exec.LookPath(mode)
+	if err != nil {
+		return "", fmt.Errorf("-ccache: %v", err)
+	}
+	return p, nil
+}
+
+// isCompilerCache reports whether the program at path is one of
+// compilerCaches.
+func isCompilerCache(path string) bool {
+	name := strings.TrimSuffix(filepath.Base(path), ".exe")
+	for _, c := range compilerCaches {
+		if name == c {
+			return true
+		}
+	}
+	return false
+}
+
+// nativeBuildEnv returns the environment of the go command building the
+// native library for goos and goarch. With a compiler cache, cgo runs the C
+// compiler through it, unless $CC already does. The paths of the temporary
+// directories gojava and the go command build in are left out of the ccache
+// keys, so the generated C files hit the cache across builds. With
+// splitGoCache, each target has its own Go build cache in the default one,
+// so CI can save and restore the cache of each platform separately.
+func nativeBuildEnv(ccache string, splitGoCache bool, goos, goarch string) ([]string, error) {
+	cache, err := findCompilerCache(ccache)
+	if err != nil {
+		return nil, err
+	}
+	env := commandEnv()
+	if cache == "" && !splitGoCache {
+		return env, nil
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	out, err := commandOutput("go", "env", "CC", "GOCACHE")
+	if err != nil {
+		return nil, err
+	}
+	vars := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(vars) != 2 {
+		return nil, fmt.Errorf("go env CC GOCACHE: unexpected output %q", out)
+	}
+	cc, goCache := vars[0], vars[1]
+	if f := strings.Fields(cc); cache != "" && len(f) > 0 && !isCompilerCache(f[0]) {
+		verbosef("Compiling the C code with %s\n", cache)
+		env = append(env, "CC="+cache+" "+cc, "CCACHE_BASEDIR="+os.TempDir(), "CCACHE_NOHASHDIR=1")
+	}
+	if splitGoCache && goCache != "" && goCache != "off" {
+		env = append(env, "GOCACHE="+filepath.Join(goCache, "gojava", goos+"_"+goarch))
+	}
+	return env, nil
+}
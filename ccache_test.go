@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNativeBuildEnv(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	sccache := filepath.Join(tmpDir, "sccache")
+	if err := ioutil.WriteFile(sccache, []byte("#!/bin/sh\nexec \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"PATH", "CC"} {
+		defer os.Setenv(k, os.Getenv(k))
+	}
+	os.Setenv("PATH", tmpDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	os.Setenv("CC", "gcc")
+
+	if p, err := findCompilerCache("auto"); err != nil || p != sccache {
+		t.Errorf("auto: got %q, %v, want %s", p, err, sccache)
+	}
+	if p, err := findCompilerCache("off"); err != nil || p != "" {
+		t.Errorf("off: got %q, %v", p, err)
+	}
+	if _, err := findCompilerCache("gojava-no-such-cache"); err == nil {
+		t.Error("expected error for missing compiler cache")
+	}
+
+	env, err := nativeBuildEnv("auto", true, "linux", "arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := make(map[string]string)
+	for _, v := range env {
+		kv := strings.SplitN(v, "=", 2)
+		vars[kv[0]] = kv[1]
+	}
+	if want := sccache + " gcc"; vars["CC"] != want {
+		t.Errorf("got CC=%s, want %s", vars["CC"], want)
+	}
+	if !strings.HasSuffix(vars["GOCACHE"], filepath.Join("gojava", "linux_arm64")) {
+		t.Errorf("got GOCACHE=%s, want a linux_arm64 cache", vars["GOCACHE"])
+	}
+
+	os.Setenv("CC", "ccache clang")
+	env, err = nativeBuildEnv("auto", false, "linux", "arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range env {
+		if strings.HasPrefix(v, "CC=") && v != "CC=ccache clang" {
+			t.Errorf("CC already using a compiler cache was changed to %s", v)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cdiProducers is the name of the class holding the producer methods written
+// by writeCDI.
+const cdiProducers = "GoProducers"
+
+// writeCDI writes the sources of a Jakarta CDI module for the jar cfg.target
+// to dir: an @ApplicationScoped wrapper for each service of pkgs, starting it
+// with the application and closing it in @PreDestroy, and producer methods
+// for the services and for the config structs of pkgs, set from MicroProfile
+// Config.
+func writeCDI(dir string, cfg *config, pkgs []*types.Package, services []service, docs *docFinder) error {
+	configs, err := findConfigStructs(pkgs, docs, cfg.split)
+	if err != nil {
+		return err
+	}
+	pkg := modulePackage("cdi", cfg.target)
+	javaDir := filepath.Join(append([]string{dir, "src", "main", "java"}, strings.Split(pkg, ".")...)...)
+	resDir := filepath.Join(dir, "src", "main", "resources", "META-INF")
+	if err := os.MkdirAll(resDir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(javaDir, 0755); err != nil {
+		return err
+	}
+	verbosef("Writing CDI module %s to %s\n", pkg, dir)
+
+	var beans []string
+	for _, s := range services {
+		class := javaClassName(s.pkg) + strings.Title(s.fn) + "ServiceBean"
+		beans = append(beans, class)
+		if err := ioutil.WriteFile(filepath.Join(javaDir, class+".java"), cdiServiceBean(pkg, class, s), 0644); err != nil {
+			return err
+		}
+	}
+	src := cdiProducerMethods(pkg, beans, configs, services)
+	if err := ioutil.WriteFile(filepath.Join(javaDir, cdiProducers+".java"), src, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(resDir, "beans.xml"), []byte(cdiBeansXML), 0644)
+}
+
+// cdiServiceBean returns the @ApplicationScoped wrapper named class for the
+// service s.
+func cdiServiceBean(pkg, class string, s service) []byte {
+	service := javaPkgName(s.pkg) + "." + strings.Title(s.fn) + "Service"
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// CDI bean for %s.%s generated by gojava.\n", s.pkg.Path(), s.fn)
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+	b.WriteString("import jakarta.annotation.PostConstruct;\n")
+	b.WriteString("import jakarta.annotation.PreDestroy;\n")
+	b.WriteString("import jakarta.enterprise.context.ApplicationScoped;\n")
+	b.WriteString("import jakarta.enterprise.context.Initialized;\n")
+	b.WriteString("import jakarta.enterprise.event.Observes;\n\n")
+	fmt.Fprintf(&b, "// %s runs %s for the lifetime of the\n// application.\n", class, service)
+	fmt.Fprintf(&b, "@ApplicationScoped\npublic class %s {\n", class)
+	fmt.Fprintf(&b, cdiServiceBeanBody, service, service)
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+const cdiServiceBeanBody = `	private %s service;
+
+	@PostConstruct
+	void start() {
+		service = new %s();
+		service.start();
+	}
+
+	// init creates the bean when the application starts, starting the service.
+	void init(@Observes @Initialized(ApplicationScoped.class) Object event) {
+	}
+
+	// stop stops the service and waits for the function to return.
+	@PreDestroy
+	void stop() {
+		try {
+			service.close();
+		} catch (RuntimeException ex) {
+			throw ex;
+		} catch (Exception ex) {
+			throw new IllegalStateException(ex);
+		}
+	}
+
+	public go.GoService service() {
+		return service;
+	}
+
+	public go.GoService.State state() {
+		return service.state();
+	}
+
+	public boolean isRunning() {
+		return service.isRunning();
+	}
+`
+
+// cdiProducerMethods returns the class with the producer methods for the
+// services, from their wrappers beans, and for configs.
+func cdiProducerMethods(pkg string, beans []string, configs []configStruct, services []service) []byte {
+	var b bytes.Buffer
+	b.WriteString("// CDI producers generated by gojava.\n")
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+	b.WriteString("import jakarta.enterprise.context.ApplicationScoped;\n")
+	b.WriteString("import jakarta.enterprise.context.Dependent;\n")
+	b.WriteString("import jakarta.enterprise.inject.Produces;\n\n")
+	fmt.Fprintf(&b, "// %s produces the Go config structs and services of the jar.\n", cdiProducers)
+	fmt.Fprintf(&b, "@ApplicationScoped\npublic class %s {\n", cdiProducers)
+	first := true
+	for _, c := range configs {
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+		fmt.Fprintf(&b, "\t// %s returns the %s with the %s.* properties.\n", javaIdent(c.pkg.Name()+c.name), c.class, c.prefix)
+		fmt.Fprintf(&b, "\t@Produces\n\t@Dependent\n\tpublic %s %s(org.eclipse.microprofile.config.Config config) {\n", c.class, javaIdent(c.pkg.Name()+c.name))
+		fmt.Fprintf(&b, "\t\t%s c = new %s();\n", c.class, c.class)
+		for _, f := range c.fields {
+			v := javaIdent(lowerCamel(f.name))
+			fmt.Fprintf(&b, "\t\tjava.util.Optional<%s> %s = config.getOptionalValue(%q, %s.class);\n", boxedJavaType(f.javaType), v, c.prefix+"."+f.property, boxedJavaType(f.javaType))
+			fmt.Fprintf(&b, "\t\tif (%s.isPresent()) {\n\t\t\tc.set%s(%s.get());\n\t\t}\n", v, f.name, v)
+		}
+		b.WriteString("\t\treturn c;\n\t}\n")
+	}
+	for i, s := range services {
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+		class := javaPkgName(s.pkg) + "." + strings.Title(s.fn) + "Service"
+		fmt.Fprintf(&b, "\t@Produces\n\t@Dependent\n\tpublic %s %s(%s bean) {\n", class, javaIdent(s.pkg.Name()+strings.Title(s.fn)+"Service"), beans[i])
+		fmt.Fprintf(&b, "\t\treturn (%s) bean.service();\n\t}\n", class)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+const cdiBeansXML = `<?xml version="1.0" encoding="UTF-8"?>
+<beans xmlns="https://jakarta.ee/xml/ns/jakartaee"
+       xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+       xsi:schemaLocation="https://jakarta.ee/xml/ns/jakartaee https://jakarta.ee/xml/ns/jakartaee/beans_3_0.xsd"
+       version="3.0" bean-discovery-mode="annotated">
+</beans>
+`
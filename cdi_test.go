@@ -0,0 +1,51 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCDI(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p, docs := typeCheckFile(t, springSrc)
+	pkgs := []*types.Package{p}
+	cfg := &config{target: "/out/server.jar"}
+	if err := writeCDI(tmpDir, cfg, pkgs, findServices(pkgs, nil, docs), docs); err != nil {
+		t.Fatal(err)
+	}
+	javaDir := filepath.Join(tmpDir, "src", "main", "java", "go", "cdi", "server")
+	for path, exp := range map[string][]string{
+		filepath.Join(javaDir, "TestpkgRunServiceBean.java"): {
+			"package go.cdi.server;",
+			"@ApplicationScoped\npublic class TestpkgRunServiceBean {\n\tprivate go.testpkg.RunService service;",
+			"\t@PreDestroy\n\tvoid stop() {\n\t\ttry {\n\t\t\tservice.close();",
+			"\tvoid init(@Observes @Initialized(ApplicationScoped.class) Object event) {",
+		},
+		filepath.Join(javaDir, "GoProducers.java"): {
+			"\tpublic go.testpkg.Testpkg.Config testpkgConfig(org.eclipse.microprofile.config.Config config) {",
+			"\t\tjava.util.Optional<Long> port = config.getOptionalValue(\"myapp.server.port\", Long.class);\n\t\tif (port.isPresent()) {\n\t\t\tc.setPort(port.get());",
+			"\tpublic go.testpkg.RunService testpkgRunService(TestpkgRunServiceBean bean) {",
+		},
+		filepath.Join(tmpDir, "src", "main", "resources", "META-INF", "beans.xml"): {
+			"bean-discovery-mode=\"annotated\"",
+		},
+	} {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range exp {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+}
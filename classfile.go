@@ -0,0 +1,951 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/sridharv/gomobile-java/bind"
+)
+
+// The access flags of the class files written by -backend classfile, along
+// with accStatic and accNative.
+const (
+	accPublic   = 0x0001
+	accPrivate  = 0x0002
+	accFinal    = 0x0010
+	accSuper    = 0x0020
+	accAbstract = 0x0400
+)
+
+// classfileDescriptors maps the Java types -backend classfile binds to their
+// descriptors.
+var classfileDescriptors = map[string]string{
+	"boolean": "Z",
+	"byte":    "B",
+	"short":   "S",
+	"int":     "I",
+	"long":    "J",
+	"float":   "F",
+	"double":  "D",
+	"String":  "Ljava/lang/String;",
+	"byte[]":  "[B",
+	"void":    "V",
+}
+
+// classfilePackage is a package bound by -backend classfile, which writes
+// its class file directly instead of compiling the Java source gomobile
+// generates for it.
+type classfilePackage struct {
+	pkg *types.Package
+	// class is the binary name of the package class in internal form, e.g.
+	// go/store/Store.
+	class  string
+	funcs  []classfileFunc
+	consts []classfileConst
+}
+
+// classfileFunc is a package function bound as a static native method.
+type classfileFunc struct {
+	fn *types.Func
+	// name is the name of the Java method and desc its descriptor.
+	name, desc string
+	// err is set if the last result of fn is an error, thrown as an
+	// Exception.
+	err bool
+}
+
+// classfileConst is a package constant bound as a static final field.
+type classfileConst struct {
+	name, desc string
+	value      constant.Value
+}
+
+// classfileFlags returns the flags set in cfg that -backend classfile does
+// not support, as they add Java sources to the jar or configure javac.
+func classfileFlags(cfg *config) []string {
+	var set []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"-s", cfg.sourceDir != ""},
+		{"-overload", len(cfg.overloadSuffixes) > 0},
+		{"-lazy", cfg.lazy},
+		{"-out-of-process", cfg.outOfProcess},
+		{"-javac-impl", cfg.javacImpl != "" && cfg.javacImpl != "javac"},
+		{"-javac", cfg.javac != ""},
+		{"-javac-opts", cfg.javacOpts != ""},
+		{"-javac-flags", cfg.javacFlags != ""},
+		{"-strict-java", cfg.strictJava},
+		{"-processorpath", cfg.processorPath != ""},
+		{"-shared-runtime", cfg.sharedRuntime != ""},
+		{"-intercept", cfg.intercept},
+		{"-metrics", cfg.metrics != ""},
+		{"-memory-limits", cfg.memoryLimits},
+		{"-jfr", cfg.jfr},
+		{"-record", cfg.record},
+		{"-tensors", cfg.tensors},
+		{"-registry", cfg.registry},
+		{"-scripting", cfg.scripting},
+		{"-c-api", cfg.cAPI != ""},
+		{"-cli", len(cfg.cli) > 0},
+		{"-android-lifecycle", cfg.androidLifecycle != ""},
+		{"-cdi", cfg.cdi != ""},
+		{"-spring-boot", cfg.springBoot != ""},
+		{"-examples", cfg.examples},
+		{"-example-tests", cfg.exampleTests != ""},
+		{"-platform-jars", cfg.platformJars},
+		{"-sbom", cfg.sbom},
+		{"-digests", cfg.digests},
+	} {
+		if f.set {
+			set = append(set, f.name)
+		}
+	}
+	return set
+}
+
+// findClassfilePackages returns the packages class files are written for by
+// -backend classfile, with their classes in the Java packages under root, or
+// in moved if moved by -javapkg-for. If exposed is not nil, only the
+// functions and constants in it are bound. It returns an error if pkgs
+// export anything but constants and functions of basic types and []byte,
+// which need the Java sources gomobile generates.
+func findClassfilePackages(pkgs []*types.Package, exposed map[*types.Package][]string, root string, moved map[string]string) ([]classfilePackage, error) {
+	var cps []classfilePackage
+	for _, p := range pkgs {
+		var e []string
+		if exposed != nil {
+			if e = exposed[p]; e == nil {
+				e = []string{}
+			}
+		}
+		cp := classfilePackage{pkg: p, class: strings.Replace(packageJavaPkg(root, moved, p), ".", "/", -1) + "/" + javaClassName(p)}
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if !obj.Exported() || (e != nil && !containsString(e, name)) {
+				continue
+			}
+			var what string
+			switch obj := obj.(type) {
+			case *types.Func:
+				f, ok := classfileFuncOf(p, obj)
+				if !ok {
+					what = "function using types other than basic types and []byte"
+					break
+				}
+				cp.funcs = append(cp.funcs, f)
+			case *types.Const:
+				if c, ok := classfileConstOf(obj); ok {
+					cp.consts = append(cp.consts, c)
+				} else {
+					verbosef("skipping %s.%s: no Java constant for %s\n", p.Path(), name, obj.Val())
+				}
+			case *types.Var:
+				what = "variable"
+			case *types.TypeName:
+				switch obj.Type().Underlying().(type) {
+				case *types.Struct, *types.Interface:
+					what = "type"
+				}
+			}
+			if what != "" {
+				return nil, fmt.Errorf("-backend classfile only binds package functions and constants of basic types and []byte, but %s.%s is a %s, which needs the Java sources gomobile generates", p.Path(), name, what)
+			}
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
+
+// classfileFuncOf returns fn bound as a method of the class of p, if it only
+// uses basic types and []byte and returns at most one value and an error.
+func classfileFuncOf(p *types.Package, fn *types.Func) (classfileFunc, bool) {
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 || sig.Variadic() {
+		return classfileFunc{}, false
+	}
+	params, _, ret, throws, err := javaSignature(fn)
+	if err != nil {
+		return classfileFunc{}, false
+	}
+	desc := "("
+	for _, param := range params {
+		d, ok := classfileDescriptors[param[:strings.LastIndexByte(param, ' ')]]
+		if !ok {
+			return classfileFunc{}, false
+		}
+		desc += d
+	}
+	d, ok := classfileDescriptors[ret]
+	if !ok {
+		return classfileFunc{}, false
+	}
+	return classfileFunc{fn: fn, name: finalMethodName(p, fn.Name()), desc: desc + ")" + d, err: throws != ""}, true
+}
+
+// classfileConstOf returns c bound as a static final field, if its value has
+// a Java constant of its type.
+func classfileConstOf(c *types.Const) (classfileConst, bool) {
+	jt, err := javaType(types.Default(c.Type()))
+	desc, ok := classfileDescriptors[jt]
+	if err != nil || !ok || desc == "[B" {
+		return classfileConst{}, false
+	}
+	v := c.Val()
+	switch desc {
+	case "J":
+		// Untyped constants may not fit a long.
+		if _, exact := constant.Int64Val(constant.ToInt(v)); !exact {
+			return classfileConst{}, false
+		}
+	case "Ljava/lang/String;":
+		if len(modifiedUTF8(constant.StringVal(v))) > math.MaxUint16 {
+			return classfileConst{}, false
+		}
+	}
+	return classfileConst{name: c.Name(), desc: desc, value: v}, true
+}
+
+// classWriter writes a class file. The constant pool is built as the members
+// are added, and written ahead of them by bytes.
+type classWriter struct {
+	pool    bytes.Buffer
+	entries map[string]uint16
+	// count is the index of the next constant.
+	count int
+	err   error
+}
+
+func newClassWriter() *classWriter {
+	return &classWriter{entries: make(map[string]uint16), count: 1}
+}
+
+// u2 returns the big endian encoding of vs.
+func u2(vs ...uint16) []byte {
+	b := make([]byte, 2*len(vs))
+	for i, v := range vs {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+// constant returns the index of the constant pool entry with tag and info,
+// adding it if needed. Longs and doubles take two entries.
+func (w *classWriter) constant(tag byte, info []byte) uint16 {
+	key := string(tag) + string(info)
+	if i, ok := w.entries[key]; ok {
+		return i
+	}
+	if w.count > math.MaxUint16-2 {
+		w.err = errors.New("too many constants for a class file")
+		return 0
+	}
+	i := uint16(w.count)
+	w.entries[key] = i
+	w.pool.WriteByte(tag)
+	w.pool.Write(info)
+	w.count++
+	if tag == 5 || tag == 6 {
+		w.count++
+	}
+	return i
+}
+
+func (w *classWriter) utf8(s string) uint16 {
+	m := modifiedUTF8(s)
+	if len(m) > math.MaxUint16 {
+		w.err = fmt.Errorf("string too long for a class file: %.40q", s)
+		return 0
+	}
+	return w.constant(1, append(u2(uint16(len(m))), m...))
+}
+
+func (w *classWriter) class(name string) uint16 {
+	return w.constant(7, u2(w.utf8(name)))
+}
+
+// ref returns the index of the field or method reference with tag.
+func (w *classWriter) ref(tag byte, class, name, desc string) uint16 {
+	nameAndType := w.constant(12, u2(w.utf8(name), w.utf8(desc)))
+	return w.constant(tag, u2(w.class(class), nameAndType))
+}
+
+// value returns the index of the constant v of the field descriptor desc.
+func (w *classWriter) value(desc string, v constant.Value) uint16 {
+	var b [8]byte
+	switch desc {
+	case "Z":
+		if constant.BoolVal(v) {
+			b[3] = 1
+		}
+		return w.constant(3, b[:4])
+	case "B", "S", "I":
+		i, _ := constant.Int64Val(constant.ToInt(v))
+		switch desc {
+		case "B":
+			i = int64(int8(i))
+		case "S":
+			i = int64(int16(i))
+		}
+		binary.BigEndian.PutUint32(b[:], uint32(int32(i)))
+		return w.constant(3, b[:4])
+	case "J":
+		i, _ := constant.Int64Val(constant.ToInt(v))
+		binary.BigEndian.PutUint64(b[:], uint64(i))
+		return w.constant(5, b[:])
+	case "F":
+		f, _ := constant.Float32Val(constant.ToFloat(v))
+		binary.BigEndian.PutUint32(b[:], math.Float32bits(f))
+		return w.constant(4, b[:4])
+	case "D":
+		f, _ := constant.Float64Val(constant.ToFloat(v))
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+		return w.constant(6, b[:])
+	}
+	return w.constant(8, u2(w.utf8(constant.StringVal(v))))
+}
+
+// attr returns the attribute name with data.
+func (w *classWriter) attr(name string, data []byte) []byte {
+	b := append(u2(w.utf8(name)), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[2:], uint32(len(data)))
+	return append(b, data...)
+}
+
+// member returns the field or method name with the attributes attrs.
+func (w *classWriter) member(access uint16, name, desc string, attrs ...[]byte) []byte {
+	b := u2(access, w.utf8(name), w.utf8(desc), uint16(len(attrs)))
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+	return b
+}
+
+// code returns the Code attribute of c, for a method with maxLocals local
+// variables.
+func (w *classWriter) code(c *classCode, maxLocals int) []byte {
+	b := append(u2(uint16(c.maxStack), uint16(maxLocals)), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[4:], uint32(c.b.Len()))
+	b = append(b, c.b.Bytes()...)
+	// No exception handlers and no attributes. The code has no branches,
+	// so it needs no StackMapTable.
+	return w.attr("Code", append(b, 0, 0, 0, 0))
+}
+
+// bytes returns the class file of the class this, extending super, with
+// fields and methods.
+func (w *classWriter) bytes(access, this, super uint16, fields, methods [][]byte) ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	var b bytes.Buffer
+	// Java 8 class files.
+	b.Write([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 52})
+	b.Write(u2(uint16(w.count)))
+	b.Write(w.pool.Bytes())
+	b.Write(u2(access, this, super, 0))
+	for _, members := range [][][]byte{fields, methods} {
+		b.Write(u2(uint16(len(members))))
+		for _, m := range members {
+			b.Write(m)
+		}
+	}
+	b.Write(u2(0))
+	return b.Bytes(), nil
+}
+
+// modifiedUTF8 returns s in the modified UTF-8 of class files, which encodes
+// NUL in two bytes and the UTF-16 surrogates of supplementary characters
+// separately.
+func modifiedUTF8(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		switch {
+		case c != 0 && c < 0x80:
+			b = append(b, byte(c))
+		case c < 0x800:
+			b = append(b, byte(0xc0|c>>6), byte(0x80|c&0x3f))
+		default:
+			b = append(b, byte(0xe0|c>>12), byte(0x80|c>>6&0x3f), byte(0x80|c&0x3f))
+		}
+	}
+	return b
+}
+
+// classCode is the bytecode of a method, tracking the depth of its operand
+// stack.
+type classCode struct {
+	b               bytes.Buffer
+	stack, maxStack int
+}
+
+// The opcodes used by classCode.
+const (
+	opIconst0       = 0x03
+	opIconst1       = 0x04
+	opLdc           = 0x12
+	opLdcW          = 0x13
+	opAload0        = 0x2a
+	opAload1        = 0x2b
+	opAstore0       = 0x4b
+	opAstore1       = 0x4c
+	opAastore       = 0x53
+	opPop2          = 0x58
+	opDup           = 0x59
+	opReturn        = 0xb1
+	opGetstatic     = 0xb2
+	opInvokevirtual = 0xb6
+	opInvokespecial = 0xb7
+	opInvokestatic  = 0xb8
+	opAnewarray     = 0xbd
+	opCheckcast     = 0xc0
+)
+
+// op appends the instruction b, which changes the depth of the operand stack
+// by delta.
+func (c *classCode) op(delta int, b ...byte) {
+	c.b.Write(b)
+	if c.stack += delta; c.stack > c.maxStack {
+		c.maxStack = c.stack
+	}
+}
+
+// index appends the instruction op with the constant pool index i.
+func (c *classCode) index(delta int, op byte, i uint16) {
+	c.op(delta, append([]byte{op}, u2(i)...)...)
+}
+
+// ldc pushes the constant at index i, which must not be a long or double.
+func (c *classCode) ldc(i uint16) {
+	if i <= math.MaxUint8 {
+		c.op(1, opLdc, byte(i))
+	} else {
+		c.index(1, opLdcW, i)
+	}
+}
+
+// invoke calls the method of class, with op.
+func (c *classCode) invoke(w *classWriter, op byte, class, name, desc string) {
+	delta := 0
+	if op != opInvokestatic {
+		// The receiver.
+		delta--
+	}
+	params, ret := desc[1:strings.IndexByte(desc, ')')], desc[strings.IndexByte(desc, ')')+1:]
+	for params != "" {
+		n := strings.TrimLeft(params, "[")
+		if n[0] == 'L' {
+			n = n[strings.IndexByte(n, ';')+1:]
+		} else {
+			n = n[1:]
+		}
+		if params[0] == 'J' || params[0] == 'D' {
+			delta--
+		}
+		params, delta = n, delta-1
+	}
+	switch ret {
+	case "V":
+	case "J", "D":
+		delta += 2
+	default:
+		delta++
+	}
+	c.index(delta, op, w.ref(10, class, name, desc))
+}
+
+// javaInternalName returns the Java package or class name in the internal
+// form of class files.
+func javaInternalName(name string) string {
+	return strings.Replace(name, ".", "/", -1)
+}
+
+// classfileLoader returns the class file of LoadJNI in the Java package root
+// for -backend classfile. Its static initializer loads the native library,
+// the resource libgojava of root, from a temporary file, and the package
+// classes call its static touch method to run it.
+func classfileLoader(root string) ([]byte, error) {
+	w := newClassWriter()
+	name := javaInternalName(root) + "/LoadJNI"
+	this, super := w.class(name), w.class("java/lang/Object")
+	clinit := &classCode{}
+	// File file = File.createTempFile("gojava", "gojava");
+	clinit.ldc(w.constant(8, u2(w.utf8("gojava"))))
+	clinit.ldc(w.constant(8, u2(w.utf8("gojava"))))
+	clinit.invoke(w, opInvokestatic, "java/io/File", "createTempFile", "(Ljava/lang/String;Ljava/lang/String;)Ljava/io/File;")
+	clinit.op(-1, opAstore0)
+	// file.deleteOnExit();
+	clinit.op(1, opAload0)
+	clinit.invoke(w, opInvokevirtual, "java/io/File", "deleteOnExit", "()V")
+	// InputStream in = (InputStream) Objects.requireNonNull(
+	//         LoadJNI.class.getResourceAsStream("/<root>/libgojava"), "...");
+	clinit.ldc(this)
+	clinit.ldc(w.constant(8, u2(w.utf8("/"+javaInternalName(root)+"/libgojava"))))
+	clinit.invoke(w, opInvokevirtual, "java/lang/Class", "getResourceAsStream", "(Ljava/lang/String;)Ljava/io/InputStream;")
+	clinit.ldc(w.constant(8, u2(w.utf8("Go JNI library not found in classpath"))))
+	clinit.invoke(w, opInvokestatic, "java/util/Objects", "requireNonNull", "(Ljava/lang/Object;Ljava/lang/String;)Ljava/lang/Object;")
+	clinit.index(0, opCheckcast, w.class("java/io/InputStream"))
+	clinit.op(-1, opAstore1)
+	// Files.copy(in, file.toPath(), StandardCopyOption.REPLACE_EXISTING);
+	clinit.op(1, opAload1)
+	clinit.op(1, opAload0)
+	clinit.invoke(w, opInvokevirtual, "java/io/File", "toPath", "()Ljava/nio/file/Path;")
+	clinit.op(1, opIconst1)
+	clinit.index(0, opAnewarray, w.class("java/nio/file/CopyOption"))
+	clinit.op(1, opDup)
+	clinit.op(1, opIconst0)
+	clinit.index(1, opGetstatic, w.ref(9, "java/nio/file/StandardCopyOption", "REPLACE_EXISTING", "Ljava/nio/file/StandardCopyOption;"))
+	clinit.op(-3, opAastore)
+	clinit.invoke(w, opInvokestatic, "java/nio/file/Files", "copy", "(Ljava/io/InputStream;Ljava/nio/file/Path;[Ljava/nio/file/CopyOption;)J")
+	clinit.op(-2, opPop2)
+	// in.close();
+	clinit.op(1, opAload1)
+	clinit.invoke(w, opInvokevirtual, "java/io/InputStream", "close", "()V")
+	// System.load(file.getAbsolutePath());
+	clinit.op(1, opAload0)
+	clinit.invoke(w, opInvokevirtual, "java/io/File", "getAbsolutePath", "()Ljava/lang/String;")
+	clinit.invoke(w, opInvokestatic, "java/lang/System", "load", "(Ljava/lang/String;)V")
+	clinit.op(0, opReturn)
+
+	touch := &classCode{}
+	touch.op(0, opReturn)
+	methods := [][]byte{
+		privateConstructor(w),
+		w.member(accStatic, "<clinit>", "()V", w.code(clinit, 2)),
+		w.member(accPublic|accStatic, "touch", "()V", w.code(touch, 0)),
+	}
+	return w.bytes(accPublic|accFinal|accSuper, this, super, nil, methods)
+}
+
+// privateConstructor returns the private constructor of a class extending
+// java.lang.Object that is not instantiated.
+func privateConstructor(w *classWriter) []byte {
+	c := &classCode{}
+	c.op(1, opAload0)
+	c.invoke(w, opInvokespecial, "java/lang/Object", "<init>", "()V")
+	c.op(0, opReturn)
+	return w.member(accPrivate, "<init>", "()V", w.code(c, 1))
+}
+
+// packageClassfile returns the class file of the package class of cp, with
+// the constants of the package as static final fields and its functions as
+// static native methods. Its static initializer loads the native library
+// through the class loader, the binary name of LoadJNI in internal form.
+func packageClassfile(cp classfilePackage, loader string) ([]byte, error) {
+	w := newClassWriter()
+	this, super := w.class(cp.class), w.class("java/lang/Object")
+	var fields, methods [][]byte
+	for _, c := range cp.consts {
+		value := w.attr("ConstantValue", u2(w.value(c.desc, c.value)))
+		fields = append(fields, w.member(accPublic|accStatic|accFinal, c.name, c.desc, value))
+	}
+	clinit := &classCode{}
+	clinit.invoke(w, opInvokestatic, loader, "touch", "()V")
+	clinit.op(0, opReturn)
+	methods = append(methods, privateConstructor(w), w.member(accStatic, "<clinit>", "()V", w.code(clinit, 0)))
+	for _, f := range cp.funcs {
+		var attrs [][]byte
+		if f.err {
+			attrs = append(attrs, w.attr("Exceptions", u2(1, w.class("java/lang/Exception"))))
+		}
+		methods = append(methods, w.member(accPublic|accStatic|accNative, f.name, f.desc, attrs...))
+	}
+	return w.bytes(accPublic|accSuper|accAbstract, this, super, fields, methods)
+}
+
+// writeClassfiles writes the class files of cps and LoadJNI in the Java
+// package root to jarDir, and the main package of their native library to
+// dir, in the bind module written to bindDir if mod is not nil.
+func writeClassfiles(jarDir, root, bindDir, dir string, cps []classfilePackage, mod *goModule) error {
+	loader := javaInternalName(root) + "/LoadJNI"
+	d, err := classfileLoader(root)
+	if err != nil {
+		return err
+	}
+	classes := map[string][]byte{loader: d}
+	for _, cp := range cps {
+		if classes[cp.class], err = packageClassfile(cp, loader); err != nil {
+			return fmt.Errorf("%s: %v", cp.pkg.Path(), err)
+		}
+	}
+	for class, d := range classes {
+		if err := writeJavaFile(filepath.Join(jarDir, filepath.FromSlash(class)+".class"), d); err != nil {
+			return err
+		}
+	}
+	sources := map[string]string{
+		"classfile_jni.h": classfileJNIHeader,
+		"classfile_jni.c": classfileJNISource,
+		"jni.go":          classfileJNIGo,
+		"main.go":         string(genClassfileMain(cps)),
+	}
+	for name, src := range sources {
+		if err := writeJavaFile(filepath.Join(dir, name), []byte(src)); err != nil {
+			return err
+		}
+	}
+	if mod == nil {
+		return nil
+	}
+	bindPkg, err := build.Import(reflect.TypeOf(bind.ErrorList{}).PkgPath(), "", build.FindOnly)
+	if err != nil {
+		return err
+	}
+	return mod.writeBindModule(bindDir, path.Dir(bindPkg.ImportPath), filepath.Dir(bindPkg.Dir))
+}
+
+// genClassfileMain returns the Go source of the JNI functions implementing
+// the native methods of cps.
+func genClassfileMain(cps []classfilePackage) []byte {
+	imports := map[string]string{}
+	qualifier := func(p *types.Package) string {
+		if a, ok := imports[p.Path()]; ok {
+			return a
+		}
+		a := fmt.Sprintf("bound%d", len(imports))
+		imports[p.Path()] = a
+		return a
+	}
+	var funcs bytes.Buffer
+	for _, cp := range cps {
+		for _, f := range cp.funcs {
+			sig := f.fn.Type().(*types.Signature)
+			params, ret, _ := jniParamTypes(f.desc)
+			decl, args := []string{"env *C.JNIEnv", "clazz C.jclass"}, make([]string, len(params))
+			for i, jt := range params {
+				a := fmt.Sprintf("a%d", i)
+				decl = append(decl, a+" C."+jt)
+				typ := types.TypeString(sig.Params().At(i).Type(), qualifier)
+				switch jt {
+				case "jboolean":
+					args[i] = a + " != 0"
+					if typ != "bool" {
+						args[i] = fmt.Sprintf("%s(%s)", typ, args[i])
+					}
+				case "jstring":
+					args[i] = fmt.Sprintf("gojavaString(env, %s)", a)
+					if typ != "string" {
+						args[i] = fmt.Sprintf("%s(%s)", typ, args[i])
+					}
+				case "jbyteArray":
+					args[i] = fmt.Sprintf("gojavaBytes(env, %s)", a)
+				default:
+					args[i] = fmt.Sprintf("%s(%s)", typ, a)
+				}
+			}
+			call := fmt.Sprintf("%s.%s(%s)", qualifier(cp.pkg), f.fn.Name(), strings.Join(args, ", "))
+			result := ""
+			if ret != "void" {
+				result = " C." + ret
+			}
+			short, _ := classNative{class: cp.class, name: f.name, desc: f.desc}.jniNames()
+			fmt.Fprintf(&funcs, "\n//export %s\nfunc %s(%s)%s {\n", short, short, strings.Join(decl, ", "), result)
+			switch {
+			case ret == "void" && !f.err:
+				fmt.Fprintf(&funcs, "\t%s\n", call)
+			case ret == "void":
+				fmt.Fprintf(&funcs, "\tif err := %s; err != nil {\n\t\tgojavaThrow(env, err)\n\t}\n", call)
+			case !f.err:
+				fmt.Fprintf(&funcs, "\treturn %s\n", classfileResult(ret, sig.Results().At(0).Type(), call))
+			default:
+				fmt.Fprintf(&funcs, "\tr, err := %s\n\tif err != nil {\n\t\tgojavaThrow(env, err)\n\t\treturn 0\n\t}\n", call)
+				fmt.Fprintf(&funcs, "\treturn %s\n", classfileResult(ret, sig.Results().At(0).Type(), "r"))
+			}
+			funcs.WriteString("}\n")
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString(classfileMainHeader)
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for p := range imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		b.WriteString("\nimport (\n")
+		for _, p := range paths {
+			fmt.Fprintf(&b, "\t%s %q\n", imports[p], p)
+		}
+		b.WriteString(")\n")
+	}
+	b.Write(funcs.Bytes())
+	return b.Bytes()
+}
+
+// classfileResult returns the conversion of v, of the Go type t, to the JNI
+// type jt.
+func classfileResult(jt string, t types.Type, v string) string {
+	_, named := types.Unalias(t).(*types.Named)
+	switch jt {
+	case "jboolean":
+		if named {
+			v = "bool(" + v + ")"
+		}
+		return "gojavaBool(" + v + ")"
+	case "jstring":
+		if named {
+			v = "string(" + v + ")"
+		}
+		return "gojavaNewString(env, " + v + ")"
+	case "jbyteArray":
+		return "gojavaNewBytes(env, " + v + ")"
+	}
+	return "C." + jt + "(" + v + ")"
+}
+
+const classfileMainHeader = `// Code generated by gojava. DO NOT EDIT.
+
+// Command gojava_classfile is the native library of bindings built with
+// -backend classfile, implementing the native methods of their classes.
+package main
+
+// #include "classfile_jni.h"
+import "C"
+`
+
+// classfileJNIHeader declares the JNI types and functions used by the native
+// library of -backend classfile, which is built without the JDK headers.
+const classfileJNIHeader = `// Code generated by gojava. DO NOT EDIT.
+
+#include <stddef.h>
+#include <stdint.h>
+
+typedef uint8_t jboolean;
+typedef int8_t jbyte;
+typedef uint16_t jchar;
+typedef int16_t jshort;
+typedef int32_t jint;
+typedef int64_t jlong;
+typedef float jfloat;
+typedef double jdouble;
+typedef jint jsize;
+typedef void *jobject;
+typedef jobject jclass;
+typedef jobject jstring;
+typedef jobject jbyteArray;
+
+// JNIEnv points to the table of the JNI functions.
+typedef void *const *JNIEnv;
+
+jsize gojava_string_length(JNIEnv *env, jstring s);
+void gojava_string_region(JNIEnv *env, jstring s, jsize len, jchar *buf);
+jstring gojava_new_string(JNIEnv *env, const jchar *chars, jsize len);
+jsize gojava_array_length(JNIEnv *env, jbyteArray a);
+void gojava_byte_array_region(JNIEnv *env, jbyteArray a, jsize len, jbyte *buf);
+jbyteArray gojava_new_byte_array(JNIEnv *env, const jbyte *bytes, jsize len);
+void gojava_throw(JNIEnv *env, const char *msg);
+`
+
+// classfileJNISource calls the JNI functions through the function table, at
+// their indices in the JNI specification.
+const classfileJNISource = `// Code generated by gojava. DO NOT EDIT.
+
+#include "classfile_jni.h"
+
+// JNI_FUNC is the function at index in the function table of env.
+#define JNI_FUNC(env, index, type) ((type)(*(env))[index])
+
+jsize gojava_string_length(JNIEnv *env, jstring s) {
+	return JNI_FUNC(env, 164, jsize (*)(JNIEnv *, jstring))(env, s);
+}
+
+void gojava_string_region(JNIEnv *env, jstring s, jsize len, jchar *buf) {
+	JNI_FUNC(env, 220, void (*)(JNIEnv *, jstring, jsize, jsize, jchar *))(env, s, 0, len, buf);
+}
+
+jstring gojava_new_string(JNIEnv *env, const jchar *chars, jsize len) {
+	return JNI_FUNC(env, 163, jstring (*)(JNIEnv *, const jchar *, jsize))(env, chars, len);
+}
+
+jsize gojava_array_length(JNIEnv *env, jbyteArray a) {
+	return JNI_FUNC(env, 171, jsize (*)(JNIEnv *, jbyteArray))(env, a);
+}
+
+void gojava_byte_array_region(JNIEnv *env, jbyteArray a, jsize len, jbyte *buf) {
+	JNI_FUNC(env, 200, void (*)(JNIEnv *, jbyteArray, jsize, jsize, jbyte *))(env, a, 0, len, buf);
+}
+
+jbyteArray gojava_new_byte_array(JNIEnv *env, const jbyte *bytes, jsize len) {
+	jbyteArray a = JNI_FUNC(env, 176, jbyteArray (*)(JNIEnv *, jsize))(env, len);
+	if (a != NULL && len > 0) {
+		JNI_FUNC(env, 208, void (*)(JNIEnv *, jbyteArray, jsize, jsize, const jbyte *))(env, a, 0, len, bytes);
+	}
+	return a;
+}
+
+void gojava_throw(JNIEnv *env, const char *msg) {
+	jclass c = JNI_FUNC(env, 6, jclass (*)(JNIEnv *, const char *))(env, "java/lang/Exception");
+	if (c != NULL) {
+		JNI_FUNC(env, 14, jint (*)(JNIEnv *, jclass, const char *))(env, c, msg);
+	}
+}
+`
+
+const classfileJNIGo = `// Code generated by gojava. DO NOT EDIT.
+
+package main
+
+// #include <stdlib.h>
+// #include "classfile_jni.h"
+import "C"
+
+import (
+	"unicode/utf16"
+	"unsafe"
+)
+
+// gojavaString returns the Java string s. A null string is empty. cgo
+// declares the JNI references as uintptr, with 0 for null.
+func gojavaString(env *C.JNIEnv, s C.jstring) string {
+	if s == 0 {
+		return ""
+	}
+	n := C.gojava_string_length(env, s)
+	if n == 0 {
+		return ""
+	}
+	buf := make([]uint16, n)
+	C.gojava_string_region(env, s, n, (*C.jchar)(unsafe.Pointer(&buf[0])))
+	return string(utf16.Decode(buf))
+}
+
+// gojavaNewString returns s as a Java string.
+func gojavaNewString(env *C.JNIEnv, s string) C.jstring {
+	buf := utf16.Encode([]rune(s))
+	if len(buf) == 0 {
+		return C.gojava_new_string(env, nil, 0)
+	}
+	return C.gojava_new_string(env, (*C.jchar)(unsafe.Pointer(&buf[0])), C.jsize(len(buf)))
+}
+
+// gojavaBytes returns a copy of the Java byte array a. A null array is nil.
+func gojavaBytes(env *C.JNIEnv, a C.jbyteArray) []byte {
+	if a == 0 {
+		return nil
+	}
+	n := C.gojava_array_length(env, a)
+	b := make([]byte, n)
+	if n > 0 {
+		C.gojava_byte_array_region(env, a, n, (*C.jbyte)(unsafe.Pointer(&b[0])))
+	}
+	return b
+}
+
+// gojavaNewBytes returns a copy of b as a Java byte array, or null if b is
+// nil.
+func gojavaNewBytes(env *C.JNIEnv, b []byte) C.jbyteArray {
+	if b == nil {
+		return 0
+	}
+	var p *C.jbyte
+	if len(b) > 0 {
+		p = (*C.jbyte)(unsafe.Pointer(&b[0]))
+	}
+	return C.gojava_new_byte_array(env, p, C.jsize(len(b)))
+}
+
+func gojavaBool(b bool) C.jboolean {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// gojavaThrow throws err as a java.lang.Exception once the native method
+// returns.
+func gojavaThrow(env *C.JNIEnv, err error) {
+	msg := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(msg))
+	C.gojava_throw(env, msg)
+}
+
+func main() {}
+`
+
+// classfileBuild is the state of bindToJar that bindClassfiles builds the
+// jar from.
+type classfileBuild struct {
+	// dir and pkgs are the directory and the packages given to bindToJar,
+	// started the time the build started.
+	dir     string
+	pkgs    []string
+	started time.Time
+
+	bindDir, jarDir, classDir string
+
+	fset     *token.FileSet
+	typePkgs []*types.Package
+	exposed  map[*types.Package][]string
+	moved    map[string]string
+	mod      *goModule
+	overlay  string
+}
+
+// bindClassfiles builds the jar of b with -backend classfile: the class files
+// are written by gojava and the native library implements their native
+// methods itself, so neither gomobile nor a Java compiler is needed.
+func bindClassfiles(cfg *config, b classfileBuild) error {
+	root := javaRoot(cfg)
+	cps, err := findClassfilePackages(b.typePkgs, b.exposed, root, b.moved)
+	if err != nil {
+		return err
+	}
+	mainDir := filepath.Join(b.bindDir, "gojava_classfile")
+	if err := writeClassfiles(b.jarDir, root, b.bindDir, mainDir, cps, b.mod); err != nil {
+		return err
+	}
+	err = withTimeout("go build", cfg.goTimeout, func() error {
+		return buildGo(cfg, b.classDir, mainDir, b.bindDir, b.mod, b.overlay)
+	})
+	if err != nil {
+		return err
+	}
+	lib := filepath.Join(b.classDir, "libgojava")
+	if err := verifyNatives(b.jarDir, root, lib, b.typePkgs, b.moved); err != nil {
+		return err
+	}
+	if err := writeLicenses(b.jarDir, mainDir, b.mod, b.overlay); err != nil {
+		return err
+	}
+	if err := writeAPIManifest(b.jarDir, b.typePkgs, cfg.split); err != nil {
+		return err
+	}
+	if cfg.sourceMap {
+		if err := writeSourceMap(b.jarDir, b.fset, b.typePkgs, cfg.split); err != nil {
+			return err
+		}
+	}
+	if err := writeJars(cfg, []jarBuild{{cfg, b.jarDir}}); err != nil {
+		return err
+	}
+	if cfg.out != nil {
+		if err := copyJar(cfg.out, cfg.target); err != nil {
+			return err
+		}
+	}
+	if cfg.provenance != "" {
+		if err := writeProvenance(cfg.provenance, []string{targetPath(cfg)}, lib, b.dir, b.pkgs, b.started); err != nil {
+			return err
+		}
+	}
+	if cfg.ideMetadata == "" {
+		return nil
+	}
+	return writeIDEMetadata(cfg.ideMetadata, cfg, b.fset, b.typePkgs)
+}
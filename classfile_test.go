@@ -0,0 +1,390 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const classfileSrc = `package lib
+
+import "errors"
+
+// Version is the version of lib.
+const Version = "1.0"
+
+const (
+	Max     = 1 << 40
+	Ratio   = 0.5
+	Enabled = true
+	Huge    = 1 << 70
+)
+
+type Celsius float64
+
+func Add(a, b int) int { return a + b }
+
+func Concat(a, b string) string { return a + b }
+
+func Reverse(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
+
+func Check(ok bool) (bool, error) {
+	if !ok {
+		return false, errors.New("not ok")
+	}
+	return true, nil
+}
+
+func Warm(c Celsius) Celsius { return c + 1 }
+
+func Fail() error { return errors.New("failed") }
+
+func Touch() {}
+`
+
+func TestFindClassfilePackages(t *testing.T) {
+	p, _ := typeCheckFile(t, strings.Replace(classfileSrc, "package lib", "package testpkg", 1))
+	cps, err := findClassfilePackages([]*types.Package{p}, nil, "go", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cps) != 1 || cps[0].class != "go/testpkg/Testpkg" {
+		t.Fatalf("got packages %+v", cps)
+	}
+	var funcs, consts []string
+	for _, f := range cps[0].funcs {
+		funcs = append(funcs, f.name+f.desc)
+		if f.err != (f.name == "check" || f.name == "fail") {
+			t.Errorf("%s: got err %v", f.name, f.err)
+		}
+	}
+	for _, c := range cps[0].consts {
+		consts = append(consts, c.name+" "+c.desc)
+	}
+	expected := []string{"add(JJ)J", "check(Z)Z", "concat(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;", "fail()V", "reverse([B)[B", "touch()V", "warm(D)D"}
+	if !reflect.DeepEqual(funcs, expected) {
+		t.Errorf("got functions %v, want %v", funcs, expected)
+	}
+	expected = []string{"Enabled Z", "Max J", "Ratio D", "Version Ljava/lang/String;"}
+	if !reflect.DeepEqual(consts, expected) {
+		t.Errorf("got constants %v, want %v", consts, expected)
+	}
+
+	exposed := map[*types.Package][]string{p: {"Add", "Version"}}
+	if cps, err = findClassfilePackages([]*types.Package{p}, exposed, "go_v2", map[string]string{"testpkg": "com.example.lib"}); err != nil {
+		t.Fatal(err)
+	}
+	if cps[0].class != "com/example/lib/Testpkg" || len(cps[0].funcs) != 1 || len(cps[0].consts) != 1 {
+		t.Errorf("exposed: got %+v", cps[0])
+	}
+
+	for src, what := range map[string]string{
+		"type Store struct{}\n":                    "Store is a type",
+		"var Debug bool\n":                         "Debug is a variable",
+		"func Sum(v ...int) int { return 0 }\n":    "Sum is a function",
+		"func Size() uint32 { return 0 }\n":        "Size is a function",
+		"func Pair() (int, int) { return 0, 0 }\n": "Pair is a function",
+	} {
+		p, _ := typeCheckFile(t, "package testpkg\n\n"+src)
+		_, err := findClassfilePackages([]*types.Package{p}, nil, "go", nil)
+		if err == nil || !strings.HasPrefix(err.Error(), "-backend classfile only binds") || !strings.Contains(err.Error(), what) {
+			t.Errorf("%s: got %v", src, err)
+		}
+	}
+}
+
+func TestPackageClassfile(t *testing.T) {
+	p, _ := typeCheckFile(t, strings.Replace(classfileSrc, "package lib", "package testpkg", 1))
+	cps, err := findClassfilePackages([]*types.Package{p}, nil, "go", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := packageClassfile(cps[0], "go/LoadJNI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := readClass(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.name != "go/testpkg/Testpkg" || len(c.natives) != 7 {
+		t.Fatalf("got class %s with natives %v", c.name, c.natives)
+	}
+	if n := c.natives[0]; n != (classNative{"go/testpkg/Testpkg", "add", "(JJ)J", true}) {
+		t.Errorf("got native %+v", n)
+	}
+	for _, s := range []string{"ConstantValue", "Exceptions", "java/lang/Exception", "go/LoadJNI", "touch"} {
+		if !bytes.Contains(d, []byte(s)) {
+			t.Errorf("class file missing %q", s)
+		}
+	}
+
+	main := genClassfileMain(cps)
+	if formatted, err := format.Source(main); err != nil || !bytes.Equal(formatted, main) {
+		t.Errorf("main package not formatted: %v\n%s", err, main)
+	}
+	for _, s := range []string{
+		"//export Java_go_testpkg_Testpkg_check\nfunc Java_go_testpkg_Testpkg_check(env *C.JNIEnv, clazz C.jclass, a0 C.jboolean) C.jboolean {\n\tr, err := bound0.Check(a0 != 0)\n",
+		"\treturn C.jdouble(bound0.Warm(bound0.Celsius(a0)))\n",
+	} {
+		if !bytes.Contains(main, []byte(s)) {
+			t.Errorf("main package missing %q:\n%s", s, main)
+		}
+	}
+
+	d, err = classfileLoader("go_v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err = readClass(d); err != nil {
+		t.Fatal(err)
+	}
+	if c.name != "go_v2/LoadJNI" || len(c.natives) != 0 {
+		t.Errorf("got loader %s with natives %v", c.name, c.natives)
+	}
+	if !bytes.Contains(d, []byte("/go_v2/libgojava")) {
+		t.Error("loader does not load /go_v2/libgojava")
+	}
+}
+
+func TestClassCode(t *testing.T) {
+	w := newClassWriter()
+	c := &classCode{stack: 6, maxStack: 6}
+	c.invoke(w, opInvokevirtual, "java/io/Writer", "write", "(Ljava/lang/String;JI[D)J")
+	if c.stack != 2 {
+		t.Errorf("got stack %d after a call, want 2", c.stack)
+	}
+	c.invoke(w, opInvokestatic, "java/lang/Math", "abs", "(J)J")
+	c.op(-2, opPop2)
+	if c.stack != 0 || c.maxStack != 6 {
+		t.Errorf("got stack %d, max %d", c.stack, c.maxStack)
+	}
+	c = &classCode{}
+	c.ldc(w.constant(8, u2(w.utf8("a"))))
+	for i := 0; i < 300; i++ {
+		w.utf8(strings.Repeat("x", i))
+	}
+	c.ldc(w.constant(8, u2(w.utf8("b"))))
+	if b := c.b.Bytes(); b[0] != opLdc || b[2] != opLdcW || c.maxStack != 2 {
+		t.Errorf("got code % x", b)
+	}
+}
+
+func TestModifiedUTF8(t *testing.T) {
+	for s, expected := range map[string][]byte{
+		"abc":   []byte("abc"),
+		"a\x00": {'a', 0xc0, 0x80},
+		"é":     []byte("é"),
+		"€":     []byte("€"),
+		"😀":     {0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80},
+	} {
+		if got := modifiedUTF8(s); !bytes.Equal(got, expected) {
+			t.Errorf("%q: got % x, want % x", s, got, expected)
+		}
+	}
+}
+
+func TestClassfileFlags(t *testing.T) {
+	cfg := &config{backend: "classfile", javacImpl: "javac", sCollisions: collisionError, lazy: true, registry: true}
+	err := checkConfig(cfg)
+	if err == nil || !strings.HasSuffix(err.Error(), "cannot be used with -lazy, -registry") {
+		t.Errorf("got %v", err)
+	}
+	if err := checkConfig(&config{backend: "classfile", javacImpl: "javac", sCollisions: collisionError, versionSuffix: "v2"}); err != nil {
+		t.Errorf("version suffix: %v", err)
+	}
+}
+
+// classfileHarness calls the JNI functions of the native library built from
+// classfileSrc, with a JNIEnv implementing the JNI functions they use.
+const classfileHarness = `#include <dlfcn.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef struct { int32_t len; void *data; } obj;
+
+static char thrown[256];
+
+static void *find_class(void *env, const char *name) { return (void *)name; }
+static int32_t throw_new(void *env, void *c, const char *msg) { snprintf(thrown, sizeof thrown, "%s: %s", (char *)c, msg); return 0; }
+static obj *new_obj(int32_t len, int size) { obj *o = malloc(sizeof *o); o->len = len; o->data = calloc(len + 1, size); return o; }
+static void *new_string(void *env, const uint16_t *c, int32_t n) { obj *o = new_obj(n, 2); memcpy(o->data, c, 2 * n); return o; }
+static int32_t length(void *env, obj *o) { return o->len; }
+static void string_region(void *env, obj *o, int32_t start, int32_t n, uint16_t *buf) { memcpy(buf, (uint16_t *)o->data + start, 2 * n); }
+static void *new_bytes(void *env, int32_t n) { return new_obj(n, 1); }
+static void get_bytes(void *env, obj *o, int32_t start, int32_t n, int8_t *buf) { memcpy(buf, (int8_t *)o->data + start, n); }
+static void set_bytes(void *env, obj *o, int32_t start, int32_t n, const int8_t *buf) { memcpy((int8_t *)o->data + start, buf, n); }
+
+static void *table[229];
+
+static void print_string(obj *s) {
+	for (int i = 0; i < s->len; i++) {
+		printf("%04x ", ((uint16_t *)s->data)[i]);
+	}
+	printf("\n");
+}
+
+int main(int argc, char **argv) {
+	table[6] = find_class;
+	table[14] = throw_new;
+	table[163] = new_string;
+	table[164] = length;
+	table[171] = length;
+	table[176] = new_bytes;
+	table[200] = get_bytes;
+	table[208] = set_bytes;
+	table[220] = string_region;
+	void **table_ptr = table;
+	void *env = &table_ptr;
+	void *lib = dlopen(argv[1], RTLD_NOW);
+	if (lib == NULL) {
+		fprintf(stderr, "%s\n", dlerror());
+		return 1;
+	}
+	int64_t (*add)(void *, void *, int64_t, int64_t) = dlsym(lib, "Java_go_lib_Lib_add");
+	void *(*concat)(void *, void *, void *, void *) = dlsym(lib, "Java_go_lib_Lib_concat");
+	void *(*reverse)(void *, void *, void *) = dlsym(lib, "Java_go_lib_Lib_reverse");
+	uint8_t (*check)(void *, void *, uint8_t) = dlsym(lib, "Java_go_lib_Lib_check");
+	double (*warm)(void *, void *, double) = dlsym(lib, "Java_go_lib_Lib_warm");
+	void (*fail)(void *, void *) = dlsym(lib, "Java_go_lib_Lib_fail");
+	printf("%lld\n", (long long)add(env, NULL, 2, 3));
+	uint16_t a[] = {'a', 'b'}, b[] = {0xe9, 0xd83d, 0xde00};
+	print_string(concat(env, NULL, new_string(env, a, 2), new_string(env, b, 3)));
+	print_string(concat(env, NULL, NULL, new_string(env, a, 1)));
+	obj *bytes = new_bytes(env, 3);
+	memcpy(bytes->data, "xyz", 3);
+	obj *r = reverse(env, NULL, bytes);
+	printf("%.*s %s\n", r->len, (char *)r->data, reverse(env, NULL, NULL) == NULL ? "null" : "not null");
+	printf("%d [%s]\n", check(env, NULL, 1), thrown);
+	printf("%d [%s]\n", check(env, NULL, 0), thrown);
+	printf("%g\n", warm(env, NULL, 1.5));
+	thrown[0] = 0;
+	fail(env, NULL);
+	printf("[%s]\n", thrown);
+	return 0;
+}
+`
+
+// classfileMain is the class file of Main, printing lib.Lib.concat("go",
+// "java") and lib.Lib.Version.
+func classfileMain(t *testing.T) []byte {
+	w := newClassWriter()
+	this, super := w.class("Main"), w.class("java/lang/Object")
+	c := &classCode{}
+	out := w.ref(9, "java/lang/System", "out", "Ljava/io/PrintStream;")
+	c.index(1, opGetstatic, out)
+	c.ldc(w.constant(8, u2(w.utf8("go"))))
+	c.ldc(w.constant(8, u2(w.utf8("java"))))
+	c.invoke(w, opInvokestatic, "go/lib/Lib", "concat", "(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;")
+	c.invoke(w, opInvokevirtual, "java/io/PrintStream", "println", "(Ljava/lang/String;)V")
+	c.index(1, opGetstatic, out)
+	c.index(1, opGetstatic, w.ref(9, "go/lib/Lib", "Version", "Ljava/lang/String;"))
+	c.invoke(w, opInvokevirtual, "java/io/PrintStream", "println", "(Ljava/lang/String;)V")
+	c.op(0, opReturn)
+	main := w.member(accPublic|accStatic, "main", "([Ljava/lang/String;)V", w.code(c, 1))
+	d, err := w.bytes(accPublic|accSuper, this, super, nil, [][]byte{main})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestClassfileBackend(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("cc not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	src := filepath.Join(tmpDir, "src")
+	for name, content := range map[string]string{"go.mod": "module example.com/lib\n\ngo 1.16\n", "lib.go": classfileSrc} {
+		if err := writeJavaFile(filepath.Join(src, name), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The build needs no JDK.
+	oldJavaHome := javaHome
+	javaHome = ""
+	jar := filepath.Join(tmpDir, "lib.jar")
+	err = bindToJar(&config{target: jar, backend: "classfile"}, src, ".")
+	javaHome = oldJavaHome
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	entries := make(map[string]*zip.File)
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	for _, name := range []string{"go/LoadJNI.class", "go/lib/Lib.class", "go/libgojava"} {
+		if entries[name] == nil {
+			t.Fatalf("jar missing %s", name)
+		}
+	}
+	lib := filepath.Join(tmpDir, "libgojava.so")
+	rc, err := entries["go/libgojava"].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(lib, d, 0700); err != nil {
+		t.Fatal(err)
+	}
+	harness := filepath.Join(tmpDir, "harness")
+	if err := ioutil.WriteFile(harness+".c", []byte(classfileHarness), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("cc", "-o", harness, harness+".c", "-ldl").CombinedOutput(); err != nil {
+		t.Fatalf("cc: %v\n%s", err, out)
+	}
+	out, err := exec.Command(harness, lib).CombinedOutput()
+	if err != nil {
+		t.Fatalf("harness: %v\n%s", err, out)
+	}
+	expected := "5\n0061 0062 00e9 d83d de00 \n0061 \nzyx null\n1 []\n0 [java/lang/Exception: not ok]\n2.5\n[java/lang/Exception: failed]\n"
+	if string(out) != expected {
+		t.Errorf("got\n%s\nwant\n%s", out, expected)
+	}
+
+	java, err := exec.LookPath(javaTool("java", ""))
+	if err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "Main.class"), classfileMain(t), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err = exec.Command(java, "-cp", jar+string(os.PathListSeparator)+tmpDir, "Main").CombinedOutput()
+	if err != nil || string(out) != "gojava\n1.0\n" {
+		t.Errorf("java: %v\n%s", err, out)
+	}
+}
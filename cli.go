@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// cobraCommand is the type of the cobra commands bound with -cli.
+const cobraCommand = "*github.com/spf13/cobra.Command"
+
+// cliRoot is a Go command line tool bound with -cli, returned by the function
+// fn of pkg. It is either a cobra command, or a flag.FlagSet with the function
+// running the tool with the remaining arguments.
+type cliRoot struct {
+	pkg   *types.Package
+	fn    string
+	cobra bool
+}
+
+// cliCommand describes a command of a bound tool and its subcommands.
+type cliCommand struct {
+	Name     string       `json:"name"`
+	Short    string       `json:"short,omitempty"`
+	Flags    []cliFlag    `json:"flags,omitempty"`
+	Commands []cliCommand `json:"commands,omitempty"`
+}
+
+// cliFlag describes a flag of a command. Type is the pflag type name of its
+// value, such as bool, int64, duration or stringSlice.
+type cliFlag struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Usage string `json:"usage,omitempty"`
+}
+
+// findCLIs returns the tools named by the -cli arguments args, as
+// <pkg>.<Func>, which must be in the bound packages pkgs.
+func findCLIs(args []string, pkgs []*types.Package) ([]cliRoot, error) {
+	var roots []cliRoot
+	for _, arg := range args {
+		path, name, err := splitFunc(arg)
+		if err != nil {
+			return nil, err
+		}
+		var p *types.Package
+		for _, bp := range pkgs {
+			if bp.Path() == path || bp.Name() == path {
+				p = bp
+			}
+		}
+		if p == nil {
+			return nil, fmt.Errorf("-cli %s: package %s is not bound", arg, path)
+		}
+		fn, ok := p.Scope().Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() {
+			return nil, fmt.Errorf("-cli %s: %s has no exported function %s", arg, p.Path(), name)
+		}
+		sig := fn.Type().(*types.Signature)
+		res := sig.Results()
+		switch {
+		case sig.Params().Len() == 0 && res.Len() == 1 && res.At(0).Type().String() == cobraCommand:
+			roots = append(roots, cliRoot{p, name, true})
+		case sig.Params().Len() == 0 && res.Len() == 2 && res.At(0).Type().String() == "*flag.FlagSet" &&
+			res.At(1).Type().String() == "func(args []string, stdout io.Writer) error":
+			roots = append(roots, cliRoot{p, name, false})
+		default:
+			return nil, fmt.Errorf("-cli %s: must have signature func() *cobra.Command or func() (*flag.FlagSet, func(args []string, stdout io.Writer) error)", arg)
+		}
+	}
+	return roots, nil
+}
+
+// describeCLIs returns the command trees of roots, found by running a program
+// built in a subdirectory of bindDir for the host, as the commands and their
+// flags are only known at run time.
+func describeCLIs(bindDir string, roots []cliRoot, mod *goModule, overlay string) ([]cliCommand, error) {
+	dir := filepath.Join(bindDir, "gojava_cli_describe")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), cliDescribeSource(roots), 0600); err != nil {
+		return nil, err
+	}
+	host, err := commandOutput("go", "env", "GOHOSTOS", "GOHOSTARCH")
+	if err != nil {
+		return nil, err
+	}
+	f := strings.Fields(string(host))
+	if len(f) != 2 {
+		return nil, fmt.Errorf("go env GOHOSTOS GOHOSTARCH: unexpected output %q", host)
+	}
+	out := filepath.Join(bindDir, "gojava_cli.json")
+	args := append(append([]string{"run"}, modFlags(mod, overlay)...), ".", out)
+	// Run the program on the host when cross-building the native library.
+	env := commandEnv()
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env, "GOOS="+f[0], "GOARCH="+f[1])
+	verbosef("Describing the commands of %d tools\n", len(roots))
+	if err := runCommandEnv(dir, env, "go", args...); err != nil {
+		return nil, err
+	}
+	d, err := ioutil.ReadFile(out)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []cliCommand
+	if err := json.Unmarshal(d, &cmds); err != nil {
+		return nil, err
+	}
+	if len(cmds) != len(roots) {
+		return nil, fmt.Errorf("described %d tools, expected %d", len(cmds), len(roots))
+	}
+	return cmds, nil
+}
+
+// cliDescribeSource returns the program writing the command trees of roots
+// as JSON to the file named by its argument.
+func cliDescribeSource(roots []cliRoot) []byte {
+	var b bytes.Buffer
+	b.WriteString("package main\n\nimport (\n\t\"encoding/json\"\n\t\"io/ioutil\"\n\t\"os\"\n")
+	hasCobra, hasFlag := false, false
+	for _, r := range roots {
+		hasCobra = hasCobra || r.cobra
+		hasFlag = hasFlag || !r.cobra
+	}
+	if hasCobra {
+		b.WriteString("\n\t\"github.com/spf13/cobra\"\n\t\"github.com/spf13/pflag\"\n")
+	}
+	if hasFlag {
+		b.WriteString("\t\"flag\"\n\t\"fmt\"\n\t\"strings\"\n")
+	}
+	for i, r := range roots {
+		fmt.Fprintf(&b, "\tcli%d %q\n", i, r.pkg.Path())
+	}
+	b.WriteString(")\n")
+	b.WriteString(cliDescribeTypes)
+	if hasCobra {
+		b.WriteString(cliDescribeCobra)
+	}
+	if hasFlag {
+		b.WriteString(cliDescribeFlag)
+	}
+	b.WriteString("\nfunc main() {\n\tvar cmds []command\n")
+	for i, r := range roots {
+		if r.cobra {
+			fmt.Fprintf(&b, "\tcmds = append(cmds, describeCobra(cli%d.%s()))\n", i, r.fn)
+		} else {
+			fmt.Fprintf(&b, "\tfs%d, _ := cli%d.%s()\n\tcmds = append(cmds, describeFlags(fs%d))\n", i, i, r.fn, i)
+		}
+	}
+	b.WriteString("\td, err := json.Marshal(cmds)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	b.WriteString("\tif err := ioutil.WriteFile(os.Args[1], d, 0600); err != nil {\n\t\tpanic(err)\n\t}\n}\n")
+	return b.Bytes()
+}
+
+const cliDescribeTypes = `
+type command struct {
+	Name     string    ` + "`json:\"name\"`" + `
+	Short    string    ` + "`json:\"short,omitempty\"`" + `
+	Flags    []cliFlag ` + "`json:\"flags,omitempty\"`" + `
+	Commands []command ` + "`json:\"commands,omitempty\"`" + `
+}
+
+type cliFlag struct {
+	Name  string ` + "`json:\"name\"`" + `
+	Type  string ` + "`json:\"type\"`" + `
+	Usage string ` + "`json:\"usage,omitempty\"`" + `
+}
+`
+
+const cliDescribeCobra = `
+func describeCobra(c *cobra.Command) command {
+	d := command{Name: c.Name(), Short: c.Short}
+	add := func(f *pflag.Flag) {
+		if f.Name != "help" && !f.Hidden {
+			d.Flags = append(d.Flags, cliFlag{f.Name, f.Value.Type(), f.Usage})
+		}
+	}
+	c.LocalFlags().VisitAll(add)
+	c.InheritedFlags().VisitAll(add)
+	for _, sub := range c.Commands() {
+		if !sub.Hidden && sub.Name() != "help" && sub.Name() != "completion" {
+			d.Commands = append(d.Commands, describeCobra(sub))
+		}
+	}
+	return d
+}
+`
+
+const cliDescribeFlag = `
+func describeFlags(fs *flag.FlagSet) command {
+	d := command{Name: fs.Name()}
+	fs.VisitAll(func(f *flag.Flag) {
+		typ := "string"
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			typ = "bool"
+		} else if t := fmt.Sprintf("%T", f.Value); strings.HasPrefix(t, "*flag.") && strings.HasSuffix(t, "Value") {
+			typ = strings.TrimSuffix(strings.TrimPrefix(t, "*flag."), "Value")
+		}
+		d.Flags = append(d.Flags, cliFlag{f.Name, typ, f.Usage})
+	})
+	return d
+}
+`
+
+// genCLIHooks writes the Go and C code to bindDir implementing the native
+// method of go.GoCommand, which runs the tool roots[i] with the given
+// arguments and returns its exit code and output.
+func genCLIHooks(bindDir string, roots []cliRoot) error {
+	var imports, runs bytes.Buffer
+	hasFlag := false
+	for i, r := range roots {
+		fmt.Fprintf(&imports, "\tcli%d %q\n", i, r.pkg.Path())
+		if r.cobra {
+			fmt.Fprintf(&runs, cliRunCobra, i, r.fn)
+		} else {
+			hasFlag = true
+			fmt.Fprintf(&runs, cliRunFlag, i, r.fn)
+		}
+	}
+	if hasFlag {
+		imports.WriteString("\t\"flag\"\n")
+	}
+	goSrc := fmt.Sprintf(cliHooksGo, imports.String(), runs.String())
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_cli.go"), []byte(goSrc), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_cli.c"), []byte(cliHooksC), 0600)
+}
+
+const cliHooksGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+%s)
+
+// gojavaCLIs run the tools bound with -cli with args, writing their output to
+// stdout and stderr and returning the exit code.
+var gojavaCLIs = []func(args []string, stdout, stderr *bytes.Buffer) int{
+%s}
+
+// gojavaRunCLI runs the tool root, reporting a panic as exit code 2.
+func gojavaRunCLI(root int, args []string, stdout, stderr *bytes.Buffer) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(stderr, "panic: %%v\n", r)
+			code = 2
+		}
+	}()
+	return gojavaCLIs[root](args, stdout, stderr)
+}
+
+//export gojava_cli_run
+func gojava_cli_run(root C.int, args *C.char, n C.int, size *C.int) *C.char {
+	var argv []string
+	if d := C.GoBytes(unsafe.Pointer(args), n); len(d) > 0 {
+		for _, a := range bytes.Split(d[:len(d)-1], []byte{0}) {
+			argv = append(argv, string(a))
+		}
+	}
+	var stdout, stderr, res bytes.Buffer
+	code := gojavaRunCLI(int(root), argv, &stdout, &stderr)
+	binary.Write(&res, binary.BigEndian, int32(code))
+	binary.Write(&res, binary.BigEndian, int32(stdout.Len()))
+	res.Write(stdout.Bytes())
+	res.Write(stderr.Bytes())
+	*size = C.int(res.Len())
+	return (*C.char)(C.CBytes(res.Bytes()))
+}
+`
+
+const cliRunCobra = `	func(args []string, stdout, stderr *bytes.Buffer) int {
+		cmd := cli%d.%s()
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		if err := cmd.Execute(); err != nil {
+			return 1
+		}
+		return 0
+	},
+`
+
+const cliRunFlag = `	func(args []string, stdout, stderr *bytes.Buffer) int {
+		fs, run := cli%d.%s()
+		// Report parse errors instead of exiting the JVM.
+		fs.Init(fs.Name(), flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		if err := fs.Parse(args); err == flag.ErrHelp {
+			return 0
+		} else if err != nil {
+			return 2
+		}
+		if err := run(fs.Args(), stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	},
+`
+
+const cliHooksC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jbyteArray JNICALL Java_go_GoCommand_run0(JNIEnv *env, jclass clazz, jint root, jbyteArray args) {
+	jsize n = (*env)->GetArrayLength(env, args);
+	jbyte *a = (*env)->GetByteArrayElements(env, args, NULL);
+	int size = 0;
+	char *res = gojava_cli_run(root, (char *)a, n, &size);
+	(*env)->ReleaseByteArrayElements(env, args, a, JNI_ABORT);
+	jbyteArray out = (*env)->NewByteArray(env, size);
+	if (out != NULL) {
+		(*env)->SetByteArrayRegion(env, out, 0, size, (jbyte *)res);
+	}
+	free(res);
+	return out;
+}
+`
+
+// cliIdent returns name as a Java identifier in camel case, starting with an
+// upper case letter if upper is set. Characters other than letters and digits
+// separate words.
+func cliIdent(name string, upper bool) string {
+	var b strings.Builder
+	for i, w := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if i > 0 || upper {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		b.WriteString(w)
+	}
+	s := b.String()
+	if s != "" && unicode.IsDigit(rune(s[0])) {
+		s = "_" + s
+	}
+	return javaIdent(s)
+}
+
+// cliSetter returns the Java parameter type of the setter of a flag of type
+// typ, and the statements adding it to the command line.
+func cliSetter(name, typ string) (string, string) {
+	q := fmt.Sprintf("%q", name)
+	switch {
+	case typ == "bool":
+		return "boolean v", "flag(" + q + ", String.valueOf(v));"
+	case typ == "count" || strings.HasPrefix(typ, "int") && !strings.Contains(typ, "Slice") || strings.HasPrefix(typ, "uint") && !strings.Contains(typ, "Slice"):
+		return "long v", "flag(" + q + ", Long.toString(v));"
+	case typ == "float32" || typ == "float64":
+		return "double v", "flag(" + q + ", Double.toString(v));"
+	case typ == "duration":
+		return "java.time.Duration v", "flag(" + q + ", v.toNanos() + \"ns\");"
+	case strings.HasSuffix(typ, "Slice") || strings.HasSuffix(typ, "Array"):
+		return "String... v", "for (String s : v) {\n\t\t\tflag(" + q + ", s);\n\t\t}"
+	}
+	return "String v", "flag(" + q + ", v);"
+}
+
+// genCLIClass writes the class for the command c of the tool root, and the
+// classes of its subcommands nested in it, to b. path is the names of the
+// command and its parents after the root, and outer the names of the
+// enclosing classes.
+func genCLIClass(b *bytes.Buffer, root int, c cliCommand, class string, path []string, outer map[string]bool, indent string) {
+	in := indent + "\t"
+	if len(path) == 0 {
+		fmt.Fprintf(b, "%s// %s runs the Go command %s in process.\n", indent, class, c.Name)
+		fmt.Fprintf(b, "%spublic class %s extends go.GoCommand {\n", indent, class)
+	} else {
+		fmt.Fprintf(b, "%s// %s runs the subcommand %s.\n", indent, class, strings.Join(path, " "))
+		fmt.Fprintf(b, "%spublic static class %s extends go.GoCommand {\n", indent, class)
+	}
+	if c.Short != "" {
+		fmt.Fprintf(b, "%s/** %s */\n", in, javadocText(c.Short))
+	}
+	var quoted []string
+	for _, s := range path {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	fmt.Fprintf(b, "%spublic %s() {\n%s\tsuper(%s);\n%s}\n", in, class, in, strings.Join(append([]string{fmt.Sprint(root)}, quoted...), ", "), in)
+	fmt.Fprintf(b, "\n%s@Override\n%spublic %s args(String... args) {\n%s\taddArgs(args);\n%s\treturn this;\n%s}\n", in, in, class, in, in, in)
+	for _, f := range c.Flags {
+		ident := cliIdent(f.Name, true)
+		if ident == "" {
+			continue
+		}
+		param, stmt := cliSetter(f.Name, f.Type)
+		fmt.Fprintf(b, "\n")
+		if f.Usage != "" {
+			fmt.Fprintf(b, "%s/** --%s: %s */\n", in, f.Name, javadocText(f.Usage))
+		}
+		fmt.Fprintf(b, "%spublic %s set%s(%s) {\n%s\t%s\n%s\treturn this;\n%s}\n", in, class, ident, param, in, strings.Replace(stmt, "\n\t\t", "\n"+in+"\t", -1), in, in)
+	}
+	outer[class] = true
+	for _, sub := range c.Commands {
+		name := cliIdent(sub.Name, true)
+		if name == "" {
+			continue
+		}
+		// A nested class cannot have the name of an enclosing class.
+		for outer[name] {
+			name += "_"
+		}
+		b.WriteString("\n")
+		genCLIClass(b, root, sub, name, append(path[:len(path):len(path)], sub.Name), outer, in)
+	}
+	delete(outer, class)
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// genCLIClasses writes go.GoCommand and a class for each tool in roots, with
+// the command tree cmds, to javaDir. It returns the paths of the files.
+func genCLIClasses(javaDir string, roots []cliRoot, cmds []cliCommand) ([]string, error) {
+	path := filepath.Join(javaDir, "GoCommand.java")
+	if err := writeJavaFile(path, []byte(goCommandJava)); err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	seen := make(map[string]bool)
+	for i, r := range roots {
+		class := cliIdent(cmds[i].Name, true)
+		if class == "" {
+			class = cliIdent(r.fn, true)
+		}
+		class += "Command"
+		path := filepath.Join(javaDir, r.pkg.Name(), class+".java")
+		if seen[path] {
+			return nil, fmt.Errorf("-cli %s.%s: class %s.%s is already generated for another tool", r.pkg.Path(), r.fn, javaPkgName(r.pkg), class)
+		}
+		seen[path] = true
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// Java command line for %s.%s generated by gojava.\n", r.pkg.Path(), r.fn)
+		fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(r.pkg))
+		genCLIClass(&b, i, cmds[i], class, nil, make(map[string]bool), "")
+		if err := writeJavaFile(path, b.Bytes()); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// bindCLIs generates the Java classes and native code for the tools named by
+// the -cli arguments args in pkgs, returning the paths of the Java files.
+func bindCLIs(args []string, bindDir, javaDir string, pkgs []*types.Package, mod *goModule, overlay string) ([]string, error) {
+	roots, err := findCLIs(args, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	cmds, err := describeCLIs(bindDir, roots, mod, overlay)
+	if err != nil {
+		return nil, err
+	}
+	if err := genCLIHooks(bindDir, roots); err != nil {
+		return nil, err
+	}
+	return genCLIClasses(javaDir, roots, cmds)
+}
+
+const goCommandJava = `package go;
+
+import java.io.ByteArrayOutputStream;
+import java.nio.ByteBuffer;
+import java.nio.charset.Charset;
+import java.util.ArrayList;
+import java.util.Collections;
+import java.util.List;
+
+// GoCommand is a Go command line tool bound with -cli, which execute runs in
+// process. The generated subclasses have a setter for each flag of a command,
+// and are nested like its subcommands.
+public abstract class GoCommand {
+	private static final Charset UTF_8 = Charset.forName("UTF-8");
+
+	private final int root;
+	private final List<String> args = new ArrayList<String>();
+
+	protected GoCommand(int root, String... path) {
+		this.root = root;
+		Collections.addAll(args, path);
+	}
+
+	// flag adds --name=value to the command line.
+	protected final void flag(String name, String value) {
+		args.add("--" + name + "=" + value);
+	}
+
+	// addArgs adds positional arguments to the command line.
+	protected final void addArgs(String... a) {
+		Collections.addAll(args, a);
+	}
+
+	// args adds positional arguments to the command line.
+	public abstract GoCommand args(String... args);
+
+	// commandLine returns the arguments the tool is run with.
+	public final List<String> commandLine() {
+		return Collections.unmodifiableList(args);
+	}
+
+	// execute runs the command and returns its exit code and output. Only the
+	// output written to the writers of the command is captured, such as
+	// cobra's OutOrStdout, not writes to os.Stdout. A command calling os.Exit
+	// exits the JVM.
+	public final Result execute() {
+		Go.load();
+		ByteArrayOutputStream b = new ByteArrayOutputStream();
+		for (String a : args) {
+			byte[] d = a.getBytes(UTF_8);
+			b.write(d, 0, d.length);
+			b.write(0);
+		}
+		ByteBuffer r = ByteBuffer.wrap(run0(root, b.toByteArray()));
+		int exitCode = r.getInt();
+		byte[] stdout = new byte[r.getInt()];
+		r.get(stdout);
+		byte[] stderr = new byte[r.remaining()];
+		r.get(stderr);
+		return new Result(exitCode, new String(stdout, UTF_8), new String(stderr, UTF_8));
+	}
+
+	private static native byte[] run0(int root, byte[] args);
+
+	// Result is the exit code and output of a command.
+	public static final class Result {
+		private final int exitCode;
+		private final String stdout;
+		private final String stderr;
+
+		Result(int exitCode, String stdout, String stderr) {
+			this.exitCode = exitCode;
+			this.stdout = stdout;
+			this.stderr = stderr;
+		}
+
+		public int exitCode() {
+			return exitCode;
+		}
+
+		public String stdout() {
+			return stdout;
+		}
+
+		public String stderr() {
+			return stderr;
+		}
+	}
+}
+`
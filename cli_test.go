@@ -0,0 +1,103 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const cliSrc = `package testpkg
+
+import (
+	"flag"
+	"io"
+)
+
+func Command() (*flag.FlagSet, func(args []string, stdout io.Writer) error) { return nil, nil }
+
+func Wrong() *flag.FlagSet { return nil }
+`
+
+func TestFindCLIs(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "cli.go", cliSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	p, err := conf.Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*types.Package{p}
+	roots, err := findCLIs([]string{"example.com/testpkg.Command"}, pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0].fn != "Command" || roots[0].cobra {
+		t.Errorf("got %+v", roots)
+	}
+	for _, arg := range []string{"example.com/testpkg.Wrong", "example.com/testpkg.Missing", "example.com/other.Command"} {
+		if _, err := findCLIs([]string{arg}, pkgs); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+}
+
+func TestGenCLIClasses(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p := types.NewPackage("example.com/tool", "tool")
+	cmd := cliCommand{
+		Name:  "tool",
+		Short: "Tool does things.",
+		Flags: []cliFlag{{Name: "verbose", Type: "bool"}, {Name: "config-file", Type: "string", Usage: "Config */ file."}},
+		Commands: []cliCommand{{
+			Name:     "serve",
+			Flags:    []cliFlag{{Name: "port", Type: "int"}, {Name: "timeout", Type: "duration"}, {Name: "tag", Type: "stringSlice"}},
+			Commands: []cliCommand{{Name: "tool"}},
+		}},
+	}
+	files, err := genCLIClasses(tmpDir, []cliRoot{{p, "Root", true}}, []cliCommand{cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[1]) != "ToolCommand.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"package go.tool;",
+		"public class ToolCommand extends go.GoCommand {",
+		"\t/** Tool does things. */\n",
+		"\tpublic ToolCommand setVerbose(boolean v) {\n\t\tflag(\"verbose\", String.valueOf(v));",
+		"\t/** --config-file: Config *&#47; file. */\n\tpublic ToolCommand setConfigFile(String v) {",
+		"\tpublic static class Serve extends go.GoCommand {\n\t\tpublic Serve() {\n\t\t\tsuper(0, \"serve\");",
+		"\t\tpublic Serve setPort(long v) {",
+		"\t\tpublic Serve setTimeout(java.time.Duration v) {\n\t\t\tflag(\"timeout\", v.toNanos() + \"ns\");",
+		"\t\tpublic Serve setTag(String... v) {\n\t\t\tfor (String s : v) {\n\t\t\t\tflag(\"tag\", s);\n\t\t\t}\n",
+		"\t\tpublic static class Tool extends go.GoCommand {\n\t\t\tpublic Tool() {\n\t\t\t\tsuper(0, \"serve\", \"tool\");",
+		"\t\t\tpublic Tool args(String... args) {",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("missing %q:\n%s", s, d)
+		}
+	}
+
+	if _, err := genCLIClasses(tmpDir, []cliRoot{{p, "Root", true}, {p, "Other", true}}, []cliCommand{cmd, cmd}); err == nil {
+		t.Error("expected an error for duplicate classes")
+	}
+}
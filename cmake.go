@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cmakeBindDir is the directory of the bind package in the directory written
+// by writeCMake.
+const cmakeBindDir = "gojava_bind"
+
+// writeCMake writes the bind package in bindDir to dir, with a CMakeLists.txt
+// building its native library as gojava does, so the native build can be run
+// by another build system with its own C toolchain, compiler launcher such as
+// ccache or distcc, or cross compiler. The JNI include directories are found
+// by CMake rather than taken from the JDK gojava was run with.
+func writeCMake(dir string, cfg *config, bindDir string, mod *goModule) error {
+	goos, goarch, err := goTargetPlatform()
+	if err != nil {
+		return err
+	}
+	const lib = "@GOJAVA_LIB@"
+	args, err := goBuildArgs(cfg, lib, bindDir, goos, mod, "")
+	if err != nil {
+		return err
+	}
+	outDir := filepath.Join(dir, cmakeBindDir)
+	if err := os.RemoveAll(outDir); err != nil {
+		return err
+	}
+	verbosef("Writing the CMake project of the native library to %s\n", dir)
+	err = filepath.Walk(bindDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(bindDir, path)
+		if err != nil {
+			return err
+		}
+		// The include directories of the JDK are passed in CGO_CFLAGS.
+		if rel == "gojavacimport.go" {
+			return nil
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if rel == "go.work" {
+			d = bytes.Replace(d, []byte(bindDir), []byte(outDir), -1)
+		}
+		if err := os.MkdirAll(filepath.Join(outDir, filepath.Dir(rel)), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(outDir, rel), d, 0644)
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "CMakeLists.txt"), cmakeLists(args, lib, bindDir, goos, goarch, mod == nil), 0644)
+}
+
+// cmakeLists returns the CMakeLists.txt running the go command with args,
+// replacing lib with the library it builds and bindDir with the copy of the
+// bind package.
+func cmakeLists(args []string, lib, bindDir, goos, goarch string, gopath bool) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, cmakeListsStart, goos, goarch, sharedLibExt(goos))
+	b.WriteString("add_custom_command(\n\tOUTPUT ${GOJAVA_LIB}\n\tCOMMAND ${CMAKE_COMMAND} -E env\n")
+	b.WriteString("\t\t\"CC=${GOJAVA_CC}\" CGO_ENABLED=1 \"CGO_CFLAGS=${GOJAVA_CFLAGS}\" GOOS=${GOJAVA_GOOS} GOARCH=${GOJAVA_GOARCH}")
+	if gopath {
+		b.WriteString(" GO111MODULE=off")
+	}
+	b.WriteString("\n\t\t${GO_EXECUTABLE}")
+	for _, a := range args {
+		a = strings.Replace(a, lib, "${GOJAVA_LIB}", -1)
+		a = strings.Replace(a, bindDir, "${CMAKE_CURRENT_SOURCE_DIR}/"+cmakeBindDir, -1)
+		b.WriteString(" " + cmakeQuote(a))
+	}
+	b.WriteString(" .\n")
+	b.WriteString(cmakeListsEnd)
+	return b.Bytes()
+}
+
+// cmakeQuote returns s as a CMake argument.
+func cmakeQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\"\\;#()'") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `;`, `\;`)
+	return `"` + r.Replace(s) + `"`
+}
+
+const cmakeListsStart = `# Code generated by gojava. DO NOT EDIT.
+#
+# Builds the native library of the Java bindings with the go command, using
+# the C compiler of this project. Add it with add_subdirectory and link the
+# imported target gojava, or build it alone, e.g. with cmake -G Ninja.
+cmake_minimum_required(VERSION 3.18)
+project(gojava C)
+
+find_package(JNI REQUIRED)
+find_program(GO_EXECUTABLE go REQUIRED)
+
+set(GOJAVA_GOOS %s CACHE STRING "GOOS of the native library")
+set(GOJAVA_GOARCH %s CACHE STRING "GOARCH of the native library")
+set(GOJAVA_LIB ${CMAKE_CURRENT_BINARY_DIR}/libgojava%s)
+
+# cgo runs the C compiler, through the compiler launcher if one is set.
+string(STRIP "${CMAKE_C_COMPILER_LAUNCHER} ${CMAKE_C_COMPILER}" GOJAVA_CC)
+set(GOJAVA_CFLAGS "-Wall ${CMAKE_C_FLAGS}")
+foreach(dir ${JNI_INCLUDE_DIRS})
+	string(APPEND GOJAVA_CFLAGS " -I${dir}")
+endforeach()
+
+file(GLOB_RECURSE GOJAVA_SOURCES CONFIGURE_DEPENDS
+	${CMAKE_CURRENT_SOURCE_DIR}/gojava_bind/*.go
+	${CMAKE_CURRENT_SOURCE_DIR}/gojava_bind/*.c
+	${CMAKE_CURRENT_SOURCE_DIR}/gojava_bind/*.h
+)
+
+`
+
+const cmakeListsEnd = `	WORKING_DIRECTORY ${CMAKE_CURRENT_SOURCE_DIR}/gojava_bind/main
+	DEPENDS ${GOJAVA_SOURCES}
+	COMMENT "Building the Go native library"
+	VERBATIM
+)
+add_custom_target(gojava_build ALL DEPENDS ${GOJAVA_LIB})
+
+add_library(gojava SHARED IMPORTED GLOBAL)
+set_target_properties(gojava PROPERTIES IMPORTED_LOCATION ${GOJAVA_LIB})
+add_dependencies(gojava gojava_build)
+`
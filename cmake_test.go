@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCMakeLists(t *testing.T) {
+	args := []string{"build", "-o", "@LIB@", "-buildmode=c-shared", "-mod=mod", "-ldflags=-extldflags '-Wl,--version-script=/tmp/x/gojava_bind/gojava_exports.txt'"}
+	got := string(cmakeLists(args, "@LIB@", "/tmp/x/gojava_bind", "linux", "arm64", false))
+	for _, s := range []string{
+		"set(GOJAVA_GOOS linux CACHE STRING",
+		"set(GOJAVA_GOARCH arm64 CACHE STRING",
+		"set(GOJAVA_LIB ${CMAKE_CURRENT_BINARY_DIR}/libgojava.so)\n",
+		"\t\t${GO_EXECUTABLE} build -o ${GOJAVA_LIB} -buildmode=c-shared -mod=mod \"-ldflags=-extldflags '-Wl,--version-script=${CMAKE_CURRENT_SOURCE_DIR}/gojava_bind/gojava_exports.txt'\" .\n",
+		"add_library(gojava SHARED IMPORTED GLOBAL)\n",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("CMakeLists.txt missing %q:\n%s", s, got)
+		}
+	}
+	if strings.Contains(got, "GO111MODULE") {
+		t.Errorf("module build sets GO111MODULE:\n%s", got)
+	}
+	got = string(cmakeLists(args[:4], "@LIB@", "/tmp/x/gojava_bind", "darwin", "amd64", true))
+	for _, s := range []string{"GO111MODULE=off", "/libgojava.dylib)"} {
+		if !strings.Contains(got, s) {
+			t.Errorf("GOPATH darwin CMakeLists.txt missing %q:\n%s", s, got)
+		}
+	}
+}
+
+func TestCMakeQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"-mod=mod":       "-mod=mod",
+		"":               `""`,
+		"a b":            `"a b"`,
+		`a;b"c\d`:        `"a\;b\"c\\d"`,
+		"${GOJAVA_LIB}":  "${GOJAVA_LIB}",
+		"-flag='quoted'": `"-flag='quoted'"`,
+	} {
+		if got := cmakeQuote(in); got != want {
+			t.Errorf("cmakeQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
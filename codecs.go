@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// codec is a package function constructing a compress-style streaming
+// encoder from an io.Writer, or decoder from an io.Reader.
+type codec struct {
+	pkg *types.Package
+	fn  *types.Func
+	// decoder is set for constructors taking an io.Reader.
+	decoder bool
+	// err is set if the constructor also returns an error.
+	err bool
+}
+
+// class returns the name of the Java class for c, such as GzipEncoder for
+// gzip.NewWriter and GzipLevelEncoder for gzip.NewWriterLevel.
+func (c codec) class() string {
+	rest := strings.TrimPrefix(c.fn.Name(), "New")
+	kind := "Encoder"
+	if c.decoder {
+		rest = strings.Replace(rest, "Reader", "", 1)
+		kind = "Decoder"
+	} else {
+		rest = strings.Replace(rest, "Writer", "", 1)
+	}
+	return strings.Title(c.pkg.Name()) + rest + kind
+}
+
+// findCodecs returns the exported functions of pkgs named New* whose first
+// parameter is an io.Writer or io.Reader, whose other parameters are integers
+// or booleans, and which return an io.Writer or io.Reader of the same kind
+// and optionally an error.
+func findCodecs(pkgs []*types.Package, exposed map[*types.Package][]string) []codec {
+	var codecs []codec
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok || !fn.Exported() || !strings.HasPrefix(name, "New") {
+				continue
+			}
+			if exposed != nil && !containsString(exposed[p], name) {
+				continue
+			}
+			sig := fn.Type().(*types.Signature)
+			params, res := sig.Params(), sig.Results()
+			if params.Len() == 0 || res.Len() == 0 || res.Len() > 2 || sig.Variadic() {
+				continue
+			}
+			c := codec{pkg: p, fn: fn, err: res.Len() == 2}
+			switch params.At(0).Type().String() {
+			case "io.Writer":
+			case "io.Reader":
+				c.decoder = true
+			default:
+				continue
+			}
+			iface := params.At(0).Type().Underlying().(*types.Interface)
+			if !types.Implements(res.At(0).Type(), iface) || (c.err && !isError(res.At(1).Type())) {
+				continue
+			}
+			supported := true
+			for _, v := range tupleVars(params)[1:] {
+				// The glue only imports the package of the constructor.
+				n, named := v.Type().(*types.Named)
+				supported = supported && codecArgType(v.Type()) != "" && (!named || n.Obj().Pkg() == nil || n.Obj().Pkg() == p)
+			}
+			if supported {
+				codecs = append(codecs, c)
+			}
+		}
+	}
+	return codecs
+}
+
+// codecArgType returns the Java type of a constructor argument of type t, or
+// "" if it is not an integer or boolean.
+func codecArgType(t types.Type) string {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok || b.Info()&(types.IsInteger|types.IsBoolean) == 0 {
+		return ""
+	}
+	jt, err := javaType(t)
+	if err != nil {
+		return ""
+	}
+	return jt
+}
+
+// genCodecs writes the Go and C code of the codecs of pkgs to bindDir, and
+// go.GoCodec and a subclass of it for each codec to javaDir. It returns the
+// paths of the Java files, or nil if pkgs have no codecs.
+func genCodecs(bindDir, javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string) ([]string, error) {
+	codecs := findCodecs(pkgs, exposed)
+	if len(codecs) == 0 {
+		return nil, nil
+	}
+	var imports, openers bytes.Buffer
+	seen := make(map[*types.Package]int)
+	for _, c := range codecs {
+		i, ok := seen[c.pkg]
+		if !ok {
+			i = len(seen)
+			seen[c.pkg] = i
+			fmt.Fprintf(&imports, "\tcodec%d %q\n", i, c.pkg.Path())
+		}
+		args := []string{"w"}
+		kind, newCodec := "Writer", "gojavaNewEncoder"
+		if c.decoder {
+			args[0] = "r"
+			kind, newCodec = "Reader", "gojavaNewDecoder"
+		}
+		for j, v := range tupleVars(c.fn.Type().(*types.Signature).Params())[1:] {
+			qualifier := func(*types.Package) string {
+				return fmt.Sprintf("codec%d", i)
+			}
+			if codecArgType(v.Type()) == "boolean" {
+				args = append(args, fmt.Sprintf("%s(args[%d] != 0)", types.TypeString(v.Type(), qualifier), j))
+			} else {
+				args = append(args, fmt.Sprintf("%s(args[%d])", types.TypeString(v.Type(), qualifier), j))
+			}
+		}
+		call := fmt.Sprintf("codec%d.%s(%s)", i, c.fn.Name(), strings.Join(args, ", "))
+		if !c.err {
+			call += ", nil"
+		}
+		fmt.Fprintf(&openers, "\tfunc(args []int64) gojavaCodec {\n\t\treturn %s(func(%s io.%s) (io.%s, error) {\n\t\t\treturn %s\n\t\t})\n\t},\n", newCodec, args[0], kind, kind, call)
+	}
+	goSrc := fmt.Sprintf(codecsGo, imports.String(), openers.String())
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_codecs.go"), []byte(goSrc), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_codecs.c"), []byte(codecsC), 0600); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(javaDir, "GoCodec.java")
+	if err := writeJavaFile(path, []byte(goCodecJava)); err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	for i, c := range codecs {
+		class := c.class()
+		path := filepath.Join(javaDir, c.pkg.Name(), class+".java")
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s.%s: class %s.%s already exists", c.pkg.Path(), c.fn.Name(), javaPkgName(c.pkg), class)
+		}
+		verbosef("Generating codec %s.%s\n", javaPkgName(c.pkg), class)
+		var params, args []string
+		for _, v := range tupleVars(c.fn.Type().(*types.Signature).Params())[1:] {
+			jt, name := codecArgType(v.Type()), javaIdent(v.Name())
+			if name == "" || name == "_" {
+				name = fmt.Sprintf("arg%d", len(params))
+			}
+			params = append(params, jt+" "+name)
+			if jt == "boolean" {
+				args = append(args, name+" ? 1L : 0L")
+			} else {
+				args = append(args, name)
+			}
+		}
+		kind := "encodes"
+		if c.decoder {
+			kind = "decodes"
+		}
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// Java codec for %s.%s generated by gojava.\n", c.pkg.Path(), c.fn.Name())
+		fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(c.pkg))
+		fmt.Fprintf(&b, "// %s %s streams with %s.%s.\n", class, kind, c.pkg.Name(), c.fn.Name())
+		fmt.Fprintf(&b, "public final class %s extends go.GoCodec {\n", class)
+		fmt.Fprintf(&b, "\tpublic %s(%s) throws java.io.IOException {\n", class, strings.Join(params, ", "))
+		fmt.Fprintf(&b, "\t\tsuper(%s);\n\t}\n}\n", strings.Join(append([]string{fmt.Sprint(i), fmt.Sprintf("%q", c.pkg.Path()+"."+c.fn.Name())}, args...), ", "))
+		if err := writeJavaFile(path, b.Bytes()); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+const codecsGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"unsafe"
+
+%s)
+
+// gojavaCodecOpeners create the codecs of the subclasses of go.GoCodec.
+var gojavaCodecOpeners = []func(args []int64) gojavaCodec{
+%s}
+
+// gojavaCodec is an open encoder or decoder. process passes it input, and
+// returns the output produced so far. op is 1 to flush the codec, and 2 to end
+// the input.
+type gojavaCodec interface {
+	process(in []byte, op int) ([]byte, error)
+	close()
+}
+
+// gojavaEncoder writes the input to an io.Writer writing to out.
+type gojavaEncoder struct {
+	out bytes.Buffer
+	w   io.Writer
+	err error
+}
+
+func gojavaNewEncoder(open func(w io.Writer) (io.Writer, error)) gojavaCodec {
+	e := &gojavaEncoder{}
+	e.w, e.err = open(&e.out)
+	return e
+}
+
+func (e *gojavaEncoder) process(in []byte, op int) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if len(in) > 0 {
+		_, e.err = e.w.Write(in)
+	}
+	if f, ok := e.w.(interface{ Flush() error }); ok && op == 1 && e.err == nil {
+		e.err = f.Flush()
+	}
+	if c, ok := e.w.(io.Closer); ok && op == 2 && e.err == nil {
+		e.err = c.Close()
+	}
+	out := append([]byte(nil), e.out.Bytes()...)
+	e.out.Reset()
+	return out, e.err
+}
+
+func (e *gojavaEncoder) close() {}
+
+// gojavaDecoder reads an io.Reader reading the input on a goroutine. Its Read
+// method blocks until more input is passed, or the input ends.
+type gojavaDecoder struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	in   []byte
+	out  bytes.Buffer
+	// ended is set when the input ends, waiting when the goroutine is blocked
+	// reading more input, and done when it has returned.
+	ended, waiting, done bool
+	err                  error
+}
+
+func gojavaNewDecoder(open func(r io.Reader) (io.Reader, error)) gojavaCodec {
+	d := &gojavaDecoder{}
+	d.cond = sync.NewCond(&d.mu)
+	go d.run(open)
+	return d
+}
+
+func (d *gojavaDecoder) run(open func(r io.Reader) (io.Reader, error)) {
+	var err error
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %%v", p)
+		}
+		d.mu.Lock()
+		if err != io.EOF {
+			d.err = err
+		}
+		d.done = true
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}()
+	r, err := open(d)
+	if err != nil {
+		return
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	buf := make([]byte, 32*1024)
+	for err == nil {
+		var n int
+		n, err = r.Read(buf)
+		d.mu.Lock()
+		d.out.Write(buf[:n])
+		d.mu.Unlock()
+	}
+}
+
+func (d *gojavaDecoder) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.in) == 0 && !d.ended {
+		d.waiting = true
+		d.cond.Broadcast()
+		d.cond.Wait()
+	}
+	d.waiting = false
+	if len(d.in) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, d.in)
+	d.in = d.in[n:]
+	return n, nil
+}
+
+// process waits until the goroutine has decoded all the input, or returned
+// after the input ends.
+func (d *gojavaDecoder) process(in []byte, op int) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.in = append(d.in, in...)
+	if op == 2 {
+		d.ended = true
+	}
+	d.cond.Broadcast()
+	for !d.done && (d.ended || !d.waiting || len(d.in) > 0) {
+		d.cond.Wait()
+	}
+	out := append([]byte(nil), d.out.Bytes()...)
+	d.out.Reset()
+	if d.err == nil && op == 2 && len(d.in) > 0 {
+		return out, fmt.Errorf("%%d bytes after the end of the stream", len(d.in))
+	}
+	return out, d.err
+}
+
+// close ends the input, so the goroutine returns.
+func (d *gojavaDecoder) close() {
+	d.mu.Lock()
+	d.ended = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+}
+
+var (
+	gojavaCodecsMu sync.Mutex
+	gojavaCodecs   = make(map[int64]gojavaCodec)
+	gojavaCodecID  int64
+)
+
+// gojava_codec_open opens the codec-th codec with the n arguments args.
+//
+//export gojava_codec_open
+func gojava_codec_open(codec C.int, args *C.longlong, n C.int) C.longlong {
+	var a []int64
+	for _, v := range unsafe.Slice(args, int(n)) {
+		a = append(a, int64(v))
+	}
+	c := gojavaCodecOpeners[codec](a)
+	gojavaCodecsMu.Lock()
+	defer gojavaCodecsMu.Unlock()
+	gojavaCodecID++
+	gojavaCodecs[gojavaCodecID] = c
+	return C.longlong(gojavaCodecID)
+}
+
+// gojava_codec_process passes the n bytes at in to the codec id, and returns
+// its output, setting size to its length, and err to its error.
+//
+//export gojava_codec_process
+func gojava_codec_process(id C.longlong, op C.int, in unsafe.Pointer, n C.int, size *C.int, err **C.char) *C.char {
+	gojavaCodecsMu.Lock()
+	c := gojavaCodecs[int64(id)]
+	gojavaCodecsMu.Unlock()
+	out, e := func() (out []byte, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %%v", p)
+			}
+		}()
+		return c.process(C.GoBytes(in, n), int(op))
+	}()
+	if e != nil {
+		*err = C.CString(e.Error())
+	}
+	*size = C.int(len(out))
+	return (*C.char)(C.CBytes(out))
+}
+
+//export gojava_codec_close
+func gojava_codec_close(id C.longlong) {
+	gojavaCodecsMu.Lock()
+	c := gojavaCodecs[int64(id)]
+	delete(gojavaCodecs, int64(id))
+	gojavaCodecsMu.Unlock()
+	if c != nil {
+		c.close()
+	}
+}
+`
+
+const codecsC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jlong JNICALL Java_go_GoCodec_open0(JNIEnv *env, jclass clazz, jint codec, jlongArray args) {
+	jsize n = (*env)->GetArrayLength(env, args);
+	jlong *a = (*env)->GetLongArrayElements(env, args, NULL);
+	jlong id = gojava_codec_open(codec, (long long *)a, n);
+	(*env)->ReleaseLongArrayElements(env, args, a, JNI_ABORT);
+	return id;
+}
+
+JNIEXPORT jbyteArray JNICALL Java_go_GoCodec_process0(JNIEnv *env, jclass clazz, jlong id, jint op, jbyteArray in, jint off, jint len) {
+	jbyte *p = (*env)->GetByteArrayElements(env, in, NULL);
+	int size = 0;
+	char *err = NULL;
+	char *out = gojava_codec_process(id, op, p + off, len, &size, &err);
+	(*env)->ReleaseByteArrayElements(env, in, p, JNI_ABORT);
+	jbyteArray res = NULL;
+	if (err != NULL) {
+		jclass c = (*env)->FindClass(env, "java/io/IOException");
+		if (c != NULL) {
+			(*env)->ThrowNew(env, c, err);
+		}
+		free(err);
+	} else {
+		res = (*env)->NewByteArray(env, size);
+		if (res != NULL) {
+			(*env)->SetByteArrayRegion(env, res, 0, size, (jbyte *)out);
+		}
+	}
+	free(out);
+	return res;
+}
+
+JNIEXPORT void JNICALL Java_go_GoCodec_close0(JNIEnv *env, jclass clazz, jlong id) {
+	gojava_codec_close(id);
+}
+`
+
+const goCodecJava = `package go;
+
+import java.io.FilterOutputStream;
+import java.io.IOException;
+import java.io.OutputStream;
+
+// GoCodec is a streaming encoder or decoder made by a Go compress-style
+// constructor, such as gzip.NewWriter or gzip.NewReader. Like
+// java.util.zip.Deflater and Inflater, input is passed to update in chunks of
+// any size, and each call returns the output produced so far, so only the
+// state of the codec is held in memory. finish ends the input and returns the
+// rest of the output.
+public abstract class GoCodec implements AutoCloseable {
+	private static final byte[] EMPTY = new byte[0];
+
+	private final String name;
+	private long id;
+	private boolean finished;
+
+	protected GoCodec(int codec, String name, long... args) throws IOException {
+		this.name = name;
+		Go.load();
+		id = open0(codec, args);
+		try {
+			// Encoders report constructor errors, such as an invalid level, now.
+			process(0, EMPTY, 0, 0);
+		} catch (IOException ex) {
+			close();
+			throw ex;
+		}
+	}
+
+	// name returns the Go constructor of the codec.
+	public final String name() {
+		return name;
+	}
+
+	public final byte[] update(byte[] in) throws IOException {
+		return update(in, 0, in.length);
+	}
+
+	// update passes len bytes of in from off to the codec, and returns the
+	// output produced so far.
+	public final synchronized byte[] update(byte[] in, int off, int len) throws IOException {
+		if (off < 0 || len < 0 || off > in.length - len) {
+			throw new IndexOutOfBoundsException();
+		}
+		return process(0, in, off, len);
+	}
+
+	// flush returns the output produced so far, after calling the Flush method
+	// of encoders that have one, such as gzip.Writer, so the output decodes to
+	// all the input so far.
+	public final synchronized byte[] flush() throws IOException {
+		return process(1, EMPTY, 0, 0);
+	}
+
+	// finish ends the input, closing the Go encoder or decoder, and returns the
+	// rest of the output.
+	public final synchronized byte[] finish() throws IOException {
+		byte[] out = process(2, EMPTY, 0, 0);
+		finished = true;
+		return out;
+	}
+
+	public final synchronized boolean finished() {
+		return finished;
+	}
+
+	// close releases the codec. It does not finish it.
+	@Override
+	public final synchronized void close() {
+		if (id != 0) {
+			close0(id);
+			id = 0;
+		}
+	}
+
+	// wrap returns a stream writing the output of the codec for the bytes
+	// written to it to out. Closing the stream finishes and closes the codec,
+	// and closes out.
+	public final OutputStream wrap(final OutputStream out) {
+		return new FilterOutputStream(out) {
+			@Override
+			public void write(int b) throws IOException {
+				write(new byte[] {(byte) b}, 0, 1);
+			}
+
+			@Override
+			public void write(byte[] b, int off, int len) throws IOException {
+				out.write(update(b, off, len));
+			}
+
+			@Override
+			public void flush() throws IOException {
+				out.write(GoCodec.this.flush());
+				out.flush();
+			}
+
+			@Override
+			public void close() throws IOException {
+				try {
+					if (!finished()) {
+						out.write(finish());
+					}
+				} finally {
+					GoCodec.this.close();
+					out.close();
+				}
+			}
+		};
+	}
+
+	@Override
+	public String toString() {
+		return "GoCodec[" + name + "]";
+	}
+
+	private byte[] process(int op, byte[] in, int off, int len) throws IOException {
+		if (id == 0) {
+			throw new IllegalStateException(name + ": codec closed");
+		}
+		if (finished) {
+			throw new IllegalStateException(name + ": codec finished");
+		}
+		return process0(id, op, in, off, len);
+	}
+
+	private static native long open0(int codec, long[] args);
+	private static native byte[] process0(long id, int op, byte[] in, int off, int len) throws IOException;
+	private static native void close0(long id);
+}
+`
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindCodecs(t *testing.T) {
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	var pkgs []*types.Package
+	for _, path := range []string{"compress/gzip", "compress/lzw", "encoding/csv"} {
+		p, err := imp.Import(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	var got []string
+	for _, c := range findCodecs(pkgs, nil) {
+		got = append(got, c.pkg.Name()+"."+c.fn.Name()+"="+c.class())
+	}
+	want := "gzip.NewReader=GzipDecoder,gzip.NewWriter=GzipEncoder,gzip.NewWriterLevel=GzipLevelEncoder,lzw.NewReader=LzwDecoder,lzw.NewWriter=LzwEncoder"
+	if strings.Join(got, ",") != want {
+		t.Errorf("got codecs %s, want %s", strings.Join(got, ","), want)
+	}
+	if c := findCodecs(pkgs, map[*types.Package][]string{pkgs[0]: {"NewReader"}}); len(c) != 1 || c[0].class() != "GzipDecoder" {
+		t.Errorf("exposed codecs: got %+v", c)
+	}
+}
+
+func TestGenCodecs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p, err := importer.ForCompiler(token.NewFileSet(), "source", nil).Import("compress/lzw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := genCodecs(tmpDir, tmpDir, []*types.Package{p}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || filepath.Base(files[0]) != "GoCodec.java" || filepath.Base(files[1]) != "LzwDecoder.java" {
+		t.Fatalf("got files %v", files)
+	}
+	for _, c := range []struct {
+		file string
+		want []string
+	}{
+		{"gojava_codecs.go", []string{
+			"\tcodec0 \"compress/lzw\"\n",
+			"\t\treturn gojavaNewDecoder(func(r io.Reader) (io.Reader, error) {\n\t\t\treturn codec0.NewReader(r, codec0.Order(args[0]), int(args[1])), nil\n",
+			"//export gojava_codec_process\n",
+		}},
+		{filepath.Join("lzw", "LzwDecoder.java"), []string{
+			"package go.lzw;",
+			"public final class LzwDecoder extends go.GoCodec {",
+			"\tpublic LzwDecoder(long order, long litWidth) throws java.io.IOException {\n\t\tsuper(0, \"compress/lzw.NewReader\", order, litWidth);\n",
+		}},
+	} {
+		d, err := ioutil.ReadFile(filepath.Join(tmpDir, c.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range c.want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", c.file, s, d)
+			}
+		}
+	}
+	if _, err := genCodecs(tmpDir, tmpDir, []*types.Package{p}, nil); err == nil {
+		t.Error("expected an error for an existing class")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The values of -s-collisions, selecting what happens to a Java source
+// given with -s at the path of a generated one.
+const (
+	// collisionError fails the build.
+	collisionError = "error"
+	// collisionOverride replaces the generated source, with a warning.
+	collisionOverride = "override"
+	// collisionMerge adds the imports and members of the -s source to the
+	// generated class.
+	collisionMerge = "merge"
+)
+
+// checkCollisions returns an error if mode is not a valid -s-collisions value.
+func checkCollisions(mode string) error {
+	switch mode {
+	case collisionError, collisionOverride, collisionMerge:
+		return nil
+	}
+	return fmt.Errorf("invalid -s-collisions %q, must be error, override or merge", mode)
+}
+
+// addExtraFiles copies the Java sources in sourceDir to javaDir, once all the
+// generated sources are written there, returning their paths in javaDir. A
+// source at the path of a generated one is handled as selected by
+// collisions.
+func addExtraFiles(javaDir, sourceDir, collisions string) ([]string, error) {
+	if sourceDir == "" {
+		return nil, nil
+	}
+	var extraFiles []string
+	found := false
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() {
+			return nil
+		}
+		fileName, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(fileName, ".java") {
+			found = found || jvmLanguageOf(fileName) != nil
+			return nil
+		}
+		found = true
+		if err := checkSourcePackage(path, fileName); err != nil {
+			return err
+		}
+		p := filepath.Join(javaDir, fileName)
+		extraFiles = append(extraFiles, p)
+		generated, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+				return err
+			}
+			return copyFile(p, path)
+		}
+		if err != nil {
+			return err
+		}
+		switch collisions {
+		case collisionOverride:
+			fmt.Fprintf(os.Stderr, "warning: %s replaces the generated %s\n", path, fileName)
+			return copyFile(p, path)
+		case collisionMerge:
+			extra, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			merged, err := mergeJava(generated, extra)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			return writeJavaFile(p, merged)
+		}
+		return fmt.Errorf("%s has the same path as the generated %s, use -s-collisions override or merge", path, fileName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		verbosef("warning: argument -s was passed on command line, but no .java, .kt or .scala files were found in '%s'\n", sourceDir)
+	}
+	return extraFiles, nil
+}
+
+// javaPackageDecl matches the package declaration of a Java source, or that
+// of a Java, Kotlin or Scala source, where the semicolon is optional.
+var javaPackageDecl = regexp.MustCompile(`(?m)^[ \t]*package[ \t]+([\p{L}\p{N}_$.]+)[ \t]*(?:;|$)`)
+
+// sourcePackage returns the Java package of the -s source at fileName,
+// relative to the -s directory: the go package, or its subpackage named after
+// the directories of fileName.
+func sourcePackage(fileName string) string {
+	dir := filepath.ToSlash(filepath.Dir(fileName))
+	if dir == "." {
+		return defaultJavaRoot
+	}
+	return defaultJavaRoot + "." + strings.Replace(dir, "/", ".", -1)
+}
+
+// checkSourcePackage returns an error if the -s source at path does not
+// declare the package of its path fileName in the -s directory. The sources
+// are copied into the source path of the generated ones, where javac looks up
+// classes by package, and their classes are matched to their paths to detect
+// collisions and to rebuild them.
+func checkSourcePackage(path, fileName string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	want := sourcePackage(fileName)
+	m := javaPackageDecl.FindSubmatch(src)
+	if m == nil {
+		return fmt.Errorf("%s: no package declaration, want package %s for its path in -s", path, want)
+	}
+	if got := string(m[1]); got != want {
+		return fmt.Errorf("%s: declares package %s, want package %s for its path in -s", path, got, want)
+	}
+	return nil
+}
+
+// javaSources returns the paths of the Java sources in sourceDir.
+func javaSources(sourceDir string) ([]string, error) {
+	if sourceDir == "" {
+		return nil, nil
+	}
+	var files []string
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".java" {
+			return err
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+var (
+	// javaPackageClause matches the package clause of a Java source, and
+	// javaImport an import declaration.
+	javaPackageClause = regexp.MustCompile(`(?m)^package [\w.]+;[ \t]*\n`)
+	javaImport        = regexp.MustCompile(`(?m)^import (?:static )?[\w.*]+;[ \t]*\n`)
+	// javaTopClass matches the declaration of a top level class, up to the
+	// opening brace of its body.
+	javaTopClass = regexp.MustCompile(`(?m)^(?:(?:public|final|abstract) )*(?:class|interface|enum) ([\p{L}\p{N}_$]+)[^{]*\{`)
+)
+
+// mergeJava returns the generated source with the imports of extra it lacks
+// and the members of the class declared by extra, which must have the name
+// of the generated class, added to its class.
+func mergeJava(generated, extra []byte) ([]byte, error) {
+	gm, em := javaTopClass.FindSubmatchIndex(generated), javaTopClass.FindSubmatchIndex(extra)
+	if gm == nil || em == nil {
+		return nil, fmt.Errorf("no top level class to merge")
+	}
+	if name := string(extra[em[2]:em[3]]); name != string(generated[gm[2]:gm[3]]) {
+		return nil, fmt.Errorf("declares %s, not the generated %s", name, generated[gm[2]:gm[3]])
+	}
+	genEnd, extraEnd := bytes.LastIndexByte(generated, '}'), bytes.LastIndexByte(extra, '}')
+	if genEnd < gm[1] || extraEnd < em[1] {
+		return nil, fmt.Errorf("unterminated class body")
+	}
+	var imports []byte
+	for _, imp := range javaImport.FindAll(extra, -1) {
+		if !bytes.Contains(generated, imp) {
+			imports = append(imports, imp...)
+		}
+	}
+	at := 0
+	if loc := javaPackageClause.FindIndex(generated); loc != nil {
+		at = loc[1]
+	}
+	var b bytes.Buffer
+	b.Write(generated[:at])
+	b.Write(imports)
+	b.Write(generated[at:genEnd])
+	fmt.Fprintf(&b, "\n\t// Merged from the -s sources.\n%s\n", bytes.Trim(extra[em[1]:extraEnd], "\n"))
+	b.Write(generated[genEnd:])
+	return b.Bytes(), nil
+}
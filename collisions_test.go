@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const generatedJava = `// Code generated by gobind. DO NOT EDIT.
+
+package go.testpkg;
+
+import go.Seq;
+
+public abstract class Testpkg {
+	public static native long add(long a, long b);
+}
+`
+
+const extraJava = `package go.testpkg;
+
+import go.Seq;
+import java.util.List;
+
+public final class Testpkg {
+	public static long sum(List<Long> vs) {
+		long s = 0;
+		for (long v : vs) {
+			s = add(s, v);
+		}
+		return s;
+	}
+}
+`
+
+func TestMergeJava(t *testing.T) {
+	merged, err := mergeJava([]byte(generatedJava), []byte(extraJava))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by gobind. DO NOT EDIT.
+
+package go.testpkg;
+import java.util.List;
+
+import go.Seq;
+
+public abstract class Testpkg {
+	public static native long add(long a, long b);
+
+	// Merged from the -s sources.
+	public static long sum(List<Long> vs) {
+		long s = 0;
+		for (long v : vs) {
+			s = add(s, v);
+		}
+		return s;
+	}
+}
+`
+	if string(merged) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", merged, want)
+	}
+	if _, err := mergeJava([]byte(generatedJava), []byte("package go.testpkg;\n\nclass Other {\n}\n")); err == nil {
+		t.Error("expected error merging a different class")
+	}
+}
+
+func TestAddExtraFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javaDir, sourceDir := filepath.Join(tmpDir, "java"), filepath.Join(tmpDir, "s")
+	generated := filepath.Join(javaDir, "testpkg", "Testpkg.java")
+	for path, src := range map[string]string{
+		filepath.Join(sourceDir, "testpkg", "Testpkg.java"): extraJava,
+		filepath.Join(sourceDir, "extra", "Extra.java"):     "package go.extra;\n",
+	} {
+		if err := writeJavaFile(path, []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		mode, want string
+	}{
+		{collisionError, ""},
+		{collisionOverride, "public final class Testpkg"},
+		{collisionMerge, "// Merged from the -s sources."},
+	} {
+		if err := os.RemoveAll(javaDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeJavaFile(generated, []byte(generatedJava)); err != nil {
+			t.Fatal(err)
+		}
+		files, err := addExtraFiles(javaDir, sourceDir, test.mode)
+		if test.mode == collisionError {
+			if err == nil || !strings.Contains(err.Error(), "-s-collisions") {
+				t.Errorf("%s: got %v, want a collision error", test.mode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.mode, err)
+			continue
+		}
+		if len(files) != 2 {
+			t.Errorf("%s: got %v, want 2 files", test.mode, files)
+		}
+		src, err := ioutil.ReadFile(generated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), test.want) {
+			t.Errorf("%s: got:\n%s\nwant it to contain %q", test.mode, src, test.want)
+		}
+		if _, err := os.Stat(filepath.Join(javaDir, "extra", "Extra.java")); err != nil {
+			t.Errorf("%s: %v", test.mode, err)
+		}
+	}
+}
+
+func TestCheckSourcePackage(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tests := []struct {
+		fileName, src string
+		ok            bool
+	}{
+		{"Util.java", "package go;\n", true},
+		{"foo/bar/Util.java", "/* package go; */\npackage go.foo.bar;\n", true},
+		{"foo/bar/Util.java", "package  go.foo.bar ;\n", true},
+		{"foo/bar/Util.kt", "package go.foo.bar\n\nfun f() = 1\n", true},
+		{"foo/Util.java", "package go.foo.bar;\n", false},
+		{"foo/Util.java", "package com.example.foo;\n", false},
+		{"foo/Util.java", "class Util {}\n", false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(tmpDir, "Util.java")
+		if err := ioutil.WriteFile(path, []byte(test.src), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkSourcePackage(path, filepath.FromSlash(test.fileName)); (err == nil) != test.ok {
+			t.Errorf("%s %q: got %v, want ok %v", test.fileName, test.src, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+)
+
+// methodSig returns the signature of the exported method name of *n, or nil.
+func methodSig(n *types.Named, name string) *types.Signature {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(n), false, n.Obj().Pkg(), name)
+	fn, ok := obj.(*types.Func)
+	if !ok || !fn.Exported() {
+		return nil
+	}
+	return fn.Type().(*types.Signature)
+}
+
+// isBasic reports whether t is a basic type with the given info.
+func isBasic(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+// compareMethod returns the Java body of compareTo for *n, if it has a method
+// Compare(*T) int or Less(*T) bool, or "" if it has neither.
+func compareMethod(n *types.Named) string {
+	self := types.NewPointer(n)
+	if sig := methodSig(n, "Compare"); sig != nil && sig.Params().Len() == 1 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Params().At(0).Type(), self) && isBasic(sig.Results().At(0).Type(), types.IsInteger) {
+		return "return Long.signum(compare(o));"
+	}
+	if sig := methodSig(n, "Less"); sig != nil && sig.Params().Len() == 1 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Params().At(0).Type(), self) && isBasic(sig.Results().At(0).Type(), types.IsBoolean) {
+		return "return less(o) ? -1 : o.less(this) ? 1 : 0;"
+	}
+	return ""
+}
+
+// isSortInterface reports whether *n implements sort.Interface.
+func isSortInterface(n *types.Named) bool {
+	length, less, swap := methodSig(n, "Len"), methodSig(n, "Less"), methodSig(n, "Swap")
+	return length != nil && length.Params().Len() == 0 && length.Results().Len() == 1 && isBasic(length.Results().At(0).Type(), types.IsInteger) &&
+		less != nil && less.Params().Len() == 2 && less.Results().Len() == 1 && isBasic(less.Results().At(0).Type(), types.IsBoolean) &&
+		swap != nil && swap.Params().Len() == 2 && swap.Results().Len() == 0
+}
+
+// implement returns src with iface added to the interfaces implemented by
+// the class declared at src[decl:brace], where src[brace] opens its body.
+func implement(src []byte, decl, brace int, iface string) []byte {
+	header := bytes.TrimRight(src[decl:brace], " \t\n")
+	sep := " implements "
+	if bytes.Contains(header, []byte(" implements ")) {
+		sep = ", "
+	}
+	out := make([]byte, 0, len(src)+len(sep)+len(iface)+1)
+	out = append(append(out, src[:decl]...), header...)
+	out = append(append(out, sep+iface+" "...), src[brace:]...)
+	return out
+}
+
+const sortMethod = `
+		// sort sorts the elements with heapsort, using len, less and swap.
+		public void sort() {
+			long n = len();
+			for (long i = n / 2 - 1; i >= 0; i--) {
+				siftDown(i, n);
+			}
+			for (long i = n - 1; i > 0; i--) {
+				swap(0, i);
+				siftDown(0, i);
+			}
+		}
+
+		private void siftDown(long root, long n) {
+			for (long child = 2 * root + 1; child < n; root = child, child = 2 * root + 1) {
+				if (child + 1 < n && less(child, child + 1)) {
+					child++;
+				}
+				if (!less(root, child)) {
+					return;
+				}
+				swap(root, child);
+			}
+		}
+`
+
+// addComparables rewrites the generated Java file for p at path. Classes of
+// struct types with a Compare(*T) int or Less(*T) bool method implement
+// Comparable and get a static comparator method, and classes of types
+// implementing sort.Interface get a sort method.
+func addComparables(path string, p *types.Package) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	changed := false
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok {
+			continue
+		}
+		var members string
+		if body := compareMethod(n); body != "" {
+			decl, start, end, err := classBody(src, p, name)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			// A compareTo or comparator bound from Go or given in -s is
+			// kept. The generated comparator calls the generated compareTo,
+			// so it is only added with it.
+			if len(memberDecls(src, start, end, "compareTo")) > 0 {
+				continue
+			}
+			comparator := len(memberDecls(src, start, end, "comparator")) == 0
+			src = implement(src, decl, start-1, "Comparable<"+name+">")
+			members += fmt.Sprintf("\n\t\t@Override\n\t\tpublic int compareTo(%s o) {\n\t\t\t%s\n\t\t}\n", name, body)
+			if comparator {
+				members += fmt.Sprintf("\n\t\tpublic static java.util.Comparator<%s> comparator() {\n", name)
+				members += fmt.Sprintf("\t\t\treturn new java.util.Comparator<%s>() {\n", name)
+				members += fmt.Sprintf("\t\t\t\t@Override\n\t\t\t\tpublic int compare(%s a, %s b) {\n\t\t\t\t\treturn a.compareTo(b);\n\t\t\t\t}\n\t\t\t};\n\t\t}\n", name, name)
+			}
+		} else if isSortInterface(n) {
+			_, start, end, err := classBody(src, p, name)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			if len(memberDecls(src, start, end, "sort")) == 0 {
+				members += sortMethod
+			}
+		}
+		if members == "" {
+			continue
+		}
+		if src, err = insertIntoClass(src, name, members); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
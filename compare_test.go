@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const compareSrc = `package testpkg
+
+type Version struct{}
+
+func (v *Version) Compare(o *Version) int { return 0 }
+
+type Name struct{}
+
+func (n *Name) Less(o *Name) bool { return false }
+
+type Names struct{}
+
+func (n *Names) Len() int           { return 0 }
+func (n *Names) Less(i, j int) bool { return false }
+func (n *Names) Swap(i, j int)      {}
+
+type Plain struct{}
+
+func (p *Plain) Less(o *Version) bool { return false }
+
+type Ordered struct{}
+
+func (o *Ordered) Compare(x *Ordered) int   { return 0 }
+func (o *Ordered) CompareTo(x *Ordered) int { return 0 }
+
+type Ranked struct{}
+
+func (r *Ranked) Compare(x *Ranked) int { return 0 }
+func (r *Ranked) Comparator() string    { return "" }
+`
+
+const compareJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Version extends Seq.Proxy {
+        public native long compare(Version o);
+    }
+
+    public static final class Name extends Seq.Proxy implements Other {
+        public native boolean less(Name o);
+    }
+
+    public static final class Names extends Seq.Proxy {
+        public native long len();
+    }
+
+    public static final class Plain extends Seq.Proxy {
+    }
+
+    public static final class Ordered extends Seq.Proxy {
+        public native long compare(Ordered x);
+        public native long compareTo(Ordered x);
+    }
+
+    public static final class Ranked extends Seq.Proxy {
+        public native long compare(Ranked x);
+        public native String comparator();
+    }
+}
+`
+
+func TestAddComparables(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(compareJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addComparables(path, typeCheck(t, compareSrc)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"class Version extends Seq.Proxy implements Comparable<Version> {",
+		"public int compareTo(Version o) {\n\t\t\treturn Long.signum(compare(o));",
+		"public static java.util.Comparator<Version> comparator() {",
+		"class Name extends Seq.Proxy implements Other, Comparable<Name> {",
+		"return less(o) ? -1 : o.less(this) ? 1 : 0;",
+		"class Names extends Seq.Proxy {\n\t\t// sort sorts",
+		"class Plain extends Seq.Proxy {\n    }",
+		"class Ordered extends Seq.Proxy {\n",
+		"class Ranked extends Seq.Proxy implements Comparable<Ranked> {",
+		"public int compareTo(Ranked o) {",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q:\n%s", s, src)
+		}
+	}
+	for _, s := range []string{
+		"Comparable<Ordered>",
+		"public int compareTo(Ordered o)",
+		"Comparator<Ordered>",
+		"Comparator<Ranked>",
+	} {
+		if strings.Contains(src, s) {
+			t.Errorf("unexpected %q:\n%s", s, src)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// javaCompiler compiles Java source files.
+type javaCompiler interface {
+	// compile compiles files to class files in outDir, looking up any other
+	// referenced sources in sourcePath and classes in classPath, if set.
+	compile(outDir, sourcePath, classPath string, files []string) error
+}
+
+// newJavaCompiler returns the Java compiler selected by cfg.javacImpl.
+func newJavaCompiler(cfg *config) (javaCompiler, error) {
+	ap := annotationProcessing{path: cfg.processorPath, proc: cfg.proc}
+	flags := strings.Fields(cfg.javacFlags)
+	// The daemon compiles with the javax.tools API in a JVM it keeps running,
+	// unless a javac executable was given.
+	warm := cfg.cache != nil && (cfg.javacImpl == "tools" || (cfg.javacImpl != "ecj" && cfg.javac == ""))
+	var tool string
+	switch cfg.javacImpl {
+	case "", "javac":
+		tool = javaTool("javac", cfg.javac)
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, noJavaCompiler(tool)
+		}
+		if warm {
+			tool = javaTool("java", "")
+		}
+	case "ecj":
+		// -strict-java is rejected with ecj, which has its own lint options.
+		return &ecj{path: javaTool("ecj", cfg.javac), opts: cfg.javacOpts, flags: flags, ap: ap}, nil
+	case "tools":
+		tool = javaTool("java", "")
+	default:
+		return nil, fmt.Errorf("unsupported Java compiler: %s", cfg.javacImpl)
+	}
+	if cfg.strictJava {
+		strict, err := strictJavaFlags(tool)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, strict...)
+	}
+	if cfg.javacImpl == "tools" || warm {
+		return &toolsCompiler{java: tool, opts: cfg.javacOpts, flags: flags, ap: ap, server: cfg.cache.compiler(tool)}, nil
+	}
+	return &javac{path: tool, opts: cfg.javacOpts, flags: flags, ap: ap}, nil
+}
+
+// noJavaCompiler returns the error for a build without the Java compiler
+// tool. Builds without a JDK use the Eclipse compiler, which runs on a JRE,
+// or -backend classfile for packages of functions and constants.
+func noJavaCompiler(tool string) error {
+	return fmt.Errorf("no Java compiler %s, $JAVA_HOME may be a JRE: install a JDK, build with -javac-impl ecj -javac <ecj jar>, or bind functions only with -backend classfile", tool)
+}
+
+// javaVersionOutput matches the version printed by java -version or
+// javac -version, with the major version in its last group.
+var javaVersionOutput = regexp.MustCompile(`(?:javac |version ")(1\.)?(\d+)`)
+
+// javaVersion returns the major version of the JDK tool, run with -version.
+func javaVersion(tool string) (int, error) {
+	c := exec.CommandContext(buildCtx, tool, "-version")
+	c.Env = commandEnv()
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("%s -version: %v: %s", tool, err, out)
+	}
+	m := javaVersionOutput.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("%s -version: unexpected output %q", tool, out)
+	}
+	return strconv.Atoi(string(m[2]))
+}
+
+// strictJavaFlags returns the flags of -strict-java for the Java compiler
+// run by tool: all lint checks, failing on warnings, except those warning
+// about the build rather than the code. options warns about cross compiling
+// with -source rather than --release, and processing about annotations
+// without a processor. From Java 21, this-escape warns about the constructors
+// of the generated classes, which register the new object with Seq.
+func strictJavaFlags(tool string) ([]string, error) {
+	lint := "-Xlint:all,-options,-processing"
+	v, err := javaVersion(tool)
+	if err != nil {
+		return nil, err
+	}
+	if v >= 21 {
+		lint += ",-this-escape"
+	}
+	return []string{lint, "-Werror"}, nil
+}
+
+// annotationProcessing holds the annotation processor options of a
+// compilation: the processor path, which is also added to the class path for
+// the annotations the processors handle, and the -proc policy, if set.
+type annotationProcessing struct {
+	path string
+	proc string
+}
+
+// args returns the compiler options setting the class path to classPath and
+// the processor options. The sources generated by the processors are written
+// next to outDir rather than into it, so they are not added to the jar.
+func (ap annotationProcessing) args(outDir, classPath string) ([]string, error) {
+	var args []string
+	if ap.path != "" {
+		genDir := filepath.Join(filepath.Dir(outDir), "generated-sources")
+		if err := os.MkdirAll(genDir, 0700); err != nil {
+			return nil, err
+		}
+		if classPath != "" {
+			classPath += string(filepath.ListSeparator)
+		}
+		classPath += ap.path
+		args = []string{"-processorpath", ap.path, "-s", genDir}
+	}
+	if ap.proc != "" {
+		args = append(args, "-proc:"+ap.proc)
+	}
+	return append(classPathArgs(classPath), args...), nil
+}
+
+// javac compiles Java sources using the JDK compiler.
+type javac struct {
+	path  string
+	opts  string
+	flags []string
+	ap    annotationProcessing
+}
+
+func (c *javac) compile(outDir, sourcePath, classPath string, files []string) error {
+	apArgs, err := c.ap.args(outDir, classPath)
+	if err != nil {
+		return err
+	}
+	args := append(append(append(optionsFile(c.opts), c.flags...), "-d", outDir, "-sourcepath", sourcePath), apArgs...)
+	return runWithArgFile(c.path, append(args, files...))
+}
+
+// ecj compiles Java sources using the Eclipse batch compiler. The path may be
+// an ecj executable or the ecj jar, which is run with java.
+type ecj struct {
+	path  string
+	opts  string
+	flags []string
+	ap    annotationProcessing
+}
+
+func (c *ecj) compile(outDir, sourcePath, classPath string, files []string) error {
+	apArgs, err := c.ap.args(outDir, classPath)
+	if err != nil {
+		return err
+	}
+	args := append(append(append(optionsFile(c.opts), c.flags...), "-d", outDir, "-sourcepath", sourcePath), apArgs...)
+	if strings.HasSuffix(c.path, ".jar") {
+		// The java launcher expands argument files from Java 9.
+		return runWithArgFile(javaTool("java", ""), append([]string{"-jar", c.path}, append(args, files...)...))
+	}
+	return runWithArgFile(c.path, append(args, files...))
+}
+
+// classPathArgs returns the compiler options setting the class path to
+// classPath, or nil if it is empty.
+func classPathArgs(classPath string) []string {
+	if classPath == "" {
+		return nil
+	}
+	return []string{"-classpath", classPath}
+}
+
+// toolsCompiler compiles Java sources in a single java process using the
+// javax.tools API. The options and files are passed in a manifest file rather
+// than on the command line, avoiding command line length limits when there are
+// many files.
+type toolsCompiler struct {
+	java  string
+	opts  string
+	flags []string
+	ap    annotationProcessing
+	// server compiles the manifests in a running JVM, if set.
+	server *compileServer
+}
+
+func (c *toolsCompiler) compile(outDir, sourcePath, classPath string, files []string) error {
+	dir, err := ioutil.TempDir("", "gojavac")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	apArgs, err := c.ap.args(outDir, classPath)
+	if err != nil {
+		return err
+	}
+	options := append(append(append([]string(nil), c.flags...), "-d", outDir, "-sourcepath", sourcePath), apArgs...)
+	if c.opts != "" {
+		d, err := ioutil.ReadFile(c.opts)
+		if err != nil {
+			return err
+		}
+		options = append(strings.Fields(string(d)), options...)
+	}
+	manifest := strings.Join(options, "\n") + "\n--\n" + strings.Join(files, "\n") + "\n"
+	manifestFile := filepath.Join(dir, "manifest")
+	if err := ioutil.WriteFile(manifestFile, []byte(manifest), 0600); err != nil {
+		return err
+	}
+	if c.server != nil {
+		return c.server.compile(manifestFile)
+	}
+	helper, err := writeCompileHelper(dir)
+	if err != nil {
+		return err
+	}
+	// Source files can be run directly from Java 11.
+	return runCommand(c.java, helper, manifestFile)
+}
+
+// writeCompileHelper writes the source of GojavaCompile to dir and returns
+// its path.
+func writeCompileHelper(dir string) (string, error) {
+	helper := filepath.Join(dir, "GojavaCompile.java")
+	return helper, ioutil.WriteFile(helper, []byte(compileHelper), 0600)
+}
+
+// compileServer is a java process running GojavaCompile in server mode, which
+// the daemon keeps between builds so they don't start a JVM and load the
+// compiler again. It is started by the first compilation and restarted if it
+// exits.
+type compileServer struct {
+	java string
+	dir  string
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	out  *bufio.Reader
+}
+
+// compileOK and compileFailed are the lines GojavaCompile writes in server
+// mode once a manifest is compiled.
+const (
+	compileOK     = "gojava-compile ok"
+	compileFailed = "gojava-compile failed"
+)
+
+// compile compiles the manifest file. The compiler diagnostics are written to
+// the manifest path with .log appended.
+func (s *compileServer) compile(manifest string) error {
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(s.in, manifest); err != nil {
+		s.stop()
+		return fmt.Errorf("GojavaCompile: %v", err)
+	}
+	for {
+		line, err := s.out.ReadString('\n')
+		if err != nil {
+			s.stop()
+			return fmt.Errorf("GojavaCompile exited: %v", err)
+		}
+		switch strings.TrimSpace(line) {
+		case compileOK:
+			return nil
+		case compileFailed:
+			log, _ := ioutil.ReadFile(manifest + ".log")
+			return fmt.Errorf("javac failed:\n%s", log)
+		}
+		// Anything else was printed by an annotation processor.
+		fmt.Fprint(logOut, line)
+	}
+}
+
+func (s *compileServer) start() error {
+	if s.dir == "" {
+		dir, err := ioutil.TempDir("", "gojavac")
+		if err != nil {
+			return err
+		}
+		s.dir = dir
+	}
+	helper, err := writeCompileHelper(s.dir)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(s.java, helper, "-")
+	cmd.Env = commandEnv()
+	cmd.Stderr = os.Stderr
+	if s.in, err = cmd.StdinPipe(); err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	verbosef("Started the Java compiler server\n")
+	s.cmd, s.out = cmd, bufio.NewReader(out)
+	return nil
+}
+
+// stop stops the java process, if running.
+func (s *compileServer) stop() {
+	if s.cmd == nil {
+		return
+	}
+	s.in.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait()
+	s.cmd = nil
+}
+
+const compileHelper = `import java.io.BufferedReader;
+import java.io.File;
+import java.io.InputStreamReader;
+import java.io.Writer;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Paths;
+import java.util.ArrayList;
+import java.util.List;
+import javax.tools.JavaCompiler;
+import javax.tools.StandardJavaFileManager;
+import javax.tools.ToolProvider;
+
+// GojavaCompile compiles the files in a manifest with the system Java compiler.
+// The manifest has one option per line, a line containing --, then one file per line.
+// Given - rather than a manifest, it compiles the manifests whose paths are read
+// from stdin, one per line, writing the diagnostics to the manifest path with .log
+// appended and the outcome to stdout.
+public class GojavaCompile {
+	public static void main(String[] args) throws Exception {
+		JavaCompiler compiler = ToolProvider.getSystemJavaCompiler();
+		if (compiler == null) {
+			System.err.println("no system Java compiler, a JDK is required, or -javac-impl ecj -javac <ecj jar>");
+			System.exit(2);
+		}
+		if (!args[0].equals("-")) {
+			System.exit(compile(compiler, args[0], null) ? 0 : 1);
+		}
+		BufferedReader in = new BufferedReader(new InputStreamReader(System.in, StandardCharsets.UTF_8));
+		for (String manifest; (manifest = in.readLine()) != null;) {
+			boolean ok;
+			try (Writer log = Files.newBufferedWriter(Paths.get(manifest + ".log"), StandardCharsets.UTF_8)) {
+				try {
+					ok = compile(compiler, manifest, log);
+				} catch (Exception e) {
+					log.write(e.toString());
+					ok = false;
+				}
+			}
+			System.out.println(ok ? "` + compileOK + `" : "` + compileFailed + `");
+			System.out.flush();
+		}
+	}
+
+	static boolean compile(JavaCompiler compiler, String manifest, Writer out) throws Exception {
+		List<String> options = new ArrayList<String>();
+		List<File> files = new ArrayList<File>();
+		boolean inFiles = false;
+		for (String line : Files.readAllLines(Paths.get(manifest))) {
+			if (line.isEmpty()) {
+				continue;
+			} else if (!inFiles && line.equals("--")) {
+				inFiles = true;
+			} else if (inFiles) {
+				files.add(new File(line));
+			} else {
+				options.add(line);
+			}
+		}
+		StandardJavaFileManager fm = compiler.getStandardFileManager(null, null, null);
+		try {
+			return compiler.getTask(out, fm, null, options, null, fm.getJavaFileObjectsFromFiles(files)).call();
+		} finally {
+			fm.close();
+		}
+	}
+}
+`
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestAnnotationProcessingArgs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	outDir, genDir := filepath.Join(tmpDir, "classes"), filepath.Join(tmpDir, "generated-sources")
+	sep := string(filepath.ListSeparator)
+	tests := []struct {
+		ap        annotationProcessing
+		classPath string
+		want      []string
+	}{
+		{annotationProcessing{}, "", nil},
+		{annotationProcessing{}, "lib.jar", []string{"-classpath", "lib.jar"}},
+		{annotationProcessing{proc: "none"}, "", []string{"-proc:none"}},
+		{annotationProcessing{path: "ap.jar"}, "", []string{"-classpath", "ap.jar", "-processorpath", "ap.jar", "-s", genDir}},
+		{annotationProcessing{path: "ap.jar", proc: "full"}, outDir, []string{"-classpath", outDir + sep + "ap.jar", "-processorpath", "ap.jar", "-s", genDir, "-proc:full"}},
+	}
+	for _, test := range tests {
+		got, err := test.ap.args(outDir, test.classPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v %q: got %q, want %q", test.ap, test.classPath, got, test.want)
+		}
+	}
+	if _, err := os.Stat(genDir); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStrictJavaFlags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as javac")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tool := filepath.Join(tmpDir, "javac")
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"javac 1.8.0_392", []string{"-Xlint:all,-options,-processing", "-Werror"}},
+		{"javac 17.0.9", []string{"-Xlint:all,-options,-processing", "-Werror"}},
+		{"openjdk version \"21.0.1\" 2023-10-17", []string{"-Xlint:all,-options,-processing,-this-escape", "-Werror"}},
+	}
+	for _, test := range tests {
+		// Java 8 prints the version to stderr.
+		script := "#!/bin/sh\necho '" + test.version + "' >&2\n"
+		if err := ioutil.WriteFile(tool, []byte(script), 0700); err != nil {
+			t.Fatal(err)
+		}
+		got, err := strictJavaFlags(tool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.version, got, test.want)
+		}
+	}
+}
+
+func TestNoJavaCompiler(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javac := filepath.Join(tmpDir, "bin", "javac")
+	_, err = newJavaCompiler(&config{javac: javac})
+	if err == nil || !strings.Contains(err.Error(), "-javac-impl ecj") {
+		t.Errorf("missing javac: got %v", err)
+	}
+	if _, err := newJavaCompiler(&config{javacImpl: "ecj", javac: filepath.Join(tmpDir, "ecj.jar")}); err != nil {
+		t.Errorf("ecj: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// configStruct is a bound struct type marked with a //gojava:config <prefix>
+// directive, configuring a Go component from the configuration of the host
+// application, under the property prefix.
+type configStruct struct {
+	pkg    *types.Package
+	name   string
+	prefix string
+	// class is the qualified Java name of the class of the type.
+	class  string
+	fields []configField
+}
+
+// configField is a field of a config struct with a basic type.
+type configField struct {
+	// name is the Go name of the field, which the Java setter is named after.
+	name string
+	// property is the name of the field in the configuration: the name in its
+	// yaml, json or mapstructure tag, or its Go name in kebab case.
+	property string
+	// env is the environment variable setting the field: the name in its env
+	// tag, or the prefix and property in upper snake case.
+	env string
+	// javaType is the Java type of the field.
+	javaType string
+}
+
+// configJavaTypes maps the basic types of config fields to Java types.
+var configJavaTypes = map[types.BasicKind]string{
+	types.String: "String", types.Bool: "boolean", types.Int: "long", types.Int64: "long",
+	types.Int32: "int", types.Int16: "short", types.Int8: "byte", types.Float64: "double", types.Float32: "float",
+}
+
+// findConfigStructs returns the struct types of pkgs marked with a
+// //gojava:config <prefix> directive, with their exported fields of basic
+// types. Fields of other types, or with a "-" yaml, json or mapstructure tag,
+// are left out.
+func findConfigStructs(pkgs []*types.Package, docs *docFinder, split bool) ([]configStruct, error) {
+	var configs []configStruct
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			for _, dir := range docs.directives(tn) {
+				f := strings.Fields(dir)
+				if len(f) == 0 || f[0] != "config" {
+					continue
+				}
+				if len(f) != 2 {
+					return nil, fmt.Errorf("%s.%s: invalid directive //gojava:%s, expected //gojava:config <prefix>", p.Path(), name, dir)
+				}
+				s, ok := tn.Type().Underlying().(*types.Struct)
+				if !ok || !tn.Exported() {
+					return nil, fmt.Errorf("%s.%s: //gojava:config must be on an exported struct type", p.Path(), name)
+				}
+				c := configStruct{pkg: p, name: name, prefix: f[1], class: strings.Replace(javaBinaryName(p, name, split), "$", ".", -1)}
+				for i := 0; i < s.NumFields(); i++ {
+					fld := s.Field(i)
+					b, ok := fld.Type().(*types.Basic)
+					if !fld.Exported() || fld.Anonymous() || !ok || configJavaTypes[b.Kind()] == "" {
+						continue
+					}
+					property := configProperty(fld.Name(), s.Tag(i))
+					if property == "-" {
+						continue
+					}
+					env := reflect.StructTag(s.Tag(i)).Get("env")
+					if env == "" {
+						env = envName(c.prefix + "." + property)
+					}
+					c.fields = append(c.fields, configField{name: fld.Name(), property: property, env: env, javaType: configJavaTypes[b.Kind()]})
+				}
+				configs = append(configs, c)
+			}
+		}
+	}
+	return configs, nil
+}
+
+// configProperty returns the name of the field name with the struct tag tag
+// in the configuration, or "-" if it is left out.
+func configProperty(name, tag string) string {
+	for _, key := range []string{"yaml", "json", "mapstructure"} {
+		if v, ok := reflect.StructTag(tag).Lookup(key); ok {
+			if v = strings.Split(v, ",")[0]; v != "" {
+				return v
+			}
+		}
+	}
+	return kebabCase(name)
+}
+
+// envName returns the environment variable for the property key, such as
+// MYAPP_LISTEN_ADDR for myapp.listen-addr.
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// kebabCase returns the Go name name in kebab case, such as listen-addr for
+// ListenAddr and http-port for HTTPPort.
+func kebabCase(name string) string {
+	var b strings.Builder
+	r := []rune(name)
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 && (unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			b.WriteByte('-')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+// lowerCamel returns the Go name name with its leading upper case letters in
+// lower case, such as listenAddr for ListenAddr and httpPort for HTTPPort.
+func lowerCamel(name string) string {
+	r := []rune(name)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// genConfigLoaders writes go.GoConfig and a loader class for each config
+// struct of pkgs to javaDir, setting the fields of the struct from Java
+// properties, the environment or YAML. It returns the paths of the files, or
+// nil if pkgs have no config structs.
+func genConfigLoaders(javaDir string, pkgs []*types.Package, docs *docFinder, split bool) ([]string, error) {
+	configs, err := findConfigStructs(pkgs, docs, split)
+	if err != nil || len(configs) == 0 {
+		return nil, err
+	}
+	path := filepath.Join(javaDir, "GoConfig.java")
+	if err := writeJavaFile(path, []byte(goConfigJava)); err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	for _, c := range configs {
+		class := c.name + "Loader"
+		path := filepath.Join(javaDir, c.pkg.Name(), class+".java")
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s.%s: class %s.%s already exists", c.pkg.Path(), c.name, javaPkgName(c.pkg), class)
+		}
+		if err := writeJavaFile(path, configLoader(class, c)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// configParse maps the Java types of config fields to the expressions
+// parsing the string v.
+var configParse = map[string]string{
+	"String": "v", "boolean": "Boolean.parseBoolean(v.trim())", "long": "Long.parseLong(v.trim())",
+	"int": "Integer.parseInt(v.trim())", "short": "Short.parseShort(v.trim())", "byte": "Byte.parseByte(v.trim())",
+	"double": "Double.parseDouble(v.trim())", "float": "Float.parseFloat(v.trim())",
+}
+
+// configLoader returns the loader class named class for the config struct c.
+func configLoader(class string, c configStruct) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Java config loader for %s.%s generated by gojava.\n", c.pkg.Path(), c.name)
+	fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(c.pkg))
+	fmt.Fprintf(&b, "// %s sets the fields of %s from the %s.* properties.\n", class, c.class, c.prefix)
+	fmt.Fprintf(&b, "public final class %s {\n", class)
+	fmt.Fprintf(&b, "\tpublic static final String PREFIX = %q;\n\n", c.prefix)
+	fmt.Fprintf(&b, "\tprivate %s() {}\n\n", class)
+	fmt.Fprintf(&b, "\tpublic static %s fromProperties(java.util.Properties properties) {\n\t\treturn load(go.GoConfig.properties(properties));\n\t}\n\n", c.class)
+	fmt.Fprintf(&b, "\tpublic static %s fromEnvironment() {\n\t\treturn load(go.GoConfig.environment(System.getenv()));\n\t}\n\n", c.class)
+	fmt.Fprintf(&b, "\tpublic static %s fromYaml(java.io.Reader yaml) throws java.io.IOException {\n\t\treturn load(go.GoConfig.yaml(yaml));\n\t}\n\n", c.class)
+	b.WriteString("\t// load returns a new config with the fields set by source, throwing an\n\t// IllegalArgumentException for invalid values.\n")
+	fmt.Fprintf(&b, "\tpublic static %s load(go.GoConfig.Source source) {\n", c.class)
+	fmt.Fprintf(&b, "\t\t%s c = new %s();\n", c.class, c.class)
+	if len(c.fields) > 0 {
+		b.WriteString("\t\tString v;\n")
+	}
+	for _, f := range c.fields {
+		key := c.prefix + "." + f.property
+		fmt.Fprintf(&b, "\t\tif ((v = source.get(%q, %q)) != null) {\n", key, f.env)
+		if f.javaType == "String" {
+			fmt.Fprintf(&b, "\t\t\tc.set%s(v);\n", f.name)
+		} else {
+			fmt.Fprintf(&b, "\t\t\ttry {\n\t\t\t\tc.set%s(%s);\n", f.name, configParse[f.javaType])
+			fmt.Fprintf(&b, "\t\t\t} catch (NumberFormatException ex) {\n\t\t\t\tthrow new IllegalArgumentException(%q + v, ex);\n\t\t\t}\n", key+": invalid value ")
+		}
+		b.WriteString("\t\t}\n")
+	}
+	b.WriteString("\t\treturn c;\n\t}\n}\n")
+	return b.Bytes()
+}
+
+const goConfigJava = `package go;
+
+import java.io.BufferedReader;
+import java.io.IOException;
+import java.io.Reader;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.Properties;
+
+// GoConfig holds the sources the generated config loaders read the fields of
+// Go config structs from.
+public final class GoConfig {
+	private GoConfig() {}
+
+	// Source returns the value of a config field, from its property key or its
+	// environment variable, or null if it is not set.
+	public interface Source {
+		String get(String key, String env);
+	}
+
+	public static Source properties(final Properties p) {
+		return new Source() {
+			@Override
+			public String get(String key, String env) {
+				return p.getProperty(key);
+			}
+		};
+	}
+
+	public static Source environment(final Map<String, String> env) {
+		return new Source() {
+			@Override
+			public String get(String key, String name) {
+				return env.get(name);
+			}
+		};
+	}
+
+	// map returns a source reading the property keys from m.
+	public static Source map(final Map<String, String> m) {
+		return new Source() {
+			@Override
+			public String get(String key, String env) {
+				return m.get(key);
+			}
+		};
+	}
+
+	// yaml returns a source reading the property keys from a YAML document of
+	// nested mappings, where the key a.b is the value of b in the mapping a.
+	// Sequences and multi-line scalars are not supported.
+	public static Source yaml(Reader r) throws IOException {
+		return map(flattenYaml(r));
+	}
+
+	// flattenYaml returns the scalars of a YAML document of nested mappings,
+	// keyed by their paths joined with dots.
+	public static Map<String, String> flattenYaml(Reader r) throws IOException {
+		Map<String, String> values = new HashMap<String, String>();
+		List<Integer> indents = new ArrayList<Integer>();
+		List<String> keys = new ArrayList<String>();
+		BufferedReader br = new BufferedReader(r);
+		String line;
+		int n = 0;
+		while ((line = br.readLine()) != null) {
+			n++;
+			String trimmed = stripComment(line).trim();
+			if (trimmed.isEmpty() || trimmed.equals("---")) {
+				continue;
+			}
+			int indent = 0;
+			while (line.charAt(indent) == ' ') {
+				indent++;
+			}
+			int colon = trimmed.indexOf(':');
+			if (colon <= 0 || trimmed.startsWith("-")) {
+				throw new IOException("line " + n + ": expected key: value");
+			}
+			while (!indents.isEmpty() && indents.get(indents.size() - 1) >= indent) {
+				indents.remove(indents.size() - 1);
+				keys.remove(keys.size() - 1);
+			}
+			String key = unquote(trimmed.substring(0, colon).trim());
+			String value = trimmed.substring(colon + 1).trim();
+			StringBuilder path = new StringBuilder();
+			for (String k : keys) {
+				path.append(k).append('.');
+			}
+			path.append(key);
+			if (value.isEmpty()) {
+				indents.add(indent);
+				keys.add(key);
+			} else {
+				values.put(path.toString(), unquote(value));
+			}
+		}
+		return values;
+	}
+
+	private static String stripComment(String line) {
+		char quote = 0;
+		for (int i = 0; i < line.length(); i++) {
+			char c = line.charAt(i);
+			if (quote != 0) {
+				if (c == quote) {
+					quote = 0;
+				}
+			} else if (c == '"' || c == '\'') {
+				quote = c;
+			} else if (c == '#' && (i == 0 || line.charAt(i - 1) == ' ')) {
+				return line.substring(0, i);
+			}
+		}
+		return line;
+	}
+
+	private static String unquote(String s) {
+		if (s.length() >= 2 && (s.charAt(0) == '"' || s.charAt(0) == '\'') && s.charAt(s.length() - 1) == s.charAt(0)) {
+			return s.substring(1, s.length() - 1);
+		}
+		return s;
+	}
+}
+`
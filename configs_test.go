@@ -0,0 +1,122 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const configsSrc = `package testpkg
+
+// Config configures the server.
+//
+//gojava:config myapp.server
+type Config struct {
+	ListenAddr string
+	HTTPPort   int
+	Debug      bool
+	Ratio      float32
+	Tags       []string
+	internal   int
+	Timeout    int    ` + "`yaml:\"timeout_ms,omitempty\" env:\"SERVER_TIMEOUT\"`" + `
+	Secret     string ` + "`json:\"-\"`" + `
+}
+`
+
+func TestFindConfigStructs(t *testing.T) {
+	p, docs := typeCheckFile(t, configsSrc)
+	configs, err := findConfigStructs([]*types.Package{p}, docs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %+v", configs)
+	}
+	c := configs[0]
+	if c.name != "Config" || c.prefix != "myapp.server" || c.class != "go.testpkg.Testpkg.Config" {
+		t.Errorf("got %+v", c)
+	}
+	exp := []configField{
+		{"ListenAddr", "listen-addr", "MYAPP_SERVER_LISTEN_ADDR", "String"},
+		{"HTTPPort", "http-port", "MYAPP_SERVER_HTTP_PORT", "long"},
+		{"Debug", "debug", "MYAPP_SERVER_DEBUG", "boolean"},
+		{"Ratio", "ratio", "MYAPP_SERVER_RATIO", "float"},
+		{"Timeout", "timeout_ms", "SERVER_TIMEOUT", "long"},
+	}
+	if len(c.fields) != len(exp) {
+		t.Fatalf("got fields %+v, expected %+v", c.fields, exp)
+	}
+	for i := range exp {
+		if c.fields[i] != exp[i] {
+			t.Errorf("field %d: got %+v, expected %+v", i, c.fields[i], exp[i])
+		}
+	}
+
+	for _, src := range []string{
+		"package testpkg\n\n//gojava:config\ntype Config struct{}\n",
+		"package testpkg\n\n//gojava:config app\ntype Config int\n",
+	} {
+		p, docs := typeCheckFile(t, src)
+		if _, err := findConfigStructs([]*types.Package{p}, docs, false); err == nil {
+			t.Errorf("expected an error for:\n%s", src)
+		}
+	}
+}
+
+func TestConfigNames(t *testing.T) {
+	for _, tc := range []struct{ name, kebab, camel string }{
+		{"Addr", "addr", "addr"},
+		{"ListenAddr", "listen-addr", "listenAddr"},
+		{"HTTPPort", "http-port", "httpPort"},
+		{"URL", "url", "url"},
+		{"MaxConnsPerIP", "max-conns-per-ip", "maxConnsPerIP"},
+	} {
+		if got := kebabCase(tc.name); got != tc.kebab {
+			t.Errorf("kebabCase(%q) = %q, expected %q", tc.name, got, tc.kebab)
+		}
+		if got := lowerCamel(tc.name); got != tc.camel {
+			t.Errorf("lowerCamel(%q) = %q, expected %q", tc.name, got, tc.camel)
+		}
+	}
+}
+
+func TestGenConfigLoaders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p, docs := typeCheckFile(t, configsSrc)
+	files, err := genConfigLoaders(tmpDir, []*types.Package{p}, docs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[1]) != "ConfigLoader.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"package go.testpkg;",
+		"\tpublic static final String PREFIX = \"myapp.server\";",
+		"\tpublic static go.testpkg.Testpkg.Config load(go.GoConfig.Source source) {",
+		"\t\tif ((v = source.get(\"myapp.server.listen-addr\", \"MYAPP_SERVER_LISTEN_ADDR\")) != null) {\n\t\t\tc.setListenAddr(v);\n",
+		"\t\tif ((v = source.get(\"myapp.server.timeout_ms\", \"SERVER_TIMEOUT\")) != null) {\n\t\t\ttry {\n\t\t\t\tc.setTimeout(Long.parseLong(v.trim()));\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("missing %q:\n%s", s, d)
+		}
+	}
+	if strings.Contains(string(d), "Secret") {
+		t.Errorf("field with json:\"-\" not left out:\n%s", d)
+	}
+
+	if files, err := genConfigLoaders(tmpDir, []*types.Package{typeCheck(t, "package testpkg\n")}, docs, false); err != nil || files != nil {
+		t.Errorf("got %v, %v without config structs", files, err)
+	}
+}
@@ -0,0 +1,969 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// converter converts a Go type gomobile cannot bind to its natural Java
+// counterpart, through the encoding of go.GoConvert.
+type converter struct {
+	// pkg is the Go package the glue code imports for the type.
+	pkg string
+	// java is the Java type.
+	java string
+	// enc names the methods of the Go and Java readers and writers encoding
+	// the type, such as readTime and writeTime.
+	enc string
+	// param is set for types that can only be parameters.
+	param bool
+}
+
+// converters maps the Go types to convert, by their type string.
+var converters = map[string]converter{
+	"time.Time":                      {"time", "java.time.Instant", "Time", false},
+	"time.Duration":                  {"time", "java.time.Duration", "Duration", false},
+	"net.IP":                         {"net", "java.net.InetAddress", "IP", false},
+	"*net/url.URL":                   {"net/url", "java.net.URI", "URL", false},
+	"net/url.URL":                    {"net/url", "java.net.URI", "URLValue", false},
+	"image.Image":                    {"image", "go.GoImage", "Image", false},
+	"*image.RGBA":                    {"image", "go.GoImage", "RGBA", false},
+	"*image.NRGBA":                   {"image", "go.GoImage", "NRGBA", false},
+	"*image.Gray":                    {"image", "go.GoImage", "Gray", false},
+	"*os.File":                       {"os", "go.GoFileRegion", "File", true},
+	"*io.SectionReader":              {"io", "go.GoFileRegion", "Section", true},
+	"net.Conn":                       {"net", "go.GoFd", "Conn", false},
+	"net.Listener":                   {"net", "go.GoFd", "Listener", false},
+	"net.PacketConn":                 {"net", "go.GoFd", "PacketConn", false},
+	"github.com/google/uuid.UUID":    {"github.com/google/uuid", "java.util.UUID", "UUID", false},
+	"github.com/gofrs/uuid.UUID":     {"github.com/gofrs/uuid", "java.util.UUID", "UUID", false},
+	"github.com/satori/go.uuid.UUID": {"github.com/satori/go.uuid", "java.util.UUID", "UUID", false},
+}
+
+// wireType returns the name of the encoding methods and the Java type of t,
+// or false if t can not be passed through go.GoConvert.
+func wireType(t types.Type) (string, string, bool) {
+	if c, ok := converters[t.String()]; ok {
+		return c.enc, c.java, true
+	}
+	jt, err := javaType(t)
+	if err != nil {
+		return "", "", false
+	}
+	switch jt {
+	case "boolean":
+		return "Bool", jt, true
+	case "byte", "short", "int", "long":
+		return "Int", jt, true
+	case "float", "double":
+		return "Float", jt, true
+	case "String":
+		return "String", jt, true
+	case "byte[]":
+		return "Bytes", jt, true
+	}
+	return "", "", false
+}
+
+// convertedFunc is a bound package function using a type in converters, bound
+// through go.GoConvert.
+type convertedFunc struct {
+	pkg *types.Package
+	fn  *types.Func
+	// err is set if the last result of fn is an error.
+	err bool
+	// mapped holds the []byte parameters named by a //gojava:mmap directive,
+	// which are memory-mapped from a go.GoFileRegion.
+	mapped []string
+	// fds holds the *os.File parameters named by a //gojava:fd directive,
+	// which are opened from the descriptor of a go.GoFd.
+	fds []string
+}
+
+// wire returns the name of the encoding methods and the Java type of the
+// parameter or result v of f.
+func (f convertedFunc) wire(v *types.Var) (string, string, bool) {
+	if containsString(f.mapped, v.Name()) && v.Type().String() == "[]byte" {
+		return "Mapped", "go.GoFileRegion", true
+	}
+	if containsString(f.fds, v.Name()) && v.Type().String() == "*os.File" {
+		return "Fd", "go.GoFd", true
+	}
+	return wireType(v.Type())
+}
+
+// findConvertedFuncs returns the exported functions of p with a parameter or
+// result of a type in converters, or a parameter named by a //gojava:mmap
+// directive, and otherwise only basic types, []byte and an error result. If
+// all is set, the functions using only basic types, []byte and an error are
+// also returned. If exposed is not nil, only the functions in it are
+// returned.
+func findConvertedFuncs(p *types.Package, exposed []string, docs *docFinder, all bool) []convertedFunc {
+	var funcs []convertedFunc
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() || (exposed != nil && !containsString(exposed, name)) {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		res := sig.Results()
+		f := convertedFunc{pkg: p, fn: fn, err: res.Len() > 0 && isError(res.At(res.Len()-1).Type())}
+		for _, dir := range docs.directives(fn) {
+			fields := strings.Fields(dir)
+			if len(fields) > 1 && fields[0] == "mmap" {
+				f.mapped = append(f.mapped, fields[1:]...)
+			}
+			if len(fields) > 1 && fields[0] == "fd" {
+				f.fds = append(f.fds, fields[1:]...)
+			}
+		}
+		n := res.Len()
+		if f.err {
+			n--
+		}
+		if n > 1 {
+			continue
+		}
+		supported, converted := true, false
+		for _, v := range tupleVars(sig.Params()) {
+			enc, _, ok := f.wire(v)
+			supported = supported && ok
+			_, conv := converters[v.Type().String()]
+			converted = converted || conv || enc == "Mapped"
+		}
+		if n == 1 {
+			c, conv := converters[res.At(0).Type().String()]
+			_, _, ok := wireType(res.At(0).Type())
+			supported = supported && ok && !c.param
+			converted = converted || conv
+		}
+		if supported && (converted || all) {
+			funcs = append(funcs, f)
+		}
+	}
+	return funcs
+}
+
+// genConverters adds static methods to the package classes of pkgs in javaDir
+// for the functions using the types in converters, which gomobile does not
+// bind, and writes the Go and C code calling them to bindDir. If server is
+// "process" or "wasm", all the package functions it can encode are called
+// through go.GoConvert, replacing their native methods, and the main package
+// calling them in a child process or a WebAssembly module is written to the
+// gojava_server directory of bindDir. It returns the paths of go.GoConvert and
+// the classes it uses, or nil if there are no such functions.
+func genConverters(bindDir, javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, docs *docFinder, server string) ([]string, error) {
+	var funcs []convertedFunc
+	for _, p := range pkgs {
+		var e []string
+		if exposed != nil {
+			e = exposed[p]
+			if e == nil {
+				e = []string{}
+			}
+		}
+		pfuncs := findConvertedFuncs(p, e, docs, server != "")
+		if len(pfuncs) == 0 {
+			continue
+		}
+		path := filepath.Join(javaDir, javaClassName(p)+".java")
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var members bytes.Buffer
+		for i, f := range pfuncs {
+			verbosef("Converting the types of %s.%s\n", p.Path(), f.fn.Name())
+			members.WriteString(convertedJavaMethod(f, len(funcs)+i))
+		}
+		if server != "" {
+			src = outOfProcessClass(src, pfuncs, server == "wasm")
+		}
+		if src, err = insertIntoClass(src, javaClassName(p), members.String()); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, src, 0600); err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, pfuncs...)
+	}
+	if len(funcs) == 0 {
+		return nil, nil
+	}
+	goSrc := convertGoSource(funcs, "")
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_convert.go"), goSrc, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_convert.c"), []byte(convertC), 0600); err != nil {
+		return nil, err
+	}
+	if server != "" {
+		if err := writeServer(filepath.Join(bindDir, "gojava_server"), funcs, server); err != nil {
+			return nil, err
+		}
+	}
+	// The platform specific parts of the glue are only written when used.
+	for _, g := range []struct {
+		encs              []string
+		name, unix, other string
+	}{
+		{fileEncs, "gojava_mmap", mmapUnixGo, mmapOtherGo},
+		{fdEncs, "gojava_fd", fdUnixGo, fdOtherGo},
+	} {
+		if !convertUses(funcs, g.encs...) {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(bindDir, g.name+"_unix.go"), []byte(g.unix), 0600); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(bindDir, g.name+"_other.go"), []byte(g.other), 0600); err != nil {
+			return nil, err
+		}
+	}
+	javaSrcs := []struct{ name, src string }{
+		{"GoConvert", goConvertJava},
+		{"GoImage", goImageJava},
+		{"GoImages", goImagesJava},
+		{"GoFileRegion", goFileRegionJava},
+		{"GoFd", goFdJava},
+		{"GoProcess", goProcessJava},
+		{"GoUnavailableException", goUnavailableExceptionJava},
+	}
+	if server == "wasm" {
+		// GoWasm needs the WebAssembly runtime to compile, so only the
+		// bindings built for it call it.
+		javaSrcs[0].src = strings.Replace(goConvertJava, goConvertCallNative, goConvertCallWasm, 1)
+		javaSrcs = append(javaSrcs, struct{ name, src string }{"GoWasm", goWasmJava})
+	}
+	var files []string
+	for _, f := range javaSrcs {
+		path := filepath.Join(javaDir, f.name+".java")
+		if err := writeJavaFile(path, []byte(f.src)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// convertedJavaMethod returns the static method calling the function f, the
+// index-th function of go.GoConvert.
+func convertedJavaMethod(f convertedFunc, index int) string {
+	sig := f.fn.Type().(*types.Signature)
+	var params []string
+	var b bytes.Buffer
+	b.WriteString("\t\tgo.GoConvert.Writer w = new go.GoConvert.Writer();\n")
+	for i, v := range tupleVars(sig.Params()) {
+		enc, jt, _ := f.wire(v)
+		name := javaIdent(v.Name())
+		if name == "" || name == "_" || name == "w" || name == "r" {
+			// w and r are the writer and reader of the call.
+			name = fmt.Sprintf("arg%d", i)
+		}
+		params = append(params, jt+" "+name)
+		fmt.Fprintf(&b, "\t\tw.write%s(%s);\n", enc, name)
+	}
+	ret := "void"
+	fmt.Fprintf(&b, "\t\tgo.GoConvert.Reader r = go.GoConvert.call(%d, w);\n", index)
+	res := sig.Results()
+	if res.Len() > 0 && !(f.err && res.Len() == 1) {
+		enc, jt, _ := wireType(res.At(0).Type())
+		ret = jt
+		if jt == "byte" || jt == "short" || jt == "int" || jt == "float" {
+			fmt.Fprintf(&b, "\t\treturn (%s) r.read%s();\n", jt, enc)
+		} else {
+			fmt.Fprintf(&b, "\t\treturn r.read%s();\n", enc)
+		}
+	}
+	// Calls throw for errors returned by the function, and for values the Go
+	// type cannot hold, so all methods declare Exception like gomobile.
+	return fmt.Sprintf("\n\tpublic static %s %s(%s) throws Exception {\n%s\t}\n", ret, javaMethodName(f.fn.Name()), strings.Join(params, ", "), b.String())
+}
+
+// convertGoSource returns the Go glue decoding the arguments of funcs,
+// calling them and encoding their results. If server is "process" or "wasm",
+// it is the main package of the server calling them out of process or of the
+// WebAssembly module, and otherwise the glue called by go.GoConvert through
+// JNI.
+func convertGoSource(funcs []convertedFunc, server string) []byte {
+	imports := map[string]string{}
+	alias := func(path string) string {
+		if a, ok := imports[path]; ok {
+			return a
+		}
+		a := fmt.Sprintf("conv%d", len(imports))
+		imports[path] = a
+		return a
+	}
+	qualifier := func(p *types.Package) string {
+		return alias(p.Path())
+	}
+	var calls bytes.Buffer
+	for _, f := range funcs {
+		sig := f.fn.Type().(*types.Signature)
+		var args []string
+		calls.WriteString("\tfunc(r *gojavaReader, w *gojavaWriter) error {\n")
+		for i, v := range tupleVars(sig.Params()) {
+			enc, _, _ := f.wire(v)
+			c, ok := converters[v.Type().String()]
+			if ok {
+				alias(c.pkg)
+			}
+			if ok || enc == "Mapped" {
+				fmt.Fprintf(&calls, "\t\ta%d := r.read%s()\n", i, enc)
+			} else {
+				fmt.Fprintf(&calls, "\t\ta%d := %s(r.read%s())\n", i, types.TypeString(v.Type(), qualifier), enc)
+			}
+			args = append(args, fmt.Sprintf("a%d", i))
+		}
+		calls.WriteString("\t\tif r.err != nil {\n\t\t\treturn r.err\n\t\t}\n")
+		call := fmt.Sprintf("%s.%s(%s)", alias(f.pkg.Path()), f.fn.Name(), strings.Join(args, ", "))
+		res := sig.Results()
+		switch {
+		case res.Len() == 0:
+			fmt.Fprintf(&calls, "\t\t%s\n\t\treturn nil\n", call)
+		case f.err && res.Len() == 1:
+			fmt.Fprintf(&calls, "\t\treturn %s\n", call)
+		default:
+			enc, _, _ := wireType(res.At(0).Type())
+			if c, ok := converters[res.At(0).Type().String()]; ok {
+				alias(c.pkg)
+			}
+			conv := "v"
+			if _, ok := converters[res.At(0).Type().String()]; !ok {
+				conv = wireGoType(enc) + "(v)"
+			}
+			if f.err {
+				fmt.Fprintf(&calls, "\t\tv, err := %s\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n", call)
+			} else {
+				fmt.Fprintf(&calls, "\t\tv := %s\n", call)
+			}
+			fmt.Fprintf(&calls, "\t\tw.write%s(%s)\n\t\treturn nil\n", enc, conv)
+		}
+		calls.WriteString("\t},\n")
+	}
+	if _, ok := imports["image"]; ok {
+		// Images of other types are converted with image/draw.
+		alias("image/draw")
+	}
+	if convertUses(funcs, fileEncs...) {
+		alias("io")
+		alias("os")
+	}
+	if convertUses(funcs, fdEncs...) {
+		alias("net")
+		alias("os")
+		alias("syscall")
+	}
+	var paths []string
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var b bytes.Buffer
+	switch server {
+	case "process":
+		b.WriteString(serverGoHeader)
+	case "wasm":
+		b.WriteString(wasmGoHeader)
+	default:
+		b.WriteString(convertGoHeader)
+	}
+	for _, p := range paths {
+		fmt.Fprintf(&b, "\t%s %q\n", imports[p], p)
+	}
+	b.WriteString(")\n")
+	fmt.Fprintf(&b, convertGoBody, calls.String(), wireGoHelpers(imports))
+	switch server {
+	case "process":
+		b.WriteString(serverGoMain)
+	case "wasm":
+		b.WriteString(wasmGoMain)
+	default:
+		b.WriteString(convertGoExport)
+	}
+	return b.Bytes()
+}
+
+var (
+	// fileEncs are the encodings of the parameters read from a
+	// go.GoFileRegion.
+	fileEncs = []string{"File", "Section", "Mapped"}
+	// fdEncs are the encodings of the values passed as a go.GoFd.
+	fdEncs = []string{"Fd", "Conn", "Listener", "PacketConn"}
+)
+
+// convertUses reports whether a parameter or result of funcs is encoded by
+// the methods named by one of encs.
+func convertUses(funcs []convertedFunc, encs ...string) bool {
+	for _, f := range funcs {
+		sig := f.fn.Type().(*types.Signature)
+		for _, v := range append(tupleVars(sig.Params()), tupleVars(sig.Results())...) {
+			if enc, _, _ := f.wire(v); containsString(encs, enc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wireGoType returns the Go type read and written by the encoding methods
+// named enc for basic types.
+func wireGoType(enc string) string {
+	switch enc {
+	case "Bool":
+		return "bool"
+	case "Int":
+		return "int64"
+	case "Float":
+		return "float64"
+	case "String":
+		return "string"
+	}
+	return "[]byte"
+}
+
+// wireGoHelpers returns the reader and writer methods for the converted types
+// whose packages are imported with the aliases in imports.
+func wireGoHelpers(imports map[string]string) string {
+	var b bytes.Buffer
+	if a, ok := imports["time"]; ok {
+		fmt.Fprintf(&b, convertGoTime, a, a, a, a, a, a)
+	}
+	if a, ok := imports["net"]; ok {
+		fmt.Fprintf(&b, convertGoIP, a, a)
+	}
+	if a, ok := imports["net/url"]; ok {
+		fmt.Fprintf(&b, convertGoURL, a, a, a, a, a, a)
+	}
+	if a, ok := imports["image"]; ok {
+		fmt.Fprintf(&b, convertGoImage, a, imports["image/draw"])
+	}
+	if _, ok := imports["io"]; ok {
+		fmt.Fprintf(&b, convertGoFile, imports["io"], imports["os"])
+	}
+	if _, ok := imports["syscall"]; ok {
+		fmt.Fprintf(&b, convertGoFd, imports["net"], imports["os"], imports["syscall"])
+	}
+	for _, p := range []string{"github.com/google/uuid", "github.com/gofrs/uuid", "github.com/satori/go.uuid"} {
+		if a, ok := imports[p]; ok {
+			fmt.Fprintf(&b, convertGoUUID, a, a)
+			break
+		}
+	}
+	return b.String()
+}
+
+const convertGoHeader = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+
+`
+
+const convertGoBody = `
+// gojavaConverted call the functions bound through go.GoConvert, reading their
+// arguments from r and writing their results to w.
+var gojavaConverted = []func(r *gojavaReader, w *gojavaWriter) error{
+%s}
+
+// gojavaReader reads the encoding of go.GoConvert.Writer.
+type gojavaReader struct {
+	b   []byte
+	err error
+	// closers release the files opened for the arguments.
+	closers []func()
+}
+
+func (r *gojavaReader) close() {
+	for _, c := range r.closers {
+		c()
+	}
+}
+
+func (r *gojavaReader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		if r.err == nil {
+			r.err = errors.New("gojava: truncated arguments")
+		}
+		return make([]byte, n)
+	}
+	d := r.b[:n]
+	r.b = r.b[n:]
+	return d
+}
+
+func (r *gojavaReader) readBool() bool     { return r.next(1)[0] != 0 }
+func (r *gojavaReader) readInt() int64     { return int64(binary.BigEndian.Uint64(r.next(8))) }
+func (r *gojavaReader) readFloat() float64 { return math.Float64frombits(binary.BigEndian.Uint64(r.next(8))) }
+
+func (r *gojavaReader) readBytes() []byte {
+	n := int32(binary.BigEndian.Uint32(r.next(4)))
+	if n < 0 {
+		return nil
+	}
+	// The arguments are not reused, so they are not copied again.
+	b := r.next(int(n))
+	return b[:len(b):len(b)]
+}
+
+func (r *gojavaReader) readString() string { return string(r.readBytes()) }
+
+// gojavaWriter writes the encoding read by go.GoConvert.Reader.
+type gojavaWriter struct {
+	b   []byte
+	err error
+}
+
+func (w *gojavaWriter) writeBool(v bool) {
+	if v {
+		w.b = append(w.b, 1)
+	} else {
+		w.b = append(w.b, 0)
+	}
+}
+
+func (w *gojavaWriter) writeInt(v int64) {
+	w.b = append(w.b, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(w.b[len(w.b)-8:], uint64(v))
+}
+
+func (w *gojavaWriter) writeFloat(v float64) { w.writeInt(int64(math.Float64bits(v))) }
+
+func (w *gojavaWriter) writeLen(n int) {
+	w.b = append(w.b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(w.b[len(w.b)-4:], uint32(int32(n)))
+}
+
+func (w *gojavaWriter) writeBytes(v []byte) {
+	if v == nil {
+		w.writeLen(-1)
+		return
+	}
+	w.writeLen(len(v))
+	w.b = append(w.b, v...)
+}
+
+func (w *gojavaWriter) writeString(v string) {
+	w.writeLen(len(v))
+	w.b = append(w.b, v...)
+}
+%s
+// gojavaCall calls the function fn with the encoded arguments args. The
+// result starts with 0 followed by the encoded results, or 1 followed by an
+// error message.
+func gojavaCall(fn int, args []byte) []byte {
+	r := &gojavaReader{b: args}
+	defer r.close()
+	w := &gojavaWriter{b: []byte{0}}
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %%v", p)
+			}
+		}()
+		if err := gojavaConverted[fn](r, w); err != nil {
+			return err
+		}
+		return w.err
+	}()
+	if err != nil {
+		w.b = []byte{1}
+		w.writeString(err.Error())
+	}
+	return w.b
+}
+`
+
+const convertGoExport = `
+// gojava_convert_call calls gojavaCall for go.GoConvert.
+//
+//export gojava_convert_call
+func gojava_convert_call(fn C.int, args *C.char, n C.int, size *C.int) *C.char {
+	// The arguments are copied by C.GoBytes, so they are not copied again.
+	res := gojavaCall(int(fn), C.GoBytes(unsafe.Pointer(args), n))
+	*size = C.int(len(res))
+	return (*C.char)(C.CBytes(res))
+}
+`
+
+const convertGoTime = `
+func (r *gojavaReader) readTime() %s.Time {
+	s := r.readInt()
+	return %s.Unix(s, r.readInt())
+}
+
+func (w *gojavaWriter) writeTime(v %s.Time) {
+	w.writeInt(v.Unix())
+	w.writeInt(int64(v.Nanosecond()))
+}
+
+func (r *gojavaReader) readDuration() %s.Duration { return %s.Duration(r.readInt()) }
+
+func (w *gojavaWriter) writeDuration(v %s.Duration) { w.writeInt(int64(v)) }
+`
+
+const convertGoIP = `
+func (r *gojavaReader) readIP() %s.IP { return %s.IP(r.readBytes()) }
+
+func (w *gojavaWriter) writeIP(v []byte) { w.writeBytes(v) }
+`
+
+const convertGoURL = `
+func (r *gojavaReader) readURL() *%s.URL {
+	b := r.readBytes()
+	if b == nil || r.err != nil {
+		return nil
+	}
+	u, err := %s.Parse(string(b))
+	if err != nil {
+		r.err = err
+	}
+	return u
+}
+
+func (w *gojavaWriter) writeURL(v *%s.URL) {
+	if v == nil {
+		w.writeBytes(nil)
+		return
+	}
+	w.writeString(v.String())
+}
+
+func (r *gojavaReader) readURLValue() %s.URL {
+	if u := r.readURL(); u != nil {
+		return *u
+	}
+	r.err = errors.New("gojava: null URI")
+	return %s.URL{}
+}
+
+func (w *gojavaWriter) writeURLValue(v %s.URL) { w.writeURL(&v) }
+`
+
+const convertGoUUID = `
+func (r *gojavaReader) readUUID() (u %s.UUID) {
+	copy(u[:], r.next(16))
+	return u
+}
+
+func (w *gojavaWriter) writeUUID(v %s.UUID) { w.b = append(w.b, v[:]...) }
+`
+
+const convertC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jbyteArray JNICALL Java_go_GoConvert_call0(JNIEnv *env, jclass clazz, jint fn, jbyteArray args) {
+	jsize n = (*env)->GetArrayLength(env, args);
+	jbyte *a = (*env)->GetByteArrayElements(env, args, NULL);
+	int size = 0;
+	char *res = gojava_convert_call(fn, (char *)a, n, &size);
+	(*env)->ReleaseByteArrayElements(env, args, a, JNI_ABORT);
+	jbyteArray out = (*env)->NewByteArray(env, size);
+	if (out != NULL) {
+		(*env)->SetByteArrayRegion(env, out, 0, size, (jbyte *)res);
+	}
+	free(res);
+	return out;
+}
+`
+
+// goConvertCallNative is the part of go.GoConvert.call calling a function
+// through JNI or GoProcess, replaced by goConvertCallWasm in bindings built
+// with -backend wasm.
+const goConvertCallNative = `		if (Go.OUT_OF_PROCESS) {
+			res = GoProcess.call(fn, w.out.toByteArray());
+		} else {
+			Go.load();
+			res = call0(fn, w.out.toByteArray());
+		}`
+
+const goConvertCallWasm = `		res = GoWasm.call(fn, w.out.toByteArray());`
+
+const goConvertJava = `package go;
+
+import java.io.ByteArrayOutputStream;
+import java.io.DataOutputStream;
+import java.io.IOException;
+import java.net.InetAddress;
+import java.net.URI;
+import java.net.UnknownHostException;
+import java.nio.ByteBuffer;
+import java.nio.charset.Charset;
+import java.time.Duration;
+import java.time.Instant;
+import java.util.UUID;
+
+// GoConvert calls the bound Go functions using time.Time, time.Duration,
+// net.IP, url.URL, uuid.UUID, images, files and connections, which gomobile
+// does not bind, encoding their arguments and results as their Java
+// counterparts.
+public final class GoConvert {
+	private static final Charset UTF_8 = Charset.forName("UTF-8");
+
+	private GoConvert() {}
+
+	// call calls the function fn with the arguments written to w, throwing
+	// the error it returns. Bindings built with -out-of-process call it in
+	// the Go server run by GoProcess, and bindings built with -backend wasm
+	// in the WebAssembly module run by GoWasm.
+	public static Reader call(int fn, Writer w) throws Exception {
+		byte[] res;
+` + goConvertCallNative + `
+		ByteBuffer b = ByteBuffer.wrap(res);
+		Reader r = new Reader(b);
+		if (b.get() != 0) {
+			throw new Exception(r.readString());
+		}
+		return r;
+	}
+
+	private static native byte[] call0(int fn, byte[] args);
+
+	// Writer encodes the arguments of a call.
+	public static final class Writer {
+		private final ByteArrayOutputStream out = new ByteArrayOutputStream();
+		private final DataOutputStream data = new DataOutputStream(out);
+
+		public void writeBool(boolean v) throws IOException {
+			data.writeBoolean(v);
+		}
+
+		public void writeInt(long v) throws IOException {
+			data.writeLong(v);
+		}
+
+		public void writeFloat(double v) throws IOException {
+			data.writeDouble(v);
+		}
+
+		public void writeBytes(byte[] v) throws IOException {
+			if (v == null) {
+				data.writeInt(-1);
+				return;
+			}
+			data.writeInt(v.length);
+			data.write(v);
+		}
+
+		public void writeString(String v) throws IOException {
+			writeBytes(v == null ? new byte[0] : v.getBytes(UTF_8));
+		}
+
+		// writeTime writes null as the zero time.Time.
+		public void writeTime(Instant v) throws IOException {
+			if (v == null) {
+				v = Instant.parse("0001-01-01T00:00:00Z");
+			}
+			data.writeLong(v.getEpochSecond());
+			data.writeLong(v.getNano());
+		}
+
+		public void writeDuration(Duration v) throws IOException {
+			data.writeLong(v == null ? 0 : v.toNanos());
+		}
+
+		public void writeIP(InetAddress v) throws IOException {
+			writeBytes(v == null ? null : v.getAddress());
+		}
+
+		public void writeURL(URI v) throws IOException {
+			writeBytes(v == null ? null : v.toString().getBytes(UTF_8));
+		}
+
+		public void writeURLValue(URI v) throws IOException {
+			writeURL(v);
+		}
+
+		// writeImage writes the pixels of v, which Go reads without converting
+		// them if it takes an image.Image or an image of the format of v.
+		public void writeImage(GoImage v) throws IOException {
+			if (v == null) {
+				data.writeByte(-1);
+				return;
+			}
+			data.writeByte(v.format().ordinal());
+			data.writeLong(v.width());
+			data.writeLong(v.height());
+			data.writeLong(v.stride());
+			writeBytes(v.pix());
+		}
+
+		public void writeRGBA(GoImage v) throws IOException {
+			writeImage(v);
+		}
+
+		public void writeNRGBA(GoImage v) throws IOException {
+			writeImage(v);
+		}
+
+		public void writeGray(GoImage v) throws IOException {
+			writeImage(v);
+		}
+
+		// writeRegion writes the path and range of v, which Go opens itself so
+		// that the contents of the file are not copied.
+		public void writeRegion(GoFileRegion v) throws IOException {
+			if (v == null) {
+				writeBytes(null);
+				return;
+			}
+			writeString(v.path());
+			data.writeLong(v.offset());
+			data.writeLong(v.length());
+		}
+
+		public void writeFile(GoFileRegion v) throws IOException {
+			writeRegion(v);
+		}
+
+		public void writeSection(GoFileRegion v) throws IOException {
+			writeRegion(v);
+		}
+
+		public void writeMapped(GoFileRegion v) throws IOException {
+			writeRegion(v);
+		}
+
+		public void writeFd(GoFd v) throws IOException {
+			data.writeLong(v == null ? -1 : v.fd());
+		}
+
+		public void writeConn(GoFd v) throws IOException {
+			writeFd(v);
+		}
+
+		public void writeListener(GoFd v) throws IOException {
+			writeFd(v);
+		}
+
+		public void writePacketConn(GoFd v) throws IOException {
+			writeFd(v);
+		}
+
+		public void writeUUID(UUID v) throws IOException {
+			if (v == null) {
+				v = new UUID(0, 0);
+			}
+			data.writeLong(v.getMostSignificantBits());
+			data.writeLong(v.getLeastSignificantBits());
+		}
+	}
+
+	// Reader decodes the results of a call.
+	public static final class Reader {
+		private final ByteBuffer b;
+
+		Reader(ByteBuffer b) {
+			this.b = b;
+		}
+
+		public boolean readBool() {
+			return b.get() != 0;
+		}
+
+		public long readInt() {
+			return b.getLong();
+		}
+
+		public double readFloat() {
+			return b.getDouble();
+		}
+
+		public byte[] readBytes() {
+			int n = b.getInt();
+			if (n < 0) {
+				return null;
+			}
+			byte[] d = new byte[n];
+			b.get(d);
+			return d;
+		}
+
+		public String readString() {
+			return new String(readBytes(), UTF_8);
+		}
+
+		public Instant readTime() {
+			return Instant.ofEpochSecond(b.getLong(), b.getLong());
+		}
+
+		public Duration readDuration() {
+			return Duration.ofNanos(b.getLong());
+		}
+
+		// readIP returns null for a nil net.IP.
+		public InetAddress readIP() throws UnknownHostException {
+			byte[] d = readBytes();
+			return d == null || d.length == 0 ? null : InetAddress.getByAddress(d);
+		}
+
+		public URI readURL() throws Exception {
+			byte[] d = readBytes();
+			return d == null ? null : new URI(new String(d, UTF_8));
+		}
+
+		public URI readURLValue() throws Exception {
+			return readURL();
+		}
+
+		public GoImage readImage() {
+			int f = b.get();
+			if (f < 0) {
+				return null;
+			}
+			int width = (int) b.getLong(), height = (int) b.getLong(), stride = (int) b.getLong();
+			return new GoImage(GoImage.Format.values()[f], width, height, stride, readBytes());
+		}
+
+		public GoImage readRGBA() {
+			return readImage();
+		}
+
+		public GoImage readNRGBA() {
+			return readImage();
+		}
+
+		public GoImage readGray() {
+			return readImage();
+		}
+
+		// readFd returns the descriptor of a connection or listener returned
+		// by Go, which belongs to the caller.
+		public GoFd readFd() {
+			long fd = b.getLong();
+			return fd < 0 ? null : new GoFd((int) fd);
+		}
+
+		public GoFd readConn() {
+			return readFd();
+		}
+
+		public GoFd readListener() {
+			return readFd();
+		}
+
+		public GoFd readPacketConn() {
+			return readFd();
+		}
+
+		public UUID readUUID() {
+			return new UUID(b.getLong(), b.getLong());
+		}
+	}
+}
+`
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const convertSrc = `package testpkg
+
+import (
+	"image"
+	"net"
+	"net/url"
+	"time"
+)
+
+type Seconds int32
+
+func After(t time.Time, d time.Duration) time.Time { return t.Add(d) }
+
+func Lookup(host string) (net.IP, error) { return nil, nil }
+
+func Parse(s []byte, n Seconds) (*url.URL, error) { return url.Parse(string(s)) }
+
+func Check(u url.URL) error { return nil }
+
+func Thumbnail(img image.Image, size int) (*image.RGBA, error) { return nil, nil }
+
+func Plain(s string) string { return s }
+
+func Chan(t time.Time) chan int { return nil }
+`
+
+func TestGenConverters(t *testing.T) {
+	p, docs := typeCheckFile(t, convertSrc)
+	var names []string
+	for _, f := range findConvertedFuncs(p, nil, docs, false) {
+		names = append(names, f.fn.Name())
+	}
+	if got, want := strings.Join(names, ","), "After,Check,Lookup,Parse,Thumbnail"; got != want {
+		t.Errorf("got converted funcs %s, want %s", got, want)
+	}
+	if f := findConvertedFuncs(p, []string{"Lookup"}, docs, false); len(f) != 1 || f[0].fn.Name() != "Lookup" {
+		t.Errorf("exposed converted funcs: got %+v", f)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte("package go.testpkg;\n\npublic abstract class Testpkg {\n    public static void touch() {}\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := genConverters(tmpDir, tmpDir, []*types.Package{p}, nil, docs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 7 || filepath.Base(files[0]) != "GoConvert.java" || filepath.Base(files[1]) != "GoImage.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(classPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"public static java.time.Instant after(java.time.Instant t, java.time.Duration d) throws Exception {",
+		"\t\tw.writeDuration(d);\n\t\tgo.GoConvert.Reader r = go.GoConvert.call(0, w);\n\t\treturn r.readTime();\n",
+		"public static void check(java.net.URI u) throws Exception {",
+		"public static java.net.InetAddress lookup(String host) throws Exception {",
+		"public static java.net.URI parse(byte[] s, int n) throws Exception {",
+		"public static go.GoImage thumbnail(go.GoImage img, long size) throws Exception {\n\t\tgo.GoConvert.Writer w = new go.GoConvert.Writer();\n\t\tw.writeImage(img);\n",
+		"\t\treturn r.readRGBA();\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Testpkg.java missing %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "gojava_convert.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil {
+		t.Errorf("gojava_convert.go: %v\n%s", err, d)
+	}
+	for _, s := range []string{
+		"\tconv1 \"example.com/testpkg\"\n",
+		"\t\ta1 := conv1.Seconds(r.readInt())\n",
+		"\t\tv, err := conv1.Parse(a0, a1)\n",
+		"func (r *gojavaReader) readURLValue() conv2.URL {",
+		"//export gojava_convert_call\n",
+		"\tconv5 \"image/draw\"\n",
+		"func (r *gojavaReader) readRGBA() *conv4.RGBA {",
+		"\tconv5.Draw(m, m.Rect, img, m.Rect.Min, conv5.Src)\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_convert.go missing %q:\n%s", s, d)
+		}
+	}
+}
+
+const convertFilesSrc = `package testpkg
+
+import (
+	"io"
+	"os"
+)
+
+// Count counts the lines of data.
+//
+//gojava:mmap data
+func Count(data []byte, sep byte) int { return 0 }
+
+func Head(f *os.File, n int) ([]byte, error) { return nil, nil }
+
+func Scan(r *io.SectionReader) error { return nil }
+
+func Create(path string) (*os.File, error) { return os.Create(path) }
+
+func Sum(data []byte) int { return 0 }
+`
+
+func TestGenConvertersFiles(t *testing.T) {
+	p, docs := typeCheckFile(t, convertFilesSrc)
+	funcs := findConvertedFuncs(p, nil, docs, false)
+	var names []string
+	for _, f := range funcs {
+		names = append(names, f.fn.Name())
+	}
+	if got, want := strings.Join(names, ","), "Count,Head,Scan"; got != want {
+		t.Errorf("got converted funcs %s, want %s", got, want)
+	}
+	if len(funcs) > 0 && strings.Join(funcs[0].mapped, ",") != "data" {
+		t.Errorf("got mapped params %v, want data", funcs[0].mapped)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte("package go.testpkg;\n\npublic abstract class Testpkg {\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := genConverters(tmpDir, tmpDir, []*types.Package{p}, nil, docs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 7 || filepath.Base(files[3]) != "GoFileRegion.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(classPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"public static long count(go.GoFileRegion data, byte sep) throws Exception {\n\t\tgo.GoConvert.Writer w = new go.GoConvert.Writer();\n\t\tw.writeMapped(data);\n",
+		"public static byte[] head(go.GoFileRegion f, long n) throws Exception {",
+		"\t\tw.writeSection(arg0);\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Testpkg.java missing %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "gojava_convert.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil {
+		t.Errorf("gojava_convert.go: %v\n%s", err, d)
+	}
+	for _, s := range []string{
+		"\t\ta0 := r.readMapped()\n",
+		"\t\ta0 := r.readFile()\n",
+		"func (r *gojavaReader) readSection() *conv2.SectionReader {",
+		"\tdefer r.close()\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_convert.go missing %q:\n%s", s, d)
+		}
+	}
+	for _, name := range []string{"gojava_mmap_unix.go", "gojava_mmap_other.go"} {
+		d, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := format.Source(d); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, d)
+		}
+	}
+}
+
+const convertFdsSrc = `package testpkg
+
+import (
+	"net"
+	"os"
+)
+
+func Serve(c net.Conn) error { return nil }
+
+func Listen(addr string) (net.Listener, error) { return net.Listen("tcp", addr) }
+
+//gojava:fd f
+func Log(f *os.File, msg string) error { return nil }
+`
+
+func TestGenConvertersFds(t *testing.T) {
+	p, docs := typeCheckFile(t, convertFdsSrc)
+	funcs := findConvertedFuncs(p, nil, docs, false)
+	if len(funcs) != 3 {
+		t.Fatalf("got converted funcs %v", funcs)
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte("package go.testpkg;\n\npublic abstract class Testpkg {\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := genConverters(tmpDir, tmpDir, []*types.Package{p}, nil, docs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 7 || filepath.Base(files[4]) != "GoFd.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(classPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"public static go.GoFd listen(String addr) throws Exception {",
+		"\t\treturn r.readListener();\n",
+		"public static void log(go.GoFd f, String msg) throws Exception {\n\t\tgo.GoConvert.Writer w = new go.GoConvert.Writer();\n\t\tw.writeFd(f);\n",
+		"public static void serve(go.GoFd c) throws Exception {",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Testpkg.java missing %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "gojava_convert.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil {
+		t.Errorf("gojava_convert.go: %v\n%s", err, d)
+	}
+	for _, s := range []string{
+		"\t\ta0 := r.readFd()\n",
+		"\t\ta0 := r.readConn()\n",
+		"\t\tw.writeListener(v)\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_convert.go missing %q:\n%s", s, d)
+		}
+	}
+	for _, name := range []string{"gojava_fd_unix.go", "gojava_fd_other.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "gojava_mmap_unix.go")); err == nil {
+		t.Error("gojava_mmap_unix.go written without mapped parameters")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultDaemonAddr is the address gojava daemon listens on by default.
+const defaultDaemonAddr = "localhost:8035"
+
+// buildRequest is the JSON body of a request to the /build endpoint of the
+// daemon. Unset options default to the flags the daemon was started with.
+type buildRequest struct {
+	Packages []string `json:"packages"`
+	Factory  *string  `json:"factory"`
+	Overload []string `json:"overload"`
+	Split    *bool    `json:"split"`
+	Main     *bool    `json:"main"`
+	Lazy     *bool    `json:"lazy"`
+}
+
+// daemon serves bind requests over HTTP. Builds are run one at a time, from
+// the working directory of the daemon, and share its go build cache and the
+// state in cache.
+type daemon struct {
+	mu    sync.Mutex
+	cfg   config
+	cache *buildCache
+}
+
+// config returns the configuration for req.
+func (d *daemon) config(req *buildRequest) *config {
+	cfg := d.cfg
+	cfg.cache = d.cache
+	if req.Factory != nil {
+		cfg.factoryPrefix = *req.Factory
+	}
+	if req.Overload != nil {
+		cfg.overloadSuffixes = listFlag(req.Overload)
+	}
+	if req.Split != nil {
+		cfg.split = *req.Split
+	}
+	if req.Main != nil {
+		cfg.bindMain = *req.Main
+	}
+	if req.Lazy != nil {
+		cfg.lazy = *req.Lazy
+	}
+	return &cfg
+}
+
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/build" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "build requests must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req buildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Packages) == 0 {
+		http.Error(w, "no packages to bind", http.StatusBadRequest)
+		return
+	}
+	cfg := d.config(&req)
+	if err := checkConfig(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jar, err := d.build(cfg, req.Packages)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/java-archive")
+	w.Write(jar)
+}
+
+// build binds the packages pkgs with cfg and returns the contents of the jar.
+func (d *daemon) build(cfg *config, pkgs []string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pkgs, err := expandPackages("", pkgs)
+	if err != nil {
+		return nil, err
+	}
+	var jar bytes.Buffer
+	cfg.out = &jar
+	verbosef("Binding %v\n", pkgs)
+	if err := bindToJar(cfg, "", pkgs...); err != nil {
+		return nil, err
+	}
+	return jar.Bytes(), nil
+}
+
+// runDaemon serves bind requests on addr, using cfg for the default options.
+func runDaemon(cfg *config, addr string) error {
+	dir, err := ioutil.TempDir("", "gojava-daemon")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	cache := &buildCache{dir: filepath.Join(dir, "build")}
+	defer cache.close()
+	fmt.Fprintf(os.Stderr, "gojava daemon listening on %s\n", addr)
+	return http.ListenAndServe(addr, &daemon{cfg: *cfg, cache: cache})
+}
+
+// buildCache holds what the daemon keeps between builds: the directory
+// builds run in, the module and packages loaded by the last build, and a
+// running Java compiler. The methods of a nil *buildCache do the work of a
+// single build.
+type buildCache struct {
+	// dir is the build directory. Building at the same paths every time
+	// lets the go command reuse its cached results for the bind packages.
+	dir string
+
+	// modKey is the digest of the go.mod, go.work and vendor/modules.txt
+	// files mod was read from.
+	modKey string
+	mod    *goModule
+
+	// pkgsKey is the digest of the sources typePkgs were loaded from.
+	pkgsKey  string
+	fset     *token.FileSet
+	typePkgs []*types.Package
+	overlay  map[string][]byte
+
+	javac *compileServer
+}
+
+// workDir empties and returns the build directory.
+func (c *buildCache) workDir() (string, func(), error) {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return "", nil, err
+	}
+	return c.dir, func() {}, nil
+}
+
+// findModule returns the module of dir, like the function of the same name,
+// reusing the last module found if its files are unchanged.
+func (c *buildCache) findModule(dir string) (*goModule, error) {
+	if c == nil {
+		return findModule(dir)
+	}
+	out, err := commandOutputIn(dir, "go", "env", "GOMOD", "GOWORK")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", dir, out)
+	if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); lines[0] != "" && lines[0] != os.DevNull {
+		files := append(lines, filepath.Join(filepath.Dir(lines[0]), "vendor", "modules.txt"))
+		for _, f := range files {
+			if d, err := ioutil.ReadFile(f); err == nil {
+				fmt.Fprintf(h, "%s %x\n", f, sha256.Sum256(d))
+			}
+		}
+	}
+	key := fmt.Sprintf("%x", h.Sum(nil))
+	if key == c.modKey {
+		return c.mod, nil
+	}
+	mod, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	c.modKey, c.mod = key, mod
+	return mod, nil
+}
+
+// loadPackages loads the packages pkgs, resolved in dir, and returns them
+// with the file set they were parsed into and the overlay of the build: if
+// bindMain is set it binds the main packages among them as libraries, and if
+// seqs is set it adds the adapters of the functions returning an iter.Seq or
+// iter.Seq2, which are then loaded too. The last packages loaded are reused
+// if pkgs and their sources are unchanged.
+func (c *buildCache) loadPackages(dir string, pkgs []string, bindMain, seqs bool) (*token.FileSet, []*types.Package, map[string][]byte, error) {
+	if c == nil {
+		var overlay map[string][]byte
+		if bindMain {
+			overlay = make(map[string][]byte)
+		}
+		fset := token.NewFileSet()
+		typePkgs, err := loadPackages(fset, dir, pkgs, overlay)
+		if err != nil || !seqs {
+			return fset, typePkgs, overlay, err
+		}
+		adapters, err := seqOverlay(fset, typePkgs)
+		if err != nil || len(adapters) == 0 {
+			return fset, typePkgs, overlay, err
+		}
+		if overlay == nil {
+			overlay = make(map[string][]byte)
+		}
+		for file, d := range adapters {
+			overlay[file] = d
+		}
+		fset = token.NewFileSet()
+		typePkgs, err = loadPackages(fset, dir, pkgs, overlay)
+		return fset, typePkgs, overlay, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %q %t %t\n", dir, pkgs, bindMain, seqs)
+	if err := sourcesDigest(h, dir, pkgs); err != nil {
+		return nil, nil, nil, err
+	}
+	key := fmt.Sprintf("%x", h.Sum(nil))
+	if key == c.pkgsKey {
+		verbosef("Reusing the packages loaded by the previous build\n")
+		return c.fset, c.typePkgs, c.overlay, nil
+	}
+	fset, typePkgs, overlay, err := (*buildCache)(nil).loadPackages(dir, pkgs, bindMain, seqs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c.pkgsKey, c.fset, c.typePkgs, c.overlay = key, fset, typePkgs, overlay
+	return fset, typePkgs, overlay, nil
+}
+
+// compiler returns the Java compiler kept running with the java launcher, or
+// nil for a single build.
+func (c *buildCache) compiler(java string) *compileServer {
+	if c == nil {
+		return nil
+	}
+	if c.javac == nil || c.javac.java != java {
+		c.close()
+		c.javac = &compileServer{java: java}
+	}
+	return c.javac
+}
+
+// close stops the Java compiler.
+func (c *buildCache) close() {
+	if c.javac != nil {
+		c.javac.stop()
+		if c.javac.dir != "" {
+			os.RemoveAll(c.javac.dir)
+		}
+	}
+}
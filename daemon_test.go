@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDaemonRequests(t *testing.T) {
+	d := &daemon{cfg: config{split: true, factoryPrefix: "create", backend: "jni", cmake: "CMakeLists.txt"}}
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"GET", "/build", "", http.StatusMethodNotAllowed},
+		{"POST", "/jar", "{}", http.StatusNotFound},
+		{"POST", "/build", "{", http.StatusBadRequest},
+		{"POST", "/build", `{"packages": []}`, http.StatusBadRequest},
+		{"POST", "/build", `{"packages": ["."], "main": true}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.code {
+			t.Errorf("%s %s %s: expected status %d, got %d", tc.method, tc.path, tc.body, tc.code, w.Code)
+		}
+	}
+
+	split := false
+	cfg := d.config(&buildRequest{Split: &split, Overload: []string{"String"}})
+	if cfg.split || cfg.factoryPrefix != "create" || len(cfg.overloadSuffixes) != 1 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if !d.cfg.split {
+		t.Error("request modified the daemon config")
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gojava-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(src, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/cached\n\ngo 1.16\n")
+	write("lib/lib.go", "package lib\n\nfunc Answer() int { return 42 }\n")
+
+	c := &buildCache{dir: filepath.Join(dir, "build")}
+	work, _, err := c.workDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(work, "stale"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if work, _, err = c.workDir(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(work, "stale")); !os.IsNotExist(err) {
+		t.Errorf("workDir did not empty the build directory: %v", err)
+	}
+
+	mod, err := c.findModule(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod == nil || mod.path != "example.com/cached" {
+		t.Fatalf("unexpected module %+v", mod)
+	}
+	if again, err := c.findModule(src); err != nil || again != mod {
+		t.Errorf("module not reused: %v", err)
+	}
+
+	fset, pkgs, _, err := c.loadPackages(src, []string{"./lib"}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Scope().Lookup("Answer") == nil {
+		t.Fatalf("unexpected packages %v", pkgs)
+	}
+	if again, _, _, err := c.loadPackages(src, []string{"./lib"}, false, true); err != nil || again != fset {
+		t.Errorf("packages not reused: %v", err)
+	}
+	write("lib/lib.go", "package lib\n\nfunc Question() string { return \"\" }\n")
+	_, pkgs, _, err = c.loadPackages(src, []string{"./lib"}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgs[0].Scope().Lookup("Question") == nil {
+		t.Error("changed package not reloaded")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// manifestName is the name of the jar manifest entry.
+const manifestName = "META-INF/MANIFEST.MF"
+
+// fileDigest returns the hex SHA-256 digest of the file at path.
+func fileDigest(path string) (string, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(d)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeManifestLine writes the header line s to b, wrapped at 72 bytes as
+// required by the jar manifest format.
+func writeManifestLine(b *bytes.Buffer, s string) {
+	for n := 72; len(s) > n; n = 71 {
+		b.WriteString(s[:n])
+		b.WriteString("\r\n ")
+		s = s[n:]
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+// jarManifest returns a jar manifest recording the SHA-256 digest of every
+// file in jarDir, in the per-entry sections also used by signed jars.
+func jarManifest(jarDir string) ([]byte, error) {
+	var b bytes.Buffer
+	writeManifestLine(&b, "Manifest-Version: 1.0")
+	writeManifestLine(&b, "Created-By: gojava")
+	b.WriteString("\r\n")
+	err := filepath.Walk(jarDir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil || info.IsDir() {
+			return walkErr
+		}
+		name, err := filepath.Rel(jarDir, path)
+		if err != nil {
+			return err
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(d)
+		writeManifestLine(&b, "Name: "+filepath.ToSlash(name))
+		writeManifestLine(&b, "SHA-256-Digest: "+base64.StdEncoding.EncodeToString(sum[:]))
+		b.WriteString("\r\n")
+		return nil
+	})
+	return b.Bytes(), err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJarManifest(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	jarDir := filepath.Join(tmpDir, "classes")
+	long := "go/" + strings.Repeat("a", 80) + ".class"
+	for _, name := range []string{"go/libgojava", long} {
+		p := filepath.Join(jarDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte("abc"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldCwd := cwd
+	defer func() { cwd = oldCwd }()
+	cwd = tmpDir
+	jar := filepath.Join(tmpDir, "test.jar")
+	if err := createJar(&config{target: jar, compression: 9, digests: true}, jarDir); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.File[0].Name != manifestName {
+		t.Fatalf("expected the manifest first, got %s", r.File[0].Name)
+	}
+	f, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(d), "\r\n") {
+		if len(line) > 72 {
+			t.Errorf("manifest line longer than 72 bytes: %q", line)
+		}
+	}
+	// Continuation lines start with a space.
+	unwrapped := strings.Replace(string(d), "\r\n ", "", -1)
+	digest := "SHA-256-Digest: ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=\r\n"
+	for _, s := range []string{
+		"Manifest-Version: 1.0\r\n",
+		"Name: go/libgojava\r\n" + digest,
+		"Name: " + long + "\r\n" + digest,
+	} {
+		if !strings.Contains(unwrapped, s) {
+			t.Errorf("manifest missing %q:\n%s", s, d)
+		}
+	}
+}
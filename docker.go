@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// dockerArgs returns args with the -in-docker flag and its value removed, so
+// they can be passed to gojava in the container.
+func dockerArgs(args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || !strings.HasPrefix(a, "-") {
+			return append(out, args[i:]...)
+		}
+		switch name := strings.TrimLeft(a, "-"); {
+		case name == "in-docker":
+			i++
+		case strings.HasPrefix(name, "in-docker="):
+		default:
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// dockerMounts returns the directories that must be mounted in the container
+// to build cfg from the current directory dir. Directories inside dir are
+// already available through its mount.
+func dockerMounts(cfg *config, dir string) []string {
+	mounts := []string{dir}
+	paths := []string{filepath.Dir(cfg.target), cfg.sourceDir, cfg.cAPI, cfg.jniArchive, cfg.cmake, cfg.springBoot, cfg.cdi, cfg.androidLifecycle}
+	for _, f := range append([]string{cfg.javacOpts, cfg.jarOpts, cfg.jarsignerOpts, cfg.provenance, cfg.sharedRuntime}, filepath.SplitList(cfg.processorPath)...) {
+		if f != "" {
+			paths = append(paths, filepath.Dir(f))
+		}
+	}
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		covered := false
+		for _, m := range mounts {
+			if rel, err := filepath.Rel(m, p); err == nil && !strings.HasPrefix(rel, "..") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			mounts = append(mounts, p)
+		}
+	}
+	return mounts
+}
+
+// runInDocker runs gojava with args in a container created from image. The
+// current directory and the other directories used by cfg are mounted at the
+// same paths, so relative paths and the output jar resolve as they would on
+// the host. On linux the running gojava binary is mounted in the container,
+// elsewhere the same version is built in the container with go run.
+func runInDocker(cfg *config, image string, args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	run := []string{"run", "--rm", "-w", dir, "-e", "HOME=/tmp"}
+	if uid := os.Getuid(); uid >= 0 {
+		run = append(run, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+	}
+	for _, m := range dockerMounts(cfg, dir) {
+		run = append(run, "-v", m+":"+m)
+	}
+	for _, v := range envVars {
+		run = append(run, "-e", v)
+	}
+	if exe, err := os.Executable(); err == nil && runtime.GOOS == "linux" {
+		run = append(run, "-v", exe+":/usr/local/bin/gojava:ro", image, "/usr/local/bin/gojava")
+	} else {
+		run = append(run, image, "go", "run", "github.com/sridharv/gojava@"+gojavaVersion())
+	}
+	verbosef("Building in docker image %s\n", image)
+	return runCommand("docker", append(run, dockerArgs(args)...)...)
+}
+
+// gojavaVersion returns the module version of the running gojava binary, or
+// latest if it is not known.
+func gojavaVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && strings.HasPrefix(info.Main.Version, "v") {
+		return info.Main.Version
+	}
+	return "latest"
+}
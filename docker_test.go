@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDockerArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args, exp []string
+	}{
+		{[]string{"-in-docker", "img", "-o", "a.jar", "build", "./..."}, []string{"-o", "a.jar", "build", "./..."}},
+		{[]string{"-v", "--in-docker=img", "build", "-in-docker"}, []string{"-v", "build", "-in-docker"}},
+	} {
+		if got := dockerArgs(tc.args); !reflect.DeepEqual(got, tc.exp) {
+			t.Errorf("dockerArgs(%v): expected %v, got %v", tc.args, tc.exp, got)
+		}
+	}
+}
+
+func TestDockerMounts(t *testing.T) {
+	cfg := &config{target: "out/lib.jar", sourceDir: "/src/java", javacOpts: "/work/javac.opts", processorPath: "/m2/ap.jar:lib/mapstruct.jar"}
+	exp := []string{"/work", "/src/java", "/m2"}
+	if got := dockerMounts(cfg, "/work"); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/doc/comment"
+	"go/token"
+	"go/types"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultGodoc is the documentation server the docs site links Go
+// declarations to.
+const defaultGodoc = "https://pkg.go.dev"
+
+// docMember is a Java member on the docs site.
+type docMember struct {
+	// Name is the name of the member and ID its anchor, its qualified name.
+	Name, ID string
+	// Java is the Java declaration of the member, or "" if it is not known.
+	Java string
+	// Go is the Go declaration it binds, GoURL its documentation and Source
+	// its file:line.
+	Go, GoURL, Source string
+	Doc               template.HTML
+}
+
+// docClass is a generated Java class on the docs site.
+type docClass struct {
+	Name      string
+	Go, GoURL string
+	Doc       template.HTML
+	Members   []docMember
+}
+
+// docPackage is the page of the Java bindings of a Go package.
+type docPackage struct {
+	ImportPath, JavaPackage, GoURL string
+	Classes                        []*docClass
+}
+
+// docSite is the data of the templates of the docs site.
+type docSite struct {
+	Packages []*docPackage
+}
+
+// runDocs runs gojava docs with args.
+func runDocs(cfg *config, args []string) error {
+	fs := flag.NewFlagSet("docs", flag.ContinueOnError)
+	godoc := fs.String("godoc", defaultGodoc, "URL of the documentation server to link Go declarations to.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: gojava docs [-godoc <url>] <dir> <pkg1> [<pkg2>...]")
+	}
+	// The site needs no Java or C toolchain, so initBuild is not called.
+	var err error
+	if cwd, err = os.Getwd(); err != nil {
+		return err
+	}
+	pkgs, err := expandPackages(cwd, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	typePkgs, err := loadPackages(fset, cwd, pkgs, nil)
+	if err != nil {
+		return err
+	}
+	return writeDocs(fs.Arg(0), newDocFinder(fset), typePkgs, cfg.split, *godoc)
+}
+
+// writeDocs writes a static HTML site documenting the Java API generated for
+// pkgs to dir: an index of the packages, a page per package with its classes
+// and members linked to the Go documentation at godoc, and a call-site report
+// listing the Go declaration every Java member calls. The doc comments are
+// read with docs.
+func writeDocs(dir string, docs *docFinder, pkgs []*types.Package, split bool, godoc string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	site := &docSite{}
+	for _, p := range pkgs {
+		site.Packages = append(site.Packages, docPackageOf(docs, p, split, strings.TrimSuffix(godoc, "/")))
+	}
+	pages := map[string]*template.Template{"index.html": docsIndex, "callsites.html": docsCallSites}
+	for name, t := range pages {
+		if err := writeDocsPage(filepath.Join(dir, name), t, site); err != nil {
+			return err
+		}
+	}
+	for _, p := range site.Packages {
+		if err := writeDocsPage(filepath.Join(dir, p.JavaPackage+".html"), docsPackage, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDocsPage(path string, t *template.Template, data interface{}) error {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+// docPackageOf returns the page of p, with the classes in the order of
+// forEachMember.
+func docPackageOf(docs *docFinder, p *types.Package, split bool, godoc string) *docPackage {
+	dp := &docPackage{ImportPath: p.Path(), JavaPackage: javaPkgName(p), GoURL: godoc + "/" + p.Path()}
+	classes := make(map[string]*docClass)
+	class := func(name string) *docClass {
+		c, ok := classes[name]
+		if !ok {
+			c = &docClass{Name: strings.Replace(name, "$", ".", -1)}
+			classes[name] = c
+			dp.Classes = append(dp.Classes, c)
+		}
+		return c
+	}
+	// The package class comes first, documented by the package.
+	class(javaPkgName(p) + "." + javaClassName(p)).GoURL = dp.GoURL
+	qual := types.RelativeTo(p)
+	forEachMember(p, split, func(cls, member string, method bool, obj types.Object) {
+		c := class(cls)
+		url := godoc + "/" + obj.Pkg().Path() + "#" + goAnchor(cls, obj)
+		if member == "" {
+			c.Go, c.GoURL, c.Doc = "type "+obj.Name(), url, docHTML(docs, obj)
+			return
+		}
+		m := docMember{
+			Name:   member,
+			ID:     c.Name + "." + member,
+			Java:   javaDecl(member, obj),
+			Go:     strings.Join(strings.Fields(types.ObjectString(obj, qual)), " "),
+			GoURL:  url,
+			Source: docSource(docs.fset, obj),
+			Doc:    docHTML(docs, obj),
+		}
+		c.Members = append(c.Members, m)
+	})
+	return dp
+}
+
+// goAnchor returns the anchor of the Go documentation of obj, a member of the
+// Java class cls.
+func goAnchor(cls string, obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if p, ok := t.(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			if n, ok := t.(*types.Named); ok {
+				return n.Obj().Name() + "." + obj.Name()
+			}
+		}
+	case *types.Var:
+		if obj.IsField() {
+			return cls[strings.LastIndexAny(cls, ".$")+1:] + "." + obj.Name()
+		}
+	}
+	return obj.Name()
+}
+
+// javaDecl returns the Java declaration of member, bound to obj, or "" if
+// its types are not known.
+func javaDecl(member string, obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		params, _, ret, throws, err := javaSignature(obj)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%s %s(%s)%s", ret, member, strings.Join(params, ", "), throws)
+	case *types.Var:
+		jt, err := javaType(obj.Type())
+		if err != nil {
+			return ""
+		}
+		if strings.HasPrefix(member, "set") {
+			return fmt.Sprintf("void %s(%s v)", member, jt)
+		}
+		return fmt.Sprintf("%s %s()", jt, member)
+	case *types.Const:
+		jt, err := javaType(obj.Type())
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("static final %s %s", jt, member)
+	}
+	return ""
+}
+
+// docHTML returns the Go doc comment of obj as HTML, as rendered by godoc.
+func docHTML(docs *docFinder, obj types.Object) template.HTML {
+	c := docs.comments(obj)
+	if c == nil {
+		return ""
+	}
+	var p comment.Parser
+	var pr comment.Printer
+	return template.HTML(pr.HTML(p.Parse(c.Text())))
+}
+
+// docSource returns the file:line of the declaration of obj, relative to the
+// working directory if it is inside it, so the site does not publish the
+// layout of the machine it was built on.
+func docSource(fset *token.FileSet, obj types.Object) string {
+	pos := fset.Position(obj.Pos())
+	if !pos.IsValid() {
+		return ""
+	}
+	file := pos.Filename
+	if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = filepath.ToSlash(rel)
+	}
+	return fmt.Sprintf("%s:%d", file, pos.Line)
+}
+
+const docsStyle = `<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; }
+code, pre { background: #f4f4f4; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 0.3em; text-align: left; vertical-align: top; }
+.member { margin-left: 1em; }
+</style>
+`
+
+var docsIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Java bindings</title>
+` + docsStyle + `</head>
+<body>
+<h1>Java bindings</h1>
+<table>
+<tr><th>Java package</th><th>Go package</th></tr>
+{{range .Packages}}<tr><td><a href="{{.JavaPackage}}.html">{{.JavaPackage}}</a></td><td><a href="{{.GoURL}}">{{.ImportPath}}</a></td></tr>
+{{end}}</table>
+<p><a href="callsites.html">Call sites</a> of the Go declarations bound by every Java member.</p>
+</body>
+</html>
+`))
+
+var docsPackage = template.Must(template.New("package").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.JavaPackage}}</title>
+` + docsStyle + `</head>
+<body>
+<p><a href="index.html">Index</a></p>
+<h1>Package {{.JavaPackage}}</h1>
+<p>Java bindings to the Go package <a href="{{.GoURL}}">{{.ImportPath}}</a>.</p>
+<ul>
+{{range .Classes}}<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{end}}</ul>
+{{range .Classes}}
+<h2 id="{{.Name}}">class {{.Name}}</h2>
+{{if .Go}}<p>Binds <a href="{{.GoURL}}"><code>{{.Go}}</code></a>.</p>{{end}}
+{{.Doc}}
+{{range .Members}}
+<h3 id="{{.ID}}">{{.Name}}</h3>
+<div class="member">
+{{if .Java}}<pre>{{.Java}}</pre>{{end}}
+<p>Binds <a href="{{.GoURL}}"><code>{{.Go}}</code></a>{{if .Source}} at {{.Source}}{{end}}.</p>
+{{.Doc}}
+</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+var docsCallSites = template.Must(template.New("callsites").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Call sites</title>
+` + docsStyle + `</head>
+<body>
+<p><a href="index.html">Index</a></p>
+<h1>Call sites</h1>
+<p>The Go declaration called by every generated Java member.</p>
+<table>
+<tr><th>Java member</th><th>Go declaration</th><th>Source</th></tr>
+{{range $p := .Packages}}{{range .Classes}}{{range .Members}}<tr><td><a href="{{$p.JavaPackage}}.html#{{.ID}}">{{.ID}}</a></td><td><a href="{{.GoURL}}"><code>{{.Go}}</code></a></td><td>{{.Source}}</td></tr>
+{{end}}{{end}}{{end}}</table>
+</body>
+</html>
+`))
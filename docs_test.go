@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const docsSrc = `package testpkg
+
+// Greet returns a greeting for name.
+func Greet(name string) (string, error) { return "hello " + name, nil }
+
+// Point is a point in the plane.
+type Point struct {
+	X int
+}
+
+// Move moves p by dx.
+func (p *Point) Move(dx int) {}
+`
+
+func TestWriteDocs(t *testing.T) {
+	p, docs := typeCheckFile(t, docsSrc)
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dp := docPackageOf(docs, p, false, defaultGodoc)
+	if len(dp.Classes) != 2 || dp.Classes[0].Name != "go.testpkg.Testpkg" || dp.Classes[1].Name != "go.testpkg.Testpkg.Point" {
+		t.Fatalf("got classes %v, want the package class and Point", dp.Classes)
+	}
+	if err := writeDocs(tmpDir, docs, []*types.Package{p}, false, defaultGodoc+"/"); err != nil {
+		t.Fatal(err)
+	}
+	pages := map[string][]string{
+		"index.html": {`<a href="go.testpkg.html">go.testpkg</a>`, `<a href="https://pkg.go.dev/example.com/testpkg">example.com/testpkg</a>`},
+		"go.testpkg.html": {
+			"<pre>String greet(String name) throws Exception</pre>",
+			`<a href="https://pkg.go.dev/example.com/testpkg#Greet"><code>func Greet(name string) (string, error)</code></a>`,
+			"<p>Greet returns a greeting for name.\n",
+			`<h2 id="go.testpkg.Testpkg.Point">class go.testpkg.Testpkg.Point</h2>`,
+			`<a href="https://pkg.go.dev/example.com/testpkg#Point.Move">`,
+			`<a href="https://pkg.go.dev/example.com/testpkg#Point.X">`,
+			"<pre>void setX(long v)</pre>",
+		},
+		"callsites.html": {`<a href="go.testpkg.html#go.testpkg.Testpkg.Point.move">go.testpkg.Testpkg.Point.move</a>`, "src.go:4"},
+	}
+	for name, want := range pages {
+		d, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", name, s, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// keptEnv holds the environment variables passed to subprocesses with
+// -clean-env, besides those named with -env.
+var keptEnv = []string{
+	"PATH", "HOME", "USER", "TMPDIR", "TEMP", "TMP",
+	"GOROOT", "GOPATH", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GO111MODULE", "GOTOOLCHAIN",
+	"GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOSUMDB", "GOINSECURE",
+	"CC", "CXX", "CGO_ENABLED", "CGO_CFLAGS", "CGO_LDFLAGS", "CCACHE_DIR", "CCACHE_CONFIGPATH", "SCCACHE_DIR",
+	"JAVA_HOME", "GOJAVA_JAVAC", "GOJAVA_ECJ", "GOJAVA_JAR", "GOJAVA_JARSIGNER",
+	"SYSTEMROOT", "USERPROFILE", "LOCALAPPDATA", "APPDATA", "PATHEXT", "COMSPEC",
+}
+
+var (
+	// cleanEnv runs subprocesses with only the keptEnv and envVars variables.
+	cleanEnv bool
+	// envVars holds the -env variables, either KEY=VALUE to set a variable or
+	// KEY to keep it with -clean-env.
+	envVars envFlag
+)
+
+// envFlag is a flag.Value holding the values of a flag that may be repeated.
+type envFlag []string
+
+func (e *envFlag) String() string {
+	return strings.Join(*e, " ")
+}
+
+func (e *envFlag) Set(v string) error {
+	*e = append(*e, v)
+	return nil
+}
+
+// commandEnv returns the environment of subprocesses, or nil to inherit the
+// environment of gojava. It is computed for every subprocess, as gojava may
+// change its own environment during the build.
+func commandEnv() []string {
+	if !cleanEnv && len(envVars) == 0 {
+		return nil
+	}
+	keep := make(map[string]bool)
+	for _, k := range keptEnv {
+		keep[k] = true
+	}
+	var set []string
+	for _, v := range envVars {
+		if strings.Contains(v, "=") {
+			set = append(set, v)
+		} else {
+			keep[v] = true
+		}
+	}
+	var env []string
+	for _, v := range os.Environ() {
+		if k := strings.SplitN(v, "=", 2)[0]; !cleanEnv || keep[k] {
+			env = append(env, v)
+		}
+	}
+	// The last value of a duplicated variable is used.
+	return append(env, set...)
+}
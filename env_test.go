@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCommandEnv(t *testing.T) {
+	defer func(clean bool, vars envFlag) {
+		cleanEnv, envVars = clean, vars
+	}(cleanEnv, envVars)
+	for _, k := range []string{"GOJAVA_TEST_KEEP", "GOJAVA_TEST_DROP"} {
+		old, ok := os.LookupEnv(k)
+		defer func(k string) {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		}(k)
+		os.Setenv(k, "1")
+	}
+	has := func(env []string, v string) bool {
+		for _, e := range env {
+			if e == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	cleanEnv, envVars = false, nil
+	if env := commandEnv(); env != nil {
+		t.Errorf("expected inherited environment, got %v", env)
+	}
+
+	envVars = envFlag{"GOJAVA_TEST_SET=a=b"}
+	env := commandEnv()
+	if !has(env, "GOJAVA_TEST_DROP=1") || env[len(env)-1] != "GOJAVA_TEST_SET=a=b" {
+		t.Errorf("unexpected environment %v", env)
+	}
+
+	cleanEnv, envVars = true, envFlag{"GOJAVA_TEST_KEEP", "PATH=/bin"}
+	env = commandEnv()
+	if has(env, "GOJAVA_TEST_DROP=1") || !has(env, "GOJAVA_TEST_KEEP=1") {
+		t.Errorf("unexpected clean environment %v", env)
+	}
+	if exp := []string{"PATH=/bin"}; !reflect.DeepEqual(env[len(env)-1:], exp) {
+		t.Errorf("expected environment to end with %v, got %v", exp, env)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// errorSource is a Go error a bound function is found to return: a sentinel
+// error variable, or a value of an error type.
+type errorSource struct {
+	// name is the name of the variable or type, qualified with its package
+	// name, and prefixed with * for pointers.
+	name string
+	// typ is set for error types, and wrapped for sentinels wrapped with
+	// fmt.Errorf and %w.
+	typ, wrapped bool
+}
+
+// tag returns the Javadoc @throws tag documenting s.
+func (s errorSource) tag() string {
+	switch {
+	case s.typ:
+		return "@throws Exception for a Go error of type {@code " + s.name + "}"
+	case s.wrapped:
+		return "@throws Exception for a Go error wrapping {@code " + s.name + "}"
+	}
+	return "@throws Exception for the Go error {@code " + s.name + "}"
+}
+
+// errorAnalyzer finds the errors returned by the functions of a package from
+// their sources. Only the errors returned directly are found: sentinels of
+// the package or of its imports, composite literals of error types, sentinels
+// wrapped with fmt.Errorf, and the errors of other functions of the package
+// whose results are returned.
+type errorAnalyzer struct {
+	p    *types.Package
+	docs *docFinder
+	// found holds the errors of the analyzed functions, and nil for those
+	// being analyzed, ending recursion.
+	found map[*types.Func][]errorSource
+}
+
+func newErrorAnalyzer(p *types.Package, docs *docFinder) *errorAnalyzer {
+	return &errorAnalyzer{p: p, docs: docs, found: make(map[*types.Func][]errorSource)}
+}
+
+// errors returns the errors fn is found to return, in the order of the return
+// statements, or nil if it returns no error or its source is not available.
+func (a *errorAnalyzer) errors(fn *types.Func) []errorSource {
+	if srcs, ok := a.found[fn]; ok {
+		return srcs
+	}
+	a.found[fn] = nil
+	res := fn.Type().(*types.Signature).Results()
+	if res.Len() == 0 || !isError(res.At(res.Len()-1).Type()) {
+		return nil
+	}
+	decl, f := a.docs.funcDecl(fn)
+	if decl == nil || decl.Body == nil {
+		return nil
+	}
+	var srcs []errorSource
+	seen := make(map[errorSource]bool)
+	add := func(found []errorSource) {
+		for _, s := range found {
+			if !seen[s] {
+				seen[s] = true
+				srcs = append(srcs, s)
+			}
+		}
+	}
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) == res.Len() {
+				add(a.expr(f, n.Results[res.Len()-1]))
+			} else if len(n.Results) == 1 {
+				if call, ok := n.Results[0].(*ast.CallExpr); ok {
+					add(a.call(f, call))
+				}
+			}
+		}
+		return true
+	})
+	a.found[fn] = srcs
+	return srcs
+}
+
+// lookup returns the package level declaration e refers to in the source
+// file f, in the package or in one of its imports.
+func (a *errorAnalyzer) lookup(f *ast.File, e ast.Expr) types.Object {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return a.p.Scope().Lookup(e.Name)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		for _, imp := range a.p.Imports() {
+			if importName(f, imp) == x.Name {
+				return imp.Scope().Lookup(e.Sel.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// qualifiedName returns the name of obj qualified with its package name.
+func qualifiedName(obj types.Object) string {
+	return obj.Pkg().Name() + "." + obj.Name()
+}
+
+// expr returns the errors found for the error value e in the file f.
+func (a *errorAnalyzer) expr(f *ast.File, e ast.Expr) []errorSource {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return a.expr(f, e.X)
+	case *ast.Ident, *ast.SelectorExpr:
+		if v, ok := a.lookup(f, e).(*types.Var); ok && v.Pkg() != nil && types.Implements(v.Type(), errorInterface) {
+			return []errorSource{{name: qualifiedName(v)}}
+		}
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+			if t, ok := a.lookup(f, lit.Type).(*types.TypeName); ok && types.Implements(types.NewPointer(t.Type()), errorInterface) {
+				return []errorSource{{name: "*" + qualifiedName(t), typ: true}}
+			}
+		}
+	case *ast.CompositeLit:
+		if t, ok := a.lookup(f, e.Type).(*types.TypeName); ok && types.Implements(t.Type(), errorInterface) {
+			return []errorSource{{name: qualifiedName(t), typ: true}}
+		}
+	case *ast.CallExpr:
+		return a.call(f, e)
+	}
+	return nil
+}
+
+// call returns the errors found for the error returned by call in the file f.
+func (a *errorAnalyzer) call(f *ast.File, call *ast.CallExpr) []errorSource {
+	switch fn := a.lookup(f, call.Fun).(type) {
+	case *types.Func:
+		if fn.Pkg() == a.p {
+			return a.errors(fn)
+		}
+		if fn.Pkg().Path() != "fmt" || fn.Name() != "Errorf" || len(call.Args) == 0 {
+			return nil
+		}
+		format, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || format.Kind != token.STRING {
+			return nil
+		}
+		if s, err := strconv.Unquote(format.Value); err != nil || !strings.Contains(s, "%w") {
+			return nil
+		}
+		var srcs []errorSource
+		for _, arg := range call.Args[1:] {
+			for _, s := range a.expr(f, arg) {
+				if !s.typ {
+					s.wrapped = true
+					srcs = append(srcs, s)
+				}
+			}
+		}
+		return srcs
+	}
+	return nil
+}
+
+// addErrorDocs adds @throws tags for the errors found to be returned by the
+// functions and methods of p to their Javadoc in the generated Java file for
+// p at path.
+func addErrorDocs(path string, p *types.Package, docs *docFinder) error {
+	a := newErrorAnalyzer(p, docs)
+	type member struct {
+		nested, name string
+		tags         []string
+	}
+	var members []member
+	forEachMember(p, false, func(class, name string, method bool, obj types.Object) {
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			return
+		}
+		var tags []string
+		for _, s := range a.errors(fn) {
+			tags = append(tags, s.tag())
+		}
+		if len(tags) > 0 {
+			members = append(members, member{nestedName(class), name, tags})
+		}
+	})
+	if len(members) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var edits []edit
+	for _, m := range members {
+		_, start, end, err := classBody(src, p, m.nested)
+		if err != nil {
+			verbosef("skipping the errors of %s.%s: %v\n", m.nested, m.name, err)
+			continue
+		}
+		for _, at := range memberDecls(src, start, end, m.name) {
+			start, end, text := javadocEdit(src, at, m.tags)
+			edits = append(edits, edit{start, end, text})
+		}
+	}
+	return ioutil.WriteFile(path, applyEdits(src, edits), 0600)
+}
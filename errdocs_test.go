@@ -0,0 +1,134 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const errorsSrc = `package testpkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type LimitError struct{ Max int }
+
+func (e *LimitError) Error() string { return "limit" }
+
+// Get gets.
+func Get(k string) (string, error) {
+	if k == "" {
+		return "", ErrNotFound
+	}
+	if len(k) > 10 {
+		return "", &LimitError{10}
+	}
+	if k == "eof" {
+		return "", fmt.Errorf("reading %s: %w", k, io.EOF)
+	}
+	return "", errors.New("other")
+}
+
+type Store struct{}
+
+func (s *Store) Load(k string) error {
+	_, err := Get(k)
+	if err != nil {
+		return err
+	}
+	return check(k)
+}
+
+func check(k string) error {
+	f := func() error { return io.ErrClosedPipe }
+	_ = f
+	return ErrNotFound
+}
+
+func Plain() error { return nil }
+`
+
+const errorsJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Store extends Seq.Proxy {
+        /** @deprecated old. */
+        @Deprecated
+        public native void load(String k) throws Exception;
+    }
+
+    /**
+     * Get gets.
+     */
+    public static native String get(String k) throws Exception;
+    public static native void plain() throws Exception;
+}
+`
+
+func TestAddErrorDocs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath, javaPath := filepath.Join(tmpDir, "p.go"), filepath.Join(tmpDir, "Testpkg.java")
+	for path, src := range map[string]string{goPath: errorsSrc, javaPath: errorsJava} {
+		if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goPath, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	p, err := conf.Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := newDocFinder(fset)
+	a := newErrorAnalyzer(p, docs)
+	var got []string
+	for _, s := range a.errors(p.Scope().Lookup("Get").(*types.Func)) {
+		got = append(got, s.tag())
+	}
+	want := []string{
+		"@throws Exception for the Go error {@code testpkg.ErrNotFound}",
+		"@throws Exception for a Go error of type {@code *testpkg.LimitError}",
+		"@throws Exception for a Go error wrapping {@code io.EOF}",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got tags\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := addErrorDocs(javaPath, p, docs); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"        /** @deprecated old.\n         * @throws Exception for the Go error {@code testpkg.ErrNotFound}\n         */\n        @Deprecated\n        public native void load",
+		"    /**\n     * Get gets.\n     * @throws Exception for the Go error {@code testpkg.ErrNotFound}\n",
+		"{@code io.EOF}\n     */\n    public static native String get",
+		"    public static native String get(String k) throws Exception;\n    public static native void plain()",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Javadoc missing %q:\n%s", s, d)
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goExample is a Go Example function of a bound package, translated to Java.
+type goExample struct {
+	// name is the name of the function without the Example prefix, e.g.
+	// Point_Move_second, and suffix the part after the documented member.
+	name, suffix string
+	// nested and member name the Java member the example shows, as in
+	// annotation.
+	nested, member string
+	// code are the Java statements of the example.
+	code []string
+	// output is the expected output, checked if hasOutput is set.
+	output    string
+	hasOutput bool
+}
+
+// findExamples returns the Example functions in the test files of p, in the
+// directory of its sources, translated to Java. Examples using Go constructs
+// without a Java translation are skipped.
+func findExamples(fset *token.FileSet, p *types.Package, split bool) ([]goExample, error) {
+	dir := ""
+	for _, name := range p.Scope().Names() {
+		if pos := fset.Position(p.Scope().Lookup(name).Pos()); pos.IsValid() {
+			dir = filepath.Dir(pos.Filename)
+			break
+		}
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+	var examples []goExample
+	tests := token.NewFileSet()
+	for _, path := range paths {
+		f, err := parser.ParseFile(tests, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		qual := ""
+		if f.Name.Name != p.Name() {
+			if qual = importName(f, p); qual == "" {
+				continue
+			}
+		}
+		for _, ex := range doc.Examples(f) {
+			e, ok := exampleMember(p, ex.Name)
+			if !ok {
+				verbosef("skipping Example%s of %s: it does not document a bound declaration\n", ex.Name, p.Path())
+				continue
+			}
+			body, ok := ex.Code.(*ast.BlockStmt)
+			if !ok {
+				continue
+			}
+			t := &exampleTranslator{p: p, split: split, qual: qual, vars: make(map[string]types.Type)}
+			if err := t.block(body); err != nil {
+				verbosef("skipping Example%s of %s: %v\n", ex.Name, p.Path(), err)
+				continue
+			}
+			e.code = t.lines
+			e.output, e.hasOutput = ex.Output, (ex.Output != "" || ex.EmptyOutput) && !ex.Unordered
+			examples = append(examples, e)
+		}
+	}
+	sort.Slice(examples, func(i, j int) bool { return examples[i].name < examples[j].name })
+	return examples, nil
+}
+
+// importName returns the name f imports p as, or "" if it does not.
+func importName(f *ast.File, p *types.Package) string {
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path != p.Path() {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return p.Name()
+	}
+	return ""
+}
+
+// exampleMember returns the example named name, without the Example prefix,
+// with the Java member it documents in p: the package class for Example,
+// the method of a function for ExampleF, the class of a type for ExampleT
+// and the method of a method for ExampleT_M.
+func exampleMember(p *types.Package, name string) (goExample, bool) {
+	e := goExample{name: name}
+	parts := strings.Split(name, "_")
+	if last := parts[len(parts)-1]; len(parts) > 1 && last != "" && !unicode.IsUpper([]rune(last)[0]) {
+		e.suffix, parts = last, parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 1:
+		if parts[0] == "" {
+			return e, true
+		}
+		switch p.Scope().Lookup(parts[0]).(type) {
+		case *types.Func:
+			e.member = finalMethodName(p, parts[0])
+			return e, true
+		case *types.TypeName:
+			e.nested = parts[0]
+			return e, true
+		}
+	case 2:
+		if tn, ok := p.Scope().Lookup(parts[0]).(*types.TypeName); ok {
+			if m, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, p, parts[1]); m != nil {
+				if _, ok := m.(*types.Func); ok {
+					e.nested, e.member = parts[0], finalMethodName(p, parts[1])
+					return e, true
+				}
+			}
+		}
+	}
+	return e, false
+}
+
+// exampleTranslator translates the body of an Example function to Java
+// statements. It handles the straight line code examples are made of: calls
+// of bound functions and methods, variables, fields, literals and fmt
+// printing, with the errors of calls becoming exceptions.
+type exampleTranslator struct {
+	p     *types.Package
+	split bool
+	// qual is the name the example refers to p by, or "" if it is in p.
+	qual string
+	// vars are the types of the variables declared by the example.
+	vars  map[string]types.Type
+	lines []string
+}
+
+// nestedRef matches the references to types nested in package classes in
+// the names returned by javaType.
+var nestedRef = regexp.MustCompile(`\bgo\.(\w+)\.\w+\.`)
+
+// javaType returns the name of the Java type of t in the Java package of p.
+func (t *exampleTranslator) javaType(typ types.Type) (string, error) {
+	jt, err := javaType(typ)
+	if err != nil {
+		return "", err
+	}
+	if t.split {
+		jt = nestedRef.ReplaceAllString(jt, "go.$1.")
+	}
+	return strings.TrimPrefix(jt, javaPkgName(t.p)+"."), nil
+}
+
+func (t *exampleTranslator) block(b *ast.BlockStmt) error {
+	for _, s := range b.List {
+		if err := t.stmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *exampleTranslator) stmt(s ast.Stmt) error {
+	switch s := s.(type) {
+	case *ast.ExprStmt:
+		if _, ok := s.X.(*ast.CallExpr); !ok {
+			return fmt.Errorf("unsupported statement")
+		}
+		x, _, err := t.expr(s.X)
+		if err != nil {
+			return err
+		}
+		t.lines = append(t.lines, x+";")
+		return nil
+	case *ast.AssignStmt:
+		return t.assign(s)
+	case *ast.IfStmt:
+		// if err != nil { ... } is left to the exception thrown by the call
+		// returning err.
+		if !isErrCheck(s.Cond) || s.Else != nil {
+			return fmt.Errorf("unsupported if statement")
+		}
+		if s.Init != nil {
+			return t.stmt(s.Init)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported statement")
+}
+
+// isErrCheck reports whether e is err != nil.
+func isErrCheck(e ast.Expr) bool {
+	b, ok := e.(*ast.BinaryExpr)
+	if !ok || b.Op != token.NEQ {
+		return false
+	}
+	x, ok1 := b.X.(*ast.Ident)
+	y, ok2 := b.Y.(*ast.Ident)
+	return ok1 && ok2 && x.Name == "err" && y.Name == "nil"
+}
+
+// isDiscard reports whether e is _ or err, whose values are not translated.
+func isDiscard(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && (id.Name == "_" || id.Name == "err")
+}
+
+func (t *exampleTranslator) assign(s *ast.AssignStmt) error {
+	if len(s.Rhs) != 1 || len(s.Lhs) > 2 || (s.Tok != token.DEFINE && s.Tok != token.ASSIGN) {
+		return fmt.Errorf("unsupported assignment")
+	}
+	if len(s.Lhs) == 2 && !isDiscard(s.Lhs[1]) {
+		return fmt.Errorf("unsupported assignment")
+	}
+	x, typ, err := t.expr(s.Rhs[0])
+	if err != nil {
+		return err
+	}
+	switch lhs := s.Lhs[0].(type) {
+	case *ast.Ident:
+		switch {
+		case isDiscard(lhs):
+			if _, ok := s.Rhs[0].(*ast.CallExpr); !ok {
+				return nil
+			}
+			t.lines = append(t.lines, x+";")
+		case s.Tok == token.DEFINE && t.vars[lhs.Name] == nil:
+			if typ == nil {
+				return fmt.Errorf("%s has no Java type", lhs.Name)
+			}
+			jt, err := t.javaType(typ)
+			if err != nil {
+				return err
+			}
+			t.vars[lhs.Name] = typ
+			t.lines = append(t.lines, fmt.Sprintf("%s %s = %s;", jt, javaIdent(lhs.Name), x))
+		default:
+			if t.vars[lhs.Name] == nil {
+				return fmt.Errorf("undeclared variable %s", lhs.Name)
+			}
+			t.lines = append(t.lines, fmt.Sprintf("%s = %s;", javaIdent(lhs.Name), x))
+		}
+		return nil
+	case *ast.SelectorExpr:
+		if s.Tok != token.ASSIGN || len(s.Lhs) != 1 {
+			return fmt.Errorf("unsupported assignment")
+		}
+		recv, f, err := t.field(lhs)
+		if err != nil {
+			return err
+		}
+		t.lines = append(t.lines, fmt.Sprintf("%s.set%s(%s);", recv, f.Name(), x))
+		return nil
+	}
+	return fmt.Errorf("unsupported assignment")
+}
+
+// pkgIdent returns the declaration in p id refers to, or nil.
+func (t *exampleTranslator) pkgIdent(e ast.Expr) types.Object {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if t.qual == "" && t.vars[e.Name] == nil {
+			return t.p.Scope().Lookup(e.Name)
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && t.qual != "" && x.Name == t.qual && t.vars[x.Name] == nil {
+			return t.p.Scope().Lookup(e.Sel.Name)
+		}
+	}
+	return nil
+}
+
+// field returns the receiver and the field of p selected by e.
+func (t *exampleTranslator) field(e *ast.SelectorExpr) (string, *types.Var, error) {
+	x, typ, err := t.expr(e.X)
+	if err != nil {
+		return "", nil, err
+	}
+	if typ == nil {
+		return "", nil, fmt.Errorf("unsupported selector %s", e.Sel.Name)
+	}
+	f, ok := lookup(typ, t.p, e.Sel.Name).(*types.Var)
+	if !ok {
+		return "", nil, fmt.Errorf("%s is not a field", e.Sel.Name)
+	}
+	return x, f, nil
+}
+
+func lookup(typ types.Type, p *types.Package, name string) types.Object {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, p, name)
+	return obj
+}
+
+// expr returns the Java expression of e and its Go type, or a nil type if it
+// has no value.
+func (t *exampleTranslator) expr(e ast.Expr) (string, types.Type, error) {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT:
+			return e.Value, types.Typ[types.UntypedInt], nil
+		case token.FLOAT:
+			return e.Value, types.Typ[types.UntypedFloat], nil
+		case token.STRING:
+			s, err := strconv.Unquote(e.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			return javaString(s), types.Typ[types.String], nil
+		}
+	case *ast.ParenExpr:
+		x, typ, err := t.expr(e.X)
+		return "(" + x + ")", typ, err
+	case *ast.Ident:
+		switch {
+		case e.Name == "true" || e.Name == "false":
+			return e.Name, types.Typ[types.Bool], nil
+		case e.Name == "nil":
+			return "null", nil, nil
+		case t.vars[e.Name] != nil:
+			return javaIdent(e.Name), t.vars[e.Name], nil
+		}
+	case *ast.SelectorExpr:
+		switch obj := t.pkgIdent(e).(type) {
+		case *types.Const:
+			return javaClassName(t.p) + "." + obj.Name(), obj.Type(), nil
+		case *types.Var:
+			return javaClassName(t.p) + ".get" + obj.Name() + "()", obj.Type(), nil
+		case nil:
+			x, f, err := t.field(e)
+			if err != nil {
+				return "", nil, err
+			}
+			return x + ".get" + f.Name() + "()", f.Type(), nil
+		}
+	case *ast.CallExpr:
+		return t.call(e)
+	case *ast.UnaryExpr:
+		if c, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND {
+			x, typ, err := t.expr(c)
+			return x, types.NewPointer(typ), err
+		}
+		if e.Op == token.SUB || e.Op == token.NOT {
+			x, typ, err := t.expr(e.X)
+			return e.Op.String() + x, typ, err
+		}
+	case *ast.CompositeLit:
+		if tn, ok := t.pkgIdent(e.Type).(*types.TypeName); ok && len(e.Elts) == 0 {
+			jt, err := t.javaType(types.NewPointer(tn.Type()))
+			if err != nil {
+				return "", nil, err
+			}
+			return "new " + jt + "()", tn.Type(), nil
+		}
+	case *ast.BinaryExpr:
+		x, xt, err := t.expr(e.X)
+		if err != nil {
+			return "", nil, err
+		}
+		y, yt, err := t.expr(e.Y)
+		if err != nil {
+			return "", nil, err
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+			return x + " " + e.Op.String() + " " + y, xt, nil
+		case token.EQL, token.NEQ, token.LSS, token.GTR, token.LEQ, token.GEQ:
+			// Java compares strings and objects by identity.
+			if _, ok := xt.(*types.Basic); !ok || isString(xt) || isString(yt) {
+				break
+			}
+			return x + " " + e.Op.String() + " " + y, types.Typ[types.Bool], nil
+		case token.LAND, token.LOR:
+			return x + " " + e.Op.String() + " " + y, types.Typ[types.Bool], nil
+		}
+	}
+	return "", nil, fmt.Errorf("unsupported expression %T", e)
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// call returns the Java expression of the call c and the Go type of its
+// result, without a trailing error.
+func (t *exampleTranslator) call(c *ast.CallExpr) (string, types.Type, error) {
+	if c.Ellipsis.IsValid() {
+		return "", nil, fmt.Errorf("unsupported variadic call")
+	}
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		x, _, err := t.expr(a)
+		if err != nil {
+			return "", nil, err
+		}
+		args[i] = x
+	}
+	if sel, ok := c.Fun.(*ast.SelectorExpr); ok {
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == "fmt" && t.vars["fmt"] == nil {
+			return printCall(sel.Sel.Name, args)
+		}
+	}
+	var recv string
+	var fn *types.Func
+	switch obj := t.pkgIdent(c.Fun).(type) {
+	case *types.Func:
+		recv, fn = javaClassName(t.p), obj
+	case *types.TypeName:
+		// Named basic types are bound as their underlying type.
+		if _, ok := obj.Type().Underlying().(*types.Basic); ok && len(args) == 1 {
+			return args[0], obj.Type(), nil
+		}
+		return "", nil, fmt.Errorf("unsupported conversion to %s", obj.Name())
+	case nil:
+		sel, ok := c.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return "", nil, fmt.Errorf("unsupported call")
+		}
+		x, typ, err := t.expr(sel.X)
+		if err != nil {
+			return "", nil, err
+		}
+		if typ == nil {
+			return "", nil, fmt.Errorf("unsupported call of %s", sel.Sel.Name)
+		}
+		m, ok := lookup(typ, t.p, sel.Sel.Name).(*types.Func)
+		if !ok {
+			return "", nil, fmt.Errorf("%s is not a method", sel.Sel.Name)
+		}
+		recv, fn = x, m
+	default:
+		return "", nil, fmt.Errorf("unsupported call")
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Variadic() || sig.Params().Len() != len(args) {
+		return "", nil, fmt.Errorf("unsupported call of %s", fn.Name())
+	}
+	res := tupleVars(sig.Results())
+	if len(res) > 0 && isError(res[len(res)-1].Type()) {
+		res = res[:len(res)-1]
+	}
+	var typ types.Type
+	switch len(res) {
+	case 0:
+	case 1:
+		typ = res[0].Type()
+	default:
+		return "", nil, fmt.Errorf("%s has several results", fn.Name())
+	}
+	return fmt.Sprintf("%s.%s(%s)", recv, finalMethodName(t.p, fn.Name()), strings.Join(args, ", ")), typ, nil
+}
+
+// printCall returns the Java expression printing args like the fmt function
+// name.
+func printCall(name string, args []string) (string, types.Type, error) {
+	switch name {
+	case "Println":
+		if len(args) == 0 {
+			return "System.out.println()", nil, nil
+		}
+		if len(args) > 1 && !strings.HasPrefix(args[0], `"`) {
+			// Concatenation starts at the first string.
+			args[0] = `"" + ` + args[0]
+		}
+		return "System.out.println(" + strings.Join(args, ` + " " + `) + ")", nil, nil
+	case "Printf":
+		if len(args) == 0 || !strings.HasPrefix(args[0], `"`) {
+			break
+		}
+		args[0] = strings.Replace(args[0], "%v", "%s", -1)
+		return "System.out.printf(" + strings.Join(args, ", ") + ")", nil, nil
+	}
+	return "", nil, fmt.Errorf("unsupported call of fmt.%s", name)
+}
+
+// javaString returns s as a Java string literal.
+func javaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// exampleJavadoc returns the lines of Javadoc showing e.
+func exampleJavadoc(e goExample) []string {
+	title := "<p>Example:"
+	if e.suffix != "" {
+		title = "<p>Example (" + e.suffix + "):"
+	}
+	lines := []string{title, "<pre>{@code"}
+	lines = append(lines, e.code...)
+	if e.hasOutput {
+		lines = append(lines, "// Output:")
+		for _, l := range strings.Split(strings.TrimSpace(e.output), "\n") {
+			lines = append(lines, "// "+l)
+		}
+	}
+	return append(lines, "}</pre>")
+}
+
+// javadocSafe reports whether lines can be put in a {@code} block: they
+// must not end the comment, and their braces must balance.
+func javadocSafe(lines []string) bool {
+	s := strings.Join(lines, "\n")
+	return !strings.Contains(s, "*/") && strings.Count(s, "{") == strings.Count(s, "}")
+}
+
+// addExamples adds examples to the Javadoc of the members they show in the
+// generated Java file for p at path, or adds Javadoc to members without.
+func addExamples(path string, p *types.Package, examples []goExample) error {
+	if len(examples) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var edits []edit
+	for _, e := range examples {
+		if !javadocSafe(e.code) {
+			verbosef("skipping Example%s of %s: it cannot be put in Javadoc\n", e.name, p.Path())
+			continue
+		}
+		decl, start, end, err := classBody(src, p, e.nested)
+		if err != nil {
+			verbosef("skipping Example%s of %s: %v\n", e.name, p.Path(), err)
+			continue
+		}
+		at := []int{decl}
+		if e.member != "" {
+			at = memberDecls(src, start, end, e.member)
+		}
+		for _, a := range at {
+			start, end, text := javadocEdit(src, a, exampleJavadoc(e))
+			edits = append(edits, edit{start, end, text})
+		}
+	}
+	return ioutil.WriteFile(path, applyEdits(src, edits), 0600)
+}
+
+// bindExamples adds the examples of p to its generated Java file at path
+// with -examples, and writes their tests with -example-tests.
+func bindExamples(cfg *config, fset *token.FileSet, path string, p *types.Package) error {
+	if !cfg.examples && cfg.exampleTests == "" {
+		return nil
+	}
+	examples, err := findExamples(fset, p, cfg.split)
+	if err != nil {
+		return err
+	}
+	if cfg.examples {
+		if err := addExamples(path, p, examples); err != nil {
+			return err
+		}
+	}
+	if cfg.exampleTests == "" {
+		return nil
+	}
+	dir := cfg.exampleTests
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cwd, dir)
+	}
+	return writeExampleTests(dir, p, examples)
+}
+
+// writeExampleTests writes a JUnit 5 test class running examples to dir, as
+// go/<pkg>/<Class>ExamplesTest.java. Examples with an Output comment check
+// the output, like go test, and the others are only compiled.
+func writeExampleTests(dir string, p *types.Package, examples []goExample) error {
+	if len(examples) == 0 {
+		return nil
+	}
+	class := javaClassName(p) + "ExamplesTest"
+	var b bytes.Buffer
+	fmt.Fprintf(&b, exampleTestsHeader, javaPkgName(p), class, p.Path(), class)
+	for _, e := range examples {
+		if !e.hasOutput {
+			b.WriteString("\t@Disabled(\"the Go example has no output to check\")\n")
+		}
+		fmt.Fprintf(&b, "\t@Test\n\tpublic void example%s() throws Exception {\n", e.name)
+		body := "\t\t"
+		if e.hasOutput {
+			b.WriteString("\t\tString gojavaOutput = capture(() -> {\n")
+			body = "\t\t\t"
+		}
+		for _, l := range e.code {
+			b.WriteString(body + l + "\n")
+		}
+		if e.hasOutput {
+			fmt.Fprintf(&b, "\t\t});\n\t\tassertEquals(%s, gojavaOutput.trim());\n", javaString(strings.TrimSpace(e.output)))
+		}
+		b.WriteString("\t}\n\n")
+	}
+	b.WriteString(exampleTestsFooter)
+	path := filepath.Join(dir, "go", p.Name(), class+".java")
+	return writeJavaFile(path, b.Bytes())
+}
+
+const exampleTestsHeader = `package %s;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+
+import java.io.ByteArrayOutputStream;
+import java.io.PrintStream;
+import org.junit.jupiter.api.Disabled;
+import org.junit.jupiter.api.Test;
+
+// %s runs the Example functions of %s, translated to Java by gojava.
+public class %s {
+`
+
+const exampleTestsFooter = `	private interface Body {
+		void run() throws Exception;
+	}
+
+	// capture returns what b prints to System.out.
+	private static String capture(Body b) throws Exception {
+		PrintStream stdout = System.out;
+		ByteArrayOutputStream out = new ByteArrayOutputStream();
+		System.setOut(new PrintStream(out, true, "UTF-8"));
+		try {
+			b.run();
+		} finally {
+			System.setOut(stdout);
+		}
+		return out.toString("UTF-8");
+	}
+}
+`
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const examplesSrc = `package testpkg
+
+type Point struct {
+	X int
+}
+
+func NewPoint(x int) (*Point, error) { return &Point{X: x}, nil }
+
+func (p *Point) Move(dx int) { p.X += dx }
+
+func Greet(name string) string { return "hello " + name }
+`
+
+const examplesTest = `package testpkg_test
+
+import (
+	"fmt"
+	"log"
+
+	tp "example.com/testpkg"
+)
+
+func ExampleGreet() {
+	fmt.Println(tp.Greet("gopher"))
+	// Output: hello gopher
+}
+
+func ExamplePoint_Move() {
+	p, err := tp.NewPoint(1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Move(2)
+	p.X = p.X * 2
+	fmt.Println("x is", p.X)
+	// Output: x is 6
+}
+
+func ExamplePoint() {
+	p := &tp.Point{}
+	fmt.Printf("%v\n", p.X)
+}
+
+func ExampleGreet_loop() {
+	for i := 0; i < 2; i++ {
+		fmt.Println(tp.Greet("gopher"))
+	}
+}
+`
+
+const examplesJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Point extends Seq.Proxy {
+        public final native long getX();
+        public final native void setX(long v);
+        public native void move(long dx);
+    }
+
+    /**
+     * Greet greets. */
+    public static native String greet(String name);
+    public static native Point newPoint(long x) throws Exception;
+}
+`
+
+func TestExamples(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath, javaPath := filepath.Join(tmpDir, "p.go"), filepath.Join(tmpDir, "Testpkg.java")
+	files := map[string]string{goPath: examplesSrc, filepath.Join(tmpDir, "p_test.go"): examplesTest, javaPath: examplesJava}
+	for path, src := range files {
+		if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goPath, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples, err := findExamples(fset, p, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []goExample{
+		{name: "Greet", member: "greet", code: []string{`System.out.println(Testpkg.greet("gopher"));`}, output: "hello gopher\n", hasOutput: true},
+		{name: "Point", nested: "Point", code: []string{"Testpkg.Point p = new Testpkg.Point();", `System.out.printf("%s\n", p.getX());`}},
+		{name: "Point_Move", nested: "Point", member: "move", code: []string{
+			"Testpkg.Point p = Testpkg.newPoint(1);",
+			"p.move(2);",
+			"p.setX(p.getX() * 2);",
+			`System.out.println("x is" + " " + p.getX());`,
+		}, output: "x is 6\n", hasOutput: true},
+	}
+	if !reflect.DeepEqual(examples, exp) {
+		t.Fatalf("got examples\n%#v\nwant\n%#v", examples, exp)
+	}
+
+	if err := addExamples(javaPath, p, examples); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"    /**\n     * Greet greets.\n     * <p>Example:\n     * <pre>{@code\n     * System.out.println(Testpkg.greet(\"gopher\"));\n     * // Output:\n     * // hello gopher\n     * }</pre>\n     */\n    public static native String greet",
+		"    /**\n     * <p>Example:\n     * <pre>{@code\n     * Testpkg.Point p = new Testpkg.Point();\n",
+		"        /**\n         * <p>Example:\n         * <pre>{@code\n         * Testpkg.Point p = Testpkg.newPoint(1);\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Javadoc missing %q:\n%s", s, d)
+		}
+	}
+
+	if err := writeExampleTests(tmpDir, p, examples); err != nil {
+		t.Fatal(err)
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "go", "testpkg", "TestpkgExamplesTest.java"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\t@Test\n\tpublic void exampleGreet() throws Exception {\n\t\tString gojavaOutput = capture(() -> {\n\t\t\tSystem.out.println(Testpkg.greet(\"gopher\"));\n\t\t});\n\t\tassertEquals(\"hello gopher\", gojavaOutput.trim());\n",
+		"\t@Disabled(\"the Go example has no output to check\")\n\t@Test\n\tpublic void examplePoint() throws Exception {\n\t\tTestpkg.Point p = new Testpkg.Point();\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("tests missing %q:\n%s", s, d)
+		}
+	}
+}
+
+func TestExampleSplitTypes(t *testing.T) {
+	p, _ := typeCheckFile(t, examplesSrc)
+	tr := &exampleTranslator{p: p, split: true}
+	if jt, err := tr.javaType(types.NewPointer(p.Scope().Lookup("Point").Type())); err != nil || jt != "Point" {
+		t.Errorf("got %q, %v, want Point", jt, err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// splitFunc splits the argument pkg.Func of gojava expose into the package
+// and the function name.
+func splitFunc(arg string) (string, string, error) {
+	i := strings.LastIndex(arg, ".")
+	if i <= strings.LastIndex(arg, "/") || i == len(arg)-1 {
+		return "", "", fmt.Errorf("%s: expected <pkg>.<Func>", arg)
+	}
+	return arg[:i], arg[i+1:], nil
+}
+
+// parseExposed returns the packages of the gojava expose arguments args, and
+// the functions to expose from each package.
+func parseExposed(args []string) ([]string, map[string][]string, error) {
+	var pkgs []string
+	funcs := make(map[string][]string)
+	for _, arg := range args {
+		pkg, fn, err := splitFunc(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := funcs[pkg]; !ok {
+			pkgs = append(pkgs, pkg)
+		}
+		funcs[pkg] = append(funcs[pkg], fn)
+	}
+	return pkgs, funcs, nil
+}
+
+// typeClosure adds the named types of p used by t to seen, with the named
+// types used by their exported fields and methods.
+func typeClosure(p *types.Package, t types.Type, seen map[*types.TypeName]bool) {
+	tuple := func(t *types.Tuple) {
+		for i := 0; i < t.Len(); i++ {
+			typeClosure(p, t.At(i).Type(), seen)
+		}
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != p || seen[obj] {
+			return
+		}
+		seen[obj] = true
+		typeClosure(p, t.Underlying(), seen)
+		var recv types.Type = t
+		if !types.IsInterface(t) {
+			recv = types.NewPointer(t)
+		}
+		ms := types.NewMethodSet(recv)
+		for i := 0; i < ms.Len(); i++ {
+			if m := ms.At(i).Obj(); m.Exported() {
+				typeClosure(p, m.Type(), seen)
+			}
+		}
+	case *types.Pointer:
+		typeClosure(p, t.Elem(), seen)
+	case *types.Slice:
+		typeClosure(p, t.Elem(), seen)
+	case *types.Array:
+		typeClosure(p, t.Elem(), seen)
+	case *types.Map:
+		typeClosure(p, t.Key(), seen)
+		typeClosure(p, t.Elem(), seen)
+	case *types.Chan:
+		typeClosure(p, t.Elem(), seen)
+	case *types.Signature:
+		tuple(t.Params())
+		tuple(t.Results())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if f := t.Field(i); f.Exported() {
+				typeClosure(p, f.Type(), seen)
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			if m := t.Method(i); m.Exported() {
+				typeClosure(p, m.Type(), seen)
+			}
+		}
+	}
+}
+
+// exposeAnnotations returns the annotations removing the members of p that
+// are not needed to call the functions funcs: members of the package class
+// other than funcs, and the types outside their type closure.
+func exposeAnnotations(p *types.Package, funcs []string) ([]annotation, error) {
+	keep := make(map[types.Object]bool)
+	closure := make(map[*types.TypeName]bool)
+	for _, name := range funcs {
+		fn, ok := p.Scope().Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() {
+			return nil, fmt.Errorf("%s has no exported function %s", p.Path(), name)
+		}
+		keep[fn] = true
+		typeClosure(p, fn.Type(), closure)
+		// The method of a function returning a sequence calls its adapter.
+		if _, ok := seqFuncOf(p, seqType(name)); ok {
+			adapter := p.Scope().Lookup(seqAdapter(name))
+			keep[adapter] = true
+			typeClosure(p, adapter.Type(), closure)
+		}
+	}
+	var anns []annotation
+	forEachMember(p, false, func(class, member string, method bool, obj types.Object) {
+		nested := nestedName(class)
+		switch {
+		case member == "":
+			if tn, ok := obj.(*types.TypeName); ok && !closure[tn] {
+				anns = append(anns, annotation{nested: nested, remove: true})
+			}
+		case nested == "" && !keep[obj]:
+			anns = append(anns, annotation{member: member, remove: true})
+		}
+	})
+	return anns, nil
+}
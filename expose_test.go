@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const exposeSrc = `package testpkg
+
+type Digest struct{ Size int }
+
+func (d *Digest) Sum() Result { return Result{} }
+
+type Result []byte
+
+type Other struct{}
+
+func Hash(b []byte) *Digest { return nil }
+
+func NewOther() *Other { return nil }
+
+const Size = 32
+
+var Count int
+`
+
+const exposeJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Digest extends Seq.Proxy {
+        public final native long getSize();
+        public final native void setSize(long v);
+        public native Result sum();
+    }
+
+    public static final class Result extends Seq.Proxy {
+    }
+
+    public static final class Other extends Seq.Proxy {
+    }
+
+    public static final long Size = 32L;
+    public static native long getCount();
+    public static native void setCount(long v);
+    public static native Digest hash(byte[] b);
+    public static native Other newOther();
+}
+`
+
+func TestExposeAnnotations(t *testing.T) {
+	p := typeCheck(t, exposeSrc)
+	if _, err := exposeAnnotations(p, []string{"Missing"}); err == nil {
+		t.Error("expected an error for a missing function")
+	}
+	anns, err := exposeAnnotations(p, []string{"Hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(annotateJava([]byte(exposeJava), p, anns))
+	for _, s := range []string{
+		"    public static final class Digest",
+		"        public native Result sum();",
+		"        public final native long getSize();",
+		"    public static final class Result",
+		"    static final class Other",
+		"    public static native Digest hash(byte[] b);",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("missing %q:\n%s", s, got)
+		}
+	}
+	for _, s := range []string{"public static final class Other", "Size = 32L", "getCount", "setCount", "newOther"} {
+		if strings.Contains(got, s) {
+			t.Errorf("unexpected %q:\n%s", s, got)
+		}
+	}
+}
+
+func TestParseExposed(t *testing.T) {
+	pkgs, funcs, err := parseExposed([]string{"example.com/a.F", "example.com/a.G", "./b.H"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pkgs, ",") != "example.com/a,./b" || strings.Join(funcs["example.com/a"], ",") != "F,G" || strings.Join(funcs["./b"], ",") != "H" {
+		t.Errorf("unexpected %v %v", pkgs, funcs)
+	}
+	for _, arg := range []string{"example.com/a", "F", "example.com/a."} {
+		if _, _, err := splitFunc(arg); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+}
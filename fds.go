@@ -0,0 +1,336 @@
+package main
+
+// convertGoFd is the source of the methods reading and writing a go.GoFd as
+// a net.Conn, net.Listener, net.PacketConn or *os.File, with the net, os and
+// syscall packages imported as %[1]s, %[2]s and %[3]s.
+const convertGoFd = `
+// readFd reads a go.GoFd as a file owning a duplicate of the descriptor, so
+// that Java can close its own. The file belongs to the function called,
+// unless reading the other arguments fails.
+func (r *gojavaReader) readFd() *%[2]s.File {
+	f := r.readFdFile()
+	if f != nil {
+		r.closeOnError(f)
+	}
+	return f
+}
+
+// readFdFile reads a go.GoFd as a file owning a duplicate of the descriptor.
+func (r *gojavaReader) readFdFile() *%[2]s.File {
+	fd := r.readInt()
+	if fd < 0 || r.err != nil {
+		return nil
+	}
+	d, err := gojavaDup(uintptr(fd))
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return %[2]s.NewFile(d, fmt.Sprintf("fd %%d", fd))
+}
+
+// closeOnError closes c when the call returns if the arguments could not be
+// read, so that the function called is not left to close it.
+func (r *gojavaReader) closeOnError(c interface{ Close() error }) {
+	r.closers = append(r.closers, func() {
+		if r.err != nil {
+			c.Close()
+		}
+	})
+}
+
+func (r *gojavaReader) readConn() %[1]s.Conn {
+	f := r.readFdFile()
+	if f == nil {
+		return nil
+	}
+	// net.FileConn duplicates the descriptor of f.
+	defer f.Close()
+	c, err := %[1]s.FileConn(f)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.closeOnError(c)
+	return c
+}
+
+func (r *gojavaReader) readListener() %[1]s.Listener {
+	f := r.readFdFile()
+	if f == nil {
+		return nil
+	}
+	defer f.Close()
+	l, err := %[1]s.FileListener(f)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.closeOnError(l)
+	return l
+}
+
+func (r *gojavaReader) readPacketConn() %[1]s.PacketConn {
+	f := r.readFdFile()
+	if f == nil {
+		return nil
+	}
+	defer f.Close()
+	c, err := %[1]s.FilePacketConn(f)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.closeOnError(c)
+	return c
+}
+
+// writeFdOf writes a blocking duplicate of the descriptor of v, which
+// belongs to Java, and closes v.
+func (w *gojavaWriter) writeFdOf(v interface{ Close() error }) {
+	defer v.Close()
+	sc, ok := v.(%[3]s.Conn)
+	if !ok {
+		w.writeInt(-1)
+		w.err = fmt.Errorf("gojava: %%T has no file descriptor", v)
+		return
+	}
+	var d uintptr
+	raw, err := sc.SyscallConn()
+	if err == nil {
+		if cerr := raw.Control(func(fd uintptr) { d, err = gojavaExport(fd) }); cerr != nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		w.writeInt(-1)
+		w.err = err
+		return
+	}
+	w.writeInt(int64(d))
+}
+
+func (w *gojavaWriter) writeConn(v %[1]s.Conn) {
+	if v == nil {
+		w.writeInt(-1)
+		return
+	}
+	w.writeFdOf(v)
+}
+
+func (w *gojavaWriter) writeListener(v %[1]s.Listener) {
+	if v == nil {
+		w.writeInt(-1)
+		return
+	}
+	w.writeFdOf(v)
+}
+
+func (w *gojavaWriter) writePacketConn(v %[1]s.PacketConn) {
+	if v == nil {
+		w.writeInt(-1)
+		return
+	}
+	w.writeFdOf(v)
+}
+`
+
+const fdUnixGo = `//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package gojava_bind
+
+import (
+	"os"
+	"syscall"
+)
+
+// gojavaDup returns a duplicate of fd that is closed on exec.
+func gojavaDup(fd uintptr) (uintptr, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	d, err := syscall.Dup(int(fd))
+	if err != nil {
+		return 0, os.NewSyscallError("dup", err)
+	}
+	syscall.CloseOnExec(d)
+	return uintptr(d), nil
+}
+
+// gojavaExport returns a duplicate of fd for Java, whose streams expect
+// blocking descriptors.
+func gojavaExport(fd uintptr) (uintptr, error) {
+	d, err := gojavaDup(fd)
+	if err != nil {
+		return 0, err
+	}
+	if err := syscall.SetNonblock(int(d), false); err != nil {
+		syscall.Close(int(d))
+		return 0, os.NewSyscallError("setnonblock", err)
+	}
+	return d, nil
+}
+`
+
+const fdOtherGo = `//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package gojava_bind
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errGojavaFd = errors.New("gojava: file descriptors cannot be passed on " + runtime.GOOS)
+
+func gojavaDup(fd uintptr) (uintptr, error) { return 0, errGojavaFd }
+
+func gojavaExport(fd uintptr) (uintptr, error) { return 0, errGojavaFd }
+`
+
+const goFdJava = `package go;
+
+import java.io.FileDescriptor;
+import java.lang.reflect.Field;
+import java.lang.reflect.Method;
+import java.nio.channels.Channel;
+
+// GoFd is an OS file descriptor passed to Go functions taking a net.Conn, a
+// net.Listener, a net.PacketConn or an *os.File named by a //gojava:fd
+// directive, or returned by them. Go gets a duplicate of the descriptor, so
+// a connection handed to Go is closed on the Java side once the call
+// returns, and the descriptors Go returns belong to Java.
+//
+// The JDK does not expose descriptors, so they are read by reflection,
+// which needs --add-opens java.base/java.io=ALL-UNNAMED and, for channels,
+// --add-opens java.base/sun.nio.ch=ALL-UNNAMED on Java 9 and later.
+public final class GoFd {
+	private final int fd;
+
+	public GoFd(int fd) {
+		if (fd < 0) {
+			throw new IllegalArgumentException("invalid file descriptor " + fd);
+		}
+		this.fd = fd;
+	}
+
+	public int fd() {
+		return fd;
+	}
+
+	// of returns the descriptor of fd, such as the result of
+	// FileInputStream.getFD.
+	public static GoFd of(FileDescriptor fd) {
+		if (!fd.valid()) {
+			throw new IllegalArgumentException("invalid file descriptor");
+		}
+		int n;
+		try {
+			Method m = android("getInt$");
+			if (m != null) {
+				n = (Integer) m.invoke(fd);
+			} else {
+				n = field(FileDescriptor.class, "fd").getInt(fd);
+			}
+		} catch (Exception e) {
+			throw new IllegalStateException("cannot read the file descriptor, run with --add-opens java.base/java.io=ALL-UNNAMED", e);
+		}
+		return new GoFd(n);
+	}
+
+	// of returns the descriptor of c, a SocketChannel, ServerSocketChannel,
+	// DatagramChannel or FileChannel.
+	public static GoFd of(Channel c) {
+		if (!c.isOpen()) {
+			throw new IllegalArgumentException("closed channel " + c);
+		}
+		FileDescriptor fd;
+		try {
+			Method m = method(c.getClass(), "getFD");
+			if (m != null) {
+				fd = (FileDescriptor) m.invoke(c);
+			} else {
+				fd = (FileDescriptor) field(c.getClass(), "fd").get(c);
+			}
+		} catch (Exception e) {
+			throw new IllegalStateException("cannot read the file descriptor of " + c.getClass().getName() + ", run with --add-opens java.base/sun.nio.ch=ALL-UNNAMED", e);
+		}
+		return of(fd);
+	}
+
+	// toFileDescriptor returns a FileDescriptor for streams over the
+	// descriptor. On Android, ParcelFileDescriptor.adoptFd(fd()) does the
+	// same.
+	public FileDescriptor toFileDescriptor() {
+		FileDescriptor d = new FileDescriptor();
+		try {
+			Method m = android("setInt$");
+			if (m != null) {
+				m.invoke(d, fd);
+			} else {
+				field(FileDescriptor.class, "fd").setInt(d, fd);
+			}
+		} catch (Exception e) {
+			throw new IllegalStateException("cannot create a FileDescriptor, run with --add-opens java.base/java.io=ALL-UNNAMED", e);
+		}
+		return d;
+	}
+
+	@Override
+	public boolean equals(Object o) {
+		return o instanceof GoFd && ((GoFd) o).fd == fd;
+	}
+
+	@Override
+	public int hashCode() {
+		return fd;
+	}
+
+	@Override
+	public String toString() {
+		return "fd " + fd;
+	}
+
+	// android returns the accessor of the descriptor of a FileDescriptor on
+	// Android, or null.
+	private static Method android(String name) {
+		for (Method m : FileDescriptor.class.getMethods()) {
+			if (m.getName().equals(name)) {
+				return m;
+			}
+		}
+		return null;
+	}
+
+	// method returns the accessible method named name of cls or its
+	// superclasses taking no arguments, or null.
+	private static Method method(Class<?> cls, String name) {
+		for (Class<?> c = cls; c != null; c = c.getSuperclass()) {
+			try {
+				Method m = c.getDeclaredMethod(name);
+				m.setAccessible(true);
+				return m;
+			} catch (NoSuchMethodException e) {
+				// Look in the superclass.
+			}
+		}
+		return null;
+	}
+
+	// field returns the accessible field named name of cls or its
+	// superclasses.
+	private static Field field(Class<?> cls, String name) throws NoSuchFieldException {
+		for (Class<?> c = cls; c != null; c = c.getSuperclass()) {
+			try {
+				Field f = c.getDeclaredField(name);
+				f.setAccessible(true);
+				return f;
+			} catch (NoSuchFieldException e) {
+				// Look in the superclass.
+			}
+		}
+		throw new NoSuchFieldException(cls.getName() + "." + name);
+	}
+}
+`
@@ -0,0 +1,184 @@
+package main
+
+// convertGoFile is the source of the methods reading a go.GoFileRegion as an
+// *os.File, an *io.SectionReader or memory-mapped []byte, with the io and os
+// packages imported as %[1]s and %[2]s.
+const convertGoFile = `
+// readRegion reads a go.GoFileRegion and opens its file, which is closed when
+// the call returns. f is nil for a null region.
+func (r *gojavaReader) readRegion() (f *%[2]s.File, off, n int64) {
+	path := r.readBytes()
+	if path == nil {
+		return nil, 0, 0
+	}
+	off, n = r.readInt(), r.readInt()
+	if r.err != nil {
+		return nil, 0, 0
+	}
+	f, err := %[2]s.Open(string(path))
+	if err != nil {
+		r.err = err
+		return nil, 0, 0
+	}
+	r.closers = append(r.closers, func() { f.Close() })
+	fi, err := f.Stat()
+	if err != nil {
+		r.err = err
+		return nil, 0, 0
+	}
+	if n < 0 {
+		n = fi.Size() - off
+	}
+	if off < 0 || n < 0 || off > fi.Size()-n {
+		r.err = fmt.Errorf("gojava: region of %%d bytes at %%d is outside %%s", n, off, path)
+		return nil, 0, 0
+	}
+	return f, off, n
+}
+
+// readFile returns the file of a region positioned at its offset.
+func (r *gojavaReader) readFile() *%[2]s.File {
+	f, off, _ := r.readRegion()
+	if f == nil {
+		return nil
+	}
+	if _, err := f.Seek(off, %[1]s.SeekStart); err != nil {
+		r.err = err
+	}
+	return f
+}
+
+func (r *gojavaReader) readSection() *%[1]s.SectionReader {
+	f, off, n := r.readRegion()
+	if f == nil {
+		return nil
+	}
+	return %[1]s.NewSectionReader(f, off, n)
+}
+
+// readMapped maps a region into memory, read-only, until the call returns.
+func (r *gojavaReader) readMapped() []byte {
+	f, off, n := r.readRegion()
+	if f == nil {
+		return nil
+	}
+	b, unmap, err := gojavaMmap(f, off, n)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.closers = append(r.closers, unmap)
+	return b
+}
+`
+
+const mmapUnixGo = `//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package gojava_bind
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// gojavaMmap maps n bytes of f at off read-only, returning the function
+// unmapping them.
+func gojavaMmap(f *os.File, off, n int64) ([]byte, func(), error) {
+	if n == 0 {
+		return []byte{}, func() {}, nil
+	}
+	// The offset of a mapping must be a multiple of the page size.
+	start := off - off%int64(os.Getpagesize())
+	size := off - start + n
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("gojava: cannot map %d bytes of %s", n, f.Name())
+	}
+	b, err := syscall.Mmap(int(f.Fd()), start, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gojava: mmap %s: %v", f.Name(), err)
+	}
+	return b[off-start : size : size], func() { syscall.Munmap(b) }, nil
+}
+`
+
+const mmapOtherGo = `//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package gojava_bind
+
+import "os"
+
+// gojavaMmap reads n bytes of f at off, where files cannot be mapped.
+func gojavaMmap(f *os.File, off, n int64) ([]byte, func(), error) {
+	b := make([]byte, n)
+	if _, err := f.ReadAt(b, off); err != nil {
+		return nil, nil, err
+	}
+	return b, func() {}, nil
+}
+`
+
+const goFileRegionJava = `package go;
+
+import java.io.File;
+
+// GoFileRegion is a range of a file passed to Go functions taking an
+// *os.File, an *io.SectionReader or a []byte named by a //gojava:mmap
+// directive. Go opens the file itself, so large files are read or mapped
+// without copying them through the JVM. The JVM does not expose file
+// descriptors, so a FileChannel is passed as the path it was opened with.
+public final class GoFileRegion {
+	private final String path;
+	private final long offset;
+	private final long length;
+
+	private GoFileRegion(String path, long offset, long length) {
+		if (offset < 0) {
+			throw new IllegalArgumentException("negative offset " + offset);
+		}
+		this.path = path;
+		this.offset = offset;
+		this.length = length;
+	}
+
+	// of returns the region of the whole of file.
+	public static GoFileRegion of(File file) {
+		return of(file, 0, -1);
+	}
+
+	// of returns the length bytes of file at offset. A negative length
+	// extends the region to the end of the file.
+	public static GoFileRegion of(File file, long offset, long length) {
+		return new GoFileRegion(file.getAbsolutePath(), offset, length);
+	}
+
+	public static GoFileRegion of(String path) {
+		return of(new File(path));
+	}
+
+	public static GoFileRegion of(String path, long offset, long length) {
+		return of(new File(path), offset, length);
+	}
+
+	public String path() {
+		return path;
+	}
+
+	public long offset() {
+		return offset;
+	}
+
+	// length returns the length of the region, or -1 if it extends to the
+	// end of the file.
+	public long length() {
+		return length < 0 ? -1 : length;
+	}
+
+	@Override
+	public String toString() {
+		return path + "[" + offset + ":" + (length < 0 ? "" : String.valueOf(offset + length)) + "]";
+	}
+}
+`
@@ -3,26 +3,371 @@ Command gojava is a tool for creating Java bindings to Go packages.
 
 Usage
 
-	gojava [-v] [-o <jar>] [-s <dir>] build [<pkg1>, [<pkg2>...]]
+	gojava [-v] [-o <jar>] [-s <dir>] [flags] build [<pkg1>, [<pkg2>...]]
 
 	This generates a jar containing Java bindings to the specified Go packages.
+	Packages may be given as import paths, directories, like ./pkg/api or
+	/src/lib, or patterns like ./... Directories outside GOPATH must be in the
+	main module or one of the modules of its go.work. Patterns skip main and
+	internal packages, which cannot be bound, with a warning. Packages at a
+	module version, like example.com/mod@v1.4.2/pkg, are downloaded into a
+	temporary module and bound from there.
 
+	gojava [flags] expose <pkg>.<Func> [<pkg>.<Func>...]
+
+	This generates a jar exposing only the given functions, and the types used by
+	their parameters and results, for embedding a single algorithm without the
+	rest of its package. The Java classes of the other types are package private.
+
+	gojava [flags] daemon [<addr>]
+
+	This serves bind requests over HTTP on addr (default localhost:8035). POST a
+	JSON object to /build with the packages to bind, and optionally factory,
+	overload, split, main and lazy to override the flags of the daemon. The
+	response is the jar, or status 400 for invalid options. Builds run one at a
+	time from the daemon's directory. The daemon keeps the packages it loaded,
+	reused while their sources are unchanged, its build directory and a running
+	Java compiler between builds.
+
+	gojava [flags] publish -repo <url> -coordinates <group:artifact:version> [publish flags] <jar>
+
+	This deploys the jar to a Maven repository such as Nexus, Artifactory or
+	GitHub Packages, with the sources and javadoc jars if given, the jars given
+	with -classifier, such as those written by -platform-jars, and a POM. A
+	minimal POM is generated if -pom is not set. MD5, SHA-1, SHA-256 and SHA-512
+	checksums are uploaded with every file, and the version is added to the
+	maven-metadata.xml of the artifact. The credentials file holds user:password,
+	defaulting to $GOJAVA_REPO_USER and $GOJAVA_REPO_PASSWORD. With -sign, or
+	-sign-key to choose the key, the jars and the POM are signed with gpg
+	($GOJAVA_GPG or gpg in $PATH) and the .asc signatures uploaded with them, as
+	required by Maven Central. The passphrase of the key is read from
+	$GOJAVA_GPG_PASSPHRASE if set.
+
+	gojava [-o <jar>] [flags] runtime
+
+	This writes the jar of the runtime classes that do not depend on the native
+	library of a binding, go.runtime.GoException, GoRejectedException,
+	GoInterceptor, GoWaitHandle and GoFuture, for jars built with
+	-shared-runtime. With Java 9 or later it holds the descriptor of the
+	go.runtime module.
+
+	gojava [flags] docs [-godoc <url>] <dir> <pkg1> [<pkg2>...]
+
+	This writes a static HTML site documenting the Java API generated for the
+	packages to dir, without building the jar: a page per package with its
+	classes and members, their Java declarations and the Go doc comments of the
+	declarations they bind, linked to the Go documentation at -godoc (default
+	https://pkg.go.dev), and callsites.html listing the Go declaration and
+	source position every Java member calls. -split is honored.
+
+	gojava [flags] replay [-cp <classpath>] <jar> <trace>
+
+	This calls the bound functions of a jar built with -record again with the
+	arguments of the calls in a trace written by go.GoRecorder, in order, and
+	prints the calls whose result or error differs from the recorded one,
+	exiting with status 1 if there are any, to check a new build of the
+	bindings, e.g. with a new Go version, against the behavior of the last
+	one. go.GoReplay.replay does the same from Java. Methods of Go values,
+	and calls passing Go values or redacted arguments, are skipped.
+
+	-allow-breaking
+	    Replace the output jar even if the bound API removes or changes members of
+	    the API recorded in it. Breaking changes are still reported.
+	-android-lifecycle string
+	    Directory to write the sources of an Android library module for the jar
+	    to, with go.android.<jar>.GoLifecycle binding services to the lifecycle
+	    of an activity or fragment, GoBitmaps converting go.GoImage to and from
+	    Bitmap if the jar binds images, and a LiveData implementing each bound
+	    interface with a single one-argument method.
+	-backend string
+	    How Java calls Go: jni, through a native library, or wasm, which builds
+	    the package functions into a WebAssembly module run in the JVM by
+	    go.GoWasm on the Chicory runtime, so the jar has no native code. Only
+	    package functions using types GoConvert encodes can be bound with wasm:
+	    packages exporting other functions, types or variables are rejected.
+	    Experimental: classfile also calls Go through a native library, but
+	    writes the class files itself instead of compiling Java sources, so
+	    the build needs no JDK. It only binds package functions and constants
+	    of basic types and []byte, and no flag adding Java sources.
+	    (default "jni")
+	-c-api string
+	    Directory to also write the native library to, with the C header gojava.h
+	    declaring the functions it exports, so C and other non-JVM code can link
+	    the same library as the jar.
+	-call-timeout value
+	    Default timeout of the calls of a bound package, as pkg=duration, e.g.
+	    example.com/store=5s. Functions and methods taking a context.Context
+	    first are passed one with the deadline. Other calls returning an error
+	    return one once the timeout expires, leaving the Go call running. May
+	    be repeated.
+	-ccache string
+	    Compiler cache to run the C compiler of cgo with when building the native
+	    library: auto, which uses ccache or sccache if found in $PATH, off, or
+	    the name or path of the program. (default "auto")
+	-cdi string
+	    Directory to write the sources of a Jakarta CDI module for the jar to, with
+	    an @ApplicationScoped bean starting each bound service with the
+	    application and stopping it in @PreDestroy, and producer methods for the
+	    services and the structs marked //gojava:config <prefix>.
+	-clean-env
+	    Run the go and Java tools with only the variables they need from the
+	    environment, such as PATH, HOME, JAVA_HOME and the GO* toolchain
+	    variables, so the build is not affected by the shell it is run from.
+	-cli value
+	    <pkg>.<Func> of a bound package returning a Go command line tool, as
+	    func() *cobra.Command or func() (*flag.FlagSet, func(args []string,
+	    stdout io.Writer) error), to generate a Java class for with a setter for
+	    each flag and a nested class for each subcommand. May be repeated.
+	-cmake string
+	    Directory to write a CMake project to, building the native library with
+	    the C compiler, compiler launcher and JNI headers of the project, as
+	    gojava builds it, e.g. to cross compile or to use ccache or distcc. Add
+	    it with add_subdirectory and link the imported target gojava.
+	-compression int
+	    Compression level of jar entries, from 0 (stored) to 9. (default -1)
+	-digests
+	    Record the SHA-256 digest of every jar entry in the jar manifest, and
+	    check the native library against its digest before loading it. The
+	    go.Go.verifyNativeLibrary method checks it on demand.
+	-env value
+	    KEY=VALUE to set in the environment of the go and Java tools, or KEY to
+	    keep KEY from the environment with -clean-env. May be repeated.
+	-example-tests string
+	    Directory to write JUnit 5 tests to, running the Example functions of the
+	    bound packages translated to Java, as go/<pkg>/<Pkg>ExamplesTest.java.
+	    Examples with an Output comment check their output, the others are only
+	    compiled.
+	-examples
+	    Add the Example functions in the tests of the bound packages, translated
+	    to Java, to the Javadoc of the members they show. Examples using more
+	    than calls, variables, fields, literals, fmt printing and error checks
+	    are skipped.
+	-export-all
+	    Export all the symbols of the native library. By default only the JNI
+	    functions are exported, and with -c-api the functions exported by the Go
+	    code, so they do not clash with other native libraries in the process.
+	-factory string
+	    Generate static factory methods on Foo for functions NewFoo and NewFooWithX,
+	    named <prefix> and <prefix>WithX. Disabled if empty.
+	-flatten-named
+	    Do not generate Java value classes, with of() and value() methods, for
+	    named basic types like type ID string. They are still bound as their
+	    underlying types.
+	-go-timeout duration
+	    Timeout for loading the bound packages and for building the native
+	    library, e.g. 10m. A hung go command fails the build. No limit if 0.
+	-ide-metadata string
+	    Path to write JSON metadata for IDE plugins and build scripts, with the
+	    jar path, the Java source roots, and the Go file and line of the
+	    declaration bound by each generated Java class and method.
+	-include-experimental
+	    Include the Go declarations marked with a //gojava:experimental or
+	    //gojava:internal directive in the Java API, annotated with
+	    @go.Experimental or @go.Internal. Without this they are left out.
+	-in-docker string
+	    Docker image to run the build in. The image must provide go, a C compiler
+	    and a JDK. The current directory and the output directory are mounted at
+	    the same paths in the container.
+	-intercept
+	    Call the go.GoInterceptor registered with go.Go.setInterceptor before
+	    and after every call to a bound function or method, with the method name,
+	    arguments, duration and exception, e.g. for metrics or auditing. The
+	    go.GoMetrics interceptor counts the calls, errors, time and argument bytes
+	    of each method. The gojava.maxCalls system property limits the calls in
+	    flight at once.
+	-jar string
+	    Path to the jar tool. If set it is used to create the jar, instead of writing
+	    the jar directly.
+	-jar-opts string
+	    Options file passed to the jar tool.
+	-jar-timeout duration
+	    Timeout for creating and signing the jar. No limit if 0.
+	-jarsigner string
+	    Path to jarsigner. Defaults to $GOJAVA_JARSIGNER, $JAVA_HOME/bin/jarsigner or
+	    jarsigner in $PATH.
+	-jarsigner-opts string
+	    Options file with the keystore options passed to jarsigner. The jar is only
+	    signed if this is set.
+	-javac string
+	    Path to the Java compiler. Defaults to $GOJAVA_JAVAC, $JAVA_HOME/bin/javac or
+	    javac in $PATH. For ecj, $GOJAVA_ECJ or ecj in $PATH. This may be the ecj jar.
+	-javac-flags string
+	    Flags passed to the Java compiler, separated by spaces, after the options
+	    in -javac-opts.
+	-javac-impl string
+	    Java compiler to use, javac, ecj or tools. tools compiles in a single java
+	    process with the javax.tools API and requires Java 11. (default "javac")
+	-javac-opts string
+	    Options file passed to javac.
+	-javac-timeout duration
+	    Timeout for compiling the Java sources. No limit if 0.
+	-javapkg string
+	    Root Java package of the bindings, e.g. com.example.bindings, instead of
+	    go: the runtime classes are in it and the classes of each bound package
+	    in its subpackage named after the Go package, with their JNI functions
+	    and the resources of the jar. The Java sources added with -s are moved
+	    too. Cannot be used with -spring-boot, -cdi, -android-lifecycle or
+	    -example-tests.
+	-javapkg-for value
+	    Java package of the classes of a bound package, as pkg=javapkg, e.g.
+	    example.com/store=com.example.store, instead of its subpackage of the
+	    root Java package. May be repeated. Cannot be used with -jni-archive.
+	-jfr
+	    Generate go.GoJfr, an interceptor committing a go.Call JDK Flight Recorder
+	    event for every bound call, and go.GoProfiler, which writes a pprof CPU
+	    profile of the Go code spanned by a go.CPUProfile event, to line the Go
+	    samples up with a recording. Requires Java 11. Implies -intercept.
+	-jni-archive string
+	    Directory to write the bindings to for existing JNI build systems, such
+	    as CMake or Gradle externalNativeBuild, instead of the jar: the Go code
+	    as the static library libgojava.a, the C headers gojava.h and seq.h,
+	    gojava_jni.h and gojava_jni.c with gojava_register_natives to call from
+	    JNI_OnLoad, and the Java sources under java/.
+	-kotlinc string
+	    Path to the Kotlin compiler, for the .kt files in -s. Defaults to
+	    $GOJAVA_KOTLINC or kotlinc in $PATH.
+	-lazy
+	    Only load the native library when go.Go.load() is called, instead of when a
+	    generated class is first used.
+	-main
+	    Bind the exported functions and types of main packages, built in place as
+	    library packages with go build -overlay. Without this main packages are
+	    rejected.
+	-memory-limits
+	    Generate go.GoMemory, an interceptor counting the argument bytes marshaled
+	    to Go and the bytes allocated in the Go heap by each call, and throwing
+	    go.GoResourceExhausted when a call marshals or allocates too many bytes.
+	    Implies -intercept.
+	-metrics string
+	    Generate go.GoMicrometer, an interceptor publishing the latency, outcome
+	    and argument bytes of every bound call to a Micrometer MeterRegistry. The
+	    only supported value is micrometer, which must be on the javac classpath,
+	    e.g. with -javac-opts. Implies -intercept.
 	-o string
-	    Path to write the generated jar file. (default "libgojava.jar")
+	    Path to write the generated jar file, or - to write it to stdout. Progress
+	    and verbose output then go to stderr. (default "libgojava.jar")
+	-out-of-process
+	    Call the package functions in a Go server run as a child process,
+	    talking to it over its stdin and stdout, instead of loading the native
+	    library. The server is added to the jar. Only functions using basic
+	    types, []byte and the types bound through go.GoConvert can be bound:
+	    packages exporting other functions, types or variables are rejected.
+	    Cannot be used with -intercept.
+	-overload value
+	    Comma separated function name suffixes (e.g. String,Bytes). Functions that
+	    differ only by one of these suffixes are also exposed as overloaded methods.
+	-platform-jars
+	    Write the native library to a separate jar next to the output jar, named
+	    with the classifier of the target platform following the os-maven-plugin
+	    convention, e.g. libgojava-linux-x86_64.jar. Build once per platform with
+	    GOOS and GOARCH set, and depend on the jar with the classifier of each
+	    platform alongside the main jar. Cannot be used with -digests.
+	-proc string
+	    Annotation processing policy passed to javac as -proc:none or -proc:full.
+	    Defaults to that of javac, which from Java 23 only runs processors given
+	    with -processorpath.
+	-processorpath string
+	    Class path of the annotation processors run when compiling the Java
+	    sources, such as AutoValue or MapStruct for wrapper layers in -s. It is
+	    also added to the class path for the annotations they process. The
+	    sources they generate are compiled but not included in the jar.
+	-provenance string
+	    Path to write SLSA provenance for the jar to, as an in-toto statement
+	    recording gojava and its arguments, the Go version, the git commits of
+	    the bound packages and the Go modules built into the native library.
+	-record
+	    Generate go.GoRecorder, an interceptor writing the arguments and result
+	    or exception of every bound call to a file as JSON lines, for replay
+	    tests and debugging, with a hook to redact sensitive values, and
+	    go.GoReplay, which replays them. Implies -intercept and -registry.
+	-registry
+	    Add the go.GoPackages class to the jar, listing the bound packages with
+	    descriptors of their functions, types and methods, which can be called
+	    through them without reflection.
 	-s string
-	    Additional path to scan for Java source code. These files will be compiled and
-	    included in the final jar.
-	-v  Verbose output.
+	    Additional path to scan for Java, Kotlin and Scala source code. These
+	    files will be compiled and included in the final jar. Each file must
+	    declare the package of its directory in the path: go, or go.foo.bar for
+	    a file in foo/bar. If the jar at -o was built from the same Go code and
+	    flags, only these files are compiled and the jar is repacked.
+	-s-collisions string
+	    What to do with a file in -s at the path of a generated Java source: error,
+	    override it with a warning, or merge the imports and members of its class
+	    into the generated class. (default "error")
+	-sbom
+	    Add a CycloneDX software bill of materials to the jar, at
+	    META-INF/sbom/gojava.cdx.json, listing the Go toolchain, the Go modules
+	    the bound packages were built with and their dependencies, and the Java
+	    sources included with -s.
+	-scalac string
+	    Path to the Scala compiler, for the .scala files in -s. Defaults to
+	    $GOJAVA_SCALAC or scalac in $PATH.
+	-scripting
+	    Add the go.GoScripting class to the jar, which puts the bound packages
+	    in JSR-223 script engines to call their functions by name. Implies
+	    -registry.
+	-shared-runtime string
+	    Path of a runtime jar written by gojava runtime. The jar then uses its
+	    runtime classes, in the go.runtime package, instead of its own, so an
+	    application using bindings built by different teams has a single copy
+	    of them. Java sources in -s refer to them as go.GoException and so on.
+	    The classes calling the native library, go.Seq, go.LoadJNI and go.Go,
+	    stay in the jar, which needs -version-suffix to be loaded with other
+	    bindings.
+	-sign-alias string
+	    Keystore alias used to sign the jar.
+	-source-map
+	    Add a map from the generated Java methods to the Go file and line they
+	    bind to the jar, and the go.SourceMap class, whose remap method rewrites
+	    stack traces to point at the Go sources.
+	-split
+	    Generate each bound type as a top level class in its own file, instead of
+	    nesting all types in the package class.
+	-split-gocache
+	    Build the native library with a separate Go build cache for each GOOS
+	    and GOARCH, in a gojava directory of the default one, so CI can save and
+	    restore the cache of each platform separately.
+	-spring-boot string
+	    Directory to write the sources of a Spring Boot auto-configuration module
+	    for the jar to, binding structs marked //gojava:config <prefix> to
+	    configuration properties and starting the bound services as beans.
+	-store-native
+	    Store the native library in the jar uncompressed, so it is faster to extract.
+	-strict-java
+	    Compile the Java sources with all javac lint checks and -Werror, for
+	    consumers with strict build policies. The checks about the build rather
+	    than the code, options, processing and, from Java 21, this-escape, are
+	    left out. Cannot be used with -javac-impl ecj.
+	-tensors
+	    Add go.GoFloatTensor and go.GoDoubleTensor to the jar, holding a shape
+	    and a row-major float[] or double[] to pass to bound functions taking a
+	    []float32 or []float64 and a []int64 shape, and converting strided and
+	    column-major arrays.
+	-v  Verbose output. The output of the go and Java tools is streamed as they run.
+	-version-suffix string
+	    Move the bindings from the go Java package and its subpackages to
+	    go_<suffix>, or from the -javapkg package to <javapkg>_<suffix>, with
+	    their JNI functions and the resources of the jar, so that bindings of
+	    two versions of the same Go packages can be loaded side by side in one
+	    JVM, e.g. go_v2.Seq and go_v2.mypkg.Mypkg. The Java sources added with
+	    -s are moved too. Cannot be used with -spring-boot, -cdi,
+	    -android-lifecycle or -example-tests.
+	-vulncheck
+	    Run govulncheck on the bound packages before building, and fail if they
+	    call functions with known vulnerabilities. With -vulncheck=warn they are
+	    only reported. govulncheck is $GOJAVA_GOVULNCHECK or found in $PATH.
 */
 package main
 
 import (
+	"bytes"
+	"errors"
 	"go/build"
+	"path"
 	"path/filepath"
 	"reflect"
 
 	"fmt"
-	"go/importer"
 	"go/token"
 	"go/types"
 	"os"
@@ -32,7 +377,10 @@ import (
 	"io/ioutil"
 
 	"archive/zip"
+	"compress/flate"
+	"io"
 	"runtime"
+	"time"
 
 	"flag"
 
@@ -40,31 +388,88 @@ import (
 )
 
 func runCommand(cmd string, args ...string) error {
-	if out, err := exec.Command(cmd, args...).CombinedOutput(); err != nil {
-		return fmt.Errorf("%s %s: %v: %s", cmd, strings.Join(args, " "), err, string(out))
+	return runCommandIn("", cmd, args...)
+}
+
+// runCommandIn runs cmd in dir, or the current directory if dir is empty. In
+// verbose mode the output of cmd is also streamed to stdout as it is written.
+func runCommandIn(dir, cmd string, args ...string) error {
+	return runCommandEnv(dir, commandEnv(), cmd, args...)
+}
+
+// runCommandEnv is like runCommandIn, but runs cmd with the environment env.
+func runCommandEnv(dir string, env []string, cmd string, args ...string) error {
+	c := exec.CommandContext(buildCtx, cmd, args...)
+	c.Dir = dir
+	c.Env = env
+	var out bytes.Buffer
+	w := verboseWriter(&out, cmd)
+	c.Stdout, c.Stderr = w, w
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", cmd, strings.Join(args, " "), err, out.String())
 	}
 	return nil
 }
 
+// verboseWriter returns a writer to w that in verbose mode also writes to stdout,
+// prefixing each line with the name of cmd.
+func verboseWriter(w io.Writer, cmd string) io.Writer {
+	if !verbose {
+		return w
+	}
+	return io.MultiWriter(w, &prefixWriter{w: logOut, prefix: "[" + filepath.Base(cmd) + "] ", bol: true})
+}
+
+// prefixWriter writes prefix at the start of every line written to w.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	bol    bool
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var out []byte
+	for _, c := range b {
+		if p.bol {
+			out = append(out, p.prefix...)
+		}
+		out = append(out, c)
+		p.bol = c == '\n'
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
 var javaHome = os.Getenv("JAVA_HOME")
 var cwd string
 var verbose = false
 
+// logOut receives progress and verbose output. It is stderr when the jar is
+// written to stdout.
+var logOut io.Writer = os.Stdout
+
 func verbosef(format string, a ...interface{}) {
 	if !verbose {
 		return
 	}
-	fmt.Printf(format, a...)
+	fmt.Fprintf(logOut, format, a...)
 }
 
-func initBuild() (string, func(), error) {
-	if javaHome == "" {
+// initBuild returns the build directory and the function removing it. If jdk
+// is set, the build needs $JAVA_HOME.
+func initBuild(cache *buildCache, jdk bool) (string, func(), error) {
+	if jdk && javaHome == "" {
 		return "", nil, fmt.Errorf("$JAVA_HOME not set")
 	}
 	var err error
 	if cwd, err = os.Getwd(); err != nil {
 		return "", nil, err
 	}
+	if cache != nil {
+		return cache.workDir()
+	}
 	tmpDir, err := ioutil.TempDir("", "gojava")
 	if err != nil {
 		return "", nil, err
@@ -73,29 +478,73 @@ func initBuild() (string, func(), error) {
 		if err := os.RemoveAll(tmpDir); err != nil {
 			fmt.Fprintln(os.Stderr, "failed to remove temp dir:", tmpDir, err)
 		}
-		if err := os.Chdir(cwd); err != nil {
-			fmt.Fprintln(os.Stderr, "failed to change to dir:", cwd, err)
-		}
 	}, nil
 }
 
-func loadExportData(pkgs []string) ([]*types.Package, error) {
-	// Load export data for the packages
-	if err := runCommand("go", append([]string{"install"}, pkgs...)...); err != nil {
-		return nil, err
+// canonicalImportPath returns the import path of the package path, which may
+// be a directory, absolute or relative to srcDir. Symlinks in the package
+// directory are resolved and it is matched against each GOPATH entry in order,
+// so that packages in symlinked work trees get the same import path as they
+// would in GOPATH. Directories outside GOPATH are resolved by the go tool, in
+// the main module or the modules of its go.work.
+func canonicalImportPath(path, srcDir string) (string, error) {
+	if filepath.IsAbs(path) {
+		// go/build only finds directories given as local imports.
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return "", err
+		}
+		path = "./" + filepath.ToSlash(rel)
 	}
-	typePkgs := make([]*types.Package, len(pkgs))
-
-	for i, p := range pkgs {
-		buildPkg, err := build.Import(p, cwd, build.AllowBinary)
+	pkg, err := buildContext(srcDir).Import(path, srcDir, build.FindOnly)
+	if err != nil {
+		return "", err
+	}
+	if pkg.Goroot {
+		return pkg.ImportPath, nil
+	}
+	dir, err := filepath.EvalSymlinks(pkg.Dir)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range filepath.SplitList(build.Default.GOPATH) {
+		src, err := filepath.EvalSymlinks(filepath.Join(root, "src"))
 		if err != nil {
-			return nil, err
+			continue
 		}
-		if typePkgs[i], err = importer.Default().Import(buildPkg.ImportPath); err != nil {
-			return nil, err
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+			continue
 		}
+		return filepath.ToSlash(rel), nil
+	}
+	if build.IsLocalImport(pkg.ImportPath) || strings.HasPrefix(pkg.ImportPath, "_") {
+		return moduleImportPath(path, dir, srcDir)
+	}
+	return pkg.ImportPath, nil
+}
+
+// buildContext returns the default build context, with the go command it runs
+// to find packages in module mode run in dir rather than the current
+// directory.
+func buildContext(dir string) *build.Context {
+	ctxt := build.Default
+	ctxt.Dir = dir
+	return &ctxt
+}
+
+// moduleImportPath returns the import path of the package in dir, given as
+// path on the command line, with go list run in srcDir.
+func moduleImportPath(path, dir, srcDir string) (string, error) {
+	out, err := commandOutputIn(srcDir, "go", "list", "-f", "{{.ImportPath}}", dir)
+	if err != nil {
+		return "", fmt.Errorf("%s: directory %s is not in GOPATH, the main module or its go.work: %v", path, dir, err)
+	}
+	importPath := strings.TrimSpace(string(out))
+	if importPath == "" || build.IsLocalImport(importPath) || strings.HasPrefix(importPath, "_") {
+		return "", fmt.Errorf("%s: directory %s is not in GOPATH, the main module or its go.work", path, dir)
 	}
-	return typePkgs, nil
+	return importPath, nil
 }
 
 func createDirs(dirs ...string) error {
@@ -107,8 +556,9 @@ func createDirs(dirs ...string) error {
 	return nil
 }
 
-func bindPackages(bindDir, javaDir string, pkgs []*types.Package) ([]string, error) {
-	fs, javaFiles := token.NewFileSet(), make([]string, 0)
+func bindPackages(cfg *config, fs *token.FileSet, bindDir, javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, timeouts map[*types.Package]time.Duration) ([]string, error) {
+	javaFiles, cFiles := make([]string, 0), make([]string, 0)
+	docs := newDocFinder(fs)
 	for _, p := range pkgs {
 		goFile := filepath.Join(bindDir, "go_"+p.Name()+"main.go")
 		f, err := os.OpenFile(goFile, os.O_CREATE|os.O_RDWR, 0600)
@@ -122,54 +572,110 @@ func bindPackages(bindDir, javaDir string, pkgs []*types.Package) ([]string, err
 		if err := f.Close(); err != nil {
 			return nil, err
 		}
+		if d, ok := timeouts[p]; ok {
+			if err := addCallTimeouts(goFile, p, d); err != nil {
+				return nil, err
+			}
+		}
 		javaFile := strings.Title(p.Name()) + ".java"
 		if err := bindJava(javaDir, javaFile, conf, int(bind.Java)); err != nil {
 			return nil, err
 		}
+		if err := addFactories(filepath.Join(javaDir, javaFile), p, cfg.factoryPrefix); err != nil {
+			return nil, err
+		}
+		if err := addOverloads(filepath.Join(javaDir, javaFile), p, cfg.overloadSuffixes); err != nil {
+			return nil, err
+		}
+		if err := addStringers(filepath.Join(javaDir, javaFile), p); err != nil {
+			return nil, err
+		}
+		if err := addComparables(filepath.Join(javaDir, javaFile), p); err != nil {
+			return nil, err
+		}
+		if err := addIterators(filepath.Join(javaDir, javaFile), p); err != nil {
+			return nil, err
+		}
+		if err := addSeqFuncs(filepath.Join(javaDir, javaFile), p, docs); err != nil {
+			return nil, err
+		}
 		if err := bindJava(bindDir, "java_"+p.Name()+".c", conf, int(bind.JavaC)); err != nil {
 			return nil, err
 		}
 		if err := bindJava(bindDir, p.Name()+".h", conf, int(bind.JavaH)); err != nil {
 			return nil, err
 		}
+		pkgCFiles := []string{filepath.Join(bindDir, "java_"+p.Name()+".c"), filepath.Join(bindDir, p.Name()+".h")}
+		if err := mangleNonASCII(append([]string{goFile}, pkgCFiles...)); err != nil {
+			return nil, err
+		}
+		if err := escapeReserved(p, filepath.Join(javaDir, javaFile), pkgCFiles); err != nil {
+			return nil, err
+		}
+		if err := addWaitHandles(filepath.Join(javaDir, javaFile), p); err != nil {
+			return nil, err
+		}
+		limits, err := findLimits(p, docs)
+		if err != nil {
+			return nil, err
+		}
+		if err := wrapNatives(filepath.Join(javaDir, javaFile), p, pkgCFiles, cfg.intercept, limits); err != nil {
+			return nil, err
+		}
+		if err := bindExamples(cfg, fs, filepath.Join(javaDir, javaFile), p); err != nil {
+			return nil, err
+		}
+		if err := annotatePackage(filepath.Join(javaDir, javaFile), p, docs, cfg.includeUnstable, exposed[p]); err != nil {
+			return nil, err
+		}
+		if err := addErrorDocs(filepath.Join(javaDir, javaFile), p, docs); err != nil {
+			return nil, err
+		}
 		javaFiles = append(javaFiles, filepath.Join(javaDir, javaFile))
+		cFiles = append(cFiles, pkgCFiles...)
+		builders, err := genBuilders(javaDir, p)
+		if err != nil {
+			return nil, err
+		}
+		javaFiles = append(javaFiles, builders...)
+		if !cfg.flattenNamed {
+			values, err := genValueTypes(javaDir, p)
+			if err != nil {
+				return nil, err
+			}
+			javaFiles = append(javaFiles, values...)
+		}
 	}
-	return javaFiles, nil
+	if !cfg.split {
+		return javaFiles, nil
+	}
+	return splitPackages(pkgs, javaDir, javaFiles, cFiles)
 }
 
-func addExtraFiles(javaDir, sourceDir string) ([]string, error) {
-	if sourceDir == "" {
-		return nil, nil
-	}
-	var extraFiles []string
-	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		if info.IsDir() {
-			return nil
-		}
-		fileName, err := filepath.Rel(sourceDir, path)
+// splitPackages moves the types in each generated package class into their own
+// files and updates all references to them in javaFiles and cFiles. It returns
+// the complete list of Java files.
+func splitPackages(pkgs []*types.Package, javaDir string, javaFiles, cFiles []string) ([]string, error) {
+	renames := make([][]string, len(pkgs))
+	for i, p := range pkgs {
+		files, names, err := splitPackageClass(filepath.Join(javaDir, javaClassName(p)+".java"), p)
 		if err != nil {
-			return err
-		}
-		if strings.HasSuffix(fileName, ".java") {
-			p := filepath.Join(javaDir, fileName)
-			extraFiles = append(extraFiles, p)
-			return copyFile(p, path)
+			return nil, err
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		javaFiles, renames[i] = append(javaFiles, files...), names
 	}
-	if len(extraFiles) == 0 {
-		verbosef("warning: argument -s was passed on command line, but no .java files were found in '%s'\n", sourceDir)
+	for i, p := range pkgs {
+		if err := renameSplitTypes(p, renames[i], append(javaFiles, cFiles...)); err != nil {
+			return nil, err
+		}
 	}
-	return extraFiles, nil
+	return javaFiles, nil
 }
 
-func createSupportFiles(bindDir, javaDir, mainFile string) error {
+// createSupportFiles copies the gomobile support files to bindDir and javaDir
+// and writes the main package for the shared library to mainFile. If mod is
+// not nil the bind package is built as a module depending on mod.
+func createSupportFiles(bindDir, javaDir, mainFile string, mod *goModule) error {
 	bindPkg, err := build.Import(reflect.TypeOf(bind.ErrorList{}).PkgPath(), "", build.FindOnly)
 	if err != nil {
 		return err
@@ -186,7 +692,14 @@ func createSupportFiles(bindDir, javaDir, mainFile string) error {
 	if err := copyFiles(toCopy); err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(mainFile, []byte(fmt.Sprintf(javaMain, bindPkg.ImportPath)), 0600); err != nil {
+	bindImport := ".."
+	if mod != nil {
+		bindImport = bindModulePath
+		if err := mod.writeBindModule(bindDir, path.Dir(bindPkg.ImportPath), filepath.Dir(bindPkg.Dir)); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(mainFile, []byte(fmt.Sprintf(javaMain, bindPkg.ImportPath, bindImport)), 0600); err != nil {
 		return err
 	}
 	inc1, inc2 := filepath.Join(javaHome, "include"), filepath.Join(javaHome, "include", runtime.GOOS)
@@ -195,50 +708,177 @@ func createSupportFiles(bindDir, javaDir, mainFile string) error {
 	return ioutil.WriteFile(flagFile, []byte(fmt.Sprintf(javaInclude, inc1, inc2)), 0600)
 }
 
-func buildGo(classDir, mainDir string) error {
+// modFlags returns the flags for go commands run on the bind package of mod,
+// with the -overlay file of the main packages bound, if not empty.
+func modFlags(mod *goModule, overlay string) []string {
+	var flags []string
+	switch {
+	case mod.isVendored():
+		flags = append(flags, "-mod=vendor")
+	case mod != nil && mod.work == nil:
+		// The generated module has no complete go.sum, allow it to be updated.
+		// This is not allowed in workspace mode.
+		flags = append(flags, "-mod=mod")
+	}
+	if overlay != "" {
+		flags = append(flags, "-overlay="+overlay)
+	}
+	return flags
+}
+
+func buildGo(cfg *config, classDir, mainDir, bindDir string, mod *goModule, overlay string) error {
+	goos, goarch, err := goTargetPlatform()
+	if err != nil {
+		return err
+	}
+	env, err := nativeBuildEnv(cfg.ccache, cfg.splitGoCache, goos, goarch)
+	if err != nil {
+		return err
+	}
+	if cfg.jniArchive != "" {
+		// The library linking the archive decides which symbols it exports.
+		args := append([]string{"build", "-o", filepath.Join(classDir, jniArchiveLib), "-buildmode=c-archive"}, modFlags(mod, overlay)...)
+		return runCommandEnv(mainDir, env, "go", append(args, ".")...)
+	}
 	dylib := filepath.Join(classDir, "libgojava")
-	if err := os.Chdir(mainDir); err != nil {
+	args, err := goBuildArgs(cfg, dylib, bindDir, goos, mod, overlay)
+	if err != nil {
+		return err
+	}
+	if err := runCommandEnv(mainDir, env, "go", append(args, ".")...); err != nil {
 		return err
 	}
-	return runCommand("go", "build", "-o", dylib, "-buildmode=c-shared", ".")
+	if goos == "android" {
+		return checkPageAlignment(dylib)
+	}
+	return nil
 }
 
-func buildJava(jarDir, javaDir string, javaFiles []string) error {
-	if err := os.Chdir(javaDir); err != nil {
-		return err
+// goBuildArgs returns the arguments of the go command building the native
+// library of the bind package in bindDir for goos to lib, without the package.
+func goBuildArgs(cfg *config, lib, bindDir, goos string, mod *goModule, overlay string) ([]string, error) {
+	args := append([]string{"build", "-o", lib, "-buildmode=c-shared"}, modFlags(mod, overlay)...)
+	var linkFlags []string
+	if goos == "android" {
+		linkFlags = append(linkFlags, androidLinkFlags)
 	}
-	javaFiles = append(javaFiles, filepath.Join(javaDir, "Seq.java"), filepath.Join(javaDir, "LoadJNI.java"))
-	return runCommand("javac", append([]string{
-		"-d", jarDir,
-		"-sourcepath", filepath.Join(javaDir, ".."),
-	}, javaFiles...)...)
+	if !cfg.exportAll {
+		flags, err := hideSymbols(bindDir, goos, cfg.cAPI != "")
+		if err != nil {
+			return nil, err
+		}
+		linkFlags = append(linkFlags, flags...)
+	}
+	if len(linkFlags) > 0 {
+		args = append(args, "-ldflags=-extldflags '"+strings.Join(linkFlags, " ")+"'")
+	}
+	return args, nil
 }
 
-func createJar(target, jarDir string) error {
-	if err := os.Chdir(cwd); err != nil {
+func buildJava(cfg *config, jarDir, javaDir string, javaFiles []string) error {
+	javaFiles = append(javaFiles, filepath.Join(javaDir, "Seq.java"), filepath.Join(javaDir, "LoadJNI.java"))
+	// -s sources may replace generated ones.
+	seen := make(map[string]bool)
+	unique := javaFiles[:0]
+	for _, f := range javaFiles {
+		if !seen[f] {
+			seen[f] = true
+			unique = append(unique, f)
+		}
+	}
+	javaFiles = unique
+	c, err := newJavaCompiler(cfg)
+	if err != nil {
 		return err
 	}
+	return c.compile(jarDir, filepath.Join(javaDir, ".."), compileClassPath(cfg, ""), javaFiles)
+}
 
-	fullPath := cwd + "/" + target
+// targetPath returns the path of the jar, relative to the directory gojava
+// was run in.
+func targetPath(cfg *config) string {
+	if filepath.IsAbs(cfg.target) {
+		return cfg.target
+	}
+	return filepath.Join(cwd, cfg.target)
+}
 
-	if _, err := os.Stat(fullPath); err == nil {
-		err := os.Remove(fullPath)
+// isNativeLib reports whether the jar entry name is the native library.
+func isNativeLib(name string) bool {
+	return strings.HasPrefix(path.Base(name), "libgojava")
+}
 
+// createJar writes the contents of jarDir to the jar cfg.target.
+func createJar(cfg *config, jarDir string) (err error) {
+	// Write to a temporary file so an interrupted build doesn't leave a
+	// partial jar behind.
+	target := targetPath(cfg)
+	tmp := target + ".tmp"
+	t, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
 		if err != nil {
-			return err
+			t.Close()
+			os.Remove(tmp)
 		}
+	}()
+	verbosef("Building %s\n", target)
+	if err := writeJar(cfg, t, jarDir); err != nil {
+		return err
 	}
-
-	t, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, 0600)
-	if err != nil {
+	if err := t.Close(); err != nil {
 		return err
 	}
-	w := zip.NewWriter(t)
-	verbosef("Building %s\n", target)
+	if err := os.Rename(tmp, target); err != nil {
+		return err
+	}
+	if cfg.out == nil {
+		fmt.Fprintf(logOut, "Finished building %s\n", cfg.target)
+	}
+	return nil
+}
+
+// storeEntries is the compression of jars whose entries are stored
+// uncompressed, set by -compression 0. The zero compression of a config is the
+// default level.
+const storeEntries = flate.HuffmanOnly - 1
+
+// writeJar writes the contents of jarDir to out as a jar. Entries are
+// compressed at cfg.compression, except for the native library which is
+// stored uncompressed if cfg.storeNative is set. Zip64 records are written
+// as needed for entries or jars larger than 4GB.
+func writeJar(cfg *config, out io.Writer, jarDir string) error {
+	w := zip.NewWriter(out)
+	level := cfg.compression
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	if cfg.digests {
+		// The manifest must be the first entry for JarInputStream to find it.
+		manifest, err := jarManifest(jarDir)
+		if err != nil {
+			return err
+		}
+		f, err := w.Create(manifestName)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(manifest); err != nil {
+			return err
+		}
+	}
 	if err := filepath.Walk(jarDir, func(path string, info os.FileInfo, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
+		if err := buildCtx.Err(); err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
@@ -247,7 +887,12 @@ func createJar(target, jarDir string) error {
 		if err != nil {
 			return err
 		}
-		f, err := w.Create(fileName)
+		h := &zip.FileHeader{Name: filepath.ToSlash(fileName), Method: zip.Deflate}
+		if level == storeEntries || (cfg.storeNative && isNativeLib(h.Name)) {
+			h.Method = zip.Store
+		}
+		h.SetModTime(info.ModTime())
+		f, err := w.CreateHeader(h)
 		if err != nil {
 			return err
 		}
@@ -262,24 +907,260 @@ func createJar(target, jarDir string) error {
 	}); err != nil {
 		return err
 	}
-	if err := w.Close(); err != nil {
+	return w.Close()
+}
+
+// copyJar copies the finished jar at path to out.
+func copyJar(out io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
 		return err
 	}
-	if err := t.Close(); err != nil {
-		return err
+	defer f.Close()
+	_, err = io.Copy(out, f)
+	return err
+}
+
+// config holds the options for a single invocation of gojava.
+type config struct {
+	// target is the path of the generated jar.
+	target string
+	// out receives the jar instead of target, if set. The jar is built in
+	// the temporary build directory and copied to out once it is complete.
+	out io.Writer
+	// cache keeps the state of the daemon between builds, or is nil.
+	cache *buildCache
+	// sourceDir is an additional directory containing Java sources to include in the jar.
+	sourceDir string
+	// sCollisions selects what happens to the Java sources in sourceDir at
+	// the path of a generated source: error, override or merge.
+	sCollisions string
+	// factoryPrefix is the name prefix of static factory methods generated for
+	// NewX functions. No factories are generated if it is empty.
+	factoryPrefix string
+	// overloadSuffixes are the function name suffixes, such as String or Bytes,
+	// that are dropped to generate overloaded Java methods.
+	overloadSuffixes listFlag
+	// split generates each bound type in its own Java file instead of nesting
+	// it in the package class.
+	split bool
+	// bindMain binds the exported functions of main packages, instead of
+	// rejecting them.
+	bindMain bool
+	// lazy defers loading the native library until go.Go.load() is called.
+	lazy bool
+	// outOfProcess calls the package functions in a Go server run as a child
+	// process.
+	outOfProcess bool
+	// backend selects how Java calls Go, jni, wasm, which builds a
+	// WebAssembly module run by go.GoWasm instead of a native library, or
+	// classfile, which writes the class files of the bindings instead of
+	// compiling Java sources.
+	backend string
+	// javacImpl selects the Java compiler, javac, ecj or tools.
+	javacImpl string
+	// javac, jarTool and jarsigner override the paths of the JDK tools. The jar
+	// tool is only used if set, otherwise the jar is written directly.
+	javac, jarTool, jarsigner string
+	// kotlinc and scalac override the paths of the compilers of the Kotlin
+	// and Scala sources in sourceDir.
+	kotlinc, scalac string
+	// javacOpts, jarOpts and jarsignerOpts are options files passed to the
+	// corresponding tools. The jar is only signed if jarsignerOpts is set.
+	javacOpts, jarOpts, jarsignerOpts string
+	// processorPath is the class path of the annotation processors run by
+	// the Java compiler, and proc its -proc policy, none or full, if set.
+	processorPath, proc string
+	// sharedRuntime is the path of the jar of the shared runtime classes the
+	// bindings use instead of their own, if set.
+	sharedRuntime string
+	// javacFlags are passed to the Java compiler after javacOpts.
+	javacFlags string
+	// strictJava compiles the Java sources with all lint checks as errors.
+	strictJava bool
+	// signAlias is the keystore alias used to sign the jar.
+	signAlias string
+	// compression is the deflate level of jar entries, from 1 to 9, or
+	// storeEntries. The zero value is the default level.
+	compression int
+	// storeNative stores the native library uncompressed in the jar.
+	storeNative bool
+	// goTimeout, javacTimeout and jarTimeout limit the time taken by the go,
+	// javac and jar stages of the build. 0 means no limit.
+	goTimeout, javacTimeout, jarTimeout time.Duration
+	// flattenNamed disables the Java value classes for named basic types.
+	flattenNamed bool
+	// intercept routes every bound call through go.GoInterceptor.
+	intercept bool
+	// metrics selects a metrics library to generate an interceptor for.
+	metrics string
+	// memoryLimits generates the go.GoMemory interceptor and its natives.
+	memoryLimits bool
+	// jfr generates the go.GoJfr interceptor and go.GoProfiler.
+	jfr bool
+	// record generates the go.GoRecorder interceptor.
+	record bool
+	// tensors adds the go.GoFloatTensor and go.GoDoubleTensor classes.
+	tensors bool
+	// registry adds the go.GoPackages class describing the bound packages.
+	registry bool
+	// scripting adds the go.GoScripting class for JSR-223 script engines.
+	scripting bool
+	// includeUnstable includes APIs marked experimental or internal.
+	includeUnstable bool
+	// allowBreaking allows replacing a jar with one that removes or changes
+	// members of its API.
+	allowBreaking bool
+	// sourceMap adds a map from generated Java methods to Go positions to the jar.
+	sourceMap bool
+	// ideMetadata is the path to write IDE metadata for the jar to, if set.
+	ideMetadata string
+	// callTimeouts holds the default timeout of the calls of each bound
+	// package with one.
+	callTimeouts callTimeoutFlag
+	// cAPI is the directory to write the native library and its C header to,
+	// if set.
+	cAPI string
+	// jniArchive is the directory to write a static library, C headers, JNI
+	// registration code and Java sources to instead of the jar, if set.
+	jniArchive string
+	// cmake is the directory to write a CMake project building the native
+	// library to, if set.
+	cmake string
+	// ccache selects the compiler cache cgo runs the C compiler with: auto,
+	// off, or the name or path of the program.
+	ccache string
+	// splitGoCache gives each target of the native library its own Go build
+	// cache.
+	splitGoCache bool
+	// cli lists the functions, as <pkg>.<Func>, returning the Go command line
+	// tools to generate Java command classes for.
+	cli listFlag
+	// androidLifecycle is the directory to write an Android lifecycle module
+	// for the jar to, if set.
+	androidLifecycle string
+	// cdi is the directory to write a Jakarta CDI module for the jar to, if
+	// set.
+	cdi string
+	// springBoot is the directory to write a Spring Boot auto-configuration
+	// module for the jar to, if set.
+	springBoot string
+	// examples adds the Example functions of the bound packages, translated
+	// to Java, to the Javadoc of the members they show.
+	examples bool
+	// exampleTests is the directory to write JUnit tests running the
+	// translated examples to, if set.
+	exampleTests string
+	// javaPkg is the root Java package of the bindings, instead of go.
+	javaPkg string
+	// javaPkgFor holds the Java packages of the bound packages moved out of
+	// the root Java package.
+	javaPkgFor javaPkgForFlag
+	// versionSuffix moves the bindings from the go Java package to
+	// go_<versionSuffix>, if set, so they can be loaded alongside bindings
+	// of another version of the same packages.
+	versionSuffix string
+	// exportAll exports all the symbols of the native library, instead of
+	// only the JNI functions.
+	exportAll bool
+	// expose maps each bound package to the functions exposed from it by
+	// gojava expose. The whole API of the packages is bound if it is nil.
+	expose map[string][]string
+	// platformJars moves the native library to a separate jar with the
+	// classifier of the target platform.
+	platformJars bool
+	// sbom adds a CycloneDX bill of materials to the jar.
+	sbom bool
+	// provenance is the path to write SLSA provenance for the jar to, if set.
+	provenance string
+	// digests records the SHA-256 digests of the jar entries in its manifest,
+	// and checks the native library against its digest before loading it.
+	digests bool
+	// vulncheck runs govulncheck on the bound packages, failing the build on
+	// vulnerabilities, or only warning if it is warn.
+	vulncheck vulncheckFlag
+}
+
+// listFlag is a flag.Value holding a comma separated list of strings. It may
+// be set more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends the comma separated values in v to l.
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
 	}
-	fmt.Printf("Finished building %s\n", target)
 	return nil
 }
 
-func bindToJar(target string, sourceDir string, pkgs ...string) error {
-	tmpDir, cleanup, err := initBuild()
+// jarBuild is a jar written by bindToJar, from the contents of dir.
+type jarBuild struct {
+	cfg *config
+	dir string
+}
+
+// bindToJar binds the packages pkgs, resolved in dir, or the current
+// directory if dir is empty, and writes the jar and the other outputs of cfg.
+func bindToJar(cfg *config, dir string, pkgs ...string) error {
+	started := time.Now()
+	tmpDir, cleanup, err := initBuild(cfg.cache, cfg.backend != "classfile")
 	if err != nil {
 		return err
 	}
 	defer cleanup()
+	if dir == "" {
+		dir = cwd
+	}
+	if cfg.out != nil {
+		c := *cfg
+		c.target = filepath.Join(tmpDir, "libgojava.jar")
+		cfg = &c
+	}
 
-	typePkgs, err := loadExportData(pkgs)
+	mod, err := cfg.cache.findModule(dir)
+	if err != nil {
+		return err
+	}
+	key := ""
+	if canRebuildJava(cfg) {
+		if key, err = buildKey(cfg, dir, pkgs); err != nil {
+			return err
+		}
+		if ok, err := rebuildJava(cfg, tmpDir, key); err != nil || ok {
+			return err
+		}
+	}
+	var (
+		fset     *token.FileSet
+		typePkgs []*types.Package
+		sources  map[string][]byte
+	)
+	// The out of process, wasm and classfile bindings only bind functions,
+	// without the adapter types of sequences.
+	seqs := !cfg.outOfProcess && cfg.backend == "jni"
+	err = withTimeout("go list", cfg.goTimeout, func() (err error) {
+		fset, typePkgs, sources, err = cfg.cache.loadPackages(dir, pkgs, cfg.bindMain, seqs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	overlay, err := writeOverlay(filepath.Join(tmpDir, "overlay"), sources)
+	if err != nil {
+		return err
+	}
+	if err := checkAPI(cfg, typePkgs); err != nil {
+		return err
+	}
+	err = withTimeout("govulncheck", cfg.goTimeout, func() error {
+		return vulncheck(cfg.vulncheck, dir, pkgs)
+	})
 	if err != nil {
 		return err
 	}
@@ -289,32 +1170,362 @@ func bindToJar(target string, sourceDir string, pkgs ...string) error {
 	mainFile := filepath.Join(mainDir, "main.go")
 	javaDir := filepath.Join(tmpDir, "src/go")
 	jarDir := filepath.Join(tmpDir, "classes")
-	classDir := filepath.Join(tmpDir, "classes/go")
+	classDir := filepath.Join(tmpDir, "classes", javaRootDir(javaRoot(cfg)))
 
 	if err = createDirs(classDir, javaDir, mainDir); err != nil {
 		return err
 	}
 
-	javaFiles, err := bindPackages(bindDir, javaDir, typePkgs)
+	var exposed map[*types.Package][]string
+	if cfg.expose != nil {
+		exposed = make(map[*types.Package][]string)
+		for i, p := range typePkgs {
+			exposed[p] = cfg.expose[pkgs[i]]
+		}
+	}
+	server := ""
+	if cfg.outOfProcess {
+		server = "process"
+	} else if cfg.backend == "wasm" {
+		server = "wasm"
+	}
+	if server != "" {
+		mode := "-out-of-process"
+		if server == "wasm" {
+			mode = "-backend wasm"
+		}
+		if err := checkServerPackages(mode, typePkgs, exposed, newDocFinder(fset)); err != nil {
+			return err
+		}
+	}
+	timeouts, err := packageTimeouts(cfg.callTimeouts, pkgs, typePkgs)
 	if err != nil {
 		return err
 	}
-	extraFiles, err := addExtraFiles(javaDir, sourceDir)
+	moved, err := packageJavaPkgs(cfg.javaPkgFor, javaRoot(cfg), pkgs, typePkgs)
 	if err != nil {
 		return err
 	}
-	javaFiles = append(javaFiles, extraFiles...)
-	if err := createSupportFiles(bindDir, javaDir, mainFile); err != nil {
+	if cfg.backend == "classfile" {
+		return bindClassfiles(cfg, classfileBuild{
+			dir: dir, pkgs: pkgs, started: started,
+			bindDir: bindDir, jarDir: jarDir, classDir: classDir,
+			fset: fset, typePkgs: typePkgs, exposed: exposed, moved: moved,
+			mod: mod, overlay: overlay,
+		})
+	}
+	javaFiles, err := bindPackages(cfg, fset, bindDir, javaDir, typePkgs, exposed, timeouts)
+	if err != nil {
+		return err
+	}
+	if err := createSupportFiles(bindDir, javaDir, mainFile, mod); err != nil {
+		return err
+	}
+	if err := genInitHooks(bindDir, typePkgs); err != nil {
+		return err
+	}
+	if err := genRuntimeHooks(bindDir); err != nil {
+		return err
+	}
+	docs := newDocFinder(fset)
+	serviceFiles, err := genServices(bindDir, javaDir, typePkgs, exposed, docs)
+	if err != nil {
+		return err
+	}
+	healthFiles, err := genHealth(bindDir, javaDir, typePkgs, docs, len(serviceFiles) > 0)
+	if err != nil {
+		return err
+	}
+	configFiles, err := genConfigLoaders(javaDir, typePkgs, docs, cfg.split)
+	if err != nil {
+		return err
+	}
+	convertFiles, err := genConverters(bindDir, javaDir, typePkgs, exposed, docs, server)
+	if err != nil {
+		return err
+	}
+	arrayFiles, err := genArrays(bindDir, javaDir, typePkgs, exposed, docs)
+	if err != nil {
+		return err
+	}
+	codecFiles, err := genCodecs(bindDir, javaDir, typePkgs, exposed)
+	if err != nil {
+		return err
+	}
+	javaFiles = append(append(append(append(append(append(javaFiles, serviceFiles...), healthFiles...), configFiles...), convertFiles...), arrayFiles...), codecFiles...)
+	if cfg.registry {
+		registryFiles, err := genRegistry(javaDir, typePkgs, exposed, cfg.split)
+		if err != nil {
+			return err
+		}
+		javaFiles = append(javaFiles, registryFiles...)
+	}
+	if cfg.scripting {
+		scriptingFiles, err := genScripting(javaDir)
+		if err != nil {
+			return err
+		}
+		javaFiles = append(javaFiles, scriptingFiles...)
+	}
+	services := findServices(typePkgs, exposed, docs)
+	if cfg.springBoot != "" {
+		dir := cfg.springBoot
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		if err := writeSpringBoot(dir, cfg, typePkgs, services, healthFiles != nil, docs); err != nil {
+			return err
+		}
+	}
+	if cfg.androidLifecycle != "" {
+		dir := cfg.androidLifecycle
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		if err := writeAndroidLifecycle(dir, cfg, typePkgs, len(services) > 0, convertFiles != nil); err != nil {
+			return err
+		}
+	}
+	if cfg.cdi != "" {
+		dir := cfg.cdi
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		if err := writeCDI(dir, cfg, typePkgs, services, docs); err != nil {
+			return err
+		}
+	}
+	if len(timeouts) > 0 {
+		if err := genCallTimeouts(bindDir); err != nil {
+			return err
+		}
+	}
+	if cfg.memoryLimits {
+		if err := genMemoryHooks(bindDir); err != nil {
+			return err
+		}
+	}
+	if cfg.jfr {
+		if err := genProfilerHooks(bindDir); err != nil {
+			return err
+		}
+	}
+	if len(cfg.cli) > 0 {
+		cliFiles, err := bindCLIs(cfg.cli, bindDir, javaDir, typePkgs, mod, overlay)
+		if err != nil {
+			return err
+		}
+		javaFiles = append(javaFiles, cliFiles...)
+	}
+	if err := suppressDeprecations(javaFiles, typePkgs, docs); err != nil {
 		return err
 	}
 
-	if err := buildGo(classDir, mainDir); err != nil {
+	// The -s sources are only copied once all generated sources are written,
+	// but the native methods they declare are part of the ABI.
+	extraSources, err := javaSources(cfg.sourceDir)
+	if err != nil {
+		return err
+	}
+	abi, err := abiVersion(append(javaFiles, extraSources...))
+	if err != nil {
+		return err
+	}
+	if err := genABIHooks(bindDir, abi); err != nil {
 		return err
 	}
-	if err := buildJava(jarDir, javaDir, javaFiles); err != nil {
+	if err := renameNativeRoot(bindDir, javaRoot(cfg)); err != nil {
 		return err
 	}
-	return createJar(target, jarDir)
+	if err := renameNativePackages(bindDir, javaRoot(cfg), moved); err != nil {
+		return err
+	}
+	if cfg.cmake != "" {
+		dir := cfg.cmake
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		if err := writeCMake(dir, cfg, bindDir, mod); err != nil {
+			return err
+		}
+	}
+
+	lib := filepath.Join(classDir, "libgojava")
+	if cfg.backend == "wasm" {
+		if convertFiles == nil {
+			return fmt.Errorf("-backend wasm: no package function can be called from WebAssembly")
+		}
+		lib = filepath.Join(classDir, "gojava.wasm")
+		err = withTimeout("go build", cfg.goTimeout, func() error {
+			return buildWasm(classDir, filepath.Join(bindDir, "gojava_server"), mod, overlay)
+		})
+	} else {
+		err = withTimeout("go build", cfg.goTimeout, func() error {
+			return buildGo(cfg, classDir, mainDir, bindDir, mod, overlay)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if cfg.outOfProcess && convertFiles != nil {
+		err = withTimeout("go build", cfg.goTimeout, func() error {
+			return buildServer(classDir, filepath.Join(bindDir, "gojava_server"), mod, overlay)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	nativeDigest := ""
+	if cfg.digests {
+		if nativeDigest, err = fileDigest(filepath.Join(classDir, "libgojava")); err != nil {
+			return err
+		}
+	}
+	runtimeFiles, err := writeRuntime(cfg, javaDir, nativeDigest, abi)
+	if err != nil {
+		return err
+	}
+	javaFiles = append(javaFiles, runtimeFiles...)
+	extraFiles, err := addExtraFiles(javaDir, cfg.sourceDir, cfg.sCollisions)
+	if err != nil {
+		return err
+	}
+	generated := make(map[string]bool)
+	for _, f := range javaFiles {
+		generated[f] = true
+	}
+	for _, f := range extraFiles {
+		// A jar with a generated class replaced by an -s source is not
+		// rebuilt from, as the generated class is missing from it.
+		if generated[f] {
+			key = ""
+		}
+	}
+	javaFiles = append(javaFiles, extraFiles...)
+	jvmFiles, err := addJVMSources(javaDir, cfg.sourceDir)
+	if err != nil {
+		return err
+	}
+	if err := renameJavaRoot(javaDir, javaRoot(cfg)); err != nil {
+		return err
+	}
+	if err := renameJavaPackages(javaDir, javaRoot(cfg), moved); err != nil {
+		return err
+	}
+	if cfg.sharedRuntime != "" {
+		if javaRoot(cfg) == "go" {
+			fmt.Fprintf(os.Stderr, "warning: -shared-runtime only shares the runtime classes that do not call the native library, go.Seq, go.LoadJNI and go.Go still conflict with those of other bindings without -version-suffix\n")
+		}
+		if err := useSharedRuntime(javaDir, javaRoot(cfg)); err != nil {
+			return err
+		}
+	}
+	if cfg.cAPI != "" {
+		dir := cfg.cAPI
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		if err := writeCAPI(dir, classDir, bindDir); err != nil {
+			return err
+		}
+	}
+	err = withTimeout("javac", cfg.javacTimeout, func() error {
+		return buildJava(cfg, jarDir, javaDir, javaFiles)
+	})
+	if err != nil {
+		return err
+	}
+	if err := compileJVMSources(cfg, jarDir, jvmFiles); err != nil {
+		return err
+	}
+	if cfg.jniArchive != "" {
+		dir := cfg.jniArchive
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+		return writeJNIArchive(dir, javaRoot(cfg), classDir, bindDir, javaDir, jarDir, typePkgs)
+	}
+	if cfg.backend != "wasm" {
+		if err := verifyNatives(jarDir, javaRoot(cfg), lib, typePkgs, moved); err != nil {
+			return err
+		}
+	}
+	if err := writeLicenses(jarDir, mainDir, mod, overlay); err != nil {
+		return err
+	}
+	if cfg.sbom {
+		name := strings.TrimSuffix(filepath.Base(cfg.target), ".jar")
+		if err := writeSBOM(jarDir, name, lib, mainDir, mod != nil, javaDir, extraFiles); err != nil {
+			return err
+		}
+	}
+	if err := writeAPIManifest(jarDir, typePkgs, cfg.split); err != nil {
+		return err
+	}
+	if key != "" {
+		entries, err := sourceDirClasses(jarDir, javaRoot(cfg), cfg.sourceDir)
+		if err != nil {
+			return err
+		}
+		if err := writeRebuildInfo(jarDir, key, entries); err != nil {
+			return err
+		}
+	}
+	if cfg.sourceMap {
+		if err := writeSourceMap(jarDir, fset, typePkgs, cfg.split); err != nil {
+			return err
+		}
+	}
+	jars := []jarBuild{{cfg, jarDir}}
+	if cfg.platformJars {
+		platformCfg, platformLib, err := platformJar(cfg, filepath.Join(tmpDir, "platform"), lib)
+		if err != nil {
+			return err
+		}
+		lib = platformLib
+		jars = append(jars, jarBuild{platformCfg, filepath.Join(tmpDir, "platform")})
+	}
+	if err := writeJars(cfg, jars); err != nil {
+		return err
+	}
+	if cfg.out != nil {
+		if err := copyJar(cfg.out, cfg.target); err != nil {
+			return err
+		}
+	}
+	if cfg.provenance != "" {
+		var targets []string
+		for _, j := range jars {
+			targets = append(targets, targetPath(j.cfg))
+		}
+		err := writeProvenance(cfg.provenance, targets, lib, dir, pkgs, started)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.ideMetadata == "" {
+		return nil
+	}
+	return writeIDEMetadata(cfg.ideMetadata, cfg, fset, typePkgs)
+}
+
+// writeJars creates and signs jars, within the -jar-timeout of cfg.
+func writeJars(cfg *config, jars []jarBuild) error {
+	return withTimeout("jar", cfg.jarTimeout, func() error {
+		for _, j := range jars {
+			if j.cfg.jarTool != "" {
+				if err := jarWithTool(j.cfg, j.dir); err != nil {
+					return err
+				}
+			} else if err := createJar(j.cfg, j.dir); err != nil {
+				return err
+			}
+			if err := signJar(j.cfg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func copyFile(dst, src string) error {
@@ -364,6 +1575,26 @@ func bindJava(dir, file string, conf *bind.GeneratorConfig, ft int) error {
 	return w.Close()
 }
 
+// bindArgs binds the packages named by the package arguments args.
+func bindArgs(cfg *config, args []string) error {
+	pkgs, err := expandPackages("", args)
+	if err != nil {
+		return err
+	}
+	return bindToJar(cfg, "", pkgs...)
+}
+
+// bindExposed binds the functions named by the gojava expose arguments args,
+// as <pkg>.<Func>, with the types they use.
+func bindExposed(cfg *config, args []string) error {
+	pkgs, funcs, err := parseExposed(args)
+	if err != nil {
+		return err
+	}
+	cfg.expose = funcs
+	return bindToJar(cfg, "", pkgs...)
+}
+
 const javaInclude = `package gojava_bind
 
 // #cgo CFLAGS: -Wall -I%s -I%s
@@ -374,7 +1605,7 @@ const javaMain = `package main
 
 import (
 	_ %q
-	_ ".."
+	_ %q
 )
 
 func main() {}
@@ -384,26 +1615,259 @@ const usage = `gojava is a tool for creating Java bindings to Go
 
 Usage:
 
-	gojava [-v] [-o <jar>] [-s <dir>] build [<pkg1>, [<pkg2>...]]
+	gojava [-v] [-o <jar>] [-s <dir>] [flags] build [<pkg1>, [<pkg2>...]]
 
 This generates a jar containing Java bindings to the specified Go packages.
+Packages may be given as import paths, relative paths or patterns like ./...
+Packages at a module version, like example.com/mod@v1.4.2/pkg, are downloaded
+into a temporary module and bound from there.
+
+	gojava [flags] expose <pkg>.<Func> [<pkg>.<Func>...]
+
+This generates a jar exposing only the given functions and the types they use.
+
+	gojava [flags] daemon [<addr>]
+
+This serves bind requests over HTTP on addr (default localhost:8035).
+
+	gojava [flags] publish -repo <url> -coordinates <group:artifact:version> [flags] <jar>
+
+This deploys the jar to a Maven repository.
+
+	gojava [-o <jar>] [flags] runtime
+
+This writes the jar of the runtime classes shared by jars built with
+-shared-runtime.
+
+	gojava [flags] docs [-godoc <url>] <dir> <pkg1> [<pkg2>...]
+
+This writes a static HTML site documenting the generated Java API to dir.
+
+	gojava [flags] replay [-cp <classpath>] <jar> <trace>
+
+This replays the calls recorded by go.GoRecorder against a jar built with
+-record, and reports the calls whose result or error changed.
+
 `
 
+// checkConfig reports an invalid flag or combination of flags in cfg.
+func checkConfig(cfg *config) error {
+	if err := checkVersionSuffix(cfg); err != nil {
+		return err
+	}
+	if err := checkJavaPkg(cfg); err != nil {
+		return err
+	}
+	if cfg.proc != "" && cfg.proc != "none" && cfg.proc != "full" {
+		return fmt.Errorf("invalid -proc, must be none or full: %s", cfg.proc)
+	}
+	if cfg.strictJava && cfg.javacImpl == "ecj" {
+		return errors.New("-strict-java cannot be used with -javac-impl ecj")
+	}
+	if err := checkCollisions(cfg.sCollisions); err != nil {
+		return err
+	}
+	if cfg.platformJars && cfg.digests {
+		return errors.New("-digests cannot be used with -platform-jars")
+	}
+	if cfg.jniArchive != "" && (cfg.backend != "jni" || cfg.outOfProcess || cfg.cAPI != "" || cfg.digests || cfg.platformJars || cfg.target == "-") {
+		return errors.New("-jni-archive cannot be used with -backend wasm or classfile, -out-of-process, -c-api, -digests, -platform-jars or -o -")
+	}
+	if cfg.cmake != "" && (cfg.backend != "jni" || cfg.jniArchive != "" || cfg.bindMain) {
+		return errors.New("-cmake cannot be used with -backend wasm or classfile, -jni-archive or -main")
+	}
+	if cfg.outOfProcess && cfg.intercept {
+		return errors.New("-out-of-process cannot be used with -intercept")
+	}
+	if len(cfg.callTimeouts) > 0 && (cfg.outOfProcess || cfg.backend != "jni") {
+		return errors.New("-call-timeout cannot be used with -out-of-process or -backend wasm or classfile")
+	}
+	switch cfg.backend {
+	case "jni":
+	case "wasm":
+		if cfg.outOfProcess || cfg.intercept || cfg.cAPI != "" || cfg.digests || cfg.platformJars {
+			return errors.New("-backend wasm cannot be used with -out-of-process, -intercept, -c-api, -digests or -platform-jars")
+		}
+	case "classfile":
+		if set := classfileFlags(cfg); len(set) > 0 {
+			return fmt.Errorf("-backend classfile writes no Java sources and cannot be used with %s", strings.Join(set, ", "))
+		}
+	default:
+		return fmt.Errorf("unknown -backend %q, must be jni, wasm or classfile", cfg.backend)
+	}
+	return nil
+}
+
 func main() {
-	o := flag.String("o", "libgojava.jar", "Path to the generated jar file.")
-	s := flag.String("s", "", "Additional path to scan for Java source code.")
+	cfg := &config{}
+	flag.StringVar(&cfg.target, "o", "libgojava.jar", "Path to the generated jar file, or - for stdout.")
+	flag.StringVar(&cfg.sourceDir, "s", "", "Additional path to scan for Java source code.")
+	flag.StringVar(&cfg.sCollisions, "s-collisions", collisionError, "What to do with a file in -s at the path of a generated Java source: error, override or merge.")
+	flag.StringVar(&cfg.factoryPrefix, "factory", "", "Name prefix for static factory methods generated from NewX functions.")
+	flag.Var(&cfg.overloadSuffixes, "overload", "Comma separated function name suffixes to collapse into overloaded methods.")
+	flag.BoolVar(&cfg.split, "split", false, "Generate one Java file per bound type.")
+	flag.BoolVar(&cfg.bindMain, "main", false, "Bind main packages as if they were libraries.")
+	flag.BoolVar(&cfg.lazy, "lazy", false, "Only load the native library when go.Go.load() is called.")
+	flag.BoolVar(&cfg.outOfProcess, "out-of-process", false, "Call the package functions in a Go child process instead of through JNI.")
+	flag.StringVar(&cfg.backend, "backend", "jni", "How Java calls Go, jni, wasm for a WebAssembly module run in the JVM, or classfile to write the class files without javac.")
+	flag.StringVar(&cfg.javacImpl, "javac-impl", "javac", "Java compiler to use, javac, ecj or tools.")
+	flag.StringVar(&cfg.javac, "javac", "", "Path to the Java compiler. Defaults to $GOJAVA_JAVAC, $JAVA_HOME/bin/javac or javac in $PATH.")
+	flag.StringVar(&cfg.javacOpts, "javac-opts", "", "Options file passed to javac.")
+	flag.StringVar(&cfg.jarTool, "jar", "", "Path to the jar tool used to create the jar, instead of writing it directly.")
+	flag.StringVar(&cfg.jarOpts, "jar-opts", "", "Options file passed to the jar tool.")
+	flag.StringVar(&cfg.sharedRuntime, "shared-runtime", "", "Path of a runtime jar written by gojava runtime, whose runtime classes not calling the native library the jar uses instead of its own.")
+	flag.StringVar(&cfg.javacFlags, "javac-flags", "", "Flags passed to the Java compiler, separated by spaces.")
+	flag.BoolVar(&cfg.strictJava, "strict-java", false, "Compile the Java sources with all javac lint checks and -Werror.")
+	flag.StringVar(&cfg.processorPath, "processorpath", "", "Class path of the annotation processors run when compiling the Java sources.")
+	flag.StringVar(&cfg.proc, "proc", "", "Annotation processing policy passed to javac, none or full.")
+	flag.StringVar(&cfg.kotlinc, "kotlinc", "", "Path to the Kotlin compiler. Defaults to $GOJAVA_KOTLINC or kotlinc in $PATH.")
+	flag.StringVar(&cfg.scalac, "scalac", "", "Path to the Scala compiler. Defaults to $GOJAVA_SCALAC or scalac in $PATH.")
+	flag.StringVar(&cfg.jarsigner, "jarsigner", "", "Path to jarsigner. Defaults to $GOJAVA_JARSIGNER, $JAVA_HOME/bin/jarsigner or jarsigner in $PATH.")
+	flag.StringVar(&cfg.jarsignerOpts, "jarsigner-opts", "", "Options file passed to jarsigner. The jar is only signed if this is set.")
+	flag.StringVar(&cfg.signAlias, "sign-alias", "", "Keystore alias used to sign the jar.")
+	flag.IntVar(&cfg.compression, "compression", flate.DefaultCompression, "Compression level of jar entries, from 0 (none) to 9.")
+	flag.BoolVar(&cfg.storeNative, "store-native", false, "Store the native library in the jar uncompressed.")
+	flag.DurationVar(&cfg.goTimeout, "go-timeout", 0, "Timeout for each go command, e.g. 10m. No limit if 0.")
+	flag.DurationVar(&cfg.javacTimeout, "javac-timeout", 0, "Timeout for compiling the Java sources. No limit if 0.")
+	flag.DurationVar(&cfg.jarTimeout, "jar-timeout", 0, "Timeout for creating and signing the jar. No limit if 0.")
+	flag.Var(&envVars, "env", "KEY=VALUE to set in the environment of subprocesses, or KEY to keep with -clean-env. May be repeated.")
+	flag.BoolVar(&cleanEnv, "clean-env", false, "Run subprocesses in a clean environment.")
+	flag.StringVar(&cfg.ideMetadata, "ide-metadata", "", "Path to write JSON metadata mapping the generated Java members to Go sources.")
+	flag.Var(&cfg.cli, "cli", "<pkg>.<Func> returning a cobra command or flag set to generate Java command classes for. May be repeated.")
+	flag.StringVar(&cfg.androidLifecycle, "android-lifecycle", "", "Directory to write an Android lifecycle module for the jar to.")
+	flag.StringVar(&cfg.cdi, "cdi", "", "Directory to write a Jakarta CDI module for the jar to.")
+	flag.BoolVar(&cfg.examples, "examples", false, "Add the Example functions of the bound packages, translated to Java, to the Javadoc.")
+	flag.StringVar(&cfg.exampleTests, "example-tests", "", "Directory to write JUnit tests running the translated Example functions to.")
+	flag.StringVar(&cfg.javaPkg, "javapkg", "", "Root Java package of the bindings, instead of go.")
+	flag.Var(&cfg.javaPkgFor, "javapkg-for", "Java package of the classes of a bound package, as pkg=javapkg. May be repeated.")
+	flag.StringVar(&cfg.versionSuffix, "version-suffix", "", "Suffix of the root Java package, go_<suffix>, so bindings of different versions can be loaded side by side.")
+	flag.StringVar(&cfg.springBoot, "spring-boot", "", "Directory to write a Spring Boot auto-configuration module for the jar to.")
+	flag.Var(&cfg.callTimeouts, "call-timeout", "Default timeout of the calls of a bound package, as pkg=duration. May be repeated.")
+	flag.StringVar(&cfg.cAPI, "c-api", "", "Directory to also write the native library and its C header to.")
+	flag.StringVar(&cfg.ccache, "ccache", "auto", "Compiler cache to run the C compiler with: auto, off, or the name or path of ccache or sccache.")
+	flag.BoolVar(&cfg.splitGoCache, "split-gocache", false, "Use a separate Go build cache for each GOOS/GOARCH the native library is built for.")
+	flag.StringVar(&cfg.cmake, "cmake", "", "Directory to write a CMake project building the native library to.")
+	flag.StringVar(&cfg.jniArchive, "jni-archive", "", "Directory to write a static library, C headers, JNI registration code and the Java sources to, instead of the jar.")
+	flag.BoolVar(&cfg.digests, "digests", false, "Record the SHA-256 digests of the jar entries in its manifest and check the native library before loading it.")
+	flag.BoolVar(&cfg.exportAll, "export-all", false, "Export all symbols of the native library, not only the JNI functions.")
+	flag.BoolVar(&cfg.platformJars, "platform-jars", false, "Write the native library to a separate jar with the classifier of the target platform.")
+	flag.StringVar(&cfg.provenance, "provenance", "", "Path to write SLSA provenance for the jar to.")
+	flag.BoolVar(&cfg.sbom, "sbom", false, "Add a CycloneDX software bill of materials to the jar.")
+	flag.BoolVar(&cfg.registry, "registry", false, "Add the go.GoPackages class listing the bound packages, functions, types and methods.")
+	flag.BoolVar(&cfg.scripting, "scripting", false, "Add the go.GoScripting class exposing the bound packages to JSR-223 script engines. Implies -registry.")
+	flag.BoolVar(&cfg.tensors, "tensors", false, "Add the go.GoFloatTensor and go.GoDoubleTensor classes to the jar.")
+	flag.BoolVar(&cfg.sourceMap, "source-map", false, "Add a map from generated Java methods to Go source positions to the jar.")
+	flag.BoolVar(&cfg.flattenNamed, "flatten-named", false, "Do not generate Java value classes for named basic types.")
+	flag.BoolVar(&cfg.intercept, "intercept", false, "Call the interceptor registered with go.Go.setInterceptor around every bound call.")
+	flag.BoolVar(&cfg.memoryLimits, "memory-limits", false, "Generate the go.GoMemory interceptor limiting memory used by bound calls. Implies -intercept.")
+	flag.BoolVar(&cfg.record, "record", false, "Generate the go.GoRecorder interceptor writing the arguments and results of bound calls to a file, and go.GoReplay. Implies -intercept and -registry.")
+	flag.BoolVar(&cfg.jfr, "jfr", false, "Generate the go.GoJfr interceptor emitting JFR events for bound calls, and go.GoProfiler. Implies -intercept.")
+	flag.StringVar(&cfg.metrics, "metrics", "", "Generate a metrics interceptor for this library, micrometer. Implies -intercept.")
+	flag.BoolVar(&cfg.includeUnstable, "include-experimental", false, "Include APIs marked //gojava:experimental or //gojava:internal.")
+	flag.BoolVar(&cfg.allowBreaking, "allow-breaking", false, "Replace the jar even if members of its API were removed or changed.")
+	inDocker := flag.String("in-docker", "", "Docker image to run the build in, e.g. golang:1.22 with a JDK installed.")
 	flag.BoolVar(&verbose, "v", false, "Verbose output.")
+	flag.Var(&cfg.vulncheck, "vulncheck", "Run govulncheck on the bound packages and fail on vulnerabilities, or warn with -vulncheck=warn.")
 	flag.Usage = func() {
-		fmt.Fprintln(os.Stderr, usage)
+		fmt.Fprint(os.Stderr, usage)
 		flag.PrintDefaults()
 	}
 	flag.Parse()
-	if flag.NArg() < 2 || flag.Args()[0] != "build" {
+	if cfg.compression < flate.HuffmanOnly || cfg.compression > flate.BestCompression {
+		fmt.Fprintln(os.Stderr, "invalid compression level:", cfg.compression)
+		os.Exit(1)
+	}
+	if cfg.compression == flate.NoCompression {
+		cfg.compression = storeEntries
+	}
+	if cfg.memoryLimits || cfg.jfr || cfg.record {
+		cfg.intercept = true
+	}
+	if cfg.scripting || cfg.record {
+		cfg.registry = true
+	}
+	switch cfg.metrics {
+	case "":
+	case "micrometer":
+		cfg.intercept = true
+	default:
+		fmt.Fprintln(os.Stderr, "unsupported metrics library:", cfg.metrics)
+		os.Exit(1)
+	}
+	if err := checkConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.target == "-" && (*inDocker != "" || cfg.platformJars) {
+		fmt.Fprintln(os.Stderr, "-o - cannot be used with -in-docker or -platform-jars")
+		os.Exit(1)
+	}
+	if flag.NArg() >= 1 && flag.Args()[0] == "publish" {
+		if err := runPublish(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() >= 1 && flag.Args()[0] == "replay" {
+		if err := runReplay(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() >= 1 && flag.Args()[0] == "docs" {
+		if err := runDocs(cfg, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() == 1 && flag.Args()[0] == "runtime" {
+		if err := buildRuntimeJar(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() >= 1 && flag.NArg() <= 2 && flag.Args()[0] == "daemon" {
+		addr := defaultDaemonAddr
+		if flag.NArg() == 2 {
+			addr = flag.Args()[1]
+		}
+		if err := runDaemon(cfg, addr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.NArg() < 2 || (flag.Args()[0] != "build" && flag.Args()[0] != "expose") {
 		flag.Usage()
 		os.Exit(1)
 	}
-	if err := bindToJar(*o, *s, flag.Args()[1:]...); err != nil {
+	if cfg.target == "-" {
+		cfg.out, logOut = os.Stdout, os.Stderr
+	}
+	handleSignals()
+	if *inDocker != "" {
+		if err := runInDocker(cfg, *inDocker, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	bind := bindArgs
+	switch {
+	case flag.Args()[0] == "expose":
+		bind = bindExposed
+	case isRemote(flag.Args()[1]):
+		bind = bindRemote
+	}
+	if err := bind(cfg, flag.Args()[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if interrupted() {
+			os.Exit(exitInterrupted)
+		}
 		os.Exit(1)
 	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"testing"
 
 	"flag"
@@ -31,8 +33,7 @@ func TestJavaBind(t *testing.T) {
 		t.Fatal(err)
 	}
 	jar := filepath.Join(tmpDir, "gojavatest.jar")
-	if err := bindToJar(jar,
-		"",
+	if err := bindToJar(&config{target: jar}, "",
 		"github.com/sridharv/gomobile-java/bind/testpkg",
 		"github.com/sridharv/gomobile-java/bind/testpkg/secondpkg",
 		"github.com/sridharv/gomobile-java/bind/testpkg/simplepkg",
@@ -64,8 +65,7 @@ func TestSourceDir(t *testing.T) {
 		t.Fatal(err)
 	}
 	jar := filepath.Join(tmpDir, "gojavatest.jar")
-	if err := bindToJar(jar,
-		"testdata",
+	if err := bindToJar(&config{target: jar, sourceDir: "testdata"}, "",
 		"github.com/sridharv/gomobile-java/bind/testpkg",
 	); err != nil {
 		t.Fatal(err)
@@ -77,3 +77,102 @@ func TestSourceDir(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCreateJar(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	jarDir := filepath.Join(tmpDir, "classes")
+	files := map[string]string{"go/libgojava": "native", "go/testpkg/Testpkg.class": "class"}
+	for name, content := range files {
+		p := filepath.Join(jarDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldCwd := cwd
+	defer func() { cwd = oldCwd }()
+	cwd = tmpDir
+
+	jar := filepath.Join(tmpDir, "test.jar")
+	if err := createJar(&config{target: jar, compression: 9, storeNative: true}, jarDir); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != len(files) {
+		t.Fatalf("expected %d entries, got %d", len(files), len(r.File))
+	}
+	for _, f := range r.File {
+		want := zip.Deflate
+		if f.Name == "go/libgojava" {
+			want = zip.Store
+		}
+		if f.Method != want {
+			t.Errorf("%s: expected method %d, got %d", f.Name, want, f.Method)
+		}
+	}
+}
+
+func TestWriteJar(t *testing.T) {
+	jarDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jarDir)
+	if err := ioutil.WriteFile(filepath.Join(jarDir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := writeJar(&config{compression: 9}, &b, jarDir); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 1 || r.File[0].Name != "a.txt" {
+		t.Errorf("unexpected entries %v", r.File)
+	}
+	for _, test := range []struct {
+		compression int
+		method      uint16
+	}{
+		{0, zip.Deflate},
+		{storeEntries, zip.Store},
+	} {
+		b.Reset()
+		if err := writeJar(&config{compression: test.compression}, &b, jarDir); err != nil {
+			t.Fatal(err)
+		}
+		r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := r.File[0].Method; m != test.method {
+			t.Errorf("compression %d: got method %d, want %d", test.compression, m, test.method)
+		}
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := &prefixWriter{w: &b, prefix: "[go] ", bol: true}
+	for _, s := range []string{"a\nb", "c\n", "\nd\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp := "[go] a\n[go] bc\n[go] \n[go] d\n"; b.String() != exp {
+		t.Errorf("expected %q, got %q", exp, b.String())
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalImportPath(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gopath1, gopath2 := filepath.Join(tmpDir, "gopath1"), filepath.Join(tmpDir, "gopath2")
+	pkgDir := filepath.Join(gopath2, "src", "example.com", "lib")
+	if err := os.MkdirAll(pkgDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(gopath1, "src"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, "lib.go"), []byte("package lib\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(tmpDir, "worktree")
+	if err := os.Symlink(pkgDir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	oldGOPATH, oldModule := build.Default.GOPATH, os.Getenv("GO111MODULE")
+	build.Default.GOPATH = gopath1 + string(filepath.ListSeparator) + gopath2
+	os.Setenv("GO111MODULE", "off")
+	defer func() {
+		build.Default.GOPATH = oldGOPATH
+		os.Setenv("GO111MODULE", oldModule)
+	}()
+
+	for _, c := range []struct{ path, srcDir string }{
+		{"example.com/lib", tmpDir},
+		{"./worktree", tmpDir},
+		{".", link},
+		{pkgDir, tmpDir},
+	} {
+		p, err := canonicalImportPath(c.path, c.srcDir)
+		if err != nil {
+			t.Errorf("%s in %s: %v", c.path, c.srcDir, err)
+			continue
+		}
+		if p != "example.com/lib" {
+			t.Errorf("%s in %s: got %s, want example.com/lib", c.path, c.srcDir, p)
+		}
+	}
+}
+
+func TestCanonicalImportPathModule(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	modDir, outside := filepath.Join(tmpDir, "mono"), filepath.Join(tmpDir, "outside")
+	for dir, files := range map[string]map[string]string{
+		modDir:                              {"go.mod": "module example.com/mono\n\ngo 1.16\n"},
+		filepath.Join(modDir, "pkg", "api"): {"api.go": "package api\n"},
+		outside:                             {"lib.go": "package lib\n"},
+	} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		for name, src := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	oldGOPATH, oldModule := build.Default.GOPATH, os.Getenv("GO111MODULE")
+	build.Default.GOPATH = filepath.Join(tmpDir, "gopath")
+	os.Setenv("GO111MODULE", "on")
+	defer func() {
+		build.Default.GOPATH = oldGOPATH
+		os.Setenv("GO111MODULE", oldModule)
+	}()
+
+	for _, path := range []string{"./pkg/api", filepath.Join(modDir, "pkg", "api")} {
+		p, err := canonicalImportPath(path, modDir)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if p != "example.com/mono/pkg/api" {
+			t.Errorf("%s: got %s, want example.com/mono/pkg/api", path, p)
+		}
+	}
+	if _, err := canonicalImportPath("../outside", modDir); err == nil {
+		t.Error("../outside: expected an error for a directory outside the main module")
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// genRuntimeHooks writes the Go and C code to bindDir implementing the native
+// methods of go.GoRuntime, which configures the Go runtime, such as its memory
+// limit and number of threads, and reports its memory use.
+func genRuntimeHooks(bindDir string) error {
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_runtime.go"), []byte(runtimeHooksGo), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_runtime.c"), []byte(runtimeHooksC), 0600)
+}
+
+const runtimeHooksGo = `package gojava_bind
+
+import "C"
+
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"unsafe"
+)
+
+// gojavaMemoryMetrics are the metrics read by go.GoRuntime.memoryStats, in
+// order. The memory limit follows them.
+var gojavaMemoryMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/heap/goal:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+//export gojava_memory_stats
+func gojava_memory_stats(out *C.longlong) {
+	s := make([]metrics.Sample, len(gojavaMemoryMetrics))
+	for i, name := range gojavaMemoryMetrics {
+		s[i].Name = name
+	}
+	metrics.Read(s)
+	v := unsafe.Slice((*int64)(unsafe.Pointer(out)), len(s)+1)
+	for i := range s {
+		v[i] = int64(s[i].Value.Uint64())
+	}
+	v[len(s)] = debug.SetMemoryLimit(-1)
+}
+
+//export gojava_set_memory_limit
+func gojava_set_memory_limit(limit C.longlong) C.longlong {
+	return C.longlong(debug.SetMemoryLimit(int64(limit)))
+}
+
+//export gojava_set_max_procs
+func gojava_set_max_procs(n C.int) C.int {
+	return C.int(runtime.GOMAXPROCS(int(n)))
+}
+
+//export gojava_set_max_threads
+func gojava_set_max_threads(n C.int) C.int {
+	return C.int(debug.SetMaxThreads(int(n)))
+}
+`
+
+const runtimeHooksC = `#include <jni.h>
+#include "_cgo_export.h"
+
+#define GOJAVA_MEMORY_STATS 5
+
+JNIEXPORT jlongArray JNICALL Java_go_GoRuntime_memoryStats0(JNIEnv *env, jclass clazz) {
+	long long v[GOJAVA_MEMORY_STATS];
+	gojava_memory_stats(v);
+	jlongArray a = (*env)->NewLongArray(env, GOJAVA_MEMORY_STATS);
+	if (a != NULL) {
+		(*env)->SetLongArrayRegion(env, a, 0, GOJAVA_MEMORY_STATS, (jlong *)v);
+	}
+	return a;
+}
+
+JNIEXPORT jlong JNICALL Java_go_GoRuntime_setMemoryLimit0(JNIEnv *env, jclass clazz, jlong limit) {
+	return gojava_set_memory_limit(limit);
+}
+
+JNIEXPORT jint JNICALL Java_go_GoRuntime_setMaxProcs0(JNIEnv *env, jclass clazz, jint n) {
+	return gojava_set_max_procs(n);
+}
+
+JNIEXPORT jint JNICALL Java_go_GoRuntime_setMaxThreads0(JNIEnv *env, jclass clazz, jint n) {
+	return gojava_set_max_threads(n);
+}
+`
+
+const goRuntimeJava = `package go;
+
+import java.lang.management.ManagementFactory;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+import javax.management.InstanceAlreadyExistsException;
+import javax.management.ObjectName;
+
+// GoRuntime configures the Go runtime of the native library and reports its
+// memory use. When the library is loaded, before any bound function runs,
+// system properties set:
+//
+//	gojava.memoryLimit  the soft memory limit of Go, in the syntax of
+//	                    GOMEMLIMIT such as 512MiB, or off
+//	gojava.maxProcs     GOMAXPROCS, the number of threads running Go code
+//	gojava.maxThreads   the maximum number of OS threads of Go
+//
+// and the memory use of Go is registered with the platform MBean server as
+// go:type=Memory unless gojava.jmx is false.
+public final class GoRuntime {
+	private static final Pattern SIZE = Pattern.compile("([0-9]+)(B|KiB|MiB|GiB|TiB)?");
+	private static final String[] UNITS = {"B", "KiB", "MiB", "GiB", "TiB"};
+
+	private GoRuntime() {}
+
+	// configure applies the system properties, when the library is loaded.
+	static void configure() {
+		String limit = System.getProperty("gojava.memoryLimit");
+		if (limit != null) {
+			setMemoryLimit0(parseBytes("gojava.memoryLimit", limit));
+		}
+		String procs = System.getProperty("gojava.maxProcs");
+		if (procs != null) {
+			setMaxProcs0(parseCount("gojava.maxProcs", procs));
+		}
+		String threads = System.getProperty("gojava.maxThreads");
+		if (threads != null) {
+			setMaxThreads0(parseCount("gojava.maxThreads", threads));
+		}
+		if (System.getProperty("gojava.maxCalls") != null && !Go.INTERCEPT) {
+			// The calls are only counted by the wrappers of -intercept.
+			throw new IllegalStateException("gojava.maxCalls is set, but the Go bindings were built without -intercept");
+		}
+		if (!"false".equals(System.getProperty("gojava.jmx"))) {
+			registerMXBean();
+		}
+	}
+
+	// setMemoryLimit sets the soft memory limit of the Go heap in bytes, and
+	// returns the previous limit. Long.MAX_VALUE means no limit.
+	public static long setMemoryLimit(long bytes) {
+		if (bytes < 0) {
+			throw new IllegalArgumentException("negative memory limit " + bytes);
+		}
+		Go.load();
+		return setMemoryLimit0(bytes);
+	}
+
+	// memoryLimit returns the soft memory limit of the Go heap in bytes.
+	public static long memoryLimit() {
+		Go.load();
+		return setMemoryLimit0(-1);
+	}
+
+	// setMaxProcs sets GOMAXPROCS, the number of threads that can run Go code
+	// at once, and returns the previous setting.
+	public static int setMaxProcs(int n) {
+		if (n < 1) {
+			throw new IllegalArgumentException("GOMAXPROCS must be positive, not " + n);
+		}
+		Go.load();
+		return setMaxProcs0(n);
+	}
+
+	public static int maxProcs() {
+		Go.load();
+		return setMaxProcs0(0);
+	}
+
+	// setMaxThreads sets the maximum number of OS threads Go can use, and
+	// returns the previous setting. Go crashes the process rather than
+	// create more, and the JVM threads calling Go count towards it.
+	public static int setMaxThreads(int n) {
+		if (n < 1) {
+			throw new IllegalArgumentException("the maximum number of threads must be positive, not " + n);
+		}
+		Go.load();
+		return setMaxThreads0(n);
+	}
+
+	// memory returns the memory use of Go, which is also registered as an
+	// MXBean.
+	public static GoMemoryMXBean memory() {
+		Go.load();
+		return new Memory();
+	}
+
+	// parseBytes parses the value of the property name in the syntax of
+	// GOMEMLIMIT.
+	static long parseBytes(String name, String v) {
+		if (v.equals("off")) {
+			return Long.MAX_VALUE;
+		}
+		Matcher m = SIZE.matcher(v);
+		if (!m.matches()) {
+			throw new IllegalArgumentException(name + ": invalid size " + v + ", want a number of bytes with an optional B, KiB, MiB, GiB or TiB unit, or off");
+		}
+		int shift = 0;
+		for (int i = 0; i < UNITS.length; i++) {
+			if (UNITS[i].equals(m.group(2))) {
+				shift = 10 * i;
+			}
+		}
+		try {
+			long n = Long.parseLong(m.group(1));
+			if (n > Long.MAX_VALUE >> shift) {
+				throw new NumberFormatException();
+			}
+			return n << shift;
+		} catch (NumberFormatException e) {
+			throw new IllegalArgumentException(name + ": size " + v + " is too large");
+		}
+	}
+
+	// parseCount parses the value of the property name as a positive int.
+	static int parseCount(String name, String v) {
+		try {
+			int n = Integer.parseInt(v.trim());
+			if (n > 0) {
+				return n;
+			}
+		} catch (NumberFormatException e) {
+			// Reported below.
+		}
+		throw new IllegalArgumentException(name + ": invalid count " + v + ", want a positive integer");
+	}
+
+	private static void registerMXBean() {
+		try {
+			// The domain is the Java package of the bindings, which differs
+			// between versions loaded side by side.
+			String domain = GoRuntime.class.getName().substring(0, GoRuntime.class.getName().lastIndexOf('.'));
+			ManagementFactory.getPlatformMBeanServer().registerMBean(new Memory(), new ObjectName(domain + ":type=Memory"));
+		} catch (InstanceAlreadyExistsException e) {
+			// Registered by bindings loaded by another class loader.
+		} catch (Exception | LinkageError e) {
+			// JMX is not available, as on Android, which does not stop the
+			// bindings from loading.
+		}
+	}
+
+	private static final class Memory implements GoMemoryMXBean {
+		@Override
+		public long getHeapObjectsBytes() {
+			return memoryStats0()[0];
+		}
+
+		@Override
+		public long getTotalBytes() {
+			return memoryStats0()[1];
+		}
+
+		@Override
+		public long getHeapGoalBytes() {
+			return memoryStats0()[2];
+		}
+
+		@Override
+		public long getGCCycles() {
+			return memoryStats0()[3];
+		}
+
+		@Override
+		public long getMemoryLimit() {
+			return memoryStats0()[4];
+		}
+
+		@Override
+		public void setMemoryLimit(long bytes) {
+			GoRuntime.setMemoryLimit(bytes);
+		}
+	}
+
+	private static native long[] memoryStats0();
+
+	private static native long setMemoryLimit0(long limit);
+
+	private static native int setMaxProcs0(int n);
+
+	private static native int setMaxThreads0(int n);
+}
+`
+
+const goMemoryMXBeanJava = `package go;
+
+// GoMemoryMXBean is the memory use of the Go runtime of the native library,
+// registered as go:type=Memory, so that container memory can be budgeted
+// across the JVM and Go.
+public interface GoMemoryMXBean {
+	// getHeapObjectsBytes returns the bytes of live and unswept objects in the
+	// Go heap, including the Go values referenced from Java.
+	long getHeapObjectsBytes();
+
+	// getTotalBytes returns all the memory mapped by the Go runtime.
+	long getTotalBytes();
+
+	// getHeapGoalBytes returns the heap size at which the next Go collection
+	// starts.
+	long getHeapGoalBytes();
+
+	long getGCCycles();
+
+	// getMemoryLimit returns the soft memory limit of Go in bytes, or
+	// Long.MAX_VALUE if there is none.
+	long getMemoryLimit();
+
+	void setMemoryLimit(long bytes);
+}
+`
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenRuntimeHooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := genRuntimeHooks(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	goPath := filepath.Join(tmpDir, "gojava_runtime.go")
+	if _, err := parser.ParseFile(token.NewFileSet(), goPath, nil, 0); err != nil {
+		t.Errorf("invalid Go source: %v", err)
+	}
+	c, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_runtime.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every native method of go.GoRuntime is implemented.
+	for _, m := range nativeDecl.FindAllStringSubmatch(goRuntimeJava, -1) {
+		if want := "Java_go_GoRuntime_" + m[4] + "("; !strings.Contains(string(c), want) {
+			t.Errorf("gojava_runtime.c missing %q:\n%s", want, c)
+		}
+	}
+}
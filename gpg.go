@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+)
+
+// gpgSign returns an ASCII armored detached signature of d, made with gpg
+// and the key key, or the default key if key is empty. gpg is $GOJAVA_GPG,
+// or found in $PATH. The passphrase of the key is read from
+// $GOJAVA_GPG_PASSPHRASE if set, otherwise gpg asks its agent for it.
+func gpgSign(d []byte, key string) ([]byte, error) {
+	tool := os.Getenv("GOJAVA_GPG")
+	if tool == "" {
+		tool = "gpg"
+	}
+	args := []string{"--batch", "--armor", "--detach-sign", "--output", "-"}
+	if key != "" {
+		args = append(args, "--local-user", key)
+	}
+	c := exec.CommandContext(buildCtx, tool)
+	if pass, ok := os.LookupEnv("GOJAVA_GPG_PASSPHRASE"); ok {
+		// Pass the passphrase through a pipe so it doesn't show up in the
+		// arguments of gpg.
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		go func() {
+			w.Write([]byte(pass))
+			w.Close()
+		}()
+		c.ExtraFiles = []*os.File{r}
+		args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "3")
+	}
+	c.Args = append(c.Args, args...)
+	c.Env = commandEnv()
+	c.Stdin = bytes.NewReader(d)
+	var out, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &out, &stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", tool, err, stderr.String())
+	}
+	return out.Bytes(), nil
+}
+
+// signArtifacts returns the .asc signatures of the artifacts of c, signed by
+// key.
+func signArtifacts(c coordinates, artifacts []artifact, key string) ([]artifact, error) {
+	var sigs []artifact
+	for _, a := range artifacts {
+		verbosef("Signing %s\n", path.Base(c.file(a.classifier, a.ext)))
+		sig, err := gpgSign(a.data, key)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, artifact{classifier: a.classifier, ext: a.ext + ".asc", data: sig})
+	}
+	return sigs, nil
+}
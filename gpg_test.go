@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSignArtifacts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as gpg")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tool := filepath.Join(tmpDir, "gpg")
+	// Signs with the arguments, the passphrase read from fd 3 and the data.
+	script := "#!/bin/sh\necho \"$@\"\nif [ -n \"$GOJAVA_GPG_PASSPHRASE\" ]; then cat <&3; echo; fi\ncat\n"
+	if err := ioutil.WriteFile(tool, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"GOJAVA_GPG", "GOJAVA_GPG_PASSPHRASE"} {
+		defer os.Setenv(v, os.Getenv(v))
+	}
+	os.Setenv("GOJAVA_GPG", tool)
+	os.Setenv("GOJAVA_GPG_PASSPHRASE", "secret")
+
+	c := coordinates{"com.example", "lib", "1.0.0"}
+	sigs, err := signArtifacts(c, []artifact{{ext: "jar", data: []byte("jar")}, {ext: "pom", data: []byte("pom")}}, "ABCD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 2 || sigs[0].ext != "jar.asc" || sigs[1].ext != "pom.asc" {
+		t.Fatalf("unexpected signatures %+v", sigs)
+	}
+	got := string(sigs[0].data)
+	for _, exp := range []string{"--detach-sign", "--local-user ABCD", "--passphrase-fd 3", "secret", "jar"} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("expected %q in %q", exp, got)
+		}
+	}
+	if strings.Contains(strings.SplitN(got, "\n", 2)[0], "secret") {
+		t.Errorf("the passphrase was passed as an argument: %q", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// healthCheck is a bound package function marked with a //gojava:health
+// directive, run by go.GoHealth.
+type healthCheck struct {
+	pkg *types.Package
+	fn  string
+	// readiness is set for readiness checks, which only affect whether the
+	// component is ready, rather than whether it is healthy.
+	readiness bool
+	// ctx is set if fn takes a context.Context.
+	ctx bool
+}
+
+// findHealthChecks returns the functions of pkgs marked with a
+// //gojava:health [liveness|readiness] directive, which must have the
+// signature func() error or func(context.Context) error.
+func findHealthChecks(pkgs []*types.Package, docs *docFinder) ([]healthCheck, error) {
+	var checks []healthCheck
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok {
+				continue
+			}
+			for _, dir := range docs.directives(fn) {
+				f := strings.Fields(dir)
+				if len(f) == 0 || f[0] != "health" {
+					continue
+				}
+				if len(f) > 2 || (len(f) == 2 && f[1] != "liveness" && f[1] != "readiness") {
+					return nil, fmt.Errorf("%s.%s: invalid directive //gojava:%s, expected //gojava:health [liveness|readiness]", p.Path(), name, dir)
+				}
+				sig := fn.Type().(*types.Signature)
+				params := sig.Params()
+				if !fn.Exported() || sig.Results().Len() != 1 || !isError(sig.Results().At(0).Type()) ||
+					params.Len() > 1 || (params.Len() == 1 && params.At(0).Type().String() != "context.Context") {
+					return nil, fmt.Errorf("%s.%s: health check must be exported with signature func() error or func(context.Context) error", p.Path(), name)
+				}
+				checks = append(checks, healthCheck{pkg: p, fn: name, readiness: len(f) == 2 && f[1] == "readiness", ctx: params.Len() == 1})
+			}
+		}
+	}
+	return checks, nil
+}
+
+// isHealthCheck reports whether fn is marked with a //gojava:health directive.
+func isHealthCheck(fn *types.Func, docs *docFinder) bool {
+	for _, dir := range docs.directives(fn) {
+		if f := strings.Fields(dir); len(f) > 0 && f[0] == "health" {
+			return true
+		}
+	}
+	return false
+}
+
+// genHealth writes go.GoHealth to javaDir if pkgs have health checks, or
+// services is set, and the Go and C code running the checks to bindDir. It
+// returns the paths of the Java files.
+func genHealth(bindDir, javaDir string, pkgs []*types.Package, docs *docFinder, services bool) ([]string, error) {
+	checks, err := findHealthChecks(pkgs, docs)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 && !services {
+		return nil, nil
+	}
+	var registrations bytes.Buffer
+	if len(checks) > 0 {
+		var imports, funcs bytes.Buffer
+		seen := make(map[*types.Package]int)
+		for i, c := range checks {
+			n, ok := seen[c.pkg]
+			if !ok {
+				n = len(seen)
+				seen[c.pkg] = n
+				fmt.Fprintf(&imports, "\thealth%d %q\n", n, c.pkg.Path())
+			}
+			if c.ctx {
+				fmt.Fprintf(&funcs, "\thealth%d.%s,\n", n, c.fn)
+			} else {
+				fmt.Fprintf(&funcs, "\tfunc(context.Context) error { return health%d.%s() },\n", n, c.fn)
+			}
+			verbosef("Registering health check %s.%s\n", c.pkg.Path(), c.fn)
+			fmt.Fprintf(&registrations, "\t\tregister(new GoCheck(%d, %q, %t));\n", i, c.pkg.Path()+"."+c.fn, c.readiness)
+		}
+		goSrc := fmt.Sprintf(healthGo, imports.String(), funcs.String())
+		if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_health.go"), []byte(goSrc), 0600); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_health.c"), []byte(healthC), 0600); err != nil {
+			return nil, err
+		}
+	}
+	path := filepath.Join(javaDir, "GoHealth.java")
+	if err := writeJavaFile(path, []byte(fmt.Sprintf(goHealthJava, registrations.String()))); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+const healthGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+%s)
+
+// gojavaHealthChecks are the functions marked with //gojava:health.
+var gojavaHealthChecks = []func(context.Context) error{
+%s}
+
+// gojava_health_check runs the health check i, and returns its error, or nil
+// if it passed. It fails if the check does not return within timeout
+// nanoseconds.
+//
+//export gojava_health_check
+func gojava_health_check(i C.int, timeout C.longlong) *C.char {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout))
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errc <- fmt.Errorf("panic: %%v", r)
+			}
+		}()
+		errc <- gojavaHealthChecks[i](ctx)
+	}()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %%v", time.Duration(timeout))
+	}
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+`
+
+const healthC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jstring JNICALL Java_go_GoHealth_check0(JNIEnv *env, jclass clazz, jint i, jlong timeout) {
+	char *err = gojava_health_check(i, timeout);
+	if (err == NULL) {
+		return NULL;
+	}
+	jstring s = (*env)->NewStringUTF(env, err);
+	free(err);
+	return s;
+}
+`
+
+const goHealthJava = `package go;
+
+import java.util.Collections;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.concurrent.CopyOnWriteArrayList;
+import java.util.concurrent.TimeUnit;
+
+// GoHealth aggregates the health of the Go components embedded in the JVM:
+// the Go functions marked with //gojava:health, the running go.GoService
+// instances, and checks registered from Java. A component is healthy if its
+// liveness checks pass, and ready if all its checks pass.
+public final class GoHealth {
+	private GoHealth() {}
+
+	// Check is a health check.
+	public interface Check {
+		String name();
+
+		// readiness reports whether the check only affects readiness.
+		boolean readiness();
+
+		// check returns null if the check passes, or the reason it fails.
+		String check();
+	}
+
+	// Status is the result of running the checks.
+	public static final class Status {
+		private final boolean healthy;
+		private final boolean ready;
+		private final Map<String, String> reasons;
+
+		Status(boolean healthy, boolean ready, Map<String, String> reasons) {
+			this.healthy = healthy;
+			this.ready = ready;
+			this.reasons = Collections.unmodifiableMap(reasons);
+		}
+
+		public boolean isHealthy() {
+			return healthy;
+		}
+
+		public boolean isReady() {
+			return ready;
+		}
+
+		// reasons maps the names of the failed checks to the reasons they failed.
+		public Map<String, String> reasons() {
+			return reasons;
+		}
+
+		@Override
+		public String toString() {
+			return (healthy ? (ready ? "READY" : "NOT_READY") : "UNHEALTHY") + (reasons.isEmpty() ? "" : " " + reasons);
+		}
+	}
+
+	private static final List<Check> checks = new CopyOnWriteArrayList<Check>();
+	private static volatile long timeoutNanos = TimeUnit.SECONDS.toNanos(5);
+
+	static {
+%s	}
+
+	public static void register(Check c) {
+		checks.add(c);
+	}
+
+	public static void unregister(Check c) {
+		checks.remove(c);
+	}
+
+	// setTimeout sets the time a Go health check may take before it fails.
+	public static void setTimeout(long timeout, TimeUnit unit) {
+		timeoutNanos = unit.toNanos(timeout);
+	}
+
+	// status runs all the checks.
+	public static Status status() {
+		boolean healthy = true;
+		boolean ready = true;
+		Map<String, String> reasons = new LinkedHashMap<String, String>();
+		for (Check c : checks) {
+			String reason;
+			try {
+				reason = c.check();
+			} catch (RuntimeException ex) {
+				reason = String.valueOf(ex);
+			}
+			if (reason == null) {
+				continue;
+			}
+			reasons.put(c.name(), reason);
+			ready = false;
+			if (!c.readiness()) {
+				healthy = false;
+			}
+		}
+		return new Status(healthy, ready, reasons);
+	}
+
+	public static boolean isHealthy() {
+		return status().isHealthy();
+	}
+
+	public static boolean isReady() {
+		return status().isReady();
+	}
+
+	// GoCheck runs a Go function marked with //gojava:health.
+	private static final class GoCheck implements Check {
+		private final int index;
+		private final String name;
+		private final boolean readiness;
+
+		GoCheck(int index, String name, boolean readiness) {
+			this.index = index;
+			this.name = name;
+			this.readiness = readiness;
+		}
+
+		@Override
+		public String name() {
+			return name;
+		}
+
+		@Override
+		public boolean readiness() {
+			return readiness;
+		}
+
+		@Override
+		public String check() {
+			Go.load();
+			return check0(index, timeoutNanos);
+		}
+	}
+
+	private static native String check0(int index, long timeoutNanos);
+}
+`
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const healthSrc = `package testpkg
+
+import "context"
+
+// Ping checks the connection.
+//
+//gojava:health
+func Ping() error { return nil }
+
+//gojava:health readiness
+func Warm(ctx context.Context) error { return nil }
+
+func Run(ctx context.Context) error { return nil }
+`
+
+// typeCheckFile type checks src, which may import the standard library, and
+// returns the package with a docFinder for its doc comments.
+func typeCheckFile(t *testing.T, src string) (*types.Package, *docFinder) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "src.go")
+	if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	p, err := conf.Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := newDocFinder(fset)
+	// Read the doc comments before the source is removed.
+	for _, name := range p.Scope().Names() {
+		docs.directives(p.Scope().Lookup(name))
+	}
+	return p, docs
+}
+
+func TestGenHealth(t *testing.T) {
+	p, docs := typeCheckFile(t, healthSrc)
+	pkgs := []*types.Package{p}
+	checks, err := findHealthChecks(pkgs, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []healthCheck{{p, "Ping", false, false}, {p, "Warm", true, true}}
+	if len(checks) != len(exp) || checks[0] != exp[0] || checks[1] != exp[1] {
+		t.Errorf("got %+v, expected %+v", checks, exp)
+	}
+	if s := findServices(pkgs, nil, docs); len(s) != 1 || s[0].fn != "Run" {
+		t.Errorf("health checks should not be services: got %+v", s)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files, err := genHealth(tmpDir, tmpDir, pkgs, docs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_health.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"\tfunc(context.Context) error { return health0.Ping() },\n", "\thealth0.Warm,\n"} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_health.go missing %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := "\t\tregister(new GoCheck(1, \"example.com/testpkg.Warm\", true));\n"; !strings.Contains(string(d), s) {
+		t.Errorf("GoHealth.java missing %q:\n%s", s, d)
+	}
+
+	none := typeCheck(t, "package testpkg\n")
+	if files, err := genHealth(tmpDir, tmpDir, []*types.Package{none}, docs, false); err != nil || files != nil {
+		t.Errorf("got %v, %v without checks or services", files, err)
+	}
+
+	for _, src := range []string{
+		"package testpkg\n\n//gojava:health\nfunc Ping() int { return 0 }\n",
+		"package testpkg\n\n//gojava:health startup\nfunc Ping() error { return nil }\n",
+	} {
+		p, docs := typeCheckFile(t, src)
+		if _, err := findHealthChecks([]*types.Package{p}, docs); err == nil {
+			t.Errorf("expected an error for:\n%s", src)
+		}
+	}
+}
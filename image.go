@@ -0,0 +1,404 @@
+package main
+
+// convertGoImage is the Go glue reading and writing images, with the aliases
+// %[1]s of image and %[2]s of image/draw. Images of the formats of go.GoImage
+// are passed without converting their pixels.
+const convertGoImage = `
+// readImage reads an image, keeping its pixels in the format Java sent.
+func (r *gojavaReader) readImage() %[1]s.Image {
+	f := r.next(1)[0]
+	if f == 0xff {
+		return nil
+	}
+	w, h, stride := int(r.readInt()), int(r.readInt()), int(r.readInt())
+	pix := r.readBytes()
+	bpp := 4
+	if f == 2 {
+		bpp = 1
+	}
+	if r.err == nil && (w < 0 || h < 0 || stride < w*bpp || (w > 0 && h > 0 && len(pix) < stride*(h-1)+w*bpp)) {
+		r.err = fmt.Errorf("gojava: invalid %%dx%%d image with stride %%d and %%d bytes", w, h, stride, len(pix))
+	}
+	if r.err != nil {
+		return nil
+	}
+	rect := %[1]s.Rect(0, 0, w, h)
+	switch f {
+	case 0:
+		return &%[1]s.RGBA{Pix: pix, Stride: stride, Rect: rect}
+	case 1:
+		return &%[1]s.NRGBA{Pix: pix, Stride: stride, Rect: rect}
+	case 2:
+		return &%[1]s.Gray{Pix: pix, Stride: stride, Rect: rect}
+	}
+	r.err = fmt.Errorf("gojava: unknown image format %%d", f)
+	return nil
+}
+
+func (r *gojavaReader) readRGBA() *%[1]s.RGBA {
+	img := r.readImage()
+	if m, ok := img.(*%[1]s.RGBA); ok || img == nil {
+		return m
+	}
+	m := %[1]s.NewRGBA(img.Bounds())
+	%[2]s.Draw(m, m.Rect, img, m.Rect.Min, %[2]s.Src)
+	return m
+}
+
+func (r *gojavaReader) readNRGBA() *%[1]s.NRGBA {
+	img := r.readImage()
+	if m, ok := img.(*%[1]s.NRGBA); ok || img == nil {
+		return m
+	}
+	m := %[1]s.NewNRGBA(img.Bounds())
+	%[2]s.Draw(m, m.Rect, img, m.Rect.Min, %[2]s.Src)
+	return m
+}
+
+func (r *gojavaReader) readGray() *%[1]s.Gray {
+	img := r.readImage()
+	if m, ok := img.(*%[1]s.Gray); ok || img == nil {
+		return m
+	}
+	m := %[1]s.NewGray(img.Bounds())
+	%[2]s.Draw(m, m.Rect, img, m.Rect.Min, %[2]s.Src)
+	return m
+}
+
+// writeImage writes the pixels of images in the formats of go.GoImage as
+// they are, and converts other images to NRGBA.
+func (w *gojavaWriter) writeImage(v %[1]s.Image) {
+	if v == nil {
+		w.b = append(w.b, 0xff)
+		return
+	}
+	b := v.Bounds()
+	if b.Empty() {
+		w.b = append(w.b, 1)
+		w.writeInt(0)
+		w.writeInt(0)
+		w.writeInt(0)
+		w.writeBytes([]byte{})
+		return
+	}
+	var f byte
+	var pix []byte
+	stride, bpp := 0, 4
+	switch m := v.(type) {
+	case *%[1]s.RGBA:
+		f, pix, stride = 0, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride
+	case *%[1]s.NRGBA:
+		f, pix, stride = 1, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride
+	case *%[1]s.Gray:
+		f, pix, stride, bpp = 2, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, 1
+	default:
+		n := %[1]s.NewNRGBA(b)
+		%[2]s.Draw(n, b, v, b.Min, %[2]s.Src)
+		f, pix, stride = 1, n.Pix, n.Stride
+	}
+	w.b = append(w.b, f)
+	w.writeInt(int64(b.Dx()))
+	w.writeInt(int64(b.Dy()))
+	w.writeInt(int64(stride))
+	w.writeBytes(pix[:stride*(b.Dy()-1)+b.Dx()*bpp])
+}
+
+func (w *gojavaWriter) writeRGBA(v *%[1]s.RGBA) {
+	if v == nil {
+		w.writeImage(nil)
+		return
+	}
+	w.writeImage(v)
+}
+
+func (w *gojavaWriter) writeNRGBA(v *%[1]s.NRGBA) {
+	if v == nil {
+		w.writeImage(nil)
+		return
+	}
+	w.writeImage(v)
+}
+
+func (w *gojavaWriter) writeGray(v *%[1]s.Gray) {
+	if v == nil {
+		w.writeImage(nil)
+		return
+	}
+	w.writeImage(v)
+}
+`
+
+const goImageJava = `package go;
+
+// GoImage holds the pixels of a Go image. Rows are stride bytes apart in pix,
+// starting at 0. RGBA pixels are 8-bit red, green, blue and alpha with the
+// colors premultiplied by alpha, like image.RGBA and the buffer of an Android
+// ARGB_8888 Bitmap. NRGBA pixels are not premultiplied, like image.NRGBA, and
+// GRAY pixels are one 8-bit level, like image.Gray.
+public final class GoImage {
+	public enum Format {
+		RGBA, NRGBA, GRAY;
+
+		public int bytesPerPixel() {
+			return this == GRAY ? 1 : 4;
+		}
+	}
+
+	private final Format format;
+	private final int width, height, stride;
+	private final byte[] pix;
+
+	// GoImage wraps pix without copying it.
+	public GoImage(Format format, int width, int height, int stride, byte[] pix) {
+		int bpp = format.bytesPerPixel();
+		if (width < 0 || height < 0 || stride < width * bpp || (width > 0 && height > 0 && pix.length < (long) stride * (height - 1) + width * bpp)) {
+			throw new IllegalArgumentException("invalid " + width + "x" + height + " " + format + " image with stride " + stride + " and " + pix.length + " bytes");
+		}
+		this.format = format;
+		this.width = width;
+		this.height = height;
+		this.stride = stride;
+		this.pix = pix;
+	}
+
+	// create returns a new transparent or black image.
+	public static GoImage create(Format format, int width, int height) {
+		return new GoImage(format, width, height, width * format.bytesPerPixel(), new byte[width * height * format.bytesPerPixel()]);
+	}
+
+	// fromArgb returns an NRGBA image with the colors of argb, as returned by
+	// BufferedImage.getRGB and Bitmap.getPixels.
+	public static GoImage fromArgb(int width, int height, int[] argb) {
+		GoImage img = create(Format.NRGBA, width, height);
+		for (int y = 0; y < height; y++) {
+			for (int x = 0; x < width; x++) {
+				img.setArgb(x, y, argb[y * width + x]);
+			}
+		}
+		return img;
+	}
+
+	public Format format() {
+		return format;
+	}
+
+	public int width() {
+		return width;
+	}
+
+	public int height() {
+		return height;
+	}
+
+	public int stride() {
+		return stride;
+	}
+
+	// pix returns the pixels. It is not a copy.
+	public byte[] pix() {
+		return pix;
+	}
+
+	// argb returns the color at x, y as a non-premultiplied ARGB int, as used
+	// by BufferedImage.getRGB and android.graphics.Color.
+	public int argb(int x, int y) {
+		int i = offset(x, y);
+		if (format == Format.GRAY) {
+			int g = pix[i] & 0xff;
+			return 0xff000000 | g << 16 | g << 8 | g;
+		}
+		int r = pix[i] & 0xff, g = pix[i + 1] & 0xff, b = pix[i + 2] & 0xff, a = pix[i + 3] & 0xff;
+		if (format == Format.RGBA && a != 0 && a != 0xff) {
+			r = Math.min(255, (r * 255 + a / 2) / a);
+			g = Math.min(255, (g * 255 + a / 2) / a);
+			b = Math.min(255, (b * 255 + a / 2) / a);
+		}
+		return a << 24 | r << 16 | g << 8 | b;
+	}
+
+	// setArgb sets the color at x, y to the non-premultiplied ARGB int argb.
+	public void setArgb(int x, int y, int argb) {
+		int i = offset(x, y);
+		int a = argb >>> 24, r = argb >> 16 & 0xff, g = argb >> 8 & 0xff, b = argb & 0xff;
+		if (format != Format.NRGBA && a != 0xff) {
+			r = (r * a + 127) / 255;
+			g = (g * a + 127) / 255;
+			b = (b * a + 127) / 255;
+		}
+		if (format == Format.GRAY) {
+			// The luminance computed by image/color.GrayModel.
+			pix[i] = (byte) ((19595 * r + 38470 * g + 7471 * b + (1 << 15)) >> 16);
+			return;
+		}
+		pix[i] = (byte) r;
+		pix[i + 1] = (byte) g;
+		pix[i + 2] = (byte) b;
+		pix[i + 3] = (byte) a;
+	}
+
+	// convert returns the image in format, which is img itself if it is
+	// already in format.
+	public GoImage convert(Format format) {
+		if (format == this.format) {
+			return this;
+		}
+		GoImage img = create(format, width, height);
+		for (int y = 0; y < height; y++) {
+			for (int x = 0; x < width; x++) {
+				img.setArgb(x, y, argb(x, y));
+			}
+		}
+		return img;
+	}
+
+	// toArgb returns the colors of the image as non-premultiplied ARGB ints,
+	// for BufferedImage.setRGB and Bitmap.createBitmap.
+	public int[] toArgb() {
+		int[] argb = new int[width * height];
+		for (int y = 0; y < height; y++) {
+			for (int x = 0; x < width; x++) {
+				argb[y * width + x] = argb(x, y);
+			}
+		}
+		return argb;
+	}
+
+	@Override
+	public String toString() {
+		return "GoImage[" + format + " " + width + "x" + height + "]";
+	}
+
+	private int offset(int x, int y) {
+		if (x < 0 || y < 0 || x >= width || y >= height) {
+			throw new IndexOutOfBoundsException(x + "," + y + " outside " + width + "x" + height + " image");
+		}
+		return y * stride + x * format.bytesPerPixel();
+	}
+}
+`
+
+const goImagesJava = `package go;
+
+import java.awt.Transparency;
+import java.awt.color.ColorSpace;
+import java.awt.image.BufferedImage;
+import java.awt.image.ColorModel;
+import java.awt.image.ComponentColorModel;
+import java.awt.image.DataBuffer;
+import java.awt.image.DataBufferByte;
+import java.awt.image.PixelInterleavedSampleModel;
+import java.awt.image.Raster;
+import java.awt.image.WritableRaster;
+import java.util.Arrays;
+
+// GoImages converts between go.GoImage and java.awt.image.BufferedImage,
+// sharing the pixels when their layouts match. It is separate from GoImage so
+// Android code does not load AWT classes.
+public final class GoImages {
+	private GoImages() {}
+
+	// toBufferedImage returns a BufferedImage backed by the pixels of img, so
+	// changes to one are seen in the other.
+	public static BufferedImage toBufferedImage(GoImage img) {
+		DataBufferByte buf = new DataBufferByte(img.pix(), img.pix().length);
+		if (img.format() == GoImage.Format.GRAY) {
+			WritableRaster raster = Raster.createInterleavedRaster(buf, img.width(), img.height(), img.stride(), 1, new int[] {0}, null);
+			ColorModel cm = new ComponentColorModel(ColorSpace.getInstance(ColorSpace.CS_GRAY), false, false, Transparency.OPAQUE, DataBuffer.TYPE_BYTE);
+			return new BufferedImage(cm, raster, false, null);
+		}
+		boolean premultiplied = img.format() == GoImage.Format.RGBA;
+		WritableRaster raster = Raster.createInterleavedRaster(buf, img.width(), img.height(), img.stride(), 4, new int[] {0, 1, 2, 3}, null);
+		ColorModel cm = new ComponentColorModel(ColorSpace.getInstance(ColorSpace.CS_sRGB), true, premultiplied, Transparency.TRANSLUCENT, DataBuffer.TYPE_BYTE);
+		return new BufferedImage(cm, raster, premultiplied, null);
+	}
+
+	// fromBufferedImage returns a GoImage sharing the pixels of img if they are
+	// 8-bit gray or RGBA in that order, such as images from toBufferedImage,
+	// and otherwise an NRGBA copy.
+	public static GoImage fromBufferedImage(BufferedImage img) {
+		GoImage shared = shared(img);
+		if (shared != null) {
+			return shared;
+		}
+		int w = img.getWidth(), h = img.getHeight();
+		return GoImage.fromArgb(w, h, img.getRGB(0, 0, w, h, null, 0, w));
+	}
+
+	private static GoImage shared(BufferedImage img) {
+		WritableRaster r = img.getRaster();
+		DataBuffer buf = r.getDataBuffer();
+		if (!(buf instanceof DataBufferByte) || buf.getNumBanks() != 1 || buf.getOffset() != 0 || r.getSampleModelTranslateX() != 0 || r.getSampleModelTranslateY() != 0) {
+			return null;
+		}
+		if (!(r.getSampleModel() instanceof PixelInterleavedSampleModel) || !(img.getColorModel() instanceof ComponentColorModel)) {
+			return null;
+		}
+		PixelInterleavedSampleModel sm = (PixelInterleavedSampleModel) r.getSampleModel();
+		ColorModel cm = img.getColorModel();
+		byte[] pix = ((DataBufferByte) buf).getData();
+		int[] offsets = sm.getBandOffsets();
+		if (cm.getColorSpace().getType() == ColorSpace.TYPE_GRAY && !cm.hasAlpha() && sm.getPixelStride() == 1 && Arrays.equals(offsets, new int[] {0})) {
+			return new GoImage(GoImage.Format.GRAY, img.getWidth(), img.getHeight(), sm.getScanlineStride(), pix);
+		}
+		if (cm.getColorSpace().isCS_sRGB() && cm.hasAlpha() && sm.getPixelStride() == 4 && Arrays.equals(offsets, new int[] {0, 1, 2, 3})) {
+			GoImage.Format f = cm.isAlphaPremultiplied() ? GoImage.Format.RGBA : GoImage.Format.NRGBA;
+			return new GoImage(f, img.getWidth(), img.getHeight(), sm.getScanlineStride(), pix);
+		}
+		return null;
+	}
+}
+`
+
+// goBitmapsJava converts between go.GoImage and android.graphics.Bitmap. It
+// is written to the module of -android-lifecycle, with the package %s, as the
+// jar is not built against the Android SDK.
+const goBitmapsJava = `// Android Bitmap support generated by gojava.
+package %s;
+
+import android.graphics.Bitmap;
+import go.GoImage;
+import java.nio.ByteBuffer;
+
+// GoBitmaps converts between go.GoImage and Bitmap. The pixels of an ARGB_8888
+// bitmap are premultiplied RGBA bytes, the layout of GoImage.Format.RGBA, so
+// they are copied once, without converting them, when the layouts match.
+public final class GoBitmaps {
+	private GoBitmaps() {}
+
+	// toBitmap returns an ARGB_8888 bitmap with the pixels of img, which are
+	// converted to RGBA if they are in another format. Bitmaps cannot be
+	// empty, so it throws IllegalArgumentException for an empty image.
+	public static Bitmap toBitmap(GoImage img) {
+		int w = img.width(), h = img.height();
+		if (w == 0 || h == 0) {
+			throw new IllegalArgumentException("cannot create a bitmap from an empty " + img);
+		}
+		img = img.convert(GoImage.Format.RGBA);
+		byte[] pix = img.pix();
+		int rowBytes = w * 4;
+		if (img.stride() != rowBytes) {
+			pix = new byte[rowBytes * h];
+			for (int y = 0; y < h; y++) {
+				System.arraycopy(img.pix(), y * img.stride(), pix, y * rowBytes, rowBytes);
+			}
+		}
+		Bitmap bitmap = Bitmap.createBitmap(w, h, Bitmap.Config.ARGB_8888);
+		bitmap.copyPixelsFromBuffer(ByteBuffer.wrap(pix, 0, rowBytes * h));
+		return bitmap;
+	}
+
+	// fromBitmap returns a GoImage with a copy of the pixels of bitmap, RGBA
+	// if they are premultiplied and NRGBA otherwise. Bitmaps in other
+	// configurations, including hardware bitmaps, are copied to ARGB_8888
+	// first.
+	public static GoImage fromBitmap(Bitmap bitmap) {
+		if (bitmap.getConfig() != Bitmap.Config.ARGB_8888) {
+			bitmap = bitmap.copy(Bitmap.Config.ARGB_8888, false);
+		}
+		byte[] pix = new byte[bitmap.getRowBytes() * bitmap.getHeight()];
+		bitmap.copyPixelsToBuffer(ByteBuffer.wrap(pix));
+		GoImage.Format f = bitmap.isPremultiplied() ? GoImage.Format.RGBA : GoImage.Format.NRGBA;
+		return new GoImage(f, bitmap.getWidth(), bitmap.getHeight(), bitmap.getRowBytes(), pix);
+	}
+}
+`
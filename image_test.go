@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// imageGlueTest checks the bounds handling of the image glue, and prints ok
+// if it passes.
+const imageGlueTest = `
+func encode(f byte, w, h, stride int64, pix []byte) []byte {
+	e := &gojavaWriter{b: []byte{f}}
+	e.writeInt(w)
+	e.writeInt(h)
+	e.writeInt(stride)
+	e.writeBytes(pix)
+	return e.b
+}
+
+func roundTrip(m image.Image) (image.Image, byte, error) {
+	w := &gojavaWriter{}
+	w.writeImage(m)
+	r := &gojavaReader{b: w.b}
+	return r.readImage(), w.b[0], r.err
+}
+
+// same reports whether a and b have the same size and colors.
+func same(a, b image.Image) bool {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return false
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y))
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if ca != cb {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func fill(m draw.Image) draw.Image {
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			m.Set(x, y, color.NRGBA{uint8(x * 40), uint8(y * 40), uint8(x + y), 255})
+		}
+	}
+	return m
+}
+
+func main() {
+	failed := false
+	fail := func(format string, a ...interface{}) {
+		fmt.Printf(format+"\n", a...)
+		failed = true
+	}
+
+	// Zero-size images.
+	for _, m := range []image.Image{
+		image.NewRGBA(image.Rect(0, 0, 0, 0)),
+		image.NewNRGBA(image.Rect(0, 0, 5, 0)),
+		image.NewGray(image.Rect(2, 2, 2, 7)),
+	} {
+		got, _, err := roundTrip(m)
+		if err != nil || got == nil || !got.Bounds().Empty() {
+			fail("empty %T: got %v, %v", m, got, err)
+		}
+	}
+	for _, tc := range []struct {
+		f                byte
+		w, h, stride int64
+	}{
+		{0, 0, 0, 0}, {1, 3, 0, 12}, {2, 0, 4, 0},
+	} {
+		r := &gojavaReader{b: encode(tc.f, tc.w, tc.h, tc.stride, []byte{})}
+		if m := r.readImage(); r.err != nil || m == nil || !m.Bounds().Empty() {
+			fail("read empty %+v: got %v, %v", tc, m, r.err)
+		}
+	}
+
+	// Strides larger than the width: sub-images are sent without copying,
+	// with the stride of the image they are in.
+	for _, m := range []draw.Image{
+		fill(image.NewRGBA(image.Rect(0, 0, 4, 3))),
+		fill(image.NewNRGBA(image.Rect(0, 0, 4, 3))),
+		fill(image.NewGray(image.Rect(0, 0, 4, 3))),
+	} {
+		sub := m.(interface {
+			SubImage(image.Rectangle) image.Image
+		}).SubImage(image.Rect(1, 1, 3, 3))
+		got, _, err := roundTrip(sub)
+		if err != nil || !same(sub, got) {
+			fail("sub-image of %T: got %v, %v", m, got, err)
+		}
+	}
+	r := &gojavaReader{b: encode(0, 2, 2, 16, make([]byte, 24))}
+	if m := r.readImage(); r.err != nil || m.Bounds() != image.Rect(0, 0, 2, 2) || m.(*image.RGBA).Stride != 16 {
+		fail("read stride 16: got %v, %v", m, r.err)
+	}
+
+	// Invalid bounds.
+	for _, tc := range []struct {
+		f                byte
+		w, h, stride int64
+		n                int
+	}{
+		{0, 2, 2, 16, 23},
+		{0, 2, 2, 7, 16},
+		{2, 4, 1, 3, 4},
+		{1, -1, 2, 0, 0},
+		{1, 2, -1, 8, 0},
+		{3, 1, 1, 4, 4},
+	} {
+		r := &gojavaReader{b: encode(tc.f, tc.w, tc.h, tc.stride, make([]byte, tc.n))}
+		if m := r.readImage(); r.err == nil {
+			fail("read invalid %+v: got %v", tc, m)
+		}
+	}
+
+	// Images in other formats are sent as NRGBA, and converted to the type
+	// the function takes.
+	pal := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black, color.White, color.NRGBA{255, 0, 0, 128}})
+	pal.SetColorIndex(1, 0, 1)
+	pal.SetColorIndex(2, 1, 2)
+	for _, m := range []image.Image{
+		pal,
+		fill(image.NewRGBA64(image.Rect(0, 0, 3, 2))),
+		fill(image.NewGray16(image.Rect(0, 0, 3, 2))),
+		image.NewYCbCr(image.Rect(0, 0, 3, 2), image.YCbCrSubsampleRatio420),
+	} {
+		got, f, err := roundTrip(m)
+		if err != nil || f != 1 || !same(m, got) {
+			fail("%T: got format %d, %v, %v", m, f, got, err)
+		}
+	}
+	w := &gojavaWriter{}
+	w.writeImage(fill(image.NewNRGBA(image.Rect(0, 0, 2, 2))))
+	r = &gojavaReader{b: w.b}
+	if m := r.readRGBA(); r.err != nil || m.Bounds() != image.Rect(0, 0, 2, 2) || m.RGBAAt(1, 1) != (color.RGBA{40, 40, 2, 255}) {
+		fail("readRGBA of NRGBA: got %v, %v", m, r.err)
+	}
+	if !failed {
+		fmt.Println("ok")
+	}
+}
+`
+
+func TestImageGlue(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	body := strings.Replace(fmt.Sprintf(convertGoBody, "", wireGoHelpers(map[string]string{"image": "image", "image/draw": "draw"})), "package gojava_bind", "", 1)
+	src := "package main\n\nimport (\n\t\"encoding/binary\"\n\t\"errors\"\n\t\"fmt\"\n\t\"image\"\n\t\"image/color\"\n\t\"image/draw\"\n\t\"math\"\n)\n" + body + imageGlueTest
+	path := filepath.Join(tmpDir, "main.go")
+	if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", path)
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil || string(out) != "ok\n" {
+		t.Errorf("image glue: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// initHookName is the name of the function a bound package can declare to be
+// called when the native library is loaded. It must have the signature
+// func() or func() error.
+const initHookName = "GojavaInit"
+
+// initHook returns the init hook declared in p, if any, and whether it
+// returns an error.
+func initHook(p *types.Package) (*types.Func, bool, error) {
+	fn, ok := p.Scope().Lookup(initHookName).(*types.Func)
+	if !ok {
+		return nil, false, nil
+	}
+	sig := fn.Type().(*types.Signature)
+	switch {
+	case sig.Recv() != nil || sig.Params().Len() != 0 || sig.Results().Len() > 1:
+	case sig.Results().Len() == 0:
+		return fn, false, nil
+	case isError(sig.Results().At(0).Type()):
+		return fn, true, nil
+	}
+	return nil, false, fmt.Errorf("%s.%s must have signature func() or func() error", p.Path(), initHookName)
+}
+
+// genInitHooks writes the Go and C code to bindDir that runs the init hooks of
+// pkgs, in order, when the native library is loaded. The first error returned
+// by a hook is thrown as a RuntimeException from the static initializer of
+// LoadJNI, which the JVM reports as an ExceptionInInitializerError.
+func genInitHooks(bindDir string, pkgs []*types.Package) error {
+	var imports, calls bytes.Buffer
+	for i, p := range pkgs {
+		fn, returnsErr, err := initHook(p)
+		if err != nil {
+			return err
+		}
+		if fn == nil {
+			continue
+		}
+		verbosef("Registering init hook %s.%s\n", p.Path(), initHookName)
+		fmt.Fprintf(&imports, "\tpkg%d %q\n", i, p.Path())
+		if returnsErr {
+			fmt.Fprintf(&calls, "\tif err := pkg%d.%s(); err != nil {\n\t\treturn C.CString(%q + err.Error())\n\t}\n", i, initHookName, p.Path()+": ")
+		} else {
+			fmt.Fprintf(&calls, "\tpkg%d.%s()\n", i, initHookName)
+		}
+	}
+	importDecl := ""
+	if imports.Len() > 0 {
+		importDecl = "import (\n" + imports.String() + ")\n"
+	}
+	goSrc := fmt.Sprintf(initHooksGo, importDecl, calls.String())
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_init.go"), []byte(goSrc), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_init.c"), []byte(initHooksC), 0600)
+}
+
+const initHooksGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+%s
+//export gojava_init
+func gojava_init() *C.char {
+%s	return nil
+}
+`
+
+const initHooksC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT void JNICALL Java_go_LoadJNI_init(JNIEnv *env, jclass clazz) {
+	char *err = gojava_init();
+	if (err != NULL) {
+		(*env)->ThrowNew(env, (*env)->FindClass(env, "java/lang/RuntimeException"), err);
+		free(err);
+	}
+}
+`
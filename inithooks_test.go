@@ -0,0 +1,45 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenInitHooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	withErr := typeCheck(t, "package testpkg\n\nfunc GojavaInit() error { return nil }\n")
+	noHook := typeCheck(t, "package testpkg\n")
+	if err := genInitHooks(tmpDir, []*types.Package{noHook, withErr}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_init.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\tpkg1 \"example.com/testpkg\"\n",
+		"\tif err := pkg1.GojavaInit(); err != nil {\n",
+		"//export gojava_init\n",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_init.go missing %q:\n%s", s, d)
+		}
+	}
+	if strings.Contains(string(d), "pkg0") {
+		t.Errorf("package without hook should not be imported:\n%s", d)
+	}
+
+	bad := typeCheck(t, "package testpkg\n\nfunc GojavaInit() int { return 0 }\n")
+	if err := genInitHooks(tmpDir, []*types.Package{bad}); err == nil {
+		t.Error("expected error for invalid init hook signature")
+	}
+}
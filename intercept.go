@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nativeSuffix is appended to the names of native methods wrapped by
+// wrapNatives.
+const nativeSuffix = "_native"
+
+// nativeDecl matches the declaration of a native method.
+var nativeDecl = regexp.MustCompile(`(?m)^([ \t]*)((?:\w+ )*)native ([^\s(]+) ([\p{L}\p{N}_$]+)\(([^)]*)\)(\s*throws [\w.]+(?:,\s*[\w.]+)*)?;[ \t]*\n`)
+
+// callLimit caps the number of concurrent calls to a bound method, set with a
+// //gojava:limit N [reject] directive.
+type callLimit struct {
+	max int
+	// reject fails calls when max calls are in flight, instead of blocking.
+	reject bool
+}
+
+// findLimits returns the call limits of the Java methods generated for p,
+// keyed by qualified Java name.
+func findLimits(p *types.Package, docs *docFinder) (map[string]callLimit, error) {
+	limits := make(map[string]callLimit)
+	var err error
+	forEachMember(p, false, func(class, member string, method bool, obj types.Object) {
+		if !method || err != nil {
+			return
+		}
+		for _, dir := range docs.directives(obj) {
+			f := strings.Fields(dir)
+			if len(f) == 0 || f[0] != "limit" {
+				continue
+			}
+			var l callLimit
+			if len(f) < 2 || len(f) > 3 || (len(f) == 3 && f[2] != "reject") {
+				err = fmt.Errorf("%s: invalid directive //gojava:%s, expected //gojava:limit N [reject]", obj.Name(), dir)
+				return
+			}
+			if l.max, err = strconv.Atoi(f[1]); err != nil || l.max <= 0 {
+				err = fmt.Errorf("%s: invalid call limit %q", obj.Name(), f[1])
+				return
+			}
+			l.reject = len(f) == 3
+			limits[strings.Replace(class, "$", ".", -1)+"."+member] = l
+		}
+	})
+	return limits, err
+}
+
+// nativeMethod is a native method declaration wrapped by wrapNatives.
+type nativeMethod struct {
+	indent, modifiers, ret, name, params, throws string
+	// method is the qualified Java name of the method.
+	method string
+}
+
+// genWrapper returns the Java declarations replacing the native method m: the
+// native renamed with nativeSuffix, and a method of the original name calling
+// it through the interceptor registered with go.Go if intercept is set, and
+// guarded by a semaphore if limit is set. Interceptors implementing
+// go.GoResultInterceptor are also passed the result of successful calls.
+func genWrapper(m nativeMethod, intercept bool, limit *callLimit) string {
+	var args []string
+	for _, p := range strings.Split(m.params, ",") {
+		if f := strings.Fields(p); len(f) > 0 {
+			args = append(args, f[len(f)-1])
+		}
+	}
+	call := fmt.Sprintf("%s%s(%s)", m.name, nativeSuffix, strings.Join(args, ", "))
+	unit := "\t"
+	if strings.HasPrefix(m.indent, " ") {
+		unit = "    "
+	}
+	depth := 1
+	var b bytes.Buffer
+	line := func(format string, a ...interface{}) {
+		b.WriteString(m.indent + strings.Repeat(unit, depth))
+		fmt.Fprintf(&b, format, a...)
+		b.WriteByte('\n')
+	}
+	private := strings.Replace(strings.Replace(m.modifiers, "public ", "", 1), "protected ", "", 1)
+	fmt.Fprintf(&b, "%sprivate %snative %s %s%s(%s)%s;\n\n", m.indent, private, m.ret, m.name, nativeSuffix, m.params, m.throws)
+	sem := "gojavaLimit_" + m.name
+	if limit != nil {
+		// The semaphore is static so the limit holds across all receivers.
+		fmt.Fprintf(&b, "%sprivate static final java.util.concurrent.Semaphore %s = new java.util.concurrent.Semaphore(%d);\n\n", m.indent, sem, limit.max)
+	}
+	fmt.Fprintf(&b, "%s%s%s %s(%s)%s {\n", m.indent, m.modifiers, m.ret, m.name, m.params, m.throws)
+	if limit != nil {
+		if limit.reject {
+			line("if (!%s.tryAcquire()) {", sem)
+			line("%sthrow new go.GoRejectedException(%q);", unit, m.method)
+			line("}")
+		} else {
+			line("%s.acquireUninterruptibly();", sem)
+		}
+		line("try {")
+		depth++
+	}
+	ret := func(expr string) {
+		if m.ret == "void" {
+			line("%s;", expr)
+			line("return;")
+		} else {
+			line("return %s;", expr)
+		}
+	}
+	if !intercept {
+		ret(call)
+	} else {
+		line("go.GoInterceptor gojavaInterceptor = go.Go.callInterceptor();")
+		line("if (gojavaInterceptor == null) {")
+		depth++
+		ret(call)
+		depth--
+		line("}")
+		line("Object gojavaState = gojavaInterceptor.before(%q, new Object[] {%s});", m.method, strings.Join(args, ", "))
+		line("long gojavaStart = System.nanoTime();")
+		line("try {")
+		depth++
+		result := "gojavaResult"
+		if m.ret == "void" {
+			line("%s;", call)
+			result = "null"
+		} else {
+			line("%s gojavaResult = %s;", m.ret, call)
+		}
+		line("if (gojavaInterceptor instanceof go.GoResultInterceptor) {")
+		line("%s((go.GoResultInterceptor) gojavaInterceptor).result(%q, gojavaState, %s);", unit, m.method, result)
+		line("}")
+		line("gojavaInterceptor.after(%q, gojavaState, System.nanoTime() - gojavaStart, null);", m.method)
+		if m.ret != "void" {
+			line("return gojavaResult;")
+		}
+		caught := []string{"RuntimeException", "Error"}
+		if m.throws != "" {
+			caught = []string{"Exception", "Error"}
+		}
+		for _, c := range caught {
+			depth--
+			line("} catch (%s gojavaErr) {", c)
+			depth++
+			line("gojavaInterceptor.after(%q, gojavaState, System.nanoTime() - gojavaStart, gojavaErr);", m.method)
+			line("throw gojavaErr;")
+		}
+		depth--
+		line("}")
+	}
+	if limit != nil {
+		depth--
+		line("} finally {")
+		line("%s%s.release();", unit, sem)
+		line("}")
+	}
+	fmt.Fprintf(&b, "%s}\n", m.indent)
+	return b.String()
+}
+
+// wrapNatives rewrites the generated Java file for p at javaPath, and the JNI
+// glue in cPaths, renaming native methods and wrapping them in methods of the
+// original name. If intercept is set every native method calls the
+// go.GoInterceptor registered with go.Go.setInterceptor, and methods in limits
+// are guarded by a semaphore.
+func wrapNatives(javaPath string, p *types.Package, cPaths []string, intercept bool, limits map[string]callLimit) error {
+	if !intercept && len(limits) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		return err
+	}
+	class := javaClassName(p)
+	loc := regexp.MustCompile(`(?m)^.*\bclass\s+` + class + `\b`).FindIndex(src)
+	if loc == nil {
+		return fmt.Errorf("%s: class %s not found", javaPath, class)
+	}
+	nested := findNestedTypes(src[loc[0]:])
+	var out bytes.Buffer
+	var symbols []string
+	last := 0
+	for _, m := range nativeDecl.FindAllSubmatchIndex(src, -1) {
+		sub := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return string(src[m[2*i]:m[2*i+1]])
+		}
+		owner, binary := javaPkgName(p)+"."+class, javaPkgName(p)+"."+class
+		for _, t := range nested {
+			if m[0] >= loc[0]+t.start && m[0] < loc[0]+t.end {
+				owner, binary = owner+"."+t.name, binary+"$"+t.name
+			}
+		}
+		nm := nativeMethod{indent: sub(1), modifiers: sub(2), ret: sub(3), name: sub(4), params: sub(5), throws: sub(6), method: owner + "." + sub(4)}
+		var limit *callLimit
+		if l, ok := limits[nm.method]; ok {
+			limit = &l
+		}
+		if !intercept && limit == nil {
+			continue
+		}
+		out.Write(src[last:m[0]])
+		out.WriteString(genWrapper(nm, intercept, limit))
+		last = m[1]
+		symbols = append(symbols, "Java_"+jniMangle(strings.Replace(binary, ".", "/", -1))+"_"+jniMangle(nm.name))
+	}
+	out.Write(src[last:])
+	if err := ioutil.WriteFile(javaPath, out.Bytes(), 0600); err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(symbols))
+	for i, s := range symbols {
+		quoted[i] = regexp.QuoteMeta(s)
+	}
+	jniFunc := regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)(\s*\()`)
+	suffix := jniMangle(nativeSuffix)
+	for _, path := range cPaths {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		d = jniFunc.ReplaceAll(d, []byte("${1}"+suffix+"${2}"))
+		if err := ioutil.WriteFile(path, d, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
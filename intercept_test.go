@@ -0,0 +1,186 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const interceptJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class S extends Seq.Proxy {
+        public final native long getX();
+        public native void setName(String start, byte[] b) throws Exception;
+    }
+
+    public static native long add(long a, long b);
+}
+`
+
+const interceptC = `JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_00024S_getX(JNIEnv *env, jobject this) {}
+JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_add(JNIEnv *env, jclass clazz, jlong a, jlong b) {}
+`
+
+func TestWrapNativesIntercept(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javaPath, cPath := filepath.Join(tmpDir, "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	if err := ioutil.WriteFile(javaPath, []byte(interceptJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(interceptC), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := wrapNatives(javaPath, typeCheck(t, "package testpkg"), []string{cPath}, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		javaPath: {
+			"        private final native long getX_native();\n\n        public final long getX() {\n",
+			"go.GoInterceptor gojavaInterceptor = go.Go.callInterceptor();",
+			`gojavaInterceptor.before("go.testpkg.Testpkg.S.getX", new Object[] {});`,
+			"private native void setName_native(String start, byte[] b) throws Exception;",
+			"public void setName(String start, byte[] b) throws Exception {",
+			"                setName_native(start, b);\n                return;",
+			"} catch (Exception gojavaErr) {",
+			"    private static native long add_native(long a, long b);",
+			`gojavaInterceptor.before("go.testpkg.Testpkg.add", new Object[] {a, b});`,
+			"        long gojavaResult = add_native(a, b);",
+			`((go.GoResultInterceptor) gojavaInterceptor).result("go.testpkg.Testpkg.add", gojavaState, gojavaResult);`,
+			`((go.GoResultInterceptor) gojavaInterceptor).result("go.testpkg.Testpkg.S.setName", gojavaState, null);`,
+			"} catch (RuntimeException gojavaErr) {",
+		},
+		cPath: {"Java_go_testpkg_Testpkg_00024S_getX_1native(", "Java_go_testpkg_Testpkg_add_1native("},
+	}
+	for path, want := range expected {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+}
+
+const limitSrc = `package testpkg
+
+type S struct{ X int }
+
+// F is slow.
+//
+//gojava:limit 2
+func (s *S) F() {}
+
+//gojava:limit 4 reject
+func Add(a, b int) int { return a + b }
+`
+
+const limitJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class S extends Seq.Proxy {
+        public final native long getX();
+        public native void f();
+    }
+
+    public static native long add(long a, long b);
+}
+`
+
+const limitC = `JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_00024S_getX(JNIEnv *env, jobject this) {}
+JNIEXPORT void JNICALL Java_go_testpkg_Testpkg_00024S_f(JNIEnv *env, jobject this) {}
+JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_add(JNIEnv *env, jclass clazz, jlong a, jlong b) {}
+`
+
+func TestWrapNativesLimits(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath, javaPath, cPath := filepath.Join(tmpDir, "s.go"), filepath.Join(tmpDir, "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	for path, src := range map[string]string{goPath: limitSrc, javaPath: limitJava, cPath: limitC} {
+		if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goPath, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits, err := findLimits(p, newDocFinder(fset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wrapNatives(javaPath, p, []string{cPath}, false, limits); err != nil {
+		t.Fatal(err)
+	}
+	java, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"        public final native long getX();",
+		"        private static final java.util.concurrent.Semaphore gojavaLimit_f = new java.util.concurrent.Semaphore(2);",
+		"            gojavaLimit_f.acquireUninterruptibly();\n            try {\n                f_native();",
+		"    private static final java.util.concurrent.Semaphore gojavaLimit_add = new java.util.concurrent.Semaphore(4);",
+		"        if (!gojavaLimit_add.tryAcquire()) {\n            throw new go.GoRejectedException(\"go.testpkg.Testpkg.add\");",
+		"        } finally {\n            gojavaLimit_add.release();\n        }",
+	} {
+		if !strings.Contains(string(java), s) {
+			t.Errorf("Testpkg.java missing %q:\n%s", s, java)
+		}
+	}
+	c, err := ioutil.ReadFile(cPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Replace(limitC, "_f(", "_f_1native(", 1), "_add(", "_add_1native(", 1)
+	if string(c) != want {
+		t.Errorf("got C:\n%s\nwant:\n%s", c, want)
+	}
+}
+
+func TestFindLimitsInvalid(t *testing.T) {
+	for _, dir := range []string{"limit", "limit 0", "limit x", "limit 2 drop"} {
+		tmpDir, err := ioutil.TempDir("", "gojavatest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		goPath := filepath.Join(tmpDir, "s.go")
+		if err := ioutil.WriteFile(goPath, []byte("package testpkg\n\n//gojava:"+dir+"\nfunc F() {}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, goPath, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := findLimits(p, newDocFinder(fset)); err == nil {
+			t.Errorf("//gojava:%s: expected error", dir)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+)
+
+// iterator describes a Go type that is iterated by calling an advance method,
+// such as Next or Scan, and reading the current element with a value method.
+type iterator struct {
+	advance, value string
+	// key is the method returning the key of the current element, if the
+	// elements are key and value pairs.
+	key string
+	// elem is the Java type of the elements, and entry the type arguments
+	// of their java.util.Map.Entry type if key is set.
+	elem, entry string
+	// err is set if the type has an Err() error method, reporting the error
+	// that stopped the iteration.
+	err bool
+}
+
+// findIterator returns the iterator shape of *n, or false if it has none.
+func findIterator(n *types.Named) (iterator, bool) {
+	var it iterator
+	for _, name := range []string{"Next", "Scan"} {
+		if sig := methodSig(n, name); sig != nil && sig.Params().Len() == 0 && sig.Results().Len() == 1 && isBasic(sig.Results().At(0).Type(), types.IsBoolean) {
+			it.advance = name
+			break
+		}
+	}
+	if it.advance == "" {
+		return it, false
+	}
+	for _, name := range []string{"Value", "Text", "Bytes"} {
+		sig := methodSig(n, name)
+		if sig == nil || sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			continue
+		}
+		jt, err := javaType(sig.Results().At(0).Type())
+		if err != nil {
+			continue
+		}
+		if b, ok := boxedTypes[jt]; ok {
+			jt = b
+		}
+		it.value, it.elem = name, jt
+		break
+	}
+	if it.value == "" {
+		return it, false
+	}
+	if sig := methodSig(n, "Err"); sig != nil && sig.Params().Len() == 0 && sig.Results().Len() == 1 && isError(sig.Results().At(0).Type()) {
+		it.err = true
+	}
+	return it, true
+}
+
+// genIterator returns the Java iterator method of the class name for it.
+// The iterator is single use, as it consumes the Go iterator.
+func genIterator(name string, it iterator) string {
+	self := name + ".this."
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\n\t\t@Override\n\t\tpublic java.util.Iterator<%s> iterator() {\n", it.elem)
+	fmt.Fprintf(&b, "\t\t\treturn new java.util.Iterator<%s>() {\n", it.elem)
+	fmt.Fprintf(&b, "\t\t\t\tprivate boolean advanced, more;\n\n")
+	fmt.Fprintf(&b, "\t\t\t\t@Override\n\t\t\t\tpublic boolean hasNext() {\n")
+	fmt.Fprintf(&b, "\t\t\t\t\tif (!advanced) {\n\t\t\t\t\t\tmore = %s%s();\n\t\t\t\t\t\tadvanced = true;\n", self, javaMethodName(it.advance))
+	if it.err {
+		fmt.Fprintf(&b, "\t\t\t\t\t\tif (!more) {\n\t\t\t\t\t\t\ttry {\n\t\t\t\t\t\t\t\t%serr();\n", self)
+		fmt.Fprintf(&b, "\t\t\t\t\t\t\t} catch (Exception ex) {\n\t\t\t\t\t\t\t\tthrow new RuntimeException(ex);\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t}\n")
+	}
+	fmt.Fprintf(&b, "\t\t\t\t\t}\n\t\t\t\t\treturn more;\n\t\t\t\t}\n\n")
+	fmt.Fprintf(&b, "\t\t\t\t@Override\n\t\t\t\tpublic %s next() {\n", it.elem)
+	fmt.Fprintf(&b, "\t\t\t\t\tif (!hasNext()) {\n\t\t\t\t\t\tthrow new java.util.NoSuchElementException();\n\t\t\t\t\t}\n")
+	fmt.Fprintf(&b, "\t\t\t\t\tadvanced = false;\n")
+	if it.key != "" {
+		fmt.Fprintf(&b, "\t\t\t\t\treturn new java.util.AbstractMap.SimpleImmutableEntry<%s>(%s%s(), %s%s());\n\t\t\t\t}\n\n", it.entry, self, javaMethodName(it.key), self, javaMethodName(it.value))
+	} else {
+		fmt.Fprintf(&b, "\t\t\t\t\treturn %s%s();\n\t\t\t\t}\n\n", self, javaMethodName(it.value))
+	}
+	fmt.Fprintf(&b, "\t\t\t\t@Override\n\t\t\t\tpublic void remove() {\n\t\t\t\t\tthrow new UnsupportedOperationException();\n\t\t\t\t}\n")
+	fmt.Fprintf(&b, "\t\t\t};\n\t\t}\n")
+	return b.String()
+}
+
+// addIterators rewrites the generated Java file for p at path, making the
+// classes of struct types with Next() bool or Scan() bool and Value(), Text()
+// or Bytes() methods implement Iterable, so they can be used in for loops.
+// The adapters of functions returning an iter.Seq2 iterate the key and value
+// pairs as java.util.Map.Entry elements, and all adapters are AutoCloseable.
+func addIterators(path string, p *types.Package) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	changed := false
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok {
+			continue
+		}
+		it, ok := findIterator(n)
+		if !ok {
+			continue
+		}
+		decl, start, end, err := classBody(src, p, name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if len(memberDecls(src, start, end, "iterator")) > 0 {
+			continue
+		}
+		seq, isSeq := seqFuncOf(p, name)
+		if isSeq && seq.key != nil {
+			key, err := javaType(seq.key)
+			if err != nil {
+				return err
+			}
+			if b, ok := boxedTypes[key]; ok {
+				key = b
+			}
+			it.key, it.entry = "Key", key+", "+it.elem
+			it.elem = "java.util.Map.Entry<" + it.entry + ">"
+		}
+		ifaces := "Iterable<" + it.elem + ">"
+		if isSeq {
+			ifaces += ", AutoCloseable"
+		}
+		src = implement(src, decl, start-1, ifaces)
+		if src, err = insertIntoClass(src, name, genIterator(name, it)); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
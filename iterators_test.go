@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const iteratorSrc = `package testpkg
+
+type Item struct{}
+
+type Items struct{}
+
+func (i *Items) Next() bool   { return false }
+func (i *Items) Value() *Item { return nil }
+func (i *Items) Err() error   { return nil }
+
+type Lines struct{}
+
+func (l *Lines) Scan() bool    { return false }
+func (l *Lines) Text() string  { return "" }
+
+type Counter struct{}
+
+func (c *Counter) Next() bool { return false }
+`
+
+const iteratorJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Item extends Seq.Proxy {
+    }
+
+    public static final class Items extends Seq.Proxy {
+        public native boolean next();
+    }
+
+    public static final class Lines extends Seq.Proxy {
+        public native boolean scan();
+    }
+
+    public static final class Counter extends Seq.Proxy {
+        public native boolean next();
+    }
+}
+`
+
+func TestAddIterators(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(iteratorJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addIterators(path, typeCheck(t, iteratorSrc)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"class Items extends Seq.Proxy implements Iterable<go.testpkg.Testpkg.Item> {",
+		"public java.util.Iterator<go.testpkg.Testpkg.Item> iterator() {",
+		"more = Items.this.next();",
+		"Items.this.err();",
+		"return Items.this.value();",
+		"class Lines extends Seq.Proxy implements Iterable<String> {",
+		"return Lines.this.text();",
+		"class Counter extends Seq.Proxy {\n",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q:\n%s", s, src)
+		}
+	}
+	if strings.Contains(src, "Lines.this.err()") {
+		t.Errorf("Lines has no Err method:\n%s", src)
+	}
+}
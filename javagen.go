@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minBuilderFields is the number of exported fields a struct must have before
+// a Builder class is generated for it.
+const minBuilderFields = 5
+
+// javaPkgName returns the Java package containing the classes generated for p.
+func javaPkgName(p *types.Package) string {
+	return "go." + p.Name()
+}
+
+// javaClassName returns the name of the Java class generated for p.
+func javaClassName(p *types.Package) string {
+	return strings.Title(p.Name())
+}
+
+// javaType returns the Java type used by the generated bindings to represent t.
+// Named types from a bound package are qualified with the class of that package.
+func javaType(t types.Type) (string, error) {
+	switch t := t.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.Bool:
+			return "boolean", nil
+		case types.Int8, types.Uint8:
+			return "byte", nil
+		case types.Int16:
+			return "short", nil
+		case types.Int32, types.UntypedRune:
+			return "int", nil
+		case types.Int, types.Int64, types.UntypedInt:
+			return "long", nil
+		case types.Float32:
+			return "float", nil
+		case types.Float64, types.UntypedFloat:
+			return "double", nil
+		case types.String, types.UntypedString:
+			return "String", nil
+		}
+	case *types.Slice:
+		if e, ok := t.Elem().(*types.Basic); ok && e.Kind() == types.Uint8 {
+			return "byte[]", nil
+		}
+	case *types.Pointer:
+		if n, ok := t.Elem().(*types.Named); ok {
+			if _, ok := n.Underlying().(*types.Struct); ok {
+				return javaType(n)
+			}
+		}
+	case *types.Named:
+		o := t.Obj()
+		if o.Pkg() == nil {
+			break
+		}
+		if _, ok := t.Underlying().(*types.Basic); ok {
+			// Named basic types are bound as the underlying type.
+			return javaType(t.Underlying())
+		}
+		return javaPkgName(o.Pkg()) + "." + javaClassName(o.Pkg()) + "." + o.Name(), nil
+	}
+	return "", fmt.Errorf("unsupported type: %s", t)
+}
+
+// writeJavaFile writes src to path, creating any missing parent directories.
+func writeJavaFile(path string, src []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
+
+// hasValidate reports whether *n has a method with the signature Validate() error.
+func hasValidate(n *types.Named) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(n), false, n.Obj().Pkg(), "Validate")
+	fn, ok := obj.(*types.Func)
+	if !ok || !fn.Exported() {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	return isError(sig.Results().At(0).Type())
+}
+
+type builderField struct {
+	name     string
+	javaType string
+}
+
+// builderFields returns the exported fields of s that can be set from Java.
+func builderFields(s *types.Struct) []builderField {
+	var fields []builderField
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !f.Exported() || f.Anonymous() {
+			continue
+		}
+		jt, err := javaType(f.Type())
+		if err != nil {
+			verbosef("skipping field %s in builder: %v\n", f.Name(), err)
+			continue
+		}
+		fields = append(fields, builderField{name: f.Name(), javaType: jt})
+	}
+	return fields
+}
+
+// genBuilders writes a fluent Builder class to javaDir for every exported struct
+// in p with at least minBuilderFields settable fields. It returns the paths of
+// the generated files.
+func genBuilders(javaDir string, p *types.Package) ([]string, error) {
+	var files []string
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		s, ok := n.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		fields := builderFields(s)
+		if len(fields) < minBuilderFields {
+			continue
+		}
+		path := filepath.Join(javaDir, p.Name(), name+"Builder.java")
+		if err := writeJavaFile(path, genBuilder(p, n, fields)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func genBuilder(p *types.Package, n *types.Named, fields []builderField) []byte {
+	name := n.Obj().Name()
+	class := javaClassName(p) + "." + name
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Java builder for %s.%s generated by gojava.\n", p.Path(), name)
+	fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(p))
+	fmt.Fprintf(&b, "public final class %sBuilder {\n", name)
+	fmt.Fprintf(&b, "\tprivate final %s value = new %s();\n", class, class)
+	for _, f := range fields {
+		fmt.Fprintf(&b, "\n\tpublic %sBuilder set%s(%s v) {\n", name, f.name, f.javaType)
+		fmt.Fprintf(&b, "\t\tvalue.set%s(v);\n", f.name)
+		fmt.Fprintf(&b, "\t\treturn this;\n\t}\n")
+	}
+	if hasValidate(n) {
+		fmt.Fprintf(&b, "\n\tpublic %s build() throws Exception {\n", class)
+		fmt.Fprintf(&b, "\t\tvalue.validate();\n")
+	} else {
+		fmt.Fprintf(&b, "\n\tpublic %s build() {\n", class)
+	}
+	fmt.Fprintf(&b, "\t\treturn value;\n\t}\n}\n")
+	return b.Bytes()
+}
+
+// boxedTypes maps Java primitive types to their boxed types.
+var boxedTypes = map[string]string{
+	"boolean": "Boolean", "byte": "Byte", "short": "Short", "int": "Integer",
+	"long": "Long", "float": "Float", "double": "Double", "String": "String",
+}
+
+// genValueTypes writes a Java value class to javaDir for every exported named
+// type in p with a basic underlying type, such as type ID string. The bindings
+// use the underlying type, and the value class lets Java code keep the
+// distinction. It returns the paths of the generated files.
+func genValueTypes(javaDir string, p *types.Package) ([]string, error) {
+	var files []string
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() || tn.IsAlias() {
+			continue
+		}
+		if _, ok := tn.Type().Underlying().(*types.Basic); !ok {
+			continue
+		}
+		jt, err := javaType(tn.Type())
+		if err != nil {
+			verbosef("skipping value type %s: %v\n", name, err)
+			continue
+		}
+		path := filepath.Join(javaDir, p.Name(), name+".java")
+		if err := writeJavaFile(path, genValueType(p, name, jt)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func genValueType(p *types.Package, name, jt string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Java value type for %s.%s generated by gojava.\n", p.Path(), name)
+	fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(p))
+	fmt.Fprintf(&b, "public final class %s {\n", name)
+	fmt.Fprintf(&b, "\tprivate final %s value;\n\n", jt)
+	fmt.Fprintf(&b, "\tprivate %s(%s value) {\n\t\tthis.value = value;\n\t}\n\n", name, jt)
+	fmt.Fprintf(&b, "\tpublic static %s of(%s value) {\n\t\treturn new %s(value);\n\t}\n\n", name, jt, name)
+	fmt.Fprintf(&b, "\tpublic %s value() {\n\t\treturn value;\n\t}\n\n", jt)
+	fmt.Fprintf(&b, "\t@Override\n\tpublic boolean equals(Object o) {\n")
+	fmt.Fprintf(&b, "\t\treturn o instanceof %s && %s.valueOf(value).equals(%s.valueOf(((%s) o).value));\n\t}\n\n", name, boxedTypes[jt], boxedTypes[jt], name)
+	fmt.Fprintf(&b, "\t@Override\n\tpublic int hashCode() {\n\t\treturn %s.valueOf(value).hashCode();\n\t}\n\n", boxedTypes[jt])
+	fmt.Fprintf(&b, "\t@Override\n\tpublic String toString() {\n\t\treturn String.valueOf(value);\n\t}\n}\n")
+	return b.Bytes()
+}
+
+// factory is a Go function NewT or NewTWithX returning *T (and optionally an
+// error), exposed as a static method on the Java class for T.
+type factory struct {
+	fn     *types.Func
+	typ    string
+	suffix string
+}
+
+// findFactories returns the functions in p that construct a struct type in p,
+// keyed by type name.
+func findFactories(p *types.Package) map[string][]factory {
+	factories := make(map[string][]factory)
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() || !strings.HasPrefix(name, "New") {
+			continue
+		}
+		res := fn.Type().(*types.Signature).Results()
+		if res.Len() == 0 || res.Len() > 2 {
+			continue
+		}
+		if res.Len() == 2 && !isError(res.At(1).Type()) {
+			continue
+		}
+		ptr, ok := res.At(0).Type().(*types.Pointer)
+		if !ok {
+			continue
+		}
+		n, ok := ptr.Elem().(*types.Named)
+		if !ok || n.Obj().Pkg() != p {
+			continue
+		}
+		typ := n.Obj().Name()
+		if !strings.HasPrefix(name, "New"+typ) {
+			continue
+		}
+		factories[typ] = append(factories[typ], factory{fn: fn, typ: typ, suffix: name[len("New"+typ):]})
+	}
+	return factories
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// javaSignature returns the Java parameter declarations, argument names,
+// return type and throws clause of the method generated for fn.
+func javaSignature(fn *types.Func) (params, args []string, ret, throws string, err error) {
+	sig := fn.Type().(*types.Signature)
+	params, args = make([]string, sig.Params().Len()), make([]string, sig.Params().Len())
+	for i := 0; i < sig.Params().Len(); i++ {
+		v := sig.Params().At(i)
+		jt, err := javaType(v.Type())
+		if err != nil {
+			return nil, nil, "", "", err
+		}
+		name := javaIdent(v.Name())
+		if name == "" || name == "_" || name == "__" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		params[i], args[i] = jt+" "+name, name
+	}
+	res := sig.Results()
+	if res.Len() > 0 && isError(res.At(res.Len()-1).Type()) {
+		throws = " throws Exception"
+		res = types.NewTuple(tupleVars(res)[:res.Len()-1]...)
+	}
+	switch res.Len() {
+	case 0:
+		ret = "void"
+	case 1:
+		if ret, err = javaType(res.At(0).Type()); err != nil {
+			return nil, nil, "", "", err
+		}
+	default:
+		return nil, nil, "", "", fmt.Errorf("unsupported results: %s", res)
+	}
+	return params, args, ret, throws, nil
+}
+
+func tupleVars(t *types.Tuple) []*types.Var {
+	vars := make([]*types.Var, t.Len())
+	for i := range vars {
+		vars[i] = t.At(i)
+	}
+	return vars
+}
+
+// genFactory returns the Java source of a static method on the class for f.typ
+// named prefix+f.suffix that delegates to the package level method for f.fn.
+func genFactory(p *types.Package, f factory, prefix string) (string, error) {
+	params, args, _, throws, err := javaSignature(f.fn)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "\n\t\tpublic static %s %s%s(%s)%s {\n", f.typ, prefix, f.suffix, strings.Join(params, ", "), throws)
+	fmt.Fprintf(&b, "\t\t\treturn %s.%s(%s);\n\t\t}\n", javaClassName(p), javaMethodName(f.fn.Name()), strings.Join(args, ", "))
+	return b.String(), nil
+}
+
+// javaMethodName returns the name of the Java method generated for the Go
+// function or method name.
+func javaMethodName(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[n:]
+}
+
+// insertIntoClass inserts members at the start of the body of the class named
+// class in the Java source src.
+func insertIntoClass(src []byte, class, members string) ([]byte, error) {
+	decl := []byte("class " + class + " ")
+	i := bytes.Index(src, decl)
+	if i < 0 {
+		return nil, fmt.Errorf("class %s not found", class)
+	}
+	brace := bytes.IndexByte(src[i:], '{')
+	if brace < 0 {
+		return nil, fmt.Errorf("malformed declaration of class %s", class)
+	}
+	i += brace + 1
+	out := make([]byte, 0, len(src)+len(members))
+	out = append(out, src[:i]...)
+	out = append(out, members...)
+	return append(out, src[i:]...), nil
+}
+
+// addFactories rewrites the generated Java file for p at path, adding static
+// factory methods named with prefix to the classes of struct types that have
+// NewX functions. It does nothing if prefix is empty.
+func addFactories(path string, p *types.Package, prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	factories := findFactories(p)
+	if len(factories) == 0 {
+		return nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for typ, fs := range factories {
+		var members string
+		for _, f := range fs {
+			m, err := genFactory(p, f, prefix)
+			if err != nil {
+				verbosef("skipping factory for %s: %v\n", f.fn.Name(), err)
+				continue
+			}
+			members += m
+		}
+		if src, err = insertIntoClass(src, typ, members); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
+
+var errorInterface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// stringMethod returns the name of the method of *n with the signature
+// String() string or, failing that, Error() string, or "" if it has neither.
+func stringMethod(n *types.Named) string {
+	for _, name := range []string{"String", "Error"} {
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(n), false, n.Obj().Pkg(), name)
+		fn, ok := obj.(*types.Func)
+		if !ok || !fn.Exported() {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			continue
+		}
+		if b, ok := sig.Results().At(0).Type().(*types.Basic); ok && b.Kind() == types.String {
+			return name
+		}
+	}
+	return ""
+}
+
+// addStringers rewrites the generated Java file for p at path, overriding
+// toString in the classes of struct types implementing fmt.Stringer or error
+// to call String or Error. Error types also get an asException method
+// returning a go.GoException wrapping them, so they can be thrown.
+func addStringers(path string, p *types.Package) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	changed := false
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok {
+			continue
+		}
+		method := stringMethod(n)
+		if method == "" {
+			continue
+		}
+		_, start, end, err := classBody(src, p, name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		var members string
+		if len(memberDecls(src, start, end, "toString")) == 0 {
+			members += fmt.Sprintf("\n\t\t@Override\n\t\tpublic String toString() {\n\t\t\treturn %s();\n\t\t}\n", javaMethodName(method))
+		}
+		if types.Implements(types.NewPointer(n), errorInterface) {
+			members += "\n\t\tpublic go.GoException asException() {\n\t\t\treturn new go.GoException(error(), this);\n\t\t}\n"
+		}
+		if members == "" {
+			continue
+		}
+		if src, err = insertIntoClass(src, name, members); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func typeCheck(t *testing.T, src string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const builderSrc = `package testpkg
+
+type Config struct {
+	Name    string
+	Port    int32
+	Timeout int64
+	Debug   bool
+	Ratio   float64
+	Data    []byte
+	hidden  int
+}
+
+func (c *Config) Validate() error { return nil }
+
+type Small struct {
+	A, B int
+}
+`
+
+func TestGenBuilders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files, err := genBuilders(tmpDir, typeCheck(t, builderSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(tmpDir, "testpkg", "ConfigBuilder.java")
+	if len(files) != 1 || files[0] != want {
+		t.Fatalf("expected only %s, got %v", want, files)
+	}
+	d, err := ioutil.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"package go.testpkg;",
+		"private final Testpkg.Config value = new Testpkg.Config();",
+		"public ConfigBuilder setPort(int v) {",
+		"public ConfigBuilder setData(byte[] v) {",
+		"public Testpkg.Config build() throws Exception {",
+		"value.validate();",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("generated builder missing %q:\n%s", s, src)
+		}
+	}
+	if strings.Contains(src, "setHidden") {
+		t.Errorf("builder should not set unexported fields:\n%s", src)
+	}
+}
+
+const factorySrc = `package testpkg
+
+type Client struct{}
+
+func NewClient() *Client { return nil }
+
+func NewClientWithAddr(addr string, port int32) (*Client, error) { return nil, nil }
+
+func NewOther() int { return 0 }
+`
+
+const factoryJava = `package go.testpkg;
+
+public abstract class Testpkg {
+	public static final class Client extends Seq.Proxy {
+	}
+}
+`
+
+func TestAddFactories(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(factoryJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addFactories(path, typeCheck(t, factorySrc), "create"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"public static Client create() {\n\t\t\treturn Testpkg.newClient();",
+		"public static Client createWithAddr(String addr, int port) throws Exception {\n\t\t\treturn Testpkg.newClientWithAddr(addr, port);",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("generated source missing %q:\n%s", s, src)
+		}
+	}
+	if strings.Contains(src, "Other") {
+		t.Errorf("unexpected factory for NewOther:\n%s", src)
+	}
+}
+
+const overloadSrc = `package testpkg
+
+func Hash(s string) int64 { return 0 }
+
+func HashString(s string) int64 { return 0 }
+
+func HashBytes(b []byte) int64 { return 0 }
+
+func WriteBytes(b []byte) error { return nil }
+`
+
+func TestAddOverloads(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(factoryJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addOverloads(path, typeCheck(t, overloadSrc), []string{"String", "Bytes"}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"public static long hash(byte[] b) {\n\t\treturn Testpkg.hashBytes(b);",
+		"public static void write(byte[] b) throws Exception {\n\t\tTestpkg.writeBytes(b);",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("generated source missing %q:\n%s", s, src)
+		}
+	}
+	if strings.Contains(src, "hashString(s)") {
+		t.Errorf("overload clashing with Hash should not be generated:\n%s", src)
+	}
+}
+
+const valueTypeSrc = `package testpkg
+
+type ID string
+
+type Port int
+
+type Alias = Port
+
+type hidden int
+
+type Config struct {
+	Port Port
+}
+`
+
+func TestGenValueTypes(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	p := typeCheck(t, valueTypeSrc)
+	files, err := genValueTypes(tmpDir, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "ID.java" || filepath.Base(files[1]) != "Port.java" {
+		t.Fatalf("expected ID.java and Port.java, got %v", files)
+	}
+	d, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"public final class Port {",
+		"public static Port of(long value) {",
+		"public long value() {",
+		"return o instanceof Port && Long.valueOf(value).equals(Long.valueOf(((Port) o).value));",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("generated value type missing %q:\n%s", s, d)
+		}
+	}
+	fields := builderFields(p.Scope().Lookup("Config").Type().Underlying().(*types.Struct))
+	if len(fields) != 1 || fields[0].javaType != "long" {
+		t.Errorf("expected named basic field bound as long, got %v", fields)
+	}
+}
+
+const stringerSrc = `package testpkg
+
+type Name struct{}
+
+func (n *Name) String() string { return "" }
+
+type Failure struct{}
+
+func (f *Failure) Error() string { return "" }
+
+type Printed struct{}
+
+func (p *Printed) String() string { return "" }
+
+type Plain struct{}
+`
+
+const stringerJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Name extends Seq.Proxy {
+        public native String string();
+    }
+
+    public static final class Failure extends Seq.Proxy {
+        public native String error();
+    }
+
+    public static final class Printed extends Seq.Proxy {
+        public native String string();
+        @Override public String toString() { return "generated"; }
+    }
+
+    public static final class Plain extends Seq.Proxy {
+    }
+}
+`
+
+func TestAddStringers(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(stringerJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addStringers(path, typeCheck(t, stringerSrc)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"class Name extends Seq.Proxy {\n\t\t@Override\n\t\tpublic String toString() {\n\t\t\treturn string();",
+		"class Failure extends Seq.Proxy {\n\t\t@Override\n\t\tpublic String toString() {\n\t\t\treturn error();",
+		"public go.GoException asException() {\n\t\t\treturn new go.GoException(error(), this);",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q:\n%s", s, src)
+		}
+	}
+	if n := strings.Count(src, "toString()"); n != 3 {
+		t.Errorf("expected 3 toString methods, got %d:\n%s", n, src)
+	}
+	if n := strings.Count(src, "asException"); n != 1 {
+		t.Errorf("expected only Failure to have asException:\n%s", src)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultJavaRoot is the Java package of the runtime classes of the
+// bindings, such as go.Seq and go.Go. The classes of a bound package are in
+// its subpackage named after the Go package.
+const defaultJavaRoot = "go"
+
+// versionSuffix matches the valid values of -version-suffix.
+var versionSuffix = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// javaPackageName matches the valid values of -javapkg and -javapkg-for.
+var javaPackageName = regexp.MustCompile(`^[\p{L}_$][\p{L}\p{N}_$]*(\.[\p{L}_$][\p{L}\p{N}_$]*)*$`)
+
+// javaRoot returns the root Java package of the bindings built with cfg:
+// go, or the -javapkg package, with _<suffix> appended with -version-suffix.
+func javaRoot(cfg *config) string {
+	root := defaultJavaRoot
+	if cfg.javaPkg != "" {
+		root = cfg.javaPkg
+	}
+	if cfg.versionSuffix == "" {
+		return root
+	}
+	return root + "_" + cfg.versionSuffix
+}
+
+// javaPkgForFlag is the -javapkg-for flag, holding the Java package of the
+// classes of each bound package given as pkg=javapkg. It may be set more than
+// once.
+type javaPkgForFlag map[string]string
+
+func (j *javaPkgForFlag) String() string {
+	var s []string
+	for pkg, javaPkg := range *j {
+		s = append(s, pkg+"="+javaPkg)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (j *javaPkgForFlag) Set(v string) error {
+	f := strings.SplitN(v, "=", 2)
+	if len(f) != 2 || f[0] == "" {
+		return fmt.Errorf("expected pkg=javapkg")
+	}
+	if !javaPackageName.MatchString(f[1]) {
+		return fmt.Errorf("invalid Java package %q for %s", f[1], f[0])
+	}
+	if *j == nil {
+		*j = make(javaPkgForFlag)
+	}
+	(*j)[f[0]] = f[1]
+	return nil
+}
+
+// packageJavaPkgs returns the -javapkg-for Java packages of the bound
+// packages typePkgs, given by the import paths pkgs on the command line or by
+// their full paths, keyed by Go package name. It returns an error if a package
+// is not bound, or would be moved to the root Java package.
+func packageJavaPkgs(javaPkgs javaPkgForFlag, root string, pkgs []string, typePkgs []*types.Package) (map[string]string, error) {
+	moved := make(map[string]string)
+	for path, javaPkg := range javaPkgs {
+		if javaPkg == root {
+			return nil, fmt.Errorf("-javapkg-for: %s cannot be moved to the root Java package %s", path, root)
+		}
+		bound := false
+		for i, p := range typePkgs {
+			if path == pkgs[i] || path == p.Path() {
+				moved[p.Name()], bound = javaPkg, true
+			}
+		}
+		if !bound {
+			return nil, fmt.Errorf("-javapkg-for: %s is not a bound package", path)
+		}
+	}
+	return moved, nil
+}
+
+// packageJavaPkg returns the Java package of the classes of p in the bindings
+// with the root Java package root, and the packages moved by -javapkg-for.
+func packageJavaPkg(root string, moved map[string]string, p *types.Package) string {
+	if javaPkg, ok := moved[p.Name()]; ok {
+		return javaPkg
+	}
+	return root + "." + p.Name()
+}
+
+// javaRootDir returns the directory of the Java package root, relative to a
+// class or source root.
+func javaRootDir(root string) string {
+	return filepath.FromSlash(strings.Replace(root, ".", "/", -1))
+}
+
+var (
+	// javaRootPackage matches the declaration of the go package in Java
+	// sources, javaRootRef references to it and its subpackages, and
+	// javaRootResource the resources in it.
+	javaRootPackage  = regexp.MustCompile(`(?m)^package go(;|[ \t]*$)`)
+	javaRootRef      = regexp.MustCompile(`(^|[^\p{L}\p{N}_$.])go\.([\p{L}_$*])`)
+	javaRootResource = regexp.MustCompile(`"/go/`)
+	// jniRootName matches the JNI functions of classes in the go package and
+	// its subpackages in C sources, and jniRootClass the names of the classes
+	// in FindClass calls and type signatures.
+	jniRootName  = regexp.MustCompile(`\bJava_go_`)
+	jniRootClass = regexp.MustCompile(`(\bL|")go/`)
+)
+
+// renameJavaRoot moves the Java sources in javaDir, including the Java,
+// Kotlin and Scala sources added with -s, from the go package and its subpackages to root, so that bindings
+// with different roots do not share any class or resource and can be loaded
+// side by side.
+func renameJavaRoot(javaDir, root string) error {
+	if root == defaultJavaRoot {
+		return nil
+	}
+	slashed := strings.Replace(root, ".", "/", -1)
+	return filepath.Walk(javaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || (filepath.Ext(path) != ".java" && jvmLanguageOf(path) == nil) {
+			return err
+		}
+		return rewriteFile(path, func(src string) string {
+			src = javaRootPackage.ReplaceAllString(src, "package "+root+"${1}")
+			src = javaRootRef.ReplaceAllString(src, "${1}"+root+".${2}")
+			return javaRootResource.ReplaceAllString(src, `"/`+slashed+`/`)
+		})
+	})
+}
+
+// renameJavaPackages moves the classes of the bound packages in moved, keyed
+// by Go package name, from their subpackage of root to their Java package, in
+// the Java sources in javaDir renamed by renameJavaRoot.
+func renameJavaPackages(javaDir, root string, moved map[string]string) error {
+	if len(moved) == 0 {
+		return nil
+	}
+	refs := make(map[*regexp.Regexp]string)
+	for name, javaPkg := range moved {
+		refs[regexp.MustCompile(`(^|[^\p{L}\p{N}_$.])`+regexp.QuoteMeta(root+"."+name)+`\b`)] = "${1}" + javaPkg
+	}
+	return filepath.Walk(javaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || (filepath.Ext(path) != ".java" && jvmLanguageOf(path) == nil) {
+			return err
+		}
+		return rewriteFile(path, func(src string) string {
+			for re, repl := range refs {
+				src = re.ReplaceAllString(src, repl)
+			}
+			return src
+		})
+	})
+}
+
+// renameNativeRoot renames the JNI functions in the C sources in bindDir,
+// and the classes they refer to, for classes moved to root by
+// renameJavaRoot.
+func renameNativeRoot(bindDir, root string) error {
+	if root == defaultJavaRoot {
+		return nil
+	}
+	cFiles, err := filepath.Glob(filepath.Join(bindDir, "*.[ch]"))
+	if err != nil {
+		return err
+	}
+	for _, path := range cFiles {
+		err := rewriteFile(path, func(src string) string {
+			src = jniRootName.ReplaceAllString(src, "Java_"+jniMangle(root)+"_")
+			return jniRootClass.ReplaceAllString(src, "${1}"+strings.Replace(root, ".", "/", -1)+"/")
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameNativePackages renames the JNI functions in the C sources in bindDir,
+// and the classes they refer to, for the classes moved by renameJavaPackages.
+func renameNativePackages(bindDir, root string, moved map[string]string) error {
+	if len(moved) == 0 {
+		return nil
+	}
+	cFiles, err := filepath.Glob(filepath.Join(bindDir, "*.[ch]"))
+	if err != nil {
+		return err
+	}
+	type rename struct {
+		re   *regexp.Regexp
+		repl string
+	}
+	var renames []rename
+	slashed := strings.Replace(root, ".", "/", -1)
+	for name, javaPkg := range moved {
+		// Class names do not start with a digit, which follows the _ of
+		// escapes in the mangled names of other packages.
+		renames = append(renames, rename{
+			regexp.MustCompile(`\bJava_` + regexp.QuoteMeta(jniMangle(root+"."+name)) + `_([^0-9])`),
+			"Java_" + jniMangle(javaPkg) + "_${1}",
+		}, rename{
+			regexp.MustCompile(`(\bL|")` + regexp.QuoteMeta(slashed+"/"+name+"/")),
+			"${1}" + strings.Replace(javaPkg, ".", "/", -1) + "/",
+		})
+	}
+	for _, path := range cFiles {
+		err := rewriteFile(path, func(src string) string {
+			for _, r := range renames {
+				src = r.re.ReplaceAllString(src, r.repl)
+			}
+			return src
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteFile replaces the contents of the file at path with the result of
+// f.
+func rewriteFile(path string, f func(string) string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out := f(string(src))
+	if out == string(src) {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(out), 0600)
+}
+
+// checkJavaPkg returns an error if cfg has an invalid -javapkg or
+// -javapkg-for, or one combined with flags generating sources outside the
+// jar, which assume the go package.
+func checkJavaPkg(cfg *config) error {
+	if cfg.javaPkg == "" && len(cfg.javaPkgFor) == 0 {
+		return nil
+	}
+	if cfg.javaPkg != "" && !javaPackageName.MatchString(cfg.javaPkg) {
+		return fmt.Errorf("invalid -javapkg %q, must be a Java package name", cfg.javaPkg)
+	}
+	if cfg.javaPkg == "java" || strings.HasPrefix(cfg.javaPkg, "java.") {
+		return fmt.Errorf("invalid -javapkg %q, the java package is reserved", cfg.javaPkg)
+	}
+	if cfg.springBoot != "" || cfg.cdi != "" || cfg.androidLifecycle != "" || cfg.exampleTests != "" {
+		return fmt.Errorf("-javapkg and -javapkg-for cannot be used with -spring-boot, -cdi, -android-lifecycle or -example-tests")
+	}
+	if len(cfg.javaPkgFor) > 0 && cfg.jniArchive != "" {
+		return fmt.Errorf("-javapkg-for cannot be used with -jni-archive")
+	}
+	return nil
+}
+
+// checkVersionSuffix returns an error if cfg has an invalid -version-suffix,
+// or one combined with flags generating sources outside the jar, which
+// assume the go package.
+func checkVersionSuffix(cfg *config) error {
+	if cfg.versionSuffix == "" {
+		return nil
+	}
+	if !versionSuffix.MatchString(cfg.versionSuffix) {
+		return fmt.Errorf("invalid -version-suffix %q, must be letters, digits and underscores", cfg.versionSuffix)
+	}
+	if cfg.springBoot != "" || cfg.cdi != "" || cfg.androidLifecycle != "" || cfg.exampleTests != "" {
+		return fmt.Errorf("-version-suffix cannot be used with -spring-boot, -cdi, -android-lifecycle or -example-tests")
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameJavaRoot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javaPath, cPath := filepath.Join(tmpDir, "src", "go", "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	java := `package go.testpkg;
+
+import go.Seq;
+import go.*;
+
+// Reads main.go. gojava.maxCalls is a property of go.Go.
+public abstract class Testpkg {
+	static { Class.forName("go.LoadJNI"); getResource("/go/libgojava"); }
+	public static native go.testpkg.Testpkg.Point origin();
+}
+`
+	exp := `package go_v2.testpkg;
+
+import go_v2.Seq;
+import go_v2.*;
+
+// Reads main.go. gojava.maxCalls is a property of go_v2.Go.
+public abstract class Testpkg {
+	static { Class.forName("go_v2.LoadJNI"); getResource("/go_v2/libgojava"); }
+	public static native go_v2.testpkg.Testpkg.Point origin();
+}
+`
+	c := `JNIEXPORT jobject JNICALL
+Java_go_testpkg_Testpkg_origin(JNIEnv* env, jclass _clazz) {
+	clazz = (*env)->FindClass(env, "go/testpkg/Testpkg$Point");
+	m = (*env)->GetStaticMethodID(env, seq_class, "getRef", "(I)Lgo/Seq$Ref;");
+}
+`
+	expC := `JNIEXPORT jobject JNICALL
+Java_go_1v2_testpkg_Testpkg_origin(JNIEnv* env, jclass _clazz) {
+	clazz = (*env)->FindClass(env, "go_v2/testpkg/Testpkg$Point");
+	m = (*env)->GetStaticMethodID(env, seq_class, "getRef", "(I)Lgo_v2/Seq$Ref;");
+}
+`
+	if err := writeJavaFile(javaPath, []byte(java)); err != nil {
+		t.Fatal(err)
+	}
+	goPath := filepath.Join(tmpDir, "src", "go", "Go.java")
+	if err := writeJavaFile(goPath, []byte("package go;\n\npublic final class Go {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	ktPath := filepath.Join(tmpDir, "src", "go", "Util.kt")
+	if err := writeJavaFile(ktPath, []byte("package go\n\nimport go.testpkg.Testpkg\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(c), 0600); err != nil {
+		t.Fatal(err)
+	}
+	root := javaRoot(&config{versionSuffix: "v2"})
+	if err := renameJavaRoot(filepath.Join(tmpDir, "src"), root); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameNativeRoot(tmpDir, root); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{javaPath: exp, goPath: "package go_v2;\n\npublic final class Go {}\n", ktPath: "package go_v2\n\nimport go_v2.testpkg.Testpkg\n", cPath: expC} {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", filepath.Base(path), d, want)
+		}
+	}
+}
+
+func TestCheckVersionSuffix(t *testing.T) {
+	for _, tc := range []struct {
+		cfg config
+		ok  bool
+	}{
+		{config{}, true},
+		{config{versionSuffix: "v2_1"}, true},
+		{config{versionSuffix: "v2.1"}, false},
+		{config{versionSuffix: "v2", springBoot: "boot"}, false},
+	} {
+		if err := checkVersionSuffix(&tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("%+v: got %v", tc.cfg, err)
+		}
+	}
+}
+
+func TestRenameJavaPackages(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javaPath, cPath := filepath.Join(tmpDir, "src", "go", "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	java := `package go.testpkg;
+
+import go.Seq;
+import go.testpkg2.Testpkg2;
+
+public abstract class Testpkg {
+	public static native go.testpkg.Testpkg.Point origin();
+}
+`
+	exp := `package com.example.store;
+
+import com.example.bindings.Seq;
+import com.example.bindings.testpkg2.Testpkg2;
+
+public abstract class Testpkg {
+	public static native com.example.store.Testpkg.Point origin();
+}
+`
+	c := `JNIEXPORT jobject JNICALL
+Java_go_testpkg_Testpkg_origin(JNIEnv* env, jclass _clazz) {
+	clazz = (*env)->FindClass(env, "go/testpkg/Testpkg$Point");
+	m = (*env)->GetStaticMethodID(env, seq_class, "getRef", "(I)Lgo/Seq$Ref;");
+}
+Java_go_testpkg_1x_Testpkg_1x_origin(JNIEnv* env, jclass _clazz) {}
+`
+	expC := `JNIEXPORT jobject JNICALL
+Java_com_example_store_Testpkg_origin(JNIEnv* env, jclass _clazz) {
+	clazz = (*env)->FindClass(env, "com/example/store/Testpkg$Point");
+	m = (*env)->GetStaticMethodID(env, seq_class, "getRef", "(I)Lcom/example/bindings/Seq$Ref;");
+}
+Java_com_example_bindings_testpkg_1x_Testpkg_1x_origin(JNIEnv* env, jclass _clazz) {}
+`
+	if err := writeJavaFile(javaPath, []byte(java)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(c), 0600); err != nil {
+		t.Fatal(err)
+	}
+	root := javaRoot(&config{javaPkg: "com.example.bindings"})
+	moved := map[string]string{"testpkg": "com.example.store"}
+	if err := renameJavaRoot(filepath.Join(tmpDir, "src"), root); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameJavaPackages(filepath.Join(tmpDir, "src"), root, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameNativeRoot(tmpDir, root); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameNativePackages(tmpDir, root, moved); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{javaPath: exp, cPath: expC} {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", filepath.Base(path), d, want)
+		}
+	}
+}
+
+func TestPackageJavaPkgs(t *testing.T) {
+	p := typeCheck(t, "package testpkg")
+	var flag javaPkgForFlag
+	if err := flag.Set("./testpkg=com.example.store"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flag.Set("example.com/other=com.example.1st"); err == nil {
+		t.Error("expected an error for an invalid Java package")
+	}
+	moved, err := packageJavaPkgs(flag, "go", []string{"./testpkg"}, []*types.Package{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageJavaPkg("go", moved, p); got != "com.example.store" {
+		t.Errorf("got %s, want com.example.store", got)
+	}
+	if got := packageJavaPkg("go", nil, p); got != "go.testpkg" {
+		t.Errorf("got %s, want go.testpkg", got)
+	}
+	for _, bad := range []javaPkgForFlag{{"example.com/other": "com.example.store"}, {"./testpkg": "go"}} {
+		if _, err := packageJavaPkgs(bad, "go", []string{"./testpkg"}, []*types.Package{p}); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
+
+func TestCheckJavaPkg(t *testing.T) {
+	for _, tc := range []struct {
+		cfg config
+		ok  bool
+	}{
+		{config{}, true},
+		{config{javaPkg: "com.example.bindings"}, true},
+		{config{javaPkg: "com.example..bindings"}, false},
+		{config{javaPkg: "java.bindings"}, false},
+		{config{javaPkg: "com.example", cdi: "cdi"}, false},
+		{config{javaPkgFor: javaPkgForFlag{"example.com/store": "com.example.store"}, jniArchive: "jni"}, false},
+	} {
+		if err := checkJavaPkg(&tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("%+v: got %v", tc.cfg, err)
+		}
+	}
+	if got := javaRoot(&config{javaPkg: "com.example", versionSuffix: "v2"}); got != "com.example_v2" {
+		t.Errorf("got root %s, want com.example_v2", got)
+	}
+}
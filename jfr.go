@@ -0,0 +1,222 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// genProfilerHooks writes the Go and C code to bindDir implementing the native
+// methods of go.GoProfiler, which runs the Go CPU profiler.
+func genProfilerHooks(bindDir string) error {
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_profiler.go"), []byte(profilerHooksGo), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_profiler.c"), []byte(profilerHooksC), 0600)
+}
+
+const profilerHooksGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"os"
+	"runtime/pprof"
+	"time"
+)
+
+// gojavaProfile is the file of the running CPU profile. go.GoProfiler starts
+// and stops profiles one at a time.
+var gojavaProfile *os.File
+
+// gojava_start_cpu_profile starts writing a CPU profile to path, setting
+// start to the wall clock time it starts at, in Unix nanoseconds.
+//
+//export gojava_start_cpu_profile
+func gojava_start_cpu_profile(path *C.char, start *C.longlong) *C.char {
+	if gojavaProfile != nil {
+		return C.CString("a Go CPU profile is already running")
+	}
+	f, err := os.Create(C.GoString(path))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	*start = C.longlong(time.Now().UnixNano())
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return C.CString(err.Error())
+	}
+	gojavaProfile = f
+	return nil
+}
+
+//export gojava_stop_cpu_profile
+func gojava_stop_cpu_profile() *C.char {
+	pprof.StopCPUProfile()
+	f := gojavaProfile
+	gojavaProfile = nil
+	if f == nil {
+		return nil
+	}
+	if err := f.Close(); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+`
+
+const profilerHooksC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+static void gojava_throw_io(JNIEnv *env, char *err) {
+	(*env)->ThrowNew(env, (*env)->FindClass(env, "java/io/IOException"), err);
+	free(err);
+}
+
+JNIEXPORT jlong JNICALL Java_go_GoProfiler_start0(JNIEnv *env, jclass clazz, jstring path) {
+	const char *p = (*env)->GetStringUTFChars(env, path, NULL);
+	if (p == NULL) {
+		return 0;
+	}
+	long long start = 0;
+	char *err = gojava_start_cpu_profile((char *)p, &start);
+	(*env)->ReleaseStringUTFChars(env, path, p);
+	if (err != NULL) {
+		gojava_throw_io(env, err);
+	}
+	return start;
+}
+
+JNIEXPORT void JNICALL Java_go_GoProfiler_stop0(JNIEnv *env, jclass clazz) {
+	char *err = gojava_stop_cpu_profile();
+	if (err != NULL) {
+		gojava_throw_io(env, err);
+	}
+}
+`
+
+const goJfrJava = `package go;
+
+import jdk.jfr.Category;
+import jdk.jfr.Description;
+import jdk.jfr.Event;
+import jdk.jfr.Label;
+import jdk.jfr.Name;
+
+// GoJfr is a GoInterceptor committing a go.Call JDK Flight Recorder event for
+// every bound call, with the method and the class of the exception it threw,
+// so that the time Java threads spend in Go shows in recordings. Register it
+// with Go.setInterceptor(new GoJfr()). Events are only built while a
+// recording enables go.Call.
+public final class GoJfr implements GoInterceptor {
+	@Name("go.Call")
+	@Label("Go Call")
+	@Category("Go")
+	@Description("A call from Java to a bound Go function or method")
+	public static final class CallEvent extends Event {
+		@Label("Method")
+		public String method;
+
+		@Label("Exception")
+		public String exception;
+	}
+
+	@Override
+	public Object before(String method, Object[] args) {
+		CallEvent e = new CallEvent();
+		if (!e.isEnabled()) {
+			return null;
+		}
+		e.begin();
+		return e;
+	}
+
+	@Override
+	public void after(String method, Object state, long nanos, Throwable error) {
+		if (state == null) {
+			return;
+		}
+		CallEvent e = (CallEvent) state;
+		e.end();
+		if (e.shouldCommit()) {
+			e.method = method;
+			e.exception = error == null ? null : error.getClass().getName();
+			e.commit();
+		}
+	}
+}
+`
+
+const goProfilerJava = `package go;
+
+import java.io.File;
+import java.io.IOException;
+import jdk.jfr.Category;
+import jdk.jfr.Description;
+import jdk.jfr.Event;
+import jdk.jfr.Label;
+import jdk.jfr.Name;
+import jdk.jfr.Timestamp;
+
+// GoProfiler writes a pprof CPU profile of the Go code, and commits a
+// go.CPUProfile JDK Flight Recorder event spanning it, with the wall clock
+// time the Go profiler was started at, so that the Go samples line up with
+// the Java samples of a recording or of async-profiler.
+public final class GoProfiler {
+	@Name("go.CPUProfile")
+	@Label("Go CPU Profile")
+	@Category("Go")
+	@Description("A pprof CPU profile of the Go code")
+	public static final class ProfileEvent extends Event {
+		@Label("Profile")
+		public String path;
+
+		@Label("Profile Start")
+		@Timestamp(Timestamp.MILLISECONDS_SINCE_EPOCH)
+		public long profileStart;
+
+		@Label("Profile Start Nanos")
+		@Description("The Unix time in nanoseconds the Go profiler was started at")
+		public long profileStartNanos;
+	}
+
+	private static ProfileEvent running;
+
+	private GoProfiler() {}
+
+	// start starts writing a CPU profile of the Go code to out, until stop is
+	// called. Only one profile runs at a time.
+	public static synchronized void start(File out) throws IOException {
+		if (running != null) {
+			throw new IllegalStateException("a Go CPU profile is already being written to " + running.path);
+		}
+		Go.load();
+		ProfileEvent e = new ProfileEvent();
+		e.path = out.getAbsolutePath();
+		e.begin();
+		e.profileStartNanos = start0(e.path);
+		e.profileStart = e.profileStartNanos / 1000000;
+		running = e;
+	}
+
+	// stop stops the CPU profile and returns its file.
+	public static synchronized File stop() throws IOException {
+		if (running == null) {
+			throw new IllegalStateException("no Go CPU profile is running");
+		}
+		ProfileEvent e = running;
+		running = null;
+		try {
+			stop0();
+		} finally {
+			e.commit();
+		}
+		return new File(e.path);
+	}
+
+	private static native long start0(String path) throws IOException;
+
+	private static native void stop0() throws IOException;
+}
+`
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenProfilerHooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := genProfilerHooks(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	goPath := filepath.Join(tmpDir, "gojava_profiler.go")
+	if _, err := parser.ParseFile(token.NewFileSet(), goPath, nil, 0); err != nil {
+		t.Errorf("invalid Go source: %v", err)
+	}
+	c, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_profiler.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range nativeDecl.FindAllStringSubmatch(goProfilerJava, -1) {
+		if want := "Java_go_GoProfiler_" + m[4] + "("; !strings.Contains(string(c), want) {
+			t.Errorf("gojava_profiler.c missing %q:\n%s", want, c)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jniArchiveLib is the name of the static library written by writeJNIArchive.
+const jniArchiveLib = "libgojava.a"
+
+// writeJNIArchive writes the output of a -jni-archive build to dir, for
+// existing JNI build systems to link instead of using the jar: the static
+// library built in classDir, the C header cAPIHeader declaring the functions
+// exported by the Go code in bindDir, gojava_jni.h and gojava_jni.c declaring
+// and registering the JNI functions of the classes compiled to jarDir, and the
+// Java sources in javaDir, under java/.
+func writeJNIArchive(dir, root, classDir, bindDir, javaDir, jarDir string, pkgs []*types.Package) error {
+	header, err := exportHeader(bindDir)
+	if err != nil {
+		return err
+	}
+	natives, err := jarNatives(jarDir, root, pkgs, nil)
+	if err != nil {
+		return err
+	}
+	jniHeader, jniSource, err := jniRegistration(natives)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	verbosef("Writing the JNI archive to %s\n", dir)
+	if err := copyFiles([]filePair{
+		{filepath.Join(dir, jniArchiveLib), filepath.Join(classDir, jniArchiveLib)},
+		{filepath.Join(dir, "seq.h"), filepath.Join(bindDir, "seq.h")},
+	}); err != nil {
+		return err
+	}
+	files := map[string][]byte{cAPIHeader: header, "gojava_jni.h": jniHeader, "gojava_jni.c": jniSource}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+			return err
+		}
+	}
+	javaOut := filepath.Join(dir, "java", javaRootDir(root))
+	return filepath.Walk(javaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".java" {
+			return err
+		}
+		rel, err := filepath.Rel(javaDir, path)
+		if err != nil {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeJavaFile(filepath.Join(javaOut, rel), src)
+	})
+}
+
+// descriptorTypes maps the primitive types of method descriptors to JNI
+// types.
+var descriptorTypes = map[byte]string{
+	'Z': "jboolean",
+	'B': "jbyte",
+	'C': "jchar",
+	'S': "jshort",
+	'I': "jint",
+	'J': "jlong",
+	'F': "jfloat",
+	'D': "jdouble",
+	'V': "void",
+}
+
+// jniParamTypes returns the JNI types of the parameters and result of the
+// method descriptor desc.
+func jniParamTypes(desc string) ([]string, string, error) {
+	var params []string
+	next := func(d string) (string, string, error) {
+		switch {
+		case d == "":
+		case d[0] == 'L':
+			end := strings.IndexByte(d, ';')
+			if end < 0 {
+				break
+			}
+			switch d[:end+1] {
+			case "Ljava/lang/String;":
+				return "jstring", d[end+1:], nil
+			case "Ljava/lang/Class;":
+				return "jclass", d[end+1:], nil
+			case "Ljava/lang/Throwable;":
+				return "jthrowable", d[end+1:], nil
+			}
+			return "jobject", d[end+1:], nil
+		case d[0] == '[' && len(d) > 1:
+			if t, ok := descriptorTypes[d[1]]; ok && d[1] != 'V' {
+				return t + "Array", d[2:], nil
+			}
+			rest := strings.TrimLeft(d, "[")
+			if rest != "" && rest[0] == 'L' {
+				rest = rest[strings.IndexByte(rest, ';')+1:]
+			} else if rest != "" {
+				rest = rest[1:]
+			}
+			return "jobjectArray", rest, nil
+		default:
+			if t, ok := descriptorTypes[d[0]]; ok {
+				return t, d[1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("malformed method descriptor %s", desc)
+	}
+	if !strings.HasPrefix(desc, "(") {
+		return nil, "", fmt.Errorf("malformed method descriptor %s", desc)
+	}
+	d := desc[1:]
+	for !strings.HasPrefix(d, ")") {
+		t, rest, err := next(d)
+		if err != nil {
+			return nil, "", err
+		}
+		params, d = append(params, t), rest
+	}
+	ret, rest, err := next(d[1:])
+	if err != nil || rest != "" {
+		return nil, "", fmt.Errorf("malformed method descriptor %s", desc)
+	}
+	return params, ret, nil
+}
+
+// jniRegistration returns gojava_jni.h, declaring the JNI functions
+// implementing natives and gojava_register_natives, and gojava_jni.c,
+// defining gojava_register_natives to register them with RegisterNatives.
+// Native libraries linking the JNI archive call it from JNI_OnLoad, so the
+// JNI functions need not be exported. Overloaded natives use the long JNI
+// names, the others the short ones.
+func jniRegistration(natives []classNative) ([]byte, []byte, error) {
+	var h, c bytes.Buffer
+	h.WriteString(jniHeaderStart)
+	c.WriteString(jniSourceStart)
+	names := make(map[string]int)
+	for _, n := range natives {
+		names[n.class+"."+n.name]++
+	}
+	var classes []string
+	byClass := make(map[string][]string)
+	for _, n := range natives {
+		params, ret, err := jniParamTypes(n.desc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", n, err)
+		}
+		this := "jobject"
+		if n.static {
+			this = "jclass"
+		}
+		fn, long := n.jniNames()
+		if names[n.class+"."+n.name] > 1 {
+			fn = long
+		}
+		fmt.Fprintf(&h, "JNIEXPORT %s JNICALL %s(%s);\n", ret, fn, strings.Join(append([]string{"JNIEnv *", this}, params...), ", "))
+		if byClass[n.class] == nil {
+			classes = append(classes, n.class)
+		}
+		byClass[n.class] = append(byClass[n.class], fmt.Sprintf("\t{(char *)%q, (char *)%q, (void *)%s},\n", n.name, n.desc, fn))
+	}
+	h.WriteString(jniHeaderEnd)
+	for i, class := range classes {
+		fmt.Fprintf(&c, "\nstatic JNINativeMethod gojava_natives_%d[] = {\n%s};\n", i, strings.Join(byClass[class], ""))
+	}
+	c.WriteString("\njint gojava_register_natives(JNIEnv *env) {\n")
+	for i, class := range classes {
+		fmt.Fprintf(&c, "\tif (gojava_register(env, %q, gojava_natives_%[2]d, sizeof(gojava_natives_%[2]d) / sizeof(JNINativeMethod)) != JNI_OK) {\n\t\treturn JNI_ERR;\n\t}\n", class, i)
+	}
+	c.WriteString("\treturn JNI_OK;\n}\n")
+	return h.Bytes(), c.Bytes(), nil
+}
+
+const jniHeaderStart = `/* Code generated by gojava. DO NOT EDIT. */
+
+#ifndef GOJAVA_JNI_H
+#define GOJAVA_JNI_H
+
+#include <jni.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* gojava_register_natives registers the native methods of the bindings with
+ * RegisterNatives. Call it from JNI_OnLoad before any bound class is used. */
+jint gojava_register_natives(JNIEnv *env);
+
+`
+
+const jniHeaderEnd = `
+#ifdef __cplusplus
+}
+#endif
+
+#endif
+`
+
+const jniSourceStart = `/* Code generated by gojava. DO NOT EDIT. */
+
+#include <jni.h>
+#include "gojava_jni.h"
+
+static jint gojava_register(JNIEnv *env, const char *name, JNINativeMethod *methods, jint n) {
+	jclass clazz = (*env)->FindClass(env, name);
+	if (clazz == NULL) {
+		return JNI_ERR;
+	}
+	jint err = (*env)->RegisterNatives(env, clazz, methods, n);
+	(*env)->DeleteLocalRef(env, clazz);
+	return err == 0 ? JNI_OK : JNI_ERR;
+}
+`
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJNIParamTypes(t *testing.T) {
+	tests := []struct {
+		desc   string
+		params []string
+		ret    string
+	}{
+		{"()V", nil, "void"},
+		{"(JJ)J", []string{"jlong", "jlong"}, "jlong"},
+		{"(Ljava/lang/String;[B)Z", []string{"jstring", "jbyteArray"}, "jboolean"},
+		{"(Lgo/Seq$Ref;[[I[Ljava/lang/String;D)Ljava/lang/Object;", []string{"jobject", "jobjectArray", "jobjectArray", "jdouble"}, "jobject"},
+	}
+	for _, test := range tests {
+		params, ret, err := jniParamTypes(test.desc)
+		if err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(params, test.params) || ret != test.ret {
+			t.Errorf("%s: got %v %s, want %v %s", test.desc, params, ret, test.params, test.ret)
+		}
+	}
+	for _, desc := range []string{"", "J", "(J", "(Lgo/Seq)V", "(Q)V", "()VV", "(["} {
+		if _, _, err := jniParamTypes(desc); err == nil {
+			t.Errorf("%q: expected error", desc)
+		}
+	}
+}
+
+func TestWriteJNIArchive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classDir, bindDir, javaDir := filepath.Join(tmpDir, "classes", "go"), filepath.Join(tmpDir, "bind"), filepath.Join(tmpDir, "src", "go")
+	jarDir, out := filepath.Join(tmpDir, "classes"), filepath.Join(tmpDir, "out")
+	class := writeClass(t, "go/testpkg/Testpkg", [][3]interface{}{
+		{"add", "(JJ)J", 0x0109},
+		{"read", "([B)Ljava/lang/String;", 0x0101},
+		{"read", "(Ljava/lang/String;)Ljava/lang/String;", 0x0101},
+		{"toString", "()Ljava/lang/String;", 0x0001},
+	})
+	for path, src := range map[string]string{
+		filepath.Join(classDir, "testpkg", "Testpkg.class"): string(class),
+		filepath.Join(classDir, jniArchiveLib):              "!<arch>\n",
+		filepath.Join(bindDir, "seq.h"):                     "/* seq */\n",
+		filepath.Join(bindDir, "go_testpkgmain.go"):         exportsGo,
+		filepath.Join(javaDir, "Seq.java"):                  "package go;\n",
+		filepath.Join(javaDir, "testpkg", "Testpkg.java"):   "package go.testpkg;\n",
+	} {
+		if err := writeJavaFile(path, []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs := []*types.Package{types.NewPackage("example.com/testpkg", "testpkg")}
+	if err := writeJNIArchive(out, "go", classDir, bindDir, javaDir, jarDir, pkgs); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{jniArchiveLib, "seq.h", cAPIHeader, "java/go/Seq.java", "java/go/testpkg/Testpkg.java"} {
+		if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+			t.Error(err)
+		}
+	}
+	h, err := ioutil.ReadFile(filepath.Join(out, "gojava_jni.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"jint gojava_register_natives(JNIEnv *env);\n",
+		"JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_add(JNIEnv *, jclass, jlong, jlong);\n",
+		"JNIEXPORT jstring JNICALL Java_go_testpkg_Testpkg_read___3B(JNIEnv *, jobject, jbyteArray);\n",
+		"JNIEXPORT jstring JNICALL Java_go_testpkg_Testpkg_read__Ljava_lang_String_2(JNIEnv *, jobject, jstring);\n",
+	} {
+		if !strings.Contains(string(h), s) {
+			t.Errorf("gojava_jni.h missing %q:\n%s", s, h)
+		}
+	}
+	c, err := ioutil.ReadFile(filepath.Join(out, "gojava_jni.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\t{(char *)\"add\", (char *)\"(JJ)J\", (void *)Java_go_testpkg_Testpkg_add},\n",
+		"\t{(char *)\"read\", (char *)\"([B)Ljava/lang/String;\", (void *)Java_go_testpkg_Testpkg_read___3B},\n",
+		"gojava_register(env, \"go/testpkg/Testpkg\", gojava_natives_0,",
+	} {
+		if !strings.Contains(string(c), s) {
+			t.Errorf("gojava_jni.c missing %q:\n%s", s, c)
+		}
+	}
+	if strings.Contains(string(c), "toString") {
+		t.Errorf("gojava_jni.c registers a method that is not native:\n%s", c)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// jvmLanguage is a JVM language other than Java whose sources can be added
+// with -s. They are compiled after the Java sources, against their classes.
+type jvmLanguage struct {
+	name, ext, compiler string
+}
+
+var jvmLanguages = []jvmLanguage{
+	{"Kotlin", ".kt", "kotlinc"},
+	{"Scala", ".scala", "scalac"},
+}
+
+// jvmLanguageOf returns the language of the source at path, or nil if it is
+// not a Kotlin or Scala source.
+func jvmLanguageOf(path string) *jvmLanguage {
+	for i, l := range jvmLanguages {
+		if filepath.Ext(path) == l.ext {
+			return &jvmLanguages[i]
+		}
+	}
+	return nil
+}
+
+// compilerPath returns the path of the compiler of l selected by cfg.
+func (l *jvmLanguage) compilerPath(cfg *config) string {
+	override := ""
+	switch l.compiler {
+	case "kotlinc":
+		override = cfg.kotlinc
+	case "scalac":
+		override = cfg.scalac
+	}
+	return javaTool(l.compiler, override)
+}
+
+// addJVMSources copies the Kotlin and Scala sources in sourceDir to javaDir,
+// next to the Java sources, returning their paths in javaDir.
+func addJVMSources(javaDir, sourceDir string) ([]string, error) {
+	if sourceDir == "" {
+		return nil, nil
+	}
+	var files []string
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || jvmLanguageOf(path) == nil {
+			return err
+		}
+		fileName, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if err := checkSourcePackage(path, fileName); err != nil {
+			return err
+		}
+		p := filepath.Join(javaDir, fileName)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		files = append(files, p)
+		return copyFile(p, path)
+	})
+	return files, err
+}
+
+// compileJVMSources compiles the Kotlin and Scala sources files to jarDir,
+// against the classes already in it. The compiler of each language is only
+// needed if there are sources in it.
+func compileJVMSources(cfg *config, jarDir string, files []string) error {
+	for i := range jvmLanguages {
+		l := &jvmLanguages[i]
+		var sources []string
+		for _, f := range files {
+			if jvmLanguageOf(f) == l {
+				sources = append(sources, f)
+			}
+		}
+		if len(sources) == 0 {
+			continue
+		}
+		compiler, err := exec.LookPath(l.compilerPath(cfg))
+		if err != nil {
+			return fmt.Errorf("compiling the %s sources in -s: %v", l.name, err)
+		}
+		verbosef("Compiling %d %s sources\n", len(sources), l.name)
+		err = withTimeout(l.compiler, cfg.javacTimeout, func() error {
+			return runWithArgFile(compiler, append([]string{"-d", jarDir, "-classpath", compileClassPath(cfg, jarDir)}, sources...))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
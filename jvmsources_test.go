@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddJVMSources(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	javaDir, sourceDir := filepath.Join(tmpDir, "java"), filepath.Join(tmpDir, "s")
+	for path, src := range map[string]string{
+		filepath.Join(sourceDir, "Util.java"):          "package go;\n",
+		filepath.Join(sourceDir, "kt", "Ext.kt"):       "@file:JvmName(\"Ext\")\npackage go.kt\n",
+		filepath.Join(sourceDir, "scala", "Ops.scala"): "package go.scala;\n",
+		filepath.Join(sourceDir, "scala", "README.md"): "Scala helpers\n",
+	} {
+		if err := writeJavaFile(path, []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := addJVMSources(javaDir, sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %v, want the Kotlin and Scala sources", files)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := writeJavaFile(filepath.Join(sourceDir, "kt", "Bad.kt"), []byte("package go\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addJVMSources(javaDir, sourceDir); err == nil {
+		t.Error("expected error for a Kotlin source in the wrong package")
+	}
+}
+
+func TestCompileJVMSources(t *testing.T) {
+	cfg := &config{kotlinc: filepath.Join(os.TempDir(), "gojava-no-kotlinc")}
+	if err := compileJVMSources(cfg, os.TempDir(), []string{"Util.java"}); err != nil {
+		t.Errorf("compiling without Kotlin sources: %v", err)
+	}
+	err := compileJVMSources(cfg, os.TempDir(), []string{"Ext.kt"})
+	if err == nil || !strings.Contains(err.Error(), "Kotlin") {
+		t.Errorf("got %v, want an error about the missing Kotlin compiler", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// licensesDir is the directory in the jar holding the license and notice
+// files of the Go code compiled into the native library.
+const licensesDir = "META-INF/licenses"
+
+// licensePrefixes are the prefixes of the upper case names of license and
+// notice files.
+var licensePrefixes = []string{"LICENSE", "LICENCE", "COPYING", "NOTICE", "PATENTS"}
+
+// licenseExts are the extensions of license and notice files.
+var licenseExts = map[string]bool{"": true, ".txt": true, ".md": true, ".markdown": true, ".rst": true}
+
+// isLicenseFile reports whether the file name is a license or notice file.
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	if !licenseExts[strings.ToLower(filepath.Ext(name))] {
+		return false
+	}
+	for _, p := range licensePrefixes {
+		if strings.HasPrefix(upper, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyLicenses copies the license and notice files at the root of dir to
+// destDir, returning how many were copied.
+func copyLicenses(destDir, dir string) (int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, f := range files {
+		if f.IsDir() || !isLicenseFile(f.Name()) {
+			continue
+		}
+		if err := os.MkdirAll(destDir, 0700); err != nil {
+			return n, err
+		}
+		if err := copyFile(filepath.Join(destDir, f.Name()), filepath.Join(dir, f.Name())); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// writeLicenses copies the license and notice files of the Go distribution,
+// and of each module providing packages built into the native library from
+// mainDir, to licensesDir in jarDir. Without modules only the files of the Go
+// distribution are copied.
+func writeLicenses(jarDir, mainDir string, mod *goModule, overlay string) error {
+	dest := filepath.Join(jarDir, filepath.FromSlash(licensesDir))
+	goroot, err := commandOutput("go", "env", "GOROOT")
+	if err != nil {
+		return err
+	}
+	if _, err := copyLicenses(filepath.Join(dest, "go"), strings.TrimSpace(string(goroot))); err != nil {
+		return err
+	}
+	if mod == nil {
+		verbosef("Only including the Go license in the jar, the licenses of dependencies are found from modules\n")
+		return nil
+	}
+	args := append([]string{"list", "-deps"}, modFlags(mod, overlay)...)
+	out, err := commandOutputIn(mainDir, "go", append(args, "-f", "{{with .Module}}{{.Path}}\t{{.Dir}}{{end}}", ".")...)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 2 || f[1] == "" || seen[f[0]] || f[0] == bindModulePath {
+			continue
+		}
+		seen[f[0]] = true
+		n, err := copyLicenses(filepath.Join(dest, filepath.FromSlash(f[0])), f[1])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			verbosef("warning: no license file found for module %s in %s\n", f[0], f[1])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCopyLicenses(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	mod, dest := filepath.Join(tmpDir, "mod"), filepath.Join(tmpDir, "licenses", "example.com", "mod")
+	if err := os.MkdirAll(filepath.Join(mod, "LICENSES"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"LICENSE", "Licence.md", "NOTICE.txt", "COPYING", "PATENTS", "README.md", "license.go", "LICENSE-MIT"} {
+		if err := ioutil.WriteFile(filepath.Join(mod, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := copyLicenses(dest, mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	expected := []string{"COPYING", "LICENSE", "LICENSE-MIT", "Licence.md", "NOTICE.txt", "PATENTS"}
+	if !reflect.DeepEqual(names, expected) || n != len(expected) {
+		t.Errorf("copied %d files %v, want %v", n, names, expected)
+	}
+}
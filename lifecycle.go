@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// callbackInterface is a bound interface with a single method taking a
+// single argument, which Go calls to deliver values to Java.
+type callbackInterface struct {
+	pkg  *types.Package
+	name string
+	// class is the qualified Java name of the interface.
+	class string
+	// method is the Java name of the method, and param the Java type of its
+	// argument.
+	method, param string
+}
+
+// findCallbackInterfaces returns the interfaces of pkgs with a single method
+// taking a single argument of a bound type and returning nothing.
+func findCallbackInterfaces(pkgs []*types.Package, split bool) []callbackInterface {
+	var callbacks []callbackInterface
+	for _, p := range pkgs {
+		forEachMember(p, split, func(class, member string, method bool, obj types.Object) {
+			fn, ok := obj.(*types.Func)
+			if !ok || member == "" {
+				return
+			}
+			sig := fn.Type().(*types.Signature)
+			if sig.Recv() == nil {
+				return
+			}
+			n, ok := sig.Recv().Type().(*types.Named)
+			if !ok {
+				return
+			}
+			iface, ok := n.Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() != 1 || sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+				return
+			}
+			param, err := javaType(sig.Params().At(0).Type())
+			if err != nil {
+				return
+			}
+			callbacks = append(callbacks, callbackInterface{
+				pkg: p, name: n.Obj().Name(), class: strings.Replace(class, "$", ".", -1), method: member, param: param,
+			})
+		})
+	}
+	return callbacks
+}
+
+// writeAndroidLifecycle writes the sources of an Android library module for
+// the jar cfg.target to dir, with go.android.<jar>.GoLifecycle tying the
+// services to the lifecycle of an activity or fragment if services is set,
+// GoBitmaps converting go.GoImage to and from Bitmap if images is set, and a
+// LiveData implementing each callback interface of pkgs.
+func writeAndroidLifecycle(dir string, cfg *config, pkgs []*types.Package, services, images bool) error {
+	pkg := modulePackage("android", cfg.target)
+	javaDir := filepath.Join(append([]string{dir, "src", "main", "java"}, strings.Split(pkg, ".")...)...)
+	if err := os.MkdirAll(javaDir, 0755); err != nil {
+		return err
+	}
+	verbosef("Writing Android lifecycle module %s to %s\n", pkg, dir)
+	if services {
+		src := fmt.Sprintf(goLifecycleJava, pkg)
+		if err := ioutil.WriteFile(filepath.Join(javaDir, "GoLifecycle.java"), []byte(src), 0644); err != nil {
+			return err
+		}
+	}
+	if images {
+		src := fmt.Sprintf(goBitmapsJava, pkg)
+		if err := ioutil.WriteFile(filepath.Join(javaDir, "GoBitmaps.java"), []byte(src), 0644); err != nil {
+			return err
+		}
+	}
+	for _, c := range findCallbackInterfaces(pkgs, cfg.split) {
+		class := javaClassName(c.pkg) + c.name + "LiveData"
+		value := boxedJavaType(c.param)
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// LiveData for %s.%s generated by gojava.\n", c.pkg.Path(), c.name)
+		fmt.Fprintf(&b, "package %s;\n\n", pkg)
+		b.WriteString("import androidx.lifecycle.LiveData;\n\n")
+		fmt.Fprintf(&b, "// %s is a %s holding the last value Go passed to\n// %s.\n", class, c.class, c.method)
+		fmt.Fprintf(&b, "public class %s extends LiveData<%s> implements %s {\n", class, value, c.class)
+		fmt.Fprintf(&b, "\t@Override\n\tpublic void %s(%s v) {\n\t\tpostValue(v);\n\t}\n}\n", c.method, c.param)
+		if err := ioutil.WriteFile(filepath.Join(javaDir, class+".java"), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const goLifecycleJava = `// Android lifecycle support generated by gojava.
+package %s;
+
+import androidx.lifecycle.DefaultLifecycleObserver;
+import androidx.lifecycle.Lifecycle;
+import androidx.lifecycle.LifecycleOwner;
+
+// GoLifecycle ties Go services to the lifecycle of an activity, fragment or
+// other LifecycleOwner, so they do not outlive it.
+public final class GoLifecycle {
+	private GoLifecycle() {}
+
+	// bind starts service and cancels its context when owner is destroyed. It
+	// does not wait for the Go function to return, so it does not block the
+	// main thread.
+	public static <S extends go.GoService> S bind(LifecycleOwner owner, final S service) {
+		Lifecycle lifecycle = owner.getLifecycle();
+		if (lifecycle.getCurrentState() == Lifecycle.State.DESTROYED) {
+			throw new IllegalStateException(service.name() + ": lifecycle already destroyed");
+		}
+		service.start();
+		lifecycle.addObserver(new DefaultLifecycleObserver() {
+			@Override
+			public void onDestroy(LifecycleOwner owner) {
+				owner.getLifecycle().removeObserver(this);
+				service.stop();
+			}
+		});
+		return service;
+	}
+}
+`
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lifecycleSrc = `package testpkg
+
+type Point struct{ X int }
+
+type PointListener interface {
+	OnPoint(p *Point)
+}
+
+type ProgressListener interface {
+	OnProgress(percent int)
+}
+
+type Multi interface {
+	A(v int)
+	B(v int)
+}
+
+type Returns interface {
+	F(v int) error
+}
+`
+
+func TestWriteAndroidLifecycle(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p := typeCheck(t, lifecycleSrc)
+	callbacks := findCallbackInterfaces([]*types.Package{p}, false)
+	if len(callbacks) != 2 || callbacks[0].name != "PointListener" || callbacks[1].name != "ProgressListener" {
+		t.Fatalf("got %+v", callbacks)
+	}
+
+	if err := writeAndroidLifecycle(tmpDir, &config{target: "geo.jar"}, []*types.Package{p}, true, true); err != nil {
+		t.Fatal(err)
+	}
+	javaDir := filepath.Join(tmpDir, "src", "main", "java", "go", "android", "geo")
+	for path, exp := range map[string][]string{
+		"GoLifecycle.java": {
+			"package go.android.geo;",
+			"\tpublic static <S extends go.GoService> S bind(LifecycleOwner owner, final S service) {",
+		},
+		"GoBitmaps.java": {
+			"package go.android.geo;",
+			"\tpublic static Bitmap toBitmap(GoImage img) {",
+			"\tpublic static GoImage fromBitmap(Bitmap bitmap) {",
+		},
+		"TestpkgPointListenerLiveData.java": {
+			"public class TestpkgPointListenerLiveData extends LiveData<go.testpkg.Testpkg.Point> implements go.testpkg.Testpkg.PointListener {",
+			"\tpublic void onPoint(go.testpkg.Testpkg.Point v) {\n\t\tpostValue(v);",
+		},
+		"TestpkgProgressListenerLiveData.java": {
+			"extends LiveData<Long> implements go.testpkg.Testpkg.ProgressListener {",
+			"\tpublic void onProgress(long v) {",
+		},
+	} {
+		d, err := ioutil.ReadFile(filepath.Join(javaDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range exp {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", path, s, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// genMemoryHooks writes the Go and C code to bindDir implementing the native
+// methods of go.GoMemory, which report the size of the Go heap and the bytes
+// allocated in it so far.
+func genMemoryHooks(bindDir string) error {
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_memory.go"), []byte(memoryHooksGo), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_memory.c"), []byte(memoryHooksC), 0600)
+}
+
+const memoryHooksGo = `package gojava_bind
+
+import "C"
+
+import "runtime/metrics"
+
+// heapObjects is the metric for the bytes of live and unswept heap objects,
+// which includes the Go values referenced from Java, and heapAllocs the
+// metric for the bytes allocated in the heap since the program started.
+const (
+	heapObjects = "/memory/classes/heap/objects:bytes"
+	heapAllocs  = "/gc/heap/allocs:bytes"
+)
+
+func gojavaReadMetric(name string) C.longlong {
+	s := []metrics.Sample{{Name: name}}
+	metrics.Read(s)
+	return C.longlong(s[0].Value.Uint64())
+}
+
+//export gojava_heap_bytes
+func gojava_heap_bytes() C.longlong { return gojavaReadMetric(heapObjects) }
+
+//export gojava_alloc_bytes
+func gojava_alloc_bytes() C.longlong { return gojavaReadMetric(heapAllocs) }
+`
+
+const memoryHooksC = `#include <jni.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jlong JNICALL Java_go_GoMemory_heapBytes0(JNIEnv *env, jclass clazz) {
+	return gojava_heap_bytes();
+}
+
+JNIEXPORT jlong JNICALL Java_go_GoMemory_allocBytes0(JNIEnv *env, jclass clazz) {
+	return gojava_alloc_bytes();
+}
+`
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenMemoryHooks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := genMemoryHooks(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	goPath := filepath.Join(tmpDir, "gojava_memory.go")
+	if _, err := parser.ParseFile(token.NewFileSet(), goPath, nil, 0); err != nil {
+		t.Errorf("invalid Go source: %v", err)
+	}
+	c, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_memory.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Java_go_GoMemory_heapBytes0(", "Java_go_GoMemory_allocBytes0("} {
+		if !strings.Contains(string(c), want) {
+			t.Errorf("gojava_memory.c missing %q:\n%s", want, c)
+		}
+	}
+}
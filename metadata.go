@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// javaMember is a generated Java member and the position of the Go
+// declaration it is bound to.
+type javaMember struct {
+	// Java is the qualified name of the member, e.g. go.pkg.Pkg.Type.method.
+	Java string `json:"java"`
+	// Go is the file:line of the Go declaration.
+	Go string `json:"go"`
+	// class is the binary name of the class declaring the member, and method
+	// the name of the member if it is a method.
+	class, method string
+}
+
+// packageMetadata describes the Java bindings of a Go package.
+type packageMetadata struct {
+	ImportPath  string       `json:"importPath"`
+	JavaPackage string       `json:"javaPackage"`
+	JavaClass   string       `json:"javaClass"`
+	Members     []javaMember `json:"members"`
+}
+
+// ideMetadata is written by -ide-metadata, for IDE plugins and build scripts
+// that need to relate the jar to the Go sources it was built from.
+type ideMetadata struct {
+	Jar         string            `json:"jar,omitempty"`
+	SourceRoots []string          `json:"sourceRoots,omitempty"`
+	Packages    []packageMetadata `json:"packages"`
+}
+
+// javaBinaryName returns the binary name of the Java class generated for the
+// type named name in p, as used in stack traces.
+func javaBinaryName(p *types.Package, name string, split bool) string {
+	if split {
+		return javaPkgName(p) + "." + name
+	}
+	return javaPkgName(p) + "." + javaClassName(p) + "$" + name
+}
+
+// forEachMember calls f for every Java member generated for the exported
+// declarations in p, with the binary name of the declaring class, the name of
+// the member or "" for the class itself, whether the member is a method, and
+// the Go object it binds. Method names are the final names, after renames.
+func forEachMember(p *types.Package, split bool, f func(class, member string, method bool, obj types.Object)) {
+	renamed := make(map[string]string)
+	for _, r := range findRenames(p) {
+		renamed[r.from] = r.to
+	}
+	method := func(name string) string {
+		m := javaMethodName(name)
+		if r, ok := renamed[m]; ok {
+			return r
+		}
+		return m
+	}
+	class := javaPkgName(p) + "." + javaClassName(p)
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			f(class, method(name), true, obj)
+		case *types.Var:
+			f(class, "get"+name, true, obj)
+			f(class, "set"+name, true, obj)
+		case *types.Const:
+			f(class, name, false, obj)
+		case *types.TypeName:
+			typ := javaBinaryName(p, name, split)
+			f(typ, "", false, obj)
+			if s, ok := obj.Type().Underlying().(*types.Struct); ok {
+				for i := 0; i < s.NumFields(); i++ {
+					if fld := s.Field(i); fld.Exported() && !fld.Anonymous() {
+						f(typ, "get"+fld.Name(), true, fld)
+						f(typ, "set"+fld.Name(), true, fld)
+					}
+				}
+			}
+			recv := obj.Type()
+			if !types.IsInterface(recv) {
+				recv = types.NewPointer(recv)
+			}
+			ms := types.NewMethodSet(recv)
+			for i := 0; i < ms.Len(); i++ {
+				if m := ms.At(i).Obj(); m.Exported() {
+					f(typ, method(m.Name()), true, m)
+				}
+			}
+		}
+	}
+}
+
+// findMembers returns the Java members generated for the exported
+// declarations in p, sorted by Java name, with the positions in fset of the
+// Go declarations.
+func findMembers(fset *token.FileSet, p *types.Package, split bool) []javaMember {
+	var members []javaMember
+	forEachMember(p, split, func(class, member string, method bool, obj types.Object) {
+		pos := fset.Position(obj.Pos())
+		if !pos.IsValid() {
+			return
+		}
+		m := javaMember{Java: strings.Replace(class, "$", ".", -1), Go: fmt.Sprintf("%s:%d", pos.Filename, pos.Line), class: class}
+		if member != "" {
+			m.Java += "." + member
+		}
+		if method {
+			m.method = member
+		}
+		members = append(members, m)
+	})
+	sort.Slice(members, func(i, j int) bool { return members[i].Java < members[j].Java })
+	return members
+}
+
+// writeIDEMetadata writes the metadata of the jar built by cfg from pkgs to
+// path as JSON.
+func writeIDEMetadata(path string, cfg *config, fset *token.FileSet, pkgs []*types.Package) error {
+	var md ideMetadata
+	if cfg.out == nil {
+		jar, err := filepath.Abs(cfg.target)
+		if err != nil {
+			return err
+		}
+		md.Jar = jar
+	}
+	if cfg.sourceDir != "" {
+		dir, err := filepath.Abs(cfg.sourceDir)
+		if err != nil {
+			return err
+		}
+		md.SourceRoots = []string{dir}
+	}
+	for _, p := range pkgs {
+		md.Packages = append(md.Packages, packageMetadata{
+			ImportPath:  p.Path(),
+			JavaPackage: javaPkgName(p),
+			JavaClass:   javaPkgName(p) + "." + javaClassName(p),
+			Members:     findMembers(fset, p, cfg.split),
+		})
+	}
+	d, err := json.MarshalIndent(md, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(d, '\n'), 0644)
+}
+
+// sourceMapPath is the path of the source map in the jar.
+const sourceMapPath = "META-INF/gojava/sourcemap.txt"
+
+// writeSourceMap writes the source map for pkgs to jarDir. Each line holds
+// the binary class name, method name and Go position of a generated method,
+// separated by tabs. It is read by go.SourceMap to remap stack traces.
+func writeSourceMap(jarDir string, fset *token.FileSet, pkgs []*types.Package, split bool) error {
+	var b bytes.Buffer
+	for _, p := range pkgs {
+		for _, m := range findMembers(fset, p, split) {
+			if m.method != "" {
+				fmt.Fprintf(&b, "%s\t%s\t%s\n", m.class, m.method, m.Go)
+			}
+		}
+	}
+	path := filepath.Join(jarDir, filepath.FromSlash(sourceMapPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b.Bytes(), 0600)
+}
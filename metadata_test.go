@@ -0,0 +1,85 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const membersSrc = `package testpkg
+
+type Point struct {
+	X int
+	y int
+}
+
+func (p *Point) Wait() {}
+
+func NewPoint() *Point { return nil }
+
+var Origin Point
+`
+
+// typeCheckMembers type checks membersSrc, returning the positions in point.go.
+func typeCheckMembers(t *testing.T) (*token.FileSet, *types.Package) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "point.go", membersSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := (&types.Config{}).Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, p
+}
+
+func TestFindMembers(t *testing.T) {
+	fset, p := typeCheckMembers(t)
+	exp := []javaMember{
+		{"go.testpkg.Testpkg.Point", "point.go:3", "go.testpkg.Testpkg$Point", ""},
+		{"go.testpkg.Testpkg.Point.getX", "point.go:4", "go.testpkg.Testpkg$Point", "getX"},
+		{"go.testpkg.Testpkg.Point.setX", "point.go:4", "go.testpkg.Testpkg$Point", "setX"},
+		{"go.testpkg.Testpkg.Point.wait_", "point.go:8", "go.testpkg.Testpkg$Point", "wait_"},
+		{"go.testpkg.Testpkg.getOrigin", "point.go:12", "go.testpkg.Testpkg", "getOrigin"},
+		{"go.testpkg.Testpkg.newPoint", "point.go:10", "go.testpkg.Testpkg", "newPoint"},
+		{"go.testpkg.Testpkg.setOrigin", "point.go:12", "go.testpkg.Testpkg", "setOrigin"},
+	}
+	if got := findMembers(fset, p, false); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+	if got := findMembers(fset, p, true); got[0].Java != "go.testpkg.Point" {
+		t.Errorf("expected split class go.testpkg.Point, got %s", got[0].Java)
+	}
+}
+
+func TestWriteSourceMap(t *testing.T) {
+	fset, p := typeCheckMembers(t)
+	dir, err := ioutil.TempDir("", "gojava_sourcemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeSourceMap(dir, fset, []*types.Package{p}, true); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(dir, sourceMapPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "go.testpkg.Point\tgetX\tpoint.go:4\n" +
+		"go.testpkg.Point\tsetX\tpoint.go:4\n" +
+		"go.testpkg.Point\twait_\tpoint.go:8\n" +
+		"go.testpkg.Testpkg\tgetOrigin\tpoint.go:12\n" +
+		"go.testpkg.Testpkg\tnewPoint\tpoint.go:10\n" +
+		"go.testpkg.Testpkg\tsetOrigin\tpoint.go:12\n"
+	if string(d) != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, d)
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// bindModulePath is the module path of the generated bind package when the
+// bound packages are built in module mode.
+const bindModulePath = "gojava_bind"
+
+// goModule describes the module containing the bound packages.
+type goModule struct {
+	// path is the module path.
+	path string
+	// dir is the root directory of the module.
+	dir string
+	// replaces holds the replace directives of the module, with relative
+	// directories made absolute.
+	replaces []string
+	// vendored holds the "path version" of each module in vendor/modules.txt.
+	vendored []string
+	// goVersion and toolchain are the go and toolchain directives of the module.
+	goVersion, toolchain string
+	// work is the go.work workspace the module is built in, if any.
+	work *goWork
+}
+
+// goWork describes a go.work workspace.
+type goWork struct {
+	// uses holds the absolute directories of the workspace modules.
+	uses []string
+	// replaces holds the replace directives of the workspace, with relative
+	// directories made absolute.
+	replaces []string
+	// goVersion and toolchain are the go and toolchain directives of the workspace.
+	goVersion, toolchain string
+}
+
+// findModule returns the module containing dir, or nil if the go command is
+// not in module mode there.
+func findModule(dir string) (*goModule, error) {
+	out, err := commandOutputIn(dir, "go", "env", "GOMOD")
+	if err != nil {
+		return nil, err
+	}
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == os.DevNull {
+		return nil, nil
+	}
+	d, err := ioutil.ReadFile(gomod)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseGoMod(d, filepath.Dir(gomod))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", gomod, err)
+	}
+	if m.vendored, err = readVendored(filepath.Join(m.dir, "vendor", "modules.txt")); err != nil {
+		return nil, err
+	}
+	if m.work, err = findWorkspace(dir); err != nil {
+		return nil, err
+	}
+	if out, err := commandOutputIn(dir, "go", "version"); err == nil {
+		verbosef("Building with %s", out)
+	}
+	return m, nil
+}
+
+// findWorkspace returns the go.work workspace of dir, or nil if there is
+// none.
+func findWorkspace(dir string) (*goWork, error) {
+	out, err := commandOutputIn(dir, "go", "env", "GOWORK")
+	if err != nil {
+		return nil, err
+	}
+	gowork := strings.TrimSpace(string(out))
+	if gowork == "" || gowork == "off" {
+		return nil, nil
+	}
+	d, err := ioutil.ReadFile(gowork)
+	if err != nil {
+		return nil, err
+	}
+	directives, err := parseModFile(d)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", gowork, err)
+	}
+	w, dir := &goWork{}, filepath.Dir(gowork)
+	for _, f := range directives {
+		switch {
+		case f[0] == "use" && len(f) == 2:
+			w.uses = append(w.uses, filepath.Join(dir, f[1]))
+		case f[0] == "go" && len(f) == 2:
+			w.goVersion = f[1]
+		case f[0] == "toolchain" && len(f) == 2:
+			w.toolchain = f[1]
+		case f[0] == "replace":
+			w.replaces = append(w.replaces, absReplace(f[1:], dir))
+		}
+	}
+	return w, nil
+}
+
+// bindGoWork returns a go.work file using the bind package module in bindDir
+// along with all the modules of w.
+func (w *goWork) bindGoWork(bindDir string) []byte {
+	var b bytes.Buffer
+	writeGoDirectives(&b, w.goVersion, w.toolchain)
+	fmt.Fprintf(&b, "use %s\n", bindDir)
+	for _, u := range w.uses {
+		fmt.Fprintf(&b, "use %s\n", u)
+	}
+	for _, r := range w.replaces {
+		fmt.Fprintf(&b, "replace %s\n", r)
+	}
+	return b.Bytes()
+}
+
+// writeGoDirectives writes the go and toolchain directives to b, so the go
+// command selects the same toolchain as for the bound module.
+func writeGoDirectives(b *bytes.Buffer, goVersion, toolchain string) {
+	if goVersion != "" {
+		fmt.Fprintf(b, "go %s\n", goVersion)
+	}
+	if toolchain != "" {
+		fmt.Fprintf(b, "toolchain %s\n", toolchain)
+	}
+	fmt.Fprintln(b)
+}
+
+// parseModFile returns the directives in the go.mod or go.work file contents
+// d, one per line, as fields starting with the directive name. Directives in
+// blocks are expanded to individual lines.
+func parseModFile(d []byte) ([][]string, error) {
+	var directives [][]string
+	block := ""
+	s := bufio.NewScanner(bytes.NewReader(d))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case block != "" && fields[0] == ")":
+			block = ""
+		case block != "":
+			directives = append(directives, append([]string{block}, fields...))
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			directives = append(directives, fields)
+		}
+	}
+	return directives, s.Err()
+}
+
+// parseGoMod parses the go.mod file contents d, for a module rooted at dir.
+func parseGoMod(d []byte, dir string) (*goModule, error) {
+	directives, err := parseModFile(d)
+	if err != nil {
+		return nil, err
+	}
+	m := &goModule{dir: dir}
+	for _, f := range directives {
+		switch {
+		case f[0] == "module" && len(f) == 2:
+			m.path = strings.Trim(f[1], `"`)
+		case f[0] == "go" && len(f) == 2:
+			m.goVersion = f[1]
+		case f[0] == "toolchain" && len(f) == 2:
+			m.toolchain = f[1]
+		case f[0] == "replace":
+			m.replaces = append(m.replaces, absReplace(f[1:], dir))
+		}
+	}
+	if m.path == "" {
+		return nil, fmt.Errorf("no module directive")
+	}
+	return m, nil
+}
+
+// absReplace returns the replace directive in fields, with a relative
+// replacement directory made absolute against dir.
+func absReplace(fields []string, dir string) string {
+	if n := len(fields); n >= 3 && fields[n-2] == "=>" {
+		if r := fields[n-1]; strings.HasPrefix(r, "./") || strings.HasPrefix(r, "../") {
+			fields[n-1] = filepath.Join(dir, r)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// readVendored returns the "path version" of each module listed in the
+// vendor/modules.txt file at path, or nil if it does not exist.
+func readVendored(path string) ([]string, error) {
+	d, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mods []string
+	for _, line := range strings.Split(string(d), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "#" && strings.HasPrefix(fields[2], "v") {
+			mods = append(mods, fields[1]+" "+fields[2])
+		}
+	}
+	return mods, nil
+}
+
+// bindGoMod returns a go.mod file for the generated bind package, which
+// depends on the module m and the gomobile-java module at bindModDir. The
+// replace directives of m are copied, since they do not apply to m when it is
+// used as a dependency. Vendored modules are required at their vendored
+// versions. In a workspace, the workspace modules are not replaced.
+func (m *goModule) bindGoMod(bindModPath, bindModDir string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "module %s\n\n", bindModulePath)
+	writeGoDirectives(&b, m.goVersion, m.toolchain)
+	fmt.Fprintf(&b, "require %s v0.0.0\n", m.path)
+	if v := dependencyVersion(bindModPath); v != "" {
+		fmt.Fprintf(&b, "require %s %s\n", bindModPath, v)
+	} else {
+		fmt.Fprintf(&b, "require %s v0.0.0\n", bindModPath)
+		fmt.Fprintf(&b, "replace %s => %s\n", bindModPath, bindModDir)
+	}
+	for _, v := range m.vendored {
+		fmt.Fprintf(&b, "require %s\n", v)
+	}
+	if m.work != nil {
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "\nreplace %s => %s\n", m.path, m.dir)
+	for _, r := range m.replaces {
+		fmt.Fprintf(&b, "replace %s\n", r)
+	}
+	return b.Bytes()
+}
+
+// dependencyVersion returns the version of the module path gojava was built
+// with, or "" if it is not known.
+func dependencyVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, d := range info.Deps {
+		if d.Path == path && d.Replace == nil {
+			return d.Version
+		}
+	}
+	return ""
+}
+
+// writeBindModule writes the go.mod and go.sum files for the bind package in
+// bindDir, along with a go.work file if m is built in a workspace, and the
+// vendor directory if m is vendored.
+func (m *goModule) writeBindModule(bindDir, bindModPath, bindModDir string) error {
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "go.mod"), m.bindGoMod(bindModPath, bindModDir), 0600); err != nil {
+		return err
+	}
+	if m.isVendored() {
+		verbosef("Building with the vendored modules of %s\n", m.dir)
+		if err := m.writeBindVendor(bindDir, bindModPath, bindModDir); err != nil {
+			return err
+		}
+	}
+	if m.work != nil {
+		if err := ioutil.WriteFile(filepath.Join(bindDir, "go.work"), m.work.bindGoWork(bindDir), 0600); err != nil {
+			return err
+		}
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(m.dir, "go.sum"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(bindDir, "go.sum"), sum, 0600)
+}
+
+// isVendored reports whether the bind package of m is built from the vendor
+// directory of m, with -mod=vendor. Workspaces are built from the module cache.
+func (m *goModule) isVendored() bool {
+	return m != nil && len(m.vendored) > 0 && m.work == nil
+}
+
+// writeBindVendor writes the vendor directory of the bind module in bindDir:
+// a copy of the vendor directory of m, with m and the gomobile-java module at
+// bindModDir linked in, as the bind module depends on them.
+func (m *goModule) writeBindVendor(bindDir, bindModPath, bindModDir string) error {
+	vendorDir := filepath.Join(bindDir, "vendor")
+	d, err := ioutil.ReadFile(filepath.Join(m.dir, "vendor", "modules.txt"))
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(filepath.Join(m.dir, "vendor"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Join(m.dir, "vendor"), path)
+		if err != nil || rel == "modules.txt" {
+			return err
+		}
+		dst := filepath.Join(vendorDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		return copyFile(dst, path)
+	})
+	if err != nil {
+		return err
+	}
+	txt := bindModulesTxt(d, m.dir)
+	for _, mod := range []struct{ path, dir, version, goVersion string }{
+		{m.path, m.dir, "", m.goVersion},
+		{bindModPath, bindModDir, dependencyVersion(bindModPath), ""},
+	} {
+		link := filepath.Join(vendorDir, filepath.FromSlash(mod.path))
+		if _, err := os.Stat(link); err == nil {
+			// Vendored by m.
+			continue
+		}
+		pkgs, err := modulePackages(mod.path, mod.dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(link), 0700); err != nil {
+			return err
+		}
+		if err := os.Symlink(mod.dir, link); err != nil {
+			return err
+		}
+		txt = append(txt, vendoredModule(mod.path, mod.version, mod.dir, mod.goVersion, pkgs)...)
+	}
+	return ioutil.WriteFile(filepath.Join(vendorDir, "modules.txt"), txt, 0600)
+}
+
+// vendoredModule returns the lines of vendor/modules.txt for the module path
+// with the packages pkgs, required at version, or replaced by dir if version
+// is empty.
+func vendoredModule(path, version, dir, goVersion string, pkgs []string) []byte {
+	var b bytes.Buffer
+	if version != "" {
+		fmt.Fprintf(&b, "# %s %s\n", path, version)
+	} else {
+		fmt.Fprintf(&b, "# %s v0.0.0 => %s\n", path, dir)
+	}
+	b.WriteString("## explicit")
+	if goVersion != "" {
+		fmt.Fprintf(&b, "; go %s", goVersion)
+	}
+	b.WriteString("\n")
+	for _, p := range pkgs {
+		fmt.Fprintln(&b, p)
+	}
+	if version == "" {
+		fmt.Fprintf(&b, "# %s => %s\n", path, dir)
+	}
+	return b.Bytes()
+}
+
+// bindModulesTxt returns the vendor/modules.txt d of the module in dir, for
+// the bind module: every module is marked as explicitly required, as the bind
+// module requires them all, and relative replacement directories are made
+// absolute, like in its go.mod.
+func bindModulesTxt(d []byte, dir string) []byte {
+	var b bytes.Buffer
+	lines := strings.Split(strings.TrimSuffix(string(d), "\n"), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "#":
+			if j := indexOf(fields, "=>"); j >= 0 {
+				line = "# " + absReplace(fields[1:], dir)
+				fields = fields[:j]
+			}
+			b.WriteString(line + "\n")
+			// Module lines with a version are followed by their annotations.
+			if len(fields) == 3 && (i+1 == len(lines) || !strings.HasPrefix(lines[i+1], "## ")) {
+				b.WriteString("## explicit\n")
+			}
+		case len(fields) >= 2 && fields[0] == "##":
+			if !strings.Contains(line, "explicit") {
+				line = "## explicit; " + strings.TrimPrefix(line, "## ")
+			}
+			b.WriteString(line + "\n")
+		default:
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func indexOf(fields []string, s string) int {
+	for i, f := range fields {
+		if f == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// modulePackages returns the import paths of the packages of the module path
+// in dir, skipping the vendor and testdata directories and nested modules.
+func modulePackages(path, dir string) ([]string, error) {
+	var pkgs []string
+	seen := make(map[string]bool)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if p == dir {
+				return nil
+			}
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		pkg := path
+		if rel != "." {
+			pkg += "/" + filepath.ToSlash(rel)
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+		return nil
+	})
+	return pkgs, err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGoMod = `module example.com/proj // the project
+
+go 1.21
+
+toolchain go1.22.3
+
+require example.com/dep v1.2.0
+
+replace example.com/dep => ../dep
+
+replace (
+	example.com/other v1.0.0 => example.com/fork v1.0.1
+	example.com/local => ./local
+)
+`
+
+func TestBindGoMod(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator), "src", "proj")
+	m, err := parseGoMod([]byte(testGoMod), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.vendored = []string{"example.com/vendored v0.3.0"}
+	if m.path != "example.com/proj" {
+		t.Errorf("unexpected module path %s", m.path)
+	}
+	gomod := string(m.bindGoMod("github.com/sridharv/gomobile-java", "/gomobile-java"))
+	for _, s := range []string{
+		"module gojava_bind\n",
+		"go 1.21\ntoolchain go1.22.3\n",
+		"require example.com/proj v0.0.0\n",
+		"require example.com/vendored v0.3.0\n",
+		"replace example.com/proj => " + dir + "\n",
+		"replace example.com/dep => " + filepath.Join(dir, "..", "dep") + "\n",
+		"replace example.com/other v1.0.0 => example.com/fork v1.0.1\n",
+		"replace example.com/local => " + filepath.Join(dir, "local") + "\n",
+	} {
+		if !strings.Contains(gomod, s) {
+			t.Errorf("go.mod missing %q:\n%s", s, gomod)
+		}
+	}
+}
+
+func TestBindGoWork(t *testing.T) {
+	m, err := parseGoMod([]byte(testGoMod), "/src/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.work = &goWork{uses: []string{"/src/proj", "/src/dep"}, goVersion: "1.22"}
+	gomod := string(m.bindGoMod("github.com/sridharv/gomobile-java", "/gomobile-java"))
+	if strings.Contains(gomod, "replace example.com/proj") {
+		t.Errorf("workspace module should not be replaced:\n%s", gomod)
+	}
+	gowork := string(m.work.bindGoWork("/tmp/gojava_bind"))
+	for _, s := range []string{"go 1.22\n", "use /tmp/gojava_bind\n", "use /src/proj\n", "use /src/dep\n"} {
+		if !strings.Contains(gowork, s) {
+			t.Errorf("go.work missing %q:\n%s", s, gowork)
+		}
+	}
+}
+
+func TestBindModulesTxt(t *testing.T) {
+	txt := "# example.com/dep v1.2.0 => ../dep\n## explicit; go 1.21\nexample.com/dep\n# example.com/indirect v0.1.0\n## go 1.20\nexample.com/indirect\n# example.com/old v0.1.0\nexample.com/old\n# example.com/dep => ../dep\n"
+	dir := filepath.Join(string(filepath.Separator), "src", "proj")
+	got := string(bindModulesTxt([]byte(txt), dir))
+	dep := filepath.Join(dir, "..", "dep")
+	exp := "# example.com/dep v1.2.0 => " + dep + "\n## explicit; go 1.21\nexample.com/dep\n# example.com/indirect v0.1.0\n## explicit; go 1.20\nexample.com/indirect\n# example.com/old v0.1.0\n## explicit\nexample.com/old\n# example.com/dep => " + dep + "\n"
+	if got != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, got)
+	}
+}
+
+func TestWriteBindVendor(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for name, src := range map[string]string{
+		"proj/go.mod":                           "module example.com/proj\n\ngo 1.21\n\nrequire example.com/dep v1.2.0\n",
+		"proj/lib/lib.go":                       "package lib\n\nimport \"example.com/dep\"\n\nfunc F() int { return dep.X }\n",
+		"proj/vendor/modules.txt":               "# example.com/dep v1.2.0\n## explicit; go 1.21\nexample.com/dep\n",
+		"proj/vendor/example.com/dep/dep.go":    "package dep\n\nconst X = 1\n",
+		"gomobile/go.mod":                       "module example.com/gomobile\n",
+		"gomobile/bind/seq/seq.go":              "package seq\n\nconst Y = 2\n",
+		"gojava_bind/main.go":                   "package main\n\nimport (\n\t\"example.com/gomobile/bind/seq\"\n\t\"example.com/proj/lib\"\n)\n\nfunc main() { println(lib.F() + seq.Y) }\n",
+		"gomobile/bind/seq/testdata/skipped.go": "package skipped\n",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldModule := os.Getenv("GO111MODULE")
+	os.Setenv("GO111MODULE", "on")
+	defer os.Setenv("GO111MODULE", oldModule)
+	mod, err := findModule(filepath.Join(tmpDir, "proj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mod.isVendored() {
+		t.Fatalf("expected %+v to be vendored", mod)
+	}
+	bindDir := filepath.Join(tmpDir, "gojava_bind")
+	if err := mod.writeBindModule(bindDir, "example.com/gomobile", filepath.Join(tmpDir, "gomobile")); err != nil {
+		t.Fatal(err)
+	}
+	txt, err := ioutil.ReadFile(filepath.Join(bindDir, "vendor", "modules.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(txt), "\nexample.com/gomobile/bind/seq\n# example.com/gomobile => ") || strings.Contains(string(txt), "skipped") {
+		t.Errorf("unexpected modules.txt:\n%s", txt)
+	}
+	// The bind package builds from the vendor directory alone.
+	env := append(os.Environ(), "GOPROXY=off", "GOFLAGS=")
+	args := append(append([]string{"build"}, modFlags(mod, "")...), "-o", os.DevNull, ".")
+	if err := runCommandEnv(bindDir, env, "go", args...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// javaReserved holds the Java keywords and literals, which cannot be used as identifiers.
+var javaReserved = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true,
+	"case": true, "catch": true, "char": true, "class": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extends": true, "final": true, "finally": true, "float": true,
+	"for": true, "goto": true, "if": true, "implements": true, "import": true,
+	"instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true,
+	"return": true, "short": true, "static": true, "strictfp": true, "super": true,
+	"switch": true, "synchronized": true, "this": true, "throw": true, "throws": true,
+	"transient": true, "try": true, "void": true, "volatile": true, "while": true,
+	"true": true, "false": true, "null": true, "_": true,
+}
+
+// objectMethods holds the methods of java.lang.Object that a generated method
+// cannot override or hide. finalize can be overridden, but is called by the
+// garbage collector.
+var objectMethods = map[string]bool{
+	"getClass": true, "notify": true, "notifyAll": true, "wait": true, "finalize": true,
+}
+
+// javaIdent returns name, with an underscore appended if it is reserved in Java.
+func javaIdent(name string) string {
+	if javaReserved[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// rename records a generated Java method renamed to avoid a reserved word or
+// a collision.
+type rename struct {
+	pkg      *types.Package
+	from, to string
+}
+
+func (r rename) String() string {
+	return fmt.Sprintf("%s: renamed Java method %s to %s", r.pkg.Path(), r.from, r.to)
+}
+
+// javaMethodNames returns the names of all Java methods generated for the
+// functions, methods and interface methods in p.
+func javaMethodNames(p *types.Package) []string {
+	seen := make(map[string]bool)
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			seen[javaMethodName(name)] = true
+		case *types.TypeName:
+			ms := types.NewMethodSet(types.NewPointer(obj.Type()))
+			for i := 0; i < ms.Len(); i++ {
+				if m := ms.At(i).Obj(); m.Exported() {
+					seen[javaMethodName(m.Name())] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findRenames returns the renames needed for the Java methods generated for p.
+// A reserved name is renamed by appending underscores until it no longer
+// collides with another method.
+func findRenames(p *types.Package) []rename {
+	names := javaMethodNames(p)
+	taken := make(map[string]bool, len(names))
+	for _, n := range names {
+		taken[n] = true
+	}
+	var renames []rename
+	for _, n := range names {
+		if !javaReserved[n] && !objectMethods[n] {
+			continue
+		}
+		to := n + "_"
+		for taken[to] {
+			to += "_"
+		}
+		taken[to] = true
+		renames = append(renames, rename{pkg: p, from: n, to: to})
+	}
+	return renames
+}
+
+// finalMethodName returns the name of the Java method generated for the Go
+// function or method name in p, after any rename by escapeReserved.
+func finalMethodName(p *types.Package, name string) string {
+	m := javaMethodName(name)
+	for _, r := range findRenames(p) {
+		if r.from == m {
+			return r.to
+		}
+	}
+	return m
+}
+
+// escapeParams matches parameter declarations named with a reserved word.
+var escapeParams = regexp.MustCompile(`([\w\]>]\s+)(` + reservedAlt() + `)(\s*[,)])`)
+
+func reservedAlt() string {
+	words := make([]string, 0, len(javaReserved))
+	for w := range javaReserved {
+		words = append(words, regexp.QuoteMeta(w))
+	}
+	sort.Strings(words)
+	return strings.Join(words, "|")
+}
+
+// javaTypeDecl matches the declaration of a Java class or interface.
+var javaTypeDecl = regexp.MustCompile(`\b(?:class|interface)\s+([\p{L}\p{N}_$]+)`)
+
+// generatedReceivers returns a pattern matching the receivers of calls to
+// methods of the classes declared in src: this, the classes themselves and
+// the variables and parameters declared with their types. Calls on other
+// receivers, such as the wait of java.lang.Object, are not renamed.
+func generatedReceivers(src []byte) string {
+	var classes []string
+	seen := make(map[string]bool)
+	for _, m := range javaTypeDecl.FindAllSubmatch(src, -1) {
+		if c := string(m[1]); !seen[c] {
+			seen[c] = true
+			classes = append(classes, regexp.QuoteMeta(c))
+		}
+	}
+	recvs := []string{"this"}
+	if len(classes) > 0 {
+		sort.Strings(classes)
+		recvs = append(recvs, classes...)
+		vars := regexp.MustCompile(`(?:^|[^\p{L}\p{N}_$])(?:` + strings.Join(classes, "|") + `)\s+([\p{L}\p{N}_$]+)\s*[=,);:]`)
+		for _, m := range vars.FindAllSubmatch(src, -1) {
+			if v := string(m[1]); !seen[v] && !javaReserved[v] {
+				seen[v] = true
+				recvs = append(recvs, regexp.QuoteMeta(v))
+			}
+		}
+	}
+	return `(?:^|[^\p{L}\p{N}_$.])(?:[\p{L}\p{N}_$]+\.)*(?:` + strings.Join(recvs, "|") + `)\.`
+}
+
+// escapeReserved rewrites the Java file for p at javaPath and the JNI glue in
+// cPaths, renaming methods and parameters that are not valid Java identifiers.
+// Only the declarations of the renamed methods and the calls on receivers of
+// the generated classes are rewritten. Every rename is reported on stderr as
+// it changes the generated API.
+func escapeReserved(p *types.Package, javaPath string, cPaths []string) error {
+	renames := findRenames(p)
+	src, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		return err
+	}
+	src = escapeParams.ReplaceAll(src, []byte("${1}${2}_${3}"))
+	recv := generatedReceivers(src)
+	for _, r := range renames {
+		fmt.Fprintln(os.Stderr, "warning:", r)
+		decl := regexp.MustCompile(`(?m)([\w\]>]\s+|` + recv + `)` + regexp.QuoteMeta(r.from) + `(\s*\()`)
+		src = decl.ReplaceAll(src, []byte("${1}"+r.to+"${2}"))
+	}
+	if err := ioutil.WriteFile(javaPath, src, 0600); err != nil {
+		return err
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	for _, path := range cPaths {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, r := range renames {
+			sym := regexp.MustCompile(`\b(Java_\w*_)` + jniMangle(r.from) + `(\s*\()`)
+			d = sym.ReplaceAll(d, []byte("${1}"+jniMangle(r.to)+"${2}"))
+			// Method IDs of Java implementations of interfaces are looked up by name.
+			d = []byte(strings.Replace(string(d), `"`+r.from+`"`, `"`+r.to+`"`, -1))
+		}
+		if err := ioutil.WriteFile(path, d, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identEnd matches the end of a Java identifier. Unlike \b it handles
+// identifiers ending in non-ASCII letters.
+const identEnd = `([^\p{L}\p{N}_$]|$)`
+
+// jniMangle returns the JNI mangled form of the Java class or member name s.
+// Characters outside ASCII are escaped as the UTF-16 code units _0xxxx.
+func jniMangle(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '_':
+			b.WriteString("_1")
+		case r == ';':
+			b.WriteString("_2")
+		case r == '[':
+			b.WriteString("_3")
+		case r == '.' || r == '/':
+			b.WriteByte('_')
+		case r < 0x80 && r != '$':
+			b.WriteRune(r)
+		default:
+			for _, c := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, "_0%04x", c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// asciiIdent returns s with all characters outside ASCII replaced by _0xxxx,
+// for use as a C identifier.
+func asciiIdent(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+		for _, c := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&b, "_0%04x", c)
+		}
+	}
+	return b.String()
+}
+
+var (
+	jniSymbol    = regexp.MustCompile(`\bJava_[\p{L}\p{N}_]+`)
+	cgoExport    = regexp.MustCompile(`(?m)^//export\s+(\S+)`)
+	nonASCIIName = regexp.MustCompile(`[^\x00-\x7f]`)
+)
+
+// mangleNonASCII rewrites the generated Go and C files in paths so that JNI
+// symbols and cgo exported functions derived from non-ASCII Go identifiers
+// only contain ASCII characters. JNI symbols are mangled as required by the
+// JNI specification so the JVM can find them.
+func mangleNonASCII(paths []string) error {
+	srcs := make([][]byte, len(paths))
+	var exports []string
+	for i, path := range paths {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		srcs[i] = d
+		for _, m := range cgoExport.FindAllSubmatch(d, -1) {
+			if nonASCIIName.Match(m[1]) {
+				exports = append(exports, string(m[1]))
+			}
+		}
+	}
+	var exportRef *regexp.Regexp
+	if len(exports) > 0 {
+		quoted := make([]string, len(exports))
+		for i, e := range exports {
+			quoted[i] = regexp.QuoteMeta(e)
+		}
+		exportRef = regexp.MustCompile(`(^|[^\p{L}\p{N}_])(` + strings.Join(quoted, "|") + `)` + identEnd)
+	}
+	for i, path := range paths {
+		d := srcs[i]
+		if !nonASCIIName.Match(d) {
+			continue
+		}
+		if exportRef != nil {
+			d = exportRef.ReplaceAllFunc(d, func(m []byte) []byte {
+				sub := exportRef.FindSubmatch(m)
+				return append(append(append([]byte{}, sub[1]...), asciiIdent(string(sub[2]))...), sub[3]...)
+			})
+		}
+		if !strings.HasSuffix(path, ".go") {
+			d = jniSymbol.ReplaceAllFunc(d, func(m []byte) []byte {
+				return []byte(asciiIdent(string(m)))
+			})
+		}
+		if err := ioutil.WriteFile(path, d, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reservedSrc = `package testpkg
+
+type T struct{}
+
+func (t *T) Wait() {}
+
+func New(class int64) *T { return nil }
+
+func New_() {}
+`
+
+const reservedJava = `package go.testpkg;
+
+public abstract class Testpkg {
+	public static final class T extends Seq.Proxy {
+		public T(int refnum) { super(refnum); }
+		public native void wait();
+
+		public void await() { this.wait(); }
+	}
+
+	public static native T new(long class);
+	public static native void new_();
+
+	static T make(Object lock) throws InterruptedException {
+		lock.wait();
+		Seq.Ref.wait();
+		T t = Testpkg.new(1);
+		t.wait();
+		return go.testpkg.Testpkg.new(2);
+	}
+}
+`
+
+const reservedC = `JNIEXPORT jobject JNICALL Java_go_testpkg_Testpkg_new(JNIEnv *env, jclass clazz, jlong class) {}
+JNIEXPORT void JNICALL Java_go_testpkg_Testpkg_new_1(JNIEnv *env, jclass clazz) {}
+JNIEXPORT void JNICALL Java_go_testpkg_Testpkg_00024T_wait(JNIEnv *env, jobject this) {}
+`
+
+func TestEscapeReserved(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	javaPath, cPath := filepath.Join(tmpDir, "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	if err := ioutil.WriteFile(javaPath, []byte(reservedJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(reservedC), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := escapeReserved(typeCheck(t, reservedSrc), javaPath, []string{cPath}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		javaPath: {
+			"super(refnum);",
+			"public native void wait_();",
+			"public static native T new__(long class_);",
+			"public static native void new_();",
+			"public void await() { this.wait_(); }",
+			"\t\tlock.wait();\n\t\tSeq.Ref.wait();\n",
+			"T t = Testpkg.new__(1);\n\t\tt.wait_();",
+			"return go.testpkg.Testpkg.new__(2);",
+		},
+		cPath: {
+			"Java_go_testpkg_Testpkg_new_1_1(JNIEnv *env, jclass clazz, jlong class)",
+			"Java_go_testpkg_Testpkg_new_1(JNIEnv *env, jclass clazz)",
+			"Java_go_testpkg_Testpkg_00024T_wait_1(",
+		},
+	}
+	for path, want := range expected {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+}
+
+func TestJNIMangle(t *testing.T) {
+	for in, want := range map[string]string{
+		"go.testpkg.Testpkg$S": "go_testpkg_Testpkg_00024S",
+		"get_X":                "get_1X",
+		"größe":                "gr_000f6_000dfe",
+		"𝒳":                    "_0d835_0dcb3",
+	} {
+		if got := jniMangle(in); got != want {
+			t.Errorf("jniMangle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMangleNonASCII(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	goPath, cPath := filepath.Join(tmpDir, "go_testpkgmain.go"), filepath.Join(tmpDir, "java_testpkg.c")
+	goSrc := "//export proxytestpkg__Größe\nfunc proxytestpkg__Größe() { testpkg.Größe() }\n"
+	cSrc := "JNIEXPORT void JNICALL Java_go_testpkg_Testpkg_größe(JNIEnv *env, jclass clazz) {\n\tproxytestpkg__Größe();\n}\n"
+	if err := ioutil.WriteFile(goPath, []byte(goSrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(cSrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := mangleNonASCII([]string{goPath, cPath}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		goPath: {"//export proxytestpkg__Gr_000f6_000dfe\n", "func proxytestpkg__Gr_000f6_000dfe()", "testpkg.Größe()"},
+		cPath:  {"Java_go_testpkg_Testpkg_gr_000f6_000dfe(", "\tproxytestpkg__Gr_000f6_000dfe();"},
+	}
+	for path, want := range expected {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+}
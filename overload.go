@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// overloadKey returns the erased Java signature of a method with params, used
+// to detect overloads that would clash.
+func overloadKey(params []string) string {
+	types := make([]string, len(params))
+	for i, p := range params {
+		types[i] = p[:strings.LastIndex(p, " ")]
+	}
+	return strings.Join(types, ",")
+}
+
+// findOverloads groups the exported functions of p whose names end with one of
+// suffixes by their name without the suffix.
+func findOverloads(p *types.Package, suffixes []string) map[string][]*types.Func {
+	families := make(map[string][]*types.Func)
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() {
+			continue
+		}
+		for _, suffix := range suffixes {
+			if base := strings.TrimSuffix(name, suffix); base != name && base != "" {
+				families[base] = append(families[base], fn)
+				break
+			}
+		}
+	}
+	return families
+}
+
+// genOverloads returns the Java source of static methods on the package class
+// named after base, each delegating to one of the functions in fns.
+func genOverloads(p *types.Package, base string, fns []*types.Func) string {
+	seen := make(map[string]bool)
+	if fn, ok := p.Scope().Lookup(base).(*types.Func); ok {
+		if params, _, _, _, err := javaSignature(fn); err == nil {
+			seen[overloadKey(params)] = true
+		}
+	}
+	var b bytes.Buffer
+	for _, fn := range fns {
+		params, args, ret, throws, err := javaSignature(fn)
+		if err != nil {
+			verbosef("skipping overload for %s: %v\n", fn.Name(), err)
+			continue
+		}
+		key := overloadKey(params)
+		if seen[key] {
+			verbosef("skipping overload for %s: %s(%s) already defined\n", fn.Name(), base, key)
+			continue
+		}
+		seen[key] = true
+		ret, call := ret+" ", ""
+		if ret != "void " {
+			call = "return "
+		}
+		fmt.Fprintf(&b, "\n\tpublic static %s%s(%s)%s {\n", ret, javaMethodName(base), strings.Join(params, ", "), throws)
+		fmt.Fprintf(&b, "\t\t%s%s.%s(%s);\n\t}\n", call, javaClassName(p), javaMethodName(fn.Name()), strings.Join(args, ", "))
+	}
+	return b.String()
+}
+
+// addOverloads rewrites the generated Java file for p at path, collapsing
+// functions that differ only by one of suffixes into overloaded methods.
+// Functions FooString and FooBytes are both made available as foo. The
+// original methods are left in place.
+func addOverloads(path string, p *types.Package, suffixes []string) error {
+	if len(suffixes) == 0 {
+		return nil
+	}
+	families := findOverloads(p, suffixes)
+	if len(families) == 0 {
+		return nil
+	}
+	bases := make([]string, 0, len(families))
+	for base := range families {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	var members string
+	for _, base := range bases {
+		members += genOverloads(p, base, families[base])
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if src, err = insertIntoClass(src, javaClassName(p), members); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// listFormat is the go list template used to describe packages matching a pattern.
+const listFormat = "{{.ImportPath}}\t{{.Name}}\t{{len .GoFiles}}\t{{len .CgoFiles}}"
+
+func commandOutput(cmd string, args ...string) ([]byte, error) {
+	return commandOutputIn("", cmd, args...)
+}
+
+// commandOutputIn is like commandOutput, but runs cmd in dir.
+func commandOutputIn(dir, cmd string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	c := exec.CommandContext(buildCtx, cmd, args...)
+	c.Dir = dir
+	c.Stderr = verboseWriter(&stderr, cmd)
+	c.Env = commandEnv()
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v: %s", cmd, strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
+
+// loadPackages loads the type information for pkgs with go/packages,
+// resolving them in dir and recording positions in fset. The types of the
+// packages and their dependencies are read from the export data go list
+// builds in the build cache. Main packages are turned into libraries by
+// adding their Go files to overlay, or rejected if overlay is nil.
+func loadPackages(fset *token.FileSet, dir string, pkgs []string, overlay map[string][]byte) ([]*types.Package, error) {
+	importPaths := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		var err error
+		if importPaths[i], err = canonicalImportPath(p, dir); err != nil {
+			return nil, err
+		}
+		if err := wrapMain(importPaths[i], dir, overlay); err != nil {
+			return nil, err
+		}
+		verbosef("Resolved %s to %s\n", p, importPaths[i])
+	}
+	conf := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedTypes,
+		Context: buildCtx,
+		Dir:     dir,
+		Env:     commandEnv(),
+		Fset:    fset,
+		Overlay: overlay,
+	}
+	loaded, err := packages.Load(conf, importPaths...)
+	if err != nil {
+		return nil, err
+	}
+	// Report the errors of all the packages, dependencies included, rather
+	// than the first one the type checker runs into.
+	var errs []string
+	packages.Visit(loaded, nil, func(p *packages.Package) {
+		for _, e := range p.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("loading %s:\n\t%s", strings.Join(pkgs, " "), strings.Join(errs, "\n\t"))
+	}
+	byPath := make(map[string]*types.Package)
+	for _, p := range loaded {
+		byPath[p.PkgPath] = p.Types
+	}
+	typePkgs := make([]*types.Package, len(pkgs))
+	for i, path := range importPaths {
+		if typePkgs[i] = byPath[path]; typePkgs[i] == nil {
+			return nil, fmt.Errorf("%s: no type information for %s", pkgs[i], path)
+		}
+	}
+	return typePkgs, nil
+}
+
+// isPattern reports whether the package argument p is a wildcard pattern.
+func isPattern(p string) bool {
+	return strings.Contains(p, "...")
+}
+
+// expandPackages expands the wildcard patterns in args, like ./... or
+// github.com/foo/..., to the import paths of the packages they match, in the
+// same way as the go tool run in dir, or the current directory if dir is
+// empty. Main and internal packages, and packages that only
+// contain tests, are skipped, since they cannot be bound. Arguments that are
+// not patterns are returned unchanged.
+func expandPackages(dir string, args []string) ([]string, error) {
+	var pkgs []string
+	for _, arg := range args {
+		if !isPattern(arg) {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		out, err := commandOutputIn(dir, "go", "list", "-f", listFormat, arg)
+		if err != nil {
+			return nil, err
+		}
+		matched, err := filterPackages(out)
+		if err != nil {
+			return nil, err
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("%s: no bindable packages found", arg)
+		}
+		pkgs = append(pkgs, matched...)
+	}
+	return pkgs, nil
+}
+
+// filterPackages parses the output of go list -f listFormat, returning the
+// import paths of all packages that can be bound.
+func filterPackages(out []byte) ([]string, error) {
+	var pkgs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected go list output: %q", line)
+		}
+		goFiles, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		cgoFiles, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fields[1] == "main":
+			fmt.Fprintf(os.Stderr, "warning: skipping main package %s\n", fields[0])
+		case isInternal(fields[0]):
+			fmt.Fprintf(os.Stderr, "warning: skipping internal package %s\n", fields[0])
+		case goFiles+cgoFiles == 0:
+			verbosef("skipping test only package %s\n", fields[0])
+		default:
+			pkgs = append(pkgs, fields[0])
+		}
+	}
+	return pkgs, nil
+}
+
+// isInternal reports whether the package with import path p is internal, and
+// so cannot be imported by the generated bindings.
+func isInternal(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+var packageMain = regexp.MustCompile(`(?m)^package\s+main\b`)
+
+// wrapMain makes the package path, resolved in srcDir, importable if it is a
+// main package, by adding its Go files to overlay with the package clause of
+// a library. The package is then built from its own directory with all its
+// files, such as C sources and embedded files, by go commands run with the
+// overlay. Main packages are rejected if overlay is nil.
+func wrapMain(path, srcDir string, overlay map[string][]byte) error {
+	pkg, err := buildContext(srcDir).Import(path, srcDir, 0)
+	if err != nil {
+		return err
+	}
+	if pkg.Name != "main" {
+		return nil
+	}
+	if overlay == nil {
+		return fmt.Errorf("%s is a main package and cannot be bound, use -main to bind its exported API", path)
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, filepath.Base(pkg.Dir))
+	if name == "main" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "main_" + name
+	}
+	// The files excluded by build constraints are rewritten too, for other
+	// target platforms.
+	files := append(append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...), pkg.IgnoredGoFiles...)
+	if _, ok := overlay[filepath.Join(pkg.Dir, files[0])]; ok {
+		// Wrapped by a previous load with the same overlay.
+		return nil
+	}
+	for i, f := range files {
+		file := filepath.Join(pkg.Dir, f)
+		d, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		loc := packageMain.FindIndex(d)
+		if loc == nil {
+			if i >= len(pkg.GoFiles)+len(pkg.CgoFiles) {
+				continue
+			}
+			return fmt.Errorf("%s: package clause not found", file)
+		}
+		overlay[file] = append(append(append([]byte{}, d[:loc[0]]...), "package "+name...), d[loc[1]:]...)
+	}
+	fmt.Fprintf(os.Stderr, "warning: binding main package %s as package %s, init functions will run when loaded\n", path, name)
+	return nil
+}
+
+// overlayJSON is the file passed to go commands with -overlay.
+type overlayJSON struct {
+	Replace map[string]string
+}
+
+// writeOverlay writes the files of overlay to dir, and an overlay file for
+// the -overlay flag of go commands replacing the files with them, returning
+// its path. It returns "" if overlay is empty.
+func writeOverlay(dir string, overlay map[string][]byte) (string, error) {
+	if len(overlay) == 0 {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	o := overlayJSON{Replace: make(map[string]string)}
+	for file, d := range overlay {
+		path := filepath.Join(dir, fmt.Sprintf("%d_%s", len(o.Replace), filepath.Base(file)))
+		if err := ioutil.WriteFile(path, d, 0600); err != nil {
+			return "", err
+		}
+		o.Replace[file] = path
+	}
+	d, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "overlay.json")
+	return path, ioutil.WriteFile(path, d, 0600)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilterPackages(t *testing.T) {
+	out := "example.com/lib\tlib\t2\t0\nexample.com/cmd/tool\tmain\t1\t0\nexample.com/lib/tests\ttests\t0\t0\nexample.com/cgo\tcgo\t0\t1\nexample.com/internal/util\tutil\t1\t0\nexample.com/lib/internal\tinternal\t1\t0\nexample.com/internals\tinternals\t1\t0\n"
+	pkgs, err := filterPackages([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(pkgs, ",") != "example.com/lib,example.com/cgo,example.com/internals" {
+		t.Errorf("unexpected packages: %v", pkgs)
+	}
+}
+
+func TestWrapMain(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for name, src := range map[string]string{
+		"go.mod":           "module example.com/m\n\ngo 1.16\n",
+		"tool/tool.go":     "// Command tool does things.\npackage main\n\nimport _ \"embed\"\n\n//go:embed version.txt\nvar Version string\n\nfunc Exported() {}\n\nfunc main() {}\n",
+		"tool/gen.go":      "//go:build ignore\n\npackage main\n\nfunc main() {}\n",
+		"tool/version.txt": "1.0\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(name)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldModule := os.Getenv("GO111MODULE")
+	os.Setenv("GO111MODULE", "on")
+	defer os.Setenv("GO111MODULE", oldModule)
+
+	if err := wrapMain("example.com/m/tool", tmpDir, nil); err == nil {
+		t.Fatal("expected main package to be rejected")
+	}
+	overlay := make(map[string][]byte)
+	if err := wrapMain("example.com/m/tool", tmpDir, overlay); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"tool.go", "gen.go"} {
+		d := overlay[filepath.Join(tmpDir, "tool", f)]
+		if !strings.Contains(string(d), "\npackage tool\n") {
+			t.Errorf("%s: package clause not rewritten:\n%s", f, d)
+		}
+	}
+	// The package is built from its own directory, with its embedded files.
+	pkgs, err := loadPackages(token.NewFileSet(), tmpDir, []string{"example.com/m/tool"}, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgs[0].Name() != "tool" || pkgs[0].Path() != "example.com/m/tool" || pkgs[0].Scope().Lookup("Version") == nil {
+		t.Errorf("unexpected package %s %s", pkgs[0].Name(), pkgs[0].Path())
+	}
+	file, err := writeOverlay(filepath.Join(tmpDir, "overlay"), overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := commandOutputIn(tmpDir, "go", append([]string{"list"}, append(modFlags(nil, file), "-f", "{{.Name}} {{.EmbedFiles}}", "example.com/m/tool")...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "tool [version.txt]" {
+		t.Errorf("go list with the overlay: got %q", got)
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	gopath := filepath.Join(tmpDir, "gopath")
+	for name, src := range map[string]string{
+		"lib/lib.go": "package lib\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n",
+		"bad/bad.go": "package bad\n\nfunc Bad() int { return \"\" }\n",
+	} {
+		if err := writeJavaFile(filepath.Join(gopath, "src", "example.com", filepath.FromSlash(name)), []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldGOPATH, oldEnv, oldModule := build.Default.GOPATH, os.Getenv("GOPATH"), os.Getenv("GO111MODULE")
+	build.Default.GOPATH = gopath
+	os.Setenv("GOPATH", gopath)
+	os.Setenv("GO111MODULE", "off")
+	defer func() {
+		build.Default.GOPATH = oldGOPATH
+		os.Setenv("GOPATH", oldEnv)
+		os.Setenv("GO111MODULE", oldModule)
+	}()
+
+	fset := token.NewFileSet()
+	pkgs, err := loadPackages(fset, tmpDir, []string{"example.com/lib"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := pkgs[0].Scope().Lookup("Upper")
+	if obj == nil || filepath.Base(fset.Position(obj.Pos()).Filename) != "lib.go" {
+		t.Errorf("got Upper %v at %v", obj, fset.Position(obj.Pos()))
+	}
+	if _, err := loadPackages(fset, tmpDir, []string{"example.com/bad"}, nil); err == nil || !strings.Contains(err.Error(), "bad.go:3") {
+		t.Errorf("got %v, want the type error of bad.go", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// platformOS and platformArch map GOOS and GOARCH to the os and arch of the
+// classifiers of platform jars, following the os-maven-plugin convention
+// also used by Netty and JavaCPP.
+var (
+	platformOS = map[string]string{
+		"linux": "linux", "darwin": "osx", "windows": "windows", "freebsd": "freebsd",
+		"openbsd": "openbsd", "netbsd": "netbsd", "solaris": "sunos", "illumos": "sunos", "aix": "aix",
+	}
+	platformArch = map[string]string{
+		"amd64": "x86_64", "386": "x86_32", "arm64": "aarch_64", "arm": "arm_32", "ppc64le": "ppcle_64",
+		"ppc64": "ppc_64", "s390x": "s390_64", "riscv64": "riscv64", "loong64": "loongarch_64",
+	}
+)
+
+// goTargetPlatform returns the GOOS and GOARCH the native library is built
+// for.
+func goTargetPlatform() (string, string, error) {
+	out, err := commandOutput("go", "env", "GOOS", "GOARCH")
+	if err != nil {
+		return "", "", err
+	}
+	f := strings.Fields(string(out))
+	if len(f) != 2 {
+		return "", "", fmt.Errorf("go env GOOS GOARCH: unexpected output %q", out)
+	}
+	return f[0], f[1], nil
+}
+
+// platformClassifier returns the classifier of the platform jar for goos and
+// goarch, such as linux-x86_64 or osx-aarch_64.
+func platformClassifier(goos, goarch string) (string, error) {
+	name, arch := platformOS[goos], platformArch[goarch]
+	if name == "" || arch == "" {
+		return "", fmt.Errorf("no platform classifier for %s/%s", goos, goarch)
+	}
+	return name + "-" + arch, nil
+}
+
+// platformJar moves the native library lib to <root>/native/<classifier> in the
+// directory dir, to be written to the platform jar next to the jar cfg.target.
+// It returns the config for the platform jar and the new path of lib.
+func platformJar(cfg *config, dir, lib string) (*config, string, error) {
+	goos, goarch, err := goTargetPlatform()
+	if err != nil {
+		return nil, "", err
+	}
+	classifier, err := platformClassifier(goos, goarch)
+	if err != nil {
+		return nil, "", err
+	}
+	nativeDir := filepath.Join(dir, javaRootDir(javaRoot(cfg)), "native", classifier)
+	if err := os.MkdirAll(nativeDir, 0700); err != nil {
+		return nil, "", err
+	}
+	dest := filepath.Join(nativeDir, filepath.Base(lib))
+	if err := os.Rename(lib, dest); err != nil {
+		return nil, "", err
+	}
+	c := *cfg
+	c.target = strings.TrimSuffix(cfg.target, ".jar") + "-" + classifier + ".jar"
+	c.digests = false
+	return &c, dest, nil
+}
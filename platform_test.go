@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlatformClassifier(t *testing.T) {
+	for _, tc := range []struct {
+		goos, goarch, classifier string
+	}{
+		{"linux", "amd64", "linux-x86_64"},
+		{"darwin", "arm64", "osx-aarch_64"},
+		{"windows", "386", "windows-x86_32"},
+		{"linux", "ppc64le", "linux-ppcle_64"},
+		{"android", "arm64", ""},
+		{"linux", "mips", ""},
+	} {
+		c, err := platformClassifier(tc.goos, tc.goarch)
+		if tc.classifier == "" {
+			if err == nil {
+				t.Errorf("%s/%s: expected an error, got %s", tc.goos, tc.goarch, c)
+			}
+			continue
+		}
+		if err != nil || c != tc.classifier {
+			t.Errorf("%s/%s: expected %s, got %s %v", tc.goos, tc.goarch, tc.classifier, c, err)
+		}
+	}
+}
+
+func TestPlatformJar(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	lib := filepath.Join(tmpDir, "classes", "go", "libgojava")
+	if err := os.MkdirAll(filepath.Dir(lib), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(lib, []byte("lib"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldEnv := envVars
+	defer func() { envVars = oldEnv }()
+	envVars = envFlag{"GOOS=linux", "GOARCH=arm64"}
+
+	cfg, moved, err := platformJar(&config{target: "out/lib.jar"}, filepath.Join(tmpDir, "platform"), lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "out/lib-linux-aarch_64.jar"; cfg.target != exp {
+		t.Errorf("expected %s, got %s", exp, cfg.target)
+	}
+	if exp := filepath.Join(tmpDir, "platform", "go", "native", "linux-aarch_64", "libgojava"); moved != exp {
+		t.Errorf("expected %s, got %s", exp, moved)
+	}
+	if _, err := os.Stat(lib); !os.IsNotExist(err) {
+		t.Errorf("expected the library to be moved, got %v", err)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// nativeDeclDoc matches the declaration of a native method and its doc
+// comment, with the submatches of nativeDecl.
+var nativeDeclDoc = regexp.MustCompile(`(?m)(?:^[ \t]*/\*\*(?:[^*]|\*+[^*/])*\*+/[ \t]*\n)?` + strings.TrimPrefix(nativeDecl.String(), "(?m)"))
+
+// staticInit matches a static initializer loading the native library.
+var staticInit = regexp.MustCompile(`static \{([^{}]*Seq\.touch\(\);[^{}]*)\}`)
+
+// staticInitLine matches the lines of a static initializer loading the native
+// library.
+var staticInitLine = regexp.MustCompile(`(?m)^[ \t]*` + staticInit.String() + `[ \t]*\n`)
+
+// checkServerPackages returns an error if pkgs, bound with the server mode
+// flag, export anything but constants and functions called through
+// go.GoConvert. Types, their methods, callbacks and variables are only bound
+// by the native library, which these modes do not load in process. If
+// exposed is not nil, only the functions in it are bound.
+func checkServerPackages(mode string, pkgs []*types.Package, exposed map[*types.Package][]string, docs *docFinder) error {
+	for _, p := range pkgs {
+		var e []string
+		if exposed != nil {
+			if e = exposed[p]; e == nil {
+				e = []string{}
+			}
+		}
+		converted := make(map[string]bool)
+		for _, f := range findConvertedFuncs(p, e, docs, true) {
+			converted[f.fn.Name()] = true
+		}
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if !obj.Exported() || (e != nil && !containsString(e, name)) {
+				continue
+			}
+			var what string
+			switch obj := obj.(type) {
+			case *types.Func:
+				if !converted[name] {
+					what = "function using types other than basic types, []byte and those of go.GoConvert"
+				}
+			case *types.Var:
+				what = "variable"
+			case *types.TypeName:
+				switch obj.Type().Underlying().(type) {
+				case *types.Struct, *types.Interface:
+					what = "type"
+				}
+			}
+			if what != "" {
+				return fmt.Errorf("%s only binds package functions using basic types, []byte and the types of go.GoConvert, but %s.%s is a %s, which needs the native library in process", mode, p.Path(), name, what)
+			}
+		}
+	}
+	return nil
+}
+
+// outOfProcessClass returns the package class src without the native methods
+// of funcs, which are called through go.GoConvert instead, and loading the
+// native library only when the bindings are not out of process. If wasm is
+// set there is no native library, and the class does not load it.
+func outOfProcessClass(src []byte, funcs []convertedFunc, wasm bool) []byte {
+	names := make(map[string]bool)
+	for _, f := range funcs {
+		names[javaMethodName(f.fn.Name())] = true
+	}
+	src = nativeDeclDoc.ReplaceAllFunc(src, func(m []byte) []byte {
+		sub := nativeDeclDoc.FindSubmatch(m)
+		if names[string(sub[4])] && bytes.Contains(sub[2], []byte("static")) {
+			return nil
+		}
+		return m
+	})
+	if wasm {
+		return staticInitLine.ReplaceAll(src, nil)
+	}
+	return staticInit.ReplaceAll(src, []byte("static {\n\t\tif (!go.Go.OUT_OF_PROCESS) {$1}\n\t}"))
+}
+
+// writeServer writes the main package calling funcs to dir, of the server run
+// out of process if server is "process", or of the WebAssembly module if it
+// is "wasm".
+func writeServer(dir string, funcs []convertedFunc, server string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	write := func(name, src string) error {
+		return ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0600)
+	}
+	if err := write("main.go", string(convertGoSource(funcs, server))); err != nil {
+		return err
+	}
+	if convertUses(funcs, fileEncs...) {
+		if err := write("gojava_mmap_unix.go", strings.Replace(mmapUnixGo, "package gojava_bind", "package main", 1)); err != nil {
+			return err
+		}
+		if err := write("gojava_mmap_other.go", strings.Replace(mmapOtherGo, "package gojava_bind", "package main", 1)); err != nil {
+			return err
+		}
+	}
+	if !convertUses(funcs, fdEncs...) {
+		return nil
+	}
+	if server == "wasm" {
+		// WASI has no descriptors to pass, which the stubs of other
+		// platforms report.
+		return write("gojava_fd.go", strings.Replace(fdOtherGo, "package gojava_bind", "package main", 1))
+	}
+	return write("gojava_fd.go", fdServerGo)
+}
+
+// buildServer builds the server in dir to classDir, for the platform of the
+// native library.
+func buildServer(classDir, dir string, mod *goModule, overlay string) error {
+	verbosef("Building the out of process server\n")
+	args := append([]string{"build", "-o", filepath.Join(classDir, "gojava-server")}, modFlags(mod, overlay)...)
+	return runCommandIn(dir, "go", append(args, ".")...)
+}
+
+const serverGoHeader = `// Command gojava-server calls the bound Go functions for go.GoProcess, when
+// the bindings are built with -out-of-process.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+`
+
+const serverGoMain = `
+// gojavaMagic is written to stdout before the results of calls, so that
+// go.GoProcess skips any output of the initialization of the bound packages.
+const gojavaMagic = "\x00gojava-server 1\n"
+
+// main reads calls from stdin, each an int64 id, an int32 function index and
+// the int32 length and bytes of the encoded arguments, and writes the id and
+// the int32 length and bytes of the result of gojavaCall to stdout. Calls run
+// concurrently and their results are written as they complete. The server
+// exits when stdin is closed, which it is when the JVM exits.
+func main() {
+	out := bufio.NewWriter(os.Stdout)
+	// Output of the bound packages goes to stderr rather than corrupting the
+	// results.
+	os.Stdout = os.Stderr
+	var mu sync.Mutex
+	write := func(b ...[]byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range b {
+			out.Write(p)
+		}
+		if err := out.Flush(); err != nil {
+			os.Exit(1)
+		}
+	}
+	write([]byte(gojavaMagic))
+	in := bufio.NewReader(os.Stdin)
+	for {
+		hdr := make([]byte, 16)
+		if _, err := io.ReadFull(in, hdr); err != nil {
+			return
+		}
+		args := make([]byte, binary.BigEndian.Uint32(hdr[12:]))
+		if _, err := io.ReadFull(in, args); err != nil {
+			return
+		}
+		go func() {
+			res := gojavaCall(int(int32(binary.BigEndian.Uint32(hdr[8:12]))), args)
+			binary.BigEndian.PutUint32(hdr[8:12], uint32(len(res)))
+			write(hdr[:12], res)
+		}()
+	}
+}
+`
+
+const fdServerGo = `package main
+
+import "errors"
+
+var errGojavaFd = errors.New("gojava: file descriptors cannot be passed to a Go server out of process")
+
+func gojavaDup(fd uintptr) (uintptr, error) { return 0, errGojavaFd }
+
+func gojavaExport(fd uintptr) (uintptr, error) { return 0, errGojavaFd }
+`
+
+const goProcessJava = `package go;
+
+import java.io.BufferedInputStream;
+import java.io.BufferedOutputStream;
+import java.io.DataInputStream;
+import java.io.DataOutputStream;
+import java.io.File;
+import java.io.FileOutputStream;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.nio.charset.Charset;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Locale;
+import java.util.Map;
+import java.util.concurrent.TimeUnit;
+
+// GoProcess runs the Go server calling the bound package functions in a child
+// process, when the bindings are built with -out-of-process, so that no native
+// code is loaded into the JVM and a crash of the Go code cannot take it down.
+// The server is started by the first call. When it exits, the calls in flight
+// throw GoUnavailableException and it is restarted after a delay, which
+// doubles each time it exits, up to a maximum. Calls made before it is
+// restarted throw GoUnavailableException without waiting.
+public final class GoProcess {
+	private static final byte[] MAGIC = "\u0000gojava-server 1\n".getBytes(Charset.forName("US-ASCII"));
+
+	// STABLE_NANOS is how long the server must run before exiting for the
+	// delay to start again from the initial one.
+	private static final long STABLE_NANOS = TimeUnit.MINUTES.toNanos(1);
+
+	private static File executable;
+	private static GoProcess current;
+	private static long initialBackoff = TimeUnit.MILLISECONDS.toNanos(100);
+	private static long maxBackoff = TimeUnit.SECONDS.toNanos(30);
+	// backoff is the delay before the last restart, or 0.
+	private static long backoff;
+	// retryAt is the System.nanoTime before which the server is not started.
+	private static long retryAt = System.nanoTime();
+	private static int crashes;
+	private static IOException lastFailure;
+
+	private final Process process;
+	private final long started = System.nanoTime();
+	private final DataOutputStream out;
+	private final Map<Long, Call> calls = new HashMap<Long, Call>();
+	private long nextId;
+	private GoUnavailableException failure;
+	private volatile boolean destroyed;
+
+	private GoProcess(Process process) {
+		this.process = process;
+		this.out = new DataOutputStream(new BufferedOutputStream(process.getOutputStream()));
+	}
+
+	// call calls the function fn in the server with the encoded arguments
+	// args, returning its encoded result.
+	static byte[] call(int fn, byte[] args) throws InterruptedException {
+		return get().send(fn, args).await();
+	}
+
+	// setBackoff sets the delay before the server is restarted after it exits
+	// to initial, doubling up to max while it keeps exiting. The defaults are
+	// 100ms and 30s.
+	public static synchronized void setBackoff(long initial, long max, TimeUnit unit) {
+		if (initial <= 0 || max < initial) {
+			throw new IllegalArgumentException("invalid backoff " + initial + " to " + max);
+		}
+		initialBackoff = unit.toNanos(initial);
+		maxBackoff = unit.toNanos(max);
+	}
+
+	// available reports whether the server is running, or the next call may
+	// start it.
+	public static synchronized boolean available() {
+		return current != null || System.nanoTime() - retryAt >= 0;
+	}
+
+	// crashes returns the number of times the server exited or failed to
+	// start, other than when it was destroyed.
+	public static synchronized int crashes() {
+		return crashes;
+	}
+
+	// destroy stops the server, if it is running, without restarting it. The
+	// calls in flight throw GoUnavailableException, and the next call starts
+	// it again.
+	public static synchronized void destroy() {
+		GoProcess g = current;
+		current = null;
+		if (g != null) {
+			g.destroyed = true;
+			g.process.destroy();
+		}
+	}
+
+	private static synchronized GoProcess get() {
+		if (current != null) {
+			return current;
+		}
+		long wait = retryAt - System.nanoTime();
+		if (wait > 0) {
+			throw new GoUnavailableException("Go server is restarting in " + TimeUnit.NANOSECONDS.toMillis(wait) + "ms", lastFailure);
+		}
+		try {
+			current = start();
+		} catch (IOException ex) {
+			crashed(null, ex);
+			throw new GoUnavailableException("cannot start Go server", ex);
+		}
+		return current;
+	}
+
+	// crashed records that g exited, or that the server failed to start if
+	// g is null, and schedules restarting it.
+	private static synchronized void crashed(GoProcess g, IOException cause) {
+		if (g != null && g != current) {
+			return;
+		}
+		current = null;
+		crashes++;
+		lastFailure = cause;
+		if (g != null && System.nanoTime() - g.started > STABLE_NANOS) {
+			backoff = 0;
+		}
+		backoff = backoff == 0 ? initialBackoff : Math.min(backoff * 2, maxBackoff);
+		retryAt = System.nanoTime() + backoff;
+		if (g != null) {
+			restartLater(backoff);
+		}
+	}
+
+	// restartLater starts the server after delay nanoseconds, unless a call
+	// has started it. If it fails to start, the next call tries again.
+	private static void restartLater(final long delay) {
+		Thread t = new Thread(new Runnable() {
+			public void run() {
+				try {
+					TimeUnit.NANOSECONDS.sleep(delay);
+				} catch (InterruptedException ex) {
+					return;
+				}
+				synchronized (GoProcess.class) {
+					if (current != null || System.nanoTime() - retryAt < 0) {
+						return;
+					}
+					try {
+						current = start();
+					} catch (IOException ex) {
+						crashed(null, ex);
+					}
+				}
+			}
+		}, "gojava-server-restart");
+		t.setDaemon(true);
+		t.start();
+	}
+
+	private static GoProcess start() throws IOException {
+		if (executable == null) {
+			executable = extract();
+		}
+		Process p = new ProcessBuilder(executable.getAbsolutePath()).redirectError(ProcessBuilder.Redirect.INHERIT).start();
+		final DataInputStream in = new DataInputStream(new BufferedInputStream(p.getInputStream()));
+		try {
+			skipOutput(in);
+		} catch (IOException ex) {
+			p.destroy();
+			throw ex;
+		}
+		final GoProcess g = new GoProcess(p);
+		Thread t = new Thread(new Runnable() {
+			public void run() {
+				g.receive(in);
+			}
+		}, "gojava-server");
+		t.setDaemon(true);
+		t.start();
+		return g;
+	}
+
+	// extract writes the server in the jar to a temporary executable.
+	private static File extract() throws IOException {
+		InputStream in = GoProcess.class.getResourceAsStream("/go/gojava-server");
+		if (in == null) {
+			throw new IOException("Go server not found in classpath, build the bindings with -out-of-process");
+		}
+		boolean windows = System.getProperty("os.name").toLowerCase(Locale.ROOT).startsWith("windows");
+		File f = File.createTempFile("gojava-server", windows ? ".exe" : "");
+		f.deleteOnExit();
+		OutputStream out = new FileOutputStream(f);
+		try {
+			byte[] buffer = new byte[8192];
+			int n;
+			while ((n = in.read(buffer)) != -1) {
+				out.write(buffer, 0, n);
+			}
+		} finally {
+			out.close();
+			in.close();
+		}
+		if (!f.setExecutable(true, true)) {
+			throw new IOException("cannot make " + f + " executable");
+		}
+		return f;
+	}
+
+	// skipOutput copies the output of the server to stderr until it starts
+	// answering calls.
+	private static void skipOutput(InputStream in) throws IOException {
+		int matched = 0;
+		while (matched < MAGIC.length) {
+			int b = in.read();
+			if (b < 0) {
+				throw new IOException("Go server exited before starting");
+			}
+			if (b == MAGIC[matched]) {
+				matched++;
+				continue;
+			}
+			System.err.write(MAGIC, 0, matched);
+			matched = 0;
+			if (b == MAGIC[0]) {
+				matched = 1;
+			} else {
+				System.err.write(b);
+			}
+		}
+		System.err.flush();
+	}
+
+	private Call send(int fn, byte[] args) {
+		Call c = new Call();
+		long id;
+		synchronized (calls) {
+			if (failure != null) {
+				throw failure;
+			}
+			id = nextId++;
+			calls.put(id, c);
+		}
+		try {
+			synchronized (out) {
+				out.writeLong(id);
+				out.writeInt(fn);
+				out.writeInt(args.length);
+				out.write(args);
+				out.flush();
+			}
+		} catch (IOException ex) {
+			synchronized (calls) {
+				calls.remove(id);
+			}
+			// The server exited, which receive handles.
+			throw new GoUnavailableException("cannot call Go server", ex);
+		}
+		return c;
+	}
+
+	// receive passes the results read from in to their calls until the
+	// server exits.
+	private void receive(DataInputStream in) {
+		IOException err;
+		try {
+			for (;;) {
+				long id = in.readLong();
+				byte[] res = new byte[in.readInt()];
+				in.readFully(res);
+				Call c;
+				synchronized (calls) {
+					c = calls.remove(id);
+				}
+				if (c != null) {
+					c.finish(res, null);
+				}
+			}
+		} catch (IOException ex) {
+			err = ex;
+		}
+		try {
+			err = new IOException("Go server exited with status " + process.waitFor(), err);
+		} catch (InterruptedException ex) {
+			Thread.currentThread().interrupt();
+		}
+		GoUnavailableException unavailable = new GoUnavailableException(destroyed ? "Go server was destroyed" : err.getMessage(), err);
+		List<Call> pending;
+		synchronized (calls) {
+			failure = unavailable;
+			pending = new ArrayList<Call>(calls.values());
+			calls.clear();
+		}
+		for (Call c : pending) {
+			c.finish(null, unavailable);
+		}
+		if (!destroyed) {
+			crashed(this, err);
+		}
+	}
+
+	// Call is a call waiting for its result.
+	private static final class Call {
+		private byte[] result;
+		private GoUnavailableException error;
+		private boolean done;
+
+		synchronized void finish(byte[] result, GoUnavailableException error) {
+			this.result = result;
+			this.error = error;
+			done = true;
+			notifyAll();
+		}
+
+		synchronized byte[] await() throws InterruptedException {
+			while (!done) {
+				wait();
+			}
+			if (error != null) {
+				throw error;
+			}
+			return result;
+		}
+	}
+}
+`
+
+const goUnavailableExceptionJava = `package go;
+
+// GoUnavailableException is thrown by calls to the Go server of bindings built
+// with -out-of-process when it exits during the call, which may or may not
+// have run, or when it is being restarted.
+public class GoUnavailableException extends RuntimeException {
+	private static final long serialVersionUID = 1L;
+
+	public GoUnavailableException(String message, Throwable cause) {
+		super(message, cause);
+	}
+}
+`
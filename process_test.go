@@ -0,0 +1,120 @@
+package main
+
+import (
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const processClass = `package go.testpkg;
+
+import go.Seq;
+
+public abstract class Testpkg {
+    static { Seq.touch(); }
+
+    public static void touch() {}
+
+    /**
+     * Plain returns s.
+     */
+    public static native String plain(String s);
+    public static native long chan();
+    public native void close();
+}
+`
+
+func TestOutOfProcess(t *testing.T) {
+	p, docs := typeCheckFile(t, convertSrc)
+	var names []string
+	for _, f := range findConvertedFuncs(p, nil, docs, true) {
+		names = append(names, f.fn.Name())
+	}
+	if got, want := strings.Join(names, ","), "After,Check,Lookup,Parse,Plain,Thumbnail"; got != want {
+		t.Errorf("got out of process funcs %s, want %s", got, want)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte(processClass), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := genConverters(tmpDir, tmpDir, []*types.Package{p}, nil, docs, "process"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(classPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"static {\n\t\tif (!go.Go.OUT_OF_PROCESS) { Seq.touch(); }\n\t}",
+		"public static String plain(String s) throws Exception {",
+		"public static native long chan();",
+		"public native void close();",
+	} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("Testpkg.java missing %q:\n%s", s, d)
+		}
+	}
+	for _, s := range []string{"native String plain", "Plain returns s."} {
+		if strings.Contains(string(d), s) {
+			t.Errorf("Testpkg.java contains %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "gojava_server", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil {
+		t.Errorf("server: %v\n%s", err, d)
+	}
+	for _, s := range []string{"package main\n", "\t\tv := conv1.Plain(a0)\n", "func main() {\n"} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("server missing %q:\n%s", s, d)
+		}
+	}
+	if strings.Contains(string(d), "//export") {
+		t.Errorf("server exports functions:\n%s", d)
+	}
+}
+
+func TestCheckServerPackages(t *testing.T) {
+	p, docs := typeCheckFile(t, convertSrc)
+	err := checkServerPackages("-out-of-process", []*types.Package{p}, nil, docs)
+	if err == nil || !strings.Contains(err.Error(), "example.com/testpkg.Chan is a function") {
+		t.Errorf("unconverted function: got %v", err)
+	}
+	exposed := map[*types.Package][]string{p: {"After", "Plain"}}
+	if err := checkServerPackages("-out-of-process", []*types.Package{p}, exposed, docs); err != nil {
+		t.Errorf("exposed functions: %v", err)
+	}
+
+	p, docs = typeCheckFile(t, `package testpkg
+
+const Version = "1"
+
+type Level int
+
+type Store struct{}
+
+func (s *Store) Get(k string) string { return "" }
+
+func Open(path string) error { return nil }
+`)
+	err = checkServerPackages("-backend wasm", []*types.Package{p}, nil, docs)
+	if err == nil || !strings.HasPrefix(err.Error(), "-backend wasm only binds") || !strings.Contains(err.Error(), "example.com/testpkg.Store is a type") {
+		t.Errorf("bound type: got %v", err)
+	}
+	p, docs = typeCheckFile(t, "package testpkg\n\nvar Debug bool\n")
+	if err := checkServerPackages("-backend wasm", []*types.Package{p}, nil, docs); err == nil || !strings.Contains(err.Error(), "Debug is a variable") {
+		t.Errorf("bound variable: got %v", err)
+	}
+}
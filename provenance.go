@@ -0,0 +1,159 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// provenanceBuildType identifies gojava builds in SLSA provenance.
+const provenanceBuildType = "https://github.com/sridharv/gojava/bind@v1"
+
+// inTotoStatement is an in-toto attestation with a SLSA v1 provenance
+// predicate.
+type inTotoStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []resourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     slsaProvenance       `json:"predicate"`
+}
+
+type resourceDescriptor struct {
+	URI         string                 `json:"uri,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Digest      map[string]string      `json:"digest,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition struct {
+		BuildType            string                 `json:"buildType"`
+		ExternalParameters   map[string]interface{} `json:"externalParameters"`
+		InternalParameters   map[string]interface{} `json:"internalParameters"`
+		ResolvedDependencies []resourceDescriptor   `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  string `json:"startedOn"`
+			FinishedOn string `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// gitSource returns the git commit checked out in dir, or nil if dir is not
+// in a git repository. Uncommitted changes are recorded in the annotations.
+func gitSource(dir string) *resourceDescriptor {
+	out, err := commandOutputIn(dir, "git", "rev-parse", "HEAD", "--show-toplevel")
+	if err != nil {
+		return nil
+	}
+	f := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(f) != 2 {
+		return nil
+	}
+	r := &resourceDescriptor{Name: f[1], Digest: map[string]string{"gitCommit": f[0]}}
+	if url, err := commandOutputIn(dir, "git", "config", "--get", "remote.origin.url"); err == nil {
+		r.URI = "git+" + strings.TrimSpace(string(url)) + "@" + f[0]
+	}
+	if status, err := commandOutputIn(dir, "git", "status", "--porcelain", "--untracked-files=no"); err == nil && len(status) > 0 {
+		r.Annotations = map[string]interface{}{"dirty": true}
+	}
+	return r
+}
+
+// sourceDependencies returns the git commits of the directories of the bound
+// packages pkgs, resolved in dir, and the Go modules the native library at lib was built from.
+func sourceDependencies(dir string, pkgs []string, lib string) ([]resourceDescriptor, error) {
+	var deps, mods []resourceDescriptor
+	out, err := commandOutputIn(dir, "go", append([]string{"list", "-f", "{{.Dir}}"}, pkgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, dir := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		r := gitSource(dir)
+		if r == nil {
+			verbosef("Not recording the commit of %s, it is not in a git repository\n", dir)
+			continue
+		}
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			deps = append(deps, *r)
+		}
+	}
+	info, err := buildinfo.ReadFile(lib)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range info.Deps {
+		if d.Replace != nil {
+			d = d.Replace
+		}
+		r := resourceDescriptor{URI: goPurl(d.Path, d.Version)}
+		if d.Sum != "" {
+			r.Digest = map[string]string{"dirhash": d.Sum}
+		}
+		mods = append(mods, r)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].URI < mods[j].URI })
+	return append(deps, mods...), nil
+}
+
+// writeProvenance writes SLSA provenance for the jars, built from the
+// packages pkgs, resolved in dir, starting at started, to path. The native library at lib is
+// read for the Go modules it was built from.
+func writeProvenance(path string, jars []string, lib, dir string, pkgs []string, started time.Time) error {
+	var subjects []resourceDescriptor
+	for _, jar := range jars {
+		digest, err := fileDigest(jar)
+		if err != nil {
+			return err
+		}
+		subjects = append(subjects, resourceDescriptor{Name: filepath.Base(jar), Digest: map[string]string{"sha256": digest}})
+	}
+	deps, err := sourceDependencies(dir, pkgs, lib)
+	if err != nil {
+		return err
+	}
+	goVersion, err := commandOutput("go", "env", "GOVERSION", "GOOS", "GOARCH")
+	if err != nil {
+		return err
+	}
+	env := strings.Fields(string(goVersion))
+	st := inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v1",
+		Subject:       subjects,
+		PredicateType: "https://slsa.dev/provenance/v1",
+	}
+	p := &st.Predicate
+	p.BuildDefinition.BuildType = provenanceBuildType
+	p.BuildDefinition.ExternalParameters = map[string]interface{}{
+		"packages": pkgs,
+		"args":     os.Args[1:],
+	}
+	p.BuildDefinition.InternalParameters = map[string]interface{}{
+		"host": runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if len(env) == 3 {
+		p.BuildDefinition.InternalParameters["goVersion"] = env[0]
+		p.BuildDefinition.InternalParameters["target"] = env[1] + "/" + env[2]
+	}
+	p.BuildDefinition.ResolvedDependencies = deps
+	p.RunDetails.Builder.ID = "https://github.com/sridharv/gojava@" + gojavaVersion()
+	p.RunDetails.Metadata.StartedOn = started.UTC().Format(time.RFC3339)
+	p.RunDetails.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+	d, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(d, '\n'), 0600)
+}
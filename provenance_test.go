@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if r := gitSource(tmpDir); r != nil {
+		t.Fatalf("expected no source outside a repository, got %+v", r)
+	}
+	git := func(args ...string) {
+		c := exec.Command("git", append([]string{"-C", tmpDir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "a.go"), []byte("package a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "a.go")
+	git("commit", "-q", "-m", "a")
+	git("remote", "add", "origin", "https://example.com/a.git")
+	r := gitSource(tmpDir)
+	if r == nil {
+		t.Fatal("expected a source")
+	}
+	commit := r.Digest["gitCommit"]
+	if len(commit) != 40 {
+		t.Errorf("expected a commit, got %q", commit)
+	}
+	if exp := "git+https://example.com/a.git@" + commit; r.URI != exp {
+		t.Errorf("expected %s, got %s", exp, r.URI)
+	}
+	if r.Annotations != nil {
+		t.Errorf("expected a clean checkout, got %v", r.Annotations)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "a.go"), []byte("package b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if r := gitSource(tmpDir); r == nil || r.Annotations["dirty"] != true {
+		t.Errorf("expected a dirty checkout, got %+v", r)
+	}
+}
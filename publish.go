@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// coordinates are the Maven coordinates of a published jar.
+type coordinates struct {
+	group, artifact, version string
+}
+
+// parseCoordinates parses Maven coordinates of the form group:artifact:version.
+func parseCoordinates(s string) (coordinates, error) {
+	f := strings.Split(s, ":")
+	if len(f) != 3 || f[0] == "" || f[1] == "" || f[2] == "" {
+		return coordinates{}, fmt.Errorf("invalid coordinates %q, expected group:artifact:version", s)
+	}
+	if strings.HasSuffix(f[2], "-SNAPSHOT") {
+		return coordinates{}, fmt.Errorf("%s: snapshot versions cannot be published", s)
+	}
+	return coordinates{f[0], f[1], f[2]}, nil
+}
+
+// dir returns the path of the artifact directory in a repository.
+func (c coordinates) dir() string {
+	return strings.Replace(c.group, ".", "/", -1) + "/" + c.artifact
+}
+
+// file returns the path of the file with the classifier and extension ext
+// in a repository.
+func (c coordinates) file(classifier, ext string) string {
+	name := c.artifact + "-" + c.version
+	if classifier != "" {
+		name += "-" + classifier
+	}
+	return c.dir() + "/" + c.version + "/" + name + "." + ext
+}
+
+// artifact is a file published with the jar.
+type artifact struct {
+	classifier, ext string
+	data            []byte
+}
+
+// pomTemplate is the POM published when none is given.
+const pomTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 http://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>%s</groupId>
+  <artifactId>%s</artifactId>
+  <version>%s</version>
+  <packaging>jar</packaging>
+  <name>%s</name>
+  <description>Java bindings to Go packages generated by gojava.</description>
+</project>
+`
+
+// defaultPOM returns a minimal POM for c.
+func defaultPOM(c coordinates) []byte {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	return []byte(fmt.Sprintf(pomTemplate, esc(c.group), esc(c.artifact), esc(c.version), esc(c.artifact)))
+}
+
+// mavenMetadata is the maven-metadata.xml listing the versions of an artifact.
+type mavenMetadata struct {
+	XMLName    xml.Name `xml:"metadata"`
+	GroupID    string   `xml:"groupId"`
+	ArtifactID string   `xml:"artifactId"`
+	Versioning struct {
+		Latest      string   `xml:"latest,omitempty"`
+		Release     string   `xml:"release,omitempty"`
+		Versions    []string `xml:"versions>version"`
+		LastUpdated string   `xml:"lastUpdated,omitempty"`
+	} `xml:"versioning"`
+}
+
+// addVersion returns the metadata old, which may be empty, with the version
+// of c added. The latest release is the greatest version published, so
+// publishing a backport does not replace it.
+func addVersion(old []byte, c coordinates, now time.Time) ([]byte, error) {
+	var md mavenMetadata
+	if len(old) > 0 {
+		if err := xml.Unmarshal(old, &md); err != nil {
+			return nil, fmt.Errorf("maven-metadata.xml: %v", err)
+		}
+	}
+	md.GroupID, md.ArtifactID = c.group, c.artifact
+	v := &md.Versioning
+	found := false
+	for _, s := range v.Versions {
+		found = found || s == c.version
+	}
+	if !found {
+		v.Versions = append(v.Versions, c.version)
+	}
+	for _, s := range v.Versions {
+		if v.Latest == "" || compareVersions(s, v.Latest) > 0 {
+			v.Latest = s
+		}
+		if v.Release == "" || compareVersions(s, v.Release) > 0 {
+			v.Release = s
+		}
+	}
+	v.LastUpdated = now.UTC().Format("20060102150405")
+	d, err := xml.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(d, '\n')...), nil
+}
+
+// versionQualifiers ranks the Maven version qualifiers known to be older
+// or newer than a release, which is ranked "". Unknown qualifiers are newer
+// than all of them, in lexical order.
+var versionQualifiers = map[string]int{"alpha": 1, "beta": 2, "milestone": 3, "rc": 4, "snapshot": 5, "": 6, "sp": 7}
+
+// versionQualifierAliases maps the aliases of known qualifiers to them.
+var versionQualifierAliases = map[string]string{"a": "alpha", "b": "beta", "m": "milestone", "cr": "rc", "ga": "", "final": "", "release": ""}
+
+// versionItems splits the Maven version v into its numbers and qualifiers,
+// separated by dots, dashes and transitions between digits and letters.
+func versionItems(v string) []string {
+	var items []string
+	start := 0
+	for i := 0; i <= len(v); i++ {
+		switch {
+		case i == len(v) || v[i] == '.' || v[i] == '-':
+			items = append(items, strings.ToLower(v[start:i]))
+			start = i + 1
+		case i > start && isDigit(v[i]) != isDigit(v[i-1]):
+			items = append(items, strings.ToLower(v[start:i]))
+			start = i
+		}
+	}
+	return items
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// compareVersionItems compares two items of Maven versions. Numbers are
+// newer than qualifiers, and a missing item is a 0 or a release.
+func compareVersionItems(a, b string) int {
+	isNum := func(s string) bool { return s != "" && isDigit(s[0]) }
+	switch {
+	case isNum(a) && isNum(b):
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	case isNum(a):
+		if b == "" && strings.TrimLeft(a, "0") == "" {
+			return 0
+		}
+		return 1
+	case isNum(b):
+		return -compareVersionItems(b, a)
+	}
+	if alias, ok := versionQualifierAliases[a]; ok {
+		a = alias
+	}
+	if alias, ok := versionQualifierAliases[b]; ok {
+		b = alias
+	}
+	ra, okA := versionQualifiers[a]
+	rb, okB := versionQualifiers[b]
+	switch {
+	case okA && okB:
+		return ra - rb
+	case okA:
+		return -1
+	case okB:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareVersions compares the Maven versions a and b like Maven resolvers
+// do, returning a negative number if a is older than b, 0 if they are the
+// same version and a positive number if a is newer.
+func compareVersions(a, b string) int {
+	ia, ib := versionItems(a), versionItems(b)
+	for i := 0; i < len(ia) || i < len(ib); i++ {
+		var x, y string
+		if i < len(ia) {
+			x = ia[i]
+		}
+		if i < len(ib) {
+			y = ib[i]
+		}
+		if c := compareVersionItems(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// checksums are the extensions and hashes of the checksum files uploaded
+// with every file.
+var checksums = []struct {
+	ext  string
+	hash func() hash.Hash
+}{
+	{"md5", md5.New},
+	{"sha1", sha1.New},
+	{"sha256", sha256.New},
+	{"sha512", sha512.New},
+}
+
+// repository is a Maven repository files are uploaded to over HTTP.
+type repository struct {
+	url            string
+	user, password string
+}
+
+// readCredentials returns the user and password in the file at path, given
+// as user:password, or from $GOJAVA_REPO_USER and $GOJAVA_REPO_PASSWORD if
+// path is empty.
+func readCredentials(path string) (string, string, error) {
+	if path == "" {
+		return os.Getenv("GOJAVA_REPO_USER"), os.Getenv("GOJAVA_REPO_PASSWORD"), nil
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	f := strings.SplitN(strings.TrimSpace(string(d)), ":", 2)
+	if len(f) != 2 {
+		return "", "", fmt.Errorf("%s: expected user:password", path)
+	}
+	return f[0], f[1], nil
+}
+
+func (r *repository) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(buildCtx, method, strings.TrimSuffix(r.url, "/")+"/"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if r.user != "" || r.password != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// get returns the contents of the file at path, or nil if it does not exist.
+func (r *repository) get(path string) ([]byte, error) {
+	resp, err := r.do(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// put uploads d to path, followed by its checksums.
+func (r *repository) put(path string, d []byte) error {
+	verbosef("Uploading %s\n", path)
+	if err := r.putFile(path, d); err != nil {
+		return err
+	}
+	for _, c := range checksums {
+		h := c.hash()
+		h.Write(d)
+		if err := r.putFile(path+"."+c.ext, []byte(hex.EncodeToString(h.Sum(nil)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) putFile(path string, d []byte) error {
+	resp, err := r.do(http.MethodPut, path, d)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("PUT %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// publish uploads artifacts and the updated maven-metadata.xml of c to repo.
+// The metadata is uploaded last, so the version is only listed once all its
+// files are in the repository.
+func publish(repo *repository, c coordinates, artifacts []artifact) error {
+	for _, a := range artifacts {
+		if err := repo.put(c.file(a.classifier, a.ext), a.data); err != nil {
+			return err
+		}
+	}
+	mdPath := c.dir() + "/maven-metadata.xml"
+	old, err := repo.get(mdPath)
+	if err != nil {
+		return err
+	}
+	md, err := addVersion(old, c, time.Now())
+	if err != nil {
+		return err
+	}
+	return repo.put(mdPath, md)
+}
+
+// classifiedFlag is the -classifier flag of gojava publish, holding the
+// classifier and path of each jar given as classifier=path. It may be set more
+// than once.
+type classifiedFlag [][2]string
+
+func (c *classifiedFlag) String() string {
+	var s []string
+	for _, f := range *c {
+		s = append(s, f[0]+"="+f[1])
+	}
+	return strings.Join(s, ",")
+}
+
+func (c *classifiedFlag) Set(v string) error {
+	f := strings.SplitN(v, "=", 2)
+	if len(f) != 2 || f[0] == "" || f[1] == "" {
+		return fmt.Errorf("expected classifier=jar")
+	}
+	*c = append(*c, [2]string{f[0], f[1]})
+	return nil
+}
+
+// runPublish runs gojava publish with the arguments args.
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	repoURL := fs.String("repo", "", "URL of the Maven repository to deploy to.")
+	coords := fs.String("coordinates", "", "Maven coordinates of the jar, as group:artifact:version.")
+	credentials := fs.String("credentials", "", "File containing user:password for the repository. Defaults to $GOJAVA_REPO_USER and $GOJAVA_REPO_PASSWORD.")
+	pomPath := fs.String("pom", "", "POM to publish. A minimal POM is generated if not set.")
+	sources := fs.String("sources", "", "Sources jar to publish with the sources classifier.")
+	javadoc := fs.String("javadoc", "", "Javadoc jar to publish with the javadoc classifier.")
+	var classified classifiedFlag
+	fs.Var(&classified, "classifier", "Jar to publish with a classifier, as classifier=jar, e.g. a platform jar. May be repeated.")
+	sign := fs.Bool("sign", false, "Sign the jars and the POM with gpg, uploading the .asc signatures with them.")
+	signKey := fs.String("sign-key", "", "Key to sign with, instead of the default key of gpg. Implies -sign.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *repoURL == "" || *coords == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: gojava publish -repo <url> -coordinates <group:artifact:version> [flags] <jar>")
+	}
+	c, err := parseCoordinates(*coords)
+	if err != nil {
+		return err
+	}
+	repo := &repository{url: *repoURL}
+	if repo.user, repo.password, err = readCredentials(*credentials); err != nil {
+		return err
+	}
+	var artifacts []artifact
+	jars := append(classifiedFlag{{"", fs.Arg(0)}, {"sources", *sources}, {"javadoc", *javadoc}}, classified...)
+	for _, f := range jars {
+		if f[1] == "" {
+			continue
+		}
+		d, err := ioutil.ReadFile(f[1])
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, artifact{classifier: f[0], ext: "jar", data: d})
+	}
+	pom := defaultPOM(c)
+	if *pomPath != "" {
+		if pom, err = ioutil.ReadFile(*pomPath); err != nil {
+			return err
+		}
+	}
+	artifacts = append(artifacts, artifact{ext: "pom", data: pom})
+	if *sign || *signKey != "" {
+		sigs, err := signArtifacts(c, artifacts, *signKey)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, sigs...)
+	}
+	if err := publish(repo, c, artifacts); err != nil {
+		return err
+	}
+	fmt.Fprintf(logOut, "Published %s:%s:%s to %s\n", c.group, c.artifact, c.version, repo.url)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublish(t *testing.T) {
+	var mu sync.Mutex
+	files := map[string]string{
+		"/com/example/lib/maven-metadata.xml": `<metadata><groupId>com.example</groupId><artifactId>lib</artifactId><versioning><versions><version>1.0.0</version></versions></versioning></metadata>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			d, _ := ioutil.ReadAll(r.Body)
+			files[r.URL.Path] = string(d)
+		case http.MethodGet:
+			d, ok := files[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(d))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := parseCoordinates("com.example:lib:1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &repository{url: srv.URL + "/", user: "u", password: "p"}
+	arts := []artifact{{ext: "jar", data: []byte("jar")}, {classifier: "sources", ext: "jar", data: []byte("src")}, {ext: "pom", data: defaultPOM(c)}}
+	if err := publish(repo, c, arts); err != nil {
+		t.Fatal(err)
+	}
+	for path, exp := range map[string]string{
+		"/com/example/lib/1.1.0/lib-1.1.0.jar":              "jar",
+		"/com/example/lib/1.1.0/lib-1.1.0.jar.sha1":         "f92e777f4341930bad9b2422283c4680d00dbc06",
+		"/com/example/lib/1.1.0/lib-1.1.0-sources.jar":      "src",
+		"/com/example/lib/1.1.0/lib-1.1.0-sources.jar.md5":  "",
+		"/com/example/lib/1.1.0/lib-1.1.0.pom":              "<artifactId>lib</artifactId>",
+		"/com/example/lib/maven-metadata.xml":               "<version>1.0.0</version>\n      <version>1.1.0</version>",
+		"/com/example/lib/maven-metadata.xml.sha512":        "",
+		"/com/example/lib/1.1.0/lib-1.1.0-sources.jar.sha1": "",
+	} {
+		got, ok := files[path]
+		if !ok {
+			t.Errorf("%s was not uploaded", path)
+			continue
+		}
+		if !strings.Contains(got, exp) {
+			t.Errorf("%s: expected %q in %q", path, exp, got)
+		}
+	}
+	if got := files["/com/example/lib/maven-metadata.xml"]; !strings.Contains(got, "<release>1.1.0</release>") {
+		t.Errorf("expected 1.1.0 to be the release, got %s", got)
+	}
+
+	repo.password = "wrong"
+	if err := publish(repo, c, arts); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected an authorization error, got %v", err)
+	}
+}
+
+func TestParseCoordinates(t *testing.T) {
+	for _, s := range []string{"com.example:lib", "com.example::1.0", "com.example:lib:1.0-SNAPSHOT"} {
+		if _, err := parseCoordinates(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestClassifiedFlag(t *testing.T) {
+	var c classifiedFlag
+	for _, v := range []string{"linux-x86_64=a.jar", "osx-aarch_64=b.jar"} {
+		if err := c.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp := "linux-x86_64=a.jar,osx-aarch_64=b.jar"; c.String() != exp {
+		t.Errorf("expected %s, got %s", exp, c.String())
+	}
+	if err := c.Set("a.jar"); err == nil {
+		t.Error("expected an error without a classifier")
+	}
+}
+
+func TestAddVersion(t *testing.T) {
+	old := []byte(`<metadata><groupId>com.example</groupId><artifactId>lib</artifactId><versioning><latest>1.3.0</latest><release>1.3.0</release><versions><version>1.2.4</version><version>1.3.0</version></versions></versioning></metadata>`)
+	md, err := addVersion(old, coordinates{"com.example", "lib", "1.2.5"}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"<latest>1.3.0</latest>", "<release>1.3.0</release>", "<version>1.2.5</version>"} {
+		if !strings.Contains(string(md), exp) {
+			t.Errorf("expected %s in:\n%s", exp, md)
+		}
+	}
+	md, err = addVersion(md, coordinates{"com.example", "lib", "1.10.0"}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(md), "<release>1.10.0</release>") {
+		t.Errorf("expected 1.10.0 to be the release in:\n%s", md)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		cmp  int
+	}{
+		{"1.0", "1", 0},
+		{"1.0.0", "1.0", 0},
+		{"1.10", "1.9", 1},
+		{"1.0-rc1", "1.0", -1},
+		{"1.0-alpha", "1.0-beta", -1},
+		{"1.0-cr2", "1.0-rc1", 1},
+		{"1.0-final", "1.0", 0},
+		{"1.0-sp1", "1.0", 1},
+		{"1.0.1", "1.0-sp1", 1},
+		{"2.0-M1", "2.0-rc1", -1},
+	}
+	for _, test := range tests {
+		c := compareVersions(test.a, test.b)
+		if c > 0 {
+			c = 1
+		} else if c < 0 {
+			c = -1
+		}
+		if c != test.cmp {
+			t.Errorf("compareVersions(%s, %s) = %d, expected %d", test.a, test.b, c, test.cmp)
+		}
+	}
+}
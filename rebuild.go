@@ -0,0 +1,345 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// rebuildInfoPath is the jar entry recording the inputs of the Go side of the
+// build, to only recompile the -s sources of the next build if they did not
+// change, and the entries compiled from the -s sources.
+const rebuildInfoPath = "META-INF/gojava/rebuild.txt"
+
+// canRebuildJava reports whether a build with cfg can reuse the jar of the
+// previous build when only its -s sources changed. Builds writing files
+// describing the whole jar are always run in full, as are builds running
+// annotation processors, which generate classes for sources other than their
+// own, and builds moving packages with -javapkg-for, whose references in the
+// -s sources are renamed with the bound packages.
+func canRebuildJava(cfg *config) bool {
+	return cfg.sourceDir != "" && cfg.sCollisions != collisionMerge && cfg.processorPath == "" && cfg.out == nil &&
+		!cfg.platformJars && !cfg.sbom && cfg.provenance == "" && cfg.jniArchive == "" && cfg.backend == "jni" && len(cfg.javaPkgFor) == 0
+}
+
+// goListPackage holds the fields of go list -json read by buildKey.
+type goListPackage struct {
+	Dir      string
+	Standard bool
+	Module   *struct{ GoMod string }
+
+	// The files of the package, relative to Dir.
+	GoFiles, CgoFiles, CFiles, CXXFiles, HFiles, SFiles, SysoFiles, EmbedFiles []string
+}
+
+// buildKey returns a digest of the inputs of the Go side of a build of pkgs,
+// resolved in dir, with cfg: gojava itself, its configuration, the Go toolchain and target,
+// the files of the non-standard packages pkgs depend on, and the native
+// methods declared in the -s sources, which the native library implements.
+func buildKey(cfg *config, dir string, pkgs []string) (string, error) {
+	h := sha256.New()
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	exeDigest, err := fileDigest(exe)
+	if err != nil {
+		return "", err
+	}
+	c := *cfg
+	c.out = nil
+	c.cache = nil
+	fmt.Fprintf(h, "gojava %s\n%#v\n%v %q\nJAVA_HOME=%s\n", exeDigest, c, cleanEnv, envVars, javaHome)
+	env, err := commandOutputIn(dir, "go", "env", "GOVERSION", "GOOS", "GOARCH", "CC", "CGO_ENABLED", "CGO_CFLAGS", "CGO_LDFLAGS", "GOFLAGS")
+	if err != nil {
+		return "", err
+	}
+	h.Write(env)
+	if err := sourcesDigest(h, dir, pkgs); err != nil {
+		return "", err
+	}
+	err = filepath.Walk(cfg.sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".java" {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range nativeDecl.FindAllString(string(src), -1) {
+			fmt.Fprintf(h, "%s: %s", path, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// sourceDirClasses returns the jar entries of the classes in jarDir compiled
+// from the Java, Kotlin and Scala sources in sourceDir, moved to the root Java
+// package.
+func sourceDirClasses(jarDir, root, sourceDir string) ([]string, error) {
+	sources := make(map[string]bool)
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || (filepath.Ext(path) != ".java" && jvmLanguageOf(path) == nil) {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		sources[filepath.ToSlash(filepath.Join(javaRootDir(root), rel))] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	err = filepath.Walk(jarDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(p) != ".class" {
+			return err
+		}
+		d, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		c, err := readClass(d)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		if c.sourceFile != "" && sources[path.Join(path.Dir(c.name), c.sourceFile)] {
+			rel, err := filepath.Rel(jarDir, p)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(entries)
+	return entries, err
+}
+
+// writeRebuildInfo writes rebuildInfoPath to jarDir, with the build key and
+// the entries compiled from the -s sources.
+func writeRebuildInfo(jarDir, key string, entries []string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "key %s\n", key)
+	for _, e := range entries {
+		fmt.Fprintln(&b, e)
+	}
+	p := filepath.Join(jarDir, filepath.FromSlash(rebuildInfoPath))
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, b.Bytes(), 0600)
+}
+
+// parseRebuildInfo parses the contents of rebuildInfoPath.
+func parseRebuildInfo(d []byte) (string, []string) {
+	var key string
+	var entries []string
+	s := bufio.NewScanner(bytes.NewReader(d))
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "key ") {
+			key = strings.TrimPrefix(s.Text(), "key ")
+		} else if s.Text() != "" {
+			entries = append(entries, s.Text())
+		}
+	}
+	return key, entries
+}
+
+// isSignatureEntry reports whether the jar entry name is the manifest or a
+// signature, which are written again for the rebuilt jar.
+func isSignatureEntry(name string) bool {
+	if name == manifestName {
+		return true
+	}
+	if path.Dir(name) != "META-INF" {
+		return false
+	}
+	switch path.Ext(name) {
+	case ".SF", ".RSA", ".DSA", ".EC":
+		return true
+	}
+	return false
+}
+
+// extractForRebuild extracts the jar at jarPath to jarDir if it was built with
+// key, leaving out the entries compiled from the -s sources, the manifest and
+// the signatures. It reports whether the jar was extracted.
+func extractForRebuild(jarPath, jarDir, key string) (bool, error) {
+	r, err := zip.OpenReader(jarPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	var skip map[string]bool
+	for _, f := range r.File {
+		if f.Name != rebuildInfoPath {
+			continue
+		}
+		d, err := readZipFile(f)
+		if err != nil {
+			return false, err
+		}
+		oldKey, entries := parseRebuildInfo(d)
+		if oldKey != key {
+			return false, nil
+		}
+		skip = map[string]bool{rebuildInfoPath: true}
+		for _, e := range entries {
+			skip[e] = true
+		}
+	}
+	if skip == nil {
+		return false, nil
+	}
+	for _, f := range r.File {
+		if skip[f.Name] || isSignatureEntry(f.Name) || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		p := filepath.Join(jarDir, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(p, filepath.Clean(jarDir)+string(filepath.Separator)) {
+			return false, fmt.Errorf("%s: invalid entry %s", jarPath, f.Name)
+		}
+		d, err := readZipFile(f)
+		if err != nil {
+			return false, err
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return false, err
+		}
+		if err := ioutil.WriteFile(p, d, 0600); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// rebuildJava rebuilds the jar of cfg by recompiling only its -s sources, if
+// the jar was built with the same key. The Go code is not bound or built
+// again. It reports whether the jar was rebuilt.
+func rebuildJava(cfg *config, tmpDir, key string) (bool, error) {
+	jarDir := filepath.Join(tmpDir, "classes")
+	ok, err := extractForRebuild(targetPath(cfg), jarDir, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	sources, err := javaSources(cfg.sourceDir)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range sources {
+		rel, err := filepath.Rel(cfg.sourceDir, f)
+		if err != nil {
+			return false, err
+		}
+		class := filepath.Join(jarDir, javaRootDir(javaRoot(cfg)), strings.TrimSuffix(rel, ".java")+".class")
+		if _, err := os.Stat(class); err == nil {
+			// The source has the path of a generated one, which the full
+			// build handles as selected by -s-collisions.
+			return false, os.RemoveAll(jarDir)
+		}
+	}
+	verbosef("The Go packages are unchanged, only compiling the Java sources in %s\n", cfg.sourceDir)
+	javaDir := filepath.Join(tmpDir, "src", "go")
+	files, err := addExtraFiles(javaDir, cfg.sourceDir, cfg.sCollisions)
+	if err != nil {
+		return false, err
+	}
+	jvmFiles, err := addJVMSources(javaDir, cfg.sourceDir)
+	if err != nil {
+		return false, err
+	}
+	if err := renameJavaRoot(javaDir, javaRoot(cfg)); err != nil {
+		return false, err
+	}
+	c, err := newJavaCompiler(cfg)
+	if err != nil {
+		return false, err
+	}
+	// With no sources left, the classes compiled from deleted ones were left
+	// out of jarDir already.
+	if len(files) > 0 {
+		err = withTimeout("javac", cfg.javacTimeout, func() error {
+			return c.compile(jarDir, filepath.Join(javaDir, ".."), compileClassPath(cfg, jarDir), files)
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := compileJVMSources(cfg, jarDir, jvmFiles); err != nil {
+		return false, err
+	}
+	entries, err := sourceDirClasses(jarDir, javaRoot(cfg), cfg.sourceDir)
+	if err != nil {
+		return false, err
+	}
+	if err := writeRebuildInfo(jarDir, key, entries); err != nil {
+		return false, err
+	}
+	return true, writeJars(cfg, []jarBuild{{cfg, jarDir}})
+}
+
+// sourcesDigest writes to h the digests of the files the packages pkgs,
+// resolved in dir, and their non standard dependencies are built from.
+func sourcesDigest(h io.Writer, dir string, pkgs []string) error {
+	out, err := commandOutputIn(dir, "go", append([]string{"list", "-deps", "-json"}, pkgs...)...)
+	if err != nil {
+		return err
+	}
+	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
+		var p goListPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if p.Standard {
+			continue
+		}
+		var files []string
+		for _, fs := range [][]string{p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles, p.HFiles, p.SFiles, p.SysoFiles, p.EmbedFiles} {
+			for _, f := range fs {
+				files = append(files, filepath.Join(p.Dir, f))
+			}
+		}
+		if p.Module != nil && p.Module.GoMod != "" {
+			files = append(files, p.Module.GoMod)
+		}
+		for _, f := range files {
+			d, err := fileDigest(f)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %s\n", f, d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeSourceClass returns a class file for class compiled from source.
+func writeSourceClass(t *testing.T, class, source string) []byte {
+	var b bytes.Buffer
+	w := func(vs ...interface{}) {
+		for _, v := range vs {
+			if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	utf8 := func(s string) {
+		w(uint8(1), uint16(len(s)))
+		b.WriteString(s)
+	}
+	w(uint32(0xCAFEBABE), uint16(0), uint16(52), uint16(5))
+	utf8(class)
+	w(uint8(7), uint16(1))
+	utf8("SourceFile")
+	utf8(source)
+	w(uint16(0x21), uint16(2), uint16(0), uint16(0), uint16(0), uint16(0))
+	w(uint16(1), uint16(3), uint32(2), uint16(4))
+	return b.Bytes()
+}
+
+func TestSourceDirClasses(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	sourceDir, jarDir := filepath.Join(tmpDir, "src"), filepath.Join(tmpDir, "classes")
+	for path, src := range map[string][]byte{
+		filepath.Join(sourceDir, "testpkg", "Extra.java"):          []byte("package go.testpkg;\n"),
+		filepath.Join(jarDir, "go_v2", "testpkg", "Extra.class"):   writeSourceClass(t, "go_v2/testpkg/Extra", "Extra.java"),
+		filepath.Join(jarDir, "go_v2", "testpkg", "Extra$1.class"): writeSourceClass(t, "go_v2/testpkg/Extra$1", "Extra.java"),
+		filepath.Join(jarDir, "go_v2", "testpkg", "Helper.class"):  writeSourceClass(t, "go_v2/testpkg/Helper", "Extra.java"),
+		filepath.Join(jarDir, "go_v2", "testpkg", "Testpkg.class"): writeSourceClass(t, "go_v2/testpkg/Testpkg", "Testpkg.java"),
+		filepath.Join(jarDir, "go_v2", "Extra.class"):              writeSourceClass(t, "go_v2/Extra", "Extra.java"),
+	} {
+		if err := writeJavaFile(path, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := sourceDirClasses(jarDir, "go_v2", sourceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"go_v2/testpkg/Extra$1.class", "go_v2/testpkg/Extra.class", "go_v2/testpkg/Helper.class"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("got %v, want %v", entries, expected)
+	}
+}
+
+func TestExtractForRebuild(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	infoDir := filepath.Join(tmpDir, "info")
+	if err := writeRebuildInfo(infoDir, "abc", []string{"go/testpkg/Extra.class"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ioutil.ReadFile(filepath.Join(infoDir, filepath.FromSlash(rebuildInfoPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, entries := parseRebuildInfo(info); key != "abc" || !reflect.DeepEqual(entries, []string{"go/testpkg/Extra.class"}) {
+		t.Fatalf("got %s %v", key, entries)
+	}
+	jar := filepath.Join(tmpDir, "lib.jar")
+	f, err := os.Create(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range []string{manifestName, "META-INF/SIGNER.SF", "META-INF/SIGNER.RSA", rebuildInfoPath, "go/", "go/Seq.class", "go/libgojava", "go/testpkg/Extra.class", "go/testpkg/Testpkg.class"} {
+		fw, err := w.Create(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e == rebuildInfoPath {
+			fw.Write(info)
+		} else {
+			fw.Write([]byte(e))
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if ok, err := extractForRebuild(jar, filepath.Join(tmpDir, "other"), "def"); err != nil || ok {
+		t.Errorf("extracted a jar built with another key: %v, %v", ok, err)
+	}
+	if ok, err := extractForRebuild(filepath.Join(tmpDir, "missing.jar"), filepath.Join(tmpDir, "other"), "abc"); err != nil || ok {
+		t.Errorf("extracted a missing jar: %v, %v", ok, err)
+	}
+	jarDir := filepath.Join(tmpDir, "classes")
+	ok, err := extractForRebuild(jar, jarDir, "abc")
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	var got []string
+	filepath.Walk(jarDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(jarDir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	expected := []string{"go/Seq.class", "go/libgojava", "go/testpkg/Testpkg.class"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("extracted %v, want %v", got, expected)
+	}
+}
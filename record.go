@@ -0,0 +1,207 @@
+package main
+
+const goRecorderJava = `package go;
+
+import java.io.Closeable;
+import java.io.FileOutputStream;
+import java.io.Flushable;
+import java.io.IOException;
+import java.io.OutputStreamWriter;
+import java.io.Writer;
+import java.lang.reflect.Array;
+import java.nio.charset.StandardCharsets;
+import java.util.Arrays;
+import java.util.Base64;
+import java.util.HashSet;
+import java.util.Set;
+
+// GoRecorder is a GoInterceptor writing every bound call as a line of JSON,
+// with the method, the time it started at in Unix milliseconds, its
+// arguments, its result or the exception it threw, and its duration:
+//
+//	{"method":"go.geo.Geo.add","start":1700000000000,"args":[1,2],"result":3,"nanos":5120}
+//
+// Numbers, booleans and strings are written as JSON values, byte[] as
+// {"bytes":"<base64>"}, other arrays as JSON arrays, exceptions as
+// {"class":"<name>","message":"<message>"} and other objects, such as bound
+// Go values, as {"class":"<name>","value":"<toString>"}. The arguments are
+// written as passed, before the call. Register it with
+// Go.setInterceptor(new GoRecorder("calls.jsonl")) and close it when done.
+public final class GoRecorder implements GoResultInterceptor, Closeable, Flushable {
+	// RESULT is the index passed to Redactor.redact for the result of a call.
+	public static final int RESULT = -1;
+
+	// REDACTED is recorded instead of the values dropped by redactMethods.
+	public static final String REDACTED = "<redacted>";
+
+	// Redactor replaces sensitive values before they are recorded.
+	public interface Redactor {
+		// redact returns the value to record for the argument of method at
+		// index arg, or for its result if arg is RESULT.
+		Object redact(String method, int arg, Object value);
+	}
+
+	// redactMethods returns a Redactor recording REDACTED instead of the
+	// arguments and results of methods, given by their qualified Java names.
+	public static Redactor redactMethods(String... methods) {
+		final Set<String> redacted = new HashSet<String>(Arrays.asList(methods));
+		return new Redactor() {
+			@Override
+			public Object redact(String method, int arg, Object value) {
+				return redacted.contains(method) ? REDACTED : value;
+			}
+		};
+	}
+
+	private final Writer out;
+	private volatile Redactor redactor;
+	// failure is the first error writing to out, thrown by flush and close.
+	private IOException failure;
+
+	// GoRecorder appends the calls to the file at path.
+	public GoRecorder(String path) throws IOException {
+		this(new OutputStreamWriter(new FileOutputStream(path, true), StandardCharsets.UTF_8));
+	}
+
+	// GoRecorder writes the calls to out.
+	public GoRecorder(Writer out) {
+		this.out = out;
+	}
+
+	// setRedactor sets the Redactor applied to the recorded values, or removes
+	// it if r is null.
+	public void setRedactor(Redactor r) {
+		redactor = r;
+	}
+
+	private Object redact(String method, int arg, Object value) {
+		Redactor r = redactor;
+		return r == null ? value : r.redact(method, arg, value);
+	}
+
+	@Override
+	public Object before(String method, Object[] args) {
+		StringBuilder b = new StringBuilder("{\"method\":");
+		string(b, method);
+		b.append(",\"start\":").append(System.currentTimeMillis()).append(",\"args\":[");
+		for (int i = 0; i < args.length; i++) {
+			if (i > 0) {
+				b.append(',');
+			}
+			value(b, redact(method, i, args[i]));
+		}
+		return b.append(']');
+	}
+
+	@Override
+	public void result(String method, Object state, Object result) {
+		StringBuilder b = (StringBuilder) state;
+		b.append(",\"result\":");
+		value(b, redact(method, RESULT, result));
+	}
+
+	@Override
+	public void after(String method, Object state, long nanos, Throwable error) {
+		StringBuilder b = (StringBuilder) state;
+		if (error != null) {
+			b.append(",\"error\":");
+			error(b, error);
+		}
+		b.append(",\"nanos\":").append(nanos).append("}\n");
+		synchronized (this) {
+			if (failure != null) {
+				return;
+			}
+			try {
+				out.write(b.toString());
+			} catch (IOException e) {
+				failure = e;
+			}
+		}
+	}
+
+	// flush flushes the recorded calls, throwing the first error writing them.
+	@Override
+	public synchronized void flush() throws IOException {
+		if (failure != null) {
+			throw failure;
+		}
+		out.flush();
+	}
+
+	// close closes the underlying file or Writer, throwing the first error
+	// writing the recorded calls. Calls made after it are not recorded.
+	@Override
+	public synchronized void close() throws IOException {
+		IOException err = failure;
+		if (err == null) {
+			failure = new IOException("GoRecorder closed");
+		}
+		out.close();
+		if (err != null) {
+			throw err;
+		}
+	}
+
+	// error appends the JSON recording the exception e to b.
+	static void error(StringBuilder b, Throwable e) {
+		b.append("{\"class\":");
+		string(b, e.getClass().getName());
+		b.append(",\"message\":");
+		value(b, e.getMessage());
+		b.append('}');
+	}
+
+	// value appends the JSON recording v to b.
+	static void value(StringBuilder b, Object v) {
+		if (v == null) {
+			b.append("null");
+		} else if (v instanceof Boolean || v instanceof Long || v instanceof Integer || v instanceof Short || v instanceof Byte) {
+			b.append(v);
+		} else if (v instanceof Double || v instanceof Float) {
+			double d = ((Number) v).doubleValue();
+			if (Double.isNaN(d) || Double.isInfinite(d)) {
+				string(b, v.toString());
+			} else {
+				b.append(v);
+			}
+		} else if (v instanceof String || v instanceof Character) {
+			string(b, v.toString());
+		} else if (v instanceof byte[]) {
+			b.append("{\"bytes\":");
+			string(b, Base64.getEncoder().encodeToString((byte[]) v));
+			b.append('}');
+		} else if (v.getClass().isArray()) {
+			b.append('[');
+			for (int i = 0; i < Array.getLength(v); i++) {
+				if (i > 0) {
+					b.append(',');
+				}
+				value(b, Array.get(v, i));
+			}
+			b.append(']');
+		} else {
+			b.append("{\"class\":");
+			string(b, v.getClass().getName());
+			b.append(",\"value\":");
+			string(b, v.toString());
+			b.append('}');
+		}
+	}
+
+	private static void string(StringBuilder b, String s) {
+		b.append('"');
+		for (int i = 0; i < s.length(); i++) {
+			char c = s.charAt(i);
+			if (c == '"' || c == '\\') {
+				b.append('\\').append(c);
+			} else if (c < 0x20 || c == 0x2028 || c == 0x2029) {
+				b.append(String.format("\\u%04x", (int) c));
+			} else {
+				b.append(c);
+			}
+		}
+		b.append('"');
+	}
+}
+`
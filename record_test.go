@@ -0,0 +1,21 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGoRecorder(t *testing.T) {
+	methods := regexp.MustCompile(`(?m)^\t(?:Object|void) (\w+)\(`)
+	for _, src := range []string{goInterceptorJava, goResultInterceptorJava} {
+		for _, m := range methods.FindAllStringSubmatch(src, -1) {
+			if !strings.Contains(goRecorderJava, "@Override\n\tpublic "+strings.TrimPrefix(m[0], "\t")) {
+				t.Errorf("GoRecorder does not implement %s", m[1])
+			}
+		}
+	}
+	if !strings.Contains(goRecorderJava, "public final class GoRecorder implements GoResultInterceptor") {
+		t.Error("GoRecorder is not a GoResultInterceptor")
+	}
+}
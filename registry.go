@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// registryMethod is a bound function or method listed by go.GoPackages.
+type registryMethod struct {
+	goName, name string
+	static       bool
+	// class is the Java class declaring the method, and params, ret the Java
+	// types of its parameters and result, "void" for none.
+	class, ret string
+	params     []string
+	throws     bool
+}
+
+// registryType is a bound struct or interface listed by go.GoPackages.
+type registryType struct {
+	goName, class string
+	methods       []registryMethod
+}
+
+// registryPackage is a bound package listed by go.GoPackages.
+type registryPackage struct {
+	path, name, class string
+	funcs             []registryMethod
+	types             []registryType
+}
+
+// registryJavaType returns the Java type of t in the methods listed by
+// go.GoPackages, and whether the registry supports it: the basic types, byte
+// slices, and pointers to the structs and the interfaces of the bound packages.
+func registryJavaType(t types.Type, bound map[*types.Package]bool, split bool) (string, bool) {
+	switch t := t.(type) {
+	case *types.Pointer:
+		n, ok := t.Elem().(*types.Named)
+		if !ok || !bound[n.Obj().Pkg()] {
+			return "", false
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok {
+			return "", false
+		}
+		return strings.Replace(javaBinaryName(n.Obj().Pkg(), n.Obj().Name(), split), "$", ".", -1), true
+	case *types.Named:
+		switch t.Underlying().(type) {
+		case *types.Interface:
+			if !bound[t.Obj().Pkg()] {
+				return "", false
+			}
+			return strings.Replace(javaBinaryName(t.Obj().Pkg(), t.Obj().Name(), split), "$", ".", -1), true
+		case *types.Basic:
+		default:
+			return "", false
+		}
+	}
+	jt, err := javaType(t)
+	return jt, err == nil
+}
+
+// registryMethodOf returns the registry entry of fn, bound as the method name
+// of class, or false if its signature is not supported.
+func registryMethodOf(fn *types.Func, goName, class, name string, bound map[*types.Package]bool, split bool) (registryMethod, bool) {
+	sig := fn.Type().(*types.Signature)
+	m := registryMethod{goName: goName, name: name, static: sig.Recv() == nil, class: class, ret: "void"}
+	if sig.Variadic() {
+		return m, false
+	}
+	for _, v := range tupleVars(sig.Params()) {
+		jt, ok := registryJavaType(v.Type(), bound, split)
+		if !ok {
+			return m, false
+		}
+		m.params = append(m.params, jt)
+	}
+	res := tupleVars(sig.Results())
+	if len(res) > 0 && isError(res[len(res)-1].Type()) {
+		m.throws = true
+		res = res[:len(res)-1]
+	}
+	switch len(res) {
+	case 0:
+	case 1:
+		jt, ok := registryJavaType(res[0].Type(), bound, split)
+		if !ok {
+			return m, false
+		}
+		m.ret = jt
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// registryTypePattern returns the pattern matching the Java type t, qualified
+// or not, in a generated declaration.
+func registryTypePattern(t string) string {
+	if strings.HasSuffix(t, "[]") {
+		return regexp.QuoteMeta(strings.TrimSuffix(t, "[]")) + `\s*\[\]`
+	}
+	return `(?:[\p{L}\p{N}_$]+\.)*` + regexp.QuoteMeta(t[strings.LastIndex(t, ".")+1:])
+}
+
+// declared reports whether the Java sources src declare m, to leave out the
+// members gobind skipped.
+func (m registryMethod) declared(src []byte) bool {
+	params := make([]string, len(m.params))
+	for i, p := range m.params {
+		params[i] = registryTypePattern(p) + `\s+[\p{L}\p{N}_$]+`
+	}
+	decl := `\b` + registryTypePattern(m.ret) + `\s+` + regexp.QuoteMeta(m.name) + `\(\s*` + strings.Join(params, `\s*,\s*`) + `\s*\)`
+	return regexp.MustCompile(decl).Match(src)
+}
+
+// packageSources returns the Java sources in javaDir by Java package.
+func packageSources(javaDir string) (map[string][]byte, error) {
+	sources := make(map[string][]byte)
+	err := filepath.Walk(javaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".java" {
+			return err
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if m := javaPackageDecl.FindSubmatch(src); m != nil {
+			sources[string(m[1])] = append(sources[string(m[1])], src...)
+		}
+		return nil
+	})
+	return sources, err
+}
+
+// findRegistry returns the registry entries of pkgs, with the functions,
+// types and methods declared in the generated Java sources in javaDir. With
+// gojava expose, only the exposed functions of each package are listed.
+func findRegistry(javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, split bool) ([]registryPackage, error) {
+	sources, err := packageSources(javaDir)
+	if err != nil {
+		return nil, err
+	}
+	bound := make(map[*types.Package]bool)
+	for _, p := range pkgs {
+		bound[p] = true
+	}
+	var reg []registryPackage
+	for _, p := range pkgs {
+		src := sources[javaPkgName(p)]
+		rp := registryPackage{path: p.Path(), name: p.Name(), class: javaPkgName(p) + "." + javaClassName(p)}
+		typeIndex := make(map[string]int)
+		forEachMember(p, split, func(class, member string, method bool, obj types.Object) {
+			class = strings.Replace(class, "$", ".", -1)
+			switch obj := obj.(type) {
+			case *types.TypeName:
+				if exposed != nil {
+					return
+				}
+				switch obj.Type().Underlying().(type) {
+				case *types.Struct, *types.Interface:
+					typeIndex[class] = len(rp.types)
+					rp.types = append(rp.types, registryType{goName: obj.Name(), class: class})
+				}
+			case *types.Func:
+				recv := obj.Type().(*types.Signature).Recv()
+				goName := obj.Name()
+				if recv == nil {
+					if exposed != nil && !containsString(exposed[p], goName) {
+						return
+					}
+				} else {
+					i, ok := typeIndex[class]
+					if !ok {
+						return
+					}
+					goName = rp.types[i].goName + "." + goName
+				}
+				m, ok := registryMethodOf(obj, goName, class, member, bound, split)
+				if !ok || !m.declared(src) {
+					verbosef("Leaving %s.%s out of the registry\n", p.Path(), goName)
+					return
+				}
+				if recv == nil {
+					rp.funcs = append(rp.funcs, m)
+				} else {
+					i := typeIndex[class]
+					rp.types[i].methods = append(rp.types[i].methods, m)
+				}
+			}
+		})
+		reg = append(reg, rp)
+	}
+	return reg, nil
+}
+
+// registryArg returns the expression converting the argument i of an invoker
+// to the Java type t: numbers are converted from any Number.
+func registryArg(t string, i int) string {
+	switch t {
+	case "long", "int", "short", "byte", "double", "float":
+		return fmt.Sprintf("((Number) a[%d]).%sValue()", i, t)
+	case "boolean":
+		return fmt.Sprintf("(Boolean) a[%d]", i)
+	}
+	return fmt.Sprintf("(%s) a[%d]", t, i)
+}
+
+// registryJavaMethod returns the expression creating the GoPackages.Method
+// of m.
+func registryJavaMethod(m registryMethod) string {
+	classes := make([]string, len(m.params))
+	args := make([]string, len(m.params))
+	for i, p := range m.params {
+		classes[i] = p + ".class"
+		args[i] = registryArg(p, i)
+	}
+	call := fmt.Sprintf("%s.%s(%s)", m.class, m.name, strings.Join(args, ", "))
+	if !m.static {
+		call = fmt.Sprintf("((%s) r).%s(%s)", m.class, m.name, strings.Join(args, ", "))
+	}
+	body := "return " + call + ";"
+	if m.ret == "void" {
+		body = call + ";\n\t\t\t\t\treturn null;"
+	}
+	return fmt.Sprintf(`new Method(%q, %q, %v, new Class<?>[] {%s}, %s.class, %v, new Invoker() {
+				public Object invoke(Object r, Object[] a) throws Exception {
+					%s
+				}
+			})`, m.goName, m.name, m.static, strings.Join(classes, ", "), m.ret, m.throws, body)
+}
+
+// registryMethods returns the expression of the list of methods ms.
+func registryMethods(ms []registryMethod) string {
+	if len(ms) == 0 {
+		return "Collections.<Method>emptyList()"
+	}
+	exprs := make([]string, len(ms))
+	for i, m := range ms {
+		exprs[i] = "\n\t\t\t" + registryJavaMethod(m)
+	}
+	return "Arrays.<Method>asList(" + strings.Join(exprs, ",") + ")"
+}
+
+// genRegistry writes go.GoPackages, describing the functions, types and
+// methods of pkgs and calling them without reflection, and returns its path.
+// Each package and type is described by its own method, keeping them under
+// the size limit of the JVM.
+func genRegistry(javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, split bool) ([]string, error) {
+	reg, err := findRegistry(javaDir, pkgs, exposed, split)
+	if err != nil {
+		return nil, err
+	}
+	var calls, methods bytes.Buffer
+	for i, p := range reg {
+		fmt.Fprintf(&calls, "\t\tl.add(package%d());\n", i)
+		var types []string
+		for j, t := range p.types {
+			types = append(types, fmt.Sprintf("type%d_%d()", i, j))
+			fmt.Fprintf(&methods, "\n\tprivate static Type type%d_%d() {\n\t\treturn new Type(%q, %s.class, %s);\n\t}\n", i, j, t.goName, t.class, registryMethods(t.methods))
+		}
+		typeList := "Collections.<Type>emptyList()"
+		if len(types) > 0 {
+			typeList = "Arrays.<Type>asList(" + strings.Join(types, ", ") + ")"
+		}
+		fmt.Fprintf(&methods, "\n\tprivate static Package package%d() {\n\t\treturn new Package(%q, %q, %s.class, %s,\n\t\t\t%s);\n\t}\n", i, p.path, p.name, p.class, registryMethods(p.funcs), typeList)
+	}
+	path := filepath.Join(javaDir, "GoPackages.java")
+	src := fmt.Sprintf(goPackagesJava, calls.String(), methods.String())
+	if err := writeJavaFile(path, []byte(src)); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+const goPackagesJava = `// Code generated by gojava. DO NOT EDIT.
+
+package go;
+
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Collections;
+import java.util.List;
+
+// GoPackages describes the bound Go packages, with their functions, types and
+// methods, and calls them without reflection, for the frameworks binding to
+// the Go API at runtime.
+public final class GoPackages {
+	private GoPackages() {}
+
+	// Invoker calls a function, with a null receiver, or a method.
+	public interface Invoker {
+		Object invoke(Object receiver, Object[] args) throws Exception;
+	}
+
+	// Method describes a bound function or method. Its name is the name of
+	// the Java method, and goName the name of the Go function, or of the Go
+	// type and method. A method returning a Go error throws it.
+	public static final class Method {
+		public final String goName;
+		public final String name;
+		public final boolean isStatic;
+		public final List<Class<?>> parameterTypes;
+		public final Class<?> returnType;
+		public final boolean throwsError;
+		private final Invoker invoker;
+
+		Method(String goName, String name, boolean isStatic, Class<?>[] parameterTypes, Class<?> returnType, boolean throwsError, Invoker invoker) {
+			this.goName = goName;
+			this.name = name;
+			this.isStatic = isStatic;
+			this.parameterTypes = Collections.unmodifiableList(Arrays.asList(parameterTypes));
+			this.returnType = returnType;
+			this.throwsError = throwsError;
+			this.invoker = invoker;
+		}
+
+		// invoke calls the method on receiver, ignored for functions, with
+		// args. Numeric arguments may be any Number, and are converted to the
+		// parameter types. The result is null for void methods.
+		public Object invoke(Object receiver, Object... args) throws Exception {
+			if (args.length != parameterTypes.size()) {
+				throw new IllegalArgumentException(goName + ": got " + args.length + " arguments, want " + parameterTypes.size());
+			}
+			if (!isStatic && receiver == null) {
+				throw new NullPointerException(goName + ": null receiver");
+			}
+			return invoker.invoke(receiver, args);
+		}
+	}
+
+	// Type describes a bound Go struct or interface.
+	public static final class Type {
+		public final String goName;
+		public final Class<?> javaClass;
+		public final List<Method> methods;
+
+		Type(String goName, Class<?> javaClass, List<Method> methods) {
+			this.goName = goName;
+			this.javaClass = javaClass;
+			this.methods = Collections.unmodifiableList(methods);
+		}
+
+		// method returns the first method with the Java name, or null.
+		public Method method(String name) {
+			return find(methods, name);
+		}
+	}
+
+	// Package describes a bound Go package.
+	public static final class Package {
+		public final String path;
+		public final String name;
+		public final Class<?> javaClass;
+		public final List<Method> functions;
+		public final List<Type> types;
+
+		Package(String path, String name, Class<?> javaClass, List<Method> functions, List<Type> types) {
+			this.path = path;
+			this.name = name;
+			this.javaClass = javaClass;
+			this.functions = Collections.unmodifiableList(functions);
+			this.types = Collections.unmodifiableList(types);
+		}
+
+		// function returns the first function with the Java name, or null.
+		public Method function(String name) {
+			return find(functions, name);
+		}
+
+		// type returns the type with the Go name, or null.
+		public Type type(String goName) {
+			for (Type t : types) {
+				if (t.goName.equals(goName)) {
+					return t;
+				}
+			}
+			return null;
+		}
+	}
+
+	private static final List<Package> PACKAGES;
+
+	static {
+		List<Package> l = new ArrayList<Package>();
+%s		PACKAGES = Collections.unmodifiableList(l);
+	}
+
+	// list returns the bound packages. Listing them does not load the native
+	// library, which is loaded by the first call.
+	public static List<Package> list() {
+		return PACKAGES;
+	}
+
+	// get returns the bound package with the Go import path, or null.
+	public static Package get(String path) {
+		for (Package p : PACKAGES) {
+			if (p.path.equals(path)) {
+				return p;
+			}
+		}
+		return null;
+	}
+
+	private static Method find(List<Method> methods, String name) {
+		for (Method m : methods) {
+			if (m.name.equals(name)) {
+				return m;
+			}
+		}
+		return null;
+	}
+%s}
+`
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const registrySrc = `package testpkg
+
+type Point struct{ X int64 }
+
+func (p *Point) Scale(f float64) *Point { return p }
+func (p *Point) Name() (string, error) { return "", nil }
+func (p *Point) Skipped(m map[string]int) {}
+
+type Shape interface {
+	Area() float64
+}
+
+func Add(a, b int64) int64 { return a + b }
+func Log(s string, args ...interface{}) {}
+func Reset() {}
+func Hidden(b bool) {}
+`
+
+const registryJava = `package go.testpkg;
+
+public abstract class Testpkg {
+	public static final class Point {
+		public native Point scale(double f);
+		public native String name() throws Exception;
+	}
+
+	public interface Shape {
+		public double area();
+	}
+
+	public static native long add(long a, long b);
+	public static native void reset();
+}
+`
+
+func TestGenRegistry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	p := typeCheck(t, registrySrc)
+	if err := writeJavaFile(filepath.Join(tmpDir, "Testpkg.java"), []byte(registryJava)); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := findRegistry(tmpDir, []*types.Package{p}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg) != 1 || reg[0].class != "go.testpkg.Testpkg" {
+		t.Fatalf("got %+v", reg)
+	}
+	var funcs []string
+	for _, f := range reg[0].funcs {
+		funcs = append(funcs, f.goName)
+	}
+	if got := strings.Join(funcs, ","); got != "Add,Reset" {
+		t.Errorf("got functions %s, want Add,Reset", got)
+	}
+	if len(reg[0].types) != 2 || reg[0].types[0].class != "go.testpkg.Testpkg.Point" || reg[0].types[1].goName != "Shape" {
+		t.Fatalf("got types %+v", reg[0].types)
+	}
+	point := reg[0].types[0]
+	if len(point.methods) != 2 || point.methods[0].goName != "Point.Name" || !point.methods[0].throws || point.methods[1].ret != "go.testpkg.Testpkg.Point" {
+		t.Errorf("got Point methods %+v", point.methods)
+	}
+
+	files, err := genRegistry(tmpDir, []*types.Package{p}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\tl.add(package0());\n",
+		`return new Package("example.com/testpkg", "testpkg", go.testpkg.Testpkg.class,`,
+		`new Method("Add", "add", true, new Class<?>[] {long.class, long.class}, long.class, false, new Invoker() {`,
+		"return go.testpkg.Testpkg.add(((Number) a[0]).longValue(), ((Number) a[1]).longValue());",
+		"go.testpkg.Testpkg.reset();\n\t\t\t\t\treturn null;",
+		"return ((go.testpkg.Testpkg.Point) r).scale(((Number) a[0]).doubleValue());",
+		`return new Type("Shape", go.testpkg.Testpkg.Shape.class, Arrays.<Method>asList(`,
+		"Arrays.<Type>asList(type0_0(), type0_1())",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("GoPackages.java does not contain %q", want)
+		}
+	}
+
+	reg, err = findRegistry(tmpDir, []*types.Package{p}, map[*types.Package][]string{p: {"Reset"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg[0].funcs) != 1 || reg[0].funcs[0].name != "reset" || len(reg[0].types) != 0 {
+		t.Errorf("exposed: got %+v", reg[0])
+	}
+}
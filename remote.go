@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// remoteModulePath is the module path of the temporary module remote
+// packages are bound from.
+const remoteModulePath = "gojava_remote"
+
+// isRemote reports whether the package argument arg names a package at a
+// module version, like example.com/mod@v1.4.2/pkg.
+func isRemote(arg string) bool {
+	return strings.Contains(arg, "@")
+}
+
+// splitRemote splits the remote package argument arg, of the form
+// module@version or module@version/path, into the package path or pattern and
+// the query go get resolves it with.
+func splitRemote(arg string) (pkg, query string, err error) {
+	i := strings.Index(arg, "@")
+	mod, version, sub := arg[:i], arg[i+1:], ""
+	if j := strings.Index(version, "/"); j >= 0 {
+		version, sub = version[:j], version[j:]
+	}
+	if mod == "" || version == "" {
+		return "", "", fmt.Errorf("%s: expected module@version or module@version/path", arg)
+	}
+	pkg = mod + sub
+	return pkg, pkg + "@" + version, nil
+}
+
+// bindRemote binds the remote package arguments args. The requested module
+// versions are downloaded into a temporary module, which the packages are then
+// resolved and built in.
+func bindRemote(cfg *config, args []string) error {
+	var pkgs, queries []string
+	for _, arg := range args {
+		if !isRemote(arg) {
+			return fmt.Errorf("%s: packages at module versions cannot be bound with local packages", arg)
+		}
+		pkg, query, err := splitRemote(arg)
+		if err != nil {
+			return err
+		}
+		pkgs, queries = append(pkgs, pkg), append(queries, query)
+	}
+	modDir, err := ioutil.TempDir("", "gojava-remote")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(modDir); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to remove temp dir:", modDir, err)
+		}
+	}()
+	verbosef("Downloading %s\n", strings.Join(queries, " "))
+	if err := runCommandIn(modDir, "go", "mod", "init", remoteModulePath); err != nil {
+		return err
+	}
+	if err := runCommandIn(modDir, "go", append([]string{"get"}, queries...)...); err != nil {
+		return err
+	}
+	if pkgs, err = expandPackages(modDir, pkgs); err != nil {
+		return err
+	}
+	return bindToJar(cfg, modDir, pkgs...)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitRemote(t *testing.T) {
+	for _, tc := range []struct {
+		arg, pkg, query string
+	}{
+		{"example.com/mod@v1.4.2", "example.com/mod", "example.com/mod@v1.4.2"},
+		{"example.com/mod@v1.4.2/pkg", "example.com/mod/pkg", "example.com/mod/pkg@v1.4.2"},
+		{"example.com/mod@latest/...", "example.com/mod/...", "example.com/mod/...@latest"},
+		{"@v1.0.0/pkg", "", ""},
+		{"example.com/mod@/pkg", "", ""},
+	} {
+		pkg, query, err := splitRemote(tc.arg)
+		if tc.pkg == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.arg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.arg, err)
+			continue
+		}
+		if pkg != tc.pkg || query != tc.query {
+			t.Errorf("%s: expected %s %s, got %s %s", tc.arg, tc.pkg, tc.query, pkg, query)
+		}
+	}
+}
+
+func TestPackagesInDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, "lib"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":     "module example.com/remote\n\ngo 1.16\n",
+		"lib/lib.go": "package lib\n\nfunc F() {}\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The packages and the module are resolved in the directory given, not
+	// the current one, which is not changed.
+	pkgs, err := expandPackages(tmpDir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0] != "example.com/remote/lib" {
+		t.Errorf("expected example.com/remote/lib, got %v", pkgs)
+	}
+	if p, err := canonicalImportPath(pkgs[0], tmpDir); err != nil || p != pkgs[0] {
+		t.Errorf("canonicalImportPath(%s) = %s, %v", pkgs[0], p, err)
+	}
+	mod, err := findModule(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, _ := filepath.EvalSymlinks(tmpDir); mod == nil || mod.path != "example.com/remote" || mod.dir != dir && mod.dir != tmpDir {
+		t.Errorf("unexpected module %+v", mod)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// replayArgs returns the arguments of java replaying trace against the jar,
+// with the extra class path cp, if set.
+func replayArgs(jar, cp, trace string) []string {
+	classPath := jar
+	if cp != "" {
+		classPath += string(filepath.ListSeparator) + cp
+	}
+	return []string{"-cp", classPath, "go.GoReplay", trace}
+}
+
+// runReplay runs gojava replay with args, replaying a trace recorded by
+// go.GoRecorder against a jar built with -record.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	cp := fs.String("cp", "", "Class path to add to the jar, for the dependencies of its Java sources.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: gojava replay [-cp <classpath>] <jar> <trace>")
+	}
+	c := exec.Command(javaTool("java", ""), replayArgs(fs.Arg(0), *cp, fs.Arg(1))...)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("replaying %s: %v", fs.Arg(1), err)
+	}
+	return nil
+}
+
+const goReplayJava = `package go;
+
+import java.io.BufferedReader;
+import java.io.FileInputStream;
+import java.io.IOException;
+import java.io.InputStreamReader;
+import java.io.Reader;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+
+// GoReplay calls the bound functions again with the arguments recorded by
+// GoRecorder, in the order they were recorded, and reports the calls whose
+// result or error differs from the recorded one, to catch changes of
+// behavior between builds of the bindings. Run it on a trace with
+//
+//	java -cp app.jar go.GoReplay calls.jsonl
+//
+// which exits with status 1 if a call does not match. Calls of the methods of
+// Go values, and calls with arguments that are Go values, arrays or redacted,
+// cannot be replayed and are skipped. Go values returned are compared by
+// class only.
+public final class GoReplay {
+	private GoReplay() {}
+
+	// Mismatch is a replayed call whose outcome differs from the recording.
+	public static final class Mismatch {
+		// line is the line of the call in the trace.
+		public final int line;
+		public final String method;
+		// expected and actual are the recorded and replayed outcomes, as
+		// "result <json>" or "error <json>".
+		public final String expected, actual;
+
+		Mismatch(int line, String method, String expected, String actual) {
+			this.line = line;
+			this.method = method;
+			this.expected = expected;
+			this.actual = actual;
+		}
+
+		public String toString() {
+			return "line " + line + ": " + method + ": recorded " + expected + ", got " + actual;
+		}
+	}
+
+	// Result summarizes a replay.
+	public static final class Result {
+		public int calls, replayed, skipped;
+		public final List<Mismatch> mismatches = new ArrayList<Mismatch>();
+	}
+
+	// SKIP is returned by arg for arguments that cannot be replayed.
+	private static final Object SKIP = new Object();
+
+	// replay replays the calls of the trace.
+	public static Result replay(Reader trace) throws IOException {
+		BufferedReader r = new BufferedReader(trace);
+		Result res = new Result();
+		int n = 0;
+		for (String line; (line = r.readLine()) != null;) {
+			n++;
+			if (line.trim().isEmpty()) {
+				continue;
+			}
+			Map<String, String> raw = new HashMap<String, String>();
+			Map<String, Object> call = new Parser(line, n).object(raw);
+			res.calls++;
+			String method = (String) call.get("method");
+			List<?> recorded = (List<?>) call.get("args");
+			if (method == null || recorded == null) {
+				throw new IOException("line " + n + ": not a recorded call");
+			}
+			GoPackages.Package p = owner(method);
+			if (p == null || GoRecorder.REDACTED.equals(call.get("result"))) {
+				res.skipped++;
+				continue;
+			}
+			String expected = raw.containsKey("error") ? "error " + raw.get("error") : "result " + raw.get("result");
+			GoPackages.Method m = function(p, method.substring(method.lastIndexOf('.') + 1), recorded.size());
+			if (m == null) {
+				res.replayed++;
+				res.mismatches.add(new Mismatch(n, method, expected, "no bound function"));
+				continue;
+			}
+			Object[] args = new Object[recorded.size()];
+			for (int i = 0; args != null && i < recorded.size(); i++) {
+				if ((args[i] = arg(recorded.get(i), m.parameterTypes.get(i))) == SKIP) {
+					args = null;
+				}
+			}
+			if (args == null) {
+				res.skipped++;
+				continue;
+			}
+			res.replayed++;
+			StringBuilder actual = new StringBuilder();
+			try {
+				Object result = m.invoke(null, args);
+				actual.append("result ");
+				if (result != null && call.get("result") instanceof Map) {
+					// Go values are recorded with their toString, which
+					// may differ between runs.
+					Object cls = ((Map<?, ?>) call.get("result")).get("class");
+					expected = "result of class " + cls;
+					actual.append("of class ").append(result.getClass().getName());
+				} else {
+					GoRecorder.value(actual, result);
+				}
+			} catch (Exception e) {
+				actual.append("error ");
+				GoRecorder.error(actual, e);
+			}
+			if (!expected.equals(actual.toString())) {
+				res.mismatches.add(new Mismatch(n, method, expected, actual.toString()));
+			}
+		}
+		return res;
+	}
+
+	// owner returns the bound package with the functions of method, or null
+	// if it is not a package function.
+	private static GoPackages.Package owner(String method) {
+		String cls = method.substring(0, Math.max(method.lastIndexOf('.'), 0));
+		for (GoPackages.Package p : GoPackages.list()) {
+			if (p.javaClass.getName().equals(cls)) {
+				return p;
+			}
+		}
+		return null;
+	}
+
+	// function returns the function of p with the Java name and arity, or null.
+	private static GoPackages.Method function(GoPackages.Package p, String name, int arity) {
+		for (GoPackages.Method m : p.functions) {
+			if (m.name.equals(name) && m.parameterTypes.size() == arity) {
+				return m;
+			}
+		}
+		return null;
+	}
+
+	// arg returns the argument of type t recorded as v, or SKIP.
+	private static Object arg(Object v, Class<?> t) {
+		if (GoRecorder.REDACTED.equals(v) || v instanceof List) {
+			return SKIP;
+		}
+		if (v instanceof Map) {
+			Object b = ((Map<?, ?>) v).get("bytes");
+			if (((Map<?, ?>) v).size() == 1 && b instanceof String) {
+				return Base64.getDecoder().decode((String) b);
+			}
+			return SKIP;
+		}
+		if (v instanceof String && (t == double.class || t == float.class)) {
+			// NaN and infinities are recorded as strings.
+			return Double.valueOf((String) v);
+		}
+		return v;
+	}
+
+	// Parser parses the JSON written by GoRecorder.
+	private static final class Parser {
+		private final String s;
+		private final int line;
+		private int i;
+
+		Parser(String s, int line) {
+			this.s = s;
+			this.line = line;
+		}
+
+		private IOException error(String msg) {
+			return new IOException("line " + line + ", column " + (i + 1) + ": " + msg);
+		}
+
+		private void space() {
+			while (i < s.length() && Character.isWhitespace(s.charAt(i))) {
+				i++;
+			}
+		}
+
+		private boolean next(char c) {
+			space();
+			if (i < s.length() && s.charAt(i) == c) {
+				i++;
+				return true;
+			}
+			return false;
+		}
+
+		private void expect(char c) throws IOException {
+			if (!next(c)) {
+				throw error("expected " + c);
+			}
+		}
+
+		Object value() throws IOException {
+			space();
+			if (i >= s.length()) {
+				throw error("unexpected end of line");
+			}
+			switch (s.charAt(i)) {
+			case '{':
+				return object(null);
+			case '[':
+				List<Object> l = new ArrayList<Object>();
+				expect('[');
+				if (next(']')) {
+					return l;
+				}
+				do {
+					l.add(value());
+				} while (next(','));
+				expect(']');
+				return l;
+			case '"':
+				return string();
+			case 't':
+				return literal("true", Boolean.TRUE);
+			case 'f':
+				return literal("false", Boolean.FALSE);
+			case 'n':
+				return literal("null", null);
+			}
+			int start = i;
+			while (i < s.length() && "+-.0123456789eE".indexOf(s.charAt(i)) >= 0) {
+				i++;
+			}
+			String n = s.substring(start, i);
+			try {
+				if (n.indexOf('.') >= 0 || n.indexOf('e') >= 0 || n.indexOf('E') >= 0) {
+					return Double.valueOf(n);
+				}
+				return Long.valueOf(n);
+			} catch (NumberFormatException e) {
+				throw error("invalid value");
+			}
+		}
+
+		// object parses an object, putting the JSON of its members in raw if
+		// it is not null.
+		Map<String, Object> object(Map<String, String> raw) throws IOException {
+			Map<String, Object> m = new LinkedHashMap<String, Object>();
+			expect('{');
+			if (next('}')) {
+				return m;
+			}
+			do {
+				space();
+				String key = string();
+				expect(':');
+				space();
+				int start = i;
+				m.put(key, value());
+				if (raw != null) {
+					raw.put(key, s.substring(start, i));
+				}
+			} while (next(','));
+			expect('}');
+			return m;
+		}
+
+		private Object literal(String lit, Object v) throws IOException {
+			if (!s.startsWith(lit, i)) {
+				throw error("invalid value");
+			}
+			i += lit.length();
+			return v;
+		}
+
+		private String string() throws IOException {
+			expect('"');
+			StringBuilder b = new StringBuilder();
+			while (i < s.length()) {
+				char c = s.charAt(i++);
+				if (c == '"') {
+					return b.toString();
+				}
+				if (c != '\\') {
+					b.append(c);
+					continue;
+				}
+				if (i >= s.length()) {
+					break;
+				}
+				switch (c = s.charAt(i++)) {
+				case 'b':
+					b.append('\b');
+					break;
+				case 'f':
+					b.append('\f');
+					break;
+				case 'n':
+					b.append('\n');
+					break;
+				case 'r':
+					b.append('\r');
+					break;
+				case 't':
+					b.append('\t');
+					break;
+				case 'u':
+					if (i + 4 > s.length()) {
+						throw error("invalid escape");
+					}
+					try {
+						b.append((char) Integer.parseInt(s.substring(i, i + 4), 16));
+					} catch (NumberFormatException e) {
+						throw error("invalid escape");
+					}
+					i += 4;
+					break;
+				default:
+					b.append(c);
+				}
+			}
+			throw error("unterminated string");
+		}
+	}
+
+	// main replays the trace at args[0], printing the calls that do not
+	// match, and exits with status 1 if there are any.
+	public static void main(String[] args) throws IOException {
+		if (args.length != 1) {
+			System.err.println("usage: java go.GoReplay <trace>");
+			System.exit(2);
+		}
+		Result r;
+		try (Reader in = new InputStreamReader(new FileInputStream(args[0]), StandardCharsets.UTF_8)) {
+			r = replay(in);
+		}
+		for (Mismatch m : r.mismatches) {
+			System.out.println(m);
+		}
+		System.out.printf("%d calls: %d replayed, %d skipped, %d mismatched%n", r.calls, r.replayed, r.skipped, r.mismatches.size());
+		if (!r.mismatches.isEmpty()) {
+			System.exit(1);
+		}
+	}
+}
+`
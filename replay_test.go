@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplayArgs(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	for _, c := range []struct {
+		cp   string
+		want []string
+	}{
+		{"", []string{"-cp", "lib.jar", "go.GoReplay", "calls.jsonl"}},
+		{"a.jar" + sep + "b.jar", []string{"-cp", "lib.jar" + sep + "a.jar" + sep + "b.jar", "go.GoReplay", "calls.jsonl"}},
+	} {
+		if got := replayArgs("lib.jar", c.cp, "calls.jsonl"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("cp %q: got %v, want %v", c.cp, got, c.want)
+		}
+	}
+	if err := runReplay([]string{"lib.jar"}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
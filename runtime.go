@@ -0,0 +1,731 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// writeRuntime writes the Java runtime support classes that are generated
+// rather than copied to javaDir, returning their paths. nativeDigest is the
+// hex SHA-256 digest of the native library checked before it is loaded, or ""
+// to not check it, and abi the ABI version the library is built for.
+func writeRuntime(cfg *config, javaDir, nativeDigest, abi string) ([]string, error) {
+	path := filepath.Join(javaDir, "Go.java")
+	if err := writeJavaFile(path, []byte(fmt.Sprintf(goJava, cfg.lazy, cfg.intercept, cfg.outOfProcess, nativeDigest, abi))); err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	var classes []struct{ name, src string }
+	if cfg.sharedRuntime == "" {
+		classes = append(classes, sharedRuntimeClasses...)
+	}
+	classes = append(classes, []struct{ name, src string }{
+		{"GoRuntime", goRuntimeJava},
+		{"GoMemoryMXBean", goMemoryMXBeanJava},
+		{"GoResultInterceptor", goResultInterceptorJava},
+	}...)
+	for _, f := range classes {
+		path := filepath.Join(javaDir, f.name+".java")
+		if err := writeJavaFile(path, []byte(f.src)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	if cfg.sourceMap {
+		path := filepath.Join(javaDir, "SourceMap.java")
+		if err := writeJavaFile(path, []byte(sourceMapJava)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	if cfg.intercept {
+		path := filepath.Join(javaDir, "GoMetrics.java")
+		if err := writeJavaFile(path, []byte(goMetricsJava)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	if cfg.record {
+		for _, f := range []struct{ name, src string }{
+			{"GoRecorder", goRecorderJava},
+			{"GoReplay", goReplayJava},
+		} {
+			path := filepath.Join(javaDir, f.name+".java")
+			if err := writeJavaFile(path, []byte(f.src)); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	if cfg.memoryLimits {
+		for _, f := range []struct{ name, src string }{
+			{"GoMemory", goMemoryJava},
+			{"GoResourceExhausted", goResourceExhaustedJava},
+		} {
+			path := filepath.Join(javaDir, f.name+".java")
+			if err := writeJavaFile(path, []byte(f.src)); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	if cfg.jfr {
+		for _, f := range []struct{ name, src string }{
+			{"GoJfr", goJfrJava},
+			{"GoProfiler", goProfilerJava},
+		} {
+			path := filepath.Join(javaDir, f.name+".java")
+			if err := writeJavaFile(path, []byte(f.src)); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	if cfg.tensors {
+		for _, f := range tensorClasses() {
+			path := filepath.Join(javaDir, f.name+".java")
+			if err := writeJavaFile(path, []byte(f.src)); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	if cfg.metrics == "micrometer" {
+		path := filepath.Join(javaDir, "GoMicrometer.java")
+		if err := writeJavaFile(path, []byte(goMicrometerJava)); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	if cfg.includeUnstable {
+		for _, name := range []string{"Experimental", "Internal"} {
+			path := filepath.Join(javaDir, name+".java")
+			if err := writeJavaFile(path, []byte(fmt.Sprintf(stabilityJava, name, strings.ToLower(name), name))); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+const goJava = `package go;
+
+import java.io.IOException;
+import java.io.InputStream;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.util.concurrent.Semaphore;
+
+// Go controls loading of the native library containing the Go code.
+public final class Go {
+	// LAZY is true if the native library is only loaded by an explicit call to load.
+	static final boolean LAZY = %t;
+
+	// INTERCEPT is true if the bindings were built with -intercept, whose
+	// wrappers call the interceptor and count the calls of gojava.maxCalls.
+	static final boolean INTERCEPT = %t;
+
+	// OUT_OF_PROCESS is true if the bindings were built with -out-of-process
+	// and the gojava.outOfProcess system property is not false. The package
+	// functions are then called in a child process run by go.GoProcess, and
+	// the native library is only loaded for the rest of the bindings.
+	public static final boolean OUT_OF_PROCESS = %t && !"false".equals(System.getProperty("gojava.outOfProcess"));
+
+	// NATIVE_SHA256 is the hex SHA-256 digest of the native library when the
+	// bindings are built with -digests, or empty. The library is checked
+	// against it before it is loaded.
+	static final String NATIVE_SHA256 = "%s";
+
+	// ABI_VERSION is the ABI version of the bindings. The native library is
+	// checked to be built for it when it is loaded.
+	public static final String ABI_VERSION = "%s";
+
+	private static volatile boolean requested;
+
+	private Go() {}
+
+	// load loads the native library, if it has not been loaded yet. When the
+	// bindings are built with -lazy, this must be called before any generated
+	// class is used.
+	public static synchronized void load() {
+		requested = true;
+		try {
+			Class.forName("go.LoadJNI", true, Go.class.getClassLoader());
+		} catch (ClassNotFoundException ex) {
+			throw new RuntimeException(ex);
+		}
+	}
+
+	static boolean loadRequested() {
+		return requested;
+	}
+
+	private static volatile GoInterceptor interceptor;
+
+	// callLimit limits the bound calls in flight at once to the gojava.maxCalls
+	// system property, or is null.
+	private static final CallLimit callLimit = CallLimit.fromProperty();
+
+	// setInterceptor registers i to be called around every bound call, when the
+	// bindings are built with -intercept. Passing null removes it.
+	public static void setInterceptor(GoInterceptor i) {
+		interceptor = i;
+	}
+
+	// interceptor returns the interceptor registered with setInterceptor, or
+	// null.
+	public static GoInterceptor interceptor() {
+		return interceptor;
+	}
+
+	// callInterceptor returns the interceptor the generated wrappers call
+	// around bound calls: the one registered, guarded by the limit of
+	// gojava.maxCalls if set, or null if there is neither.
+	public static GoInterceptor callInterceptor() {
+		if (callLimit != null) {
+			return callLimit;
+		}
+		return interceptor;
+	}
+
+	// CallLimit blocks bound calls while gojava.maxCalls calls are in flight,
+	// so that the JVM threads calling Go do not exceed the threads of Go.
+	private static final class CallLimit implements GoResultInterceptor {
+		private final Semaphore calls;
+
+		private CallLimit(int max) {
+			calls = new Semaphore(max);
+		}
+
+		static CallLimit fromProperty() {
+			String v = System.getProperty("gojava.maxCalls");
+			if (v == null) {
+				return null;
+			}
+			return new CallLimit(GoRuntime.parseCount("gojava.maxCalls", v));
+		}
+
+		@Override
+		public Object before(String method, Object[] args) {
+			calls.acquireUninterruptibly();
+			GoInterceptor i = interceptor;
+			if (i == null) {
+				return null;
+			}
+			try {
+				return new Object[] {i, i.before(method, args)};
+			} catch (RuntimeException | Error e) {
+				calls.release();
+				throw e;
+			}
+		}
+
+		@Override
+		public void result(String method, Object state, Object result) {
+			if (state != null) {
+				Object[] s = (Object[]) state;
+				if (s[0] instanceof GoResultInterceptor) {
+					((GoResultInterceptor) s[0]).result(method, s[1], result);
+				}
+			}
+		}
+
+		@Override
+		public void after(String method, Object state, long nanos, Throwable error) {
+			try {
+				if (state != null) {
+					Object[] s = (Object[]) state;
+					((GoInterceptor) s[0]).after(method, s[1], nanos, error);
+				}
+			} finally {
+				calls.release();
+			}
+		}
+	}
+
+	// verifyNativeLibrary checks the native library in the jar against the
+	// SHA-256 digest recorded when the bindings were built with -digests,
+	// throwing SecurityException if it does not match.
+	public static void verifyNativeLibrary() throws IOException {
+		if (NATIVE_SHA256.isEmpty()) {
+			throw new IllegalStateException("the Go bindings were built without -digests");
+		}
+		InputStream in = Go.class.getResourceAsStream("/go/libgojava");
+		if (in == null) {
+			throw new IOException("Go JNI library not found in classpath");
+		}
+		MessageDigest digest = newDigest();
+		try {
+			byte[] buffer = new byte[8192];
+			int n;
+			while ((n = in.read(buffer)) != -1) {
+				digest.update(buffer, 0, n);
+			}
+		} finally {
+			in.close();
+		}
+		checkDigest(digest);
+	}
+
+	static MessageDigest newDigest() {
+		try {
+			return MessageDigest.getInstance("SHA-256");
+		} catch (NoSuchAlgorithmException ex) {
+			throw new RuntimeException(ex);
+		}
+	}
+
+	// checkDigest throws SecurityException if digest, of the native library,
+	// does not match NATIVE_SHA256.
+	static void checkDigest(MessageDigest digest) {
+		StringBuilder b = new StringBuilder();
+		for (byte x : digest.digest()) {
+			b.append(String.format("%%02x", x));
+		}
+		if (!NATIVE_SHA256.equals(b.toString())) {
+			throw new SecurityException("Go JNI library has SHA-256 digest " + b + ", expected " + NATIVE_SHA256);
+		}
+	}
+}
+`
+
+const goInterceptorJava = `package go;
+
+// GoInterceptor is called around every call from Java to a bound Go function
+// or method, when the bindings are built with -intercept. Register it with
+// Go.setInterceptor.
+public interface GoInterceptor {
+	// before is called before the call to method, the qualified Java name of
+	// the method, with its arguments. The result is passed to after.
+	Object before(String method, Object[] args);
+
+	// after is called when the call returns or throws, with the duration of
+	// the call in nanoseconds and the exception thrown, if any.
+	void after(String method, Object state, long nanos, Throwable error);
+}
+`
+
+const goResultInterceptorJava = `package go;
+
+// GoResultInterceptor is a GoInterceptor also passed the result of every bound
+// call that returns, before after is called.
+public interface GoResultInterceptor extends GoInterceptor {
+	// result is called with the state returned by before and the result of
+	// method, boxed, or null for void methods.
+	void result(String method, Object state, Object result);
+}
+`
+
+const goExceptionJava = `package go;
+
+// GoException is thrown for a Go error value bound from a type implementing
+// error. value returns the bound Go value.
+public class GoException extends RuntimeException {
+	private static final long serialVersionUID = 1L;
+
+	private final transient Object value;
+
+	public GoException(String message, Object value) {
+		super(message);
+		this.value = value;
+	}
+
+	public Object value() {
+		return value;
+	}
+}
+`
+
+const goRejectedExceptionJava = `package go;
+
+// GoRejectedException is thrown by a bound method with a //gojava:limit N reject
+// directive when N calls to it are already in flight.
+public class GoRejectedException extends RuntimeException {
+	private static final long serialVersionUID = 1L;
+
+	public GoRejectedException(String method) {
+		super(method + ": too many concurrent calls");
+	}
+}
+`
+
+const goWaitHandleJava = `package go;
+
+// GoWaitHandle is implemented by the classes of Go types with a Wait method,
+// which blocks until background work started by Go completes.
+public interface GoWaitHandle {
+	// await blocks until the Go work completes, throwing its error if any.
+	void await() throws Exception;
+}
+`
+
+const goFutureJava = `package go;
+
+import java.util.concurrent.Callable;
+import java.util.concurrent.ExecutionException;
+import java.util.concurrent.Future;
+import java.util.concurrent.FutureTask;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.TimeoutException;
+
+// GoFuture adapts a GoWaitHandle to the java.util.concurrent APIs.
+public final class GoFuture {
+	private GoFuture() {}
+
+	// of returns a Future completing when h.await returns. The wait runs on a
+	// daemon thread, as the Go call cannot be interrupted.
+	public static Future<Void> of(final GoWaitHandle h) {
+		FutureTask<Void> task = new FutureTask<Void>(new Callable<Void>() {
+			@Override
+			public Void call() throws Exception {
+				h.await();
+				return null;
+			}
+		});
+		Thread t = new Thread(task, "gojava-wait");
+		t.setDaemon(true);
+		t.start();
+		return task;
+	}
+
+	// await waits at most timeout for h to complete, returning false if it did
+	// not complete in time.
+	public static boolean await(GoWaitHandle h, long timeout, TimeUnit unit) throws Exception {
+		try {
+			of(h).get(timeout, unit);
+			return true;
+		} catch (TimeoutException ex) {
+			return false;
+		} catch (ExecutionException ex) {
+			Throwable cause = ex.getCause();
+			if (cause instanceof Exception) {
+				throw (Exception) cause;
+			}
+			throw ex;
+		}
+	}
+}
+`
+
+const goMetricsJava = `package go;
+
+import java.util.HashMap;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.atomic.AtomicLong;
+
+// GoMetrics is a GoInterceptor counting the calls, errors, time and argument
+// bytes of every bound method. Register it with Go.setInterceptor.
+public class GoMetrics implements GoInterceptor {
+	// Stats holds the totals for a method.
+	public static final class Stats {
+		public final AtomicLong calls = new AtomicLong();
+		public final AtomicLong errors = new AtomicLong();
+		public final AtomicLong nanos = new AtomicLong();
+		public final AtomicLong bytes = new AtomicLong();
+	}
+
+	private final ConcurrentHashMap<String, Stats> stats = new ConcurrentHashMap<String, Stats>();
+
+	// argBytes estimates the bytes marshaled to Go for args.
+	public static long argBytes(Object[] args) {
+		long n = 0;
+		for (Object a : args) {
+			if (a instanceof byte[]) {
+				n += ((byte[]) a).length;
+			} else if (a instanceof String) {
+				n += ((String) a).length();
+			} else {
+				n += 8;
+			}
+		}
+		return n;
+	}
+
+	@Override
+	public Object before(String method, Object[] args) {
+		return argBytes(args);
+	}
+
+	@Override
+	public void after(String method, Object state, long nanos, Throwable error) {
+		Stats s = stats(method);
+		s.calls.incrementAndGet();
+		s.nanos.addAndGet(nanos);
+		s.bytes.addAndGet((Long) state);
+		if (error != null) {
+			s.errors.incrementAndGet();
+		}
+	}
+
+	// stats returns the totals for method.
+	public Stats stats(String method) {
+		Stats s = stats.get(method);
+		if (s == null) {
+			stats.putIfAbsent(method, new Stats());
+			s = stats.get(method);
+		}
+		return s;
+	}
+
+	// snapshot returns the totals of all methods called so far.
+	public Map<String, Stats> snapshot() {
+		return new HashMap<String, Stats>(stats);
+	}
+}
+`
+
+const goMemoryJava = `package go;
+
+import java.util.HashMap;
+import java.util.Map;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.atomic.AtomicLong;
+import java.util.concurrent.locks.ReentrantLock;
+
+// GoMemory is a GoInterceptor accounting for the memory used by bound calls. It
+// counts the argument bytes marshaled to Go by each method, and throws
+// GoResourceExhausted instead of calling Go when a call marshals more than
+// maxCallBytes. With maxCallAllocBytes, it also measures the bytes each call
+// allocates in the Go heap, as the growth of the allocations of the Go runtime
+// while it runs, and throws GoResourceExhausted when a call that allocated more
+// than maxCallAllocBytes returns. Go does not count allocations per goroutine,
+// so measured calls run one at a time to be charged only their own
+// allocations, and those of goroutines running in the background meanwhile.
+// A limit of 0 is not enforced, and calls are not serialized without
+// maxCallAllocBytes.
+public final class GoMemory implements GoInterceptor {
+	private final long maxCallBytes;
+	private final long maxCallAllocBytes;
+	private final ReentrantLock measuring = new ReentrantLock();
+	private final ConcurrentHashMap<String, AtomicLong> marshaled = new ConcurrentHashMap<String, AtomicLong>();
+	private final ConcurrentHashMap<String, AtomicLong> allocated = new ConcurrentHashMap<String, AtomicLong>();
+
+	public GoMemory(long maxCallBytes, long maxCallAllocBytes) {
+		this.maxCallBytes = maxCallBytes;
+		this.maxCallAllocBytes = maxCallAllocBytes;
+	}
+
+	// Measured is the state of a measured call.
+	private static final class Measured {
+		final long allocs;
+		boolean done;
+
+		Measured(long allocs) {
+			this.allocs = allocs;
+		}
+	}
+
+	@Override
+	public Object before(String method, Object[] args) {
+		long n = GoMetrics.argBytes(args);
+		if (maxCallBytes > 0 && n > maxCallBytes) {
+			throw new GoResourceExhausted(method + ": " + n + " argument bytes exceed the limit of " + maxCallBytes);
+		}
+		add(marshaled, method, n);
+		if (maxCallAllocBytes <= 0) {
+			return null;
+		}
+		measuring.lock();
+		return new Measured(allocBytes0());
+	}
+
+	@Override
+	public void after(String method, Object state, long nanos, Throwable error) {
+		if (!(state instanceof Measured)) {
+			return;
+		}
+		// after is called again with the exception it throws.
+		Measured m = (Measured) state;
+		if (m.done) {
+			return;
+		}
+		m.done = true;
+		long n = allocBytes0() - m.allocs;
+		measuring.unlock();
+		add(allocated, method, n);
+		if (error == null && n > maxCallAllocBytes) {
+			throw new GoResourceExhausted(method + ": " + n + " bytes allocated in the Go heap exceed the limit of " + maxCallAllocBytes);
+		}
+	}
+
+	private static void add(ConcurrentHashMap<String, AtomicLong> totals, String method, long n) {
+		AtomicLong total = totals.get(method);
+		if (total == null) {
+			totals.putIfAbsent(method, new AtomicLong());
+			total = totals.get(method);
+		}
+		total.addAndGet(n);
+	}
+
+	private static Map<String, Long> snapshot(ConcurrentHashMap<String, AtomicLong> totals) {
+		Map<String, Long> m = new HashMap<String, Long>();
+		for (Map.Entry<String, AtomicLong> e : totals.entrySet()) {
+			m.put(e.getKey(), e.getValue().get());
+		}
+		return m;
+	}
+
+	// marshaledBytes returns the argument bytes marshaled to Go by the calls to
+	// each method so far.
+	public Map<String, Long> marshaledBytes() {
+		return snapshot(marshaled);
+	}
+
+	// allocatedBytes returns the bytes allocated in the Go heap by the
+	// measured calls to each method so far.
+	public Map<String, Long> allocatedBytes() {
+		return snapshot(allocated);
+	}
+
+	// heapBytes returns the bytes of objects in the Go heap, which holds the Go
+	// values referenced from Java, loading the native library if needed. It is
+	// the size of the whole heap, not attributed to calls.
+	public static long heapBytes() {
+		Go.load();
+		return heapBytes0();
+	}
+
+	private static native long heapBytes0();
+
+	private static native long allocBytes0();
+}
+`
+
+const goResourceExhaustedJava = `package go;
+
+// GoResourceExhausted is thrown by GoMemory when a bound call would exceed a
+// memory limit.
+public class GoResourceExhausted extends RuntimeException {
+	private static final long serialVersionUID = 1L;
+
+	public GoResourceExhausted(String message) {
+		super(message);
+	}
+}
+`
+
+const goMicrometerJava = `package go;
+
+import io.micrometer.core.instrument.DistributionSummary;
+import io.micrometer.core.instrument.MeterRegistry;
+import io.micrometer.core.instrument.Timer;
+import java.util.concurrent.TimeUnit;
+
+// GoMicrometer is a GoInterceptor publishing the latency and outcome of every
+// bound call as the gojava.calls timer, and the argument bytes marshaled to Go
+// as the gojava.marshaled.bytes summary, tagged with the method name.
+public final class GoMicrometer implements GoInterceptor {
+	private final MeterRegistry registry;
+
+	public GoMicrometer(MeterRegistry registry) {
+		this.registry = registry;
+	}
+
+	@Override
+	public Object before(String method, Object[] args) {
+		return GoMetrics.argBytes(args);
+	}
+
+	@Override
+	public void after(String method, Object state, long nanos, Throwable error) {
+		Timer.builder("gojava.calls")
+				.tag("method", method)
+				.tag("outcome", error == null ? "success" : "error")
+				.register(registry)
+				.record(nanos, TimeUnit.NANOSECONDS);
+		DistributionSummary.builder("gojava.marshaled.bytes")
+				.baseUnit("bytes")
+				.tag("method", method)
+				.register(registry)
+				.record((Long) state);
+	}
+}
+`
+
+const sourceMapJava = `package go;
+
+import java.io.BufferedReader;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.InputStreamReader;
+import java.util.HashMap;
+import java.util.Map;
+
+// SourceMap maps the methods of the generated classes to the Go declarations
+// they call, using the source map written by gojava -source-map.
+public final class SourceMap {
+	private static Map<String, String> positions;
+
+	private SourceMap() {}
+
+	private static synchronized Map<String, String> positions() {
+		if (positions != null) {
+			return positions;
+		}
+		positions = new HashMap<String, String>();
+		InputStream in = SourceMap.class.getClassLoader().getResourceAsStream("` + sourceMapPath + `");
+		if (in == null) {
+			return positions;
+		}
+		try {
+			BufferedReader r = new BufferedReader(new InputStreamReader(in, "UTF-8"));
+			try {
+				String line;
+				while ((line = r.readLine()) != null) {
+					String[] f = line.split("\t", 3);
+					if (f.length == 3) {
+						positions.put(f[0] + "." + f[1], f[2]);
+					}
+				}
+			} finally {
+				r.close();
+			}
+		} catch (IOException ex) {
+			// A missing or unreadable source map only disables remapping.
+		}
+		return positions;
+	}
+
+	// position returns the Go file:line bound by the method of the class with
+	// the given binary name, or null if it is not a generated method.
+	public static String position(String className, String methodName) {
+		return positions().get(className + "." + methodName);
+	}
+
+	// remap replaces the file and line of stack frames in generated methods of t,
+	// and of its causes, with the position of the Go declaration they call.
+	public static <T extends Throwable> T remap(T t) {
+		for (Throwable c = t; c != null; c = c.getCause()) {
+			StackTraceElement[] trace = c.getStackTrace();
+			for (int i = 0; i < trace.length; i++) {
+				StackTraceElement e = trace[i];
+				String pos = position(e.getClassName(), e.getMethodName());
+				if (pos == null) {
+					continue;
+				}
+				int colon = pos.lastIndexOf(':');
+				trace[i] = new StackTraceElement(e.getClassName(), e.getMethodName(),
+						pos.substring(0, colon), Integer.parseInt(pos.substring(colon + 1)));
+			}
+			c.setStackTrace(trace);
+		}
+		return t;
+	}
+}
+`
+
+const stabilityJava = `package go;
+
+import java.lang.annotation.Documented;
+import java.lang.annotation.Retention;
+import java.lang.annotation.RetentionPolicy;
+
+// %s marks generated APIs bound to Go declarations with a //gojava:%s
+// directive. They may change or be removed in any release.
+@Documented
+@Retention(RetentionPolicy.CLASS)
+public @interface %s {}
+`
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRuntime(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   config
+		files []string
+	}{
+		{config{}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java"}},
+		{config{intercept: true, memoryLimits: true}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java", "GoMetrics.java", "GoMemory.java", "GoResourceExhausted.java"}},
+		{config{intercept: true, jfr: true}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java", "GoMetrics.java", "GoJfr.java", "GoProfiler.java"}},
+		{config{tensors: true}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java", "GoFloatTensor.java", "GoDoubleTensor.java"}},
+		{config{intercept: true, record: true}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java", "GoMetrics.java", "GoRecorder.java", "GoReplay.java"}},
+		{config{intercept: true, metrics: "micrometer"}, []string{"Go.java", "GoInterceptor.java", "GoException.java", "GoRejectedException.java", "GoWaitHandle.java", "GoFuture.java", "GoRuntime.java", "GoMemoryMXBean.java", "GoResultInterceptor.java", "GoMetrics.java", "GoMicrometer.java"}},
+	} {
+		tmpDir, err := ioutil.TempDir("", "gojavatest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		files, err := writeRuntime(&tc.cfg, tmpDir, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range files {
+			names = append(names, filepath.Base(f))
+		}
+		if got, exp := strings.Join(names, ","), strings.Join(tc.files, ","); got != exp {
+			t.Errorf("%+v: expected %s, got %s", tc.cfg, exp, got)
+		}
+	}
+}
+
+func TestWriteRuntimeInterceptor(t *testing.T) {
+	for _, intercept := range []bool{false, true} {
+		tmpDir, err := ioutil.TempDir("", "gojavatest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		if _, err := writeRuntime(&config{intercept: intercept}, tmpDir, "", ""); err != nil {
+			t.Fatal(err)
+		}
+		d, err := ioutil.ReadFile(filepath.Join(tmpDir, "Go.java"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// interceptor returns the registered interceptor, not the call limit
+		// wrapping it for the generated wrappers.
+		for _, s := range []string{
+			fmt.Sprintf("static final boolean INTERCEPT = %t;", intercept),
+			"public static GoInterceptor interceptor() {\n\t\treturn interceptor;\n\t}",
+			"public static GoInterceptor callInterceptor() {\n\t\tif (callLimit != null) {\n\t\t\treturn callLimit;",
+		} {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("intercept %t: Go.java missing %q", intercept, s)
+			}
+		}
+	}
+	// Loading fails if gojava.maxCalls is set without the wrappers counting
+	// the calls.
+	if !strings.Contains(goRuntimeJava, `System.getProperty("gojava.maxCalls") != null && !Go.INTERCEPT`) {
+		t.Error("GoRuntime.configure does not check gojava.maxCalls")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// sharedRuntimePkg is the Java package of the runtime classes in the jar
+	// written by gojava runtime, which the jars built with -shared-runtime
+	// use instead of their own copies.
+	sharedRuntimePkg = "go.runtime"
+	// sharedRuntimeVersion is the version of the shared runtime classes,
+	// recorded in the manifest of the runtime jar. It changes when a class
+	// is changed incompatibly.
+	sharedRuntimeVersion = "1"
+)
+
+// sharedRuntimeClasses are the runtime classes that do not depend on the
+// native library of a binding, so a single copy can be shared by the jars of
+// bindings built by different teams. Seq, LoadJNI and Go call into the native
+// library of their jar, and stay in it.
+var sharedRuntimeClasses = []struct{ name, src string }{
+	{"GoInterceptor", goInterceptorJava},
+	{"GoException", goExceptionJava},
+	{"GoRejectedException", goRejectedExceptionJava},
+	{"GoWaitHandle", goWaitHandleJava},
+	{"GoFuture", goFutureJava},
+}
+
+// sharedRuntimePath returns the absolute path of the runtime jar of cfg, or
+// "" if the bindings have their own runtime classes.
+func sharedRuntimePath(cfg *config) string {
+	if cfg.sharedRuntime == "" || filepath.IsAbs(cfg.sharedRuntime) {
+		return cfg.sharedRuntime
+	}
+	return filepath.Join(cwd, cfg.sharedRuntime)
+}
+
+// compileClassPath returns the class path compiling the Java sources of cfg
+// against the classes in dir, if set, and the runtime jar, if any.
+func compileClassPath(cfg *config, dir string) string {
+	var paths []string
+	for _, p := range []string{dir, sharedRuntimePath(cfg)} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return strings.Join(paths, string(filepath.ListSeparator))
+}
+
+// useSharedRuntime rewrites the sources in javaDir, moved to root, to use the
+// shared runtime classes: qualified references are moved to sharedRuntimePkg,
+// and the classes are imported by the Java sources referring to them
+// unqualified. The Kotlin and Scala sources from -s must refer to them
+// qualified or with an import.
+func useSharedRuntime(javaDir, root string) error {
+	type rewrite struct {
+		qualified, unqualified *regexp.Regexp
+		replace, imp           string
+	}
+	var rewrites []rewrite
+	for _, c := range sharedRuntimeClasses {
+		rewrites = append(rewrites, rewrite{
+			qualified:   regexp.MustCompile(`(^|[^\p{L}\p{N}_$.])` + regexp.QuoteMeta(root+"."+c.name) + `\b`),
+			unqualified: regexp.MustCompile(`(^|[^\p{L}\p{N}_$.])` + c.name + `\b`),
+			replace:     "${1}" + sharedRuntimePkg + "." + c.name,
+			imp:         "import " + sharedRuntimePkg + "." + c.name + ";\n",
+		})
+	}
+	return filepath.Walk(javaDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || (filepath.Ext(path) != ".java" && jvmLanguageOf(path) == nil) {
+			return err
+		}
+		java := filepath.Ext(path) == ".java"
+		return rewriteFile(path, func(src string) string {
+			var imports string
+			for _, r := range rewrites {
+				src = r.qualified.ReplaceAllString(src, r.replace)
+				if java && r.unqualified.MatchString(src) {
+					imports += r.imp
+				}
+			}
+			if imports == "" {
+				return src
+			}
+			at := 0
+			if loc := javaPackageClause.FindStringIndex(src); loc != nil {
+				at = loc[1]
+			}
+			return src[:at] + imports + src[at:]
+		})
+	})
+}
+
+// moduleInfoJava is the module descriptor of the runtime jar.
+const moduleInfoJava = `module go.runtime {
+	exports go.runtime;
+}
+`
+
+// buildRuntimeJar writes the jar of the shared runtime classes to the target
+// of cfg. With a Java 9 or later compiler the jar is a multi-release jar with
+// the module descriptor of the go.runtime module, and otherwise names it as an
+// automatic module.
+func buildRuntimeJar(cfg *config) error {
+	tmpDir, err := ioutil.TempDir("", "gojava")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	srcDir, jarDir := filepath.Join(tmpDir, "src"), filepath.Join(tmpDir, "classes")
+	pkgDir := filepath.Join(srcDir, javaRootDir(sharedRuntimePkg))
+	var files []string
+	for _, c := range sharedRuntimeClasses {
+		path := filepath.Join(pkgDir, c.name+".java")
+		src := strings.Replace(c.src, "package go;", "package "+sharedRuntimePkg+";", 1)
+		if err := writeJavaFile(path, []byte(src)); err != nil {
+			return err
+		}
+		files = append(files, path)
+	}
+	c, err := newJavaCompiler(cfg)
+	if err != nil {
+		return err
+	}
+	err = withTimeout("javac", cfg.javacTimeout, func() error {
+		return c.compile(jarDir, srcDir, "", files)
+	})
+	if err != nil {
+		return err
+	}
+	modular, err := compileModuleInfo(cfg, c, tmpDir, srcDir, jarDir, files)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	writeManifestLine(&b, "Manifest-Version: 1.0")
+	writeManifestLine(&b, "Created-By: gojava")
+	writeManifestLine(&b, "Implementation-Title: gojava runtime")
+	writeManifestLine(&b, "Implementation-Version: "+sharedRuntimeVersion)
+	if modular {
+		writeManifestLine(&b, "Multi-Release: true")
+	} else {
+		writeManifestLine(&b, "Automatic-Module-Name: "+sharedRuntimePkg)
+	}
+	manifest := filepath.Join(jarDir, filepath.FromSlash(manifestName))
+	if err := os.MkdirAll(filepath.Dir(manifest), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(manifest, b.Bytes(), 0600); err != nil {
+		return err
+	}
+	return writeJars(cfg, []jarBuild{{cfg, jarDir}})
+}
+
+// compileModuleInfo compiles the module descriptor of the runtime jar with
+// its classes to META-INF/versions/9 in jarDir, if the Java compiler of cfg
+// supports modules. It reports whether the descriptor was compiled.
+func compileModuleInfo(cfg *config, c javaCompiler, tmpDir, srcDir, jarDir string, files []string) (bool, error) {
+	if cfg.javacImpl != "ecj" {
+		tool := javaTool("javac", cfg.javac)
+		if cfg.javacImpl == "tools" {
+			tool = javaTool("java", "")
+		}
+		v, err := javaVersion(tool)
+		if err != nil {
+			return false, err
+		}
+		if v < 9 {
+			verbosef("Java %d has no modules, naming go.runtime as an automatic module\n", v)
+			return false, nil
+		}
+	}
+	moduleInfo := filepath.Join(srcDir, "module-info.java")
+	if err := ioutil.WriteFile(moduleInfo, []byte(moduleInfoJava), 0600); err != nil {
+		return false, err
+	}
+	outDir := filepath.Join(tmpDir, "module")
+	err := withTimeout("javac", cfg.javacTimeout, func() error {
+		return c.compile(outDir, srcDir, "", append([]string{moduleInfo}, files...))
+	})
+	if err != nil {
+		return false, fmt.Errorf("compiling the module descriptor: %v", err)
+	}
+	versionDir := filepath.Join(jarDir, "META-INF", "versions", "9")
+	if err := os.MkdirAll(versionDir, 0700); err != nil {
+		return false, err
+	}
+	return true, copyFile(filepath.Join(versionDir, "module-info.class"), filepath.Join(outDir, "module-info.class"))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseSharedRuntime(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goPath, pkgPath := filepath.Join(tmpDir, "Go.java"), filepath.Join(tmpDir, "testpkg", "Testpkg.java")
+	for path, src := range map[string]string{
+		goPath:  "package go_v2;\n\npublic final class Go {\n\tstatic GoInterceptor interceptor;\n}\n",
+		pkgPath: "package go_v2.testpkg;\n\nimport go_v2.Seq;\n\npublic abstract class Testpkg {\n\tpublic go_v2.GoException asException() { return null; }\n}\n",
+	} {
+		if err := writeJavaFile(path, []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := useSharedRuntime(tmpDir, "go_v2"); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		goPath:  "package go_v2;\nimport go.runtime.GoInterceptor;\n\npublic final class Go {\n\tstatic GoInterceptor interceptor;\n}\n",
+		pkgPath: "package go_v2.testpkg;\n\nimport go_v2.Seq;\n\npublic abstract class Testpkg {\n\tpublic go.runtime.GoException asException() { return null; }\n}\n",
+	} {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", filepath.Base(path), d, want)
+		}
+	}
+}
+
+func TestCompileClassPath(t *testing.T) {
+	oldCwd := cwd
+	defer func() { cwd = oldCwd }()
+	cwd = "/work"
+	sep := string(filepath.ListSeparator)
+	for _, test := range []struct {
+		sharedRuntime, dir, want string
+	}{
+		{"", "", ""},
+		{"", "/tmp/classes", "/tmp/classes"},
+		{"lib/gojava-runtime.jar", "", "/work/lib/gojava-runtime.jar"},
+		{"/m2/gojava-runtime.jar", "/tmp/classes", "/tmp/classes" + sep + "/m2/gojava-runtime.jar"},
+	} {
+		if got := compileClassPath(&config{sharedRuntime: test.sharedRuntime}, test.dir); got != test.want {
+			t.Errorf("%q %q: got %q, want %q", test.sharedRuntime, test.dir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sbomPath is the path in the jar of the CycloneDX software bill of materials.
+const sbomPath = "META-INF/sbom/gojava.cdx.json"
+
+// cdxBOM is a CycloneDX bill of materials, with the fields gojava writes.
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cdxComponent struct {
+	Type    string    `json:"type"`
+	BOMRef  string    `json:"bom-ref,omitempty"`
+	Name    string    `json:"name"`
+	Version string    `json:"version,omitempty"`
+	Purl    string    `json:"purl,omitempty"`
+	Hashes  []cdxHash `json:"hashes,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// goPurl returns the package URL of the Go module path at version.
+func goPurl(path, version string) string {
+	if version == "" {
+		return "pkg:golang/" + path
+	}
+	return "pkg:golang/" + path + "@" + version
+}
+
+// moduleRef is the selected version of a module, and the bom-ref of its
+// component.
+type moduleRef struct {
+	version, ref string
+}
+
+// goModuleComponents returns the components for the Go toolchain and the
+// modules the native library at lib was built from, and the modules keyed by
+// path.
+func goModuleComponents(lib string) ([]cdxComponent, map[string]moduleRef, error) {
+	info, err := buildinfo.ReadFile(lib)
+	if err != nil {
+		return nil, nil, err
+	}
+	comps := []cdxComponent{{
+		Type:    "platform",
+		BOMRef:  "go",
+		Name:    "go",
+		Version: strings.TrimPrefix(info.GoVersion, "go"),
+	}}
+	refs := make(map[string]moduleRef)
+	for _, d := range info.Deps {
+		path, version := d.Path, d.Version
+		if d.Replace != nil {
+			path, version = d.Replace.Path, d.Replace.Version
+		}
+		if strings.HasPrefix(path, ".") || filepath.IsAbs(path) {
+			// Replaced by a local directory, which has no version.
+			path, version = d.Path, ""
+		}
+		purl := goPurl(path, version)
+		refs[d.Path] = moduleRef{d.Version, purl}
+		comps = append(comps, cdxComponent{Type: "library", BOMRef: purl, Name: path, Version: version, Purl: purl})
+	}
+	return comps, refs, nil
+}
+
+// moduleDependencies returns the dependencies between the modules in refs
+// given by the output of go mod graph. Requirements of module versions that
+// were not selected are left out, and requirements of the main module are
+// attributed to root.
+func moduleDependencies(graph []byte, root string, refs map[string]moduleRef) []cdxDependency {
+	deps := make(map[string]map[string]bool)
+	s := bufio.NewScanner(bytes.NewReader(graph))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 {
+			continue
+		}
+		var from, to string
+		if i := strings.LastIndex(f[0], "@"); i < 0 {
+			from = root
+		} else if m, ok := refs[f[0][:i]]; ok && m.version == f[0][i+1:] {
+			from = m.ref
+		}
+		if i := strings.LastIndex(f[1], "@"); i >= 0 {
+			to = refs[f[1][:i]].ref
+		}
+		if from == "" || to == "" {
+			continue
+		}
+		if deps[from] == nil {
+			deps[from] = make(map[string]bool)
+		}
+		deps[from][to] = true
+	}
+	var out []cdxDependency
+	for from, to := range deps {
+		d := cdxDependency{Ref: from}
+		for t := range to {
+			d.DependsOn = append(d.DependsOn, t)
+		}
+		sort.Strings(d.DependsOn)
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ref < out[j].Ref })
+	return out
+}
+
+// javaSourceComponents returns the components for the Java source files in
+// javaDir copied from the -s directory, with their SHA-256 hashes.
+func javaSourceComponents(javaDir string, files []string) ([]cdxComponent, error) {
+	var comps []cdxComponent
+	for _, f := range files {
+		d, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		name, err := filepath.Rel(javaDir, f)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(d)
+		comps = append(comps, cdxComponent{
+			Type:   "file",
+			Name:   filepath.ToSlash(name),
+			Hashes: []cdxHash{{Alg: "SHA-256", Content: hex.EncodeToString(sum[:])}},
+		})
+	}
+	return comps, nil
+}
+
+// writeSBOM writes a CycloneDX bill of materials for the jar named name to
+// jarDir, listing the Go toolchain and modules the native library at lib was
+// built with from mainDir, the dependencies between the modules if built in
+// module mode, and the Java sources included with -s.
+func writeSBOM(jarDir, name, lib, mainDir string, modules bool, javaDir string, sources []string) error {
+	comps, refs, err := goModuleComponents(lib)
+	if err != nil {
+		return err
+	}
+	javaComps, err := javaSourceComponents(javaDir, sources)
+	if err != nil {
+		return err
+	}
+	bom := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools:     []cdxTool{{Name: "gojava", Version: gojavaVersion()}},
+			Component: cdxComponent{Type: "library", BOMRef: "jar", Name: name},
+		},
+		Components: append(comps, javaComps...),
+	}
+	if modules {
+		graph, err := commandOutputIn(mainDir, "go", "mod", "graph")
+		if err != nil {
+			return err
+		}
+		bom.Dependencies = moduleDependencies(graph, "jar", refs)
+	}
+	d, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(jarDir, filepath.FromSlash(sbomPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(d, '\n'), 0600)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModuleDependencies(t *testing.T) {
+	refs := map[string]moduleRef{
+		"example.com/a": {"v1.2.0", "pkg:golang/example.com/a@v1.2.0"},
+		"example.com/b": {"v0.1.0", "pkg:golang/example.com/fork/b@v0.2.0"},
+	}
+	graph := `gojava_bind example.com/a@v1.2.0
+gojava_bind go@1.21
+example.com/a@v1.2.0 example.com/b@v0.1.0
+example.com/a@v1.1.0 example.com/c@v1.0.0
+example.com/b@v0.1.0 example.com/a@v1.0.0
+example.com/b@v0.1.0 toolchain@go1.21.0
+`
+	expected := []cdxDependency{
+		{Ref: "jar", DependsOn: []string{"pkg:golang/example.com/a@v1.2.0"}},
+		{Ref: "pkg:golang/example.com/a@v1.2.0", DependsOn: []string{"pkg:golang/example.com/fork/b@v0.2.0"}},
+		{Ref: "pkg:golang/example.com/fork/b@v0.2.0", DependsOn: []string{"pkg:golang/example.com/a@v1.2.0"}},
+	}
+	if deps := moduleDependencies([]byte(graph), "jar", refs); !reflect.DeepEqual(deps, expected) {
+		t.Errorf("got %+v, want %+v", deps, expected)
+	}
+}
+
+func TestJavaSourceComponents(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "extra", "Extra.java")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	comps, err := javaSourceComponents(tmpDir, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []cdxComponent{{
+		Type:   "file",
+		Name:   "extra/Extra.java",
+		Hashes: []cdxHash{{Alg: "SHA-256", Content: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}},
+	}}
+	if !reflect.DeepEqual(comps, expected) {
+		t.Errorf("got %+v, want %+v", comps, expected)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import "path/filepath"
+
+// genScripting writes go.GoScripting, exposing the packages described by
+// go.GoPackages to JSR-223 script engines, and returns its path.
+func genScripting(javaDir string) ([]string, error) {
+	path := filepath.Join(javaDir, "GoScripting.java")
+	if err := writeJavaFile(path, []byte(goScriptingJava)); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+const goScriptingJava = `// Code generated by gojava. DO NOT EDIT.
+
+package go;
+
+import java.util.Collections;
+import java.util.LinkedHashMap;
+import java.util.Map;
+import javax.script.Bindings;
+import javax.script.ScriptContext;
+import javax.script.ScriptEngine;
+
+// GoScripting exposes the bound Go packages to JSR-223 script engines, such
+// as Groovy, JavaScript or Jython, as a map from package names to objects
+// calling their functions by name with boxed arguments:
+//
+//	go.geo.call("NewPoint", 1, 2)
+//
+// The objects returned are the generated classes, whose methods scripts call
+// directly, or by name with GoScripting.invoke.
+public final class GoScripting {
+	private GoScripting() {}
+
+	// Package calls the functions of a bound package by name.
+	public static final class Package {
+		public final GoPackages.Package descriptor;
+
+		Package(GoPackages.Package descriptor) {
+			this.descriptor = descriptor;
+		}
+
+		// call calls the function with the Go or Java name, converting numeric
+		// arguments to its parameter types.
+		public Object call(String name, Object... args) throws Exception {
+			for (GoPackages.Method m : descriptor.functions) {
+				if (m.goName.equals(name) || m.name.equals(name)) {
+					return m.invoke(null, args);
+				}
+			}
+			throw new IllegalArgumentException(descriptor.path + " has no function " + name);
+		}
+
+		public String toString() {
+			return descriptor.path;
+		}
+	}
+
+	private static final Map<String, Package> PACKAGES;
+
+	static {
+		Map<String, Package> m = new LinkedHashMap<String, Package>();
+		for (GoPackages.Package p : GoPackages.list()) {
+			Package sp = new Package(p);
+			m.put(p.name, sp);
+			m.put(p.path, sp);
+		}
+		PACKAGES = Collections.unmodifiableMap(m);
+	}
+
+	// packages returns the bound packages by Go package name and import path.
+	public static Map<String, Package> packages() {
+		return PACKAGES;
+	}
+
+	// bind puts the bound packages in the engine scope of engine as go.
+	public static void bind(ScriptEngine engine) {
+		bind(engine.getBindings(ScriptContext.ENGINE_SCOPE), "go");
+	}
+
+	// bind puts the bound packages in bindings under name.
+	public static void bind(Bindings bindings, String name) {
+		bindings.put(name, PACKAGES);
+	}
+
+	// invoke calls the method with the Go or Java name of the bound type
+	// receiver is an instance of, converting numeric arguments to its
+	// parameter types.
+	public static Object invoke(Object receiver, String name, Object... args) throws Exception {
+		if (receiver == null) {
+			throw new NullPointerException(name + ": null receiver");
+		}
+		for (GoPackages.Package p : GoPackages.list()) {
+			for (GoPackages.Type t : p.types) {
+				if (!t.javaClass.isInstance(receiver)) {
+					continue;
+				}
+				for (GoPackages.Method m : t.methods) {
+					if (m.name.equals(name) || m.goName.equals(t.goName + "." + name)) {
+						return m.invoke(receiver, args);
+					}
+				}
+			}
+		}
+		throw new IllegalArgumentException(receiver.getClass().getName() + " has no bound method " + name);
+	}
+}
+`
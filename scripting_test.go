@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenScripting(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files, err := genScripting(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(tmpDir, "GoScripting.java") {
+		t.Fatalf("got files %v", files)
+	}
+	src, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"package go;\n", "for (GoPackages.Package p : GoPackages.list())", "public static void bind(ScriptEngine engine)"} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("GoScripting.java does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// seqFunc is an exported package function returning an iter.Seq or
+// iter.Seq2. gomobile cannot bind function types, so a Go adapter with a
+// Next/Value shape is added to its package, driving the sequence with
+// iter.Pull, and bound as an Iterable.
+type seqFunc struct {
+	fn *types.Func
+	// key is the key type of an iter.Seq2, or nil for an iter.Seq.
+	key, value types.Type
+}
+
+// seqType returns the name of the adapter type of the function named name.
+func seqType(name string) string {
+	return name + "Seq"
+}
+
+// seqAdapter returns the name of the adapter function calling the function
+// named name. It is package private in Java, where the method named after
+// the function calls it.
+func seqAdapter(name string) string {
+	return "Gojava" + name
+}
+
+// seqElems returns the key and value types of t if it is an iter.Seq2, or a
+// nil key and the value type if it is an iter.Seq.
+func seqElems(t types.Type) (key, value types.Type, ok bool) {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok || n.Obj().Pkg() == nil || n.Obj().Pkg().Path() != "iter" {
+		return nil, nil, false
+	}
+	args := n.TypeArgs()
+	switch {
+	case n.Obj().Name() == "Seq" && args.Len() == 1:
+		return nil, args.At(0), true
+	case n.Obj().Name() == "Seq2" && args.Len() == 2:
+		return args.At(0), args.At(1), true
+	}
+	return nil, nil, false
+}
+
+// findSeqFuncs returns the exported functions of p whose only result is an
+// iter.Seq or iter.Seq2 of types with a Java type. Generic and variadic
+// functions are skipped.
+func findSeqFuncs(p *types.Package) []seqFunc {
+	var funcs []seqFunc
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 || sig.Variadic() || sig.Results().Len() != 1 {
+			continue
+		}
+		key, value, ok := seqElems(sig.Results().At(0).Type())
+		if !ok {
+			continue
+		}
+		if _, err := javaType(value); err != nil {
+			verbosef("skipping %s.%s: %v\n", p.Path(), name, err)
+			continue
+		}
+		if key != nil {
+			if _, err := javaType(key); err != nil {
+				verbosef("skipping %s.%s: %v\n", p.Path(), name, err)
+				continue
+			}
+		}
+		funcs = append(funcs, seqFunc{fn: fn, key: key, value: value})
+	}
+	return funcs
+}
+
+// seqOverlay returns the overlay adding the adapters of the functions of pkgs
+// returning an iter.Seq or iter.Seq2 to their packages, in a file next to
+// the one declaring them, named so that it has the same build constraints.
+// Functions whose adapter names are taken are skipped.
+func seqOverlay(fset *token.FileSet, pkgs []*types.Package) (map[string][]byte, error) {
+	overlay := make(map[string][]byte)
+	for _, p := range pkgs {
+		byFile := make(map[string][]seqFunc)
+		var files []string
+		for _, f := range findSeqFuncs(p) {
+			name := f.fn.Name()
+			if p.Scope().Lookup(seqType(name)) != nil || p.Scope().Lookup(seqAdapter(name)) != nil {
+				verbosef("skipping %s.%s: %s or %s is already declared\n", p.Path(), name, seqType(name), seqAdapter(name))
+				continue
+			}
+			file := fset.Position(f.fn.Pos()).Filename
+			if file == "" {
+				continue
+			}
+			if byFile[file] == nil {
+				files = append(files, file)
+			}
+			byFile[file] = append(byFile[file], f)
+		}
+		for _, file := range files {
+			constraints, err := buildConstraints(file)
+			if err != nil {
+				return nil, err
+			}
+			// The prefix keeps the GOOS and GOARCH suffixes of the name, as
+			// the part before the first underscore is ignored.
+			adapters := filepath.Join(filepath.Dir(file), "gojava"+filepath.Base(file))
+			overlay[adapters] = genSeqAdapters(p, constraints, byFile[file])
+		}
+	}
+	return overlay, nil
+}
+
+// buildConstraints returns the //go:build and // +build lines of the Go file.
+func buildConstraints(file string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//go:build ") || strings.HasPrefix(c.Text, "// +build ") {
+				lines = append(lines, c.Text)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// genSeqAdapters returns the Go file in p declaring the adapters of funcs,
+// with the build constraints.
+func genSeqAdapters(p *types.Package, constraints []string, funcs []seqFunc) []byte {
+	imports := map[string]string{"iter": "iter"}
+	names := map[string]string{"iter": "iter"}
+	qual := func(q *types.Package) string {
+		if q == p {
+			return ""
+		}
+		if name, ok := imports[q.Path()]; ok {
+			return name
+		}
+		name := q.Name()
+		for i := 2; names[name] != ""; i++ {
+			name = fmt.Sprintf("%s%d", q.Name(), i)
+		}
+		imports[q.Path()], names[name] = name, q.Path()
+		return name
+	}
+	var b bytes.Buffer
+	for _, f := range funcs {
+		name, typ := f.fn.Name(), seqType(f.fn.Name())
+		value := types.TypeString(f.value, qual)
+		elems, pull := value, "Pull"
+		if f.key != nil {
+			elems, pull = types.TypeString(f.key, qual)+", "+value, "Pull2"
+		}
+		fmt.Fprintf(&b, "\n// %s iterates the sequence returned by %s. It is not safe for concurrent\n", typ, name)
+		fmt.Fprintf(&b, "// use, and must be closed if it is not read to its end.\n")
+		fmt.Fprintf(&b, "type %s struct {\n\tnext  func() (%s, bool)\n\tstop  func()\n", typ, elems)
+		if f.key != nil {
+			fmt.Fprintf(&b, "\tkey   %s\n", types.TypeString(f.key, qual))
+		}
+		fmt.Fprintf(&b, "\tvalue %s\n}\n", value)
+		fmt.Fprintf(&b, "\n// Next advances to the next element, reporting whether there is one.\n")
+		fmt.Fprintf(&b, "func (s *%s) Next() bool {\n\tvar ok bool\n", typ)
+		if f.key != nil {
+			fmt.Fprintf(&b, "\ts.key, s.value, ok = s.next()\n")
+		} else {
+			fmt.Fprintf(&b, "\ts.value, ok = s.next()\n")
+		}
+		fmt.Fprintf(&b, "\treturn ok\n}\n")
+		if f.key != nil {
+			fmt.Fprintf(&b, "\n// Key returns the key of the current element.\n")
+			fmt.Fprintf(&b, "func (s *%s) Key() %s {\n\treturn s.key\n}\n", typ, types.TypeString(f.key, qual))
+		}
+		fmt.Fprintf(&b, "\n// Value returns the current element.\n")
+		fmt.Fprintf(&b, "func (s *%s) Value() %s {\n\treturn s.value\n}\n", typ, value)
+		fmt.Fprintf(&b, "\n// Close stops the sequence.\n")
+		fmt.Fprintf(&b, "func (s *%s) Close() {\n\ts.stop()\n}\n", typ)
+		sig := f.fn.Type().(*types.Signature)
+		params, args := make([]string, sig.Params().Len()), make([]string, sig.Params().Len())
+		for i := range params {
+			// The parameters must not shadow the names used in the body.
+			v := sig.Params().At(i)
+			switch args[i] = v.Name(); args[i] {
+			case "", "_", "iter", "next", "stop", name:
+				args[i] = fmt.Sprintf("p%d", i)
+			}
+			params[i] = args[i] + " " + types.TypeString(v.Type(), qual)
+		}
+		fmt.Fprintf(&b, "\n// %s returns the sequence of %s as a %s.\n", seqAdapter(name), name, typ)
+		fmt.Fprintf(&b, "func %s(%s) *%s {\n", seqAdapter(name), strings.Join(params, ", "), typ)
+		fmt.Fprintf(&b, "\tnext, stop := iter.%s(%s(%s))\n", pull, name, strings.Join(args, ", "))
+		fmt.Fprintf(&b, "\treturn &%s{next: next, stop: stop}\n}\n", typ)
+	}
+	var out bytes.Buffer
+	out.WriteString("// Code generated by gojava. DO NOT EDIT.\n\n")
+	for _, c := range constraints {
+		out.WriteString(c + "\n")
+	}
+	if len(constraints) > 0 {
+		out.WriteString("\n")
+	}
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", p.Name())
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if name := imports[path]; name != path[strings.LastIndex(path, "/")+1:] {
+			fmt.Fprintf(&out, "\t%s %q\n", name, path)
+		} else {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(b.Bytes())
+	return out.Bytes()
+}
+
+// seqFuncOf returns the function of p whose sequence the adapter type named
+// typ iterates.
+func seqFuncOf(p *types.Package, typ string) (seqFunc, bool) {
+	if !strings.HasSuffix(typ, "Seq") {
+		return seqFunc{}, false
+	}
+	name := strings.TrimSuffix(typ, "Seq")
+	for _, f := range findSeqFuncs(p) {
+		if f.fn.Name() == name && p.Scope().Lookup(seqAdapter(name)) != nil {
+			return f, true
+		}
+	}
+	return seqFunc{}, false
+}
+
+// addSeqFuncs rewrites the generated Java file for p at path, adding a method
+// for each function returning an iter.Seq or iter.Seq2 that returns its
+// adapter, and making the adapter function package private.
+func addSeqFuncs(path string, p *types.Package, docs *docFinder) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, start, end, err := classBody(src, p, "")
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	var edits []edit
+	for _, f := range findSeqFuncs(p) {
+		adapter := javaMethodName(seqAdapter(f.fn.Name()))
+		if p.Scope().Lookup(seqAdapter(f.fn.Name())) == nil {
+			continue
+		}
+		for _, at := range memberDecls(src, start, end, adapter) {
+			m := nativeDecl.FindSubmatchIndex(src[at:])
+			if m == nil || m[0] != 0 {
+				continue
+			}
+			sub := func(i int) string {
+				return string(src[at+m[2*i] : at+m[2*i+1]])
+			}
+			indent, modifiers, ret, params := sub(1), sub(2), sub(3), sub(5)
+			var args []string
+			for _, param := range strings.Split(params, ",") {
+				if fs := strings.Fields(param); len(fs) > 0 {
+					args = append(args, fs[len(fs)-1])
+				}
+			}
+			unit := "\t"
+			if strings.HasPrefix(indent, " ") {
+				unit = "    "
+			}
+			var b bytes.Buffer
+			fmt.Fprintf(&b, "%s%snative %s %s(%s);\n\n", indent, strings.Replace(modifiers, "public ", "", 1), ret, adapter, params)
+			if doc := javadocText(docs.doc(f.fn)); doc != "" {
+				fmt.Fprintf(&b, "%s/** %s */\n", indent, doc)
+			}
+			fmt.Fprintf(&b, "%s%s%s %s(%s) {\n", indent, modifiers, ret, javaMethodName(f.fn.Name()), params)
+			fmt.Fprintf(&b, "%s%sreturn %s(%s);\n%s}\n", indent, unit, adapter, strings.Join(args, ", "), indent)
+			edits = append(edits, edit{at, at + m[1], b.String()})
+		}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(path, applyEdits(src, edits), 0600)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const seqLibSrc = `//go:build !windows
+
+package seqlib
+
+import (
+	"iter"
+	"strings"
+)
+
+type Word struct{ Text string }
+
+// Numbers returns the numbers from 0 to n.
+func Numbers(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func Words(s string, next int) iter.Seq2[int, *Word] {
+	return func(yield func(int, *Word) bool) {
+		for i, w := range strings.Fields(s)[next:] {
+			if !yield(i, &Word{w}) {
+				return
+			}
+		}
+	}
+}
+
+func Funcs() iter.Seq[func()] { return nil }
+
+type TakenSeq struct{}
+
+func Taken() iter.Seq[int] { return nil }
+`
+
+const seqMainSrc = `package main
+
+import (
+	"fmt"
+
+	"example.com/seqlib"
+)
+
+func main() {
+	s := seqlib.GojavaNumbers(5)
+	for s.Next() {
+		fmt.Print(s.Value())
+		if s.Value() == 2 {
+			s.Close()
+		}
+	}
+	w := seqlib.GojavaWords("a b c", 1)
+	for w.Next() {
+		fmt.Print(" ", w.Key(), w.Value().Text)
+	}
+	fmt.Println()
+}
+`
+
+func TestSeqOverlay(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	gopath := filepath.Join(tmpDir, "gopath")
+	for name, src := range map[string]string{"seqlib/seq_unix.go": seqLibSrc, "seqmain/main.go": seqMainSrc} {
+		if err := writeJavaFile(filepath.Join(gopath, "src", "example.com", filepath.FromSlash(name)), []byte(src)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldGOPATH, oldEnv, oldModule := build.Default.GOPATH, os.Getenv("GOPATH"), os.Getenv("GO111MODULE")
+	build.Default.GOPATH = gopath
+	os.Setenv("GOPATH", gopath)
+	os.Setenv("GO111MODULE", "off")
+	defer func() {
+		build.Default.GOPATH = oldGOPATH
+		os.Setenv("GOPATH", oldEnv)
+		os.Setenv("GO111MODULE", oldModule)
+	}()
+
+	fset, pkgs, overlay, err := (*buildCache)(nil).loadPackages(tmpDir, []string{"example.com/seqlib"}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(gopath, "src", "example.com", "seqlib", "gojavaseq_unix.go")
+	adapters := string(overlay[file])
+	if len(overlay) != 1 || !strings.HasPrefix(adapters, "// Code generated by gojava. DO NOT EDIT.\n\n//go:build !windows\n\npackage seqlib\n") {
+		t.Fatalf("got overlay %q", overlay)
+	}
+	for _, s := range []string{
+		"func GojavaNumbers(n int) *NumbersSeq {\n\tnext, stop := iter.Pull(Numbers(n))\n",
+		"func GojavaWords(s string, p1 int) *WordsSeq {\n\tnext, stop := iter.Pull2(Words(s, p1))\n",
+		"func (s *WordsSeq) Key() int {",
+		"func (s *WordsSeq) Value() *Word {",
+	} {
+		if !strings.Contains(adapters, s) {
+			t.Errorf("missing %q:\n%s", s, adapters)
+		}
+	}
+	for _, name := range []string{"GojavaFuncs", "GojavaTaken"} {
+		if strings.Contains(adapters, name) {
+			t.Errorf("unexpected adapter %s:\n%s", name, adapters)
+		}
+	}
+	if pkgs[0].Scope().Lookup("NumbersSeq") == nil || pkgs[0].Scope().Lookup("GojavaWords") == nil {
+		t.Errorf("adapters not loaded: %v", pkgs[0].Scope().Names())
+	}
+	if _, ok := seqFuncOf(pkgs[0], "WordsSeq"); !ok {
+		t.Error("WordsSeq is not the adapter of Words")
+	}
+	if _, ok := seqFuncOf(pkgs[0], "TakenSeq"); ok {
+		t.Error("TakenSeq is the adapter of Taken")
+	}
+	if fset.Position(pkgs[0].Scope().Lookup("GojavaNumbers").Pos()).Filename != file {
+		t.Errorf("GojavaNumbers not declared in %s", file)
+	}
+
+	json, err := writeOverlay(filepath.Join(tmpDir, "overlay"), overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", "-overlay="+json, "example.com/seqmain")
+	cmd.Dir = tmpDir
+	out, err := cmd.CombinedOutput()
+	if err != nil || string(out) != "012 0b 1c\n" {
+		t.Errorf("adapters: %v\n%s", err, out)
+	}
+}
+
+const seqJava = `package go.seqlib;
+
+public abstract class Seqlib {
+    public static final class NumbersSeq extends Seq.Proxy {
+        public native boolean next();
+        public native long value();
+        public native void close();
+    }
+
+    public static final class WordsSeq extends Seq.Proxy {
+        public native boolean next();
+        public native long key();
+        public native Word value();
+        public native void close();
+    }
+
+    public static final class Word extends Seq.Proxy {
+    }
+
+    /**
+     * GojavaNumbers returns the sequence of Numbers as a NumbersSeq.
+     */
+    public static native NumbersSeq gojavaNumbers(long n);
+    public static native WordsSeq gojavaWords(String s, long p1);
+}
+`
+
+func TestAddSeqFuncs(t *testing.T) {
+	src := seqLibSrc[strings.Index(seqLibSrc, "package"):] + `
+type NumbersSeq struct{}
+
+func (s *NumbersSeq) Next() bool  { return false }
+func (s *NumbersSeq) Value() int  { return 0 }
+func (s *NumbersSeq) Close()      {}
+func GojavaNumbers(n int) *NumbersSeq { return nil }
+
+type WordsSeq struct{}
+
+func (s *WordsSeq) Next() bool    { return false }
+func (s *WordsSeq) Key() int      { return 0 }
+func (s *WordsSeq) Value() *Word  { return nil }
+func (s *WordsSeq) Close()        {}
+func GojavaWords(s string, p1 int) *WordsSeq { return nil }
+`
+	p, docs := typeCheckFile(t, strings.Replace(src, "package seqlib", "package testpkg", 1))
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	java := strings.Replace(strings.Replace(seqJava, "Seqlib", "Testpkg", 1), "go.seqlib", "go.testpkg", 1)
+	if err := ioutil.WriteFile(path, []byte(java), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addIterators(path, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := addSeqFuncs(path, p, docs); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(d)
+	for _, s := range []string{
+		"class NumbersSeq extends Seq.Proxy implements Iterable<Long>, AutoCloseable {",
+		"class WordsSeq extends Seq.Proxy implements Iterable<java.util.Map.Entry<Long, go.testpkg.Testpkg.Word>>, AutoCloseable {",
+		"return new java.util.AbstractMap.SimpleImmutableEntry<Long, go.testpkg.Testpkg.Word>(WordsSeq.this.key(), WordsSeq.this.value());",
+		"    static native NumbersSeq gojavaNumbers(long n);\n\n    /** Numbers returns the numbers from 0 to n. */\n    public static NumbersSeq numbers(long n) {\n        return gojavaNumbers(n);\n    }\n",
+		"    static native WordsSeq gojavaWords(String s, long p1);\n\n    public static WordsSeq words(String s, long p1) {\n        return gojavaWords(s, p1);\n    }\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("missing %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "public static native") {
+		t.Errorf("adapter functions are public:\n%s", out)
+	}
+
+	anns, err := exposeAnnotations(p, []string{"Numbers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exposed := string(annotateJava(d, p, anns))
+	for _, s := range []string{"public static final class NumbersSeq", "static native NumbersSeq gojavaNumbers(long n);", "public static NumbersSeq numbers(long n) {"} {
+		if !strings.Contains(exposed, s) {
+			t.Errorf("missing %q:\n%s", s, exposed)
+		}
+	}
+	for _, s := range []string{"gojavaWords", "words(", "public static final class WordsSeq"} {
+		if strings.Contains(exposed, s) {
+			t.Errorf("unexpected %q:\n%s", s, exposed)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// service is a bound function run as a go.GoService.
+type service struct {
+	pkg *types.Package
+	fn  string
+}
+
+// serviceFunc reports whether fn has the signature func(context.Context) error
+// of a long-running service, which runs until it fails or its context is
+// cancelled.
+func serviceFunc(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	return sig.Recv() == nil && sig.Params().Len() == 1 && sig.Params().At(0).Type().String() == "context.Context" &&
+		sig.Results().Len() == 1 && isError(sig.Results().At(0).Type())
+}
+
+// findServices returns the exported functions of pkgs with the signature of a
+// service, other than health checks. If exposed is not nil, only the functions
+// exposed from each package are returned.
+func findServices(pkgs []*types.Package, exposed map[*types.Package][]string, docs *docFinder) []service {
+	var services []service
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok || !fn.Exported() || !serviceFunc(fn) || isHealthCheck(fn, docs) {
+				continue
+			}
+			if exposed != nil && !containsString(exposed[p], name) {
+				continue
+			}
+			services = append(services, service{p, name})
+		}
+	}
+	return services
+}
+
+// containsString reports whether l contains s.
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// genServices writes the Go and C code to bindDir running the services of
+// pkgs, and go.GoService and a subclass of it for each service to javaDir. It
+// returns the paths of the Java files, or nil if pkgs have no services.
+func genServices(bindDir, javaDir string, pkgs []*types.Package, exposed map[*types.Package][]string, docs *docFinder) ([]string, error) {
+	services := findServices(pkgs, exposed, docs)
+	if len(services) == 0 {
+		return nil, nil
+	}
+	var imports, funcs bytes.Buffer
+	seen := make(map[*types.Package]int)
+	for _, s := range services {
+		i, ok := seen[s.pkg]
+		if !ok {
+			i = len(seen)
+			seen[s.pkg] = i
+			fmt.Fprintf(&imports, "\tsvc%d %q\n", i, s.pkg.Path())
+		}
+		fmt.Fprintf(&funcs, "\tsvc%d.%s,\n", i, s.fn)
+	}
+	goSrc := fmt.Sprintf(servicesGo, imports.String(), funcs.String())
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_services.go"), []byte(goSrc), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_services.c"), []byte(servicesC), 0600); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(javaDir, "GoService.java")
+	if err := writeJavaFile(path, []byte(goServiceJava)); err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	for i, s := range services {
+		class := strings.Title(s.fn) + "Service"
+		path := filepath.Join(javaDir, s.pkg.Name(), class+".java")
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s.%s: class %s.%s already exists", s.pkg.Path(), s.fn, javaPkgName(s.pkg), class)
+		}
+		verbosef("Generating service %s.%s\n", javaPkgName(s.pkg), class)
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "// Java service for %s.%s generated by gojava.\n", s.pkg.Path(), s.fn)
+		fmt.Fprintf(&b, "package %s;\n\n", javaPkgName(s.pkg))
+		fmt.Fprintf(&b, "// %s runs %s.%s on a goroutine until stopped.\n", class, s.pkg.Name(), s.fn)
+		fmt.Fprintf(&b, "public final class %s extends go.GoService {\n", class)
+		fmt.Fprintf(&b, "\tpublic %s() {\n\t\tsuper(%d, %q);\n\t}\n}\n", class, i, s.pkg.Path()+"."+s.fn)
+		if err := writeJavaFile(path, b.Bytes()); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+const servicesGo = `package gojava_bind
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+%s)
+
+// gojavaServiceFuncs are the functions run by the subclasses of go.GoService.
+var gojavaServiceFuncs = []func(context.Context) error{
+%s}
+
+// gojavaService is a running service.
+type gojavaService struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+var (
+	gojavaServicesMu sync.Mutex
+	gojavaServices   = make(map[int64]*gojavaService)
+	gojavaServiceID  int64
+)
+
+func gojavaLookupService(id C.longlong) *gojavaService {
+	gojavaServicesMu.Lock()
+	defer gojavaServicesMu.Unlock()
+	return gojavaServices[int64(id)]
+}
+
+//export gojava_service_start
+func gojava_service_start(fn C.int) C.longlong {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &gojavaService{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	gojavaServicesMu.Lock()
+	gojavaServiceID++
+	id := gojavaServiceID
+	gojavaServices[id] = s
+	gojavaServicesMu.Unlock()
+	go func() {
+		defer close(s.done)
+		defer func() {
+			if r := recover(); r != nil {
+				s.err = fmt.Errorf("panic: %%v", r)
+			}
+		}()
+		s.err = gojavaServiceFuncs[fn](ctx)
+	}()
+	return C.longlong(id)
+}
+
+//export gojava_service_stop
+func gojava_service_stop(id C.longlong) {
+	if s := gojavaLookupService(id); s != nil {
+		s.cancel()
+	}
+}
+
+// gojava_service_wait blocks until the service returns, and returns its error,
+// or nil if it returned nil or the error of its context after being stopped.
+//
+//export gojava_service_wait
+func gojava_service_wait(id C.longlong) *C.char {
+	s := gojavaLookupService(id)
+	if s == nil {
+		return nil
+	}
+	<-s.done
+	gojavaServicesMu.Lock()
+	delete(gojavaServices, int64(id))
+	gojavaServicesMu.Unlock()
+	stopped := s.ctx.Err() != nil
+	s.cancel()
+	if s.err == nil || (stopped && s.err == s.ctx.Err()) {
+		return nil
+	}
+	return C.CString(s.err.Error())
+}
+`
+
+const servicesC = `#include <jni.h>
+#include <stdlib.h>
+#include "_cgo_export.h"
+
+JNIEXPORT jlong JNICALL Java_go_GoService_start0(JNIEnv *env, jclass clazz, jint fn) {
+	return gojava_service_start(fn);
+}
+
+JNIEXPORT void JNICALL Java_go_GoService_stop0(JNIEnv *env, jclass clazz, jlong id) {
+	gojava_service_stop(id);
+}
+
+JNIEXPORT jstring JNICALL Java_go_GoService_wait0(JNIEnv *env, jclass clazz, jlong id) {
+	char *err = gojava_service_wait(id);
+	if (err == NULL) {
+		return NULL;
+	}
+	jstring s = (*env)->NewStringUTF(env, err);
+	free(err);
+	return s;
+}
+`
+
+const goServiceJava = `package go;
+
+import java.util.List;
+import java.util.concurrent.CopyOnWriteArrayList;
+import java.util.concurrent.TimeUnit;
+
+// GoService runs a bound Go function with the signature
+// func(ctx context.Context) error on a goroutine. start runs it, and stop
+// cancels its context. A service runs once: it cannot be started again after
+// it terminates. A running service is registered with go.GoHealth: it is
+// unhealthy once it fails, and only ready while running.
+public abstract class GoService implements GoWaitHandle, AutoCloseable {
+	// State is the lifecycle state of a service.
+	public enum State {
+		NEW, RUNNING, STOPPING, TERMINATED, FAILED
+	}
+
+	// Listener is notified when a service terminates. The methods are called on
+	// the thread waiting for the Go function.
+	public static abstract class Listener {
+		// terminated is called when the function returns without an error.
+		public void terminated(GoService service) {}
+
+		// failed is called when the function returns an error, or panics.
+		public void failed(GoService service, Throwable failure) {}
+	}
+
+	private final int fn;
+	private final String name;
+	private final List<Listener> listeners = new CopyOnWriteArrayList<Listener>();
+	private State state = State.NEW;
+	private Throwable failure;
+	private long id;
+
+	private final GoHealth.Check liveness = new GoHealth.Check() {
+		@Override
+		public String name() {
+			return name;
+		}
+
+		@Override
+		public boolean readiness() {
+			return false;
+		}
+
+		@Override
+		public String check() {
+			Throwable f = failure();
+			return f == null ? null : f.getMessage();
+		}
+	};
+
+	private final GoHealth.Check readiness = new GoHealth.Check() {
+		@Override
+		public String name() {
+			return name + " (ready)";
+		}
+
+		@Override
+		public boolean readiness() {
+			return true;
+		}
+
+		@Override
+		public String check() {
+			State s = state();
+			return s == State.RUNNING ? null : s.toString();
+		}
+	};
+
+	protected GoService(int fn, String name) {
+		this.fn = fn;
+		this.name = name;
+	}
+
+	// name returns the Go function the service runs.
+	public final String name() {
+		return name;
+	}
+
+	public final void addListener(Listener l) {
+		listeners.add(l);
+	}
+
+	public final synchronized State state() {
+		return state;
+	}
+
+	// isRunning reports whether the function is running and not being stopped.
+	public final synchronized boolean isRunning() {
+		return state == State.RUNNING;
+	}
+
+	// failure returns the error the service failed with, or null.
+	public final synchronized Throwable failure() {
+		return failure;
+	}
+
+	// start runs the function on a new goroutine.
+	public final synchronized GoService start() {
+		if (state != State.NEW) {
+			throw new IllegalStateException(name + ": service already started");
+		}
+		Go.load();
+		id = start0(fn);
+		state = State.RUNNING;
+		GoHealth.register(liveness);
+		GoHealth.register(readiness);
+		Thread t = new Thread(new Runnable() {
+			@Override
+			public void run() {
+				terminate(wait0(id));
+			}
+		}, "gojava-service-" + name);
+		t.setDaemon(true);
+		t.start();
+		return this;
+	}
+
+	// stop cancels the context of the function. It does not wait for the
+	// function to return.
+	public final synchronized GoService stop() {
+		if (state == State.NEW) {
+			state = State.TERMINATED;
+			notifyAll();
+		} else if (state == State.RUNNING) {
+			state = State.STOPPING;
+			stop0(id);
+		}
+		return this;
+	}
+
+	// close stops the service and waits for the function to return, throwing
+	// its failure if it failed.
+	@Override
+	public final void close() throws Exception {
+		stop().await();
+	}
+
+	// await blocks until the service terminates, throwing its failure if it
+	// failed.
+	@Override
+	public final void await() throws Exception {
+		await(Long.MAX_VALUE, TimeUnit.NANOSECONDS);
+	}
+
+	// await waits at most timeout for the service to terminate, returning false
+	// if it is still running. It throws the failure of a failed service.
+	public final synchronized boolean await(long timeout, TimeUnit unit) throws Exception {
+		long deadline = System.nanoTime() + unit.toNanos(timeout);
+		while (state != State.TERMINATED && state != State.FAILED) {
+			long left = deadline - System.nanoTime();
+			if (timeout != Long.MAX_VALUE && left <= 0) {
+				return false;
+			}
+			if (timeout == Long.MAX_VALUE) {
+				wait();
+			} else {
+				TimeUnit.NANOSECONDS.timedWait(this, left);
+			}
+		}
+		if (failure instanceof Exception) {
+			throw (Exception) failure;
+		}
+		return true;
+	}
+
+	private void terminate(String err) {
+		synchronized (this) {
+			if (err == null) {
+				state = State.TERMINATED;
+			} else {
+				state = State.FAILED;
+				failure = new RuntimeException(name + ": " + err);
+			}
+			notifyAll();
+		}
+		if (err == null) {
+			GoHealth.unregister(liveness);
+			GoHealth.unregister(readiness);
+		}
+		for (Listener l : listeners) {
+			if (err == null) {
+				l.terminated(this);
+			} else {
+				l.failed(this, failure);
+			}
+		}
+	}
+
+	private static native long start0(int fn);
+	private static native void stop0(long id);
+	private static native String wait0(long id);
+}
+`
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const servicesSrc = `package testpkg
+
+import "context"
+
+func Run(ctx context.Context) error { return nil }
+
+func Serve(ctx context.Context) error { return nil }
+
+func NoError(ctx context.Context) {}
+
+func run(ctx context.Context) error { return nil }
+`
+
+func TestGenServices(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "services.go", servicesSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	p, err := conf.Check("example.com/testpkg", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*types.Package{p}
+
+	exposed := map[*types.Package][]string{p: {"Serve"}}
+	if s := findServices(pkgs, exposed, newDocFinder(fset)); len(s) != 1 || s[0].fn != "Serve" {
+		t.Errorf("exposed services: got %+v", s)
+	}
+
+	files, err := genServices(tmpDir, tmpDir, pkgs, nil, newDocFinder(fset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || filepath.Base(files[1]) != "RunService.java" || filepath.Base(files[2]) != "ServeService.java" {
+		t.Fatalf("got files %v", files)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(tmpDir, "gojava_services.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"\tsvc0 \"example.com/testpkg\"\n", "\tsvc0.Run,\n\tsvc0.Serve,\n", "//export gojava_service_wait\n"} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("gojava_services.go missing %q:\n%s", s, d)
+		}
+	}
+	d, err = ioutil.ReadFile(files[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"package go.testpkg;", "public final class ServeService extends go.GoService {", "super(1, \"example.com/testpkg.Serve\");"} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("missing %q:\n%s", s, d)
+		}
+	}
+
+	if _, err := genServices(tmpDir, tmpDir, pkgs, nil, newDocFinder(fset)); err == nil {
+		t.Error("expected an error for an existing class")
+	}
+	if files, err := genServices(tmpDir, tmpDir, []*types.Package{typeCheck(t, "package testpkg\n")}, nil, newDocFinder(fset)); err != nil || files != nil {
+		t.Errorf("got %v, %v for a package without services", files, err)
+	}
+}
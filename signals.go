@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exitInterrupted is the exit status of gojava when a build is interrupted.
+const exitInterrupted = 130
+
+// buildCtx is cancelled when gojava is interrupted, killing any running
+// subprocesses.
+var buildCtx = context.Background()
+
+// handleSignals cancels buildCtx on SIGINT or SIGTERM, so the build stops and
+// cleans up its temporary files. A second signal exits immediately.
+func handleSignals() {
+	ctx, cancel := context.WithCancel(context.Background())
+	buildCtx = ctx
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		fmt.Fprintln(os.Stderr, "interrupted, cleaning up")
+		cancel()
+		<-sigs
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// interrupted reports whether the build was interrupted by a signal.
+func interrupted() bool {
+	return buildCtx.Err() != nil
+}
+
+// withTimeout runs the build stage f with buildCtx limited to timeout, so any
+// subprocess still running when it expires is killed. A timeout of 0 means no
+// limit.
+func withTimeout(stage string, timeout time.Duration, f func() error) error {
+	if timeout <= 0 {
+		return f()
+	}
+	parent := buildCtx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	buildCtx = ctx
+	defer func() {
+		cancel()
+		buildCtx = parent
+	}()
+	err := f()
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %v: %v", stage, timeout, err)
+	}
+	return err
+}
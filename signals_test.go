@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	start := time.Now()
+	err := withTimeout("sleep", 50*time.Millisecond, func() error {
+		return runCommand("sleep", "10")
+	})
+	if err == nil || !strings.Contains(err.Error(), "sleep timed out after 50ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("subprocess not killed, took %v", d)
+	}
+	if buildCtx.Err() != nil {
+		t.Error("buildCtx not restored after timeout")
+	}
+	if err := withTimeout("true", 0, func() error { return runCommand("true") }); err != nil {
+		t.Errorf("unexpected error without timeout: %v", err)
+	}
+}
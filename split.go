@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// nestedType is a type declared directly in the body of a generated package class.
+type nestedType struct {
+	name       string
+	iface      bool
+	start, end int
+}
+
+var typeDecl = regexp.MustCompile(`\b(class|interface)\s+(\w+)`)
+
+// skipLiteral returns the index of the last byte of the comment, string or
+// character literal starting at src[i], or i if there is none.
+func skipLiteral(src []byte, i int) int {
+	switch {
+	case src[i] == '"' || src[i] == '\'':
+		for j := i + 1; j < len(src); j++ {
+			if src[j] == '\\' {
+				j++
+			} else if src[j] == src[i] {
+				return j
+			}
+		}
+	case i+1 < len(src) && src[i] == '/' && src[i+1] == '/':
+		if j := strings.IndexByte(string(src[i:]), '\n'); j >= 0 {
+			return i + j
+		}
+	case i+1 < len(src) && src[i] == '/' && src[i+1] == '*':
+		if j := strings.Index(string(src[i+2:]), "*/"); j >= 0 {
+			return i + j + 3
+		}
+	default:
+		return i
+	}
+	return len(src) - 1
+}
+
+// findNestedTypes returns the types declared directly inside the first
+// top level type declared in src. Each type spans src[start:end], including
+// any preceding doc comment.
+func findNestedTypes(src []byte) []nestedType {
+	var types []nestedType
+	depth, memberStart := 0, 0
+	var cur *nestedType
+	for i := 0; i < len(src); i++ {
+		if j := skipLiteral(src, i); j != i {
+			i = j
+			continue
+		}
+		switch src[i] {
+		case '{':
+			depth++
+			if depth == 1 {
+				memberStart = i + 1
+			} else if depth == 2 && cur == nil {
+				if m := typeDecl.FindSubmatch(src[memberStart:i]); m != nil {
+					cur = &nestedType{name: string(m[2]), iface: string(m[1]) == "interface", start: memberStart}
+				}
+			}
+		case '}':
+			depth--
+			if depth == 1 {
+				if cur != nil {
+					cur.end = i + 1
+					types = append(types, *cur)
+					cur = nil
+				}
+				memberStart = i + 1
+			} else if depth == 0 {
+				return types
+			}
+		case ';':
+			if depth == 1 {
+				memberStart = i + 1
+			}
+		}
+	}
+	return types
+}
+
+// splitPackageClass moves the types nested in the generated Java class for p at
+// path into their own files in the same Java package, leaving the package level
+// functions and variables in the original class as a facade. It returns the
+// paths of the new files and the names of the moved types.
+func splitPackageClass(path string, p *types.Package) ([]string, []string, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	class := javaClassName(p)
+	decl := regexp.MustCompile(`(?m)^.*\bclass\s+` + class + `\b`).FindIndex(src)
+	if decl == nil {
+		return nil, nil, fmt.Errorf("%s: class %s not found", path, class)
+	}
+	header := src[:decl[0]]
+
+	var files, names []string
+	facade := make([]byte, 0, len(src))
+	last := decl[0]
+	facade = append(facade, src[:last]...)
+	for _, t := range findNestedTypes(src[decl[0]:]) {
+		start, end := decl[0]+t.start, decl[0]+t.end
+		if t.name == class {
+			continue
+		}
+		body := strings.Replace(string(src[start:end]), " static ", " ", 1)
+		body = strings.TrimLeft(body, "\n")
+		if !t.iface {
+			b, err := insertIntoClass([]byte(body), t.name, fmt.Sprintf("\n\t\tstatic { %s.touch(); }\n", class))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", path, err)
+			}
+			body = string(b)
+		}
+		typePath := filepath.Join(filepath.Dir(path), p.Name(), t.name+".java")
+		if err := writeJavaFile(typePath, append(append([]byte{}, header...), body+"\n"...)); err != nil {
+			return nil, nil, err
+		}
+		facade = append(facade, src[last:start]...)
+		last = end
+		files, names = append(files, typePath), append(names, t.name)
+	}
+	facade = append(facade, src[last:]...)
+	return files, names, ioutil.WriteFile(path, facade, 0600)
+}
+
+// renameSplitTypes rewrites references to the types in names, moved out of
+// the class generated for p, in the Java and C files in paths.
+func renameSplitTypes(p *types.Package, names []string, paths []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	class, pkg := javaClassName(p), javaPkgName(p)
+	quoted, mangled := make([]string, len(names)), make([]string, len(names))
+	for i, n := range names {
+		quoted[i], mangled[i] = regexp.QuoteMeta(n), jniMangle(n)
+	}
+	alt := strings.Join(quoted, "|")
+	javaRef := regexp.MustCompile(`\b(` + regexp.QuoteMeta(pkg) + `\.)?` + class + `\.(` + alt + `)` + identEnd)
+	classRef := regexp.MustCompile(regexp.QuoteMeta(strings.Replace(pkg, ".", "/", -1)+"/"+class) + `\$(` + alt + `)` + identEnd)
+	jniRef := regexp.MustCompile(`\b(Java_` + jniMangle(pkg) + `_)` + jniMangle(class) + `_00024(` + strings.Join(mangled, "|") + `)_([a-zA-Z0])`)
+	for _, path := range paths {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(path, ".java") {
+			d = javaRef.ReplaceAll(d, []byte("${1}${2}${3}"))
+		} else {
+			d = classRef.ReplaceAll(d, []byte(strings.Replace(pkg, ".", "/", -1)+"/${1}${2}"))
+			d = jniRef.ReplaceAll(d, []byte("${1}${2}_${3}"))
+		}
+		if err := ioutil.WriteFile(path, d, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const splitJava = `// Java class go.testpkg.Testpkg is a proxy for talking to a Go program.
+package go.testpkg;
+
+import go.Seq;
+
+public abstract class Testpkg {
+    static { Seq.touch(); }
+
+    public static void touch() {}
+
+    public static final class S extends Seq.Proxy {
+        /* "{" is not a brace */
+        public final native long getX();
+    }
+
+    public interface I extends go.Seq.Object {
+        public void f(Testpkg.S s);
+    }
+
+    public static native Testpkg.S newS();
+}
+`
+
+const splitC = `jclass proxy_class_testpkg_S = (*env)->FindClass(env, "go/testpkg/Testpkg$S");
+JNIEXPORT jlong JNICALL Java_go_testpkg_Testpkg_00024S_getX(JNIEnv *env, jobject this) {}
+JNIEXPORT jobject JNICALL Java_go_testpkg_Testpkg_newS(JNIEnv *env, jclass clazz) {}
+`
+
+func TestSplitPackageClass(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	javaPath, cPath := filepath.Join(tmpDir, "Testpkg.java"), filepath.Join(tmpDir, "java_testpkg.c")
+	if err := ioutil.WriteFile(javaPath, []byte(splitJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cPath, []byte(splitC), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := typeCheck(t, "package testpkg")
+	files, names, err := splitPackageClass(javaPath, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "S,I" || len(files) != 2 {
+		t.Fatalf("unexpected split: %v %v", names, files)
+	}
+	if err := renameSplitTypes(p, names, append(files, javaPath, cPath)); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		javaPath: {"public static native S newS();"},
+		files[0]: {"package go.testpkg;", "public final class S extends Seq.Proxy {", "static { Testpkg.touch(); }"},
+		files[1]: {"public interface I extends", "public void f(S s);"},
+		cPath:    {`"go/testpkg/S"`, "Java_go_testpkg_S_getX(", "Java_go_testpkg_Testpkg_newS("},
+	}
+	for path, want := range expected {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+	d, err := ioutil.ReadFile(javaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "class S") || strings.Contains(string(d), "interface I") {
+		t.Errorf("nested types not removed from facade:\n%s", d)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// springAutoConfiguration is the name of the auto-configuration class written
+// by writeSpringBoot.
+const springAutoConfiguration = "GoAutoConfiguration"
+
+// modulePackage returns the Java package of the module integrating the jar
+// target with framework, such as go.spring.mylib for mylib.jar.
+func modulePackage(framework, target string) string {
+	base := strings.TrimSuffix(filepath.Base(target), ".jar")
+	var b strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "lib" + name
+	}
+	return "go." + framework + "." + javaIdent(name)
+}
+
+// writeSpringBoot writes the sources of a Spring Boot auto-configuration
+// module for the jar cfg.target to dir. It binds the config structs of pkgs to
+// @ConfigurationProperties, defines beans starting the services of pkgs, and
+// a HealthIndicator reporting go.GoHealth if health is set.
+func writeSpringBoot(dir string, cfg *config, pkgs []*types.Package, services []service, health bool, docs *docFinder) error {
+	configs, err := findConfigStructs(pkgs, docs, cfg.split)
+	if err != nil {
+		return err
+	}
+	pkg := modulePackage("spring", cfg.target)
+	javaDir := filepath.Join(append([]string{dir, "src", "main", "java"}, strings.Split(pkg, ".")...)...)
+	resDir := filepath.Join(dir, "src", "main", "resources", "META-INF")
+	if err := os.MkdirAll(filepath.Join(resDir, "spring"), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(javaDir, 0755); err != nil {
+		return err
+	}
+	verbosef("Writing Spring Boot module %s to %s\n", pkg, dir)
+
+	var props []string
+	for _, c := range configs {
+		class := javaClassName(c.pkg) + c.name + "Properties"
+		props = append(props, class)
+		if err := ioutil.WriteFile(filepath.Join(javaDir, class+".java"), springProperties(pkg, class, c), 0644); err != nil {
+			return err
+		}
+	}
+	src := springConfiguration(pkg, props, configs, services, health)
+	if err := ioutil.WriteFile(filepath.Join(javaDir, springAutoConfiguration+".java"), src, 0644); err != nil {
+		return err
+	}
+	qualified := pkg + "." + springAutoConfiguration
+	// spring.factories registers the auto-configuration with Spring Boot 2,
+	// and AutoConfiguration.imports with Spring Boot 2.7 and later.
+	factories := "org.springframework.boot.autoconfigure.EnableAutoConfiguration=" + qualified + "\n"
+	if err := ioutil.WriteFile(filepath.Join(resDir, "spring.factories"), []byte(factories), 0644); err != nil {
+		return err
+	}
+	imports := filepath.Join(resDir, "spring", "org.springframework.boot.autoconfigure.AutoConfiguration.imports")
+	return ioutil.WriteFile(imports, []byte(qualified+"\n"), 0644)
+}
+
+// springProperties returns the @ConfigurationProperties class named class for
+// the config struct c.
+func springProperties(pkg, class string, c configStruct) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Spring Boot configuration properties for %s.%s generated by gojava.\n", c.pkg.Path(), c.name)
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+	b.WriteString("import org.springframework.boot.context.properties.ConfigurationProperties;\n\n")
+	fmt.Fprintf(&b, "// %s holds the %s.* properties configuring %s.\n", class, c.prefix, c.class)
+	fmt.Fprintf(&b, "@ConfigurationProperties(prefix = %q)\n", c.prefix)
+	fmt.Fprintf(&b, "public class %s {\n", class)
+	for _, f := range c.fields {
+		fmt.Fprintf(&b, "\tprivate %s %s;\n", boxedJavaType(f.javaType), javaIdent(lowerCamel(f.name)))
+	}
+	for _, f := range c.fields {
+		field, prop := javaIdent(lowerCamel(f.name)), strings.Title(lowerCamel(f.name))
+		fmt.Fprintf(&b, "\n\tpublic %s get%s() {\n\t\treturn %s;\n\t}\n", boxedJavaType(f.javaType), prop, field)
+		fmt.Fprintf(&b, "\n\tpublic void set%s(%s v) {\n\t\t%s = v;\n\t}\n", prop, boxedJavaType(f.javaType), field)
+	}
+	fmt.Fprintf(&b, "\n\t// to%s returns the Go config with the properties that are set.\n", c.name)
+	fmt.Fprintf(&b, "\tpublic %s to%s() {\n\t\t%s c = new %s();\n", c.class, c.name, c.class, c.class)
+	for _, f := range c.fields {
+		field := javaIdent(lowerCamel(f.name))
+		fmt.Fprintf(&b, "\t\tif (%s != null) {\n\t\t\tc.set%s(%s);\n\t\t}\n", field, f.name, field)
+	}
+	b.WriteString("\t\treturn c;\n\t}\n}\n")
+	return b.Bytes()
+}
+
+// boxedJavaType returns the boxed type of the Java primitive type t, or t if
+// it is not primitive.
+func boxedJavaType(t string) string {
+	switch t {
+	case "boolean":
+		return "Boolean"
+	case "long":
+		return "Long"
+	case "int":
+		return "Integer"
+	case "short":
+		return "Short"
+	case "byte":
+		return "Byte"
+	case "double":
+		return "Double"
+	case "float":
+		return "Float"
+	}
+	return t
+}
+
+// springConfiguration returns the auto-configuration class, enabling the
+// property classes props of configs, and defining beans for configs and
+// services.
+func springConfiguration(pkg string, props []string, configs []configStruct, services []service, health bool) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Spring Boot auto-configuration generated by gojava.\n")
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+	b.WriteString("import org.springframework.boot.autoconfigure.condition.ConditionalOnClass;\n")
+	b.WriteString("import org.springframework.boot.autoconfigure.condition.ConditionalOnMissingBean;\n")
+	b.WriteString("import org.springframework.boot.context.properties.EnableConfigurationProperties;\n")
+	b.WriteString("import org.springframework.context.annotation.Bean;\n")
+	b.WriteString("import org.springframework.context.annotation.Configuration;\n\n")
+	fmt.Fprintf(&b, "// %s defines beans for the Go config structs and services\n// of the jar.\n", springAutoConfiguration)
+	b.WriteString("@Configuration(proxyBeanMethods = false)\n")
+	if len(props) > 0 {
+		fmt.Fprintf(&b, "@EnableConfigurationProperties({%s.class})\n", strings.Join(props, ".class, "))
+	}
+	fmt.Fprintf(&b, "public class %s {\n", springAutoConfiguration)
+	first := true
+	sep := func() {
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+	}
+	for i, c := range configs {
+		sep()
+		fmt.Fprintf(&b, "\t@Bean\n\t@ConditionalOnMissingBean\n")
+		fmt.Fprintf(&b, "\tpublic %s %s(%s properties) {\n", c.class, javaIdent(c.pkg.Name()+c.name), props[i])
+		fmt.Fprintf(&b, "\t\treturn properties.to%s();\n\t}\n", c.name)
+	}
+	for _, s := range services {
+		class := javaPkgName(s.pkg) + "." + strings.Title(s.fn) + "Service"
+		sep()
+		// close stops the service and waits for it to return.
+		fmt.Fprintf(&b, "\t@Bean(destroyMethod = \"close\")\n\t@ConditionalOnMissingBean\n")
+		fmt.Fprintf(&b, "\tpublic %s %s() {\n", class, javaIdent(s.pkg.Name()+strings.Title(s.fn)+"Service"))
+		fmt.Fprintf(&b, "\t\t%s s = new %s();\n\t\ts.start();\n\t\treturn s;\n\t}\n", class, class)
+	}
+	if health {
+		sep()
+		b.WriteString(springHealthConfiguration)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+const springHealthConfiguration = `	// GoHealthConfiguration reports go.GoHealth through Spring Boot Actuator.
+	@Configuration(proxyBeanMethods = false)
+	@ConditionalOnClass(name = "org.springframework.boot.actuate.health.HealthIndicator")
+	static class GoHealthConfiguration {
+		@Bean
+		@ConditionalOnMissingBean(name = "goHealthIndicator")
+		public org.springframework.boot.actuate.health.HealthIndicator goHealthIndicator() {
+			return new org.springframework.boot.actuate.health.HealthIndicator() {
+				@Override
+				public org.springframework.boot.actuate.health.Health health() {
+					go.GoHealth.Status s = go.GoHealth.status();
+					org.springframework.boot.actuate.health.Health.Builder b;
+					if (!s.isHealthy()) {
+						b = org.springframework.boot.actuate.health.Health.down();
+					} else if (!s.isReady()) {
+						b = org.springframework.boot.actuate.health.Health.outOfService();
+					} else {
+						b = org.springframework.boot.actuate.health.Health.up();
+					}
+					return b.withDetails(s.reasons()).build();
+				}
+			};
+		}
+	}
+`
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const springSrc = `package testpkg
+
+import "context"
+
+//gojava:config myapp.server
+type Config struct {
+	ListenAddr string
+	Port       int
+}
+
+func Run(ctx context.Context) error { return nil }
+`
+
+func TestWriteSpringBoot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if got := modulePackage("spring", "/out/my-lib.jar"); got != "go.spring.mylib" {
+		t.Errorf("modulePackage = %q", got)
+	}
+
+	p, docs := typeCheckFile(t, springSrc)
+	pkgs := []*types.Package{p}
+	cfg := &config{target: "/out/server.jar"}
+	if err := writeSpringBoot(tmpDir, cfg, pkgs, findServices(pkgs, nil, docs), true, docs); err != nil {
+		t.Fatal(err)
+	}
+	javaDir := filepath.Join(tmpDir, "src", "main", "java", "go", "spring", "server")
+	for path, exp := range map[string][]string{
+		filepath.Join(javaDir, "GoAutoConfiguration.java"): {
+			"package go.spring.server;",
+			"@EnableConfigurationProperties({TestpkgConfigProperties.class})\npublic class GoAutoConfiguration {",
+			"\tpublic go.testpkg.Testpkg.Config testpkgConfig(TestpkgConfigProperties properties) {\n\t\treturn properties.toConfig();",
+			"\t@Bean(destroyMethod = \"close\")\n\t@ConditionalOnMissingBean\n\tpublic go.testpkg.RunService testpkgRunService() {",
+			"\tstatic class GoHealthConfiguration {",
+		},
+		filepath.Join(javaDir, "TestpkgConfigProperties.java"): {
+			"@ConfigurationProperties(prefix = \"myapp.server\")\npublic class TestpkgConfigProperties {",
+			"\tprivate String listenAddr;\n\tprivate Long port;\n",
+			"\tpublic void setListenAddr(String v) {",
+			"\t\tif (port != null) {\n\t\t\tc.setPort(port);\n\t\t}\n",
+		},
+		filepath.Join(tmpDir, "src", "main", "resources", "META-INF", "spring.factories"): {
+			"org.springframework.boot.autoconfigure.EnableAutoConfiguration=go.spring.server.GoAutoConfiguration\n",
+		},
+		filepath.Join(tmpDir, "src", "main", "resources", "META-INF", "spring", "org.springframework.boot.autoconfigure.AutoConfiguration.imports"): {
+			"go.spring.server.GoAutoConfiguration\n",
+		},
+	} {
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range exp {
+			if !strings.Contains(string(d), s) {
+				t.Errorf("%s missing %q:\n%s", filepath.Base(path), s, d)
+			}
+		}
+	}
+}
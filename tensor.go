@@ -0,0 +1,227 @@
+package main
+
+import "fmt"
+
+// tensorClasses returns the names and sources of go.GoFloatTensor and
+// go.GoDoubleTensor.
+func tensorClasses() []struct{ name, src string } {
+	var classes []struct{ name, src string }
+	for _, t := range []struct {
+		elem, name string
+		bytes      int
+		goType     string
+	}{
+		{"float", "Float", 4, "float32"},
+		{"double", "Double", 8, "float64"},
+	} {
+		name := "Go" + t.name + "Tensor"
+		classes = append(classes, struct{ name, src string }{name, fmt.Sprintf(goTensorJava, t.elem, name, t.name, t.bytes, t.goType)})
+	}
+	return classes
+}
+
+// goTensorJava is the source of a tensor class named %[2]s with elements of
+// the Java type %[1]s and Go type %[5]s, %[4]d bytes long.
+const goTensorJava = `package go;
+
+import java.nio.ByteBuffer;
+import java.nio.ByteOrder;
+import java.nio.%[3]sBuffer;
+import java.util.Arrays;
+
+// %[2]s is a multi-dimensional array of %[1]ss stored in a flat array in
+// row-major order, the layout of a Go []%[5]s with a shape as used by gonum
+// and gorgonia. Bound functions take data() and shape() as a []%[5]s and a
+// []int64.
+public final class %[2]s {
+	private final %[1]s[] data;
+	private final long[] shape;
+
+	// %[2]s wraps data, in row-major order, without copying it.
+	public %[2]s(%[1]s[] data, long... shape) {
+		int n = size(shape);
+		if (data.length != n) {
+			throw new IllegalArgumentException("shape " + Arrays.toString(shape) + " has " + n + " elements, not " + data.length);
+		}
+		this.data = data;
+		this.shape = shape.clone();
+	}
+
+	// zeros returns a new tensor of zeros.
+	public static %[2]s zeros(long... shape) {
+		return new %[2]s(new %[1]s[size(shape)], shape);
+	}
+
+	// fromStrided returns a copy in row-major order of the tensor with the
+	// element strides starting at offset in data, such as a view of an ND4J
+	// array or a gonum sub-matrix.
+	public static %[2]s fromStrided(%[1]s[] data, long offset, long[] shape, long[] strides) {
+		if (strides.length != shape.length) {
+			throw new IllegalArgumentException("shape " + Arrays.toString(shape) + " and strides " + Arrays.toString(strides) + " differ in rank");
+		}
+		%[1]s[] out = new %[1]s[size(shape)];
+		copy(data, offset, strides, out, rowMajorStrides(shape), shape);
+		return new %[2]s(out, shape);
+	}
+
+	// fromColumnMajor returns a copy in row-major order of the tensor stored in
+	// column-major order in data, such as an ojAlgo or Fortran-order array.
+	public static %[2]s fromColumnMajor(%[1]s[] data, long... shape) {
+		if (data.length != size(shape)) {
+			throw new IllegalArgumentException("shape " + Arrays.toString(shape) + " has " + size(shape) + " elements, not " + data.length);
+		}
+		return fromStrided(data, 0, shape, columnMajorStrides(shape));
+	}
+
+	// fromBuffer returns a copy of the remaining elements of b, in row-major
+	// order.
+	public static %[2]s fromBuffer(%[3]sBuffer b, long... shape) {
+		%[1]s[] data = new %[1]s[b.remaining()];
+		b.duplicate().get(data);
+		return new %[2]s(data, shape);
+	}
+
+	// data returns the elements in row-major order. It is not a copy.
+	public %[1]s[] data() {
+		return data;
+	}
+
+	public long[] shape() {
+		return shape.clone();
+	}
+
+	public int rank() {
+		return shape.length;
+	}
+
+	public int size() {
+		return data.length;
+	}
+
+	// strides returns the number of elements between consecutive indices of
+	// each dimension.
+	public long[] strides() {
+		return rowMajorStrides(shape);
+	}
+
+	public %[1]s get(long... index) {
+		return data[offset(index)];
+	}
+
+	public void set(%[1]s v, long... index) {
+		data[offset(index)] = v;
+	}
+
+	// reshape returns a tensor with the same elements and another shape. It
+	// shares the data of t.
+	public %[2]s reshape(long... shape) {
+		return new %[2]s(data, shape);
+	}
+
+	// toColumnMajor returns a copy of the elements in column-major order.
+	public %[1]s[] toColumnMajor() {
+		%[1]s[] out = new %[1]s[data.length];
+		copy(data, 0, rowMajorStrides(shape), out, columnMajorStrides(shape), shape);
+		return out;
+	}
+
+	// toBuffer returns a copy of the elements in a direct buffer in native
+	// byte order, for libraries reading off-heap memory.
+	public %[3]sBuffer toBuffer() {
+		%[3]sBuffer b = ByteBuffer.allocateDirect(data.length * %[4]d).order(ByteOrder.nativeOrder()).as%[3]sBuffer();
+		b.put(data);
+		b.flip();
+		return b;
+	}
+
+	public static long[] rowMajorStrides(long... shape) {
+		long[] strides = new long[shape.length];
+		long s = 1;
+		for (int d = shape.length - 1; d >= 0; d--) {
+			strides[d] = s;
+			s *= shape[d];
+		}
+		return strides;
+	}
+
+	public static long[] columnMajorStrides(long... shape) {
+		long[] strides = new long[shape.length];
+		long s = 1;
+		for (int d = 0; d < shape.length; d++) {
+			strides[d] = s;
+			s *= shape[d];
+		}
+		return strides;
+	}
+
+	@Override
+	public boolean equals(Object o) {
+		if (!(o instanceof %[2]s)) {
+			return false;
+		}
+		%[2]s t = (%[2]s) o;
+		return Arrays.equals(shape, t.shape) && Arrays.equals(data, t.data);
+	}
+
+	@Override
+	public int hashCode() {
+		return 31 * Arrays.hashCode(shape) + Arrays.hashCode(data);
+	}
+
+	@Override
+	public String toString() {
+		return "%[2]s" + Arrays.toString(shape);
+	}
+
+	// size returns the number of elements of a tensor with shape.
+	private static int size(long[] shape) {
+		long n = 1;
+		for (long d : shape) {
+			if (d < 0) {
+				throw new IllegalArgumentException("negative dimension in shape " + Arrays.toString(shape));
+			}
+			n *= d;
+			if (n > Integer.MAX_VALUE) {
+				throw new IllegalArgumentException("shape " + Arrays.toString(shape) + " is too large for a Java array");
+			}
+		}
+		return (int) n;
+	}
+
+	private int offset(long[] index) {
+		if (index.length != shape.length) {
+			throw new IllegalArgumentException("index " + Arrays.toString(index) + " for shape " + Arrays.toString(shape));
+		}
+		long off = 0;
+		for (int d = 0; d < index.length; d++) {
+			if (index[d] < 0 || index[d] >= shape[d]) {
+				throw new IndexOutOfBoundsException("index " + Arrays.toString(index) + " for shape " + Arrays.toString(shape));
+			}
+			off = off * shape[d] + index[d];
+		}
+		return (int) off;
+	}
+
+	// copy copies the elements of the tensor with shape from src, starting at
+	// srcOffset with srcStrides, to dst with dstStrides.
+	private static void copy(%[1]s[] src, long srcOffset, long[] srcStrides, %[1]s[] dst, long[] dstStrides, long[] shape) {
+		int n = size(shape);
+		long[] index = new long[shape.length];
+		long s = srcOffset, t = 0;
+		for (int i = 0; i < n; i++) {
+			dst[(int) t] = src[(int) s];
+			for (int d = shape.length - 1; d >= 0; d--) {
+				index[d]++;
+				s += srcStrides[d];
+				t += dstStrides[d];
+				if (index[d] < shape[d]) {
+					break;
+				}
+				s -= srcStrides[d] * shape[d];
+				t -= dstStrides[d] * shape[d];
+				index[d] = 0;
+			}
+		}
+	}
+}
+`
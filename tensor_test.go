@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTensorClasses(t *testing.T) {
+	classes := tensorClasses()
+	if len(classes) != 2 || classes[0].name != "GoFloatTensor" || classes[1].name != "GoDoubleTensor" {
+		t.Fatalf("got %d classes", len(classes))
+	}
+	for _, s := range []string{
+		"public final class GoDoubleTensor {",
+		"import java.nio.DoubleBuffer;",
+		"public GoDoubleTensor(double[] data, long... shape) {",
+		"ByteBuffer.allocateDirect(data.length * 8).order(ByteOrder.nativeOrder()).asDoubleBuffer();",
+		"the layout of a Go []float64",
+	} {
+		if !strings.Contains(classes[1].src, s) {
+			t.Errorf("GoDoubleTensor missing %q", s)
+		}
+	}
+	for _, c := range classes {
+		if strings.Contains(c.src, "%!") {
+			t.Errorf("%s has formatting errors:\n%s", c.name, c.src)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// callTimeoutFlag is the -call-timeout flag, holding the default timeout of
+// the calls of each bound package given as pkg=duration. It may be set more
+// than once.
+type callTimeoutFlag map[string]time.Duration
+
+func (c *callTimeoutFlag) String() string {
+	var s []string
+	for pkg, d := range *c {
+		s = append(s, pkg+"="+d.String())
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (c *callTimeoutFlag) Set(v string) error {
+	f := strings.SplitN(v, "=", 2)
+	if len(f) != 2 || f[0] == "" {
+		return fmt.Errorf("expected pkg=duration")
+	}
+	d, err := time.ParseDuration(f[1])
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q for %s", f[1], f[0])
+	}
+	if *c == nil {
+		*c = make(callTimeoutFlag)
+	}
+	(*c)[f[0]] = d
+	return nil
+}
+
+// packageTimeouts returns the -call-timeout of the bound packages typePkgs,
+// given by the import paths pkgs on the command line or by their full paths.
+// It returns an error if a package with a timeout is not bound.
+func packageTimeouts(timeouts callTimeoutFlag, pkgs []string, typePkgs []*types.Package) (map[*types.Package]time.Duration, error) {
+	found := make(map[*types.Package]time.Duration)
+	for path, d := range timeouts {
+		bound := false
+		for i, p := range typePkgs {
+			if path == pkgs[i] || path == p.Path() {
+				found[p], bound = d, true
+			}
+		}
+		if !bound {
+			return nil, fmt.Errorf("-call-timeout: %s is not a bound package", path)
+		}
+	}
+	return found, nil
+}
+
+// timedFunc returns the function or method of p called by the gobind proxy
+// decl with call, if it can be given a timeout, and whether it takes a
+// context.Context first, which is then given the deadline. Other functions
+// must return an error, which reports the timeout, and take no context.
+func timedFunc(f *ast.File, decl *ast.FuncDecl, call *ast.CallExpr, p *types.Package) (*types.Func, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || call.Ellipsis.IsValid() {
+		return nil, false
+	}
+	var obj types.Object
+	if x, ok := sel.X.(*ast.Ident); ok && x.Name == importName(f, p) {
+		obj = p.Scope().Lookup(sel.Sel.Name)
+	} else {
+		// Methods are called on the receiver unwrapped by the proxy, which
+		// is named after the type and method.
+		for _, name := range p.Scope().Names() {
+			t, ok := p.Scope().Lookup(name).(*types.TypeName)
+			if !ok || !strings.HasSuffix(decl.Name.Name, "_"+name+"_"+sel.Sel.Name) {
+				continue
+			}
+			recv := t.Type()
+			if !types.IsInterface(recv) {
+				recv = types.NewPointer(recv)
+			}
+			obj, _, _ = types.LookupFieldOrMethod(recv, false, p, sel.Sel.Name)
+		}
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok || !fn.Exported() {
+		return nil, false
+	}
+	sig := fn.Type().(*types.Signature)
+	for i, v := range tupleVars(sig.Params()) {
+		if v.Type().String() == "context.Context" {
+			if i != 0 || len(call.Args) != sig.Params().Len() {
+				return nil, false
+			}
+			return fn, true
+		}
+	}
+	res := sig.Results()
+	if res.Len() == 0 || !isError(res.At(res.Len()-1).Type()) {
+		return nil, false
+	}
+	return fn, false
+}
+
+// resultTypes returns the types of the results of fn as named in the file f,
+// and false if one of them cannot be named there.
+func resultTypes(f *ast.File, fn *types.Func) ([]string, bool) {
+	missing := false
+	qualifier := func(p *types.Package) string {
+		name := importName(f, p)
+		if name == "" {
+			missing = true
+		}
+		return name
+	}
+	var typs []string
+	for _, v := range tupleVars(fn.Type().(*types.Signature).Results()) {
+		typs = append(typs, types.TypeString(v.Type(), qualifier))
+	}
+	return typs, !missing
+}
+
+// resultList returns the result list of a function returning typs.
+func resultList(typs []string) string {
+	results := strings.Join(typs, ", ")
+	if len(typs) > 1 {
+		results = "(" + results + ")"
+	}
+	return results
+}
+
+// contextCall returns the expression replacing the call of fun with args in
+// the proxy of the file f, a call of fn, which takes a context.Context first.
+// fn is called with the context given as its first argument, or the
+// background context if it is nil, with the deadline timeout, so the call is
+// cancelled rather than left running. It returns "" if the types of the
+// results cannot be named in f.
+func contextCall(f *ast.File, fn *types.Func, fun string, args []string, timeout time.Duration) string {
+	typs, ok := resultTypes(f, fn)
+	if !ok {
+		return ""
+	}
+	lit, ret := "func() {", ""
+	if len(typs) > 0 {
+		lit, ret = "func() "+resultList(typs)+" {", "return "
+	}
+	return fmt.Sprintf("%s\n\t\tctx, cancel := gojavaContext(%s, %d)\n\t\tdefer cancel()\n\t\t%s%s(%s)\n\t}()",
+		lit, args[0], int64(timeout), ret, fun, strings.Join(append([]string{"ctx"}, args[1:]...), ", "))
+}
+
+// timedCall returns the expression replacing the call of fn in the proxy of
+// the file f, calling it through gojavaTimeout, or "" if the types of its
+// results cannot be named in f.
+func timedCall(f *ast.File, fn *types.Func, call string, timeout time.Duration) string {
+	typs, ok := resultTypes(f, fn)
+	if !ok {
+		return ""
+	}
+	vars, zeros := make([]string, len(typs)), make([]string, len(typs))
+	var b strings.Builder
+	for i, t := range typs {
+		vars[i] = fmt.Sprintf("r%d", i)
+		zeros[i] = fmt.Sprintf("*new(%s)", t)
+		fmt.Fprintf(&b, "\t\tvar r%d %s\n", i, t)
+	}
+	name := fn.Pkg().Name() + "." + fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		name = types.TypeString(t, (*types.Package).Name) + "." + fn.Name()
+	}
+	zeros[len(zeros)-1] = "err"
+	return fmt.Sprintf("func() %s {\n%s\t\tif err := gojavaTimeout(%q, %d, func() { %s = %s }); err != nil {\n\t\t\treturn %s\n\t\t}\n\t\treturn %s\n\t}()",
+		resultList(typs), b.String(), name, int64(timeout), strings.Join(vars, ", "), call, strings.Join(zeros, ", "), strings.Join(vars, ", "))
+}
+
+// addCallTimeouts rewrites the gobind proxies in goFile calling the functions
+// and methods of p to give them the deadline timeout: those taking a
+// context.Context first are passed a context with the deadline, and those
+// returning an error return one after timeout, leaving the call running.
+func addCallTimeouts(goFile string, p *types.Package, timeout time.Duration) error {
+	src, err := ioutil.ReadFile(goFile)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, goFile, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	text := func(n ast.Node) string {
+		return string(src[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset])
+	}
+	var edits []edit
+	for _, d := range f.Decls {
+		decl, ok := d.(*ast.FuncDecl)
+		if !ok || decl.Recv != nil || !strings.HasPrefix(decl.Name.Name, "proxy") {
+			continue
+		}
+		for _, s := range decl.Body.List {
+			var call *ast.CallExpr
+			switch s := s.(type) {
+			case *ast.AssignStmt:
+				if len(s.Rhs) == 1 {
+					call, _ = s.Rhs[0].(*ast.CallExpr)
+				}
+			case *ast.ExprStmt:
+				call, _ = s.X.(*ast.CallExpr)
+			}
+			if call == nil {
+				continue
+			}
+			fn, ctx := timedFunc(f, decl, call, p)
+			if fn == nil {
+				continue
+			}
+			at, end := fset.Position(call.Pos()).Offset, fset.Position(call.End()).Offset
+			var repl string
+			if ctx {
+				var args []string
+				for _, a := range call.Args {
+					args = append(args, text(a))
+				}
+				repl = contextCall(f, fn, text(call.Fun), args, timeout)
+			} else if _, ok := s.(*ast.AssignStmt); ok {
+				repl = timedCall(f, fn, text(call), timeout)
+			} else {
+				continue
+			}
+			if repl == "" {
+				verbosef("Calling %s.%s without a timeout, its results are not named in %s\n", p.Path(), fn.Name(), filepath.Base(goFile))
+				continue
+			}
+			edits = append(edits, edit{at, end, repl})
+		}
+	}
+	return ioutil.WriteFile(goFile, applyEdits(src, edits), 0600)
+}
+
+// genCallTimeouts writes gojavaTimeout, called by the proxies rewritten by
+// addCallTimeouts, to bindDir.
+func genCallTimeouts(bindDir string) error {
+	return ioutil.WriteFile(filepath.Join(bindDir, "gojava_timeouts.go"), []byte(callTimeoutsGo), 0600)
+}
+
+const callTimeoutsGo = `package gojava_bind
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// gojavaContext returns ctx, or the background context if it is nil, with
+// the deadline timeout from now.
+func gojavaContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// gojavaTimeout runs call on a goroutine, returning the error of the context
+// of the call if it does not return within timeout. The call, which takes no
+// context to cancel, is left running and its results are dropped: each
+// timeout leaks its goroutine, and what the call holds, until it returns.
+func gojavaTimeout(method string, timeout time.Duration, call func()) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		call()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: no result after %v: %w", method, timeout, ctx.Err())
+	}
+}
+`
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const timeoutsSrc = `package testpkg
+
+import "context"
+
+type Store struct{}
+
+func Fetch(ctx context.Context, k string) (string, error) { return "", nil }
+func Ping(ctx context.Context)                             {}
+func Late(k string, ctx context.Context) error             { return nil }
+
+func (s *Store) Load(k string) (string, error) { return "", nil }
+
+func Save(k string) error { return nil }
+func Size() int64 { return 0 }
+`
+
+const timeoutsProxy = `package gojava_bind
+
+// #include "seq.h"
+import "C"
+
+import (
+	"example.com/testpkg"
+	_seq "golang.org/x/mobile/bind/seq"
+)
+
+//export proxytestpkg_Store_Load
+func proxytestpkg_Store_Load(refnum C.int32_t, param_k C.nstring) (C.nstring, C.int32_t) {
+	ref := _seq.FromRefNum(int32(refnum))
+	v := ref.Get().(*testpkg.Store)
+	_param_k := decodeString(param_k)
+	res_0, res_1 := v.Load(_param_k)
+	_res_0 := encodeString(res_0)
+	var _res_1 C.int32_t = _seq.NullRefNum
+	return _res_0, _res_1
+}
+
+//export proxytestpkg__Save
+func proxytestpkg__Save(param_k C.nstring) C.int32_t {
+	_param_k := decodeString(param_k)
+	res_0 := testpkg.Save(_param_k)
+	var _res_0 C.int32_t = _seq.NullRefNum
+	return _res_0
+}
+
+//export proxytestpkg__Fetch
+func proxytestpkg__Fetch(param_ctx C.int32_t, param_k C.nstring) (C.nstring, C.int32_t) {
+	_param_ctx := decodeContext(param_ctx)
+	_param_k := decodeString(param_k)
+	res_0, res_1 := testpkg.Fetch(_param_ctx, _param_k)
+	_res_0 := encodeString(res_0)
+	var _res_1 C.int32_t = _seq.NullRefNum
+	return _res_0, _res_1
+}
+
+//export proxytestpkg__Ping
+func proxytestpkg__Ping(param_ctx C.int32_t) {
+	_param_ctx := decodeContext(param_ctx)
+	testpkg.Ping(_param_ctx)
+}
+
+//export proxytestpkg__Late
+func proxytestpkg__Late(param_k C.nstring, param_ctx C.int32_t) C.int32_t {
+	_param_k := decodeString(param_k)
+	_param_ctx := decodeContext(param_ctx)
+	res_0 := testpkg.Late(_param_k, _param_ctx)
+	var _res_0 C.int32_t = _seq.NullRefNum
+	return _res_0
+}
+
+//export proxytestpkg__Size
+func proxytestpkg__Size() C.int64_t {
+	res_0 := testpkg.Size()
+	_res_0 := C.int64_t(res_0)
+	return _res_0
+}
+`
+
+func TestAddCallTimeouts(t *testing.T) {
+	var timeouts callTimeoutFlag
+	if err := timeouts.Set("example.com/testpkg=2s"); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"example.com/testpkg", "example.com/testpkg=-1s", "=1s"} {
+		if err := timeouts.Set(v); err == nil {
+			t.Errorf("Set(%q): expected error", v)
+		}
+	}
+	p, _ := typeCheckFile(t, timeoutsSrc)
+	found, err := packageTimeouts(timeouts, []string{"./testpkg"}, []*types.Package{p})
+	if err != nil || found[p] != 2*time.Second {
+		t.Fatalf("got %v, %v", found, err)
+	}
+	if _, err := packageTimeouts(callTimeoutFlag{"example.com/other": time.Second}, []string{"./testpkg"}, []*types.Package{p}); err == nil {
+		t.Error("expected error for a package that is not bound")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	goFile := filepath.Join(tmpDir, "go_testpkgmain.go")
+	if err := ioutil.WriteFile(goFile, []byte(timeoutsProxy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addCallTimeouts(goFile, p, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(goFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), goFile, src, 0); err != nil {
+		t.Fatalf("%v:\n%s", err, src)
+	}
+	for _, want := range []string{
+		"\tres_0, res_1 := func() (string, error) {\n\t\tvar r0 string\n\t\tvar r1 error\n\t\tif err := gojavaTimeout(\"testpkg.Store.Load\", 2000000000, func() { r0, r1 = v.Load(_param_k) }); err != nil {\n\t\t\treturn *new(string), err\n\t\t}\n\t\treturn r0, r1\n\t}()\n",
+		"\tres_0 := func() error {\n\t\tvar r0 error\n\t\tif err := gojavaTimeout(\"testpkg.Save\", 2000000000, func() { r0 = testpkg.Save(_param_k) }); err != nil {\n\t\t\treturn err\n",
+		"\tres_0 := testpkg.Size()\n",
+		"\tres_0, res_1 := func() (string, error) {\n\t\tctx, cancel := gojavaContext(_param_ctx, 2000000000)\n\t\tdefer cancel()\n\t\treturn testpkg.Fetch(ctx, _param_k)\n\t}()\n",
+		"\tfunc() {\n\t\tctx, cancel := gojavaContext(_param_ctx, 2000000000)\n\t\tdefer cancel()\n\t\ttestpkg.Ping(ctx)\n\t}()\n",
+		"\tres_0 := testpkg.Late(_param_k, _param_ctx)\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("proxies do not contain %q:\n%s", want, src)
+		}
+	}
+}
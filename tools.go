@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// javaTool returns the path of the JDK tool name. In order of preference this
+// is override, the value of $GOJAVA_<NAME>, the tool in $JAVA_HOME/bin, or
+// name itself to be found in $PATH.
+func javaTool(name, override string) string {
+	if override != "" {
+		return override
+	}
+	if env := os.Getenv("GOJAVA_" + strings.ToUpper(name)); env != "" {
+		return env
+	}
+	if javaHome != "" {
+		p := filepath.Join(javaHome, "bin", name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return name
+}
+
+// optionsFile returns the arguments passing the options file path to a JDK
+// tool, or nil if path is empty.
+func optionsFile(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return []string{"@" + path}
+}
+
+// jarWithTool creates target from the contents of jarDir using the jar tool.
+func jarWithTool(cfg *config, jarDir string) error {
+	verbosef("Building %s with %s\n", cfg.target, cfg.jarTool)
+	mode := "cf"
+	if cfg.compression == storeEntries {
+		mode = "c0f"
+	}
+	args := append(optionsFile(cfg.jarOpts), mode, targetPath(cfg))
+	if cfg.digests {
+		manifest, err := jarManifest(jarDir)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(filepath.Dir(jarDir), "MANIFEST.MF")
+		if err := ioutil.WriteFile(path, manifest, 0600); err != nil {
+			return err
+		}
+		args[len(args)-2] += "m"
+		args = append(args, path)
+	}
+	args = append(args, "-C", jarDir, ".")
+	if err := runWithArgFile(javaTool("jar", cfg.jarTool), args); err != nil {
+		os.Remove(targetPath(cfg))
+		return err
+	}
+	return nil
+}
+
+// signJar signs the jar at cfg.target with jarsigner, if a jarsigner options
+// file was provided. The options file must contain the keystore options and
+// is followed by the jar and, if set, cfg.signAlias.
+func signJar(cfg *config) error {
+	if cfg.jarsignerOpts == "" {
+		return nil
+	}
+	args := append(optionsFile(cfg.jarsignerOpts), targetPath(cfg))
+	if cfg.signAlias != "" {
+		args = append(args, cfg.signAlias)
+	}
+	verbosef("Signing %s\n", cfg.target)
+	return runCommand(javaTool("jarsigner", cfg.jarsigner), args...)
+}
+
+// quoteArg quotes arg for use in a JDK tool argument file.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\r\f\"'#\\") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\f", `\f`).Replace(arg) + `"`
+}
+
+// runWithArgFile runs the JDK tool cmd with args passed in an @argfile,
+// avoiding command line length limits when there are many arguments.
+func runWithArgFile(cmd string, args []string) error {
+	f, err := ioutil.TempFile("", "gojava-args")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	for _, a := range args {
+		if _, err := f.WriteString(quoteArg(a) + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	verbosef("%s %s\n", cmd, strings.Join(args, " "))
+	return runCommand(cmd, "@"+f.Name())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJavaTool(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	javac := filepath.Join(tmpDir, "bin", "javac")
+	if err := os.MkdirAll(filepath.Dir(javac), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(javac, nil, 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldHome, oldEnv := javaHome, os.Getenv("GOJAVA_JAVAC")
+	defer func() {
+		javaHome = oldHome
+		os.Setenv("GOJAVA_JAVAC", oldEnv)
+	}()
+	javaHome = tmpDir
+	os.Setenv("GOJAVA_JAVAC", "")
+
+	if p := javaTool("javac", "/opt/jdk/bin/javac"); p != "/opt/jdk/bin/javac" {
+		t.Errorf("override not used: %s", p)
+	}
+	if p := javaTool("javac", ""); p != javac {
+		t.Errorf("$JAVA_HOME/bin/javac not used: %s", p)
+	}
+	if p := javaTool("jarsigner", ""); p != "jarsigner" {
+		t.Errorf("expected jarsigner from $PATH, got %s", p)
+	}
+	os.Setenv("GOJAVA_JAVAC", "/env/javac")
+	if p := javaTool("javac", ""); p != "/env/javac" {
+		t.Errorf("$GOJAVA_JAVAC not used: %s", p)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	for in, want := range map[string]string{
+		"/tmp/src/Foo.java":    "/tmp/src/Foo.java",
+		"/My Documents/A.java": `"/My Documents/A.java"`,
+		`C:\src\A.java`:        `"C:\\src\\A.java"`,
+		"":                     `""`,
+	} {
+		if got := quoteArg(in); got != want {
+			t.Errorf("quoteArg(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// accStatic and accNative are the access flags of static and native methods
+// in class files.
+const (
+	accStatic = 0x0008
+	accNative = 0x0100
+)
+
+// classNative is a native method declared in a class file.
+type classNative struct {
+	// class is the binary name of the class in internal form, e.g. go/Seq.
+	class string
+	name  string
+	// desc is the method descriptor, e.g. (JJ)J.
+	desc   string
+	static bool
+}
+
+// String returns the Java name of the method.
+func (n classNative) String() string {
+	return strings.Replace(n.class, "/", ".", -1) + "." + n.name
+}
+
+// jniNames returns the short and long names of the JNI function implementing n.
+func (n classNative) jniNames() (string, string) {
+	short := "Java_" + jniMangle(n.class) + "_" + jniMangle(n.name)
+	args := n.desc[1:strings.IndexByte(n.desc, ')')]
+	return short, short + "__" + jniMangle(args)
+}
+
+// errClassFormat is returned for malformed class files.
+var errClassFormat = errors.New("malformed class file")
+
+// classReader reads the big endian values of a class file.
+type classReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *classReader) u1() int {
+	var v uint8
+	r.read(&v)
+	return int(v)
+}
+
+func (r *classReader) u2() int {
+	var v uint16
+	r.read(&v)
+	return int(v)
+}
+
+func (r *classReader) u4() int {
+	var v uint32
+	r.read(&v)
+	return int(v)
+}
+
+func (r *classReader) read(v interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.BigEndian, v)
+	}
+}
+
+func (r *classReader) skip(n int) {
+	if r.err == nil {
+		_, r.err = r.r.Seek(int64(n), io.SeekCurrent)
+	}
+}
+
+// members reads the fields or methods of a class file, returning the native
+// methods if methods is set.
+func (r *classReader) members(utf8 map[int]string, methods bool) []classNative {
+	var natives []classNative
+	for i, n := 0, r.u2(); i < n && r.err == nil; i++ {
+		access, name, desc := r.u2(), r.u2(), r.u2()
+		for j, attrs := 0, r.u2(); j < attrs && r.err == nil; j++ {
+			r.skip(2)
+			r.skip(r.u4())
+		}
+		if methods && access&accNative != 0 {
+			natives = append(natives, classNative{name: utf8[name], desc: utf8[desc], static: access&accStatic != 0})
+		}
+	}
+	return natives
+}
+
+// classFile is the part of a class file read by gojava.
+type classFile struct {
+	// name is the binary name of the class in internal form.
+	name    string
+	natives []classNative
+	// sourceFile is the base name of the source file the class was compiled
+	// from, or "" if it is not recorded.
+	sourceFile string
+}
+
+// classNatives returns the native methods declared in the class file d.
+func classNatives(d []byte) ([]classNative, error) {
+	c, err := readClass(d)
+	if err != nil {
+		return nil, err
+	}
+	return c.natives, nil
+}
+
+// readClass reads the class file d.
+func readClass(d []byte) (*classFile, error) {
+	r := &classReader{r: bytes.NewReader(d)}
+	if r.u4() != 0xCAFEBABE {
+		return nil, errClassFormat
+	}
+	r.skip(4)
+	utf8 := make(map[int]string)
+	classes := make(map[int]int)
+	for i, n := 1, r.u2(); i < n && r.err == nil; i++ {
+		switch tag := r.u1(); tag {
+		case 1:
+			b := make([]byte, r.u2())
+			r.read(b)
+			utf8[i] = string(b)
+		case 7:
+			classes[i] = r.u2()
+		case 8, 16, 19, 20:
+			r.skip(2)
+		case 15:
+			r.skip(3)
+		case 3, 4, 9, 10, 11, 12, 17, 18:
+			r.skip(4)
+		case 5, 6:
+			// Longs and doubles take two entries.
+			r.skip(8)
+			i++
+		default:
+			return nil, errClassFormat
+		}
+	}
+	r.skip(2)
+	c := &classFile{name: utf8[classes[r.u2()]]}
+	r.skip(2)
+	r.skip(2 * r.u2())
+	r.members(utf8, false)
+	c.natives = r.members(utf8, true)
+	for i, n := 0, r.u2(); i < n && r.err == nil; i++ {
+		name, length := r.u2(), r.u4()
+		if utf8[name] == "SourceFile" && length == 2 {
+			c.sourceFile = utf8[r.u2()]
+		} else {
+			r.skip(length)
+		}
+	}
+	if r.err != nil {
+		return nil, errClassFormat
+	}
+	for i := range c.natives {
+		c.natives[i].class = c.name
+	}
+	return c, nil
+}
+
+// librarySymbols returns the symbols exported by the shared library at path,
+// or nil if its format is not supported.
+func librarySymbols(path string) (map[string]bool, error) {
+	syms := make(map[string]bool)
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		dyn, err := f.DynamicSymbols()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range dyn {
+			if s.Section != elf.SHN_UNDEF {
+				syms[s.Name] = true
+			}
+		}
+		return syms, nil
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		if f.Symtab == nil {
+			return syms, nil
+		}
+		for _, s := range f.Symtab.Syms {
+			// External symbols defined in a section, without the leading _.
+			if s.Type&0x01 != 0 && s.Sect != 0 {
+				syms[strings.TrimPrefix(s.Name, "_")] = true
+			}
+		}
+		return syms, nil
+	}
+	return nil, nil
+}
+
+// verifyNatives checks that every native method of the classes compiled to
+// jarDir in the root Java package and the Java packages of pkgs has a JNI
+// function in the native library at lib, so mangling errors fail the build
+// instead of throwing UnsatisfiedLinkError at run time.
+func verifyNatives(jarDir, root, lib string, pkgs []*types.Package, moved map[string]string) error {
+	syms, err := librarySymbols(lib)
+	if err != nil {
+		return err
+	}
+	if syms == nil {
+		verbosef("Not verifying native methods, %s is not an ELF or Mach-O library\n", lib)
+		return nil
+	}
+	natives, err := jarNatives(jarDir, root, pkgs, moved)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, n := range natives {
+		short, long := n.jniNames()
+		if !syms[short] && !syms[long] {
+			missing = append(missing, fmt.Sprintf("%s (%s)", n, short))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("native methods without a JNI function in %s:\n\t%s", filepath.Base(lib), strings.Join(missing, "\n\t"))
+	}
+	return nil
+}
+
+// jarNatives returns the native methods of the classes compiled to jarDir in
+// the root Java package and the Java packages of pkgs, in moved if moved by
+// -javapkg-for.
+func jarNatives(jarDir, root string, pkgs []*types.Package, moved map[string]string) ([]classNative, error) {
+	dirs := []string{filepath.Join(jarDir, javaRootDir(root))}
+	for _, p := range pkgs {
+		dirs = append(dirs, filepath.Join(jarDir, javaRootDir(packageJavaPkg(root, moved, p))))
+	}
+	var natives []classNative
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.class"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files {
+			d, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			n, err := classNatives(d)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			natives = append(natives, n...)
+		}
+	}
+	return natives, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// writeClass returns a class file for class with the methods in methods,
+// given as name, descriptor and access flags.
+func writeClass(t *testing.T, class string, methods [][3]interface{}) []byte {
+	var b bytes.Buffer
+	w := func(vs ...interface{}) {
+		for _, v := range vs {
+			if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	utf8 := func(s string) {
+		w(uint8(1), uint16(len(s)))
+		b.WriteString(s)
+	}
+	w(uint32(0xCAFEBABE), uint16(0), uint16(52))
+	// 1 and 2 are the class, 3 a long taking 3 and 4, 5 and 6 the superclass.
+	w(uint16(7 + 2*len(methods)))
+	utf8(class)
+	w(uint8(7), uint16(1))
+	w(uint8(5), uint64(42))
+	utf8("java/lang/Object")
+	w(uint8(7), uint16(5))
+	for _, m := range methods {
+		utf8(m[0].(string))
+		utf8(m[1].(string))
+	}
+	w(uint16(0x21), uint16(2), uint16(6), uint16(0), uint16(0))
+	w(uint16(len(methods)))
+	for i, m := range methods {
+		w(uint16(m[2].(int)), uint16(7+2*i), uint16(8+2*i), uint16(1), uint16(7), uint32(2), uint16(0))
+	}
+	w(uint16(0))
+	return b.Bytes()
+}
+
+func TestClassNatives(t *testing.T) {
+	d := writeClass(t, "go/testpkg/Testpkg$S", [][3]interface{}{
+		{"add", "(JJ)J", 0x0109},
+		{"toString", "()Ljava/lang/String;", 0x0001},
+		{"set_name", "(Ljava/lang/String;[B)V", 0x0101},
+	})
+	natives, err := classNatives(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []classNative{
+		{"go/testpkg/Testpkg$S", "add", "(JJ)J", true},
+		{"go/testpkg/Testpkg$S", "set_name", "(Ljava/lang/String;[B)V", false},
+	}
+	if !reflect.DeepEqual(natives, expected) {
+		t.Fatalf("got %v, want %v", natives, expected)
+	}
+	short, long := natives[1].jniNames()
+	if want := "Java_go_testpkg_Testpkg_00024S_set_1name"; short != want {
+		t.Errorf("got short name %s, want %s", short, want)
+	}
+	if want := "Java_go_testpkg_Testpkg_00024S_set_1name__Ljava_lang_String_2_3B"; long != want {
+		t.Errorf("got long name %s, want %s", long, want)
+	}
+	if _, err := classNatives(d[:len(d)-10]); err == nil {
+		t.Error("expected error for truncated class file")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// jniSymbols are the patterns of the symbols the JVM looks up in the native
+// library.
+var jniSymbols = []string{"Java_*", "JNI_OnLoad", "JNI_OnUnload"}
+
+// exportList returns the contents of the linker file restricting the symbols
+// exported by a native library for goos to syms, and the linker flag using
+// the file at path. It returns nil if the linker for goos is not supported.
+func exportList(goos, path string, syms []string) ([]byte, string) {
+	var b bytes.Buffer
+	switch goos {
+	case "darwin", "ios":
+		for _, s := range syms {
+			fmt.Fprintf(&b, "_%s\n", s)
+		}
+		return b.Bytes(), "-Wl,-exported_symbols_list," + path
+	case "windows", "plan9":
+		return nil, ""
+	}
+	b.WriteString("{\n\tglobal:\n")
+	for _, s := range syms {
+		fmt.Fprintf(&b, "\t\t%s;\n", s)
+	}
+	b.WriteString("\tlocal:\n\t\t*;\n};\n")
+	return b.Bytes(), "-Wl,--version-script=" + path
+}
+
+// hideSymbols writes the files to bindDir limiting the symbols exported by the
+// native library for goos to the JNI functions, along with the Go exports if
+// goExports is set, and returns the external linker flags using them. Unless
+// goExports is set, the C code of the bind package is also compiled with
+// hidden visibility, so only the functions marked JNIEXPORT are visible.
+func hideSymbols(bindDir, goos string, goExports bool) ([]string, error) {
+	syms := append([]string{}, jniSymbols...)
+	if goExports {
+		if err := forEachExport(bindDir, func(name string, fn *ast.FuncDecl) {
+			syms = append(syms, name)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	path := filepath.Join(bindDir, "gojava_exports.txt")
+	list, flag := exportList(goos, path, syms)
+	if list == nil {
+		verbosef("Exporting all symbols of the native library, the %s linker is not supported\n", goos)
+		return nil, nil
+	}
+	if err := ioutil.WriteFile(path, list, 0600); err != nil {
+		return nil, err
+	}
+	if !goExports {
+		if err := ioutil.WriteFile(filepath.Join(bindDir, "gojava_visibility.go"), []byte(hiddenVisibilityGo), 0600); err != nil {
+			return nil, err
+		}
+	}
+	return []string{flag}, nil
+}
+
+const hiddenVisibilityGo = `package gojava_bind
+
+// #cgo CFLAGS: -fvisibility=hidden
+import "C"
+`
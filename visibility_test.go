@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHideSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		goos      string
+		goExports bool
+		list      string
+		flag      string
+		hidden    bool
+	}{
+		{"linux", false, "{\n\tglobal:\n\t\tJava_*;\n\t\tJNI_OnLoad;\n\t\tJNI_OnUnload;\n\tlocal:\n\t\t*;\n};\n", "-Wl,--version-script=", true},
+		{"android", true, "{\n\tglobal:\n\t\tJava_*;\n\t\tJNI_OnLoad;\n\t\tJNI_OnUnload;\n\t\tgojava_init;\n\tlocal:\n\t\t*;\n};\n", "-Wl,--version-script=", false},
+		{"darwin", false, "_Java_*\n_JNI_OnLoad\n_JNI_OnUnload\n", "-Wl,-exported_symbols_list,", true},
+		{"windows", false, "", "", false},
+	} {
+		tmpDir, err := ioutil.TempDir("", "gojavatest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+		src := "package gojava_bind\n\nimport \"C\"\n\n//export gojava_init\nfunc gojava_init() *C.char { return nil }\n"
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "gojava_init.go"), []byte(src), 0600); err != nil {
+			t.Fatal(err)
+		}
+		flags, err := hideSymbols(tmpDir, tc.goos, tc.goExports)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listPath := filepath.Join(tmpDir, "gojava_exports.txt")
+		var expected []string
+		if tc.flag != "" {
+			expected = []string{tc.flag + listPath}
+		}
+		if !reflect.DeepEqual(flags, expected) {
+			t.Errorf("%s: got flags %q, want %q", tc.goos, flags, expected)
+		}
+		if list, _ := ioutil.ReadFile(listPath); string(list) != tc.list {
+			t.Errorf("%s: got export list %q, want %q", tc.goos, list, tc.list)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "gojava_visibility.go")); (err == nil) != tc.hidden {
+			t.Errorf("%s: got hidden visibility %v, want %v", tc.goos, err == nil, tc.hidden)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// govulncheckFound is the exit status of govulncheck when it finds
+// vulnerabilities.
+const govulncheckFound = 3
+
+// vulncheckFlag is the -vulncheck flag. Used as a bool flag it fails the
+// build on known vulnerabilities; -vulncheck=warn only reports them.
+type vulncheckFlag string
+
+func (v *vulncheckFlag) String() string {
+	return string(*v)
+}
+
+func (v *vulncheckFlag) Set(s string) error {
+	switch s {
+	case "true", "fail":
+		*v = "fail"
+	case "false":
+		*v = ""
+	case "warn":
+		*v = "warn"
+	default:
+		return fmt.Errorf("must be fail or warn")
+	}
+	return nil
+}
+
+func (v *vulncheckFlag) IsBoolFlag() bool {
+	return true
+}
+
+// vulncheck runs govulncheck in dir on the packages pkgs and their dependencies,
+// returning an error if it finds vulnerabilities affecting them, unless mode
+// is warn. govulncheck is $GOJAVA_GOVULNCHECK, or found in $PATH.
+func vulncheck(mode vulncheckFlag, dir string, pkgs []string) error {
+	if mode == "" {
+		return nil
+	}
+	tool := os.Getenv("GOJAVA_GOVULNCHECK")
+	if tool == "" {
+		tool = "govulncheck"
+	}
+	verbosef("Checking %s for known vulnerabilities\n", strings.Join(pkgs, " "))
+	c := exec.CommandContext(buildCtx, tool, pkgs...)
+	c.Dir = dir
+	c.Env = commandEnv()
+	var out bytes.Buffer
+	w := verboseWriter(&out, "govulncheck")
+	c.Stdout, c.Stderr = w, w
+	err := c.Run()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == govulncheckFound {
+		if mode == "warn" {
+			fmt.Fprintf(os.Stderr, "warning: govulncheck found vulnerabilities:\n%s", out.String())
+			return nil
+		}
+		return fmt.Errorf("govulncheck found vulnerabilities:\n%s\nPass -vulncheck=warn to build anyway", out.String())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", tool, err, out.String())
+	}
+	return nil
+}
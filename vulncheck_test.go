@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVulncheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as govulncheck")
+	}
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tool := filepath.Join(tmpDir, "govulncheck")
+	script := "#!/bin/sh\nif [ \"$1\" = vulnerable ]; then echo \"Vulnerability #1: GO-2024-0001\"; exit 3; fi\nif [ \"$1\" = broken ]; then exit 1; fi\n"
+	if err := ioutil.WriteFile(tool, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldEnv := os.Getenv("GOJAVA_GOVULNCHECK")
+	defer os.Setenv("GOJAVA_GOVULNCHECK", oldEnv)
+	os.Setenv("GOJAVA_GOVULNCHECK", tool)
+
+	for _, tc := range []struct {
+		mode vulncheckFlag
+		pkg  string
+		err  string
+	}{
+		{"", "broken", ""},
+		{"fail", "clean", ""},
+		{"fail", "vulnerable", "GO-2024-0001"},
+		{"warn", "vulnerable", ""},
+		{"warn", "broken", "exit status 1"},
+	} {
+		err := vulncheck(tc.mode, "", []string{tc.pkg})
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s %s: unexpected error %v", tc.mode, tc.pkg, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s %s: got error %v, want %q", tc.mode, tc.pkg, err, tc.err)
+		}
+	}
+}
+
+func TestVulncheckFlag(t *testing.T) {
+	var v vulncheckFlag
+	for _, tc := range []struct {
+		value, expected string
+	}{
+		{"true", "fail"},
+		{"warn", "warn"},
+		{"false", ""},
+		{"fail", "fail"},
+	} {
+		if err := v.Set(tc.value); err != nil || string(v) != tc.expected {
+			t.Errorf("Set(%q): got %q, %v, want %q", tc.value, v, err, tc.expected)
+		}
+	}
+	if err := v.Set("ignore"); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
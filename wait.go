@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io/ioutil"
+)
+
+// waitMethod reports whether *n has a Wait() or Wait() error method, which
+// blocks until background work started by n completes.
+func waitMethod(n *types.Named) bool {
+	sig := methodSig(n, "Wait")
+	if sig == nil || sig.Params().Len() != 0 {
+		return false
+	}
+	res := sig.Results()
+	return res.Len() == 0 || (res.Len() == 1 && isError(res.At(0).Type()))
+}
+
+// addWaitHandles rewrites the generated Java file for p at path, making the
+// classes of struct types with a Wait method implement go.GoWaitHandle, so
+// Java code can wait for the Go work they track with go.GoFuture. It must run
+// after escapeReserved, as it calls the renamed Wait method.
+func addWaitHandles(path string, p *types.Package) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	changed := false
+	scope := p.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok || !waitMethod(n) {
+			continue
+		}
+		decl, start, end, err := classBody(src, p, name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if len(memberDecls(src, start, end, "await")) > 0 {
+			continue
+		}
+		src = implement(src, decl, start-1, "go.GoWaitHandle")
+		members := fmt.Sprintf("\n\t\t@Override\n\t\tpublic void await() throws Exception {\n\t\t\t%s();\n\t\t}\n", finalMethodName(p, "Wait"))
+		if src, err = insertIntoClass(src, name, members); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return ioutil.WriteFile(path, src, 0600)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const waitSrc = `package testpkg
+
+type Job struct{}
+
+func (j *Job) Wait() error { return nil }
+
+type Group struct{}
+
+func (g *Group) Wait() {}
+
+type Timer struct{}
+
+func (t *Timer) Wait(d int) {}
+`
+
+const waitJava = `package go.testpkg;
+
+public abstract class Testpkg {
+    public static final class Job extends Seq.Proxy {
+        public native void wait_() throws Exception;
+    }
+
+    public static final class Group extends Seq.Proxy {
+        public native void wait_();
+    }
+
+    public static final class Timer extends Seq.Proxy {
+        public native void wait_(long d);
+    }
+}
+`
+
+func TestAddWaitHandles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(path, []byte(waitJava), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := addWaitHandles(path, typeCheck(t, waitSrc)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(d)
+	for _, s := range []string{
+		"class Job extends Seq.Proxy implements go.GoWaitHandle {\n\t\t@Override\n\t\tpublic void await() throws Exception {\n\t\t\twait_();",
+		"class Group extends Seq.Proxy implements go.GoWaitHandle {",
+		"class Timer extends Seq.Proxy {\n",
+	} {
+		if !strings.Contains(src, s) {
+			t.Errorf("missing %q:\n%s", s, src)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// buildWasm builds the WebAssembly module in dir to classDir, for go.GoWasm.
+func buildWasm(classDir, dir string, mod *goModule, overlay string) error {
+	verbosef("Building the WebAssembly module\n")
+	env := commandEnv()
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env, "GOOS=wasip1", "GOARCH=wasm", "CGO_ENABLED=0")
+	// A c-shared module is a WASI reactor, initialized once and then called
+	// through its exports.
+	args := append([]string{"build", "-buildmode=c-shared", "-o", filepath.Join(classDir, "gojava.wasm")}, modFlags(mod, overlay)...)
+	return runCommandEnv(dir, env, "go", append(args, ".")...)
+}
+
+const wasmGoHeader = `// Command gojava.wasm calls the bound Go functions for go.GoWasm, when the
+// bindings are built with -backend wasm.
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+
+`
+
+const wasmGoMain = `
+// main is not run, the module is a reactor called through its exports.
+func main() {}
+
+// gojavaBuffers keeps the buffers shared with go.GoWasm alive, by address.
+var gojavaBuffers = map[uint32][]byte{}
+
+func gojavaKeep(b []byte) uint32 {
+	if cap(b) == 0 {
+		// An empty result still needs an address of its own.
+		b = make([]byte, 0, 1)
+	}
+	p := uint32(uintptr(unsafe.Pointer(unsafe.SliceData(b))))
+	gojavaBuffers[p] = b
+	return p
+}
+
+// gojavaAlloc returns the address of n bytes for the arguments of a call.
+//
+//go:wasmexport gojava_alloc
+func gojavaAlloc(n int32) uint32 {
+	return gojavaKeep(make([]byte, n))
+}
+
+// gojavaFree releases the result of a call, once go.GoWasm has read it.
+//
+//go:wasmexport gojava_free
+func gojavaFree(p uint32) {
+	delete(gojavaBuffers, p)
+}
+
+// gojavaCallWasm calls gojavaCall with the n bytes of arguments at args,
+// allocated by gojavaAlloc and released by the call. It returns the address
+// of the result in the high 32 bits and its length in the low 32 bits.
+//
+//go:wasmexport gojava_call
+func gojavaCallWasm(fn int32, args uint32, n int32) uint64 {
+	a := gojavaBuffers[args][:n]
+	delete(gojavaBuffers, args)
+	res := gojavaCall(int(fn), a)
+	return uint64(gojavaKeep(res))<<32 | uint64(len(res))
+}
+`
+
+const goWasmJava = `package go;
+
+import com.dylibso.chicory.runtime.ExportFunction;
+import com.dylibso.chicory.runtime.ImportValues;
+import com.dylibso.chicory.runtime.Instance;
+import com.dylibso.chicory.runtime.Machine;
+import com.dylibso.chicory.wasi.WasiOptions;
+import com.dylibso.chicory.wasi.WasiPreview1;
+import com.dylibso.chicory.wasm.Parser;
+import com.dylibso.chicory.wasm.WasmModule;
+import java.io.IOException;
+import java.io.InputStream;
+import java.lang.reflect.Method;
+
+// GoWasm calls the bound Go functions in the WebAssembly module of bindings
+// built with -backend wasm, go/gojava.wasm, run by the pure Java Chicory
+// runtime. The module runs one call at a time, and can only use the files,
+// environment and standard streams of the JVM granted by the WASI options
+// given to configure, by default standard output and error. The module is
+// compiled to JVM bytecode if the Chicory compiler is on the class path, and
+// interpreted otherwise.
+public final class GoWasm {
+	private static WasiOptions options;
+	private static WasmModule module;
+	private static WasiPreview1 wasi;
+	private static Instance instance;
+	private static ExportFunction alloc, free, call;
+
+	private GoWasm() {}
+
+	// configure sets the WASI options of the module, such as the directories
+	// it may open. It must be called before the first call.
+	public static synchronized void configure(WasiOptions o) {
+		if (instance != null) {
+			throw new IllegalStateException("the Go WebAssembly module is already running");
+		}
+		options = o;
+	}
+
+	// call calls the function fn with the encoded arguments args. A module
+	// that traps is discarded, and the next call starts a new one.
+	static synchronized byte[] call(int fn, byte[] args) {
+		try {
+			start();
+			int p = (int) alloc.apply(args.length)[0];
+			instance.memory().write(p, args);
+			long r = call.apply(fn, p, args.length)[0];
+			int res = (int) (r >>> 32);
+			try {
+				return instance.memory().readBytes(res, (int) r);
+			} finally {
+				free.apply(res);
+			}
+		} catch (GoUnavailableException e) {
+			stop();
+			throw e;
+		} catch (RuntimeException e) {
+			stop();
+			throw new GoUnavailableException("the Go WebAssembly module failed", e);
+		}
+	}
+
+	private static void start() {
+		if (instance != null) {
+			return;
+		}
+		if (module == null) {
+			try (InputStream in = GoWasm.class.getResourceAsStream("gojava.wasm")) {
+				if (in == null) {
+					throw new GoUnavailableException("go/gojava.wasm is not on the class path", null);
+				}
+				module = Parser.parse(in);
+			} catch (IOException e) {
+				throw new GoUnavailableException("cannot read go/gojava.wasm", e);
+			}
+		}
+		WasiOptions o = options;
+		if (o == null) {
+			o = WasiOptions.builder().withStdout(System.out).withStderr(System.err).build();
+		}
+		wasi = WasiPreview1.builder().withOptions(o).build();
+		Instance.Builder b = Instance.builder(module)
+			.withImportValues(ImportValues.builder().addFunction(wasi.toHostFunctions()).build())
+			.withStart(false);
+		compile(b);
+		instance = b.build();
+		// The module is a WASI reactor, whose initialization runs the Go
+		// runtime and the init functions of the bound packages.
+		instance.export("_initialize").apply();
+		alloc = instance.export("gojava_alloc");
+		free = instance.export("gojava_free");
+		call = instance.export("gojava_call");
+	}
+
+	private static void stop() {
+		instance = null;
+		alloc = free = call = null;
+		if (wasi != null) {
+			try {
+				wasi.close();
+			} catch (Exception e) {
+				// The module is discarded anyway.
+			}
+			wasi = null;
+		}
+	}
+
+	// compile makes b compile the module with the Chicory compiler, if it is
+	// on the class path.
+	private static void compile(Instance.Builder b) {
+		final Method m;
+		try {
+			m = Class.forName("com.dylibso.chicory.compiler.MachineFactoryCompiler").getMethod("compile", Instance.class);
+		} catch (ClassNotFoundException | NoSuchMethodException e) {
+			return;
+		}
+		b.withMachineFactory(i -> {
+			try {
+				return (Machine) m.invoke(null, i);
+			} catch (ReflectiveOperationException e) {
+				throw new IllegalStateException("cannot compile the Go WebAssembly module", e);
+			}
+		});
+	}
+}
+`
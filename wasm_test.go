@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/format"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWasmBackend(t *testing.T) {
+	p, docs := typeCheckFile(t, convertSrc)
+	tmpDir, err := ioutil.TempDir("", "gojavatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	classPath := filepath.Join(tmpDir, "Testpkg.java")
+	if err := ioutil.WriteFile(classPath, []byte(processClass), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := genConverters(tmpDir, tmpDir, []*types.Package{p}, nil, docs, "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 8 || filepath.Base(files[7]) != "GoWasm.java" {
+		t.Errorf("got files %v, want GoWasm.java last", files)
+	}
+	d, err := ioutil.ReadFile(classPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "Seq.touch") || strings.Contains(string(d), "static {") {
+		t.Errorf("Testpkg.java loads the native library:\n%s", d)
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "GoConvert.java"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(d), goConvertCallWasm) || strings.Contains(string(d), "GoProcess.call") {
+		t.Errorf("GoConvert.java does not call GoWasm:\n%s", d)
+	}
+	d, err = ioutil.ReadFile(filepath.Join(tmpDir, "gojava_server", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := format.Source(d); err != nil {
+		t.Errorf("module: %v\n%s", err, d)
+	}
+	for _, s := range []string{"package main\n", "\t\tv := conv1.Plain(a0)\n", "//go:wasmexport gojava_call\n", "func main() {}\n"} {
+		if !strings.Contains(string(d), s) {
+			t.Errorf("module missing %q:\n%s", s, d)
+		}
+	}
+	if strings.Contains(string(d), `import "C"`) {
+		t.Errorf("module uses cgo:\n%s", d)
+	}
+}
+
+func TestConvertedParamNames(t *testing.T) {
+	p, docs := typeCheckFile(t, `package testpkg
+
+import "time"
+
+func Wait(w, r time.Duration) {}
+`)
+	funcs := findConvertedFuncs(p, nil, docs, false)
+	if len(funcs) != 1 {
+		t.Fatalf("got %d converted funcs, want 1", len(funcs))
+	}
+	if m := convertedJavaMethod(funcs[0], 0); !strings.Contains(m, "wait(java.time.Duration arg0, java.time.Duration arg1)") {
+		t.Errorf("parameters named w and r are not renamed:\n%s", m)
+	}
+}